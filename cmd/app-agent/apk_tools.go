@@ -73,7 +73,7 @@ func (b *Bridge) pushStoredAPKPackage(toUser, groupID, fileID, fileName, owner,
 	}
 
 	if toUser != "" {
-		if err := b.sendExistingAttachmentMessage(toUser, content, "file", nil, attachment); err != nil {
+		if err := b.sendExistingAttachmentMessage(toUser, content, "file", nil, attachment, nil); err != nil {
 			return nil, err
 		}
 		b.recordApkVersion(toUser, version)
@@ -59,9 +59,10 @@ type AppAttachment struct {
 
 // Bridge bridges app messages and UAP messages.
 type Bridge struct {
-	cfg    *Config
-	client *uap.Client
-	groups *groupManager
+	cfg     *Config
+	client  *uap.Client
+	groups  *groupManager
+	history *groupHistoryStore
 
 	lastEventTime map[string]time.Time // session_id:event_type → 上次推送时间
 	eventMu       sync.Mutex
@@ -73,7 +74,12 @@ type Bridge struct {
 	nextSequence    int64
 	pendingMessages map[string]*pendingMessage
 	pendingByUser   map[string][]string
+	readReceipts    map[string]*readReceiptRoute // push message id → users yet to read
 	clients         map[string]map[*appClientConn]struct{}
+	sendReceipt     func(to string, payload uap.DeliveryReceiptPayload) error
+
+	pendingSaveTimer *time.Timer // guarded by deliveryMu
+	pendingSaveMu    sync.Mutex  // serializes store writes
 
 	// delegation tokens by user
 	delegationTokens map[string]string
@@ -148,6 +154,17 @@ func NewBridge(cfg *Config) *Bridge {
 				}
 			}`),
 		},
+		{
+			Name:        "app.ListUndelivered",
+			Description: "List app pushes not yet acknowledged by the user's device, grouped by user with an online flag. Use it to fall back to another channel (e.g. WeChat) when the app is offline; origin_message_id matches the message ID of the original notify or tool call.",
+			Parameters: json.RawMessage(`{
+				"type":"object",
+				"properties":{
+					"to_user":{"type":"string","description":"Optional app user ID; omit to list every user with undelivered messages"},
+					"min_age_sec":{"type":"integer","description":"Only include messages queued at least this many seconds ago"}
+				}
+			}`),
+		},
 	}
 	client.Capacity = 20
 	client.Meta = map[string]any{
@@ -157,11 +174,13 @@ func NewBridge(cfg *Config) *Bridge {
 	b := &Bridge{
 		cfg:               cfg,
 		client:            client,
-		groups:            newGroupManager(cfg.GroupStoreFile),
+		groups:            newGroupManager(cfg.GroupStoreFile, cfg.groupRouteAgents()),
+		history:           newGroupHistoryStore(cfg.GroupHistoryDir, cfg.GroupHistoryLimit),
 		lastEventTime:     make(map[string]time.Time),
 		sessionUsers:      make(map[string]string),
 		pendingMessages:   make(map[string]*pendingMessage),
 		pendingByUser:     make(map[string][]string),
+		readReceipts:      make(map[string]*readReceiptRoute),
 		clients:           make(map[string]map[*appClientConn]struct{}),
 		delegationTokens:  make(map[string]string),
 		lastApkVersions:   make(map[string]string),
@@ -169,6 +188,12 @@ func NewBridge(cfg *Config) *Bridge {
 		downloadTickets:   newDownloadTicketSigner(cfg),
 		downloadTicketTTL: time.Duration(cfg.DownloadTicketTTLSeconds) * time.Second,
 	}
+	b.sendReceipt = func(to string, payload uap.DeliveryReceiptPayload) error {
+		return client.SendTo(to, uap.MsgDeliveryReceipt, payload)
+	}
+	if err := b.loadPending(); err != nil {
+		log.Printf("[Bridge] load pending store failed: %v", err)
+	}
 	client.OnMessage = b.handleUAPMessage
 	return b
 }
@@ -180,6 +205,7 @@ func (b *Bridge) Run() {
 func (b *Bridge) Stop() {
 	b.client.Stop()
 	b.closeAllClients()
+	b.flushPendingStore()
 }
 
 func (b *Bridge) IsConnected() bool {
@@ -451,7 +477,7 @@ func (b *Bridge) PushUploadedAPK(toUser, content, fileName string, src io.Reader
 	// Record the version after successful storage
 	b.recordApkVersion(toUser, version)
 
-	if err := b.sendExistingAttachmentMessage(toUser, content, "file", nil, attachment); err != nil {
+	if err := b.sendExistingAttachmentMessage(toUser, content, "file", nil, attachment, nil); err != nil {
 		return nil, err
 	}
 	return attachment, nil
@@ -576,27 +602,34 @@ func (b *Bridge) handleGroupMessage(groupID string, msg *AppMessage, attachment
 	if !ok {
 		return fmt.Errorf("group robot account not found")
 	}
-	return b.forwardGroupMessageToLLM(groupID, msg.UserID, robotAccount, b.buildAppContentForAgent(msg, attachment, groupID))
+	route, ok := b.groups.ResolveRoute(groupID, msg.Content, msg.Meta, b.cfg.LLMAgentID)
+	if !ok {
+		log.Printf("[Bridge] group message not routed group=%s from=%s: robot not mentioned", groupID, msg.UserID)
+		return nil
+	}
+	routed := *msg
+	routed.Content = route.Content
+	return b.forwardGroupMessageToAgent(groupID, msg.UserID, robotAccount, route, b.buildGroupContentForAgent(&routed, attachment, groupID, route))
 }
 
-func (b *Bridge) forwardGroupMessageToLLM(groupID, fromUser, robotAccount, content string) error {
+func (b *Bridge) forwardGroupMessageToAgent(groupID, fromUser, robotAccount string, route groupRoute, content string) error {
 	if !b.IsConnected() {
 		return fmt.Errorf("gateway disconnected")
 	}
-	if strings.TrimSpace(b.cfg.LLMAgentID) == "" {
-		return fmt.Errorf("llm routing is not configured")
+	if strings.TrimSpace(route.AgentID) == "" {
+		return fmt.Errorf("group routing is not configured")
 	}
 
-	llmContent := strings.TrimSpace(content)
+	agentContent := strings.TrimSpace(content)
 	payload := uap.NotifyPayload{
 		Channel: "app",
 		To:      robotAccount,
-		Content: llmContent,
+		Content: agentContent,
 	}
-	log.Printf("[Bridge] route group message to llm group=%s from=%s robot_account=%s len=%d content=%q",
-		groupID, fromUser, robotAccount, len(llmContent), shortText(llmContent))
-	if err := b.client.SendTo(b.cfg.LLMAgentID, uap.MsgNotify, payload); err != nil {
-		return fmt.Errorf("send to %s failed: %w", b.cfg.LLMAgentID, err)
+	log.Printf("[Bridge] route group message group=%s from=%s agent=%s mention=%s robot_account=%s len=%d content=%q",
+		groupID, fromUser, route.AgentID, route.Mention, robotAccount, len(agentContent), shortText(agentContent))
+	if err := b.client.SendTo(route.AgentID, uap.MsgNotify, payload); err != nil {
+		return fmt.Errorf("send to %s failed: %w", route.AgentID, err)
 	}
 	return nil
 }
@@ -732,6 +765,25 @@ func (b *Bridge) buildAppContentForAgent(msg *AppMessage, attachment *AppAttachm
 	if msg == nil {
 		return ""
 	}
+	return marshalAppContentForAgent(msg, appMessagePayloadForAgent(msg, attachment, groupID))
+}
+
+// buildGroupContentForAgent 在 app_message 基础上附带触发路由的 @ 与群人设
+func (b *Bridge) buildGroupContentForAgent(msg *AppMessage, attachment *AppAttachment, groupID string, route groupRoute) string {
+	if msg == nil {
+		return ""
+	}
+	payload := appMessagePayloadForAgent(msg, attachment, groupID)
+	if route.Mention != "" {
+		payload["mention"] = route.Mention
+	}
+	if route.Persona != "" {
+		payload["persona"] = route.Persona
+	}
+	return marshalAppContentForAgent(msg, payload)
+}
+
+func appMessagePayloadForAgent(msg *AppMessage, attachment *AppAttachment, groupID string) map[string]any {
 	payload := map[string]any{
 		"kind":         "app_message",
 		"user_id":      msg.UserID,
@@ -750,6 +802,10 @@ func (b *Bridge) buildAppContentForAgent(msg *AppMessage, attachment *AppAttachm
 	if msg.Meta != nil {
 		payload["meta"] = sanitizeAppMetaForForward(msg.Meta)
 	}
+	return payload
+}
+
+func marshalAppContentForAgent(msg *AppMessage, payload map[string]any) string {
 	data, err := json.MarshalIndent(payload, "", "  ")
 	if err != nil {
 		return strings.TrimSpace(msg.Content)
@@ -893,7 +949,10 @@ func (b *Bridge) handleUAPMessage(msg *uap.Message) {
 		log.Printf("[Bridge] notify payload from=%s channel=%s to=%s len=%d content=%q",
 			msg.From, payload.Channel, payload.To, len(payload.Content), shortText(payload.Content))
 		if payload.Channel == "app" && payload.To != "" {
-			b.sendNotification(payload.To, payload.Content)
+			origin := &pushOrigin{AgentID: msg.From, MessageID: msg.ID}
+			if err := b.sendNotificationMessage(payload.To, payload.Content, "text", nil, origin); err != nil {
+				log.Printf("[Bridge] app push failed for user=%s: %v", payload.To, err)
+			}
 		}
 
 	case uap.MsgToolCall:
@@ -923,6 +982,7 @@ func (b *Bridge) handleUAPMessage(msg *uap.Message) {
 
 func (b *Bridge) handleToolCall(msg *uap.Message, payload *uap.ToolCallPayload) {
 	var result uap.ToolResultPayload
+	origin := &pushOrigin{AgentID: msg.From, MessageID: msg.ID}
 
 	switch payload.ToolName {
 	case "app.SendMessage":
@@ -934,7 +994,7 @@ func (b *Bridge) handleToolCall(msg *uap.Message, payload *uap.ToolCallPayload)
 			result = uap.BuildToolError(msg.ID, fmt.Sprintf("invalid arguments: %v", err))
 			break
 		}
-		if err := b.sendNotificationMessage(strings.TrimSpace(args.ToUser), strings.TrimSpace(args.Content), "text", nil); err != nil {
+		if err := b.sendNotificationMessage(strings.TrimSpace(args.ToUser), strings.TrimSpace(args.Content), "text", nil, origin); err != nil {
 			result = uap.BuildToolError(msg.ID, fmt.Sprintf("send failed: %v", err))
 		} else {
 			result = uap.BuildToolResult(msg.ID, nil, "message queued")
@@ -955,6 +1015,7 @@ func (b *Bridge) handleToolCall(msg *uap.Message, payload *uap.ToolCallPayload)
 			strings.TrimSpace(args.Content),
 			normalizeAppMessageType(args.MessageType, args.Meta),
 			args.Meta,
+			origin,
 		); err != nil {
 			result = uap.BuildToolError(msg.ID, fmt.Sprintf("send failed: %v", err))
 		} else {
@@ -1009,6 +1070,26 @@ func (b *Bridge) handleToolCall(msg *uap.Message, payload *uap.ToolCallPayload)
 		} else {
 			result = uap.BuildToolResult(msg.ID, pushResult, "apk package queued")
 		}
+	case "app.ListUndelivered":
+		var args struct {
+			ToUser    string `json:"to_user"`
+			MinAgeSec int    `json:"min_age_sec"`
+		}
+		if len(payload.Arguments) > 0 {
+			if err := json.Unmarshal(payload.Arguments, &args); err != nil {
+				result = uap.BuildToolError(msg.ID, fmt.Sprintf("invalid arguments: %v", err))
+				break
+			}
+		}
+		users := b.listUndelivered(strings.TrimSpace(args.ToUser), time.Duration(args.MinAgeSec)*time.Second)
+		total := 0
+		for _, user := range users {
+			total += len(user.Messages)
+		}
+		result = uap.BuildToolResult(msg.ID, map[string]any{
+			"count": total,
+			"users": users,
+		}, "undelivered messages listed")
 
 	default:
 		result = uap.BuildToolError(msg.ID, fmt.Sprintf("unknown tool: %s", payload.ToolName))
@@ -1212,12 +1293,12 @@ func truncateForApp(content string) string {
 }
 
 func (b *Bridge) sendNotification(toUser, content string) {
-	if err := b.sendNotificationMessage(toUser, content, "text", nil); err != nil {
+	if err := b.sendNotificationMessage(toUser, content, "text", nil, nil); err != nil {
 		log.Printf("[Bridge] app push failed for user=%s: %v", toUser, err)
 	}
 }
 
-func (b *Bridge) sendNotificationMessage(toUser, content, messageType string, meta map[string]any) error {
+func (b *Bridge) sendNotificationMessage(toUser, content, messageType string, meta map[string]any, origin *pushOrigin) error {
 	if toUser == "" {
 		log.Printf("[Bridge] skip notification: empty user")
 		return nil
@@ -1238,10 +1319,10 @@ func (b *Bridge) sendNotificationMessage(toUser, content, messageType string, me
 		}
 		pushMeta = sanitizeAppMetaForPush(pushMeta)
 	}
-	return b.sendExistingAttachmentMessage(toUser, content, messageType, pushMeta, attachment)
+	return b.sendExistingAttachmentMessage(toUser, content, messageType, pushMeta, attachment, origin)
 }
 
-func (b *Bridge) sendExistingAttachmentMessage(toUser, content, messageType string, meta map[string]any, attachment *AppAttachment) error {
+func (b *Bridge) sendExistingAttachmentMessage(toUser, content, messageType string, meta map[string]any, attachment *AppAttachment, origin *pushOrigin) error {
 	if attachment != nil && strings.TrimSpace(content) == "" {
 		if attachment.FileName != "" {
 			content = attachment.FileName
@@ -1271,7 +1352,7 @@ func (b *Bridge) sendExistingAttachmentMessage(toUser, content, messageType stri
 	}
 	pushMeta := b.buildPushMetaForUser(meta, attachment, toUser)
 	log.Printf("[Bridge] deliver notification user=%s len=%d content=%q", toUser, len(content), shortText(content))
-	return b.sendAppPushWithType(toUser, content, messageType, pushMeta)
+	return b.sendAppPushWithType(toUser, content, messageType, pushMeta, origin)
 }
 
 func (b *Bridge) broadcastGroupMessage(groupID, fromUser, content, messageType string, meta map[string]any) error {
@@ -1331,6 +1412,8 @@ func (b *Bridge) broadcastGroupMessageWithAttachment(
 		}
 	}
 
+	b.recordGroupHistory(groupID, displayFrom, content, messageType, meta, attachment)
+
 	messageID := buildPushMessageID(groupID)
 	for _, member := range recipients {
 		log.Printf("[Bridge] queue group message group=%s to_member=%s from=%s type=%s message_id=%s",
@@ -1347,6 +1430,7 @@ func (b *Bridge) broadcastGroupMessageWithAttachment(
 			content,
 			messageType,
 			b.buildPushMetaForUser(pushMeta, attachment, member),
+			nil,
 		); err != nil {
 			return err
 		}
@@ -1356,11 +1440,30 @@ func (b *Bridge) broadcastGroupMessageWithAttachment(
 	return nil
 }
 
+func (b *Bridge) recordGroupHistory(groupID, fromUser, content, messageType string, meta map[string]any, attachment *AppAttachment) {
+	historyMeta := buildPushMeta(sanitizeAppMetaForPush(meta), attachment)
+	for _, key := range []string{"scope", "group_id", "from_user", "members", "local_only", "account"} {
+		delete(historyMeta, key)
+	}
+	if len(historyMeta) == 0 {
+		historyMeta = nil
+	}
+	if _, err := b.history.Append(groupID, groupHistoryEntry{
+		FromUser:    fromUser,
+		Content:     content,
+		MessageType: messageType,
+		Meta:        historyMeta,
+	}); err != nil {
+		log.Printf("[Bridge] record group history failed group=%s: %v", groupID, err)
+	}
+}
+
 func (b *Bridge) sendAppPush(toUser, content string, meta map[string]any) error {
-	return b.sendAppPushWithType(toUser, content, "text", meta)
+	return b.sendAppPushWithType(toUser, content, "text", meta, nil)
 }
 
-func (b *Bridge) sendAppPushWithType(toUser, content, messageType string, meta map[string]any) error {
+// sendAppPushWithType queues a push; origin, when set, receives delivery/read receipts.
+func (b *Bridge) sendAppPushWithType(toUser, content, messageType string, meta map[string]any, origin *pushOrigin) error {
 	if strings.TrimSpace(toUser) == "" {
 		return fmt.Errorf("empty user")
 	}
@@ -1379,7 +1482,7 @@ func (b *Bridge) sendAppPushWithType(toUser, content, messageType string, meta m
 	if payload.MessageType == "" {
 		payload.MessageType = "text"
 	}
-	return b.enqueueAndDeliver(payload)
+	return b.enqueueAndDeliver(payload, origin)
 }
 
 func buildPushMeta(baseMeta map[string]any, attachment *AppAttachment) map[string]any {
@@ -33,6 +33,9 @@ type Config struct {
 	AppSessionTTLMinutes     int              `json:"app_session_ttl_minutes,omitempty"`
 	AppRefreshTokenTTLHours  int              `json:"app_refresh_token_ttl_hours,omitempty"`
 	GroupStoreFile           string           `json:"group_store_file,omitempty"`
+	PendingStoreFile         string           `json:"pending_store_file,omitempty"`  // 待送达推送队列持久化文件，空则仅内存
+	GroupHistoryDir          string           `json:"group_history_dir,omitempty"`   // 群消息记录目录，空则仅内存
+	GroupHistoryLimit        int              `json:"group_history_limit,omitempty"` // 每群保留条数
 	AttachmentStoreDir       string           `json:"attachment_store_dir,omitempty"`
 	ObsAgentBaseURL          string           `json:"obs_agent_base_url,omitempty"`
 	ObsAgentToken            string           `json:"obs_agent_token,omitempty"`
@@ -43,6 +46,8 @@ type Config struct {
 	LLMAgentID     string `json:"llm_agent_id"`
 	CmdAgentID     string `json:"cmd_agent_id,omitempty"`
 	BackendAgentID string `json:"backend_agent_id"`
+	// GroupAgentIDs 群配置（agent_id / agents）可路由到的 agent，llm_agent_id 始终允许
+	GroupAgentIDs []string `json:"group_agent_ids,omitempty"`
 
 	ProtectedFiles []string `json:"protected_files,omitempty"`
 
@@ -62,6 +67,7 @@ func DefaultConfig() *Config {
 		AppSessionTTLMinutes:     2880,
 		AppRefreshTokenTTLHours:  720,
 		GroupStoreFile:           "app-groups.json",
+		GroupHistoryLimit:        defaultGroupHistoryLimit,
 		AttachmentStoreDir:       "app-attachments",
 		DownloadTicketTTLSeconds: 300,
 		LLMAgentID:               "llm-agent",
@@ -107,9 +113,23 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.AttachmentStoreDir == "" {
 		cfg.AttachmentStoreDir = "app-attachments"
 	}
+	if cfg.PendingStoreFile == "" {
+		cfg.PendingStoreFile = "app-pending.json"
+	}
+	if cfg.GroupHistoryDir == "" {
+		cfg.GroupHistoryDir = "app-group-history"
+	}
+	if cfg.GroupHistoryLimit <= 0 {
+		cfg.GroupHistoryLimit = defaultGroupHistoryLimit
+	}
 	return cfg, nil
 }
 
+// groupRouteAgents 群路由允许的 agent ID
+func (c *Config) groupRouteAgents() []string {
+	return append([]string{c.LLMAgentID}, c.GroupAgentIDs...)
+}
+
 func writeDefaultConfig(path string, cfg interface{}) error {
 	if _, err := os.Stat(path); err == nil {
 		return fmt.Errorf("config file already exists: %s", path)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -13,27 +14,68 @@ import (
 
 const groupRobotDisplayName = "@robot"
 
+// 群角色：owner > admin > member
+const (
+	groupRoleOwner  = "owner"
+	groupRoleAdmin  = "admin"
+	groupRoleMember = "member"
+)
+
+// 群回复模式：mention 仅在 @robot / @别名 时转发给 agent，all 转发每条消息
+const (
+	groupReplyMention = "mention"
+	groupReplyAll     = "all"
+)
+
+var groupAgentAliasPattern = regexp.MustCompile(`^[\p{L}\p{N}_.-]{1,32}$`)
+
 type appGroup struct {
 	ID           string
 	Owner        string
 	HumanMembers map[string]bool
+	Admins       map[string]bool
 	RobotAccount string
+	Settings     groupSettings
 	CreatedAt    time.Time
 }
 
+// groupSettings 群级路由配置
+type groupSettings struct {
+	ReplyMode  string            `json:"reply_mode,omitempty"` // mention（默认）/ all
+	AgentID    string            `json:"agent_id,omitempty"`   // 默认路由目标，空则使用 llm_agent_id
+	Persona    string            `json:"persona,omitempty"`    // 随消息附带的人设说明
+	Agents     map[string]string `json:"agents,omitempty"`     // @别名 → agent ID
+	InviteOnly bool              `json:"invite_only,omitempty"`
+}
+
+// groupSettingsPatch 仅更新非 nil 字段
+type groupSettingsPatch struct {
+	ReplyMode  *string           `json:"reply_mode"`
+	AgentID    *string           `json:"agent_id"`
+	Persona    *string           `json:"persona"`
+	Agents     map[string]string `json:"agents"`
+	InviteOnly *bool             `json:"invite_only"`
+}
+
 type groupInfo struct {
-	ID           string   `json:"id"`
-	Members      []string `json:"members"`
-	CreatedAt    int64    `json:"created_at"`
-	RobotAccount string   `json:"robot_account,omitempty"`
+	ID           string        `json:"id"`
+	Members      []string      `json:"members"`
+	CreatedAt    int64         `json:"created_at"`
+	RobotAccount string        `json:"robot_account,omitempty"`
+	Owner        string        `json:"owner"`
+	Admins       []string      `json:"admins,omitempty"`
+	Role         string        `json:"role"`
+	Settings     groupSettings `json:"settings"`
 }
 
 type groupStoreRecord struct {
-	ID           string   `json:"id"`
-	Owner        string   `json:"owner"`
-	HumanMembers []string `json:"human_members"`
-	RobotAccount string   `json:"robot_account"`
-	CreatedAt    int64    `json:"created_at"`
+	ID           string        `json:"id"`
+	Owner        string        `json:"owner"`
+	HumanMembers []string      `json:"human_members"`
+	Admins       []string      `json:"admins,omitempty"`
+	RobotAccount string        `json:"robot_account"`
+	Settings     groupSettings `json:"settings"`
+	CreatedAt    int64         `json:"created_at"`
 }
 
 type groupStoreFile struct {
@@ -41,15 +83,22 @@ type groupStoreFile struct {
 }
 
 type groupManager struct {
-	mu        sync.RWMutex
-	groups    map[string]*appGroup
-	storePath string
+	mu            sync.RWMutex
+	groups        map[string]*appGroup
+	storePath     string
+	allowedAgents map[string]bool // 群配置可路由的 agent ID
 }
 
-func newGroupManager(storePath string) *groupManager {
+func newGroupManager(storePath string, allowedAgents []string) *groupManager {
 	m := &groupManager{
-		groups:    make(map[string]*appGroup),
-		storePath: strings.TrimSpace(storePath),
+		groups:        make(map[string]*appGroup),
+		storePath:     strings.TrimSpace(storePath),
+		allowedAgents: make(map[string]bool, len(allowedAgents)),
+	}
+	for _, agentID := range allowedAgents {
+		if agentID = strings.TrimSpace(agentID); agentID != "" {
+			m.allowedAgents[agentID] = true
+		}
 	}
 	if err := m.load(); err != nil {
 		fmt.Printf("[GroupManager] load failed: %v\n", err)
@@ -74,6 +123,7 @@ func (m *groupManager) Create(groupID, owner, robotAccount string) error {
 		ID:           groupID,
 		Owner:        owner,
 		HumanMembers: map[string]bool{owner: true},
+		Admins:       make(map[string]bool),
 		RobotAccount: robotAccount,
 		CreatedAt:    time.Now(),
 	}
@@ -93,6 +143,12 @@ func (m *groupManager) Join(groupID, userID string) error {
 	if !ok {
 		return fmt.Errorf("group not found")
 	}
+	if group.HumanMembers[userID] {
+		return nil
+	}
+	if group.Settings.InviteOnly {
+		return fmt.Errorf("group %s is invite-only", groupID)
+	}
 	group.HumanMembers[userID] = true
 	return m.saveLocked()
 }
@@ -110,13 +166,191 @@ func (m *groupManager) Leave(groupID, userID string) error {
 	if !ok {
 		return fmt.Errorf("group not found")
 	}
-	delete(group.HumanMembers, userID)
+	removeGroupMember(group, userID)
 	if len(group.HumanMembers) == 0 {
 		delete(m.groups, groupID)
 	}
 	return m.saveLocked()
 }
 
+// Invite 由 admin/owner 直接拉人入群，不受 invite_only 限制
+func (m *groupManager) Invite(groupID, actor, target string) error {
+	groupID = normalizeGroupID(groupID)
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return fmt.Errorf("target_user is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, err := m.groupForActorLocked(groupID, actor, groupRoleAdmin)
+	if err != nil {
+		return err
+	}
+	if target == group.RobotAccount {
+		return fmt.Errorf("robot account cannot be invited")
+	}
+	if group.HumanMembers[target] {
+		return nil
+	}
+	group.HumanMembers[target] = true
+	return m.saveLocked()
+}
+
+// Kick 移出成员：owner 可移出任何人，admin 只能移出普通成员
+func (m *groupManager) Kick(groupID, actor, target string) error {
+	groupID = normalizeGroupID(groupID)
+	actor = strings.TrimSpace(actor)
+	target = strings.TrimSpace(target)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, err := m.groupForActorLocked(groupID, actor, groupRoleAdmin)
+	if err != nil {
+		return err
+	}
+	if target == actor {
+		return fmt.Errorf("use leave to exit the group")
+	}
+	if !group.HumanMembers[target] {
+		return fmt.Errorf("%s is not a member of group %s", target, groupID)
+	}
+	if groupRoleRank(groupRoleOf(group, target)) >= groupRoleRank(groupRoleOf(group, actor)) {
+		return fmt.Errorf("cannot remove %s: insufficient role", target)
+	}
+	removeGroupMember(group, target)
+	return m.saveLocked()
+}
+
+// SetRole 仅 owner 可调整角色；设置 owner 即转让群主，原群主降为 admin
+func (m *groupManager) SetRole(groupID, actor, target, role string) error {
+	groupID = normalizeGroupID(groupID)
+	actor = strings.TrimSpace(actor)
+	target = strings.TrimSpace(target)
+	role = strings.ToLower(strings.TrimSpace(role))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, err := m.groupForActorLocked(groupID, actor, groupRoleOwner)
+	if err != nil {
+		return err
+	}
+	if !group.HumanMembers[target] {
+		return fmt.Errorf("%s is not a member of group %s", target, groupID)
+	}
+	if target == actor {
+		return fmt.Errorf("owner role can only be transferred to another member")
+	}
+	if group.Admins == nil {
+		group.Admins = make(map[string]bool)
+	}
+	switch role {
+	case groupRoleOwner:
+		group.Owner = target
+		delete(group.Admins, target)
+		group.Admins[actor] = true
+	case groupRoleAdmin:
+		group.Admins[target] = true
+	case groupRoleMember:
+		delete(group.Admins, target)
+	default:
+		return fmt.Errorf("unknown role %q", role)
+	}
+	return m.saveLocked()
+}
+
+// UpdateSettings 由 admin/owner 修改群路由配置
+func (m *groupManager) UpdateSettings(groupID, actor string, patch groupSettingsPatch) error {
+	groupID = normalizeGroupID(groupID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, err := m.groupForActorLocked(groupID, actor, groupRoleAdmin)
+	if err != nil {
+		return err
+	}
+	settings := group.Settings
+	if patch.ReplyMode != nil {
+		mode := strings.ToLower(strings.TrimSpace(*patch.ReplyMode))
+		if mode != groupReplyMention && mode != groupReplyAll {
+			return fmt.Errorf("reply_mode must be %s or %s", groupReplyMention, groupReplyAll)
+		}
+		settings.ReplyMode = mode
+	}
+	if patch.AgentID != nil {
+		agentID := strings.TrimSpace(*patch.AgentID)
+		if agentID != "" && !m.allowedAgents[agentID] {
+			return fmt.Errorf("agent %q is not allowed for group routing", agentID)
+		}
+		settings.AgentID = agentID
+	}
+	if patch.Persona != nil {
+		settings.Persona = strings.TrimSpace(*patch.Persona)
+	}
+	if patch.Agents != nil {
+		agents := make(map[string]string, len(patch.Agents))
+		for alias, agentID := range patch.Agents {
+			alias = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(alias), "@"))
+			agentID = strings.TrimSpace(agentID)
+			if !groupAgentAliasPattern.MatchString(alias) || agentID == "" {
+				return fmt.Errorf("invalid agent alias %q", alias)
+			}
+			if alias == strings.TrimPrefix(groupRobotDisplayName, "@") {
+				return fmt.Errorf("alias %q is reserved", alias)
+			}
+			if !m.allowedAgents[agentID] {
+				return fmt.Errorf("agent %q is not allowed for group routing", agentID)
+			}
+			agents[alias] = agentID
+		}
+		settings.Agents = agents
+	}
+	if patch.InviteOnly != nil {
+		settings.InviteOnly = *patch.InviteOnly
+	}
+	group.Settings = settings
+	return m.saveLocked()
+}
+
+func (m *groupManager) Settings(groupID string) (groupSettings, bool) {
+	groupID = normalizeGroupID(groupID)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	group := m.groups[groupID]
+	if group == nil {
+		return groupSettings{}, false
+	}
+	settings := group.Settings
+	settings.Agents = cloneStringMap(group.Settings.Agents)
+	return settings, true
+}
+
+func (m *groupManager) Role(groupID, userID string) string {
+	groupID = normalizeGroupID(groupID)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	group := m.groups[groupID]
+	if group == nil {
+		return ""
+	}
+	return groupRoleOf(group, strings.TrimSpace(userID))
+}
+
+func (m *groupManager) groupForActorLocked(groupID, actor, minRole string) (*appGroup, error) {
+	group, ok := m.groups[groupID]
+	if !ok {
+		return nil, fmt.Errorf("group not found")
+	}
+	role := groupRoleOf(group, strings.TrimSpace(actor))
+	if role == "" {
+		return nil, fmt.Errorf("you are not a member of group %s", groupID)
+	}
+	if groupRoleRank(role) < groupRoleRank(minRole) {
+		return nil, fmt.Errorf("%s role required", minRole)
+	}
+	return group, nil
+}
+
 func (m *groupManager) HasMember(groupID, userID string) bool {
 	groupID = normalizeGroupID(groupID)
 	userID = strings.TrimSpace(userID)
@@ -139,11 +373,17 @@ func (m *groupManager) ListForUser(userID string) []groupInfo {
 		if !group.HumanMembers[userID] {
 			continue
 		}
+		settings := group.Settings
+		settings.Agents = cloneStringMap(group.Settings.Agents)
 		result = append(result, groupInfo{
 			ID:           group.ID,
 			Members:      visibleMembers(group.HumanMembers),
 			CreatedAt:    group.CreatedAt.UnixMilli(),
 			RobotAccount: group.RobotAccount,
+			Owner:        group.Owner,
+			Admins:       sortedHumanMembers(group.Admins),
+			Role:         groupRoleOf(group, userID),
+			Settings:     settings,
 		})
 	}
 	sort.Slice(result, func(i, j int) bool {
@@ -232,11 +472,20 @@ func (m *groupManager) load() error {
 		if len(members) == 0 && strings.TrimSpace(record.Owner) != "" {
 			members[strings.TrimSpace(record.Owner)] = true
 		}
+		admins := make(map[string]bool)
+		for _, admin := range record.Admins {
+			admin = strings.TrimSpace(admin)
+			if members[admin] {
+				admins[admin] = true
+			}
+		}
 		m.groups[groupID] = &appGroup{
 			ID:           groupID,
 			Owner:        strings.TrimSpace(record.Owner),
 			HumanMembers: members,
+			Admins:       admins,
 			RobotAccount: strings.TrimSpace(record.RobotAccount),
+			Settings:     record.Settings,
 			CreatedAt:    time.UnixMilli(record.CreatedAt),
 		}
 		if m.groups[groupID].CreatedAt.IsZero() {
@@ -257,7 +506,9 @@ func (m *groupManager) saveLocked() error {
 			ID:           group.ID,
 			Owner:        group.Owner,
 			HumanMembers: sortedHumanMembers(group.HumanMembers),
+			Admins:       sortedHumanMembers(group.Admins),
 			RobotAccount: group.RobotAccount,
+			Settings:     group.Settings,
 			CreatedAt:    group.CreatedAt.UnixMilli(),
 		})
 	}
@@ -301,3 +552,55 @@ func visibleMembers(members map[string]bool) []string {
 	result = append(result, groupRobotDisplayName)
 	return result
 }
+
+func groupRoleOf(group *appGroup, userID string) string {
+	switch {
+	case userID == "" || !group.HumanMembers[userID]:
+		return ""
+	case userID == group.Owner:
+		return groupRoleOwner
+	case group.Admins[userID]:
+		return groupRoleAdmin
+	default:
+		return groupRoleMember
+	}
+}
+
+func groupRoleRank(role string) int {
+	switch role {
+	case groupRoleOwner:
+		return 3
+	case groupRoleAdmin:
+		return 2
+	case groupRoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// removeGroupMember 移除成员；群主离开时依次转让给 admin、其他成员
+func removeGroupMember(group *appGroup, userID string) {
+	delete(group.HumanMembers, userID)
+	delete(group.Admins, userID)
+	if group.Owner != userID || len(group.HumanMembers) == 0 {
+		return
+	}
+	candidates := sortedHumanMembers(group.Admins)
+	if len(candidates) == 0 {
+		candidates = sortedHumanMembers(group.HumanMembers)
+	}
+	group.Owner = candidates[0]
+	delete(group.Admins, group.Owner)
+}
+
+func cloneStringMap(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGroupHistoryLimit = 2000
+	maxGroupHistoryPageSize  = 200
+)
+
+// groupHistoryEntry 群消息记录（不含 base64 附件内容）
+type groupHistoryEntry struct {
+	ID          int64          `json:"id"`
+	FromUser    string         `json:"from_user"`
+	Content     string         `json:"content"`
+	MessageType string         `json:"message_type"`
+	Meta        map[string]any `json:"meta,omitempty"`
+	CreatedAt   int64          `json:"created_at"`
+}
+
+type groupHistory struct {
+	nextID  int64
+	entries []groupHistoryEntry
+}
+
+// groupHistoryStore 按群保存消息记录：每群一个 JSONL 文件，超出上限时压缩重写。
+// dir 为空时仅保存在内存中。
+type groupHistoryStore struct {
+	mu     sync.Mutex
+	dir    string
+	limit  int
+	groups map[string]*groupHistory
+}
+
+func newGroupHistoryStore(dir string, limit int) *groupHistoryStore {
+	if limit <= 0 {
+		limit = defaultGroupHistoryLimit
+	}
+	return &groupHistoryStore{
+		dir:    strings.TrimSpace(dir),
+		limit:  limit,
+		groups: make(map[string]*groupHistory),
+	}
+}
+
+// Append 追加一条记录并返回分配的 ID
+func (s *groupHistoryStore) Append(groupID string, entry groupHistoryEntry) (int64, error) {
+	groupID = normalizeGroupID(groupID)
+	if groupID == "" {
+		return 0, fmt.Errorf("empty group")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := s.historyLocked(groupID)
+	history.nextID++
+	entry.ID = history.nextID
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = time.Now().UnixMilli()
+	}
+	history.entries = append(history.entries, entry)
+
+	// 超出上限 1/4 后才压缩，避免每条消息都重写文件
+	if len(history.entries) > s.limit+s.limit/4 {
+		history.entries = append([]groupHistoryEntry(nil), history.entries[len(history.entries)-s.limit:]...)
+		return entry.ID, s.rewriteLocked(groupID, history)
+	}
+	return entry.ID, s.appendLineLocked(groupID, entry)
+}
+
+// Page 返回 ID 小于 before 的最近 limit 条记录（按时间正序）；before<=0 表示从最新开始。
+// hasMore 表示更早的记录仍存在。
+func (s *groupHistoryStore) Page(groupID string, before int64, limit int) (entries []groupHistoryEntry, hasMore bool) {
+	groupID = normalizeGroupID(groupID)
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > maxGroupHistoryPageSize {
+		limit = maxGroupHistoryPageSize
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := s.historyLocked(groupID).entries
+	end := len(all)
+	if before > 0 {
+		end = sort.Search(len(all), func(i int) bool { return all[i].ID >= before })
+	}
+	start := max(end-limit, 0)
+	return append([]groupHistoryEntry{}, all[start:end]...), start > 0
+}
+
+func (s *groupHistoryStore) historyLocked(groupID string) *groupHistory {
+	if history := s.groups[groupID]; history != nil {
+		return history
+	}
+	history := &groupHistory{}
+	if err := s.loadLocked(groupID, history); err != nil {
+		log.Printf("[GroupHistory] load group=%s failed: %v", groupID, err)
+	}
+	s.groups[groupID] = history
+	return history
+}
+
+func (s *groupHistoryStore) loadLocked(groupID string, history *groupHistory) error {
+	if s.dir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path(groupID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry groupHistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.ID <= history.nextID {
+			continue // 跳过损坏或乱序的行（例如写入中断）
+		}
+		history.entries = append(history.entries, entry)
+		history.nextID = entry.ID
+	}
+	if len(history.entries) > s.limit {
+		history.entries = history.entries[len(history.entries)-s.limit:]
+	}
+	return scanner.Err()
+}
+
+func (s *groupHistoryStore) appendLineLocked(groupID string, entry groupHistoryEntry) error {
+	if s.dir == "" {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal group history: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("mkdir group history dir: %w", err)
+	}
+	f, err := os.OpenFile(s.path(groupID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open group history: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write group history: %w", err)
+	}
+	return nil
+}
+
+func (s *groupHistoryStore) rewriteLocked(groupID string, history *groupHistory) error {
+	if s.dir == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, entry := range history.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("marshal group history: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("mkdir group history dir: %w", err)
+	}
+	path := s.path(groupID)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write group history temp: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename group history temp: %w", err)
+	}
+	return nil
+}
+
+func (s *groupHistoryStore) path(groupID string) string {
+	return filepath.Join(s.dir, sanitizeFileName(groupID)+".jsonl")
+}
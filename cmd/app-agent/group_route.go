@@ -0,0 +1,108 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// groupMentionPattern 匹配 @xxx / ＠xxx，别名由字母、数字、下划线、点和横线组成
+var groupMentionPattern = regexp.MustCompile(`[@＠]([\p{L}\p{N}_.-]+)`)
+
+// groupRoute 描述一条群消息应转发给哪个 agent
+type groupRoute struct {
+	AgentID string
+	Mention string // 触发路由的 @别名；reply_mode=all 且无 @ 时为空
+	Content string // 去掉触发 @ 后的正文
+	Persona string
+}
+
+// parseGroupMentions 返回去重后的小写 @别名，按出现顺序
+func parseGroupMentions(content string, meta map[string]any) []string {
+	seen := make(map[string]bool)
+	var mentions []string
+	add := func(name string) {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "@＠.-"))
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		mentions = append(mentions, name)
+	}
+	for _, match := range groupMentionPattern.FindAllStringSubmatch(content, -1) {
+		add(match[1])
+	}
+	// 客户端也可以在 meta.mentions 中显式给出（语音、图片等无正文消息）
+	if list, ok := meta["mentions"].([]any); ok {
+		for _, item := range list {
+			if name, ok := item.(string); ok {
+				add(name)
+			}
+		}
+	}
+	return mentions
+}
+
+// ResolveRoute 根据 @ 与群配置决定转发目标。命名 agent 优先于 @robot；
+// mention 模式下未被 @ 的消息不转发。配置中已不在允许列表内的 agent 被忽略。
+func (m *groupManager) ResolveRoute(groupID, content string, meta map[string]any, defaultAgent string) (groupRoute, bool) {
+	groupID = normalizeGroupID(groupID)
+	m.mu.RLock()
+	group := m.groups[groupID]
+	if group == nil {
+		m.mu.RUnlock()
+		return groupRoute{}, false
+	}
+	settings := group.Settings
+	robotAccount := strings.ToLower(group.RobotAccount)
+	m.mu.RUnlock()
+
+	route := groupRoute{
+		AgentID: defaultAgent,
+		Content: strings.TrimSpace(content),
+		Persona: settings.Persona,
+	}
+	if m.allowedAgents[settings.AgentID] {
+		route.AgentID = settings.AgentID
+	}
+	robotAlias := strings.TrimPrefix(groupRobotDisplayName, "@")
+	robotMentioned := ""
+	for _, mention := range parseGroupMentions(content, meta) {
+		if agentID, ok := settings.Agents[mention]; ok && m.allowedAgents[agentID] {
+			route.AgentID = agentID
+			route.Mention = mention
+			route.Content = stripGroupMention(content, mention)
+			return route, route.AgentID != ""
+		}
+		if robotMentioned == "" && (mention == robotAlias || mention == robotAccount) {
+			robotMentioned = mention
+		}
+	}
+	if robotMentioned != "" {
+		route.Mention = robotMentioned
+		route.Content = stripGroupMention(content, robotMentioned)
+		return route, route.AgentID != ""
+	}
+	if settings.ReplyMode == groupReplyAll {
+		return route, route.AgentID != ""
+	}
+	return groupRoute{}, false
+}
+
+// stripGroupMention 去掉正文中指向 mention 的 @，保留其余换行与格式
+func stripGroupMention(content, mention string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range groupMentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		if strings.ToLower(strings.Trim(content[loc[2]:loc[3]], ".-")) != mention {
+			continue
+		}
+		sb.WriteString(content[last:loc[0]])
+		last = loc[1]
+	}
+	sb.WriteString(content[last:])
+	stripped := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(sb.String()), ",:，："))
+	if stripped == "" {
+		return strings.TrimSpace(content)
+	}
+	return stripped
+}
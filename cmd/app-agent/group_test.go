@@ -0,0 +1,170 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newTestGroupManager(t *testing.T) (*groupManager, string) {
+	t.Helper()
+	storePath := filepath.Join(t.TempDir(), "groups.json")
+	m := newGroupManager(storePath, []string{"llm-agent", "deploy-agent", "family-agent"})
+	if err := m.Create("family", "ztt", "group_family_robot"); err != nil {
+		t.Fatal(err)
+	}
+	return m, storePath
+}
+
+func TestGroupRolesInviteKickAndTransfer(t *testing.T) {
+	m, storePath := newTestGroupManager(t)
+	inviteOnly := true
+	if err := m.UpdateSettings("family", "ztt", groupSettingsPatch{InviteOnly: &inviteOnly}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Join("family", "alice"); err == nil {
+		t.Fatal("invite-only group should reject join")
+	}
+	for _, user := range []string{"alice", "bob"} {
+		if err := m.Invite("family", "ztt", user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Invite("family", "bob", "carol"); err == nil {
+		t.Fatal("member should not be able to invite")
+	}
+	if err := m.SetRole("family", "ztt", "alice", groupRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetRole("family", "alice", "bob", groupRoleAdmin); err == nil {
+		t.Fatal("only owner may change roles")
+	}
+	if err := m.Kick("family", "alice", "ztt"); err == nil {
+		t.Fatal("admin should not be able to kick the owner")
+	}
+	if err := m.Kick("family", "alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if m.HasMember("family", "bob") {
+		t.Fatal("bob should have been removed")
+	}
+
+	// 群主离开后转让给 admin，角色与配置在重载后保留
+	if err := m.Leave("family", "ztt"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := newGroupManager(storePath, nil)
+	if role := reloaded.Role("family", "alice"); role != groupRoleOwner {
+		t.Fatalf("expected alice to inherit ownership, got %q", role)
+	}
+	if settings, _ := reloaded.Settings("family"); !settings.InviteOnly {
+		t.Fatalf("settings should survive reload: %+v", settings)
+	}
+}
+
+func TestResolveGroupRouteByMention(t *testing.T) {
+	m, _ := newTestGroupManager(t)
+	persona := "家庭助手，回答简短"
+	if err := m.UpdateSettings("family", "ztt", groupSettingsPatch{
+		Persona: &persona,
+		Agents:  map[string]string{"@Deploy": "deploy-agent", "小悦": "llm-agent"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := m.ResolveRoute("family", "晚饭吃什么", nil, "llm-agent"); ok {
+		t.Fatal("mention mode should ignore messages without @")
+	}
+	cases := []struct {
+		content string
+		meta    map[string]any
+		agent   string
+		mention string
+		text    string
+	}{
+		{"@robot 明天天气？", nil, "llm-agent", "robot", "明天天气？"},
+		{"@ROBOT，帮我\n查一下", nil, "llm-agent", "robot", "帮我\n查一下"},
+		{"请 @deploy 发布 blog", nil, "deploy-agent", "deploy", "请  发布 blog"},
+		{"@小悦 讲个笑话", nil, "llm-agent", "小悦", "讲个笑话"},
+		{"", map[string]any{"mentions": []any{"robot"}}, "llm-agent", "robot", ""},
+	}
+	for _, tc := range cases {
+		route, ok := m.ResolveRoute("family", tc.content, tc.meta, "llm-agent")
+		if !ok || route.AgentID != tc.agent || route.Mention != tc.mention || route.Content != tc.text || route.Persona != persona {
+			t.Fatalf("ResolveRoute(%q) = %+v, %v", tc.content, route, ok)
+		}
+	}
+
+	mode := groupReplyAll
+	agentID := "family-agent"
+	if err := m.UpdateSettings("family", "ztt", groupSettingsPatch{ReplyMode: &mode, AgentID: &agentID}); err != nil {
+		t.Fatal(err)
+	}
+	if route, ok := m.ResolveRoute("family", "晚饭吃什么", nil, "llm-agent"); !ok || route.AgentID != "family-agent" {
+		t.Fatalf("reply_mode=all should route to the group agent: %+v", route)
+	}
+	bad := "sometimes"
+	if err := m.UpdateSettings("family", "ztt", groupSettingsPatch{ReplyMode: &bad}); err == nil {
+		t.Fatal("invalid reply_mode should be rejected")
+	}
+}
+
+func TestGroupRoutingRestrictedToAllowedAgents(t *testing.T) {
+	m, storePath := newTestGroupManager(t)
+	rogue := "exec-agent"
+	if err := m.UpdateSettings("family", "ztt", groupSettingsPatch{AgentID: &rogue}); err == nil {
+		t.Fatal("agent_id outside the allowlist should be rejected")
+	}
+	if err := m.UpdateSettings("family", "ztt", groupSettingsPatch{Agents: map[string]string{"run": rogue}}); err == nil {
+		t.Fatal("agents outside the allowlist should be rejected")
+	}
+
+	mode := groupReplyAll
+	agentID := "family-agent"
+	if err := m.UpdateSettings("family", "ztt", groupSettingsPatch{
+		ReplyMode: &mode, AgentID: &agentID, Agents: map[string]string{"deploy": "deploy-agent"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 允许列表收紧后，已保存的配置不再生效，回落到默认 agent
+	reloaded := newGroupManager(storePath, []string{"llm-agent"})
+	if route, ok := reloaded.ResolveRoute("family", "@deploy 发布", nil, "llm-agent"); !ok || route.AgentID != "llm-agent" {
+		t.Fatalf("disallowed agents should fall back to the default: %+v", route)
+	}
+}
+
+func TestGroupHistoryPersistsAndPaginates(t *testing.T) {
+	dir := t.TempDir()
+	bridge := newTestBridgeWithAttachmentDir(t)
+	bridge.history = newGroupHistoryStore(dir, 4)
+	bridge.groups.groups["g1"] = &appGroup{
+		ID:           "g1",
+		Owner:        "ztt",
+		HumanMembers: map[string]bool{"ztt": true, "alice": true},
+		RobotAccount: "robot-g1",
+	}
+	for _, content := range []string{"m1", "m2", "m3", "m4", "m5", "m6"} {
+		if err := bridge.broadcastGroupMessage("g1", "ztt", content, "text", map[string]any{"audio_base64": "AAAA"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, hasMore := bridge.history.Page("g1", 0, 2)
+	if len(page) != 2 || page[0].Content != "m5" || page[1].Content != "m6" || !hasMore {
+		t.Fatalf("unexpected latest page: %+v more=%v", page, hasMore)
+	}
+	if page[1].FromUser != "ztt" || page[1].Meta != nil {
+		t.Fatalf("history should keep sender and drop base64/group meta: %+v", page[1])
+	}
+
+	// 超出上限后压缩为最近 limit 条；重新加载后 ID 不回退
+	reloaded := newGroupHistoryStore(dir, 4)
+	older, hasMore := reloaded.Page("g1", page[0].ID, 10)
+	if len(older) != 2 || older[0].Content != "m3" || hasMore {
+		t.Fatalf("unexpected older page: %+v more=%v", older, hasMore)
+	}
+	id, err := reloaded.Append("g1", groupHistoryEntry{FromUser: "alice", Content: "m7"})
+	if err != nil || id != 7 {
+		t.Fatalf("expected next id 7, got %d err=%v", id, err)
+	}
+}
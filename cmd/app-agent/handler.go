@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -308,9 +309,12 @@ func (h *Handler) HandleGroups(w http.ResponseWriter, r *http.Request) {
 
 	case http.MethodPost:
 		var req struct {
-			Action  string `json:"action"`
-			UserID  string `json:"user_id"`
-			GroupID string `json:"group_id"`
+			Action     string              `json:"action"`
+			UserID     string              `json:"user_id"`
+			GroupID    string              `json:"group_id"`
+			TargetUser string              `json:"target_user,omitempty"` // invite / kick / set_role
+			Role       string              `json:"role,omitempty"`        // set_role: owner / admin / member
+			Settings   *groupSettingsPatch `json:"settings,omitempty"`    // update_settings
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
@@ -340,6 +344,18 @@ func (h *Handler) HandleGroups(w http.ResponseWriter, r *http.Request) {
 			err = h.bridge.groups.Join(req.GroupID, req.UserID)
 		case "leave":
 			err = h.bridge.groups.Leave(req.GroupID, req.UserID)
+		case "invite":
+			err = h.bridge.groups.Invite(req.GroupID, req.UserID, req.TargetUser)
+		case "kick":
+			err = h.bridge.groups.Kick(req.GroupID, req.UserID, req.TargetUser)
+		case "set_role":
+			err = h.bridge.groups.SetRole(req.GroupID, req.UserID, req.TargetUser, req.Role)
+		case "update_settings":
+			if req.Settings == nil {
+				err = fmt.Errorf("settings is required")
+			} else {
+				err = h.bridge.groups.UpdateSettings(req.GroupID, req.UserID, *req.Settings)
+			}
 		default:
 			http.Error(w, "unknown action", http.StatusBadRequest)
 			return
@@ -354,7 +370,7 @@ func (h *Handler) HandleGroups(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		log.Printf("[Handler] group action=%s group=%s user=%s", req.Action, req.GroupID, req.UserID)
+		log.Printf("[Handler] group action=%s group=%s user=%s target=%s", req.Action, req.GroupID, req.UserID, req.TargetUser)
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(map[string]any{
 			"success": true,
@@ -366,6 +382,49 @@ func (h *Handler) HandleGroups(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleGroupHistory 分页返回群消息记录：before 为上一页最早一条的 id，按时间正序返回
+func (h *Handler) HandleGroupHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	userID := strings.TrimSpace(query.Get("user_id"))
+	groupID := normalizeGroupID(query.Get("group_id"))
+	if userID == "" || groupID == "" {
+		http.Error(w, "user_id and group_id are required", http.StatusBadRequest)
+		return
+	}
+	if !h.validateAppSession(r, userID) {
+		http.Error(w, "Login required", http.StatusUnauthorized)
+		return
+	}
+	if h.bridge.groups.Role(groupID, userID) == "" {
+		http.Error(w, "Not a group member", http.StatusForbidden)
+		return
+	}
+	before, _ := strconv.ParseInt(query.Get("before"), 10, 64)
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	messages, hasMore := h.bridge.history.Page(groupID, before, limit)
+	resp := map[string]any{
+		"success":  true,
+		"group_id": groupID,
+		"messages": messages,
+		"has_more": hasMore,
+	}
+	if hasMore && len(messages) > 0 {
+		resp["next_before"] = messages[0].ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
 func (h *Handler) HandleCodegenProjects(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -5,11 +5,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"uap"
 )
 
 type appClientConn struct {
@@ -19,23 +24,59 @@ type appClientConn struct {
 }
 
 type pendingDelivery struct {
-	UserID      string
-	Sequence    int64
-	AckedAt     time.Time
-	LastSentAt  time.Time
-	LastAttempt time.Time
+	UserID      string    `json:"user_id"`
+	Sequence    int64     `json:"sequence"`
+	AckedAt     time.Time `json:"acked_at"`
+	LastSentAt  time.Time `json:"last_sent_at"`
+	LastAttempt time.Time `json:"last_attempt"`
 }
 
 type pendingMessage struct {
-	MessageID   string
-	Content     string
-	MessageType string
-	Channel     string
-	Timestamp   int64
-	Meta        map[string]any
-	CreatedAt   time.Time
-	ExpiresAt   time.Time
-	Deliveries  map[string]*pendingDelivery
+	MessageID   string                      `json:"message_id"`
+	Content     string                      `json:"content"`
+	MessageType string                      `json:"message_type"`
+	Channel     string                      `json:"channel"`
+	Timestamp   int64                       `json:"timestamp"`
+	Meta        map[string]any              `json:"meta,omitempty"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	ExpiresAt   time.Time                   `json:"expires_at"`
+	Deliveries  map[string]*pendingDelivery `json:"deliveries"`
+	// Origin is the UAP agent that asked for this push; receipts are relayed to it
+	// keyed by OriginMessageID.
+	Origin          string `json:"origin,omitempty"`
+	OriginMessageID string `json:"origin_message_id,omitempty"`
+}
+
+// pushOrigin identifies the UAP message that produced an app push.
+type pushOrigin struct {
+	AgentID   string
+	MessageID string
+}
+
+// readReceiptRoute is what remains of a message with an origin once every
+// recipient has acked it: enough to relay read receipts for users who have not
+// read it yet, without keeping the message itself.
+type readReceiptRoute struct {
+	PushMessageID   string    `json:"push_message_id"`
+	Origin          string    `json:"origin"`
+	OriginMessageID string    `json:"origin_message_id"`
+	Unread          []string  `json:"unread"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+type pendingStoreFile struct {
+	NextSequence int64               `json:"next_sequence"`
+	Messages     []*pendingMessage   `json:"messages"`
+	ReadReceipts []*readReceiptRoute `json:"read_receipts,omitempty"`
+}
+
+// pendingStoreSaveDelay batches store writes; enqueue/ack/read bursts only
+// mark the store dirty and a single write follows.
+const pendingStoreSaveDelay = 2 * time.Second
+
+type pendingReceipt struct {
+	to      string
+	payload uap.DeliveryReceiptPayload
 }
 
 type clientEnvelope struct {
@@ -109,11 +150,11 @@ func (b *Bridge) ServeWebSocket(w http.ResponseWriter, r *http.Request, userID s
 	}
 }
 
-func (b *Bridge) enqueueAndDeliver(payload AppPushPayload) error {
-	return b.enqueueAndDeliverMany([]string{payload.UserID}, payload)
+func (b *Bridge) enqueueAndDeliver(payload AppPushPayload, origin *pushOrigin) error {
+	return b.enqueueAndDeliverMany([]string{payload.UserID}, payload, origin)
 }
 
-func (b *Bridge) enqueueAndDeliverMany(users []string, payload AppPushPayload) error {
+func (b *Bridge) enqueueAndDeliverMany(users []string, payload AppPushPayload, origin *pushOrigin) error {
 	users = uniqueNonEmptyUsers(users)
 	if len(users) == 0 {
 		return fmt.Errorf("empty users")
@@ -148,6 +189,10 @@ func (b *Bridge) enqueueAndDeliverMany(users []string, payload AppPushPayload) e
 		ExpiresAt:   now.Add(ttl),
 		Deliveries:  make(map[string]*pendingDelivery, len(users)),
 	}
+	if origin != nil && origin.AgentID != "" && origin.MessageID != "" {
+		msg.Origin = origin.AgentID
+		msg.OriginMessageID = origin.MessageID
+	}
 
 	for _, user := range users {
 		b.nextSequence++
@@ -159,6 +204,7 @@ func (b *Bridge) enqueueAndDeliverMany(users []string, payload AppPushPayload) e
 		b.trimPendingForUserLocked(user)
 	}
 	b.pendingMessages[messageID] = msg
+	b.savePendingLocked()
 
 	clients := make([]*appClientConn, 0, len(users))
 	for _, user := range users {
@@ -338,27 +384,128 @@ func (b *Bridge) handleClientEnvelope(userID string, data []byte) {
 	if err := json.Unmarshal(data, &envelope); err != nil {
 		return
 	}
-	if envelope.Type != "ack" || strings.TrimSpace(envelope.MessageID) == "" {
+	if strings.TrimSpace(envelope.MessageID) == "" {
 		return
 	}
-	b.ackMessage(userID, envelope.MessageID)
+	switch envelope.Type {
+	case "ack":
+		b.ackMessage(userID, envelope.MessageID)
+	case "read":
+		b.markRead(userID, envelope.MessageID)
+	}
 }
 
 func (b *Bridge) ackMessage(userID, messageID string) {
 	b.deliveryMu.Lock()
-	defer b.deliveryMu.Unlock()
+	receipts, changed := b.ackLocked(userID, messageID, time.Now())
+	if changed {
+		b.removeAckedMessagesLocked()
+		b.savePendingLocked()
+	}
+	b.deliveryMu.Unlock()
+
+	b.relayReceipts(receipts)
+}
 
+// markRead records a read receipt. Reading implies delivery, so an unacked
+// delivery is acked first.
+func (b *Bridge) markRead(userID, messageID string) {
+	now := time.Now()
+	b.deliveryMu.Lock()
+	receipts, changed := b.ackLocked(userID, messageID, now)
+	if read, ok := b.readLocked(userID, messageID, now); ok {
+		receipts = append(receipts, read...)
+		changed = true
+	}
+	if changed {
+		b.removeAckedMessagesLocked()
+		b.savePendingLocked()
+	}
+	b.deliveryMu.Unlock()
+
+	b.relayReceipts(receipts)
+}
+
+// ackLocked marks a delivery as acked and drops it from the user's queue. For
+// messages with an origin the user is remembered in a read receipt route, so
+// the message itself can be dropped once every recipient has acked.
+func (b *Bridge) ackLocked(userID, messageID string, now time.Time) ([]pendingReceipt, bool) {
 	msg := b.pendingMessages[messageID]
 	if msg == nil {
-		return
+		return nil, false
 	}
 	delivery := msg.Deliveries[userID]
 	if delivery == nil || !delivery.AckedAt.IsZero() {
-		return
+		return nil, false
 	}
-	delivery.AckedAt = time.Now()
+	delivery.AckedAt = now
 	log.Printf("[WS] ack message id=%s user=%s", messageID, userID)
-	b.removeAckedMessagesLocked()
+	b.removeFromUserQueueLocked(userID, messageID)
+
+	if msg.Origin == "" || msg.OriginMessageID == "" {
+		return nil, true
+	}
+	route := b.readReceipts[messageID]
+	if route == nil {
+		route = &readReceiptRoute{
+			PushMessageID:   msg.MessageID,
+			Origin:          msg.Origin,
+			OriginMessageID: msg.OriginMessageID,
+			ExpiresAt:       msg.ExpiresAt,
+		}
+		b.readReceipts[messageID] = route
+	}
+	route.Unread = append(route.Unread, userID)
+	return []pendingReceipt{route.receipt(userID, uap.ReceiptDelivered, now)}, true
+}
+
+// readLocked consumes the user's entry in the message's read receipt route.
+func (b *Bridge) readLocked(userID, messageID string, now time.Time) ([]pendingReceipt, bool) {
+	route := b.readReceipts[messageID]
+	if route == nil {
+		return nil, false
+	}
+	idx := -1
+	for i, user := range route.Unread {
+		if user == userID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, false
+	}
+	route.Unread = append(route.Unread[:idx], route.Unread[idx+1:]...)
+	if len(route.Unread) == 0 {
+		delete(b.readReceipts, messageID)
+	}
+	log.Printf("[WS] read message id=%s user=%s", messageID, userID)
+	return []pendingReceipt{route.receipt(userID, uap.ReceiptRead, now)}, true
+}
+
+func (r *readReceiptRoute) receipt(userID, status string, at time.Time) pendingReceipt {
+	return pendingReceipt{
+		to: r.Origin,
+		payload: uap.DeliveryReceiptPayload{
+			MessageID:     r.OriginMessageID,
+			PushMessageID: r.PushMessageID,
+			Channel:       "app",
+			To:            userID,
+			Status:        status,
+			Timestamp:     at.UnixMilli(),
+		},
+	}
+}
+
+// relayReceipts sends receipts outside deliveryMu; failures are only logged
+// since receipts are best effort.
+func (b *Bridge) relayReceipts(receipts []pendingReceipt) {
+	for _, receipt := range receipts {
+		if err := b.sendReceipt(receipt.to, receipt.payload); err != nil {
+			log.Printf("[WS] relay %s receipt to %s failed message_id=%s: %v",
+				receipt.payload.Status, receipt.to, receipt.payload.MessageID, err)
+		}
+	}
 }
 
 func (b *Bridge) trimPendingForUserLocked(userID string) {
@@ -384,16 +531,18 @@ func (b *Bridge) trimPendingForUserLocked(userID string) {
 	b.pendingByUser[userID] = queue
 }
 
+// removeAckedMessagesLocked drops messages every recipient has acked. Pending
+// read receipts live on in readReceipts.
 func (b *Bridge) removeAckedMessagesLocked() {
 	for messageID, msg := range b.pendingMessages {
-		allAcked := true
+		done := true
 		for _, delivery := range msg.Deliveries {
 			if delivery.AckedAt.IsZero() {
-				allAcked = false
+				done = false
 				break
 			}
 		}
-		if !allAcked {
+		if !done {
 			continue
 		}
 		b.removeMessageLocked(messageID, msg)
@@ -407,12 +556,24 @@ func (b *Bridge) cleanupExpiredMessages() {
 }
 
 func (b *Bridge) cleanupExpiredLocked(now time.Time) {
+	removed := 0
 	for messageID, msg := range b.pendingMessages {
 		if now.Before(msg.ExpiresAt) {
 			continue
 		}
 		log.Printf("[WS] expired pending message id=%s created_at=%s", messageID, msg.CreatedAt.Format(time.RFC3339))
 		b.removeMessageLocked(messageID, msg)
+		removed++
+	}
+	for messageID, route := range b.readReceipts {
+		if now.Before(route.ExpiresAt) {
+			continue
+		}
+		delete(b.readReceipts, messageID)
+		removed++
+	}
+	if removed > 0 {
+		b.savePendingLocked()
 	}
 }
 
@@ -424,23 +585,226 @@ func (b *Bridge) removeMessageLocked(messageID string, msg *pendingMessage) {
 		}
 	}
 	for userID := range msg.Deliveries {
-		queue := b.pendingByUser[userID]
-		if len(queue) == 0 {
+		b.removeFromUserQueueLocked(userID, messageID)
+	}
+	delete(b.pendingMessages, messageID)
+}
+
+func (b *Bridge) removeFromUserQueueLocked(userID, messageID string) {
+	queue := b.pendingByUser[userID]
+	if len(queue) == 0 {
+		return
+	}
+	filtered := make([]string, 0, len(queue))
+	for _, id := range queue {
+		if id != messageID {
+			filtered = append(filtered, id)
+		}
+	}
+	if len(filtered) == 0 {
+		delete(b.pendingByUser, userID)
+		return
+	}
+	b.pendingByUser[userID] = filtered
+}
+
+// ========================= 未送达查询 =========================
+
+type undeliveredMessage struct {
+	MessageID       string `json:"message_id"`
+	Origin          string `json:"origin,omitempty"`
+	OriginMessageID string `json:"origin_message_id,omitempty"`
+	MessageType     string `json:"message_type"`
+	Preview         string `json:"preview"`
+	CreatedAt       int64  `json:"created_at"`
+	AgeSec          int64  `json:"age_sec"`
+	LastSentAt      int64  `json:"last_sent_at,omitempty"`
+}
+
+type undeliveredUser struct {
+	UserID   string               `json:"user_id"`
+	Online   bool                 `json:"online"`
+	Messages []undeliveredMessage `json:"messages"`
+}
+
+// listUndelivered returns unacked messages per user, oldest first. minAge skips
+// messages that may still be in flight.
+func (b *Bridge) listUndelivered(userID string, minAge time.Duration) []undeliveredUser {
+	now := time.Now()
+	b.deliveryMu.Lock()
+	defer b.deliveryMu.Unlock()
+
+	b.cleanupExpiredLocked(now)
+
+	users := make([]string, 0, len(b.pendingByUser))
+	if userID != "" {
+		users = append(users, userID)
+	} else {
+		for user := range b.pendingByUser {
+			users = append(users, user)
+		}
+		sort.Strings(users)
+	}
+
+	result := make([]undeliveredUser, 0, len(users))
+	for _, user := range users {
+		entry := undeliveredUser{UserID: user, Online: len(b.clients[user]) > 0, Messages: []undeliveredMessage{}}
+		for _, messageID := range b.pendingByUser[user] {
+			msg := b.pendingMessages[messageID]
+			if msg == nil {
+				continue
+			}
+			delivery := msg.Deliveries[user]
+			if delivery == nil || !delivery.AckedAt.IsZero() || now.Sub(msg.CreatedAt) < minAge {
+				continue
+			}
+			item := undeliveredMessage{
+				MessageID:       msg.MessageID,
+				Origin:          msg.Origin,
+				OriginMessageID: msg.OriginMessageID,
+				MessageType:     msg.MessageType,
+				Preview:         shortText(msg.Content),
+				CreatedAt:       msg.CreatedAt.UnixMilli(),
+				AgeSec:          int64(now.Sub(msg.CreatedAt).Seconds()),
+			}
+			if !delivery.LastSentAt.IsZero() {
+				item.LastSentAt = delivery.LastSentAt.UnixMilli()
+			}
+			entry.Messages = append(entry.Messages, item)
+		}
+		if len(entry.Messages) > 0 || userID != "" {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// ========================= 持久化 =========================
+
+// loadPending restores queues saved by flushPendingStore. A missing store file
+// is not an error.
+func (b *Bridge) loadPending() error {
+	path := strings.TrimSpace(b.cfg.PendingStoreFile)
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var store pendingStoreFile
+	if err := json.Unmarshal(data, &store); err != nil {
+		return fmt.Errorf("parse pending store: %w", err)
+	}
+
+	b.deliveryMu.Lock()
+	defer b.deliveryMu.Unlock()
+
+	type queued struct {
+		messageID string
+		sequence  int64
+	}
+	queues := make(map[string][]queued)
+	b.nextSequence = store.NextSequence
+	for _, msg := range store.Messages {
+		if msg == nil || msg.MessageID == "" || len(msg.Deliveries) == 0 {
 			continue
 		}
-		filtered := queue[:0]
-		for _, id := range queue {
-			if id != messageID {
-				filtered = append(filtered, id)
+		b.pendingMessages[msg.MessageID] = msg
+		for userID, delivery := range msg.Deliveries {
+			if delivery.Sequence > b.nextSequence {
+				b.nextSequence = delivery.Sequence
+			}
+			if delivery.AckedAt.IsZero() {
+				queues[userID] = append(queues[userID], queued{msg.MessageID, delivery.Sequence})
 			}
 		}
-		if len(filtered) == 0 {
-			delete(b.pendingByUser, userID)
-		} else {
-			b.pendingByUser[userID] = append([]string(nil), filtered...)
+	}
+	for userID, items := range queues {
+		sort.Slice(items, func(i, j int) bool { return items[i].sequence < items[j].sequence })
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.messageID)
+		}
+		b.pendingByUser[userID] = ids
+	}
+	for _, route := range store.ReadReceipts {
+		if route == nil || route.PushMessageID == "" || len(route.Unread) == 0 {
+			continue
 		}
+		b.readReceipts[route.PushMessageID] = route
 	}
-	delete(b.pendingMessages, messageID)
+	b.cleanupExpiredLocked(time.Now())
+	log.Printf("[WS] restored %d pending messages from %s", len(b.pendingMessages), path)
+	return nil
+}
+
+// savePendingLocked marks the store dirty and schedules a write.
+func (b *Bridge) savePendingLocked() {
+	if strings.TrimSpace(b.cfg.PendingStoreFile) == "" || b.pendingSaveTimer != nil {
+		return
+	}
+	b.pendingSaveTimer = time.AfterFunc(pendingStoreSaveDelay, b.flushPendingStore)
+}
+
+// flushPendingStore writes the store if a save is scheduled. The snapshot is
+// taken under deliveryMu, the file is written outside it.
+func (b *Bridge) flushPendingStore() {
+	b.pendingSaveMu.Lock()
+	defer b.pendingSaveMu.Unlock()
+
+	b.deliveryMu.Lock()
+	if b.pendingSaveTimer == nil {
+		b.deliveryMu.Unlock()
+		return
+	}
+	b.pendingSaveTimer.Stop()
+	b.pendingSaveTimer = nil
+	store := pendingStoreFile{
+		NextSequence: b.nextSequence,
+		Messages:     make([]*pendingMessage, 0, len(b.pendingMessages)),
+		ReadReceipts: make([]*readReceiptRoute, 0, len(b.readReceipts)),
+	}
+	for _, msg := range b.pendingMessages {
+		store.Messages = append(store.Messages, msg)
+	}
+	sort.Slice(store.Messages, func(i, j int) bool {
+		return store.Messages[i].CreatedAt.Before(store.Messages[j].CreatedAt)
+	})
+	for _, route := range b.readReceipts {
+		store.ReadReceipts = append(store.ReadReceipts, route)
+	}
+	sort.Slice(store.ReadReceipts, func(i, j int) bool {
+		return store.ReadReceipts[i].PushMessageID < store.ReadReceipts[j].PushMessageID
+	})
+	data, err := json.MarshalIndent(&store, "", "  ")
+	b.deliveryMu.Unlock()
+	if err != nil {
+		log.Printf("[WS] marshal pending store failed: %v", err)
+		return
+	}
+
+	if err := writePendingStore(b.cfg.PendingStoreFile, data); err != nil {
+		log.Printf("[WS] save pending store failed: %v", err)
+	}
+}
+
+func writePendingStore(path string, data []byte) error {
+	path = strings.TrimSpace(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("mkdir pending store dir: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write pending store temp: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename pending store temp: %w", err)
+	}
+	return nil
 }
 
 func (b *Bridge) closeAllClients() {
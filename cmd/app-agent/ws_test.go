@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -10,6 +11,7 @@ import (
 
 	"downloadticket"
 	"obsstore"
+	"uap"
 )
 
 type fakeOBSStorage struct {
@@ -422,3 +424,154 @@ func TestPushUploadedAPKToGroupSameFileNameKeepsOnlyLatestPendingMessage(t *test
 		t.Fatalf("expected latest group apk file data to be group-v2, got %q", string(data))
 	}
 }
+
+func newTestBridgeWithPendingStore(t *testing.T, storeFile string) (*Bridge, *[]uap.DeliveryReceiptPayload) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.AttachmentStoreDir = filepath.Join(t.TempDir(), "app-attachments")
+	cfg.GroupStoreFile = filepath.Join(t.TempDir(), "groups.json")
+	cfg.PendingStoreFile = storeFile
+	bridge := NewBridge(cfg)
+	t.Cleanup(bridge.flushPendingStore)
+	var receipts []uap.DeliveryReceiptPayload
+	bridge.sendReceipt = func(_ string, payload uap.DeliveryReceiptPayload) error {
+		receipts = append(receipts, payload)
+		return nil
+	}
+	return bridge, &receipts
+}
+
+func TestPendingQueuePersistsAcrossRestart(t *testing.T) {
+	storeFile := filepath.Join(t.TempDir(), "app-pending.json")
+	bridge, _ := newTestBridgeWithPendingStore(t, storeFile)
+
+	for i, content := range []string{"first", "second", "third"} {
+		origin := &pushOrigin{AgentID: "llm-agent", MessageID: fmt.Sprintf("uap-%d", i)}
+		if err := bridge.sendNotificationMessage("ztt", content, "text", nil, origin); err != nil {
+			t.Fatalf("send %s: %v", content, err)
+		}
+	}
+	queue := bridge.pendingForUser("ztt")
+	bridge.handleClientEnvelope("ztt", []byte(`{"type":"ack","message_id":"`+queue[1].MessageID+`"}`))
+	bridge.flushPendingStore()
+
+	restarted, receipts := newTestBridgeWithPendingStore(t, storeFile)
+	restored := restarted.pendingForUser("ztt")
+	if len(restored) != 2 || restored[0].Content != "first" || restored[1].Content != "third" {
+		t.Fatalf("unexpected restored queue: %+v", restored)
+	}
+	if restored[1].Sequence != queue[2].Sequence {
+		t.Fatalf("expected sequence %d to survive restart, got %d", queue[2].Sequence, restored[1].Sequence)
+	}
+	if err := restarted.sendNotificationMessage("ztt", "fourth", "text", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if next := restarted.pendingForUser("ztt"); next[2].Sequence <= queue[2].Sequence {
+		t.Fatalf("sequence should keep increasing after restart, got %d", next[2].Sequence)
+	}
+	// 已送达的消息不再保留，只保留已读回执路由，重启后仍能回传
+	if _, ok := restarted.pendingMessages[queue[1].MessageID]; ok {
+		t.Fatal("acked message should be dropped from the pending store")
+	}
+	restarted.handleClientEnvelope("ztt", []byte(`{"type":"read","message_id":"`+queue[1].MessageID+`"}`))
+	if len(*receipts) != 1 || (*receipts)[0].Status != uap.ReceiptRead || (*receipts)[0].MessageID != "uap-1" {
+		t.Fatalf("expected read receipt after restart, got %+v", *receipts)
+	}
+}
+
+func TestPendingStoreWritesAreBatched(t *testing.T) {
+	storeFile := filepath.Join(t.TempDir(), "app-pending.json")
+	bridge, _ := newTestBridgeWithPendingStore(t, storeFile)
+
+	for i := 0; i < 5; i++ {
+		if err := bridge.sendNotificationMessage("ztt", fmt.Sprintf("msg-%d", i), "text", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bridge.ackMessage("ztt", bridge.pendingForUser("ztt")[0].MessageID)
+	if _, err := os.Stat(storeFile); !os.IsNotExist(err) {
+		t.Fatalf("store should not be written on every change, stat err=%v", err)
+	}
+
+	bridge.flushPendingStore()
+	restarted, _ := newTestBridgeWithPendingStore(t, storeFile)
+	if got := restarted.PendingMessageCount(); got != 4 {
+		t.Fatalf("expected 4 pending messages after flush, got %d", got)
+	}
+}
+
+func TestDeliveryAndReadReceiptsRelayedToOrigin(t *testing.T) {
+	bridge, receipts := newTestBridgeWithPendingStore(t, "")
+
+	origin := &pushOrigin{AgentID: "llm-agent", MessageID: "uap-msg-1"}
+	if err := bridge.sendNotificationMessage("ztt", "hello", "text", nil, origin); err != nil {
+		t.Fatal(err)
+	}
+	messageID := bridge.pendingForUser("ztt")[0].MessageID
+
+	bridge.handleClientEnvelope("ztt", []byte(`{"type":"ack","message_id":"`+messageID+`"}`))
+	bridge.handleClientEnvelope("ztt", []byte(`{"type":"ack","message_id":"`+messageID+`"}`))
+	if len(*receipts) != 1 || (*receipts)[0].Status != uap.ReceiptDelivered || (*receipts)[0].MessageID != "uap-msg-1" {
+		t.Fatalf("expected one delivered receipt, got %+v", *receipts)
+	}
+	if got := bridge.PendingMessageCount(); got != 0 {
+		t.Fatalf("acked message should not count as pending, got %d", got)
+	}
+	if _, ok := bridge.pendingMessages[messageID]; ok {
+		t.Fatalf("acked message should be dropped even with an origin")
+	}
+
+	bridge.handleClientEnvelope("ztt", []byte(`{"type":"read","message_id":"`+messageID+`"}`))
+	if len(*receipts) != 2 || (*receipts)[1].Status != uap.ReceiptRead || (*receipts)[1].To != "ztt" || (*receipts)[1].PushMessageID != messageID {
+		t.Fatalf("expected read receipt, got %+v", *receipts)
+	}
+	if len(bridge.readReceipts) != 0 {
+		t.Fatalf("read receipt route should be consumed: %+v", bridge.readReceipts)
+	}
+
+	// 直接收到 read 时补发 delivered；无来源的消息不回传
+	if err := bridge.sendNotificationMessage("ztt", "again", "text", nil, &pushOrigin{AgentID: "llm-agent", MessageID: "uap-msg-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.sendNotificationMessage("ztt", "local", "text", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range bridge.pendingForUser("ztt") {
+		bridge.handleClientEnvelope("ztt", []byte(`{"type":"read","message_id":"`+msg.MessageID+`"}`))
+	}
+	if len(*receipts) != 4 || (*receipts)[2].Status != uap.ReceiptDelivered || (*receipts)[3].Status != uap.ReceiptRead {
+		t.Fatalf("expected delivered+read for uap-msg-2 only, got %+v", *receipts)
+	}
+	if len(bridge.pendingMessages) != 0 || len(bridge.readReceipts) != 0 {
+		t.Fatalf("expected no pending state, got %d messages %d routes", len(bridge.pendingMessages), len(bridge.readReceipts))
+	}
+}
+
+func TestListUndeliveredGroupsByUser(t *testing.T) {
+	bridge, _ := newTestBridgeWithPendingStore(t, "")
+
+	origin := &pushOrigin{AgentID: "cron-agent", MessageID: "reminder-1"}
+	for _, user := range []string{"ztt", "alice", "ztt"} {
+		if err := bridge.sendNotificationMessage(user, "记得开会", "text", nil, origin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bridge.ackMessage("alice", bridge.pendingForUser("alice")[0].MessageID)
+	bridge.registerClient(&appClientConn{userID: "alice"})
+
+	users := bridge.listUndelivered("", 0)
+	if len(users) != 1 || users[0].UserID != "ztt" || users[0].Online || len(users[0].Messages) != 2 {
+		t.Fatalf("unexpected undelivered listing: %+v", users)
+	}
+	if item := users[0].Messages[0]; item.Origin != "cron-agent" || item.OriginMessageID != "reminder-1" || item.Preview != "记得开会" {
+		t.Fatalf("unexpected undelivered item: %+v", item)
+	}
+
+	alice := bridge.listUndelivered("alice", 0)
+	if len(alice) != 1 || !alice[0].Online || len(alice[0].Messages) != 0 {
+		t.Fatalf("alice should be listed as online with nothing pending: %+v", alice)
+	}
+	if fresh := bridge.listUndelivered("ztt", time.Hour); len(fresh[0].Messages) != 0 {
+		t.Fatalf("min_age should skip fresh messages: %+v", fresh)
+	}
+}
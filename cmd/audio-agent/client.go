@@ -38,10 +38,17 @@ type SynthesizeParams struct {
 	Format string
 }
 
+// Transcribe normalizes the audio, splits long recordings into stt_chunk_sec chunks and runs
+// each chunk through the speech_to_text provider chain. A provider that fails is skipped for
+// the remaining chunks.
 func (c *AudioClient) Transcribe(ctx context.Context, params TranscribeParams) (map[string]any, error) {
-	provider, model, err := c.cfg.ResolveSTT()
-	if err != nil {
-		return nil, err
+	var backends []sttBackend
+	for _, ref := range c.cfg.SpeechToText.Chain() {
+		provider, err := c.newSTTProvider(ref)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, sttBackend{ref: ref, provider: provider})
 	}
 	start := time.Now()
 
@@ -49,67 +56,231 @@ func (c *AudioClient) Transcribe(ctx context.Context, params TranscribeParams) (
 	if err != nil {
 		return nil, fmt.Errorf("decode audio_base64: %w", err)
 	}
+	format, err := detectAudioFormat(audioBytes, params.Format)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := c.prepareSTTChunks(ctx, audioBytes, format)
+	if err != nil {
+		return nil, err
+	}
 	fileName := strings.TrimSpace(params.FileName)
 	if fileName == "" {
-		fileName = "audio." + defaultString(strings.TrimSpace(params.Format), "mp3")
+		fileName = "audio." + defaultString(format, "mp3")
+	}
+
+	var (
+		text      string
+		segments  []Segment
+		providers []string
+		attempts  []string
+		lastErr   error
+		raw       map[string]any
+		next      int
+	)
+	for i, chunk := range chunks {
+		in := STTInput{Audio: chunk.data, Format: chunk.format, FileName: fileName, Prompt: params.Prompt, Language: params.Language}
+		if chunk.normalized {
+			in.FileName = fmt.Sprintf("audio-%d.wav", i+1)
+		}
+
+		var tr *Transcript
+		for ; next < len(backends); next++ {
+			b := backends[next]
+			if !chunk.normalized && b.provider.NeedsWAV() {
+				lastErr = fmt.Errorf("%s/%s requires normalized audio", b.ref.Provider, b.ref.Model)
+				attempts = append(attempts, lastErr.Error())
+				continue
+			}
+			callCtx, cancel := c.withTimeout(ctx)
+			tr, lastErr = b.provider.Transcribe(callCtx, in)
+			cancel()
+			if lastErr == nil {
+				break
+			}
+			log.Printf("[AudioClient] STT provider=%s model=%s chunk=%d/%d failed: %v", b.ref.Provider, b.ref.Model, i+1, len(chunks), lastErr)
+			attempts = append(attempts, fmt.Sprintf("%s/%s: %v", b.ref.Provider, b.ref.Model, lastErr))
+		}
+		if tr == nil {
+			if len(attempts) == 1 {
+				return nil, lastErr
+			}
+			return nil, fmt.Errorf("all speech_to_text providers failed: %s", strings.Join(attempts, "; "))
+		}
+
+		if name := backends[next].ref.Provider; len(providers) == 0 || providers[len(providers)-1] != name {
+			providers = append(providers, name)
+		}
+		if len(tr.Segments) == 0 && chunk.normalized {
+			tr.Segments = []Segment{{End: chunk.end - chunk.start, Text: strings.TrimSpace(tr.Text)}}
+		}
+		for _, seg := range tr.Segments {
+			seg.Start += chunk.start
+			seg.End += chunk.start
+			segments = append(segments, seg)
+		}
+		text = joinTranscript(text, tr.Text)
+		raw = tr.Raw
+	}
+
+	result := map[string]any{}
+	if len(chunks) == 1 {
+		for k, v := range raw {
+			result[k] = v
+		}
+	}
+	result["text"] = text
+	result["provider"] = strings.Join(providers, ",")
+	result["audio_format"] = format
+	if len(segments) > 0 {
+		result["segments"] = segments
+	}
+	if last := chunks[len(chunks)-1]; last.normalized {
+		result["duration_sec"] = last.end
+		result["chunks"] = len(chunks)
+	}
+	if len(attempts) > 0 {
+		result["fallback_errors"] = attempts
+	}
+	log.Printf("[AudioClient] STT done provider=%s format=%s chunks=%d duration=%v text_len=%d", result["provider"], format, len(chunks), time.Since(start), len(text))
+	return result, nil
+}
+
+type sttBackend struct {
+	ref      AudioModelRef
+	provider SpeechToTextProvider
+}
+
+// prepareSTTChunks falls back to the original bytes when normalization is unavailable
+// (e.g. no ffmpeg) and the format is one hosted APIs accept directly.
+func (c *AudioClient) prepareSTTChunks(ctx context.Context, audioBytes []byte, format string) ([]audioChunk, error) {
+	normCtx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	pcm, err := c.normalizeToPCM(normCtx, audioBytes, format)
+	if err == nil {
+		return splitPCM(pcm, c.cfg.STTChunkSec), nil
 	}
+	if !httpSTTFormats[format] {
+		return nil, fmt.Errorf("normalize %s audio: %w", defaultString(format, "unknown"), err)
+	}
+	log.Printf("[AudioClient] STT normalize skipped format=%s err=%v, sending original audio", format, err)
+	return []audioChunk{{data: audioBytes, format: format}}, nil
+}
+
+type httpSTT struct {
+	client   *AudioClient
+	name     string
+	provider *AudioProviderConfig
+	model    *SpeechToTextModelConfig
+}
+
+func (p *httpSTT) NeedsWAV() bool { return false }
+
+func (p *httpSTT) Transcribe(ctx context.Context, in STTInput) (*Transcript, error) {
+	start := time.Now()
 
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
-	_ = writer.WriteField("model", model.Model)
-	if strings.TrimSpace(params.Prompt) != "" {
-		_ = writer.WriteField("prompt", strings.TrimSpace(params.Prompt))
+	_ = writer.WriteField("model", p.model.Model)
+	if strings.TrimSpace(in.Prompt) != "" {
+		_ = writer.WriteField("prompt", strings.TrimSpace(in.Prompt))
 	}
-	if strings.TrimSpace(params.Language) != "" {
-		_ = writer.WriteField("language", strings.TrimSpace(params.Language))
+	if strings.TrimSpace(in.Language) != "" {
+		_ = writer.WriteField("language", strings.TrimSpace(in.Language))
 	}
-	part, err := writer.CreateFormFile("file", fileName)
+	part, err := writer.CreateFormFile("file", in.FileName)
 	if err != nil {
 		return nil, fmt.Errorf("create multipart file: %w", err)
 	}
-	if _, err := part.Write(audioBytes); err != nil {
+	if _, err := part.Write(in.Audio); err != nil {
 		return nil, fmt.Errorf("write audio file: %w", err)
 	}
 	if err := writer.Close(); err != nil {
 		return nil, fmt.Errorf("close multipart writer: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(c.withTimeout(ctx), http.MethodPost, joinURL(provider.BaseURL, provider.SpeechToTextPath), &body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, joinURL(p.provider.BaseURL, p.provider.SpeechToTextPath), &body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+provider.APIKey)
+	req.Header.Set("Authorization", "Bearer "+p.provider.APIKey)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	log.Printf("[AudioClient] STT request provider=%s model=%s format=%s file=%s bytes=%d", c.cfg.SpeechToText.Provider, model.Model, strings.TrimSpace(params.Format), fileName, len(audioBytes))
+	log.Printf("[AudioClient] STT request provider=%s model=%s format=%s file=%s bytes=%d", p.name, p.model.Model, in.Format, in.FileName, len(in.Audio))
 
 	var result map[string]any
 	if err := doJSON(req, &result); err != nil {
-		log.Printf("[AudioClient] STT request failed provider=%s model=%s duration=%v err=%v", c.cfg.SpeechToText.Provider, model.Model, time.Since(start), err)
+		log.Printf("[AudioClient] STT request failed provider=%s model=%s duration=%v err=%v", p.name, p.model.Model, time.Since(start), err)
 		return nil, err
 	}
-	log.Printf("[AudioClient] STT request done provider=%s model=%s duration=%v text_len=%d", c.cfg.SpeechToText.Provider, model.Model, time.Since(start), len(firstStringField(result, "text", "transcript", "content")))
-	return result, nil
+	text := firstStringField(result, "text", "transcript", "content")
+	log.Printf("[AudioClient] STT request done provider=%s model=%s duration=%v text_len=%d", p.name, p.model.Model, time.Since(start), len(text))
+
+	tr := &Transcript{Text: text, Raw: result}
+	if items, ok := result["segments"].([]any); ok {
+		for _, item := range items {
+			seg, _ := item.(map[string]any)
+			startSec, _ := seg["start"].(float64)
+			endSec, _ := seg["end"].(float64)
+			segText, _ := seg["text"].(string)
+			tr.Segments = append(tr.Segments, Segment{Start: startSec, End: endSec, Text: strings.TrimSpace(segText)})
+		}
+	}
+	return tr, nil
 }
 
+// Synthesize tries the text_to_speech provider chain in order.
 func (c *AudioClient) Synthesize(ctx context.Context, params SynthesizeParams) (map[string]any, error) {
-	provider, model, err := c.cfg.ResolveTTS()
-	if err != nil {
-		return nil, err
+	var (
+		attempts []string
+		lastErr  error
+	)
+	for _, ref := range c.cfg.TextToSpeech.Chain() {
+		provider, err := c.newTTSProvider(ref)
+		if err != nil {
+			return nil, err
+		}
+		callCtx, cancel := c.withTimeout(ctx)
+		result, err := provider.Synthesize(callCtx, params)
+		cancel()
+		if err != nil {
+			log.Printf("[AudioClient] TTS provider=%s model=%s failed: %v", ref.Provider, ref.Model, err)
+			attempts = append(attempts, fmt.Sprintf("%s/%s: %v", ref.Provider, ref.Model, err))
+			lastErr = err
+			continue
+		}
+		result["provider"] = ref.Provider
+		if len(attempts) > 0 {
+			result["fallback_errors"] = attempts
+		}
+		return result, nil
+	}
+	if len(attempts) == 1 {
+		return nil, lastErr
 	}
+	return nil, fmt.Errorf("all text_to_speech providers failed: %s", strings.Join(attempts, "; "))
+}
+
+type httpTTS struct {
+	client   *AudioClient
+	name     string
+	provider *AudioProviderConfig
+	model    *TextToSpeechModelConfig
+}
 
-	voice, err := c.resolveConfiguredTTSVoice(model, params.Voice)
+func (p *httpTTS) Synthesize(ctx context.Context, params SynthesizeParams) (map[string]any, error) {
+	voice, err := p.client.resolveConfiguredTTSVoice(p.model, params.Voice)
 	if err != nil {
 		return nil, err
 	}
 	format := strings.TrimSpace(params.Format)
 	if format == "" {
-		format = defaultString(model.ResponseFormat, "mp3")
+		format = defaultString(p.model.ResponseFormat, "mp3")
 	}
 
-	if strings.EqualFold(c.cfg.TextToSpeech.Provider, "minimax") {
-		return c.synthesizeMiniMaxHTTP(ctx, provider, model, params, voice, format)
+	if strings.EqualFold(p.name, "minimax") {
+		return p.client.synthesizeMiniMaxHTTP(ctx, p.provider, p.model, params, voice, format)
 	}
-	return c.synthesizeStandardHTTP(ctx, provider, model, params, voice, format)
+	return p.client.synthesizeStandardHTTP(ctx, p.name, p.provider, p.model, params, voice, format)
 }
 
 func (c *AudioClient) resolveConfiguredTTSVoice(model *TextToSpeechModelConfig, requestedVoice string) (string, error) {
@@ -126,7 +297,7 @@ func (c *AudioClient) resolveConfiguredTTSVoice(model *TextToSpeechModelConfig,
 	return configuredVoice, nil
 }
 
-func (c *AudioClient) synthesizeStandardHTTP(ctx context.Context, provider *AudioProviderConfig, model *TextToSpeechModelConfig, params SynthesizeParams, voice string, format string) (map[string]any, error) {
+func (c *AudioClient) synthesizeStandardHTTP(ctx context.Context, name string, provider *AudioProviderConfig, model *TextToSpeechModelConfig, params SynthesizeParams, voice string, format string) (map[string]any, error) {
 	start := time.Now()
 	body := map[string]any{
 		"model":           model.Model,
@@ -136,24 +307,26 @@ func (c *AudioClient) synthesizeStandardHTTP(ctx context.Context, provider *Audi
 	}
 	bodyJSON, _ := json.Marshal(body)
 
-	req, err := http.NewRequestWithContext(c.withTimeout(ctx), http.MethodPost, joinURL(provider.BaseURL, provider.TextToSpeechPath), bytes.NewReader(bodyJSON))
+	reqCtx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, joinURL(provider.BaseURL, provider.TextToSpeechPath), bytes.NewReader(bodyJSON))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+provider.APIKey)
 	req.Header.Set("Content-Type", "application/json")
-	log.Printf("[AudioClient] TTS request provider=%s model=%s voice=%s format=%s text_len=%d", c.cfg.TextToSpeech.Provider, model.Model, voice, format, len(params.Text))
+	log.Printf("[AudioClient] TTS request provider=%s model=%s voice=%s format=%s text_len=%d", name, model.Model, voice, format, len(params.Text))
 
 	resp, err := audioHTTPClient.Do(req)
 	if err != nil {
-		log.Printf("[AudioClient] TTS request failed provider=%s model=%s duration=%v err=%v", c.cfg.TextToSpeech.Provider, model.Model, time.Since(start), err)
+		log.Printf("[AudioClient] TTS request failed provider=%s model=%s duration=%v err=%v", name, model.Model, time.Since(start), err)
 		return nil, fmt.Errorf("send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		data, _ := io.ReadAll(io.LimitReader(resp.Body, 32768))
-		log.Printf("[AudioClient] TTS api error provider=%s model=%s duration=%v status=%d body=%q", c.cfg.TextToSpeech.Provider, model.Model, time.Since(start), resp.StatusCode, strings.TrimSpace(string(data)))
+		log.Printf("[AudioClient] TTS api error provider=%s model=%s duration=%v status=%d body=%q", name, model.Model, time.Since(start), resp.StatusCode, strings.TrimSpace(string(data)))
 		return nil, fmt.Errorf("api error status=%d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
 	}
 
@@ -161,7 +334,7 @@ func (c *AudioClient) synthesizeStandardHTTP(ctx context.Context, provider *Audi
 	if err != nil {
 		return nil, fmt.Errorf("read response body: %w", err)
 	}
-	log.Printf("[AudioClient] TTS request done provider=%s model=%s duration=%v audio_bytes=%d", c.cfg.TextToSpeech.Provider, model.Model, time.Since(start), len(audioBytes))
+	log.Printf("[AudioClient] TTS request done provider=%s model=%s duration=%v audio_bytes=%d", name, model.Model, time.Since(start), len(audioBytes))
 
 	return map[string]any{
 		"audio_base64": base64.StdEncoding.EncodeToString(audioBytes),
@@ -206,7 +379,9 @@ func (c *AudioClient) synthesizeMiniMaxHTTP(ctx context.Context, provider *Audio
 	}
 
 	bodyJSON, _ := json.Marshal(body)
-	req, err := http.NewRequestWithContext(c.withTimeout(ctx), http.MethodPost, joinURL(provider.BaseURL, provider.TextToSpeechPath), bytes.NewReader(bodyJSON))
+	reqCtx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, joinURL(provider.BaseURL, provider.TextToSpeechPath), bytes.NewReader(bodyJSON))
 	if err != nil {
 		return nil, err
 	}
@@ -255,13 +430,12 @@ func (c *AudioClient) synthesizeMiniMaxHTTP(ctx context.Context, provider *Audio
 	}, nil
 }
 
-func (c *AudioClient) withTimeout(ctx context.Context) context.Context {
+func (c *AudioClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
 	timeout := time.Duration(c.cfg.RequestTimeoutSec) * time.Second
 	if _, ok := ctx.Deadline(); ok {
-		return ctx
+		return ctx, func() {}
 	}
-	newCtx, _ := context.WithTimeout(ctx, timeout)
-	return newCtx
+	return context.WithTimeout(ctx, timeout)
 }
 
 func doJSON(req *http.Request, out any) error {
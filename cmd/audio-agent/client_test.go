@@ -1,6 +1,15 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
 
 func TestResolveConfiguredTTSVoiceIgnoresExternalVoice(t *testing.T) {
 	client := NewAudioClient(&Config{
@@ -29,3 +38,136 @@ func TestResolveConfiguredTTSVoiceRequiresDefaultVoice(t *testing.T) {
 		t.Fatalf("expected error, got voice=%q", voice)
 	}
 }
+
+// newLocalFallbackConfig points the hosted providers at server and adds whisper.cpp / piper fallbacks.
+func newLocalFallbackConfig(serverURL string) *Config {
+	cfg := DefaultConfig()
+	openai := cfg.Providers["openai"]
+	openai.BaseURL = serverURL
+	cfg.Providers["openai"] = openai
+	minimax := cfg.Providers["minimax"]
+	minimax.BaseURL = serverURL
+	cfg.Providers["minimax"] = minimax
+	cfg.Providers["whisper"] = AudioProviderConfig{
+		Type:       ProviderTypeWhisperCpp,
+		BinaryPath: "/opt/whisper-cli",
+		STTModels:  map[string]SpeechToTextModelConfig{"base": {Model: "/models/ggml-base.bin"}},
+	}
+	cfg.Providers["piper"] = AudioProviderConfig{
+		Type:       ProviderTypePiper,
+		BinaryPath: "/opt/piper",
+		TTSModels:  map[string]TextToSpeechModelConfig{"zh": {Model: "/models/zh_CN-huayan-medium.onnx", ResponseFormat: "wav"}},
+	}
+	cfg.SpeechToText.Fallbacks = []AudioModelRef{{Provider: "whisper", Model: "base"}}
+	cfg.TextToSpeech.Fallbacks = []AudioModelRef{{Provider: "piper", Model: "zh"}}
+	return cfg
+}
+
+func TestTranscribeFallsBackToLocalWhisperInChunks(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.Error(w, `{"error":"quota exceeded"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	cfg := newLocalFallbackConfig(server.URL)
+	cfg.STTChunkSec = 10
+	client := NewAudioClient(cfg)
+
+	words := []string{"one", "two", "three"}
+	var calls int
+	stubRunCommand(t, func(name string, args []string, stdin []byte) error {
+		if name != "/opt/whisper-cli" || argValue(args, "-l") != "en" {
+			return fmt.Errorf("unexpected command %s %v", name, args)
+		}
+		wav, err := os.ReadFile(argValue(args, "-f"))
+		if err != nil {
+			return err
+		}
+		pcm, _, _, _ := parseWAV(wav)
+		ms := len(pcm) * 1000 / sttBytesPerSecond
+		out := fmt.Sprintf(`{"transcription":[{"offsets":{"from":0,"to":%d},"text":" %s"}]}`, ms, words[calls])
+		calls++
+		return os.WriteFile(argValue(args, "-of")+".json", []byte(out), 0600)
+	})
+
+	result, err := client.Transcribe(context.Background(), TranscribeParams{
+		AudioBase64: base64.StdEncoding.EncodeToString(encodeWAV(silentPCM(25))),
+		Language:    "en",
+	})
+	if err != nil {
+		t.Fatalf("transcribe: %v", err)
+	}
+	if result["text"] != "one two three" || result["provider"] != "whisper" || result["chunks"] != 3 {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	// 主 provider 失败后，后续分片不再重试
+	if hits != 1 || len(result["fallback_errors"].([]string)) != 1 {
+		t.Fatalf("expected a single hosted attempt, hits=%d result=%v", hits, result)
+	}
+	segments := result["segments"].([]Segment)
+	if len(segments) != 3 || segments[1].Start != 10 || segments[2].Start != 20 || segments[2].End != 25 {
+		t.Fatalf("unexpected segments: %+v", segments)
+	}
+}
+
+func TestTranscribeSendsOriginalAudioWithoutFFmpeg(t *testing.T) {
+	var fileName string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("file")
+		if err == nil {
+			fileName = header.Filename
+		}
+		w.Write([]byte(`{"text":"你好"}`))
+	}))
+	defer server.Close()
+
+	client := NewAudioClient(newLocalFallbackConfig(server.URL))
+	stubRunCommand(t, func(name string, args []string, stdin []byte) error {
+		return fmt.Errorf("%s: executable file not found", name)
+	})
+
+	result, err := client.Transcribe(context.Background(), TranscribeParams{
+		AudioBase64: base64.StdEncoding.EncodeToString([]byte("ID3\x04mp3-bytes")),
+	})
+	if err != nil {
+		t.Fatalf("transcribe: %v", err)
+	}
+	if result["text"] != "你好" || result["provider"] != "openai" || fileName != "audio.mp3" || result["duration_sec"] != nil {
+		t.Fatalf("unexpected result: %v file=%s", result, fileName)
+	}
+
+	// amr 无法转码时直接报错
+	if _, err := client.Transcribe(context.Background(), TranscribeParams{
+		AudioBase64: base64.StdEncoding.EncodeToString([]byte("#!AMR\n")),
+	}); err == nil || !strings.Contains(err.Error(), "normalize amr") {
+		t.Fatalf("expected normalize error, got %v", err)
+	}
+}
+
+func TestSynthesizeFallsBackToPiper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"base_resp":{"status_code":1008,"status_msg":"insufficient balance"}}`))
+	}))
+	defer server.Close()
+
+	client := NewAudioClient(newLocalFallbackConfig(server.URL))
+	stubRunCommand(t, func(name string, args []string, stdin []byte) error {
+		if name != "/opt/piper" || string(stdin) != "你好" {
+			return fmt.Errorf("unexpected command %s %v", name, args)
+		}
+		return os.WriteFile(argValue(args, "--output_file"), encodeWAV(silentPCM(0.2)), 0600)
+	})
+
+	result, err := client.Synthesize(context.Background(), SynthesizeParams{Text: "你好"})
+	if err != nil {
+		t.Fatalf("synthesize: %v", err)
+	}
+	if result["provider"] != "piper" || result["transport"] != "local" || result["audio_format"] != "wav" {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	if errs := result["fallback_errors"].([]string); len(errs) != 1 || !strings.Contains(errs[0], "insufficient balance") {
+		t.Fatalf("unexpected fallback errors: %v", errs)
+	}
+}
@@ -25,18 +25,38 @@ type TextToSpeechModelConfig struct {
 	PronunciationTone    []string `json:"pronunciation_tone,omitempty"`
 }
 
+// Provider types. Local backends use the model config's Model field as the model file path.
+const (
+	ProviderTypeHTTP       = "http"
+	ProviderTypeWhisperCpp = "whisper_cpp"
+	ProviderTypePiper      = "piper"
+)
+
 type AudioProviderConfig struct {
+	Type             string                             `json:"type,omitempty"`
 	BaseURL          string                             `json:"base_url"`
 	APIKey           string                             `json:"api_key"`
 	SpeechToTextPath string                             `json:"speech_to_text_path"`
 	TextToSpeechPath string                             `json:"text_to_speech_path"`
+	BinaryPath       string                             `json:"binary_path,omitempty"`
+	ExtraArgs        []string                           `json:"extra_args,omitempty"`
 	STTModels        map[string]SpeechToTextModelConfig `json:"speech_to_text_models,omitempty"`
 	TTSModels        map[string]TextToSpeechModelConfig `json:"text_to_speech_models,omitempty"`
 }
 
 type AudioModelRef struct {
-	Provider string `json:"provider"`
-	Model    string `json:"model"`
+	Provider  string          `json:"provider"`
+	Model     string          `json:"model"`
+	Fallbacks []AudioModelRef `json:"fallbacks,omitempty"`
+}
+
+// Chain returns the primary ref followed by its fallbacks in order.
+func (r AudioModelRef) Chain() []AudioModelRef {
+	chain := []AudioModelRef{{Provider: r.Provider, Model: r.Model}}
+	for _, fb := range r.Fallbacks {
+		chain = append(chain, AudioModelRef{Provider: fb.Provider, Model: fb.Model})
+	}
+	return chain
 }
 
 type Config struct {
@@ -47,6 +67,10 @@ type Config struct {
 	MaxConcurrent     int `json:"max_concurrent"`
 	RequestTimeoutSec int `json:"request_timeout_sec"`
 
+	FFmpegPath      string `json:"ffmpeg_path"`
+	SilkDecoderPath string `json:"silk_decoder_path,omitempty"`
+	STTChunkSec     int    `json:"stt_chunk_sec"`
+
 	Providers    map[string]AudioProviderConfig `json:"providers"`
 	SpeechToText AudioModelRef                  `json:"speech_to_text"`
 	TextToSpeech AudioModelRef                  `json:"text_to_speech"`
@@ -60,6 +84,8 @@ func DefaultConfig() *Config {
 		AgentName:         "audio-agent",
 		MaxConcurrent:     3,
 		RequestTimeoutSec: 180,
+		FFmpegPath:        "ffmpeg",
+		STTChunkSec:       300,
 		Providers: map[string]AudioProviderConfig{
 			"openai": {
 				BaseURL:          "https://api.openai.com/v1",
@@ -121,38 +147,74 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.RequestTimeoutSec <= 0 {
 		cfg.RequestTimeoutSec = 180
 	}
+	if cfg.FFmpegPath == "" {
+		cfg.FFmpegPath = "ffmpeg"
+	}
+	if cfg.STTChunkSec <= 0 {
+		cfg.STTChunkSec = 300
+	}
 	if len(cfg.Providers) == 0 {
 		return nil, fmt.Errorf("providers is required")
 	}
-	if _, _, err := cfg.ResolveSTT(); err != nil {
-		return nil, err
+	for _, ref := range cfg.SpeechToText.Chain() {
+		if _, _, err := cfg.resolveSTTRef(ref); err != nil {
+			return nil, err
+		}
 	}
-	if _, _, err := cfg.ResolveTTS(); err != nil {
-		return nil, err
+	for _, ref := range cfg.TextToSpeech.Chain() {
+		if _, _, err := cfg.resolveTTSRef(ref); err != nil {
+			return nil, err
+		}
 	}
 	return cfg, nil
 }
 
 func (c *Config) ResolveSTT() (*AudioProviderConfig, *SpeechToTextModelConfig, error) {
-	provider, ok := c.Providers[c.SpeechToText.Provider]
+	return c.resolveSTTRef(c.SpeechToText)
+}
+
+func (c *Config) ResolveTTS() (*AudioProviderConfig, *TextToSpeechModelConfig, error) {
+	return c.resolveTTSRef(c.TextToSpeech)
+}
+
+func (c *Config) resolveSTTRef(ref AudioModelRef) (*AudioProviderConfig, *SpeechToTextModelConfig, error) {
+	provider, ok := c.Providers[ref.Provider]
 	if !ok {
-		return nil, nil, fmt.Errorf("speech_to_text provider not found: %s", c.SpeechToText.Provider)
+		return nil, nil, fmt.Errorf("speech_to_text provider not found: %s", ref.Provider)
 	}
-	model, ok := provider.STTModels[c.SpeechToText.Model]
+	model, ok := provider.STTModels[ref.Model]
 	if !ok {
-		return nil, nil, fmt.Errorf("speech_to_text model not found: %s/%s", c.SpeechToText.Provider, c.SpeechToText.Model)
+		return nil, nil, fmt.Errorf("speech_to_text model not found: %s/%s", ref.Provider, ref.Model)
+	}
+	switch provider.Type {
+	case "", ProviderTypeHTTP:
+	case ProviderTypeWhisperCpp:
+		if provider.BinaryPath == "" || model.Model == "" {
+			return nil, nil, fmt.Errorf("speech_to_text provider %s requires binary_path and model path", ref.Provider)
+		}
+	default:
+		return nil, nil, fmt.Errorf("speech_to_text provider %s has unsupported type: %s", ref.Provider, provider.Type)
 	}
 	return &provider, &model, nil
 }
 
-func (c *Config) ResolveTTS() (*AudioProviderConfig, *TextToSpeechModelConfig, error) {
-	provider, ok := c.Providers[c.TextToSpeech.Provider]
+func (c *Config) resolveTTSRef(ref AudioModelRef) (*AudioProviderConfig, *TextToSpeechModelConfig, error) {
+	provider, ok := c.Providers[ref.Provider]
 	if !ok {
-		return nil, nil, fmt.Errorf("text_to_speech provider not found: %s", c.TextToSpeech.Provider)
+		return nil, nil, fmt.Errorf("text_to_speech provider not found: %s", ref.Provider)
 	}
-	model, ok := provider.TTSModels[c.TextToSpeech.Model]
+	model, ok := provider.TTSModels[ref.Model]
 	if !ok {
-		return nil, nil, fmt.Errorf("text_to_speech model not found: %s/%s", c.TextToSpeech.Provider, c.TextToSpeech.Model)
+		return nil, nil, fmt.Errorf("text_to_speech model not found: %s/%s", ref.Provider, ref.Model)
+	}
+	switch provider.Type {
+	case "", ProviderTypeHTTP:
+	case ProviderTypePiper:
+		if provider.BinaryPath == "" || model.Model == "" {
+			return nil, nil, fmt.Errorf("text_to_speech provider %s requires binary_path and model path", ref.Provider)
+		}
+	default:
+		return nil, nil, fmt.Errorf("text_to_speech provider %s has unsupported type: %s", ref.Provider, provider.Type)
 	}
 	return &provider, &model, nil
 }
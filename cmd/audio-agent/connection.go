@@ -43,12 +43,12 @@ func buildAudioToolDefs() []uap.ToolDef {
 	return []uap.ToolDef{
 		{
 			Name:        "AudioToText",
-			Description: "Convert audio content to text with a configured speech-to-text model",
+			Description: "Convert audio content to text with the configured speech-to-text provider chain. Long recordings are transcribed in chunks and the result includes timestamped segments",
 			Parameters: agentbase.MustMarshalJSON(map[string]any{
 				"type": "object",
 				"properties": map[string]any{
 					"audio_base64": map[string]any{"type": "string", "description": "Base64 encoded audio bytes"},
-					"audio_format": map[string]any{"type": "string", "description": "Optional file extension such as mp3, wav, m4a"},
+					"audio_format": map[string]any{"type": "string", "description": "Optional format hint: mp3, wav, m4a, amr, silk, ogg, flac, webm or pcm (16 kHz mono s16le); detected from the audio header when possible"},
 					"file_name":    map[string]any{"type": "string", "description": "Optional file name for multipart upload"},
 					"prompt":       map[string]any{"type": "string", "description": "Optional transcription prompt"},
 					"language":     map[string]any{"type": "string", "description": "Optional language hint"},
@@ -64,7 +64,7 @@ func buildAudioToolDefs() []uap.ToolDef {
 				"properties": map[string]any{
 					"text":         map[string]any{"type": "string", "description": "Input text"},
 					"voice":        map[string]any{"type": "string", "description": "Optional voice override"},
-					"audio_format": map[string]any{"type": "string", "description": "Optional output format such as mp3 or wav"},
+					"audio_format": map[string]any{"type": "string", "description": "Optional output format: mp3, wav, m4a, ogg, flac, webm or pcm"},
 				},
 				"required": []string{"text"},
 			}),
@@ -162,7 +162,9 @@ func (c *Connection) toolTextToAudio(args map[string]any) (map[string]any, error
 	if err != nil {
 		return nil, err
 	}
-	result["provider"] = c.cfg.TextToSpeech.Provider
+	if _, exists := result["provider"]; !exists {
+		result["provider"] = c.cfg.TextToSpeech.Provider
+	}
 	return result, nil
 }
 
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// STT input is normalized to 16 kHz mono 16-bit PCM so it can be chunked and fed to local backends.
+const (
+	sttSampleRate     = 16000
+	sttBytesPerSecond = sttSampleRate * 2
+)
+
+// runCommand runs a local binary; replaced in tests.
+var runCommand = func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", filepath.Base(name), err, truncateForLog(stderr.String(), 300))
+	}
+	return stdout.Bytes(), nil
+}
+
+// httpSTTFormats are the containers hosted STT APIs accept without conversion.
+var httpSTTFormats = map[string]bool{
+	"mp3": true, "m4a": true, "wav": true, "webm": true, "ogg": true, "flac": true,
+}
+
+// ffmpegFormat is the demuxer/muxer passed to ffmpeg with -f; an empty muxer means ffmpeg cannot write it.
+type ffmpegFormat struct {
+	demuxer string
+	muxer   string
+}
+
+// audioFormats is the fixed allowlist of formats accepted from callers. Formats never become
+// part of a file name; they only select the ffmpeg container. pcm is 16 kHz mono s16le.
+var audioFormats = map[string]ffmpegFormat{
+	"mp3":  {"mp3", "mp3"},
+	"wav":  {"wav", "wav"},
+	"m4a":  {"mov", "ipod"},
+	"amr":  {"amr", "amr"},
+	"silk": {},
+	"ogg":  {"ogg", "ogg"},
+	"flac": {"flac", "flac"},
+	"webm": {"matroska", "webm"},
+	"pcm":  {"s16le", "s16le"},
+}
+
+// audioFormatAliases maps other common extensions onto audioFormats.
+var audioFormatAliases = map[string]string{
+	"mpeg": "mp3", "mpga": "mp3", "mp4": "m4a",
+}
+
+// parseAudioFormat normalizes a caller-supplied format and rejects anything outside audioFormats.
+func parseAudioFormat(s string) (string, error) {
+	format := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "."))
+	if alias, ok := audioFormatAliases[format]; ok {
+		format = alias
+	}
+	if _, ok := audioFormats[format]; !ok {
+		return "", fmt.Errorf("unsupported audio format %q", s)
+	}
+	return format, nil
+}
+
+// ffmpegInputArgs forces the demuxer for a known format; unknown input is left to ffmpeg's probe.
+func ffmpegInputArgs(format string) []string {
+	if format == "pcm" {
+		return []string{"-f", "s16le", "-ar", fmt.Sprint(sttSampleRate), "-ac", "1"}
+	}
+	if f := audioFormats[format]; f.demuxer != "" {
+		return []string{"-f", f.demuxer}
+	}
+	return nil
+}
+
+// detectAudioFormat prefers magic bytes over the caller's hint, since WeChat voice files are often mislabeled.
+// An empty hint yields ""; a hint outside audioFormats is rejected.
+func detectAudioFormat(data []byte, hint string) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return "amr", nil
+	case bytes.HasPrefix(data, []byte("#!SILK_V3")), bytes.HasPrefix(data, []byte("\x02#!SILK_V3")):
+		return "silk", nil
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return "ogg", nil
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return "wav", nil
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "flac", nil
+	case bytes.HasPrefix(data, []byte("\x1a\x45\xdf\xa3")):
+		return "webm", nil
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return "m4a", nil
+	case bytes.HasPrefix(data, []byte("ID3")), len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return "mp3", nil
+	}
+	if strings.TrimSpace(hint) == "" {
+		return "", nil
+	}
+	return parseAudioFormat(hint)
+}
+
+// normalizeToPCM converts audio to 16 kHz mono s16le PCM. Canonical wav is parsed directly,
+// silk goes through the silk decoder, everything else through ffmpeg.
+func (c *AudioClient) normalizeToPCM(ctx context.Context, data []byte, format string) ([]byte, error) {
+	if format == "wav" {
+		if pcm, rate, channels, err := parseWAV(data); err == nil && rate == sttSampleRate && channels == 1 {
+			return pcm, nil
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "audio-agent-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input")
+	if err := os.WriteFile(input, data, 0600); err != nil {
+		return nil, err
+	}
+
+	if format == "silk" {
+		if c.cfg.SilkDecoderPath == "" {
+			return nil, fmt.Errorf("silk audio requires silk_decoder_path")
+		}
+		output := filepath.Join(dir, "output.pcm")
+		if _, err := runCommand(ctx, nil, c.cfg.SilkDecoderPath, input, output, "-Fs_API", fmt.Sprint(sttSampleRate)); err != nil {
+			return nil, err
+		}
+		return os.ReadFile(output)
+	}
+
+	output := filepath.Join(dir, "output")
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-y"}, ffmpegInputArgs(format)...)
+	args = append(args, "-i", input, "-ar", fmt.Sprint(sttSampleRate), "-ac", "1", "-acodec", "pcm_s16le", "-f", "wav", output)
+	if _, err := runCommand(ctx, nil, c.cfg.FFmpegPath, args...); err != nil {
+		return nil, err
+	}
+	wav, err := os.ReadFile(output)
+	if err != nil {
+		return nil, err
+	}
+	pcm, _, _, err := parseWAV(wav)
+	return pcm, err
+}
+
+// convertAudio transcodes between containers with ffmpeg (used for local TTS output).
+// Both formats must come from audioFormats.
+func (c *AudioClient) convertAudio(ctx context.Context, data []byte, from, to string) ([]byte, error) {
+	in, ok := audioFormats[from]
+	if !ok || in.demuxer == "" {
+		return nil, fmt.Errorf("unsupported input audio format %q", from)
+	}
+	out, ok := audioFormats[to]
+	if !ok || out.muxer == "" {
+		return nil, fmt.Errorf("unsupported output audio format %q", to)
+	}
+
+	dir, err := os.MkdirTemp("", "audio-agent-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input")
+	output := filepath.Join(dir, "output")
+	if err := os.WriteFile(input, data, 0600); err != nil {
+		return nil, err
+	}
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-y"}, ffmpegInputArgs(from)...)
+	args = append(args, "-i", input, "-f", out.muxer, output)
+	if _, err := runCommand(ctx, nil, c.cfg.FFmpegPath, args...); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(output)
+}
+
+// parseWAV returns the 16-bit PCM data chunk. Size fields are clamped because
+// streamed wav files often carry placeholder lengths.
+func parseWAV(data []byte) (pcm []byte, sampleRate, channels int, err error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, fmt.Errorf("not a wav file")
+	}
+	bits := 0
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := pos + 8
+		if size < 0 || body+size > len(data) {
+			size = len(data) - body
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, 0, fmt.Errorf("invalid wav fmt chunk")
+			}
+			if format := binary.LittleEndian.Uint16(data[body:]); format != 1 {
+				return nil, 0, 0, fmt.Errorf("unsupported wav encoding: %d", format)
+			}
+			channels = int(binary.LittleEndian.Uint16(data[body+2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(data[body+4:]))
+			bits = int(binary.LittleEndian.Uint16(data[body+14:]))
+		case "data":
+			if bits != 16 {
+				return nil, 0, 0, fmt.Errorf("unsupported wav sample size: %d", bits)
+			}
+			return data[body : body+size], sampleRate, channels, nil
+		}
+		pos = body + size + size%2
+	}
+	return nil, 0, 0, fmt.Errorf("wav data chunk not found")
+}
+
+// encodeWAV wraps 16 kHz mono s16le PCM in a wav header.
+func encodeWAV(pcm []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint32(sttSampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sttBytesPerSecond))
+	binary.Write(&buf, binary.LittleEndian, uint16(2))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+type audioChunk struct {
+	data       []byte
+	format     string
+	start      float64
+	end        float64
+	normalized bool // 16 kHz wav with known timing
+}
+
+// splitPCM cuts normalized PCM into wav chunks of at most chunkSec seconds.
+func splitPCM(pcm []byte, chunkSec int) []audioChunk {
+	pcm = pcm[:len(pcm)&^1]
+	size := chunkSec * sttBytesPerSecond
+	var chunks []audioChunk
+	for off := 0; ; off += size {
+		end := min(off+size, len(pcm))
+		chunks = append(chunks, audioChunk{
+			data:       encodeWAV(pcm[off:end]),
+			format:     "wav",
+			start:      float64(off) / sttBytesPerSecond,
+			end:        float64(end) / sttBytesPerSecond,
+			normalized: true,
+		})
+		if end == len(pcm) {
+			break
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// stubRunCommand replaces local binaries with fn for the duration of the test.
+func stubRunCommand(t *testing.T, fn func(name string, args []string, stdin []byte) error) {
+	t.Helper()
+	orig := runCommand
+	runCommand = func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		return nil, fn(name, args, stdin)
+	}
+	t.Cleanup(func() { runCommand = orig })
+}
+
+func argValue(args []string, flag string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func silentPCM(seconds float64) []byte {
+	return make([]byte, int(seconds*sttBytesPerSecond))
+}
+
+func TestDetectAudioFormatPrefersMagicBytes(t *testing.T) {
+	cases := []struct {
+		data []byte
+		hint string
+		want string
+	}{
+		{[]byte("#!AMR\n\x00"), "mp3", "amr"},
+		{[]byte("\x02#!SILK_V3\x00"), "", "silk"},
+		{[]byte("OggS\x00\x02"), "opus", "ogg"},
+		{encodeWAV(silentPCM(0.1)), "", "wav"},
+		{[]byte("ID3\x04"), "", "mp3"},
+		{[]byte("\x00\x00\x00\x20ftypM4A "), "", "m4a"},
+		{[]byte("unknown"), ".MP3", "mp3"},
+	}
+	for _, tc := range cases {
+		if got, err := detectAudioFormat(tc.data, tc.hint); err != nil || got != tc.want {
+			t.Fatalf("detectAudioFormat(%q, %q) = %q, %v, want %q", tc.data, tc.hint, got, err, tc.want)
+		}
+	}
+}
+
+func TestAudioFormatRejectsUnlistedHints(t *testing.T) {
+	for _, hint := range []string{"../x", "x/../../etc", "wav;rm", "exe"} {
+		if got, err := detectAudioFormat([]byte("unknown"), hint); err == nil {
+			t.Fatalf("detectAudioFormat hint %q accepted as %q", hint, got)
+		}
+	}
+	if got, err := parseAudioFormat("MP4"); err != nil || got != "m4a" {
+		t.Fatalf("parseAudioFormat(MP4) = %q, %v", got, err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.FFmpegPath = "/opt/ffmpeg"
+	client := NewAudioClient(cfg)
+	stubRunCommand(t, func(name string, args []string, stdin []byte) error {
+		t.Fatalf("ffmpeg must not run for rejected formats: %v", args)
+		return nil
+	})
+	if _, err := client.convertAudio(context.Background(), []byte("x"), "wav", "../x"); err == nil {
+		t.Fatal("convertAudio accepted an unlisted output format")
+	}
+}
+
+func TestSplitPCMChunksWithTimestamps(t *testing.T) {
+	chunks := splitPCM(silentPCM(25), 10)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	last := chunks[2]
+	if last.start != 20 || last.end != 25 || !last.normalized {
+		t.Fatalf("unexpected last chunk: start=%v end=%v", last.start, last.end)
+	}
+	pcm, rate, channels, err := parseWAV(last.data)
+	if err != nil || rate != sttSampleRate || channels != 1 || len(pcm) != 5*sttBytesPerSecond {
+		t.Fatalf("chunk is not a valid 16k mono wav: rate=%d channels=%d len=%d err=%v", rate, channels, len(pcm), err)
+	}
+}
+
+func TestNormalizeToPCMUsesConverters(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.FFmpegPath = "/opt/ffmpeg"
+	cfg.SilkDecoderPath = "/opt/silk_v3_decoder"
+	client := NewAudioClient(cfg)
+
+	var calls []string
+	stubRunCommand(t, func(name string, args []string, stdin []byte) error {
+		calls = append(calls, name)
+		switch name {
+		case cfg.FFmpegPath:
+			if argValue(args, "-ar") != "16000" || argValue(args, "-ac") != "1" || argValue(args, "-f") != "amr" {
+				return fmt.Errorf("unexpected ffmpeg args: %v", args)
+			}
+			if filepath.Base(argValue(args, "-i")) != "input" {
+				return fmt.Errorf("input must use a constant file name: %v", args)
+			}
+			return os.WriteFile(args[len(args)-1], encodeWAV(silentPCM(1)), 0600)
+		case cfg.SilkDecoderPath:
+			return os.WriteFile(args[1], silentPCM(2), 0600)
+		}
+		return fmt.Errorf("unexpected binary %s", name)
+	})
+
+	pcm, err := client.normalizeToPCM(context.Background(), []byte("#!AMR\n"), "amr")
+	if err != nil || len(pcm) != sttBytesPerSecond {
+		t.Fatalf("amr normalize: len=%d err=%v", len(pcm), err)
+	}
+	pcm, err = client.normalizeToPCM(context.Background(), []byte("\x02#!SILK_V3"), "silk")
+	if err != nil || len(pcm) != 2*sttBytesPerSecond {
+		t.Fatalf("silk normalize: len=%d err=%v", len(pcm), err)
+	}
+
+	// 已是 16k 单声道 wav 时不调用外部程序
+	wav := encodeWAV(silentPCM(0.5))
+	pcm, err = client.normalizeToPCM(context.Background(), wav, "wav")
+	if err != nil || !bytes.Equal(pcm, silentPCM(0.5)) {
+		t.Fatalf("wav passthrough failed: %v", err)
+	}
+	if len(calls) != 2 || calls[0] != cfg.FFmpegPath || calls[1] != cfg.SilkDecoderPath {
+		t.Fatalf("unexpected converter calls: %v", calls)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type STTInput struct {
+	Audio    []byte
+	Format   string
+	FileName string
+	Prompt   string
+	Language string
+}
+
+// Segment is a timestamped piece of a transcript, in seconds from the start of the recording.
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+type Transcript struct {
+	Text     string
+	Segments []Segment      // relative to the submitted audio
+	Raw      map[string]any // provider response, merged into single-chunk results
+}
+
+type SpeechToTextProvider interface {
+	Transcribe(ctx context.Context, in STTInput) (*Transcript, error)
+	// NeedsWAV reports whether the backend only accepts normalized 16 kHz mono wav.
+	NeedsWAV() bool
+}
+
+type TextToSpeechProvider interface {
+	Synthesize(ctx context.Context, params SynthesizeParams) (map[string]any, error)
+}
+
+func (c *AudioClient) newSTTProvider(ref AudioModelRef) (SpeechToTextProvider, error) {
+	provider, model, err := c.cfg.resolveSTTRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if provider.Type == ProviderTypeWhisperCpp {
+		return &whisperCppSTT{name: ref.Provider, provider: provider, model: model}, nil
+	}
+	return &httpSTT{client: c, name: ref.Provider, provider: provider, model: model}, nil
+}
+
+func (c *AudioClient) newTTSProvider(ref AudioModelRef) (TextToSpeechProvider, error) {
+	provider, model, err := c.cfg.resolveTTSRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if provider.Type == ProviderTypePiper {
+		return &piperTTS{client: c, name: ref.Provider, provider: provider, model: model}, nil
+	}
+	return &httpTTS{client: c, name: ref.Provider, provider: provider, model: model}, nil
+}
+
+// ========================= whisper.cpp =========================
+
+type whisperCppSTT struct {
+	name     string
+	provider *AudioProviderConfig
+	model    *SpeechToTextModelConfig
+}
+
+func (w *whisperCppSTT) NeedsWAV() bool { return true }
+
+func (w *whisperCppSTT) Transcribe(ctx context.Context, in STTInput) (*Transcript, error) {
+	if in.Format != "wav" {
+		return nil, fmt.Errorf("whisper.cpp requires wav input, got %s", in.Format)
+	}
+	start := time.Now()
+	dir, err := os.MkdirTemp("", "audio-agent-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.wav")
+	if err := os.WriteFile(input, in.Audio, 0600); err != nil {
+		return nil, err
+	}
+	prefix := filepath.Join(dir, "output")
+	args := []string{"-m", w.model.Model, "-f", input, "-oj", "-of", prefix, "-np", "-l", defaultString(strings.TrimSpace(in.Language), "auto")}
+	if prompt := strings.TrimSpace(in.Prompt); prompt != "" {
+		args = append(args, "--prompt", prompt)
+	}
+	args = append(args, w.provider.ExtraArgs...)
+
+	log.Printf("[AudioClient] STT local provider=%s model=%s bytes=%d", w.name, filepath.Base(w.model.Model), len(in.Audio))
+	if _, err := runCommand(ctx, nil, w.provider.BinaryPath, args...); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(prefix + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("read whisper output: %w", err)
+	}
+
+	var output struct {
+		Transcription []struct {
+			Offsets struct {
+				From int `json:"from"`
+				To   int `json:"to"`
+			} `json:"offsets"`
+			Text string `json:"text"`
+		} `json:"transcription"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("parse whisper output: %w", err)
+	}
+	tr := &Transcript{}
+	for _, item := range output.Transcription {
+		tr.Text = joinTranscript(tr.Text, item.Text)
+		tr.Segments = append(tr.Segments, Segment{
+			Start: float64(item.Offsets.From) / 1000,
+			End:   float64(item.Offsets.To) / 1000,
+			Text:  strings.TrimSpace(item.Text),
+		})
+	}
+	log.Printf("[AudioClient] STT local done provider=%s duration=%v text_len=%d", w.name, time.Since(start), len(tr.Text))
+	return tr, nil
+}
+
+// ========================= piper =========================
+
+type piperTTS struct {
+	client   *AudioClient
+	name     string
+	provider *AudioProviderConfig
+	model    *TextToSpeechModelConfig
+}
+
+func (p *piperTTS) Synthesize(ctx context.Context, params SynthesizeParams) (map[string]any, error) {
+	start := time.Now()
+	format, err := parseAudioFormat(defaultString(strings.TrimSpace(params.Format), defaultString(p.model.ResponseFormat, "wav")))
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "audio-agent-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "output.wav")
+	args := []string{"--model", p.model.Model, "--output_file", output}
+	speaker := strings.TrimSpace(p.model.DefaultVoice)
+	if speaker != "" {
+		args = append(args, "--speaker", speaker)
+	}
+	if p.model.Speed > 0 && p.model.Speed != 1 {
+		args = append(args, "--length_scale", fmt.Sprintf("%.2f", 1/p.model.Speed))
+	}
+	args = append(args, p.provider.ExtraArgs...)
+
+	log.Printf("[AudioClient] TTS local provider=%s model=%s speaker=%s text_len=%d", p.name, filepath.Base(p.model.Model), speaker, len(params.Text))
+	if _, err := runCommand(ctx, []byte(params.Text), p.provider.BinaryPath, args...); err != nil {
+		return nil, err
+	}
+	audioBytes, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("read piper output: %w", err)
+	}
+
+	if format != "wav" {
+		converted, err := p.client.convertAudio(ctx, audioBytes, "wav", format)
+		if err != nil {
+			log.Printf("[AudioClient] TTS local convert to %s failed, returning wav: %v", format, err)
+			format = "wav"
+		} else {
+			audioBytes = converted
+		}
+	}
+	log.Printf("[AudioClient] TTS local done provider=%s duration=%v audio_bytes=%d", p.name, time.Since(start), len(audioBytes))
+
+	return map[string]any{
+		"audio_base64": base64.StdEncoding.EncodeToString(audioBytes),
+		"audio_format": format,
+		"voice":        speaker,
+		"model":        filepath.Base(p.model.Model),
+		"transport":    "local",
+	}, nil
+}
+
+// joinTranscript concatenates transcript pieces, adding a space only between Latin words
+// so CJK text is not split apart.
+func joinTranscript(prev, next string) string {
+	next = strings.TrimSpace(next)
+	prev = strings.TrimRight(prev, " ")
+	if prev == "" || next == "" {
+		return prev + next
+	}
+	last, first := prev[len(prev)-1], next[0]
+	if isASCIIAlnum(first) && (isASCIIAlnum(last) || strings.IndexByte(".,?!;:", last) >= 0) {
+		return prev + " " + next
+	}
+	return prev + next
+}
+
+func isASCIIAlnum(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -99,6 +99,34 @@ func main() {
 	}
 	log.Debug(log.ModuleCommon, "Logging system initialized")
 
+	// 存储迁移: blog-agent <sys_conf> migrate-storage <redis|file|bolt> [storage_path]
+	if len(args) >= 4 && args[2] == "migrate-storage" {
+		toPath := ""
+		if len(args) >= 5 {
+			toPath = args[4]
+		}
+		report, err := persistence.MigrateStorage(args[3], toPath)
+		if err != nil {
+			fmt.Printf("migrate-storage failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(report.String())
+		for _, key := range report.Mismatches {
+			fmt.Printf("  mismatch:      %s\n", key)
+		}
+		for _, key := range report.FileMismatch {
+			fmt.Printf("  file mismatch: %s\n", key)
+		}
+		for _, key := range report.MissingFiles {
+			fmt.Printf("  missing file:  %s\n", key)
+		}
+		if !report.OK() {
+			os.Exit(2)
+		}
+		fmt.Printf("done, set storage_backend=%s in sys_conf to switch\n", args[3])
+		return
+	}
+
 	persistence.Init()
 	blog.Init()
 	control.Init()
@@ -113,7 +141,7 @@ func main() {
 	codegen.Init()
 
 	// 注入 MCP 桥接函数到 codegen，避免 codegen 直接依赖 mcp 的重量级传递依赖链
-	codegen.MCPCallInnerTools = mcp.CallInnerTools
+	codegen.MCPCallInnerTools = mcp.CallInnerToolsAs
 	codegen.MCPGetToolInfos = func() []codegen.MCPToolInfo {
 		tools := mcp.GetInnerMCPTools(nil)
 		infos := make([]codegen.MCPToolInfo, 0, len(tools))
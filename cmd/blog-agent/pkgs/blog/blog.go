@@ -3,6 +3,7 @@ package blog
 import (
 	"auth"
 	"config"
+	"context"
 	"encoding/json"
 	"fmt"
 	"ioutils"
@@ -76,6 +77,38 @@ func getBlogStore(account string) *BlogStore {
 	return store
 }
 
+// ========== 变更钩子 ==========
+// 博客保存/删除后通知其他模块（如搜索索引）。钩子在 store 锁内调用，不能回调 blog 模块。
+
+// ChangeHook 博客变更钩子
+type ChangeHook struct {
+	OnSave   func(account string, b *module.Blog)
+	OnDelete func(account, title string)
+}
+
+var changeHooks []ChangeHook
+
+// RegisterChangeHook 注册博客变更钩子（在 Init 阶段调用）
+func RegisterChangeHook(h ChangeHook) {
+	changeHooks = append(changeHooks, h)
+}
+
+func notifySaved(account string, b *module.Blog) {
+	for _, h := range changeHooks {
+		if h.OnSave != nil {
+			h.OnSave(account, b)
+		}
+	}
+}
+
+func notifyDeleted(account, title string) {
+	for _, h := range changeHooks {
+		if h.OnDelete != nil {
+			h.OnDelete(account, title)
+		}
+	}
+}
+
 func strTime() string {
 	return time.Now().Format("2006-01-02 15:04:05")
 }
@@ -98,6 +131,18 @@ func GetBlogsWithAccount(account string) map[string]*module.Blog {
 	return store.blogs
 }
 
+// SnapshotBlogsWithAccount 获取所有博客的副本（用于构建索引等不持锁的遍历）
+func SnapshotBlogsWithAccount(account string) []module.Blog {
+	store := getBlogStore(account)
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	blogs := make([]module.Blog, 0, len(store.blogs))
+	for _, b := range store.blogs {
+		blogs = append(blogs, *b)
+	}
+	return blogs
+}
+
 // GetBlogWithAccount 获取单个博客
 func GetBlogWithAccount(account, title string) *module.Blog {
 	store := getBlogStore(account)
@@ -129,6 +174,7 @@ func ImportBlogsFromPathWithAccount(account, dir string) {
 
 	// 从目录递归导入（支持子文件夹）
 	files := ioutils.GetFilesRecursive(dir)
+	imported := make([]*module.Blog, 0, len(files))
 	for _, file := range files {
 		// 计算相对路径作为 title（支持子文件夹如 agent_tasks/xxx/output）
 		relPath, err := filepath.Rel(dir, file)
@@ -145,6 +191,7 @@ func ImportBlogsFromPathWithAccount(account, dir string) {
 			if b, ok := store.blogs[name]; ok {
 				b.Account = account
 				b.Content = datas
+				imported = append(imported, b)
 				log.DebugF(log.ModuleBlog, "import update blog %s", name)
 			} else {
 				now := strTime()
@@ -157,15 +204,28 @@ func ImportBlogsFromPathWithAccount(account, dir string) {
 					AuthType:   module.EAuthType_private,
 					Account:    account,
 				}
+				imported = append(imported, store.blogs[name])
 				log.DebugF(log.ModuleBlog, "import add blog %s", name)
 			}
 		}
 	}
 	db.SaveBlogs(account, store.blogs)
+
+	// 文件内容有变化的博客记录修订（内容未变时自动跳过）
+	src := RevisionSource{Source: module.RevisionSource_system, Note: "import " + dir}
+	for _, b := range imported {
+		recordRevision(account, b, module.RevisionAction_modify, src)
+		notifySaved(account, b)
+	}
 }
 
 // AddBlogWithAccount 添加博客
 func AddBlogWithAccount(account string, udb *module.UploadedBlogData) int {
+	return AddBlogWithContext(context.Background(), account, udb)
+}
+
+// AddBlogWithContext 添加博客，修订来源取自 ctx（见 WithRevisionSource）
+func AddBlogWithContext(ctx context.Context, account string, udb *module.UploadedBlogData) int {
 	store := getBlogStore(account)
 	store.mu.Lock()
 	defer store.mu.Unlock()
@@ -206,12 +266,17 @@ func AddBlogWithAccount(account string, udb *module.UploadedBlogData) int {
 
 	log.DebugF(log.ModuleBlog, "add blog %s", title)
 	store.blogs[title] = b
-	db.SaveBlog(account, b)
+	saveBlog(account, b, module.RevisionAction_create, resolveRevisionSource(ctx, udb))
 	return 0
 }
 
 // ModifyBlogWithAccount 修改博客
 func ModifyBlogWithAccount(account string, udb *module.UploadedBlogData) int {
+	return ModifyBlogWithContext(context.Background(), account, udb)
+}
+
+// ModifyBlogWithContext 修改博客，修订来源取自 ctx
+func ModifyBlogWithContext(ctx context.Context, account string, udb *module.UploadedBlogData) int {
 	store := getBlogStore(account)
 	store.mu.Lock()
 	defer store.mu.Unlock()
@@ -236,17 +301,23 @@ func ModifyBlogWithAccount(account string, udb *module.UploadedBlogData) int {
 		b.Account = udb.Account
 	}
 
-	db.SaveBlog(account, b)
+	saveBlog(account, b, module.RevisionAction_modify, resolveRevisionSource(ctx, udb))
 	return 0
 }
 
 // DeleteBlogWithAccount 删除博客
 func DeleteBlogWithAccount(account, title string) int {
+	return DeleteBlogWithContext(context.Background(), account, title)
+}
+
+// DeleteBlogWithContext 删除博客，修订来源取自 ctx
+func DeleteBlogWithContext(ctx context.Context, account, title string) int {
 	store := getBlogStore(account)
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	if _, ok := store.blogs[title]; !ok {
+	b, ok := store.blogs[title]
+	if !ok {
 		return 1
 	}
 	if config.IsSysFile(title) == 1 {
@@ -255,7 +326,10 @@ func DeleteBlogWithAccount(account, title string) int {
 	if db.DeleteBlogWithAccount(account, title) == 1 {
 		return 3
 	}
+	// 删除也记录一条修订，保留最后的内容以便恢复
+	recordRevision(account, b, module.RevisionAction_delete, resolveRevisionSource(ctx, nil))
 	delete(store.blogs, title)
+	notifyDeleted(account, title)
 	return 0
 }
 
@@ -332,6 +406,11 @@ func IsPublicTag(tag string) int {
 
 // TagReplaceWithAccount 替换标签
 func TagReplaceWithAccount(account, from, to string) []*module.Blog {
+	return TagReplaceWithContext(context.Background(), account, from, to)
+}
+
+// TagReplaceWithContext 替换标签，修订来源取自 ctx
+func TagReplaceWithContext(ctx context.Context, account, from, to string) []*module.Blog {
 	store := getBlogStore(account)
 	store.mu.Lock()
 	defer store.mu.Unlock()
@@ -368,7 +447,7 @@ func TagReplaceWithAccount(account, from, to string) []*module.Blog {
 
 		// 去重
 		b.Tags = deduplicateTags(b.Tags)
-		db.SaveBlog(account, b)
+		saveBlog(account, b, module.RevisionAction_modify, resolveRevisionSource(ctx, nil))
 		blogs = append(blogs, b)
 	}
 	return blogs
@@ -376,6 +455,11 @@ func TagReplaceWithAccount(account, from, to string) []*module.Blog {
 
 // TagAddWithAccount 添加标签
 func TagAddWithAccount(account, title, newtag string) []*module.Blog {
+	return TagAddWithContext(context.Background(), account, title, newtag)
+}
+
+// TagAddWithContext 添加标签，修订来源取自 ctx
+func TagAddWithContext(ctx context.Context, account, title, newtag string) []*module.Blog {
 	store := getBlogStore(account)
 	store.mu.Lock()
 	defer store.mu.Unlock()
@@ -399,7 +483,7 @@ func TagAddWithAccount(account, title, newtag string) []*module.Blog {
 		log.InfoF(log.ModuleBlog, "blog add new tag %s", b.Tags)
 
 		b.Tags = deduplicateTags(b.Tags)
-		db.SaveBlog(account, b)
+		saveBlog(account, b, module.RevisionAction_modify, resolveRevisionSource(ctx, nil))
 		blogs = append(blogs, b)
 	}
 	return blogs
@@ -441,7 +525,7 @@ func SetSameAuthWithAccount(account, blogname string) {
 	for _, name := range names {
 		if b, ok := store.blogs[name]; ok {
 			b.AuthType = blog.AuthType
-			db.SaveBlog(account, b)
+			saveBlog(account, b, module.RevisionAction_modify, resolveRevisionSource(context.Background(), nil))
 		}
 	}
 }
@@ -469,7 +553,7 @@ func AddAuthTypeWithAccount(account, blogname string, flag int) {
 
 	if b, ok := store.blogs[blogname]; ok {
 		b.AuthType |= flag
-		db.SaveBlog(account, b)
+		saveBlog(account, b, module.RevisionAction_modify, resolveRevisionSource(context.Background(), nil))
 	}
 }
 
@@ -484,7 +568,7 @@ func DelAuthTypeWithAccount(account, blogname string, flag int) {
 		if b.AuthType == 0 {
 			b.AuthType = module.EAuthType_private
 		}
-		db.SaveBlog(account, b)
+		saveBlog(account, b, module.RevisionAction_modify, resolveRevisionSource(context.Background(), nil))
 	}
 }
 
@@ -0,0 +1,159 @@
+package blog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ========== 行级差异 ==========
+
+// 差异操作
+const (
+	DiffEqual  = "="
+	DiffInsert = "+"
+	DiffDelete = "-"
+)
+
+// DiffLine 差异中的一行；OldLine/NewLine 为 1 起始行号，不存在时为 0
+type DiffLine struct {
+	Op      string `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+}
+
+// RevisionDiff 两个修订之间的差异
+type RevisionDiff struct {
+	Title   string     `json:"title"`
+	From    int        `json:"from"`
+	To      int        `json:"to"` // 0 表示博客当前内容
+	Added   int        `json:"added"`
+	Removed int        `json:"removed"`
+	Lines   []DiffLine `json:"lines"`
+}
+
+// maxDiffCells LCS 表规模上限，超过时退化为整体替换
+const maxDiffCells = 4000000
+
+// DiffLines 计算 a → b 的行级差异（去除公共首尾后对中间部分做 LCS）
+func DiffLines(a, b string) []DiffLine {
+	al := splitLines(a)
+	bl := splitLines(b)
+
+	prefix := 0
+	for prefix < len(al) && prefix < len(bl) && al[prefix] == bl[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(al)-prefix && suffix < len(bl)-prefix &&
+		al[len(al)-1-suffix] == bl[len(bl)-1-suffix] {
+		suffix++
+	}
+
+	out := make([]DiffLine, 0, len(al)+len(bl))
+	for i := 0; i < prefix; i++ {
+		out = append(out, DiffLine{Op: DiffEqual, Text: al[i], OldLine: i + 1, NewLine: i + 1})
+	}
+
+	am := al[prefix : len(al)-suffix]
+	bm := bl[prefix : len(bl)-suffix]
+	out = append(out, diffMiddle(am, bm, prefix)...)
+
+	for i := 0; i < suffix; i++ {
+		oi := len(al) - suffix + i
+		ni := len(bl) - suffix + i
+		out = append(out, DiffLine{Op: DiffEqual, Text: al[oi], OldLine: oi + 1, NewLine: ni + 1})
+	}
+	return out
+}
+
+func diffMiddle(a, b []string, offset int) []DiffLine {
+	n, m := len(a), len(b)
+	var out []DiffLine
+	if n*m > maxDiffCells {
+		for i, l := range a {
+			out = append(out, DiffLine{Op: DiffDelete, Text: l, OldLine: offset + i + 1})
+		}
+		for j, l := range b {
+			out = append(out, DiffLine{Op: DiffInsert, Text: l, NewLine: offset + j + 1})
+		}
+		return out
+	}
+
+	// lcs[i][j] = a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			out = append(out, DiffLine{Op: DiffEqual, Text: a[i], OldLine: offset + i + 1, NewLine: offset + j + 1})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			out = append(out, DiffLine{Op: DiffInsert, Text: b[j], NewLine: offset + j + 1})
+			j++
+		default:
+			out = append(out, DiffLine{Op: DiffDelete, Text: a[i], OldLine: offset + i + 1})
+			i++
+		}
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Unified 以统一差异格式输出（仅含变更行及上下文 context 行）
+func (d *RevisionDiff) Unified(context int) string {
+	var sb strings.Builder
+	to := "current"
+	if d.To > 0 {
+		to = fmt.Sprintf("#%d", d.To)
+	}
+	fmt.Fprintf(&sb, "--- %s #%d\n+++ %s %s\n", d.Title, d.From, d.Title, to)
+
+	show := make([]bool, len(d.Lines))
+	for i, l := range d.Lines {
+		if l.Op == DiffEqual {
+			continue
+		}
+		for k := i - context; k <= i+context; k++ {
+			if k >= 0 && k < len(d.Lines) {
+				show[k] = true
+			}
+		}
+	}
+	for i, l := range d.Lines {
+		if !show[i] {
+			if i > 0 && show[i-1] {
+				sb.WriteString("...\n")
+			}
+			continue
+		}
+		op := " "
+		if l.Op != DiffEqual {
+			op = l.Op
+		}
+		sb.WriteString(op + l.Text + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,39 @@
+package blog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	old := "# todo\n- a\n- b\n- c\n"
+	cur := "# todo\n- a\n- B\n- c\n- d\n"
+
+	lines := DiffLines(old, cur)
+	var ops []string
+	for _, l := range lines {
+		ops = append(ops, l.Op+l.Text)
+	}
+	want := []string{"=# todo", "=- a", "-- b", "+- B", "=- c", "+- d"}
+	if strings.Join(ops, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected diff:\n got %v\nwant %v", ops, want)
+	}
+	if lines[2].OldLine != 3 || lines[3].NewLine != 3 || lines[5].NewLine != 5 {
+		t.Fatalf("unexpected line numbers: %+v", lines)
+	}
+
+	d := &RevisionDiff{Title: "todo", From: 1, Lines: lines}
+	unified := d.Unified(0)
+	if !strings.Contains(unified, "-- b\n+- B\n") || strings.Contains(unified, " - a") {
+		t.Fatalf("unexpected unified output:\n%s", unified)
+	}
+}
+
+func TestDiffLinesEmpty(t *testing.T) {
+	if lines := DiffLines("", "x\ny"); len(lines) != 2 || lines[0].Op != DiffInsert {
+		t.Fatalf("expected two inserts, got %+v", lines)
+	}
+	if lines := DiffLines("same", "same"); len(lines) != 1 || lines[0].Op != DiffEqual {
+		t.Fatalf("expected one equal line, got %+v", lines)
+	}
+}
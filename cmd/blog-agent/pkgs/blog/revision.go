@@ -0,0 +1,176 @@
+package blog
+
+import (
+	"context"
+	"fmt"
+	"module"
+	log "mylog"
+	db "persistence"
+)
+
+// ========== 修订历史 ==========
+// 每次博客内容变化（新建、修改、标签变更、删除、恢复）都会追加一条不可变修订，
+// 记录来源（web / llm-tool / restore ...）和修改者，支持查看差异和一键恢复。
+
+// RevisionSource 修订来源
+type RevisionSource struct {
+	Source string
+	Author string
+	Note   string
+}
+
+type revisionSourceKey struct{}
+
+// WithRevisionSource 返回携带修订来源的 ctx，通过 ctx 写入且未显式指定来源的修改都归属于 src。
+// 嵌套时 Source/Author 取最外层，Note 取最内层。
+func WithRevisionSource(ctx context.Context, src RevisionSource) context.Context {
+	if outer, ok := ctx.Value(revisionSourceKey{}).(RevisionSource); ok {
+		if outer.Source != "" {
+			src.Source = outer.Source
+		}
+		if outer.Author != "" {
+			src.Author = outer.Author
+		}
+		if src.Note == "" {
+			src.Note = outer.Note
+		}
+	}
+	return context.WithValue(ctx, revisionSourceKey{}, src)
+}
+
+// resolveRevisionSource 确定本次写入的来源：显式指定 > ctx > system
+func resolveRevisionSource(ctx context.Context, udb *module.UploadedBlogData) RevisionSource {
+	src, _ := ctx.Value(revisionSourceKey{}).(RevisionSource)
+	if udb != nil && udb.Source != "" {
+		src.Source = udb.Source
+		src.Author = udb.Author
+	}
+	if src.Source == "" {
+		src.Source = module.RevisionSource_system
+	}
+	return src
+}
+
+// saveBlog 保存博客并记录修订（调用方持有 store 锁）
+func saveBlog(account string, b *module.Blog, action string, src RevisionSource) {
+	db.SaveBlog(account, b)
+	recordRevision(account, b, action, src)
+	notifySaved(account, b)
+}
+
+func recordRevision(account string, b *module.Blog, action string, src RevisionSource) int {
+	seq := db.AppendBlogRevision(account, &module.BlogRevision{
+		Title:    b.Title,
+		Content:  b.Content,
+		Tags:     b.Tags,
+		AuthType: b.AuthType,
+		Encrypt:  b.Encrypt,
+		Time:     strTime(),
+		Action:   action,
+		Source:   src.Source,
+		Author:   src.Author,
+		Note:     src.Note,
+	})
+	if seq > 0 {
+		log.DebugF(log.ModuleBlog, "blog revision title=%s seq=%d action=%s source=%s", b.Title, seq, action, src.Source)
+	}
+	return seq
+}
+
+// ListRevisionsWithAccount 列出博客修订（不含内容，按修订号降序）
+func ListRevisionsWithAccount(account, title string) []*module.BlogRevision {
+	revs := db.GetBlogRevisions(account, title)
+	out := make([]*module.BlogRevision, 0, len(revs))
+	for i := len(revs) - 1; i >= 0; i-- {
+		rev := *revs[i]
+		rev.Content = ""
+		out = append(out, &rev)
+	}
+	return out
+}
+
+// GetRevisionWithAccount 获取指定修订（含内容）
+func GetRevisionWithAccount(account, title string, seq int) *module.BlogRevision {
+	return db.GetBlogRevision(account, title, seq)
+}
+
+// DiffRevisionsWithAccount 比较两个修订；to 为 0 时与博客当前内容比较
+func DiffRevisionsWithAccount(account, title string, from, to int) (*RevisionDiff, error) {
+	old := db.GetBlogRevision(account, title, from)
+	if old == nil {
+		return nil, fmt.Errorf("revision %d not found", from)
+	}
+
+	var newContent string
+	if to > 0 {
+		rev := db.GetBlogRevision(account, title, to)
+		if rev == nil {
+			return nil, fmt.Errorf("revision %d not found", to)
+		}
+		newContent = rev.Content
+	} else {
+		b := GetBlogWithAccount(account, title)
+		if b == nil {
+			return nil, fmt.Errorf("blog %s not found", title)
+		}
+		newContent = b.Content
+	}
+
+	lines := DiffLines(old.Content, newContent)
+	d := &RevisionDiff{Title: title, From: from, To: to, Lines: lines}
+	for _, l := range lines {
+		switch l.Op {
+		case DiffInsert:
+			d.Added++
+		case DiffDelete:
+			d.Removed++
+		}
+	}
+	return d, nil
+}
+
+// RestoreRevisionWithAccount 将博客恢复到指定修订（博客已删除时重新创建）；
+// 恢复内容、标签和加密标志，权限保持当前设置。返回新修订号。
+func RestoreRevisionWithAccount(account, title string, seq int, src RevisionSource) (int, error) {
+	rev := db.GetBlogRevision(account, title, seq)
+	if rev == nil {
+		return 0, fmt.Errorf("revision %d not found", seq)
+	}
+
+	store := getBlogStore(account)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := strTime()
+	b, ok := store.blogs[title]
+	if !ok {
+		b = &module.Blog{
+			Title:      title,
+			CreateTime: now,
+			AccessTime: now,
+			AuthType:   rev.AuthType,
+			Account:    account,
+		}
+		store.blogs[title] = b
+	} else {
+		b.ModifyNum++
+	}
+	b.Content = rev.Content
+	b.Tags = rev.Tags
+	b.Encrypt = rev.Encrypt
+	b.ModifyTime = now
+	if b.Encrypt == 1 {
+		b.AuthType = module.EAuthType_encrypt
+	}
+
+	if src.Source == "" {
+		src.Source = module.RevisionSource_restore
+	}
+	if src.Note == "" {
+		src.Note = fmt.Sprintf("restore from #%d", seq)
+	}
+	log.MessageF(log.ModuleBlog, "restore blog %s to revision %d source=%s author=%s", title, seq, src.Source, src.Author)
+	db.SaveBlog(account, b)
+	notifySaved(account, b)
+	return recordRevision(account, b, module.RevisionAction_restore, src), nil
+}
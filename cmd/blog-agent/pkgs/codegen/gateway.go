@@ -22,8 +22,8 @@ type MCPToolInfo struct {
 
 // MCP 桥接函数（由 agent 包注入，避免 codegen 直接依赖 mcp 的重量级传递依赖链）
 var (
-	// MCPCallInnerTools 调用 MCP 内部工具
-	MCPCallInnerTools func(name string, args map[string]interface{}) string
+	// MCPCallInnerTools 调用 MCP 内部工具，author 为 tool_call 携带的已认证用户
+	MCPCallInnerTools func(name string, args map[string]interface{}, author string) string
 	// MCPGetToolInfos 获取 MCP 工具定义列表
 	MCPGetToolInfos func() []MCPToolInfo
 
@@ -854,7 +854,7 @@ func (b *GatewayBridge) handleToolCall(msg *uap.Message) {
 	}
 	resultCh := make(chan mcpResult, 1)
 	go func() {
-		r := MCPCallInnerTools(mcpName, args)
+		r := MCPCallInnerTools(mcpName, args, payload.AuthenticatedUser)
 		resultCh <- mcpResult{result: r}
 	}()
 
@@ -487,6 +487,16 @@ func GetMaxBlogComments() int {
 	return cnt
 }
 
+// GetBlogRevisionKeep 每篇博客保留的修订数，超出后删除最旧的修订
+func GetBlogRevisionKeep() int {
+	str_cnt := GetConfigWithAccount(adminAccount, "blog_revision_keep")
+	cnt, _ := strconv.Atoi(str_cnt)
+	if cnt <= 0 {
+		cnt = 100
+	}
+	return cnt
+}
+
 func GetMainBlogNum() int {
 	str_cnt := GetConfigWithAccount(adminAccount, "main_show_blogs")
 	cnt, _ := strconv.Atoi(str_cnt)
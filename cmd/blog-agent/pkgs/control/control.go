@@ -3,6 +3,7 @@ package control
 import (
 	"blog"
 	"comment"
+	"context"
 	"errors"
 	"module"
 	log "mylog"
@@ -46,6 +47,11 @@ func GetMatch(account, match string) []*module.Blog {
 	return search.Search(account, match)
 }
 
+// SearchHits 全文检索，返回带相关度和摘要的结果
+func SearchHits(account, query string, limit int) []*search.Hit {
+	return search.SearchHits(account, query, limit)
+}
+
 func UpdateAccessTime(account string, b *module.Blog) {
 	blog.UpdateAccessTimeWithAccount(account, b)
 }
@@ -244,6 +250,32 @@ func DeleteBlog(account, title string) int {
 	return blog.DeleteBlogWithAccount(account, title)
 }
 
+// DeleteBlogFromWeb 网页端删除博客（修订历史记为 web 来源）
+func DeleteBlogFromWeb(account, title string) int {
+	ctx := blog.WithRevisionSource(context.Background(), blog.RevisionSource{Source: module.RevisionSource_web, Author: account})
+	return blog.DeleteBlogWithContext(ctx, account, title)
+}
+
+// 修订历史
+func ListBlogRevisions(account, title string) []*module.BlogRevision {
+	return blog.ListRevisionsWithAccount(account, title)
+}
+
+func GetBlogRevision(account, title string, seq int) *module.BlogRevision {
+	return blog.GetRevisionWithAccount(account, title, seq)
+}
+
+func DiffBlogRevisions(account, title string, from, to int) (*blog.RevisionDiff, error) {
+	return blog.DiffRevisionsWithAccount(account, title, from, to)
+}
+
+func RestoreBlogRevision(account, title string, seq int, author string) (int, error) {
+	return blog.RestoreRevisionWithAccount(account, title, seq, blog.RevisionSource{
+		Source: module.RevisionSource_web,
+		Author: author,
+	})
+}
+
 func GetRecentlyTimedBlog(account, title string) *module.Blog {
 	return blog.GetRecentlyTimedBlogWithAccount(account, title)
 }
@@ -3,6 +3,7 @@ package exercise
 import (
 	"account"
 	"blog"
+	"context"
 	"encoding/json"
 	"fmt"
 	"module"
@@ -150,7 +151,7 @@ func calculateCaloriesInternal(exerciseType, intensity string, duration int, wei
 
 // ========== 对外接口 ==========
 
-func AddExercise(acc, date, name, exerciseType string, duration int, intensity string, calories int, notes string, weight float64, bodyParts []string) (*ExerciseItem, error) {
+func AddExercise(ctx context.Context, acc, date, name, exerciseType string, duration int, intensity string, calories int, notes string, weight float64, bodyParts []string) (*ExerciseItem, error) {
 	exerciseMu.Lock()
 	defer exerciseMu.Unlock()
 
@@ -172,13 +173,13 @@ func AddExercise(acc, date, name, exerciseType string, duration int, intensity s
 	}
 	exerciseList.Items = append(exerciseList.Items, item)
 
-	if err := saveExercisesToBlog(acc, exerciseList); err != nil {
+	if err := saveExercisesToBlog(ctx, acc, exerciseList); err != nil {
 		return nil, err
 	}
 	return &item, nil
 }
 
-func DeleteExercise(acc, date, id string) error {
+func DeleteExercise(ctx context.Context, acc, date, id string) error {
 	exerciseMu.Lock()
 	defer exerciseMu.Unlock()
 
@@ -200,10 +201,10 @@ func DeleteExercise(acc, date, id string) error {
 		return fmt.Errorf("exercise item not found")
 	}
 	exerciseList.Items = updatedItems
-	return saveExercisesToBlog(acc, exerciseList)
+	return saveExercisesToBlog(ctx, acc, exerciseList)
 }
 
-func UpdateExercise(acc, date, id, name, exerciseType string, duration int, intensity string, calories int, notes string, weight float64, bodyParts []string) error {
+func UpdateExercise(ctx context.Context, acc, date, id, name, exerciseType string, duration int, intensity string, calories int, notes string, weight float64, bodyParts []string) error {
 	exerciseMu.Lock()
 	defer exerciseMu.Unlock()
 
@@ -236,10 +237,10 @@ func UpdateExercise(acc, date, id, name, exerciseType string, duration int, inte
 	if !found {
 		return fmt.Errorf("exercise item not found")
 	}
-	return saveExercisesToBlog(acc, exerciseList)
+	return saveExercisesToBlog(ctx, acc, exerciseList)
 }
 
-func ToggleExercise(acc, date, id string) error {
+func ToggleExercise(ctx context.Context, acc, date, id string) error {
 	exerciseMu.Lock()
 	defer exerciseMu.Unlock()
 
@@ -265,7 +266,7 @@ func ToggleExercise(acc, date, id string) error {
 	if !found {
 		return fmt.Errorf("exercise item not found")
 	}
-	return saveExercisesToBlog(acc, exerciseList)
+	return saveExercisesToBlog(ctx, acc, exerciseList)
 }
 
 func GetExercisesByDate(acc, date string) (ExerciseList, error) {
@@ -306,7 +307,7 @@ func getAllExercisesInternal(acc string) (map[string]ExerciseList, error) {
 	return result, nil
 }
 
-func saveExercisesToBlog(acc string, exerciseList ExerciseList) error {
+func saveExercisesToBlog(ctx context.Context, acc string, exerciseList ExerciseList) error {
 	title := generateBlogTitle(exerciseList.Date)
 	content, err := json.MarshalIndent(exerciseList, "", "  ")
 	if err != nil {
@@ -316,9 +317,9 @@ func saveExercisesToBlog(acc string, exerciseList ExerciseList) error {
 		Title: title, Content: string(content), Tags: "exercise", AuthType: module.EAuthType_private, Account: acc,
 	}
 	if blog.GetBlogWithAccount(acc, title) == nil {
-		blog.AddBlogWithAccount(acc, ubd)
+		blog.AddBlogWithContext(ctx, acc, ubd)
 	} else {
-		blog.ModifyBlogWithAccount(acc, ubd)
+		blog.ModifyBlogWithContext(ctx, acc, ubd)
 	}
 	return nil
 }
@@ -561,7 +562,7 @@ func AddFromCollection(acc, date, collectionID string) error {
 					Completed: false, Weight: template.Weight, CreatedAt: time.Now(), BodyParts: template.BodyParts,
 				}
 				exerciseList.Items = append(exerciseList.Items, item)
-				saveExercisesToBlog(acc, exerciseList)
+				saveExercisesToBlog(context.Background(), acc, exerciseList)
 				break
 			}
 		}
@@ -786,7 +787,7 @@ func UpdateAllExerciseCalories(acc string, weight float64) (int, error) {
 			}
 		}
 		if updated {
-			saveExercisesToBlog(acc, exerciseList)
+			saveExercisesToBlog(context.Background(), acc, exerciseList)
 		}
 		_ = date
 	}
@@ -58,7 +58,7 @@ func HandleToggleExercise(w http.ResponseWriter, r *http.Request) {
 	}
 
 	account := getAccountFromRequest(r)
-	if err := ToggleExercise(account, req.Date, req.ID); err != nil {
+	if err := ToggleExercise(r.Context(), account, req.Date, req.ID); err != nil {
 		log.ErrorF(log.ModuleExercise, "Failed to toggle exercise: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -184,7 +184,7 @@ func handleAddExercise(w http.ResponseWriter, r *http.Request) {
 	}
 
 	account := getAccountFromRequest(r)
-	item, err := AddExercise(account, req.Date, req.Name, req.Type, req.Duration, req.Intensity, req.Calories, req.Notes, req.Weight, req.BodyParts)
+	item, err := AddExercise(r.Context(), account, req.Date, req.Name, req.Type, req.Duration, req.Intensity, req.Calories, req.Notes, req.Weight, req.BodyParts)
 	if err != nil {
 		log.ErrorF(log.ModuleExercise, "Failed to add exercise: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -213,7 +213,7 @@ func handleUpdateExercise(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	account := getAccountFromRequest(r)
-	if err := UpdateExercise(account, req.Date, req.ID, req.Name, req.Type, req.Duration, req.Intensity, req.Calories, req.Notes, req.Weight, req.BodyParts); err != nil {
+	if err := UpdateExercise(r.Context(), account, req.Date, req.ID, req.Name, req.Type, req.Duration, req.Intensity, req.Calories, req.Notes, req.Weight, req.BodyParts); err != nil {
 		log.ErrorF(log.ModuleExercise, "Failed to update exercise: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -234,7 +234,7 @@ func handleDeleteExercise(w http.ResponseWriter, r *http.Request) {
 	}
 
 	account := getAccountFromRequest(r)
-	if err := DeleteExercise(account, req.Date, req.ID); err != nil {
+	if err := DeleteExercise(r.Context(), account, req.Date, req.ID); err != nil {
 		log.ErrorF(log.ModuleExercise, "Failed to delete exercise: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -27,6 +27,7 @@ func HandleMCPToolsAPI(w h.ResponseWriter, r *h.Request) {
 
 	// 生成请求 ID 用于 delegation token 上下文
 	requestID := fmt.Sprintf("%d", time.Now().UnixNano())
+	var author string
 
 	// 检查是否有 delegation token
 	delegationTokenHeader := r.Header.Get("X-Delegation-Token")
@@ -51,6 +52,7 @@ func HandleMCPToolsAPI(w h.ResponseWriter, r *h.Request) {
 			h.Error(w, "Unauthorized", h.StatusUnauthorized)
 			return
 		}
+		author = getAccountFromRequest(r)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -78,7 +80,7 @@ func HandleMCPToolsAPI(w h.ResponseWriter, r *h.Request) {
 			return
 		}
 
-		result := mcp.CallToolForAPI(toolCall, requestID)
+		result := mcp.CallToolForAPI(toolCall, requestID, author)
 		json.NewEncoder(w).Encode(result)
 
 	default:
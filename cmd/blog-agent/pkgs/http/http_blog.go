@@ -114,6 +114,8 @@ func HandleSave(w h.ResponseWriter, r *h.Request) {
 		Tags:     tags,
 		Encrypt:  encrypt,
 		Account:  account,
+		Source:   module.RevisionSource_web,
+		Author:   account,
 	}
 
 	ret := control.AddBlog(account, &ubd)
@@ -600,7 +602,7 @@ func HandleDelete(w h.ResponseWriter, r *h.Request) {
 
 	account := getAccountFromRequest(r)
 
-	ret := control.DeleteBlog(account, title)
+	ret := control.DeleteBlogFromWeb(account, title)
 	if ret == 0 {
 		w.Write([]byte(fmt.Sprintf("Content received successfully! ret=%d", ret)))
 	} else {
@@ -678,6 +680,8 @@ func HandleModify(w h.ResponseWriter, r *h.Request) {
 		Tags:     tags,
 		Encrypt:  encrypt,
 		Account:  account,
+		Source:   module.RevisionSource_web,
+		Author:   account,
 	}
 
 	ret := control.ModifyBlog(account, &ubd)
@@ -1011,6 +1015,8 @@ func HandleMigrationImport(w h.ResponseWriter, r *h.Request) {
 			Tags:     existingBlog.Tags,
 			Encrypt:  existingBlog.Encrypt,
 			Account:  account,
+			Source:   module.RevisionSource_web,
+			Author:   account,
 		}
 
 		ret := control.ModifyBlog(account, &ubd)
@@ -122,6 +122,8 @@ func createDefaultConfig(w h.ResponseWriter, account, configTitle string, isAdmi
 			"redis_ip":                   "Redis服务器IP地址",
 			"redis_port":                 "Redis服务器端口",
 			"redis_pwd":                  "Redis密码（留空表示无密码）",
+			"storage_backend":            "存储后端（redis|file|bolt，默认redis）",
+			"storage_path":               "存储路径（file为目录，bolt为数据库文件，留空使用默认位置）",
 			"publictags":                 "公开标签列表（用|分隔）",
 			"sysfiles":                   "系统文件列表（用|分隔）",
 			"title_auto_add_date_suffix": "自动添加日期后缀的标题前缀（用|分隔）",
@@ -158,6 +160,8 @@ func createDefaultConfig(w h.ResponseWriter, account, configTitle string, isAdmi
 		AuthType: module.EAuthType_private,
 		Tags:     "system,config",
 		Encrypt:  0,
+		Source:   module.RevisionSource_web,
+		Author:   account,
 	}
 
 	result := control.AddBlog(account, uploadData)
@@ -209,6 +213,8 @@ func handleUpdateConfig(w h.ResponseWriter, r *h.Request, account string, isAdmi
 		AuthType: module.EAuthType_private,
 		Tags:     "system,config",
 		Encrypt:  0,
+		Source:   module.RevisionSource_web,
+		Author:   account,
 	}
 
 	result := control.ModifyBlog(account, uploadData)
@@ -349,8 +355,8 @@ func buildConfigContentWithComments(configs map[string]string, comments map[stri
 	configOrder := []string{
 		// 基础设置
 		"port", "pwd", "admin", "logs_dir", "statics_path", "templates_path", "download_path", "recycle_path",
-		// Redis 缓存
-		"redis_ip", "redis_port", "redis_pwd",
+		// 存储
+		"storage_backend", "storage_path", "redis_ip", "redis_port", "redis_pwd",
 		// 博客设置
 		"publictags", "sysfiles", "main_show_blogs", "max_blog_comments", "share_days", "help_blog_name",
 		// 日记设置
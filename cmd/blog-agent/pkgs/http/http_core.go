@@ -222,6 +222,10 @@ func Init() int {
 	h.HandleFunc("/get", HandleGet)
 	h.HandleFunc("/modify", HandleModify)
 	h.HandleFunc("/delete", HandleDelete)
+	h.HandleFunc("/api/blog/revisions", HandleBlogRevisions)
+	h.HandleFunc("/api/blog/revision", HandleBlogRevision)
+	h.HandleFunc("/api/blog/revision/diff", HandleBlogRevisionDiff)
+	h.HandleFunc("/api/blog/revision/restore", HandleBlogRevisionRestore)
 	h.HandleFunc("/search", HandleSearch)
 	h.HandleFunc("/comment", HandleComment)
 	h.HandleFunc("/api/check-username", HandleCheckUsername)
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"control"
+	"encoding/json"
+	"fmt"
+	log "mylog"
+	h "net/http"
+	"strconv"
+)
+
+// ========== 博客修订历史 ==========
+// GET  /api/blog/revisions?title=xxx                      修订列表（不含内容）
+// GET  /api/blog/revision?title=xxx&seq=N                 单个修订（含内容）
+// GET  /api/blog/revision/diff?title=xxx&from=N[&to=M]    行级差异，to 省略时与当前内容比较；format=unified 返回文本
+// POST /api/blog/revision/restore  title=xxx&seq=N        恢复到指定修订
+
+// HandleBlogRevisions 列出博客修订
+func HandleBlogRevisions(w h.ResponseWriter, r *h.Request) {
+	LogRemoteAddr("HandleBlogRevisions", r)
+	if checkLogin(r) != 0 {
+		h.Error(w, "Unauthorized", h.StatusUnauthorized)
+		return
+	}
+
+	title := r.URL.Query().Get("title")
+	if title == "" {
+		h.Error(w, "title parameter is missing", h.StatusBadRequest)
+		return
+	}
+
+	account := getAccountFromRequest(r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"title":     title,
+		"revisions": control.ListBlogRevisions(account, title),
+	})
+}
+
+// HandleBlogRevision 获取单个修订
+func HandleBlogRevision(w h.ResponseWriter, r *h.Request) {
+	LogRemoteAddr("HandleBlogRevision", r)
+	if checkLogin(r) != 0 {
+		h.Error(w, "Unauthorized", h.StatusUnauthorized)
+		return
+	}
+
+	title := r.URL.Query().Get("title")
+	seq, err := strconv.Atoi(r.URL.Query().Get("seq"))
+	if title == "" || err != nil {
+		h.Error(w, "title and seq parameters are required", h.StatusBadRequest)
+		return
+	}
+
+	account := getAccountFromRequest(r)
+	rev := control.GetBlogRevision(account, title, seq)
+	if rev == nil {
+		h.Error(w, fmt.Sprintf("revision %d of %s not found", seq, title), h.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"revision": rev,
+	})
+}
+
+// HandleBlogRevisionDiff 比较两个修订
+func HandleBlogRevisionDiff(w h.ResponseWriter, r *h.Request) {
+	LogRemoteAddr("HandleBlogRevisionDiff", r)
+	if checkLogin(r) != 0 {
+		h.Error(w, "Unauthorized", h.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	title := q.Get("title")
+	from, err := strconv.Atoi(q.Get("from"))
+	if title == "" || err != nil {
+		h.Error(w, "title and from parameters are required", h.StatusBadRequest)
+		return
+	}
+	to, _ := strconv.Atoi(q.Get("to"))
+
+	account := getAccountFromRequest(r)
+	diff, err := control.DiffBlogRevisions(account, title, from, to)
+	if err != nil {
+		h.Error(w, err.Error(), h.StatusNotFound)
+		return
+	}
+
+	if q.Get("format") == "unified" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(diff.Unified(3)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"diff":    diff,
+	})
+}
+
+// HandleBlogRevisionRestore 恢复到指定修订
+func HandleBlogRevisionRestore(w h.ResponseWriter, r *h.Request) {
+	LogRemoteAddr("HandleBlogRevisionRestore", r)
+	if checkLogin(r) != 0 {
+		h.Error(w, "Unauthorized", h.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != h.MethodPost {
+		h.Error(w, "Method not allowed", h.StatusMethodNotAllowed)
+		return
+	}
+
+	r.ParseForm()
+	title := r.FormValue("title")
+	seq, err := strconv.Atoi(r.FormValue("seq"))
+	if title == "" || err != nil {
+		h.Error(w, "title and seq parameters are required", h.StatusBadRequest)
+		return
+	}
+
+	account := getAccountFromRequest(r)
+	newSeq, err := control.RestoreBlogRevision(account, title, seq, account)
+	if err != nil {
+		h.Error(w, err.Error(), h.StatusNotFound)
+		return
+	}
+	log.InfoF(log.ModuleBlog, "blog %s restored to revision %d by %s", title, seq, account)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"title":        title,
+		"restored_seq": seq,
+		"revision":     newSeq,
+	})
+}
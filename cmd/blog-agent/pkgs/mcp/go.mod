@@ -15,3 +15,5 @@ replace mylog => ../../../common/mylog
 replace config => ../config
 
 replace statistics => ../statistics
+
+replace search => ../search
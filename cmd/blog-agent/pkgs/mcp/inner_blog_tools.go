@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"statistics"
 	"strconv"
 )
@@ -305,7 +306,7 @@ func Inner_blog_RawGetCurrentTaskByRageDate(arguments map[string]interface{}) st
 	return wrapResult(statistics.RawGetCurrentTaskByRageDate(account, startDate, endDate))
 }
 
-func Inner_blog_RawCreateBlog(arguments map[string]interface{}) string {
+func Inner_blog_RawCreateBlog(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -331,7 +332,7 @@ func Inner_blog_RawCreateBlog(arguments map[string]interface{}) string {
 		return errorJSON(err.Error())
 	}
 	encrypt := getOptionalIntParam(arguments, "encrypt", 0)
-	return wrapResult(statistics.RawCreateBlog(account, title, content, tags, authType, encrypt))
+	return wrapResult(statistics.RawCreateBlog(ctx, account, title, content, tags, authType, encrypt))
 }
 
 // =================================== 扩展Inner_blog接口 =========================================
@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"statistics"
 )
 
@@ -44,7 +45,7 @@ func Inner_blog_RawGetExerciseRange(arguments map[string]interface{}) string {
 	return wrapResult(statistics.RawGetExerciseRange(account, startDate, endDate))
 }
 
-func Inner_blog_RawAddExercise(arguments map[string]interface{}) string {
+func Inner_blog_RawAddExercise(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -75,7 +76,7 @@ func Inner_blog_RawAddExercise(arguments map[string]interface{}) string {
 	}
 	calories := getOptionalIntParam(arguments, "calories", 0)
 	notes, _ := getStringParam(arguments, "notes")
-	return wrapResult(statistics.RawAddExercise(account, date, name, exerciseType, duration, intensity, calories, notes))
+	return wrapResult(statistics.RawAddExercise(ctx, account, date, name, exerciseType, duration, intensity, calories, notes))
 }
 
 func Inner_blog_RawGetExerciseStats(arguments map[string]interface{}) string {
@@ -91,7 +92,7 @@ func Inner_blog_RawGetExerciseStats(arguments map[string]interface{}) string {
 	return wrapResult(statistics.RawGetExerciseStats(account, days))
 }
 
-func Inner_blog_RawToggleExercise(arguments map[string]interface{}) string {
+func Inner_blog_RawToggleExercise(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -108,10 +109,10 @@ func Inner_blog_RawToggleExercise(arguments map[string]interface{}) string {
 	if err != nil {
 		return errorJSON(err.Error())
 	}
-	return wrapResult(statistics.RawToggleExercise(account, date, id))
+	return wrapResult(statistics.RawToggleExercise(ctx, account, date, id))
 }
 
-func Inner_blog_RawDeleteExercise(arguments map[string]interface{}) string {
+func Inner_blog_RawDeleteExercise(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -128,10 +129,10 @@ func Inner_blog_RawDeleteExercise(arguments map[string]interface{}) string {
 	if err != nil {
 		return errorJSON(err.Error())
 	}
-	return wrapResult(statistics.RawDeleteExercise(account, date, id))
+	return wrapResult(statistics.RawDeleteExercise(ctx, account, date, id))
 }
 
-func Inner_blog_RawUpdateExercise(arguments map[string]interface{}) string {
+func Inner_blog_RawUpdateExercise(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -166,5 +167,5 @@ func Inner_blog_RawUpdateExercise(arguments map[string]interface{}) string {
 	}
 	calories := getOptionalIntParam(arguments, "calories", 0)
 	notes, _ := getStringParam(arguments, "notes")
-	return wrapResult(statistics.RawUpdateExercise(account, date, id, name, exerciseType, duration, intensity, calories, notes))
+	return wrapResult(statistics.RawUpdateExercise(ctx, account, date, id, name, exerciseType, duration, intensity, calories, notes))
 }
@@ -1,8 +1,11 @@
 package mcp
 
-import "statistics"
+import (
+	"context"
+	"statistics"
+)
 
-func Inner_blog_RawCreateProject(arguments map[string]interface{}) string {
+func Inner_blog_RawCreateProject(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -28,7 +31,7 @@ func Inner_blog_RawCreateProject(arguments map[string]interface{}) string {
 	startDate, _ := getStringParam(arguments, "startDate")
 	endDate, _ := getStringParam(arguments, "endDate")
 	tags, _ := getStringParam(arguments, "tags")
-	return wrapResult(statistics.RawCreateProject(account, name, description, status, priority, owner, startDate, endDate, tags))
+	return wrapResult(statistics.RawCreateProject(ctx, account, name, description, status, priority, owner, startDate, endDate, tags))
 }
 
 func Inner_blog_RawGetProject(arguments map[string]interface{}) string {
@@ -60,7 +63,7 @@ func Inner_blog_RawListProjects(arguments map[string]interface{}) string {
 	return wrapResult(statistics.RawListProjects(account, status))
 }
 
-func Inner_blog_RawUpdateProject(arguments map[string]interface{}) string {
+func Inner_blog_RawUpdateProject(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -81,10 +84,10 @@ func Inner_blog_RawUpdateProject(arguments map[string]interface{}) string {
 	startDate, _ := getStringParam(arguments, "startDate")
 	endDate, _ := getStringParam(arguments, "endDate")
 	tags, _ := getStringParam(arguments, "tags")
-	return wrapResult(statistics.RawUpdateProject(account, projectID, name, description, status, priority, owner, startDate, endDate, tags))
+	return wrapResult(statistics.RawUpdateProject(ctx, account, projectID, name, description, status, priority, owner, startDate, endDate, tags))
 }
 
-func Inner_blog_RawDeleteProject(arguments map[string]interface{}) string {
+func Inner_blog_RawDeleteProject(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -97,10 +100,10 @@ func Inner_blog_RawDeleteProject(arguments map[string]interface{}) string {
 	if err != nil {
 		return errorJSON(err.Error())
 	}
-	return wrapResult(statistics.RawDeleteProject(account, projectID))
+	return wrapResult(statistics.RawDeleteProject(ctx, account, projectID))
 }
 
-func Inner_blog_RawAddProjectGoal(arguments map[string]interface{}) string {
+func Inner_blog_RawAddProjectGoal(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -129,10 +132,10 @@ func Inner_blog_RawAddProjectGoal(arguments map[string]interface{}) string {
 	startDate, _ := getStringParam(arguments, "startDate")
 	endDate, _ := getStringParam(arguments, "endDate")
 	progress := getOptionalIntParam(arguments, "progress", 0)
-	return wrapResult(statistics.RawAddProjectGoal(account, projectID, title, description, status, priority, startDate, endDate, progress))
+	return wrapResult(statistics.RawAddProjectGoal(ctx, account, projectID, title, description, status, priority, startDate, endDate, progress))
 }
 
-func Inner_blog_RawUpdateProjectGoal(arguments map[string]interface{}) string {
+func Inner_blog_RawUpdateProjectGoal(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -165,10 +168,10 @@ func Inner_blog_RawUpdateProjectGoal(arguments map[string]interface{}) string {
 	startDate, _ := getStringParam(arguments, "startDate")
 	endDate, _ := getStringParam(arguments, "endDate")
 	progress := getOptionalIntParam(arguments, "progress", 0)
-	return wrapResult(statistics.RawUpdateProjectGoal(account, projectID, goalID, title, description, status, priority, startDate, endDate, progress))
+	return wrapResult(statistics.RawUpdateProjectGoal(ctx, account, projectID, goalID, title, description, status, priority, startDate, endDate, progress))
 }
 
-func Inner_blog_RawDeleteProjectGoal(arguments map[string]interface{}) string {
+func Inner_blog_RawDeleteProjectGoal(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -185,10 +188,10 @@ func Inner_blog_RawDeleteProjectGoal(arguments map[string]interface{}) string {
 	if err != nil {
 		return errorJSON(err.Error())
 	}
-	return wrapResult(statistics.RawDeleteProjectGoal(account, projectID, goalID))
+	return wrapResult(statistics.RawDeleteProjectGoal(ctx, account, projectID, goalID))
 }
 
-func Inner_blog_RawAddProjectOKR(arguments map[string]interface{}) string {
+func Inner_blog_RawAddProjectOKR(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -211,10 +214,10 @@ func Inner_blog_RawAddProjectOKR(arguments map[string]interface{}) string {
 	}
 	period, _ := getStringParam(arguments, "period")
 	progress := getOptionalIntParam(arguments, "progress", 0)
-	return wrapResult(statistics.RawAddProjectOKR(account, projectID, objective, status, period, progress))
+	return wrapResult(statistics.RawAddProjectOKR(ctx, account, projectID, objective, status, period, progress))
 }
 
-func Inner_blog_RawUpdateProjectOKR(arguments map[string]interface{}) string {
+func Inner_blog_RawUpdateProjectOKR(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -241,10 +244,10 @@ func Inner_blog_RawUpdateProjectOKR(arguments map[string]interface{}) string {
 	}
 	period, _ := getStringParam(arguments, "period")
 	progress := getOptionalIntParam(arguments, "progress", 0)
-	return wrapResult(statistics.RawUpdateProjectOKR(account, projectID, okrID, objective, status, period, progress))
+	return wrapResult(statistics.RawUpdateProjectOKR(ctx, account, projectID, okrID, objective, status, period, progress))
 }
 
-func Inner_blog_RawDeleteProjectOKR(arguments map[string]interface{}) string {
+func Inner_blog_RawDeleteProjectOKR(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -261,10 +264,10 @@ func Inner_blog_RawDeleteProjectOKR(arguments map[string]interface{}) string {
 	if err != nil {
 		return errorJSON(err.Error())
 	}
-	return wrapResult(statistics.RawDeleteProjectOKR(account, projectID, okrID))
+	return wrapResult(statistics.RawDeleteProjectOKR(ctx, account, projectID, okrID))
 }
 
-func Inner_blog_RawUpdateProjectKeyResult(arguments map[string]interface{}) string {
+func Inner_blog_RawUpdateProjectKeyResult(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -300,7 +303,7 @@ func Inner_blog_RawUpdateProjectKeyResult(arguments map[string]interface{}) stri
 	if status == "" {
 		status = "pending"
 	}
-	return wrapResult(statistics.RawUpdateProjectKeyResult(account, projectID, okrID, keyResultID, title, metricType, targetValue, currentValue, unit, status))
+	return wrapResult(statistics.RawUpdateProjectKeyResult(ctx, account, projectID, okrID, keyResultID, title, metricType, targetValue, currentValue, unit, status))
 }
 
 func Inner_blog_RawGetProjectSummary(arguments map[string]interface{}) string {
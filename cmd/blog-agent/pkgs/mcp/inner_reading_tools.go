@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"statistics"
 )
 
@@ -48,7 +49,7 @@ func Inner_blog_RawGetReadingStats(arguments map[string]interface{}) string {
 	return wrapResult(statistics.RawGetReadingStats(account))
 }
 
-func Inner_blog_RawUpdateReadingProgress(arguments map[string]interface{}) string {
+func Inner_blog_RawUpdateReadingProgress(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -66,7 +67,7 @@ func Inner_blog_RawUpdateReadingProgress(arguments map[string]interface{}) strin
 		return errorJSON(err.Error())
 	}
 	notes, _ := getStringParam(arguments, "notes")
-	return wrapResult(statistics.RawUpdateReadingProgress(account, bookID, currentPage, notes))
+	return wrapResult(statistics.RawUpdateReadingProgress(ctx, account, bookID, currentPage, notes))
 }
 
 func Inner_blog_RawGetBookNotes(arguments map[string]interface{}) string {
@@ -85,7 +86,7 @@ func Inner_blog_RawGetBookNotes(arguments map[string]interface{}) string {
 	return wrapResult(statistics.RawGetBookNotes(account, bookID))
 }
 
-func Inner_blog_RawAddBook(arguments map[string]interface{}) string {
+func Inner_blog_RawAddBook(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -108,5 +109,5 @@ func Inner_blog_RawAddBook(arguments map[string]interface{}) string {
 	totalPages := getOptionalIntParam(arguments, "totalPages", 0)
 	category, _ := getStringParam(arguments, "category")
 	tags, _ := getStringParam(arguments, "tags")
-	return wrapResult(statistics.RawAddBook(account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl, totalPages, category, tags))
+	return wrapResult(statistics.RawAddBook(ctx, account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl, totalPages, category, tags))
 }
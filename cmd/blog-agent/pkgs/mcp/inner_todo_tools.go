@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"statistics"
 )
 
@@ -44,7 +45,7 @@ func Inner_blog_RawGetTodosRange(arguments map[string]interface{}) string {
 	return wrapResult(statistics.RawGetTodosRange(account, startDate, endDate))
 }
 
-func Inner_blog_RawAddTodo(arguments map[string]interface{}) string {
+func Inner_blog_RawAddTodo(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -65,10 +66,10 @@ func Inner_blog_RawAddTodo(arguments map[string]interface{}) string {
 	minutes := getOptionalIntParam(arguments, "minutes", 0)
 	urgency := getOptionalIntParam(arguments, "urgency", 2)
 	importance := getOptionalIntParam(arguments, "importance", 2)
-	return wrapResult(statistics.RawAddTodo(account, date, content, hours, minutes, urgency, importance))
+	return wrapResult(statistics.RawAddTodo(ctx, account, date, content, hours, minutes, urgency, importance))
 }
 
-func Inner_blog_RawToggleTodo(arguments map[string]interface{}) string {
+func Inner_blog_RawToggleTodo(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -85,10 +86,10 @@ func Inner_blog_RawToggleTodo(arguments map[string]interface{}) string {
 	if err != nil {
 		return errorJSON(err.Error())
 	}
-	return wrapResult(statistics.RawToggleTodo(account, date, id))
+	return wrapResult(statistics.RawToggleTodo(ctx, account, date, id))
 }
 
-func Inner_blog_RawDeleteTodo(arguments map[string]interface{}) string {
+func Inner_blog_RawDeleteTodo(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -105,10 +106,10 @@ func Inner_blog_RawDeleteTodo(arguments map[string]interface{}) string {
 	if err != nil {
 		return errorJSON(err.Error())
 	}
-	return wrapResult(statistics.RawDeleteTodo(account, date, id))
+	return wrapResult(statistics.RawDeleteTodo(ctx, account, date, id))
 }
 
-func Inner_blog_RawUpdateTodo(arguments map[string]interface{}) string {
+func Inner_blog_RawUpdateTodo(ctx context.Context, arguments map[string]interface{}) string {
 	requestedAccount, err := getStringParam(arguments, "account")
 	if err != nil {
 		return errorJSON(err.Error())
@@ -133,5 +134,5 @@ func Inner_blog_RawUpdateTodo(arguments map[string]interface{}) string {
 	if err != nil {
 		return errorJSON(err.Error())
 	}
-	return wrapResult(statistics.RawUpdateTodo(account, date, id, hours, minutes))
+	return wrapResult(statistics.RawUpdateTodo(ctx, account, date, id, hours, minutes))
 }
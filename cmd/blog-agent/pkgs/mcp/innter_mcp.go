@@ -1,8 +1,11 @@
 package mcp
 
 import (
+	"blog"
+	"context"
 	"encoding/json"
 	"fmt"
+	"module"
 	"strings"
 )
 
@@ -10,6 +13,9 @@ import (
 var callBacks = make(map[string]func(arguments map[string]interface{}) string)
 var callBacksPrompt = make(map[string]string)
 
+// 会写入博客的工具注册为带 context 的回调，调用方通过 ctx 传递修订来源
+var ctxCallBacks = make(map[string]func(ctx context.Context, arguments map[string]interface{}) string)
+
 // 当前请求 ID（用于 delegation token 上下文）
 // 在 HTTP API 调用时设置为实际请求 ID，LLM 调用时为 "llm-request"
 var currentRequestID = "llm-request"
@@ -169,17 +175,46 @@ func RegisterCallBack(name string, callback func(arguments map[string]interface{
 	callBacks[name] = callback
 }
 
+// RegisterContextCallBack 注册带 context 的回调（写类工具）
+func RegisterContextCallBack(name string, callback func(ctx context.Context, arguments map[string]interface{}) string) {
+	ctxCallBacks[name] = callback
+}
+
 func CallInnerTools(name string, arguments map[string]interface{}) string {
 	return CallInnerToolsWithRequestID(name, arguments, currentRequestID)
 }
 
 func CallInnerToolsWithRequestID(name string, arguments map[string]interface{}, requestID string) string {
-	callback, ok := callBacks[name]
-	if !ok {
+	return callInnerTools(name, arguments, requestID, "")
+}
+
+// CallInnerToolsAs 以已认证用户 author 的身份调用内部工具，博客修订的修改者记为 author
+func CallInnerToolsAs(name string, arguments map[string]interface{}, author string) string {
+	return callInnerTools(name, arguments, currentRequestID, author)
+}
+
+// callInnerTools author 为空时取当前请求 delegation token 授权的账户
+func callInnerTools(name string, arguments map[string]interface{}, requestID, author string) string {
+	if author == "" {
+		if token := GetDelegationToken(requestID); token != nil {
+			author, _ = VerifyDelegationToken(token)
+		}
+	}
+	// 工具调用期间的博客写入记为 llm-tool 来源，便于在修订历史中定位和撤销
+	ctx := blog.WithRevisionSource(context.Background(), blog.RevisionSource{
+		Source: module.RevisionSource_llmTool,
+		Author: author,
+		Note:   fmt.Sprintf("%s request=%s", name, requestID),
+	})
+
+	var result string
+	if callback, ok := ctxCallBacks[name]; ok {
+		result = callback(ctx, arguments)
+	} else if callback, ok := callBacks[name]; ok {
+		result = callback(arguments)
+	} else {
 		return errorJSON("NOT find callback: " + name)
 	}
-
-	result := callback(arguments)
 	// 注入 prompt 到信封内（不再用 /n/n 拼接破坏 JSON）
 	prompt, hasPrompt := getInnerToolsPrompt(name)
 	if hasPrompt {
@@ -259,7 +294,7 @@ func RegisterInnerTools() {
 	RegisterCallBack("RawGetCurrentTaskByRageDate", Inner_blog_RawGetCurrentTaskByRageDate)
 
 	// 新增接口 - 创建博客
-	RegisterCallBack("RawCreateBlog", Inner_blog_RawCreateBlog)
+	RegisterContextCallBack("RawCreateBlog", Inner_blog_RawCreateBlog)
 	RegisterCallBackPrompt("RawCreateBlog", "完成创建后返回博客链接格式为[title](/get?blogname=title)")
 
 	// 新增模块工具 - Web 搜索与抓取
@@ -269,41 +304,41 @@ func RegisterInnerTools() {
 	// 新增模块工具 - TodoList
 	RegisterCallBack("RawGetTodosByDate", Inner_blog_RawGetTodosByDate)
 	RegisterCallBack("RawGetTodosRange", Inner_blog_RawGetTodosRange)
-	RegisterCallBack("RawAddTodo", Inner_blog_RawAddTodo)
-	RegisterCallBack("RawToggleTodo", Inner_blog_RawToggleTodo)
-	RegisterCallBack("RawDeleteTodo", Inner_blog_RawDeleteTodo)
-	RegisterCallBack("RawUpdateTodo", Inner_blog_RawUpdateTodo)
+	RegisterContextCallBack("RawAddTodo", Inner_blog_RawAddTodo)
+	RegisterContextCallBack("RawToggleTodo", Inner_blog_RawToggleTodo)
+	RegisterContextCallBack("RawDeleteTodo", Inner_blog_RawDeleteTodo)
+	RegisterContextCallBack("RawUpdateTodo", Inner_blog_RawUpdateTodo)
 
 	// 新增模块工具 - Exercise
 	RegisterCallBack("RawGetExerciseByDate", Inner_blog_RawGetExerciseByDate)
 	RegisterCallBack("RawGetExerciseRange", Inner_blog_RawGetExerciseRange)
-	RegisterCallBack("RawAddExercise", Inner_blog_RawAddExercise)
+	RegisterContextCallBack("RawAddExercise", Inner_blog_RawAddExercise)
 	RegisterCallBack("RawGetExerciseStats", Inner_blog_RawGetExerciseStats)
-	RegisterCallBack("RawToggleExercise", Inner_blog_RawToggleExercise)
-	RegisterCallBack("RawDeleteExercise", Inner_blog_RawDeleteExercise)
-	RegisterCallBack("RawUpdateExercise", Inner_blog_RawUpdateExercise)
+	RegisterContextCallBack("RawToggleExercise", Inner_blog_RawToggleExercise)
+	RegisterContextCallBack("RawDeleteExercise", Inner_blog_RawDeleteExercise)
+	RegisterContextCallBack("RawUpdateExercise", Inner_blog_RawUpdateExercise)
 
 	// 新增模块工具 - Reading
 	RegisterCallBack("RawGetAllBooks", Inner_blog_RawGetAllBooks)
 	RegisterCallBack("RawGetBooksByStatus", Inner_blog_RawGetBooksByStatus)
 	RegisterCallBack("RawGetReadingStats", Inner_blog_RawGetReadingStats)
-	RegisterCallBack("RawUpdateReadingProgress", Inner_blog_RawUpdateReadingProgress)
+	RegisterContextCallBack("RawUpdateReadingProgress", Inner_blog_RawUpdateReadingProgress)
 	RegisterCallBack("RawGetBookNotes", Inner_blog_RawGetBookNotes)
-	RegisterCallBack("RawAddBook", Inner_blog_RawAddBook)
+	RegisterContextCallBack("RawAddBook", Inner_blog_RawAddBook)
 
 	// 新增模块工具 - Project Management
-	RegisterCallBack("RawCreateProject", Inner_blog_RawCreateProject)
+	RegisterContextCallBack("RawCreateProject", Inner_blog_RawCreateProject)
 	RegisterCallBack("RawGetProject", Inner_blog_RawGetProject)
 	RegisterCallBack("RawListProjects", Inner_blog_RawListProjects)
-	RegisterCallBack("RawUpdateProject", Inner_blog_RawUpdateProject)
-	RegisterCallBack("RawDeleteProject", Inner_blog_RawDeleteProject)
-	RegisterCallBack("RawAddProjectGoal", Inner_blog_RawAddProjectGoal)
-	RegisterCallBack("RawUpdateProjectGoal", Inner_blog_RawUpdateProjectGoal)
-	RegisterCallBack("RawDeleteProjectGoal", Inner_blog_RawDeleteProjectGoal)
-	RegisterCallBack("RawAddProjectOKR", Inner_blog_RawAddProjectOKR)
-	RegisterCallBack("RawUpdateProjectOKR", Inner_blog_RawUpdateProjectOKR)
-	RegisterCallBack("RawDeleteProjectOKR", Inner_blog_RawDeleteProjectOKR)
-	RegisterCallBack("RawUpdateProjectKeyResult", Inner_blog_RawUpdateProjectKeyResult)
+	RegisterContextCallBack("RawUpdateProject", Inner_blog_RawUpdateProject)
+	RegisterContextCallBack("RawDeleteProject", Inner_blog_RawDeleteProject)
+	RegisterContextCallBack("RawAddProjectGoal", Inner_blog_RawAddProjectGoal)
+	RegisterContextCallBack("RawUpdateProjectGoal", Inner_blog_RawUpdateProjectGoal)
+	RegisterContextCallBack("RawDeleteProjectGoal", Inner_blog_RawDeleteProjectGoal)
+	RegisterContextCallBack("RawAddProjectOKR", Inner_blog_RawAddProjectOKR)
+	RegisterContextCallBack("RawUpdateProjectOKR", Inner_blog_RawUpdateProjectOKR)
+	RegisterContextCallBack("RawDeleteProjectOKR", Inner_blog_RawDeleteProjectOKR)
+	RegisterContextCallBack("RawUpdateProjectKeyResult", Inner_blog_RawUpdateProjectKeyResult)
 	RegisterCallBack("RawGetProjectSummary", Inner_blog_RawGetProjectSummary)
 
 }
@@ -839,12 +874,12 @@ func GetInnerMCPTools(toolNameMapping map[string]string) []LLMTool {
 			Type: "function",
 			Function: LLMFunction{
 				Name:        "Inner_blog.RawSearchBlogContent",
-				Description: "在博客标题和内容中搜索关键词,返回匹配的博客列表。返回str(格式化结果)",
+				Description: "全文搜索博客标题和内容,按相关度返回匹配博客及命中摘要(命中部分用**标出)。关键词支持\"短语\"、title:xx、tag:xx、date:2024-01..2024-06、modified:起..止。返回str(格式化结果)",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"account": map[string]string{"type": "string", "description": "账号"},
-						"keyword": map[string]string{"type": "string", "description": "要搜索的关键词"},
+						"keyword": map[string]string{"type": "string", "description": "搜索关键词，多个词空格分隔（取交集）"},
 					},
 					"required": []string{"account", "keyword"},
 				},
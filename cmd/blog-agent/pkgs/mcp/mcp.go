@@ -255,8 +255,8 @@ func CallMCPTool(toolName string, arguments map[string]interface{}) MCPToolRespo
 }
 
 // CallToolForAPI 供 HTTP API 调用的工具执行入口
-// requestID 用于 delegation token 上下文
-func CallToolForAPI(toolCall MCPToolCall, requestID string) MCPToolResponse {
+// requestID 用于 delegation token 上下文，author 为已登录账户（delegation token 调用时为空）
+func CallToolForAPI(toolCall MCPToolCall, requestID, author string) MCPToolResponse {
 	log.DebugF(log.ModuleMCP, "=== Calling MCP Tool: %s ===", toolCall.Name)
 	log.DebugF(log.ModuleMCP, "Tool arguments: %v", toolCall.Arguments)
 
@@ -273,7 +273,7 @@ func CallToolForAPI(toolCall MCPToolCall, requestID string) MCPToolResponse {
 		return MCPToolResponse{Success: false, Error: fmt.Sprintf("tool not available: %s", callName)}
 	}
 
-	data := CallInnerToolsWithRequestID(callName, toolCall.Arguments, requestID)
+	data := callInnerTools(callName, toolCall.Arguments, requestID, author)
 	// 解析统一信封
 	var envelope struct {
 		OK    bool            `json:"ok"`
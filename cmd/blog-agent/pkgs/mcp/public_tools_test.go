@@ -20,7 +20,7 @@ func TestCallToolForAPIRejectsHiddenTools(t *testing.T) {
 		Arguments: map[string]interface{}{
 			"query": "golang",
 		},
-	}, "test-request")
+	}, "test-request", "")
 	if result.Success {
 		t.Fatalf("expected hidden tool to be rejected")
 	}
@@ -20,6 +20,40 @@ type UploadedBlogData struct {
 	Tags     string
 	Encrypt  int
 	Account  string
+	Source   string // 修改来源（见 RevisionSource_*），为空时按调用上下文推断
+	Author   string // 修改者（账户、工具调用的已认证用户等）
+}
+
+// 博客修订来源
+const (
+	RevisionSource_web     = "web"
+	RevisionSource_llmTool = "llm-tool"
+	RevisionSource_restore = "restore"
+	RevisionSource_system  = "system"
+)
+
+// 博客修订动作
+const (
+	RevisionAction_create  = "create"
+	RevisionAction_modify  = "modify"
+	RevisionAction_delete  = "delete"
+	RevisionAction_restore = "restore"
+)
+
+// 博客修订（只追加，不可修改）
+type BlogRevision struct {
+	Seq      int    `json:"seq"`
+	Title    string `json:"title"`
+	Content  string `json:"content,omitempty"`
+	Tags     string `json:"tags"`
+	AuthType int    `json:"auth_type"`
+	Encrypt  int    `json:"encrypt"`
+	Time     string `json:"time"`
+	Action   string `json:"action"`
+	Source   string `json:"source"`
+	Author   string `json:"author,omitempty"`
+	Note     string `json:"note,omitempty"` // 附加说明，如工具名、恢复自哪个修订
+	Size     int    `json:"size"`
 }
 
 // blog数据
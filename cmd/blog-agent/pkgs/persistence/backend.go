@@ -0,0 +1,87 @@
+package persistence
+
+import (
+	"config"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ========== 存储后端 ==========
+// 所有数据均以 "key → 字段表" 的哈希形式保存，key 沿用 Redis 时代的格式：
+//   <account>:blog@<title>、comments@<title>、comment_user@<id> ...
+// 因此不同后端之间可以逐 key 原样迁移。
+//
+// sys_conf 配置：
+//   storage_backend=redis|file|bolt   （默认 redis）
+//   storage_path=                     （file: 目录，默认 <exe>/storage_kv；bolt: 文件，默认 <exe>/storage.db）
+
+const (
+	BackendRedis = "redis"
+	BackendFile  = "file"
+	BackendBolt  = "bolt"
+)
+
+// Backend 哈希 KV 存储后端
+type Backend interface {
+	Name() string
+	HMSet(key string, values map[string]string) error // 合并写入字段
+	HGetAll(key string) (map[string]string, error)    // key 不存在时返回空 map
+	Del(key string) error
+	Keys(prefix string) ([]string, error) // 按前缀列出 key（prefix 为空表示全部）
+	Close() error
+}
+
+// NewBackend 按名称创建存储后端；path 为空时使用默认位置
+func NewBackend(name, path string) (Backend, error) {
+	switch normalizeBackend(name) {
+	case BackendRedis:
+		admin := config.GetAdminAccount()
+		ip := config.GetConfigWithAccount(admin, "redis_ip")
+		port, _ := strconv.Atoi(config.GetConfigWithAccount(admin, "redis_port"))
+		pwd := config.GetConfigWithAccount(admin, "redis_pwd")
+		return NewRedisBackend(ip, port, pwd)
+	case BackendFile:
+		if path == "" {
+			path = filepath.Join(config.GetExePath(), "storage_kv")
+		}
+		return NewFileBackend(path)
+	case BackendBolt:
+		if path == "" {
+			path = filepath.Join(config.GetExePath(), "storage.db")
+		}
+		return NewBoltBackend(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (redis|file|bolt)", name)
+	}
+}
+
+// normalizeBackend 规范化后端名称，空值视为 redis
+func normalizeBackend(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return BackendRedis
+	}
+	return name
+}
+
+// stringify 将字段值转为字符串，编码方式与 go-redis 写入 Redis 时一致（bool → "1"/"0"）
+func stringify(values map[string]interface{}) map[string]string {
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		switch val := v.(type) {
+		case string:
+			out[k] = val
+		case bool:
+			if val {
+				out[k] = "1"
+			} else {
+				out[k] = "0"
+			}
+		default:
+			out[k] = fmt.Sprint(val)
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ========== BoltDB 后端 ==========
+// 嵌入式单文件数据库，所有 key 保存在同一个 bucket，值为字段表的 JSON 编码。
+
+var boltBucket = []byte("kv")
+
+// BoltBackend 嵌入式 BoltDB 存储
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+// NewBoltBackend 打开（或创建）BoltDB 文件
+func NewBoltBackend(path string) (*BoltBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltBackend{db: db}, nil
+}
+
+func (b *BoltBackend) Name() string { return BackendBolt }
+
+func (b *BoltBackend) HMSet(key string, values map[string]string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		current := make(map[string]string)
+		if data := bucket.Get([]byte(key)); data != nil {
+			if err := json.Unmarshal(data, &current); err != nil {
+				return fmt.Errorf("decode %s: %v", key, err)
+			}
+		}
+		for k, v := range values {
+			current[k] = v
+		}
+		data, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), data)
+	})
+}
+
+func (b *BoltBackend) HGetAll(key string) (map[string]string, error) {
+	values := make(map[string]string)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &values)
+	})
+	return values, err
+}
+
+func (b *BoltBackend) Del(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+func (b *BoltBackend) Keys(prefix string) ([]string, error) {
+	var keys []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		p := []byte(prefix)
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
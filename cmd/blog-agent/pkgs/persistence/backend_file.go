@@ -0,0 +1,129 @@
+package persistence
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ========== 文件系统后端 ==========
+// 每个 key 对应目录下一个 JSON 文件，文件名为 sha256(key) + ".json"，原始 key 保存在文件内。
+// 定长文件名不受中文标题长度限制，也不会在大小写不敏感的文件系统上冲突。单用户部署无需 Redis。
+
+// fileRecord 单个 key 的文件内容
+type fileRecord struct {
+	Key    string            `json:"key"`
+	Values map[string]string `json:"values"`
+}
+
+// FileBackend 纯文件系统存储
+type FileBackend struct {
+	dir string
+	mu  sync.Mutex // 串行化写入，保证 HMSet 的读-改-写完整
+}
+
+// NewFileBackend 创建文件系统后端
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create storage dir %s: %v", dir, err)
+	}
+	return &FileBackend{dir: dir}, nil
+}
+
+func (f *FileBackend) Name() string { return BackendFile }
+
+func (f *FileBackend) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (f *FileBackend) HMSet(key string, values map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, err := f.HGetAll(key)
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		current[k] = v
+	}
+	data, err := json.Marshal(fileRecord{Key: key, Values: current})
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再 rename，避免进程中断留下半个文件
+	tmp := f.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path(key))
+}
+
+func (f *FileBackend) HGetAll(key string) (map[string]string, error) {
+	record, err := f.read(f.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]string), nil
+		}
+		return nil, err
+	}
+	if record.Key != key {
+		return nil, fmt.Errorf("decode %s: file holds key %q", key, record.Key)
+	}
+	return record.Values, nil
+}
+
+// read 读取并解码单个数据文件
+func (f *FileBackend) read(path string) (*fileRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var record fileRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("decode %s: %v", filepath.Base(path), err)
+	}
+	if record.Values == nil {
+		record.Values = make(map[string]string)
+	}
+	return &record, nil
+}
+
+func (f *FileBackend) Del(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := os.Remove(f.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (f *FileBackend) Keys(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		record, err := f.read(filepath.Join(f.dir, name))
+		if err != nil || !strings.HasPrefix(record.Key, prefix) {
+			continue
+		}
+		keys = append(keys, record.Key)
+	}
+	return keys, nil
+}
+
+func (f *FileBackend) Close() error { return nil }
@@ -0,0 +1,68 @@
+package persistence
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis"
+)
+
+// ========== Redis 后端 ==========
+
+// RedisBackend 使用 Redis 哈希保存数据
+type RedisBackend struct {
+	client *redis.Client
+}
+
+// NewRedisBackend 连接 Redis
+func NewRedisBackend(ip string, port int, password string) (*RedisBackend, error) {
+	c := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", ip, port),
+		Password: password,
+		DB:       0,
+	})
+	if _, err := c.Ping().Result(); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("connect redis %s:%d: %v", ip, port, err)
+	}
+	return &RedisBackend{client: c}, nil
+}
+
+func (r *RedisBackend) Name() string { return BackendRedis }
+
+func (r *RedisBackend) HMSet(key string, values map[string]string) error {
+	fields := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		fields[k] = v
+	}
+	return r.client.HMSet(key, fields).Err()
+}
+
+func (r *RedisBackend) HGetAll(key string) (map[string]string, error) {
+	return r.client.HGetAll(key).Result()
+}
+
+func (r *RedisBackend) Del(key string) error {
+	return r.client.Del(key).Err()
+}
+
+func (r *RedisBackend) Keys(prefix string) ([]string, error) {
+	return r.client.Keys(escapeGlob(prefix) + "*").Result()
+}
+
+func (r *RedisBackend) Close() error {
+	return r.client.Close()
+}
+
+// escapeGlob 转义 Redis KEYS 模式中的通配字符（标题可能包含 * ? [ ]）
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
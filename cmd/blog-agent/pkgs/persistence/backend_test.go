@@ -0,0 +1,216 @@
+package persistence
+
+import (
+	"fmt"
+	"module"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTestBackends(t *testing.T) []Backend {
+	dir := t.TempDir()
+	fb, err := NewFileBackend(filepath.Join(dir, "kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb, err := NewBoltBackend(filepath.Join(dir, "storage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bb.Close() })
+	return []Backend{fb, bb}
+}
+
+func TestBackendHashSemantics(t *testing.T) {
+	for _, b := range newTestBackends(t) {
+		t.Run(b.Name(), func(t *testing.T) {
+			key := "alice:blog@notes/2024 [draft]*"
+			if err := b.HMSet(key, stringify(map[string]interface{}{"title": "t", "encrypt": 1, "flag": true})); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.HMSet(key, map[string]string{"content": "hello"}); err != nil {
+				t.Fatal(err)
+			}
+			b.HMSet("alice:blog@other", map[string]string{"title": "other"})
+			b.HMSet("bob:blog@x", map[string]string{"title": "x"})
+
+			m, err := b.HGetAll(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m["title"] != "t" || m["content"] != "hello" || m["encrypt"] != "1" || m["flag"] != "1" {
+				t.Fatalf("unexpected fields: %v", m)
+			}
+			if keys, _ := b.Keys("alice:blog@"); len(keys) != 2 {
+				t.Fatalf("expected 2 alice keys, got %v", keys)
+			}
+			if err := b.Del(key); err != nil {
+				t.Fatal(err)
+			}
+			if m, _ := b.HGetAll(key); len(m) != 0 {
+				t.Fatalf("expected empty after delete, got %v", m)
+			}
+			if err := b.Del("missing"); err != nil {
+				t.Fatalf("deleting missing key should succeed: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileBackendLongAndCaseOnlyKeys(t *testing.T) {
+	fb, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := "alice:blog@" + strings.Repeat("中文标题", 40) // 转义后远超 255 字节
+	for _, key := range []string{long, "alice:blog@Readme", "alice:blog@README"} {
+		if err := fb.HMSet(key, map[string]string{"title": key}); err != nil {
+			t.Fatalf("HMSet %q: %v", key, err)
+		}
+	}
+	for _, key := range []string{long, "alice:blog@Readme", "alice:blog@README"} {
+		if m, _ := fb.HGetAll(key); m["title"] != key {
+			t.Fatalf("key %q read back %v", key, m)
+		}
+	}
+	if keys, _ := fb.Keys("alice:blog@"); len(keys) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(keys))
+	}
+
+	// 并发合并写入不同字段，不能互相覆盖
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fb.HMSet("alice:comments@x", map[string]string{fmt.Sprint(i): "v"})
+		}(i)
+	}
+	wg.Wait()
+	if m, _ := fb.HGetAll("alice:comments@x"); len(m) != 20 {
+		t.Fatalf("concurrent HMSet lost fields: got %d", len(m))
+	}
+}
+
+func TestMigrateAndVerify(t *testing.T) {
+	backends := newTestBackends(t)
+	from, to := backends[0], backends[1]
+
+	blogsRoot := t.TempDir()
+	blogsPath := func(account string) string { return filepath.Join(blogsRoot, account) }
+	os.MkdirAll(blogsPath("alice"), 0755)
+	os.WriteFile(filepath.Join(blogsPath("alice"), "hello.md"), []byte("hello world"), 0644)
+
+	from.HMSet("alice:blog@hello", map[string]string{"title": "hello", "content": "hello world"})
+	from.HMSet("alice:blog@orphan", map[string]string{"title": "orphan", "content": "no file"})
+	from.HMSet("comments@hello", map[string]string{"0": "Idx=0"})
+	to.HMSet("comments@hello", map[string]string{"stale": "x"})
+
+	n, err := Migrate(from, to)
+	if err != nil || n != 3 {
+		t.Fatalf("migrate n=%d err=%v", n, err)
+	}
+	report, err := VerifyMigration(from, to, blogsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Blogs != 2 || len(report.Mismatches) != 0 || len(report.FileMismatch) != 0 {
+		t.Fatalf("unexpected report: %s %+v", report, report)
+	}
+	if len(report.MissingFiles) != 1 || report.MissingFiles[0] != "alice:blog@orphan" {
+		t.Fatalf("expected orphan to be reported missing, got %v", report.MissingFiles)
+	}
+	if report.OK() {
+		t.Fatal("report with missing file should not be OK")
+	}
+
+	to.HMSet("alice:blog@hello", map[string]string{"content": "edited"})
+	report, _ = VerifyMigration(from, to, blogsPath)
+	if len(report.Mismatches) != 1 || len(report.FileMismatch) != 1 {
+		t.Fatalf("expected divergence to be reported: %+v", report)
+	}
+}
+
+func TestAppendBlogRevisionSkipsUnchanged(t *testing.T) {
+	fb, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevStore, prevKeep := store, revisionKeep
+	store, revisionKeep = fb, func() int { return 100 }
+	defer func() { store, revisionKeep = prevStore, prevKeep }()
+
+	rev := func(content, action string) *module.BlogRevision {
+		return &module.BlogRevision{Title: "todo", Content: content, Action: action, Source: module.RevisionSource_web}
+	}
+	if seq := AppendBlogRevision("alice", rev("v1", module.RevisionAction_create)); seq != 1 {
+		t.Fatalf("expected seq 1, got %d", seq)
+	}
+	if seq := AppendBlogRevision("alice", rev("v1", module.RevisionAction_modify)); seq != 0 {
+		t.Fatalf("unchanged content should be skipped, got %d", seq)
+	}
+	if seq := AppendBlogRevision("alice", rev("v2", module.RevisionAction_modify)); seq != 2 {
+		t.Fatalf("expected seq 2, got %d", seq)
+	}
+	authOnly := rev("v2", module.RevisionAction_modify)
+	authOnly.AuthType = module.EAuthType_public
+	if seq := AppendBlogRevision("alice", authOnly); seq != 3 {
+		t.Fatalf("auth change should be recorded, got %d", seq)
+	}
+	if seq := AppendBlogRevision("alice", rev("v2", module.RevisionAction_delete)); seq != 4 {
+		t.Fatalf("delete should always be recorded, got %d", seq)
+	}
+
+	revs := GetBlogRevisions("alice", "todo")
+	if len(revs) != 4 || revs[0].Content != "v1" || revs[2].AuthType != module.EAuthType_public || revs[3].Action != module.RevisionAction_delete {
+		t.Fatalf("unexpected revisions: %+v", revs)
+	}
+	if r := GetBlogRevision("alice", "todo", 2); r == nil || r.Content != "v2" || r.Size != 2 {
+		t.Fatalf("unexpected revision 2: %+v", r)
+	}
+	if blogs, _ := fb.Keys("alice:blog@"); len(blogs) != 0 {
+		t.Fatalf("revision keys must not look like blog keys: %v", blogs)
+	}
+}
+
+func TestAppendBlogRevisionKeepsLatestN(t *testing.T) {
+	fb, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevStore, prevKeep := store, revisionKeep
+	store, revisionKeep = fb, func() int { return 3 }
+	defer func() { store, revisionKeep = prevStore, prevKeep }()
+
+	// 旧版单 hash 存储的修订在首次访问时拆分
+	fb.HMSet(legacyRevisionKey("alice", "todo"), map[string]string{
+		"1": `{"Seq":1,"Title":"todo","Content":"v1"}`,
+		"2": `{"Seq":2,"Title":"todo","Content":"v2"}`,
+	})
+	fb.HMSet(revisionHeadKey("alice", "todo"), map[string]string{"seq": "2", "digest": "x"})
+	if r := GetBlogRevision("alice", "todo", 1); r == nil || r.Content != "v1" {
+		t.Fatalf("legacy revision should be readable: %+v", r)
+	}
+
+	for i := 3; i <= 5; i++ {
+		content := fmt.Sprintf("v%d", i)
+		rev := &module.BlogRevision{Title: "todo", Content: content, Action: module.RevisionAction_modify}
+		if seq := AppendBlogRevision("alice", rev); seq != i {
+			t.Fatalf("expected seq %d, got %d", i, seq)
+		}
+	}
+
+	revs := GetBlogRevisions("alice", "todo")
+	if len(revs) != 3 || revs[0].Seq != 3 || revs[2].Content != "v5" {
+		t.Fatalf("expected revisions 3..5, got %+v", revs)
+	}
+	if r := GetBlogRevision("alice", "todo", 2); r != nil {
+		t.Fatalf("revision 2 should have been pruned: %+v", r)
+	}
+	if keys, _ := fb.Keys("alice:blog_rev"); len(keys) != 4 {
+		t.Fatalf("expected 3 revisions plus the head after pruning, got %v", keys)
+	}
+}
@@ -1,3 +1,5 @@
 module persistence
 
 go 1.20
+
+require go.etcd.io/bbolt v1.3.11
@@ -0,0 +1,160 @@
+package persistence
+
+import (
+	"config"
+	"fmt"
+	"ioutils"
+	log "mylog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ========== 存储迁移 ==========
+// 在不同后端之间逐 key 复制全部数据（所有账户的博客、评论、用户、会话、用户名预留），
+// 迁移完成后对每篇博客做一致性校验：
+//   1. 源与目标后端的字段逐一相等
+//   2. 博客内容与 blogs_txt/<account>/<title>.md 一致
+
+// MigrationReport 迁移结果
+type MigrationReport struct {
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	Keys         int      `json:"keys"`          // 复制的 key 数
+	Blogs        int      `json:"blogs"`         // 校验的博客数
+	Mismatches   []string `json:"mismatches"`    // 源与目标不一致的 key
+	FileMismatch []string `json:"file_mismatch"` // 与 markdown 文件内容不一致的博客
+	MissingFiles []string `json:"missing_files"` // 没有对应 markdown 文件的博客
+}
+
+// OK 源与目标、markdown 文件全部一致
+func (r *MigrationReport) OK() bool {
+	return len(r.Mismatches) == 0 && len(r.FileMismatch) == 0 && len(r.MissingFiles) == 0
+}
+
+func (r *MigrationReport) String() string {
+	return fmt.Sprintf("migrate %s -> %s: keys=%d blogs=%d mismatches=%d file_mismatch=%d missing_files=%d",
+		r.From, r.To, r.Keys, r.Blogs, len(r.Mismatches), len(r.FileMismatch), len(r.MissingFiles))
+}
+
+// Migrate 将 from 中的全部 key 原样复制到 to，返回复制的 key 数
+func Migrate(from, to Backend) (int, error) {
+	keys, err := from.Keys("")
+	if err != nil {
+		return 0, fmt.Errorf("list keys from %s: %v", from.Name(), err)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		values, err := from.HGetAll(key)
+		if err != nil {
+			return i, fmt.Errorf("read %s: %v", key, err)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		// 先删除再写入，保证目标中不残留旧字段
+		if err := to.Del(key); err != nil {
+			return i, fmt.Errorf("reset %s: %v", key, err)
+		}
+		if err := to.HMSet(key, values); err != nil {
+			return i, fmt.Errorf("write %s: %v", key, err)
+		}
+	}
+	return len(keys), nil
+}
+
+// VerifyMigration 校验迁移结果；blogsPath 返回账户的 markdown 目录
+func VerifyMigration(from, to Backend, blogsPath func(account string) string) (*MigrationReport, error) {
+	report := &MigrationReport{From: from.Name(), To: to.Name()}
+	keys, err := from.Keys("")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	report.Keys = len(keys)
+
+	for _, key := range keys {
+		src, err := from.HGetAll(key)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := to.HGetAll(key)
+		if err != nil {
+			return nil, err
+		}
+		if !equalFields(src, dst) {
+			report.Mismatches = append(report.Mismatches, key)
+		}
+
+		account, title, ok := parseBlogKey(key)
+		if !ok {
+			continue
+		}
+		report.Blogs++
+		full := fmt.Sprintf("%s.md", filepath.Join(blogsPath(account), title))
+		if _, err := os.Stat(full); err != nil {
+			report.MissingFiles = append(report.MissingFiles, key)
+			continue
+		}
+		content, _ := ioutils.GetFileDatas(full)
+		if content != dst["content"] {
+			report.FileMismatch = append(report.FileMismatch, key)
+		}
+	}
+	return report, nil
+}
+
+// MigrateStorage 从当前配置的后端迁移到 toName/toPath，并执行一致性校验
+func MigrateStorage(toName, toPath string) (*MigrationReport, error) {
+	admin := config.GetAdminAccount()
+	fromName := config.GetConfigWithAccount(admin, "storage_backend")
+	fromPath := config.GetConfigWithAccount(admin, "storage_path")
+
+	if normalizeBackend(fromName) == normalizeBackend(toName) && fromPath == toPath {
+		return nil, fmt.Errorf("source and target backend are the same (%s)", normalizeBackend(toName))
+	}
+
+	from, err := NewBackend(fromName, fromPath)
+	if err != nil {
+		return nil, fmt.Errorf("open source backend: %v", err)
+	}
+	defer from.Close()
+	to, err := NewBackend(toName, toPath)
+	if err != nil {
+		return nil, fmt.Errorf("open target backend: %v", err)
+	}
+	defer to.Close()
+
+	n, err := Migrate(from, to)
+	if err != nil {
+		return nil, err
+	}
+	log.MessageF(log.ModulePersistence, "migrated %d keys %s -> %s", n, from.Name(), to.Name())
+
+	return VerifyMigration(from, to, config.GetBlogsPath)
+}
+
+// parseBlogKey 解析博客 key：<account>:blog@<title>；旧格式 blog@<title> 归属管理员账户
+func parseBlogKey(key string) (account, title string, ok bool) {
+	if strings.HasPrefix(key, "blog@") {
+		return config.GetAdminAccount(), strings.TrimPrefix(key, "blog@"), true
+	}
+	idx := strings.Index(key, ":blog@")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return key[:idx], key[idx+len(":blog@"):], true
+}
+
+func equalFields(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -12,15 +12,14 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/go-redis/redis"
 )
 
 // ========== Simple Persistence 模块 ==========
 // 无 Actor、无 Channel，使用 sync.Mutex
+// 底层存储通过 sys_conf 的 storage_backend 选择（redis|file|bolt），见 backend.go
 
 var (
-	client      *redis.Client
+	store       Backend
 	persistence sync.Mutex
 )
 
@@ -33,31 +32,37 @@ func Init() {
 	persistence.Lock()
 	defer persistence.Unlock()
 
-	ip := config.GetConfigWithAccount(config.GetAdminAccount(), "redis_ip")
-	port, _ := strconv.Atoi(config.GetConfigWithAccount(config.GetAdminAccount(), "redis_port"))
-	pwd := config.GetConfigWithAccount(config.GetAdminAccount(), "redis_pwd")
-	connect(ip, port, pwd)
+	admin := config.GetAdminAccount()
+	name := config.GetConfigWithAccount(admin, "storage_backend")
+	path := config.GetConfigWithAccount(admin, "storage_path")
+	b, err := NewBackend(name, path)
+	if err != nil {
+		log.ErrorF(log.ModulePersistence, "init storage backend failed backend=%s err=%v", name, err)
+		return
+	}
+	store = b
+	log.DebugF(log.ModulePersistence, "storage backend ready backend=%s", b.Name())
 }
 
-func strTime() string {
-	return time.Now().Format("2006-01-02 15:04:05")
+// Close 关闭存储后端
+func Close() {
+	persistence.Lock()
+	defer persistence.Unlock()
+	if store != nil {
+		store.Close()
+		store = nil
+	}
 }
 
-func connect(ip string, port int, password string) int {
-	c := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", ip, port),
-		Password: password,
-		DB:       0,
-	})
+// CurrentBackend 返回当前使用的存储后端（未初始化时为 nil）
+func CurrentBackend() Backend {
+	persistence.Lock()
+	defer persistence.Unlock()
+	return store
+}
 
-	pong, err := c.Ping().Result()
-	if err == nil {
-		client = c
-		log.DebugF(log.ModulePersistence, "connect redis success ip=%s port=%d", ip, port)
-		return 1
-	}
-	log.DebugF(log.ModulePersistence, pong, err)
-	return 0
+func strTime() string {
+	return time.Now().Format("2006-01-02 15:04:05")
 }
 
 // ========== Blog 操作 ==========
@@ -83,14 +88,14 @@ func saveBlogInternal(account string, blog *module.Blog) {
 	values["encrypt"] = blog.Encrypt
 	values["account"] = account
 
-	if err := client.HMSet(key, values).Err(); err != nil {
+	if err := store.HMSet(key, stringify(values)); err != nil {
 		log.ErrorF(log.ModulePersistence, "saveblog error key=%s err=%s", key, err.Error())
 	}
-	log.DebugF(log.ModulePersistence, "redis saveblog success key=%s", key)
+	log.DebugF(log.ModulePersistence, "%s saveblog success key=%s", store.Name(), key)
 
 	// 删除旧格式key
 	old_key := fmt.Sprintf("blog@%s", blog.Title)
-	client.Del(old_key)
+	store.Del(old_key)
 
 	saveToFile(account, blog)
 }
@@ -107,21 +112,21 @@ func GetBlogsByAccount(account string) map[string]*module.Blog {
 	persistence.Lock()
 	defer persistence.Unlock()
 
-	pattern := fmt.Sprintf("%s:blog@*", account)
-	keys, err := client.Keys(pattern).Result()
+	prefix := fmt.Sprintf("%s:blog@", account)
+	keys, err := store.Keys(prefix)
 	if err != nil {
-		log.ErrorF(log.ModulePersistence, "getblogsbyaccount error pattern=%s err=%s", pattern, err.Error())
+		log.ErrorF(log.ModulePersistence, "getblogsbyaccount error prefix=%s err=%s", prefix, err.Error())
 		return nil
 	}
 
 	if account == config.GetAdminAccount() {
-		legacy, _ := client.Keys("blog@*").Result()
+		legacy, _ := store.Keys("blog@")
 		keys = append(keys, legacy...)
 	}
 
 	blogs := make(map[string]*module.Blog)
 	for _, key := range keys {
-		m, err := client.HGetAll(key).Result()
+		m, err := store.HGetAll(key)
 		if err != nil {
 			continue
 		}
@@ -136,7 +141,7 @@ func GetBlogWithAccount(account, name string) *module.Blog {
 	defer persistence.Unlock()
 
 	key := fmt.Sprintf("%s:blog@%s", account, name)
-	m, err := client.HGetAll(key).Result()
+	m, err := store.HGetAll(key)
 	if err != nil || len(m) == 0 {
 		return nil
 	}
@@ -148,7 +153,7 @@ func DeleteBlogWithAccount(account, title string) int {
 	defer persistence.Unlock()
 
 	key := fmt.Sprintf("%s:blog@%s", account, title)
-	client.Del(key)
+	store.Del(key)
 	deleteFile(account, title)
 	return 0
 }
@@ -226,24 +231,24 @@ func SaveBlogComments(account string, bc *module.BlogComments) {
 	s := "\x01"
 	for _, c := range bc.Comments {
 		value := fmt.Sprintf("Idx=%d%sowner=%s%sct=%s%smt=%s%smsg=%s%smail=%s%sPwd=%s",
-			c.Idx, s, c.Owner, s, c.CreateTime, s, c.ModifyTime, s, c.Msg, s, c.Mail, s, s, c.Pwd)
+			c.Idx, s, c.Owner, s, c.CreateTime, s, c.ModifyTime, s, c.Msg, s, c.Mail, s, c.Pwd)
 		values[fmt.Sprintf("%d", c.Idx)] = value
 	}
-	client.HMSet(key, values)
+	store.HMSet(key, stringify(values))
 }
 
 func GetAllBlogComments(account string) map[string]*module.BlogComments {
 	persistence.Lock()
 	defer persistence.Unlock()
 
-	keys, err := client.Keys("comments@*").Result()
+	keys, err := store.Keys("comments@")
 	if err != nil {
 		return nil
 	}
 
 	bcs := make(map[string]*module.BlogComments)
 	for _, key := range keys {
-		m, err := client.HGetAll(key).Result()
+		m, err := store.HGetAll(key)
 		if err != nil {
 			continue
 		}
@@ -314,17 +319,17 @@ func SaveCommentUser(account string, user *module.CommentUser) {
 		"comment_count": user.CommentCount, "reputation": user.Reputation,
 		"status": user.Status, "is_verified": user.IsVerified,
 	}
-	client.HMSet(key, values)
+	store.HMSet(key, stringify(values))
 }
 
 func GetAllCommentUsers(account string) map[string]*module.CommentUser {
 	persistence.Lock()
 	defer persistence.Unlock()
 
-	keys, _ := client.Keys("comment_user@*").Result()
+	keys, _ := store.Keys("comment_user@")
 	users := make(map[string]*module.CommentUser)
 	for _, key := range keys {
-		m, err := client.HGetAll(key).Result()
+		m, err := store.HGetAll(key)
 		if err != nil {
 			continue
 		}
@@ -364,17 +369,17 @@ func SaveCommentSession(account string, session *module.CommentSession) {
 		"user_agent": session.UserAgent, "create_time": session.CreateTime,
 		"expire_time": session.ExpireTime, "is_active": session.IsActive,
 	}
-	client.HMSet(key, values)
+	store.HMSet(key, stringify(values))
 }
 
 func GetAllCommentSessions(account string) map[string]*module.CommentSession {
 	persistence.Lock()
 	defer persistence.Unlock()
 
-	keys, _ := client.Keys("comment_session@*").Result()
+	keys, _ := store.Keys("comment_session@")
 	sessions := make(map[string]*module.CommentSession)
 	for _, key := range keys {
-		m, err := client.HGetAll(key).Result()
+		m, err := store.HGetAll(key)
 		if err != nil {
 			continue
 		}
@@ -401,7 +406,7 @@ func toCommentSession(m map[string]string) *module.CommentSession {
 func DeleteCommentSession(account, sessionID string) {
 	persistence.Lock()
 	defer persistence.Unlock()
-	client.Del(fmt.Sprintf("comment_session@%s", sessionID))
+	store.Del(fmt.Sprintf("comment_session@%s", sessionID))
 }
 
 // ========== UsernameReservation 操作 ==========
@@ -415,17 +420,17 @@ func SaveUsernameReservation(account string, reservation *module.UsernameReserva
 		"username": reservation.Username, "user_id": reservation.UserID,
 		"reserve_time": reservation.ReserveTime, "is_temporary": reservation.IsTemporary,
 	}
-	client.HMSet(key, values)
+	store.HMSet(key, stringify(values))
 }
 
 func GetAllUsernameReservations(account string) map[string]*module.UsernameReservation {
 	persistence.Lock()
 	defer persistence.Unlock()
 
-	keys, _ := client.Keys("username_reservation@*").Result()
+	keys, _ := store.Keys("username_reservation@")
 	reservations := make(map[string]*module.UsernameReservation)
 	for _, key := range keys {
-		m, err := client.HGetAll(key).Result()
+		m, err := store.HGetAll(key)
 		if err != nil {
 			continue
 		}
@@ -451,7 +456,7 @@ func toUsernameReservation(m map[string]string) *module.UsernameReservation {
 func DeleteUsernameReservation(account, username string) {
 	persistence.Lock()
 	defer persistence.Unlock()
-	client.Del(fmt.Sprintf("username_reservation@%s", username))
+	store.Del(fmt.Sprintf("username_reservation@%s", username))
 }
 
 // ========== 兼容性函数 ==========
@@ -0,0 +1,166 @@
+package persistence
+
+import (
+	"config"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"module"
+	log "mylog"
+	"strconv"
+)
+
+// ========== 博客修订历史 ==========
+// <account>:blog_rev#<seq>@<title>  单条修订，字段 "data" → 修订 JSON（删除博客时保留）
+// <account>:blog_revhead@<title>    最新修订号 seq、最早保留的修订号 first、内容摘要与动作
+// 每篇博客最多保留 blog_revision_keep 条修订，追加时删除更早的修订
+// 旧版本把全部修订存在 <account>:blog_rev@<title> 一个 hash 中，首次访问时拆分为单条 key
+
+// revisionKeep 保留条数（测试中替换）
+var revisionKeep = config.GetBlogRevisionKeep
+
+func revisionKey(account, title string, seq int) string {
+	return fmt.Sprintf("%s:blog_rev#%d@%s", account, seq, title)
+}
+
+func legacyRevisionKey(account, title string) string {
+	return fmt.Sprintf("%s:blog_rev@%s", account, title)
+}
+
+func revisionHeadKey(account, title string) string {
+	return fmt.Sprintf("%s:blog_revhead@%s", account, title)
+}
+
+// revisionDigest 修订内容摘要（标题、内容、标签、加密标志）
+func revisionDigest(rev *module.BlogRevision) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00%d", rev.Title, rev.Content, rev.Tags, rev.Encrypt, rev.AuthType)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadRevisionHeadLocked 读取修订头，返回最新修订号与最早保留的修订号；
+// 遇到旧版单 hash 存储时先拆分为单条 key。调用方需持有 persistence 锁
+func loadRevisionHeadLocked(account, title string) (map[string]string, int, int, error) {
+	headKey := revisionHeadKey(account, title)
+	head, err := store.HGetAll(headKey)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	seq, _ := strconv.Atoi(head["seq"])
+	first, _ := strconv.Atoi(head["first"])
+	if seq == 0 || first > 0 {
+		return head, seq, first, nil
+	}
+
+	legacyKey := legacyRevisionKey(account, title)
+	legacy, err := store.HGetAll(legacyKey)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	first = seq + 1
+	for field, data := range legacy {
+		n, err := strconv.Atoi(field)
+		if err != nil || n <= 0 {
+			continue
+		}
+		if err := store.HMSet(revisionKey(account, title, n), map[string]string{"data": data}); err != nil {
+			return nil, 0, 0, err
+		}
+		if n < first {
+			first = n
+		}
+	}
+	if err := store.HMSet(headKey, map[string]string{"first": strconv.Itoa(first)}); err != nil {
+		return nil, 0, 0, err
+	}
+	store.Del(legacyKey)
+	head["first"] = strconv.Itoa(first)
+	return head, seq, first, nil
+}
+
+// AppendBlogRevision 追加一条修订并返回修订号；
+// 与最新修订内容相同且不是删除/恢复操作时跳过，返回 0
+func AppendBlogRevision(account string, rev *module.BlogRevision) int {
+	persistence.Lock()
+	defer persistence.Unlock()
+
+	head, seq, first, err := loadRevisionHeadLocked(account, rev.Title)
+	if err != nil {
+		log.ErrorF(log.ModulePersistence, "revision head error account=%s title=%s err=%v", account, rev.Title, err)
+		return 0
+	}
+	digest := revisionDigest(rev)
+	if seq > 0 && head["digest"] == digest && head["action"] != module.RevisionAction_delete &&
+		rev.Action != module.RevisionAction_delete && rev.Action != module.RevisionAction_restore {
+		return 0
+	}
+
+	rev.Seq = seq + 1
+	rev.Size = len(rev.Content)
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return 0
+	}
+	key := revisionKey(account, rev.Title, rev.Seq)
+	if err := store.HMSet(key, map[string]string{"data": string(data)}); err != nil {
+		log.ErrorF(log.ModulePersistence, "save revision error key=%s err=%v", key, err)
+		return 0
+	}
+
+	// 超出保留条数时删除最旧的修订
+	if first == 0 {
+		first = rev.Seq
+	}
+	for ; first <= rev.Seq-revisionKeep(); first++ {
+		store.Del(revisionKey(account, rev.Title, first))
+	}
+	store.HMSet(revisionHeadKey(account, rev.Title), map[string]string{
+		"seq":    strconv.Itoa(rev.Seq),
+		"first":  strconv.Itoa(first),
+		"digest": digest,
+		"action": rev.Action,
+	})
+	return rev.Seq
+}
+
+// GetBlogRevisions 获取博客保留的全部修订（按修订号升序）
+func GetBlogRevisions(account, title string) []*module.BlogRevision {
+	persistence.Lock()
+	defer persistence.Unlock()
+
+	_, seq, first, err := loadRevisionHeadLocked(account, title)
+	if err != nil || seq == 0 {
+		return nil
+	}
+	revs := make([]*module.BlogRevision, 0, seq-first+1)
+	for n := first; n <= seq; n++ {
+		if rev := getBlogRevisionLocked(account, title, n); rev != nil {
+			revs = append(revs, rev)
+		}
+	}
+	return revs
+}
+
+// GetBlogRevision 获取指定修订
+func GetBlogRevision(account, title string, seq int) *module.BlogRevision {
+	persistence.Lock()
+	defer persistence.Unlock()
+
+	if _, _, _, err := loadRevisionHeadLocked(account, title); err != nil {
+		return nil
+	}
+	return getBlogRevisionLocked(account, title, seq)
+}
+
+func getBlogRevisionLocked(account, title string, seq int) *module.BlogRevision {
+	m, err := store.HGetAll(revisionKey(account, title, seq))
+	if err != nil || m["data"] == "" {
+		return nil
+	}
+	rev := &module.BlogRevision{}
+	if json.Unmarshal([]byte(m["data"]), rev) != nil {
+		return nil
+	}
+	return rev
+}
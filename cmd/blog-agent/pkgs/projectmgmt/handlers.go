@@ -63,7 +63,7 @@ func HandleProjects(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
-		project, err := CreateProjectWithAccount(account, &req)
+		project, err := CreateProjectWithAccount(r.Context(), account, &req)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
@@ -75,7 +75,7 @@ func HandleProjects(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
-		if err := UpdateProjectWithAccount(account, &req); err != nil {
+		if err := UpdateProjectWithAccount(r.Context(), account, &req); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
@@ -95,7 +95,7 @@ func HandleProjects(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": "project_id is required"})
 			return
 		}
-		if err := DeleteProjectWithAccount(account, projectID); err != nil {
+		if err := DeleteProjectWithAccount(r.Context(), account, projectID); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
@@ -144,7 +144,7 @@ func HandleProjectGoals(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
-		goal, err := AddGoalWithAccount(account, req.ProjectID, req.Goal)
+		goal, err := AddGoalWithAccount(r.Context(), account, req.ProjectID, req.Goal)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
@@ -159,7 +159,7 @@ func HandleProjectGoals(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
-		if err := UpdateGoalWithAccount(account, req.ProjectID, req.Goal); err != nil {
+		if err := UpdateGoalWithAccount(r.Context(), account, req.ProjectID, req.Goal); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
@@ -173,7 +173,7 @@ func HandleProjectGoals(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
-		if err := DeleteGoalWithAccount(account, req.ProjectID, req.GoalID); err != nil {
+		if err := DeleteGoalWithAccount(r.Context(), account, req.ProjectID, req.GoalID); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
@@ -208,7 +208,7 @@ func HandleProjectOKRs(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
-		okr, err := AddOKRWithAccount(account, req.ProjectID, req.OKR)
+		okr, err := AddOKRWithAccount(r.Context(), account, req.ProjectID, req.OKR)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
@@ -223,7 +223,7 @@ func HandleProjectOKRs(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
-		if err := UpdateOKRWithAccount(account, req.ProjectID, req.OKR); err != nil {
+		if err := UpdateOKRWithAccount(r.Context(), account, req.ProjectID, req.OKR); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
@@ -237,7 +237,7 @@ func HandleProjectOKRs(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
-		if err := DeleteOKRWithAccount(account, req.ProjectID, req.OKRID); err != nil {
+		if err := DeleteOKRWithAccount(r.Context(), account, req.ProjectID, req.OKRID); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 			return
 		}
@@ -266,7 +266,7 @@ func HandleProjectKeyResults(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 		return
 	}
-	if err := UpdateKeyResultWithAccount(account, req.ProjectID, req.OKRID, req.KeyResult); err != nil {
+	if err := UpdateKeyResultWithAccount(r.Context(), account, req.ProjectID, req.OKRID, req.KeyResult); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "message": err.Error()})
 		return
 	}
@@ -2,6 +2,7 @@ package projectmgmt
 
 import (
 	"blog"
+	"context"
 	"encoding/json"
 	"fmt"
 	"module"
@@ -290,7 +291,7 @@ func prepareProjectForSave(project *Project, account string, isNew bool) (*Proje
 	return &copyProject, nil
 }
 
-func saveProjectWithAccount(account string, project *Project, isNew bool) error {
+func saveProjectWithAccount(ctx context.Context, account string, project *Project, isNew bool) error {
 	prepared, err := prepareProjectForSave(project, account, isNew)
 	if err != nil {
 		return err
@@ -308,11 +309,11 @@ func saveProjectWithAccount(account string, project *Project, isNew bool) error
 	}
 	existing := blog.GetBlogWithAccount(account, udb.Title)
 	if existing == nil {
-		if ret := blog.AddBlogWithAccount(account, udb); ret != 0 {
+		if ret := blog.AddBlogWithContext(ctx, account, udb); ret != 0 {
 			return fmt.Errorf("failed to create project")
 		}
 	} else {
-		if ret := blog.ModifyBlogWithAccount(account, udb); ret != 0 {
+		if ret := blog.ModifyBlogWithContext(ctx, account, udb); ret != 0 {
 			return fmt.Errorf("failed to update project")
 		}
 	}
@@ -363,7 +364,7 @@ func findKRIndex(okr *OKR, krID string) int {
 	return -1
 }
 
-func CreateProjectWithAccount(account string, project *Project) (*Project, error) {
+func CreateProjectWithAccount(ctx context.Context, account string, project *Project) (*Project, error) {
 	if account == "" {
 		return nil, fmt.Errorf("account is required")
 	}
@@ -371,7 +372,7 @@ func CreateProjectWithAccount(account string, project *Project) (*Project, error
 	if err != nil {
 		return nil, err
 	}
-	if err := saveProjectWithAccount(account, prepared, true); err != nil {
+	if err := saveProjectWithAccount(ctx, account, prepared, true); err != nil {
 		return nil, err
 	}
 	log.DebugF(log.ModuleBlog, "created project %s for %s", prepared.ID, account)
@@ -411,7 +412,7 @@ func ListProjectsWithAccount(account, status string) ([]Project, error) {
 	return projects, nil
 }
 
-func UpdateProjectWithAccount(account string, project *Project) error {
+func UpdateProjectWithAccount(ctx context.Context, account string, project *Project) error {
 	if account == "" {
 		return fmt.Errorf("account is required")
 	}
@@ -425,10 +426,10 @@ func UpdateProjectWithAccount(account string, project *Project) error {
 	if project.CreatedAt == "" {
 		project.CreatedAt = existing.CreatedAt
 	}
-	return saveProjectWithAccount(account, project, false)
+	return saveProjectWithAccount(ctx, account, project, false)
 }
 
-func DeleteProjectWithAccount(account, projectID string) error {
+func DeleteProjectWithAccount(ctx context.Context, account, projectID string) error {
 	if account == "" || strings.TrimSpace(projectID) == "" {
 		return fmt.Errorf("account and projectID are required")
 	}
@@ -436,13 +437,13 @@ func DeleteProjectWithAccount(account, projectID string) error {
 	if target == nil {
 		return fmt.Errorf("project not found: %s", projectID)
 	}
-	if blog.DeleteBlogWithAccount(account, projectTitle(projectID)) != 0 {
+	if blog.DeleteBlogWithContext(ctx, account, projectTitle(projectID)) != 0 {
 		return fmt.Errorf("failed to delete project")
 	}
 	return nil
 }
 
-func AddGoalWithAccount(account, projectID string, goal Goal) (*Goal, error) {
+func AddGoalWithAccount(ctx context.Context, account, projectID string, goal Goal) (*Goal, error) {
 	project, err := GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return nil, err
@@ -463,13 +464,13 @@ func AddGoalWithAccount(account, projectID string, goal Goal) (*Goal, error) {
 		return nil, err
 	}
 	project.Goals = append(project.Goals, goal)
-	if err := UpdateProjectWithAccount(account, project); err != nil {
+	if err := UpdateProjectWithAccount(ctx, account, project); err != nil {
 		return nil, err
 	}
 	return &goal, nil
 }
 
-func UpdateGoalWithAccount(account, projectID string, goal Goal) error {
+func UpdateGoalWithAccount(ctx context.Context, account, projectID string, goal Goal) error {
 	project, err := GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return err
@@ -484,10 +485,10 @@ func UpdateGoalWithAccount(account, projectID string, goal Goal) error {
 		return err
 	}
 	project.Goals[idx] = goal
-	return UpdateProjectWithAccount(account, project)
+	return UpdateProjectWithAccount(ctx, account, project)
 }
 
-func DeleteGoalWithAccount(account, projectID, goalID string) error {
+func DeleteGoalWithAccount(ctx context.Context, account, projectID, goalID string) error {
 	project, err := GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return err
@@ -497,10 +498,10 @@ func DeleteGoalWithAccount(account, projectID, goalID string) error {
 		return fmt.Errorf("goal not found: %s", goalID)
 	}
 	project.Goals = append(project.Goals[:idx], project.Goals[idx+1:]...)
-	return UpdateProjectWithAccount(account, project)
+	return UpdateProjectWithAccount(ctx, account, project)
 }
 
-func AddOKRWithAccount(account, projectID string, okr OKR) (*OKR, error) {
+func AddOKRWithAccount(ctx context.Context, account, projectID string, okr OKR) (*OKR, error) {
 	project, err := GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return nil, err
@@ -531,13 +532,13 @@ func AddOKRWithAccount(account, projectID string, okr OKR) (*OKR, error) {
 		return nil, err
 	}
 	project.OKRs = append(project.OKRs, okr)
-	if err := UpdateProjectWithAccount(account, project); err != nil {
+	if err := UpdateProjectWithAccount(ctx, account, project); err != nil {
 		return nil, err
 	}
 	return &okr, nil
 }
 
-func UpdateOKRWithAccount(account, projectID string, okr OKR) error {
+func UpdateOKRWithAccount(ctx context.Context, account, projectID string, okr OKR) error {
 	project, err := GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return err
@@ -565,10 +566,10 @@ func UpdateOKRWithAccount(account, projectID string, okr OKR) error {
 		return err
 	}
 	project.OKRs[idx] = okr
-	return UpdateProjectWithAccount(account, project)
+	return UpdateProjectWithAccount(ctx, account, project)
 }
 
-func DeleteOKRWithAccount(account, projectID, okrID string) error {
+func DeleteOKRWithAccount(ctx context.Context, account, projectID, okrID string) error {
 	project, err := GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return err
@@ -578,10 +579,10 @@ func DeleteOKRWithAccount(account, projectID, okrID string) error {
 		return fmt.Errorf("okr not found: %s", okrID)
 	}
 	project.OKRs = append(project.OKRs[:idx], project.OKRs[idx+1:]...)
-	return UpdateProjectWithAccount(account, project)
+	return UpdateProjectWithAccount(ctx, account, project)
 }
 
-func UpdateKeyResultWithAccount(account, projectID, okrID string, kr KeyResult) error {
+func UpdateKeyResultWithAccount(ctx context.Context, account, projectID, okrID string, kr KeyResult) error {
 	project, err := GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return err
@@ -614,7 +615,7 @@ func UpdateKeyResultWithAccount(account, projectID, okrID string, kr KeyResult)
 		project.OKRs[okrIdx].KeyResults[krIdx] = kr
 	}
 	project.OKRs[okrIdx].UpdatedAt = now
-	return UpdateProjectWithAccount(account, project)
+	return UpdateProjectWithAccount(ctx, account, project)
 }
 
 func GetProjectSummaryWithAccount(account string) (*ProjectSummary, error) {
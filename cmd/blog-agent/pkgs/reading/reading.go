@@ -2,6 +2,7 @@ package reading
 
 import (
 	"blog"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -73,6 +74,10 @@ func ensureAccountData(account string) {
 // ========== Book 管理 ==========
 
 func AddBookWithAccount(account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl string, totalPages int, category, tags []string) (*module.Book, error) {
+	return AddBookWithContext(context.Background(), account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl, totalPages, category, tags)
+}
+
+func AddBookWithContext(ctx context.Context, account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl string, totalPages int, category, tags []string) (*module.Book, error) {
 	readingMu.Lock()
 	defer readingMu.Unlock()
 
@@ -103,7 +108,7 @@ func AddBookWithAccount(account, title, author, isbn, publisher, publishDate, co
 	}
 	readingRecords[account][bookID] = record
 
-	saveBookToBlog(account, book)
+	saveBookToBlog(ctx, account, book)
 	return book, nil
 }
 
@@ -194,7 +199,7 @@ func UpdateBookWithAccount(account, bookID string, updates map[string]interface{
 
 	ensureAccountData(account)
 	books[account][bookID] = book
-	saveBookToBlog(account, book)
+	saveBookToBlog(context.Background(), account, book)
 	return nil
 }
 
@@ -257,12 +262,16 @@ func StartReadingWithAccount(account, bookID string) error {
 
 	if book, exists := books[account][bookID]; exists {
 		book.Status = "reading"
-		saveBookToBlog(account, book)
+		saveBookToBlog(context.Background(), account, book)
 	}
 	return nil
 }
 
 func UpdateReadingProgressWithAccount(account, bookID string, currentPage int, notes string) error {
+	return UpdateReadingProgressWithContext(context.Background(), account, bookID, currentPage, notes)
+}
+
+func UpdateReadingProgressWithContext(ctx context.Context, account, bookID string, currentPage int, notes string) error {
 	readingMu.Lock()
 	defer readingMu.Unlock()
 
@@ -303,7 +312,7 @@ func UpdateReadingProgressWithAccount(account, bookID string, currentPage int, n
 
 	books[account][bookID] = book
 	readingRecords[account][bookID] = record
-	saveBookToBlog(account, book)
+	saveBookToBlog(ctx, account, book)
 	return nil
 }
 
@@ -346,7 +355,7 @@ func AddBookNoteWithAccount(account, bookID, noteType, chapter, content string,
 
 	if book := getBookInternal(account, bookID); book != nil {
 		books[account][bookID] = book
-		saveBookToBlog(account, book)
+		saveBookToBlog(context.Background(), account, book)
 	}
 	return note, nil
 }
@@ -395,7 +404,7 @@ func UpdateBookNoteWithAccount(account, bookID, noteID string, updates map[strin
 			note.UpdateTime = strTime()
 			if book := getBookInternal(account, bookID); book != nil {
 				books[account][bookID] = book
-				saveBookToBlog(account, book)
+				saveBookToBlog(context.Background(), account, book)
 			}
 			return nil
 		}
@@ -414,7 +423,7 @@ func DeleteBookNoteWithAccount(account, bookID, noteID string) error {
 			bookNotes[account][bookID] = append(notes[:i], notes[i+1:]...)
 			if book := getBookInternal(account, bookID); book != nil {
 				books[account][bookID] = book
-				saveBookToBlog(account, book)
+				saveBookToBlog(context.Background(), account, book)
 			}
 			return nil
 		}
@@ -443,7 +452,7 @@ func AddBookInsightWithAccount(account, bookID, title, content string, keyTakeaw
 	if book := getBookInternal(account, bookID); book != nil && rating > 0 {
 		book.Rating = float64(rating)
 		books[account][bookID] = book
-		saveBookToBlog(account, book)
+		saveBookToBlog(context.Background(), account, book)
 	}
 	return insight, nil
 }
@@ -509,7 +518,7 @@ func UpdateBookInsightWithAccount(account, insightID string, updates map[string]
 
 	if book := getBookInternal(account, insight.BookID); book != nil {
 		books[account][insight.BookID] = book
-		saveBookToBlog(account, book)
+		saveBookToBlog(context.Background(), account, book)
 	}
 	return nil
 }
@@ -647,7 +656,7 @@ func GetAllReadingPlansWithAccount(account string) []*module.ReadingPlan {
 
 // ========== 持久化 ==========
 
-func saveBookToBlog(account string, book *module.Book) {
+func saveBookToBlog(ctx context.Context, account string, book *module.Book) {
 	title := fmt.Sprintf("reading_book_%s.md", book.Title)
 	data := map[string]interface{}{"book": book}
 
@@ -677,9 +686,9 @@ func saveBookToBlog(account string, book *module.Book) {
 		Title: title, Content: string(content), Tags: "reading", AuthType: module.EAuthType_private, Account: account,
 	}
 	if blog.GetBlogWithAccount(account, title) == nil {
-		blog.AddBlogWithAccount(account, ubd)
+		blog.AddBlogWithContext(ctx, account, ubd)
 	} else {
-		blog.ModifyBlogWithAccount(account, ubd)
+		blog.ModifyBlogWithContext(ctx, account, ubd)
 	}
 }
 
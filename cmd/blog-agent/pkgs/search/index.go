@@ -0,0 +1,208 @@
+package search
+
+import (
+	"blog"
+	"module"
+	log "mylog"
+	"sync"
+)
+
+// ========== 倒排索引 ==========
+// 每个账户一份内存索引，首次搜索时从博客全量构建，之后由 blog 模块的变更钩子增量维护。
+// 加密博客不进入索引。
+
+// posting 某个词在一篇博客中的位置
+type posting struct {
+	title   []int
+	content []int
+}
+
+// indexedDoc 已索引博客的元数据（不保存正文）
+type indexedDoc struct {
+	title      string
+	tags       string
+	createTime string
+	modifyTime string
+	titleLen   int
+	contentLen int
+	terms      []string // 出现过的词，用于删除
+}
+
+// Index 单个账户的倒排索引
+type Index struct {
+	mu              sync.RWMutex
+	docs            map[string]*indexedDoc
+	postings        map[string]map[string]*posting // term → title → posting
+	totalTitleLen   int
+	totalContentLen int
+
+	building bool            // 正在全量构建
+	touched  map[string]bool // 构建期间被钩子更新过的博客，全量快照不再覆盖
+	ready    chan struct{}
+}
+
+func newIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*indexedDoc),
+		postings: make(map[string]map[string]*posting),
+		ready:    make(chan struct{}),
+	}
+}
+
+// putLocked 索引（或重建）一篇博客；调用方持有写锁
+func (ix *Index) putLocked(b *module.Blog) {
+	ix.removeLocked(b.Title)
+	if b.Encrypt == 1 {
+		return
+	}
+
+	doc := &indexedDoc{
+		title:      b.Title,
+		tags:       b.Tags,
+		createTime: b.CreateTime,
+		modifyTime: b.ModifyTime,
+	}
+	titleTokens := tokenize(b.Title)
+	contentTokens := tokenize(b.Content)
+	doc.titleLen = len(titleTokens)
+	doc.contentLen = len(contentTokens)
+
+	postings := make(map[string]*posting)
+	for _, t := range titleTokens {
+		p := postings[t.term]
+		if p == nil {
+			p = &posting{}
+			postings[t.term] = p
+		}
+		p.title = append(p.title, t.pos)
+	}
+	for _, t := range contentTokens {
+		p := postings[t.term]
+		if p == nil {
+			p = &posting{}
+			postings[t.term] = p
+		}
+		p.content = append(p.content, t.pos)
+	}
+
+	doc.terms = make([]string, 0, len(postings))
+	for term, p := range postings {
+		m := ix.postings[term]
+		if m == nil {
+			m = make(map[string]*posting)
+			ix.postings[term] = m
+		}
+		m[b.Title] = p
+		doc.terms = append(doc.terms, term)
+	}
+	ix.docs[b.Title] = doc
+	ix.totalTitleLen += doc.titleLen
+	ix.totalContentLen += doc.contentLen
+}
+
+// removeLocked 从索引删除博客；调用方持有写锁
+func (ix *Index) removeLocked(title string) {
+	doc, ok := ix.docs[title]
+	if !ok {
+		return
+	}
+	for _, term := range doc.terms {
+		if m := ix.postings[term]; m != nil {
+			delete(m, title)
+			if len(m) == 0 {
+				delete(ix.postings, term)
+			}
+		}
+	}
+	ix.totalTitleLen -= doc.titleLen
+	ix.totalContentLen -= doc.contentLen
+	delete(ix.docs, title)
+}
+
+// Put 索引一篇博客
+func (ix *Index) Put(b *module.Blog) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.building {
+		ix.touched[b.Title] = true
+	}
+	ix.putLocked(b)
+}
+
+// Remove 删除一篇博客
+func (ix *Index) Remove(title string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.building {
+		ix.touched[title] = true
+	}
+	ix.removeLocked(title)
+}
+
+// Len 已索引博客数
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// ========== 账户索引管理 ==========
+
+var (
+	indexesMu sync.Mutex
+	indexes   = make(map[string]*Index)
+)
+
+// getIndex 获取账户索引，首次调用时全量构建
+func getIndex(account string) *Index {
+	indexesMu.Lock()
+	ix, ok := indexes[account]
+	if ok {
+		indexesMu.Unlock()
+		<-ix.ready
+		return ix
+	}
+	ix = newIndex()
+	ix.building = true
+	ix.touched = make(map[string]bool)
+	indexes[account] = ix
+	indexesMu.Unlock()
+
+	// 先注册再取快照：快照之后的变更由钩子写入，快照不覆盖被钩子更新过的博客
+	blogs := blog.SnapshotBlogsWithAccount(account)
+	for i := range blogs {
+		ix.mu.Lock()
+		if !ix.touched[blogs[i].Title] {
+			ix.putLocked(&blogs[i])
+		}
+		ix.mu.Unlock()
+	}
+	ix.mu.Lock()
+	ix.building = false
+	ix.touched = nil
+	docs, terms := len(ix.docs), len(ix.postings)
+	ix.mu.Unlock()
+	close(ix.ready)
+
+	log.InfoF(log.ModuleSearch, "search index built account=%s docs=%d terms=%d", account, docs, terms)
+	return ix
+}
+
+// lookupIndex 返回已存在的账户索引（不触发构建）
+func lookupIndex(account string) *Index {
+	indexesMu.Lock()
+	defer indexesMu.Unlock()
+	return indexes[account]
+}
+
+func onBlogSaved(account string, b *module.Blog) {
+	if ix := lookupIndex(account); ix != nil {
+		ix.Put(b)
+	}
+}
+
+func onBlogDeleted(account, title string) {
+	if ix := lookupIndex(account); ix != nil {
+		ix.Remove(title)
+	}
+}
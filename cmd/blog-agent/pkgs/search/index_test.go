@@ -0,0 +1,147 @@
+package search
+
+import (
+	"module"
+	"testing"
+)
+
+func TestTokenizeCJKBigrams(t *testing.T) {
+	toks := tokenize("Go语言 学习笔记")
+	var terms []string
+	for i, tk := range toks {
+		if tk.pos != i {
+			t.Fatalf("positions must be consecutive: %+v", toks)
+		}
+		terms = append(terms, tk.term)
+	}
+	want := []string{"go", "语言", "学习", "习笔", "笔记"}
+	if len(terms) != len(want) {
+		t.Fatalf("terms=%v want %v", terms, want)
+	}
+	for i := range want {
+		if terms[i] != want[i] {
+			t.Fatalf("terms=%v want %v", terms, want)
+		}
+	}
+}
+
+func newTestIndex() *Index {
+	ix := newIndex()
+	ix.Put(&module.Blog{Title: "redis 持久化", Content: "RDB 和 AOF 两种持久化方式", Tags: "redis|db",
+		CreateTime: "2024-01-10 10:00:00", ModifyTime: "2024-05-01 10:00:00"})
+	ix.Put(&module.Blog{Title: "周报", Content: "本周学习了 redis 集群，下周继续学习分布式锁",
+		Tags: "weekly", CreateTime: "2024-03-02 09:00:00", ModifyTime: "2024-03-02 09:00:00"})
+	ix.Put(&module.Blog{Title: "读书笔记", Content: "分布式系统的一致性，锁与分布式事务",
+		Tags: "book|Reading", CreateTime: "2023-12-30 09:00:00", ModifyTime: "2024-06-01 09:00:00"})
+	ix.Put(&module.Blog{Title: "密码", Content: "redis 密码", Encrypt: 1})
+	return ix
+}
+
+func titles(docs []*scoredDoc) []string {
+	var out []string
+	for _, d := range docs {
+		out = append(out, d.title)
+	}
+	return out
+}
+
+func TestIndexSearch(t *testing.T) {
+	ix := newTestIndex()
+	if ix.Len() != 3 {
+		t.Fatalf("encrypted blog must not be indexed, len=%d", ix.Len())
+	}
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		// 标题命中权重更高
+		{"redis", []string{"redis 持久化", "周报"}},
+		{"持久化", []string{"redis 持久化"}},
+		// 多个词取交集
+		{"redis 分布式", []string{"周报"}},
+		// 短语需连续出现
+		{`"分布式锁"`, []string{"周报"}},
+		{`"锁分布式"`, nil},
+		// 单字匹配包含该字的二元组
+		{"锁", []string{"读书笔记", "周报"}},
+		// 拉丁词前缀
+		{"red", []string{"redis 持久化", "周报"}},
+		{"title:redis", []string{"redis 持久化"}},
+		{"-tTitle 笔记", []string{"读书笔记"}},
+		{"tag:reading", []string{"读书笔记"}},
+		{"tag:redis tag:weekly", nil},
+		{"date:2024-01..2024-03 redis", []string{"redis 持久化", "周报"}},
+		{"date:2024-03", []string{"周报"}},
+		{"modified:2024-05..", []string{"读书笔记", "redis 持久化"}},
+		{"密码", nil},
+	}
+	for _, c := range cases {
+		got := titles(ix.search(parseQuery(c.query)))
+		if len(got) != len(c.want) {
+			t.Fatalf("%q: got %v want %v", c.query, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("%q: got %v want %v", c.query, got, c.want)
+			}
+		}
+	}
+}
+
+func TestIndexIncrementalUpdate(t *testing.T) {
+	ix := newTestIndex()
+	ix.Put(&module.Blog{Title: "周报", Content: "本周休息", ModifyTime: "2024-03-09 09:00:00"})
+	if got := titles(ix.search(parseQuery("redis"))); len(got) != 1 {
+		t.Fatalf("stale postings after update: %v", got)
+	}
+	ix.Remove("redis 持久化")
+	if got := ix.search(parseQuery("redis")); len(got) != 0 {
+		t.Fatalf("removed blog still matches: %v", titles(got))
+	}
+	if ix.totalContentLen <= 0 || len(ix.postings["持久"]) != 0 {
+		t.Fatalf("index bookkeeping not updated")
+	}
+}
+
+func TestBuildSnippet(t *testing.T) {
+	content := "本周学习了 redis 集群，下周继续学习分布式锁"
+	ix := newIndex()
+	ix.Put(&module.Blog{Title: "周报", Content: content})
+	docs := ix.search(parseQuery(`redis "分布式"`))
+	if len(docs) != 1 {
+		t.Fatalf("expected one hit, got %v", titles(docs))
+	}
+	text := SnippetText(buildSnippet(content, docs[0].spans))
+	if text != "本周学习了 **redis** 集群，下周继续学习**分布式**锁" {
+		t.Fatalf("unexpected snippet: %s", text)
+	}
+}
+
+func TestIndexPrefixExpandsExactTerm(t *testing.T) {
+	ix := newIndex()
+	ix.Put(&module.Blog{Title: "golang 入门", Content: "入门教程", ModifyTime: "2024-01-01 09:00:00"})
+	ix.Put(&module.Blog{Title: "go 笔记", Content: "示例", ModifyTime: "2024-01-02 09:00:00"})
+	// 索引中存在精确词 "go" 时，"golang" 也应命中
+	if got := titles(ix.search(parseQuery("go"))); len(got) != 2 {
+		t.Fatalf("expected prefix expansion alongside exact term, got %v", got)
+	}
+}
+
+func TestSubstringMatchesKeepsLegacyBehavior(t *testing.T) {
+	blogs := map[string]*module.Blog{
+		"golang 入门":   {Title: "golang 入门", Content: "goroutine", ModifyTime: "2024-01-01 09:00:00"},
+		"周报":          {Title: "周报", Content: "学习 erlang", ModifyTime: "2024-02-01 09:00:00"},
+		"secret lang": {Title: "secret lang", Content: "lang", Encrypt: 1},
+	}
+	// 词中子串仍可命中，加密博客不返回
+	got := substringMatches(blogs, []string{"lang"}, nil)
+	if len(got) != 2 || got[0].Title != "周报" || got[1].Title != "golang 入门" {
+		t.Fatalf("unexpected substring matches: %v", got)
+	}
+	// 已由索引命中的博客不重复返回
+	got = substringMatches(blogs, []string{"lang"}, map[string]bool{"周报": true})
+	if len(got) != 1 || got[0].Title != "golang 入门" {
+		t.Fatalf("seen blogs must be skipped: %v", got)
+	}
+}
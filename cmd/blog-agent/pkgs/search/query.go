@@ -0,0 +1,375 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// ========== 查询 ==========
+// 语法（空格分隔，多个条件取交集）：
+//   关键词              标题或正文包含（中文按二元组匹配，连续出现才算命中）
+//   "多个 词"           短语，词必须连续出现
+//   title:xx            只匹配标题（支持 title:"短语"）
+//   tag:xx              标签过滤
+//   date:2024-01..2024-03      创建时间范围（闭区间，可省略任一端，也可只写 date:2024-05）
+//   modified:2024-06-01..      修改时间范围
+//   -tTitle             兼容旧语法：其后所有关键词只匹配标题
+
+const (
+	bm25K1     = 1.2
+	bm25B      = 0.75
+	titleBoost = 2.0
+)
+
+type queryTerm struct {
+	raw       string
+	tokens    []string
+	titleOnly bool
+}
+
+type dateRange struct {
+	from string
+	to   string
+}
+
+func (r dateRange) empty() bool { return r.from == "" && r.to == "" }
+
+// contains 按字符串前缀比较，"2024-03" 作为上界时包含整个三月
+func (r dateRange) contains(t string) bool {
+	if r.from != "" && t < r.from {
+		return false
+	}
+	if r.to != "" {
+		cut := t
+		if len(cut) > len(r.to) {
+			cut = cut[:len(r.to)]
+		}
+		if cut > r.to {
+			return false
+		}
+	}
+	return true
+}
+
+func parseDateRange(v string) dateRange {
+	if idx := strings.Index(v, ".."); idx >= 0 {
+		return dateRange{from: v[:idx], to: v[idx+2:]}
+	}
+	return dateRange{from: v, to: v}
+}
+
+// parsedQuery 解析后的查询
+type parsedQuery struct {
+	terms    []queryTerm
+	tags     []string
+	created  dateRange
+	modified dateRange
+}
+
+func (q *parsedQuery) empty() bool {
+	return len(q.terms) == 0 && len(q.tags) == 0 && q.created.empty() && q.modified.empty()
+}
+
+// splitQuery 按空格切分，保留引号内的空格
+func splitQuery(s string) []string {
+	var parts []string
+	var cur strings.Builder
+	inQuote := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			cur.WriteRune(r)
+		case (r == ' ' || r == '\t' || r == '　') && !inQuote:
+			if cur.Len() > 0 {
+				parts = append(parts, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		parts = append(parts, cur.String())
+	}
+	return parts
+}
+
+func parseQuery(s string) *parsedQuery {
+	q := &parsedQuery{}
+	titleOnly := false
+	for _, part := range splitQuery(s) {
+		if strings.EqualFold(part, "-tTitle") {
+			titleOnly = true
+			continue
+		}
+
+		field, value := "", part
+		if idx := strings.Index(part, ":"); idx > 0 && !strings.HasPrefix(part, `"`) {
+			field, value = strings.ToLower(part[:idx]), part[idx+1:]
+		}
+		value = strings.Trim(value, `"`)
+		if value == "" {
+			continue
+		}
+
+		switch field {
+		case "tag":
+			q.tags = append(q.tags, strings.ToLower(value))
+		case "date", "created":
+			q.created = parseDateRange(value)
+		case "modified":
+			q.modified = parseDateRange(value)
+		case "title":
+			q.addTerm(value, true)
+		default:
+			q.addTerm(strings.Trim(part, `"`), titleOnly)
+		}
+	}
+	return q
+}
+
+func (q *parsedQuery) addTerm(raw string, titleOnly bool) {
+	toks := tokenize(raw)
+	if len(toks) == 0 {
+		return
+	}
+	term := queryTerm{raw: raw, titleOnly: titleOnly}
+	for _, t := range toks {
+		term.tokens = append(term.tokens, t.term)
+	}
+	q.terms = append(q.terms, term)
+}
+
+// ========== 执行 ==========
+
+// matchSpan 正文中的一次命中：起始词位置与词数
+type matchSpan struct {
+	pos int
+	n   int
+}
+
+type scoredDoc struct {
+	title      string
+	modifyTime string
+	score      float64
+	spans      []matchSpan
+}
+
+// resolveSlot 将查询词映射为索引中的词集合：
+// 单个中文字匹配所有包含该字的二元组；拉丁词同时按前缀扩展（"go" 也匹配 "golang"）
+func (ix *Index) resolveSlot(tok string) []string {
+	_, exact := ix.postings[tok]
+	if runeCount(tok) == 1 && isCJK([]rune(tok)[0]) {
+		var terms []string
+		for term := range ix.postings {
+			if strings.Contains(term, tok) {
+				terms = append(terms, term)
+			}
+		}
+		return terms
+	}
+	if len(tok) >= 2 && !isCJK([]rune(tok)[0]) {
+		var terms []string
+		for term := range ix.postings {
+			if strings.HasPrefix(term, tok) {
+				terms = append(terms, term)
+			}
+		}
+		return terms
+	}
+	if exact {
+		return []string{tok}
+	}
+	return nil
+}
+
+// slotPositions 某个词槽在一篇博客某字段中的位置集合
+func (ix *Index) slotPositions(slot []string, title string, inTitle bool) map[int]bool {
+	var set map[int]bool
+	for _, term := range slot {
+		p := ix.postings[term][title]
+		if p == nil {
+			continue
+		}
+		positions := p.content
+		if inTitle {
+			positions = p.title
+		}
+		for _, pos := range positions {
+			if set == nil {
+				set = make(map[int]bool)
+			}
+			set[pos] = true
+		}
+	}
+	return set
+}
+
+// phraseStarts 返回短语在字段中的所有起始位置
+func (ix *Index) phraseStarts(slots [][]string, title string, inTitle bool) []int {
+	sets := make([]map[int]bool, len(slots))
+	for i, slot := range slots {
+		sets[i] = ix.slotPositions(slot, title, inTitle)
+		if len(sets[i]) == 0 {
+			return nil
+		}
+	}
+	var starts []int
+	for pos := range sets[0] {
+		ok := true
+		for i := 1; i < len(sets); i++ {
+			if !sets[i][pos+i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			starts = append(starts, pos)
+		}
+	}
+	sort.Ints(starts)
+	return starts
+}
+
+// candidates 包含词槽中任意词的博客
+func (ix *Index) candidates(slot []string) map[string]bool {
+	docs := make(map[string]bool)
+	for _, term := range slot {
+		for title := range ix.postings[term] {
+			docs[title] = true
+		}
+	}
+	return docs
+}
+
+func bm25(tf, docLen int, avgLen, idf float64) float64 {
+	if tf == 0 {
+		return 0
+	}
+	if avgLen == 0 {
+		avgLen = 1
+	}
+	f := float64(tf)
+	return idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(docLen)/avgLen))
+}
+
+func (ix *Index) passFilters(q *parsedQuery, doc *indexedDoc) bool {
+	if !q.created.contains(doc.createTime) || !q.modified.contains(doc.modifyTime) {
+		return false
+	}
+	if len(q.tags) == 0 {
+		return true
+	}
+	tags := strings.Split(strings.ToLower(doc.tags), "|")
+	for _, want := range q.tags {
+		found := false
+		for _, t := range tags {
+			if t == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// search 执行查询，返回按相关度排序的结果
+func (ix *Index) search(q *parsedQuery) []*scoredDoc {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	results := make(map[string]*scoredDoc)
+
+	// 只有过滤条件时返回全部满足条件的博客
+	if len(q.terms) == 0 {
+		for title, doc := range ix.docs {
+			if ix.passFilters(q, doc) {
+				results[title] = &scoredDoc{title: title, modifyTime: doc.modifyTime}
+			}
+		}
+		return sortScored(results)
+	}
+
+	n := float64(len(ix.docs))
+	avgTitle := float64(ix.totalTitleLen) / math.Max(n, 1)
+	avgContent := float64(ix.totalContentLen) / math.Max(n, 1)
+
+	for i, term := range q.terms {
+		slots := make([][]string, len(term.tokens))
+		for k, tok := range term.tokens {
+			slots[k] = ix.resolveSlot(tok)
+			if len(slots[k]) == 0 {
+				return nil // 有词完全不存在，交集为空
+			}
+		}
+
+		// 命中的博客及词频
+		type termHit struct {
+			titleTF int
+			starts  []int
+		}
+		hits := make(map[string]*termHit)
+		for title := range ix.candidates(slots[0]) {
+			if i > 0 && results[title] == nil {
+				continue
+			}
+			doc := ix.docs[title]
+			if doc == nil || !ix.passFilters(q, doc) {
+				continue
+			}
+			titleStarts := ix.phraseStarts(slots, title, true)
+			var contentStarts []int
+			if !term.titleOnly {
+				contentStarts = ix.phraseStarts(slots, title, false)
+			}
+			if len(titleStarts) == 0 && len(contentStarts) == 0 {
+				continue
+			}
+			hits[title] = &termHit{titleTF: len(titleStarts), starts: contentStarts}
+		}
+
+		df := float64(len(hits))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		next := make(map[string]*scoredDoc, len(hits))
+		for title, h := range hits {
+			doc := ix.docs[title]
+			sd := results[title]
+			if sd == nil {
+				sd = &scoredDoc{title: title, modifyTime: doc.modifyTime}
+			}
+			sd.score += titleBoost*bm25(h.titleTF, doc.titleLen, avgTitle, idf) +
+				bm25(len(h.starts), doc.contentLen, avgContent, idf)
+			for _, s := range h.starts {
+				sd.spans = append(sd.spans, matchSpan{pos: s, n: len(slots)})
+			}
+			next[title] = sd
+		}
+		results = next
+		if len(results) == 0 {
+			return nil
+		}
+	}
+	return sortScored(results)
+}
+
+func sortScored(m map[string]*scoredDoc) []*scoredDoc {
+	out := make([]*scoredDoc, 0, len(m))
+	for _, sd := range m {
+		out = append(out, sd)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].score != out[j].score {
+			return out[i].score > out[j].score
+		}
+		if out[i].modifyTime != out[j].modifyTime {
+			return out[i].modifyTime > out[j].modifyTime
+		}
+		return out[i].title < out[j].title
+	})
+	return out
+}
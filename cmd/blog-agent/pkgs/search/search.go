@@ -50,12 +50,15 @@ func GetSearchCommands() []SearchCommandMeta {
 func Info() {
 	log.InfoF(log.ModuleSearch, "info search v5.0")
 
+	// 博客变更时增量更新全文索引
+	blog.RegisterChangeHook(blog.ChangeHook{OnSave: onBlogSaved, OnDelete: onBlogDeleted})
+
 	// 注册搜索命令及其元数据
 	registerCommand("@normal search", normalMatch, SearchCommandMeta{
 		Name:        "@normal search",
 		DisplayName: "普通搜索",
-		Description: "搜索博客标题和内容",
-		Example:     "关键词1 关键词2",
+		Description: "全文搜索博客标题和内容，按相关度排序；支持 \"短语\"、title:、tag:、date:起..止、modified:起..止",
+		Example:     "关键词1 \"短语\" tag:linux date:2024-01..2024-06",
 		HasParam:    true,
 		ParamHint:   "搜索关键词",
 	})
@@ -217,17 +220,47 @@ func reloadCfg(account string, tokens []string) []*module.Blog {
 	return []*module.Blog{}
 }
 
+// normalMatch 全文检索，结果按相关度排序
 func normalMatch(account string, matches []string) []*module.Blog {
+	query := strings.TrimSpace(strings.Join(matches, " "))
+	q := parseQuery(query)
+	if q.empty() {
+		// 没有关键词，返回所有非加密博客
+		s := make([]*module.Blog, 0)
+		for _, b := range blog.GetBlogsWithAccount(account) {
+			if b.Encrypt != 1 {
+				s = append(s, b)
+			}
+		}
+		sortblogs(s)
+		return s
+	}
+
+	hits := SearchHits(account, query, 0)
+	s := make([]*module.Blog, 0, len(hits))
+	seen := make(map[string]bool, len(hits))
+	for _, h := range hits {
+		s = append(s, h.Blog)
+		seen[h.Blog.Title] = true
+	}
+	// 带标签/时间过滤时只返回索引结果，子串兜底无法应用这些过滤条件
+	if len(q.tags) > 0 || !q.created.empty() || !q.modified.empty() {
+		return s
+	}
+	return append(s, substringMatches(blog.GetBlogsWithAccount(account), matches, seen)...)
+}
+
+// substringMatches 兼容旧行为：任一关键词是标题或正文的子串即命中（加密博客除外），
+// 排在索引结果之后；seen 中的博客已由索引命中，跳过
+func substringMatches(blogs map[string]*module.Blog, matches []string, seen map[string]bool) []*module.Blog {
 	s := make([]*module.Blog, 0)
-	for _, b := range blog.GetBlogsWithAccount(account) {
-		if ismatch(b, matches) == 0 {
+	for _, b := range blogs {
+		if seen[b.Title] || ismatch(b, matches) == 0 {
 			continue
 		}
 		s = append(s, b)
 	}
-
 	sortblogs(s)
-
 	return s
 }
 
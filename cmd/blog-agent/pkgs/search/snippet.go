@@ -0,0 +1,179 @@
+package search
+
+import (
+	"blog"
+	"module"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ========== 搜索结果与摘要 ==========
+
+const (
+	snippetBefore = 60  // 第一个命中前保留的字符数
+	snippetLength = 200 // 摘要总字符数
+)
+
+// SnippetPart 摘要片段，Match 为 true 表示命中部分
+type SnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match"`
+}
+
+// Hit 一条搜索结果
+type Hit struct {
+	Blog    *module.Blog  `json:"-"`
+	Title   string        `json:"title"`
+	Score   float64       `json:"score"`
+	Snippet []SnippetPart `json:"snippet"`
+}
+
+// SearchHits 全文检索，按相关度返回最多 limit 条结果（limit<=0 不限制）
+func SearchHits(account, query string, limit int) []*Hit {
+	q := parseQuery(query)
+	if q.empty() {
+		return []*Hit{}
+	}
+	docs := getIndex(account).search(q)
+	hits := make([]*Hit, 0, len(docs))
+	for _, sd := range docs {
+		if limit > 0 && len(hits) >= limit {
+			break
+		}
+		b := blog.GetBlogWithAccount(account, sd.title)
+		if b == nil || b.Encrypt == 1 {
+			continue
+		}
+		hits = append(hits, &Hit{
+			Blog:    b,
+			Title:   b.Title,
+			Score:   sd.score,
+			Snippet: buildSnippet(b.Content, sd.spans),
+		})
+	}
+	return hits
+}
+
+// buildSnippet 截取第一个命中附近的正文，并标出所有命中的词
+func buildSnippet(content string, spans []matchSpan) []SnippetPart {
+	if content == "" {
+		return nil
+	}
+
+	// 命中的字节区间（二元组重叠，相邻区间合并）
+	var ranges [][2]int
+	if len(spans) > 0 {
+		covered := make(map[int]bool)
+		for _, s := range spans {
+			for k := 0; k < s.n; k++ {
+				covered[s.pos+k] = true
+			}
+		}
+		for _, t := range tokenize(content) {
+			if !covered[t.pos] {
+				continue
+			}
+			if n := len(ranges); n > 0 && t.start <= ranges[n-1][1] {
+				if t.end > ranges[n-1][1] {
+					ranges[n-1][1] = t.end
+				}
+				continue
+			}
+			ranges = append(ranges, [2]int{t.start, t.end})
+		}
+	}
+
+	// 窗口：第一个命中前 snippetBefore 个字符起，共 snippetLength 个字符
+	start := 0
+	if len(ranges) > 0 {
+		start = backRunes(content, ranges[0][0], snippetBefore)
+	}
+	end := forwardRunes(content, start, snippetLength)
+
+	var parts []SnippetPart
+	add := func(text string, match bool) {
+		if text == "" {
+			return
+		}
+		if !match {
+			text = collapseSpace(text)
+		}
+		parts = append(parts, SnippetPart{Text: text, Match: match})
+	}
+	if start > 0 {
+		add("…", false)
+	}
+	cur := start
+	for _, r := range ranges {
+		if r[1] <= cur || r[0] >= end {
+			continue
+		}
+		rs, re := r[0], r[1]
+		if rs < cur {
+			rs = cur
+		}
+		if re > end {
+			re = end
+		}
+		add(content[cur:rs], false)
+		add(content[rs:re], true)
+		cur = re
+	}
+	add(content[cur:end], false)
+	if end < len(content) {
+		add("…", false)
+	}
+	return parts
+}
+
+// collapseSpace 换行等连续空白压缩为一个空格
+func collapseSpace(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// backRunes 从字节偏移 i 向前移动 n 个字符
+func backRunes(s string, i, n int) int {
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return i
+}
+
+// forwardRunes 从字节偏移 i 向后移动 n 个字符
+func forwardRunes(s string, i, n int) int {
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return i
+}
+
+// SnippetText 将摘要渲染为纯文本，命中部分用 ** 标出
+func SnippetText(parts []SnippetPart) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		if p.Match {
+			sb.WriteString("**")
+			sb.WriteString(p.Text)
+			sb.WriteString("**")
+		} else {
+			sb.WriteString(p.Text)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ========== 分词 ==========
+// 拉丁字母/数字按连续片段切词（小写）；CJK 连续片段切为重叠二元组（长度为 1 时保留单字），
+// 相邻词的位置连续，因此短语查询只需判断位置是否相邻。
+
+// token 分词结果
+type token struct {
+	term  string
+	pos   int // 词位置
+	start int // 原文字节起始
+	end   int // 原文字节结束
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isCJK(r)
+}
+
+// tokenize 将文本切分为词序列
+func tokenize(text string) []token {
+	var tokens []token
+	pos := 0
+	i := 0
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case isWordRune(r):
+			start := i
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !isWordRune(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), pos: pos, start: start, end: i})
+			pos++
+		case isCJK(r):
+			// 收集连续 CJK 字符的字节偏移
+			var offsets []int
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !isCJK(r) {
+					break
+				}
+				offsets = append(offsets, i)
+				i += size
+			}
+			offsets = append(offsets, i)
+			n := len(offsets) - 1
+			if n == 1 {
+				tokens = append(tokens, token{term: text[offsets[0]:offsets[1]], pos: pos, start: offsets[0], end: offsets[1]})
+				pos++
+				continue
+			}
+			for k := 0; k+1 < n; k++ {
+				tokens = append(tokens, token{term: text[offsets[k]:offsets[k+2]], pos: pos, start: offsets[k], end: offsets[k+2]})
+				pos++
+			}
+		default:
+			i += size
+		}
+	}
+	return tokens
+}
+
+// runeCount 词的字符数（用于区分单字 CJK 查询）
+func runeCount(s string) int {
+	return utf8.RuneCountInString(s)
+}
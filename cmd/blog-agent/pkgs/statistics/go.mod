@@ -12,6 +12,7 @@ require (
 	mylog v0.0.0
 	projectmgmt v0.0.0
 	reading v0.0.0
+	search v0.0.0
 	taskbreakdown v0.0.0
 	todolist v0.0.0
 	yearplan v0.0.0
@@ -60,3 +61,5 @@ replace account => ../account
 replace auth => ../auth
 
 replace view => ../view
+
+replace search => ../search
@@ -1,6 +1,7 @@
 package statistics
 
 import (
+	"context"
 	"encoding/json"
 	"exercise"
 	"fmt"
@@ -38,9 +39,9 @@ func RawGetTodosRange(account, startDate, endDate string) string {
 }
 
 // RawAddTodo 添加待办事项
-func RawAddTodo(account, date, content string, hours, minutes, urgency, importance int) string {
+func RawAddTodo(ctx context.Context, account, date, content string, hours, minutes, urgency, importance int) string {
 	mgr := todolist.NewTodoManager()
-	item, err := mgr.AddTodo(account, date, content, hours, minutes, urgency, importance)
+	item, err := mgr.AddTodo(ctx, account, date, content, hours, minutes, urgency, importance)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -49,9 +50,9 @@ func RawAddTodo(account, date, content string, hours, minutes, urgency, importan
 }
 
 // RawToggleTodo 切换待办完成状态
-func RawToggleTodo(account, date, id string) string {
+func RawToggleTodo(ctx context.Context, account, date, id string) string {
 	mgr := todolist.NewTodoManager()
-	err := mgr.ToggleTodo(account, date, id)
+	err := mgr.ToggleTodo(ctx, account, date, id)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -59,9 +60,9 @@ func RawToggleTodo(account, date, id string) string {
 }
 
 // RawDeleteTodo 删除待办
-func RawDeleteTodo(account, date, id string) string {
+func RawDeleteTodo(ctx context.Context, account, date, id string) string {
 	mgr := todolist.NewTodoManager()
-	err := mgr.DeleteTodo(account, date, id)
+	err := mgr.DeleteTodo(ctx, account, date, id)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -69,9 +70,9 @@ func RawDeleteTodo(account, date, id string) string {
 }
 
 // RawUpdateTodo 修改待办时间
-func RawUpdateTodo(account, date, id string, hours, minutes int) string {
+func RawUpdateTodo(ctx context.Context, account, date, id string, hours, minutes int) string {
 	mgr := todolist.NewTodoManager()
-	err := mgr.UpdateTodoTime(account, date, id, hours, minutes)
+	err := mgr.UpdateTodoTime(ctx, account, date, id, hours, minutes)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -121,8 +122,8 @@ func RawGetExerciseRange(account, startDate, endDate string) string {
 }
 
 // RawAddExercise 添加运动记录
-func RawAddExercise(account, date, name, exerciseType string, duration int, intensity string, calories int, notes string) string {
-	item, err := exercise.AddExercise(account, date, name, exerciseType, duration, intensity, calories, notes, 0, nil)
+func RawAddExercise(ctx context.Context, account, date, name, exerciseType string, duration int, intensity string, calories int, notes string) string {
+	item, err := exercise.AddExercise(ctx, account, date, name, exerciseType, duration, intensity, calories, notes, 0, nil)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -131,8 +132,8 @@ func RawAddExercise(account, date, name, exerciseType string, duration int, inte
 }
 
 // RawToggleExercise 切换运动记录完成状态
-func RawToggleExercise(account, date, id string) string {
-	err := exercise.ToggleExercise(account, date, id)
+func RawToggleExercise(ctx context.Context, account, date, id string) string {
+	err := exercise.ToggleExercise(ctx, account, date, id)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -140,8 +141,8 @@ func RawToggleExercise(account, date, id string) string {
 }
 
 // RawDeleteExercise 删除运动记录
-func RawDeleteExercise(account, date, id string) string {
-	err := exercise.DeleteExercise(account, date, id)
+func RawDeleteExercise(ctx context.Context, account, date, id string) string {
+	err := exercise.DeleteExercise(ctx, account, date, id)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -149,8 +150,8 @@ func RawDeleteExercise(account, date, id string) string {
 }
 
 // RawUpdateExercise 修改运动记录
-func RawUpdateExercise(account, date, id, name, exerciseType string, duration int, intensity string, calories int, notes string) string {
-	err := exercise.UpdateExercise(account, date, id, name, exerciseType, duration, intensity, calories, notes, 0, nil)
+func RawUpdateExercise(ctx context.Context, account, date, id, name, exerciseType string, duration int, intensity string, calories int, notes string) string {
+	err := exercise.UpdateExercise(ctx, account, date, id, name, exerciseType, duration, intensity, calories, notes, 0, nil)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -286,8 +287,8 @@ func RawGetReadingStats(account string) string {
 }
 
 // RawUpdateReadingProgress 更新阅读进度
-func RawUpdateReadingProgress(account, bookID string, currentPage int, notes string) string {
-	err := reading.UpdateReadingProgressWithAccount(account, bookID, currentPage, notes)
+func RawUpdateReadingProgress(ctx context.Context, account, bookID string, currentPage int, notes string) string {
+	err := reading.UpdateReadingProgressWithContext(ctx, account, bookID, currentPage, notes)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -295,7 +296,7 @@ func RawUpdateReadingProgress(account, bookID string, currentPage int, notes str
 }
 
 // RawAddBook 添加书籍
-func RawAddBook(account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl string, totalPages int, category, tags string) string {
+func RawAddBook(ctx context.Context, account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl string, totalPages int, category, tags string) string {
 	var cats []string
 	if category != "" {
 		for _, c := range splitAndTrim(category) {
@@ -313,7 +314,7 @@ func RawAddBook(account, title, author, isbn, publisher, publishDate, coverUrl,
 		}
 	}
 
-	book, err := reading.AddBookWithAccount(account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl, totalPages, cats, tagList)
+	book, err := reading.AddBookWithContext(ctx, account, title, author, isbn, publisher, publishDate, coverUrl, description, sourceUrl, totalPages, cats, tagList)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -419,7 +420,7 @@ func RawUpdateYearTask(account string, year, month int, taskID, status string) s
 
 // =================================== ProjectMgmt Raw 接口 =========================================
 
-func RawCreateProject(account, name, description, status, priority, owner, startDate, endDate, tags string) string {
+func RawCreateProject(ctx context.Context, account, name, description, status, priority, owner, startDate, endDate, tags string) string {
 	project := &projectmgmt.Project{
 		Name:        name,
 		Description: description,
@@ -430,7 +431,7 @@ func RawCreateProject(account, name, description, status, priority, owner, start
 		EndDate:     endDate,
 		Tags:        splitAndTrim(tags),
 	}
-	created, err := projectmgmt.CreateProjectWithAccount(account, project)
+	created, err := projectmgmt.CreateProjectWithAccount(ctx, account, project)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -456,7 +457,7 @@ func RawListProjects(account, status string) string {
 	return string(data)
 }
 
-func RawUpdateProject(account, projectID, name, description, status, priority, owner, startDate, endDate, tags string) string {
+func RawUpdateProject(ctx context.Context, account, projectID, name, description, status, priority, owner, startDate, endDate, tags string) string {
 	project, err := projectmgmt.GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
@@ -477,7 +478,7 @@ func RawUpdateProject(account, projectID, name, description, status, priority, o
 	project.StartDate = startDate
 	project.EndDate = endDate
 	project.Tags = splitAndTrim(tags)
-	if err := projectmgmt.UpdateProjectWithAccount(account, project); err != nil {
+	if err := projectmgmt.UpdateProjectWithAccount(ctx, account, project); err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
 	updated, _ := projectmgmt.GetProjectWithAccount(account, projectID)
@@ -485,14 +486,14 @@ func RawUpdateProject(account, projectID, name, description, status, priority, o
 	return string(data)
 }
 
-func RawDeleteProject(account, projectID string) string {
-	if err := projectmgmt.DeleteProjectWithAccount(account, projectID); err != nil {
+func RawDeleteProject(ctx context.Context, account, projectID string) string {
+	if err := projectmgmt.DeleteProjectWithAccount(ctx, account, projectID); err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
 	return `{"success": true}`
 }
 
-func RawAddProjectGoal(account, projectID, title, description, status, priority, startDate, endDate string, progress int) string {
+func RawAddProjectGoal(ctx context.Context, account, projectID, title, description, status, priority, startDate, endDate string, progress int) string {
 	goal := projectmgmt.Goal{
 		Title:       title,
 		Description: description,
@@ -502,7 +503,7 @@ func RawAddProjectGoal(account, projectID, title, description, status, priority,
 		StartDate:   startDate,
 		EndDate:     endDate,
 	}
-	created, err := projectmgmt.AddGoalWithAccount(account, projectID, goal)
+	created, err := projectmgmt.AddGoalWithAccount(ctx, account, projectID, goal)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -510,7 +511,7 @@ func RawAddProjectGoal(account, projectID, title, description, status, priority,
 	return string(data)
 }
 
-func RawUpdateProjectGoal(account, projectID, goalID, title, description, status, priority, startDate, endDate string, progress int) string {
+func RawUpdateProjectGoal(ctx context.Context, account, projectID, goalID, title, description, status, priority, startDate, endDate string, progress int) string {
 	project, err := projectmgmt.GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
@@ -524,7 +525,7 @@ func RawUpdateProjectGoal(account, projectID, goalID, title, description, status
 			goal.Progress = progress
 			goal.StartDate = startDate
 			goal.EndDate = endDate
-			if err := projectmgmt.UpdateGoalWithAccount(account, projectID, goal); err != nil {
+			if err := projectmgmt.UpdateGoalWithAccount(ctx, account, projectID, goal); err != nil {
 				return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 			}
 			data, _ := json.Marshal(goal)
@@ -534,21 +535,21 @@ func RawUpdateProjectGoal(account, projectID, goalID, title, description, status
 	return fmt.Sprintf(`{"error": "goal not found: %s"}`, goalID)
 }
 
-func RawDeleteProjectGoal(account, projectID, goalID string) string {
-	if err := projectmgmt.DeleteGoalWithAccount(account, projectID, goalID); err != nil {
+func RawDeleteProjectGoal(ctx context.Context, account, projectID, goalID string) string {
+	if err := projectmgmt.DeleteGoalWithAccount(ctx, account, projectID, goalID); err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
 	return `{"success": true}`
 }
 
-func RawAddProjectOKR(account, projectID, objective, status, period string, progress int) string {
+func RawAddProjectOKR(ctx context.Context, account, projectID, objective, status, period string, progress int) string {
 	okr := projectmgmt.OKR{
 		Objective: objective,
 		Status:    status,
 		Period:    period,
 		Progress:  progress,
 	}
-	created, err := projectmgmt.AddOKRWithAccount(account, projectID, okr)
+	created, err := projectmgmt.AddOKRWithAccount(ctx, account, projectID, okr)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
@@ -556,7 +557,7 @@ func RawAddProjectOKR(account, projectID, objective, status, period string, prog
 	return string(data)
 }
 
-func RawUpdateProjectOKR(account, projectID, okrID, objective, status, period string, progress int) string {
+func RawUpdateProjectOKR(ctx context.Context, account, projectID, okrID, objective, status, period string, progress int) string {
 	project, err := projectmgmt.GetProjectWithAccount(account, projectID)
 	if err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
@@ -567,7 +568,7 @@ func RawUpdateProjectOKR(account, projectID, okrID, objective, status, period st
 			okr.Status = status
 			okr.Period = period
 			okr.Progress = progress
-			if err := projectmgmt.UpdateOKRWithAccount(account, projectID, okr); err != nil {
+			if err := projectmgmt.UpdateOKRWithAccount(ctx, account, projectID, okr); err != nil {
 				return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 			}
 			data, _ := json.Marshal(okr)
@@ -577,14 +578,14 @@ func RawUpdateProjectOKR(account, projectID, okrID, objective, status, period st
 	return fmt.Sprintf(`{"error": "okr not found: %s"}`, okrID)
 }
 
-func RawDeleteProjectOKR(account, projectID, okrID string) string {
-	if err := projectmgmt.DeleteOKRWithAccount(account, projectID, okrID); err != nil {
+func RawDeleteProjectOKR(ctx context.Context, account, projectID, okrID string) string {
+	if err := projectmgmt.DeleteOKRWithAccount(ctx, account, projectID, okrID); err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
 	return `{"success": true}`
 }
 
-func RawUpdateProjectKeyResult(account, projectID, okrID, keyResultID, title, metricType string, targetValue, currentValue float64, unit, status string) string {
+func RawUpdateProjectKeyResult(ctx context.Context, account, projectID, okrID, keyResultID, title, metricType string, targetValue, currentValue float64, unit, status string) string {
 	kr := projectmgmt.KeyResult{
 		ID:           keyResultID,
 		Title:        title,
@@ -594,7 +595,7 @@ func RawUpdateProjectKeyResult(account, projectID, okrID, keyResultID, title, me
 		Unit:         unit,
 		Status:       status,
 	}
-	if err := projectmgmt.UpdateKeyResultWithAccount(account, projectID, okrID, kr); err != nil {
+	if err := projectmgmt.UpdateKeyResultWithAccount(ctx, account, projectID, okrID, kr); err != nil {
 		return fmt.Sprintf(`{"error": "%s"}`, err.Error())
 	}
 	project, err := projectmgmt.GetProjectWithAccount(account, projectID)
@@ -3,10 +3,12 @@ package statistics
 import (
 	"blog"
 	"comment"
+	"context"
 	"exercise"
 	"fmt"
 	"module"
 	log "mylog"
+	"search"
 	"sort"
 	"strings"
 	"time"
@@ -437,52 +439,18 @@ func RawMonthlyCreationTrend(account string) string {
 
 // 搜索博客内容（返回标题+关键词上下文片段，避免返回全文导致数据量过大）
 func RawSearchBlogContent(account, keyword string) string {
-	blogs := blog.GetBlogsWithAccount(account)
 	const maxResults = 20
-	const snippetLen = 200
-
-	var results []string
-	lowerKeyword := strings.ToLower(keyword)
-
-	for _, b := range blogs {
-		lowerContent := strings.ToLower(b.Content)
-		lowerTitle := strings.ToLower(b.Title)
-
-		if !strings.Contains(lowerContent, lowerKeyword) && !strings.Contains(lowerTitle, lowerKeyword) {
-			continue
-		}
-
-		// 提取关键词周围的上下文片段
-		snippet := ""
-		idx := strings.Index(lowerContent, lowerKeyword)
-		if idx >= 0 {
-			start := idx - snippetLen/2
-			if start < 0 {
-				start = 0
-			}
-			end := idx + len(keyword) + snippetLen/2
-			if end > len(b.Content) {
-				end = len(b.Content)
-			}
-			snippet = b.Content[start:end]
-			if start > 0 {
-				snippet = "..." + snippet
-			}
-			if end < len(b.Content) {
-				snippet = snippet + "..."
-			}
-		}
-
-		entry := fmt.Sprintf("【%s】\n%s", b.Title, snippet)
-		results = append(results, entry)
 
-		if len(results) >= maxResults {
-			break
-		}
+	// 全文索引检索，按相关度排序，命中部分用 ** 标出
+	hits := search.SearchHits(account, keyword, maxResults)
+	if len(hits) == 0 {
+		return fmt.Sprintf("未找到包含关键词'%s'的博客", keyword)
 	}
 
-	if len(results) == 0 {
-		return fmt.Sprintf("未找到包含关键词'%s'的博客", keyword)
+	results := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		entry := fmt.Sprintf("【%s】\n%s", hit.Blog.Title, search.SnippetText(hit.Snippet))
+		results = append(results, entry)
 	}
 
 	header := fmt.Sprintf("找到 %d 篇包含'%s'的博客（最多显示%d篇）:\n\n", len(results), keyword, maxResults)
@@ -591,7 +559,7 @@ func RawCurrentDiaryContent(account string) string {
 }
 
 // 创建博客
-func RawCreateBlog(account, title, content, tags string, authType int, encrypt int) string {
+func RawCreateBlog(ctx context.Context, account, title, content, tags string, authType int, encrypt int) string {
 	udb := &module.UploadedBlogData{
 		Title:    title,
 		Content:  content,
@@ -600,7 +568,7 @@ func RawCreateBlog(account, title, content, tags string, authType int, encrypt i
 		Encrypt:  encrypt,
 		Account:  account,
 	}
-	ret := blog.AddBlogWithAccount(account, udb)
+	ret := blog.AddBlogWithContext(ctx, account, udb)
 	if ret == 0 {
 		return "Success"
 	} else if ret == 1 {
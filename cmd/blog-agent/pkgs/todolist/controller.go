@@ -118,7 +118,7 @@ func (c *Controller) HandleAddTodo(w http.ResponseWriter, r *http.Request) {
         date = time.Now().Format("2006-01-02")
     }
 
-    todo, err := c.manager.AddTodo(account, date, request.Content, request.Hours, request.Minutes, request.Urgency, request.Importance)
+    todo, err := c.manager.AddTodo(r.Context(), account, date, request.Content, request.Hours, request.Minutes, request.Urgency, request.Importance)
     if err != nil {
         w.WriteHeader(http.StatusInternalServerError)
         json.NewEncoder(w).Encode(map[string]string{
@@ -152,7 +152,7 @@ func (c *Controller) HandleDeleteTodo(w http.ResponseWriter, r *http.Request) {
         date = time.Now().Format("2006-01-02")
     }
 
-    if err := c.manager.DeleteTodo(account, date, id); err != nil {
+    if err := c.manager.DeleteTodo(r.Context(), account, date, id); err != nil {
         w.WriteHeader(http.StatusInternalServerError)
         json.NewEncoder(w).Encode(map[string]string{
             "error": "Failed to delete todo: " + err.Error(),
@@ -188,7 +188,7 @@ func (c *Controller) HandleToggleTodo(w http.ResponseWriter, r *http.Request) {
         date = time.Now().Format("2006-01-02")
     }
 
-    if err := c.manager.ToggleTodo(account, date, id); err != nil {
+    if err := c.manager.ToggleTodo(r.Context(), account, date, id); err != nil {
         w.WriteHeader(http.StatusInternalServerError)
         json.NewEncoder(w).Encode(map[string]string{
             "error": "Failed to toggle todo: " + err.Error(),
@@ -267,7 +267,7 @@ func (c *Controller) HandleUpdateTodoTime(w http.ResponseWriter, r *http.Request
         return
     }
     
-    if err := c.manager.UpdateTodoTime(account, date, id, request.Hours, request.Minutes); err != nil {
+    if err := c.manager.UpdateTodoTime(r.Context(), account, date, id, request.Hours, request.Minutes); err != nil {
         w.WriteHeader(http.StatusInternalServerError)
         json.NewEncoder(w).Encode(map[string]string{
             "error": "Failed to update todo time: " + err.Error(),
@@ -371,7 +371,7 @@ func (c *Controller) HandleUpdateTodoOrder(w http.ResponseWriter, r *http.Reques
     }
     
     account := getAccountFromRequest(r)
-    if err := c.manager.UpdateTodoOrder(account, request.Date, request.Order); err != nil {
+    if err := c.manager.UpdateTodoOrder(r.Context(), account, request.Date, request.Order); err != nil {
         w.WriteHeader(http.StatusInternalServerError)
         json.NewEncoder(w).Encode(map[string]string{
             "error": "Failed to update todo order: " + err.Error(),
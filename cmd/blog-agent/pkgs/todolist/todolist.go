@@ -2,6 +2,7 @@ package todolist
 
 import (
 	"blog"
+	"context"
 	"encoding/json"
 	"fmt"
 	"module"
@@ -53,7 +54,7 @@ func getDateFromTitle(title string) string {
 }
 
 // AddTodo adds a new todo item to a specific date's list
-func (tm *TodoManager) AddTodo(account, date, content string, hours, minutes, urgency, importance int) (*TodoItem, error) {
+func (tm *TodoManager) AddTodo(ctx context.Context, account, date, content string, hours, minutes, urgency, importance int) (*TodoItem, error) {
 	// Get or create todo list for the date
 	todoList, err := tm.GetTodosByDate(account, date)
 	if err != nil {
@@ -80,7 +81,7 @@ func (tm *TodoManager) AddTodo(account, date, content string, hours, minutes, ur
 	todoList.Items = append(todoList.Items, item)
 
 	// Save to blog
-	if err := tm.saveTodosToBlog(account, todoList); err != nil {
+	if err := tm.saveTodosToBlog(ctx, account, todoList); err != nil {
 		return nil, err
 	}
 
@@ -88,7 +89,7 @@ func (tm *TodoManager) AddTodo(account, date, content string, hours, minutes, ur
 }
 
 // DeleteTodo removes a todo item by ID
-func (tm *TodoManager) DeleteTodo(account, date, id string) error {
+func (tm *TodoManager) DeleteTodo(ctx context.Context, account, date, id string) error {
 	// Get todo list for the date
 	todoList, err := tm.GetTodosByDate(account, date)
 	if err != nil {
@@ -114,11 +115,11 @@ func (tm *TodoManager) DeleteTodo(account, date, id string) error {
 	todoList.Items = updatedItems
 
 	// Save to blog
-	return tm.saveTodosToBlog(account, todoList)
+	return tm.saveTodosToBlog(ctx, account, todoList)
 }
 
 // ToggleTodo toggles the completion status of a todo item
-func (tm *TodoManager) ToggleTodo(account, date, id string) error {
+func (tm *TodoManager) ToggleTodo(ctx context.Context, account, date, id string) error {
 	// Get todo list for the date
 	todoList, err := tm.GetTodosByDate(account, date)
 	if err != nil {
@@ -140,11 +141,11 @@ func (tm *TodoManager) ToggleTodo(account, date, id string) error {
 	}
 
 	// Save to blog
-	return tm.saveTodosToBlog(account, todoList)
+	return tm.saveTodosToBlog(ctx, account, todoList)
 }
 
 // UpdateTodoTime updates the time spent on a todo item
-func (tm *TodoManager) UpdateTodoTime(account, date, id string, hours, minutes int) error {
+func (tm *TodoManager) UpdateTodoTime(ctx context.Context, account, date, id string, hours, minutes int) error {
 	// Get todo list for the date
 	todoList, err := tm.GetTodosByDate(account, date)
 	if err != nil {
@@ -174,7 +175,7 @@ func (tm *TodoManager) UpdateTodoTime(account, date, id string, hours, minutes i
 	}
 
 	// Save to blog
-	return tm.saveTodosToBlog(account, todoList)
+	return tm.saveTodosToBlog(ctx, account, todoList)
 }
 
 // GetTodosByDate retrieves the todo list for a specific date
@@ -241,8 +242,8 @@ func ParseTodoListFromBlog(content string) TodoList {
 	return todoList
 }
 
-// saveTodosToBlog saves a TodoList as a blog post
-func (tm *TodoManager) saveTodosToBlog(account string, todoList TodoList) error {
+// saveTodosToBlog saves a TodoList as a blog post; ctx carries the revision source
+func (tm *TodoManager) saveTodosToBlog(ctx context.Context, account string, todoList TodoList) error {
 	title := generateBlogTitle(todoList.Date)
 
 	// Convert to JSON
@@ -262,7 +263,7 @@ func (tm *TodoManager) saveTodosToBlog(account string, todoList TodoList) error
 			AuthType: module.EAuthType_private,
 			Account:  account,
 		}
-		blog.AddBlogWithAccount(account, ubd)
+		blog.AddBlogWithContext(ctx, account, ubd)
 	} else {
 		// Update existing blog using UploadedBlogData
 		ubd := &module.UploadedBlogData{
@@ -272,14 +273,14 @@ func (tm *TodoManager) saveTodosToBlog(account string, todoList TodoList) error
 			AuthType: module.EAuthType_private,
 			Account:  account,
 		}
-		blog.ModifyBlogWithAccount(account, ubd)
+		blog.ModifyBlogWithContext(ctx, account, ubd)
 	}
 
 	return nil
 }
 
 // UpdateTodoOrder updates the order of todo items for a specific date
-func (tm *TodoManager) UpdateTodoOrder(account, date string, order []string) error {
+func (tm *TodoManager) UpdateTodoOrder(ctx context.Context, account, date string, order []string) error {
 	// Get todo list for the date
 	todoList, err := tm.GetTodosByDate(account, date)
 	if err != nil {
@@ -303,7 +304,7 @@ func (tm *TodoManager) UpdateTodoOrder(account, date string, order []string) err
 	todoList.Order = order
 
 	// Save to blog
-	return tm.saveTodosToBlog(account, todoList)
+	return tm.saveTodosToBlog(ctx, account, todoList)
 }
 
 // calculateScore calculates the priority score for a todo item
@@ -57,6 +57,7 @@ type LinkData struct {
 	TAGS         []string
 	IS_ENCRYPTED bool
 	IS_DIARY     bool
+	SNIPPET      []search.SnippetPart // 全文搜索命中摘要
 }
 
 type TagInfo struct {
@@ -357,9 +358,24 @@ func parseAuthTypeToEditorData(authType int, encrypt int) (string, bool, bool, b
 func PageSearch(match string, w h.ResponseWriter, session string) {
 
 	account := blog.GetAccountFromSession(session)
-	blogs := control.GetMatch(account, match)
+
+	// 普通搜索走全文索引，附带命中摘要
+	var blogs []*module.Blog
+	snippets := make(map[string][]search.SnippetPart)
+	if !strings.HasPrefix(match, "@") {
+		for _, hit := range control.SearchHits(account, match, 0) {
+			blogs = append(blogs, hit.Blog)
+			snippets[hit.Blog.Title] = hit.Snippet
+		}
+	}
+	if len(blogs) == 0 {
+		blogs = control.GetMatch(account, match)
+	}
 	flag := module.EAuthType_all
 	datas := getLinks(blogs, flag, account)
+	for i := range datas.LINKS {
+		datas.LINKS[i].SNIPPET = snippets[datas.LINKS[i].DESC]
+	}
 
 	// 为搜索结果中的所有链接添加highlight参数
 	for i := range datas.LINKS {
@@ -1265,4 +1265,141 @@ function fallbackCopyTextToClipboard(text) {
 
 	document.body.removeChild(textArea);
 }
-		
\ No newline at end of file
+		
+// ========== 修订历史 ==========
+const revisionSourceNames = {
+	'web': '网页',
+	'wechat': '微信',
+	'llm-tool': 'AI工具',
+	'restore': '恢复',
+	'system': '系统'
+};
+
+function onHistory() {
+	const title = document.getElementById('title').textContent.trim();
+	if (!title) {
+		showToast('无法获取博客标题', 'error');
+		return;
+	}
+
+	fetch('/api/blog/revisions?title=' + encodeURIComponent(title))
+		.then(response => {
+			if (!response.ok) {
+				throw new Error(`HTTP error! status: ${response.status}`);
+			}
+			return response.json();
+		})
+		.then(data => showHistoryPanel(title, data.revisions || []))
+		.catch(error => {
+			console.error('Error:', error);
+			showToast('获取修订历史失败', 'error');
+		});
+}
+
+function showHistoryPanel(title, revisions) {
+	closeHistoryPanel();
+
+	const overlay = document.createElement('div');
+	overlay.id = 'history-overlay';
+	overlay.style.cssText = 'position:fixed;inset:0;background:rgba(0,0,0,0.6);z-index:10000;display:flex;align-items:center;justify-content:center;';
+	overlay.addEventListener('click', function(e) {
+		if (e.target === overlay) closeHistoryPanel();
+	});
+
+	const panel = document.createElement('div');
+	panel.style.cssText = 'background:#1e1e1e;color:#e0e0e0;width:90%;max-width:900px;max-height:85vh;overflow:auto;border-radius:8px;padding:16px;font-size:14px;';
+
+	const header = document.createElement('div');
+	header.style.cssText = 'display:flex;justify-content:space-between;align-items:center;margin-bottom:12px;';
+	header.innerHTML = '<strong>🕘 修订历史</strong>';
+	const closeBtn = document.createElement('button');
+	closeBtn.textContent = '✕';
+	closeBtn.onclick = closeHistoryPanel;
+	header.appendChild(closeBtn);
+	panel.appendChild(header);
+
+	if (revisions.length === 0) {
+		panel.appendChild(document.createTextNode('暂无修订记录'));
+	}
+
+	revisions.forEach(rev => {
+		const row = document.createElement('div');
+		row.style.cssText = 'display:flex;justify-content:space-between;align-items:center;padding:6px 0;border-bottom:1px solid #333;gap:8px;';
+
+		const info = document.createElement('span');
+		const source = revisionSourceNames[rev.source] || rev.source;
+		info.textContent = `#${rev.seq}  ${rev.time}  ${rev.action}  ${source}` +
+			(rev.author ? ` · ${rev.author}` : '') +
+			(rev.note ? ` · ${rev.note}` : '') +
+			`  (${rev.size} 字节)`;
+		row.appendChild(info);
+
+		const actions = document.createElement('span');
+		const diffBtn = document.createElement('button');
+		diffBtn.textContent = '与当前对比';
+		diffBtn.onclick = () => showRevisionDiff(title, rev.seq);
+		const restoreBtn = document.createElement('button');
+		restoreBtn.textContent = '恢复';
+		restoreBtn.style.marginLeft = '6px';
+		restoreBtn.onclick = () => restoreRevision(title, rev.seq);
+		actions.appendChild(diffBtn);
+		actions.appendChild(restoreBtn);
+		row.appendChild(actions);
+
+		panel.appendChild(row);
+	});
+
+	const diffBox = document.createElement('pre');
+	diffBox.id = 'history-diff';
+	diffBox.style.cssText = 'white-space:pre-wrap;margin-top:12px;font-family:monospace;font-size:12px;';
+	panel.appendChild(diffBox);
+
+	overlay.appendChild(panel);
+	document.body.appendChild(overlay);
+}
+
+function closeHistoryPanel() {
+	const overlay = document.getElementById('history-overlay');
+	if (overlay) overlay.remove();
+}
+
+function showRevisionDiff(title, seq) {
+	fetch(`/api/blog/revision/diff?format=unified&title=${encodeURIComponent(title)}&from=${seq}`)
+		.then(response => response.text())
+		.then(text => {
+			const box = document.getElementById('history-diff');
+			box.innerHTML = '';
+			text.split('\n').forEach(line => {
+				const span = document.createElement('span');
+				span.textContent = line + '\n';
+				if (line.startsWith('+') && !line.startsWith('+++')) span.style.color = '#6a9955';
+				if (line.startsWith('-') && !line.startsWith('---')) span.style.color = '#f14c4c';
+				box.appendChild(span);
+			});
+		})
+		.catch(() => showToast('获取差异失败', 'error'));
+}
+
+function restoreRevision(title, seq) {
+	if (!confirm(`确定将博客恢复到修订 #${seq} 吗？当前内容会保留在历史中。`)) {
+		return;
+	}
+	fetch('/api/blog/revision/restore', {
+		method: 'POST',
+		headers: {
+			'Content-Type': 'application/x-www-form-urlencoded',
+		},
+		body: `title=${encodeURIComponent(title)}&seq=${seq}`
+	})
+		.then(response => {
+			if (!response.ok) {
+				throw new Error(`HTTP error! status: ${response.status}`);
+			}
+			return response.json();
+		})
+		.then(() => {
+			showToast('已恢复', 'success');
+			setTimeout(() => window.location.reload(), 800);
+		})
+		.catch(() => showToast('恢复失败', 'error'));
+}
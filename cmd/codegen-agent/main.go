@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -70,14 +69,6 @@ func main() {
 		gatewayHTTP := envCfg.GatewayHTTP
 		catalog := agentbase.NewToolCatalog(gatewayHTTP)
 		rc := agentbase.NewRemoteCaller(conn.AgentBase, catalog)
-		// 注册 MsgToolResult handler 给 RemoteCaller
-		conn.RegisterHandler(uap.MsgToolResult, func(msg *uap.Message) {
-			var payload uap.ToolResultPayload
-			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-				return
-			}
-			rc.DispatchToolResult(&payload)
-		})
 		go agentbase.NewEnvChecker(conn.AgentBase, catalog, rc, envCfg, nil).Run()
 	}
 
@@ -47,6 +47,7 @@ type AgentBase struct {
 	ActiveTaskCounter func() int                          // 返回活跃任务数（drain 轮询用）
 	OnShutdown        func()                              // shutdown 时的自定义回调（如通知业务层停止接收）
 	OnToolCancel      func(toolName string, msgID string) // tool_cancel 回调（agent 自行实现取消逻辑）
+	StatusMeta        func() map[string]any               // ctrl_status 报告附带的扩展状态（如下游服务健康度）
 
 	// 消息处理器注册表
 	handlers  map[string]MessageHandler
@@ -131,7 +132,7 @@ func NewAgentBase(cfg *Config) *AgentBase {
 	ab.handlers[uap.MsgDescribe] = ab.handleDescribe
 
 	// 内置注册 tool_cancel 处理器
-	ab.handlers["tool_cancel"] = ab.handleToolCancel
+	ab.handlers[uap.MsgToolCancel] = ab.handleToolCancel
 
 	return ab
 }
@@ -332,6 +333,11 @@ func (ab *AgentBase) handleCtrlStatus(msg *uap.Message) {
 
 	uptime := int64(time.Since(ab.startTime).Seconds())
 
+	var meta map[string]any
+	if ab.StatusMeta != nil {
+		meta = ab.StatusMeta()
+	}
+
 	log.Printf("[AgentBase] received ctrl_status from=%s, reporting state=%s tasks=%d",
 		msg.From, ab.lifecycle.State(), activeTasks)
 
@@ -343,6 +349,7 @@ func (ab *AgentBase) handleCtrlStatus(msg *uap.Message) {
 		ActiveTasks: activeTasks,
 		Capacity:    ab.Capacity,
 		Uptime:      uptime,
+		Meta:        meta,
 	})
 }
 
@@ -365,14 +372,13 @@ func (ab *AgentBase) handleDescribe(msg *uap.Message) {
 
 // handleToolCancel 处理工具取消请求
 func (ab *AgentBase) handleToolCancel(msg *uap.Message) {
-	var payload map[string]interface{}
+	var payload uap.ToolCancelPayload
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 		log.Printf("[AgentBase] invalid tool_cancel payload: %v", err)
 		return
 	}
 
-	toolName, _ := payload["tool_name"].(string)
-	msgID, _ := payload["original_msg_id"].(string)
+	toolName, msgID := payload.ToolName, payload.OriginalMsgID
 	log.Printf("[AgentBase] tool_cancel from=%s tool=%s msgID=%s", msg.From, toolName, msgID)
 
 	// 调用 agent 自定义的取消回调
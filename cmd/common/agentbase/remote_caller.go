@@ -1,25 +1,22 @@
 package agentbase
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
-	"sync"
 	"time"
 
 	"uap"
 )
 
 // RemoteCaller 远程工具调用组件
-// 封装 pending channel 模式，提供请求-响应关联能力
-// 不自动注册 handler，由各 agent 在自己的 handler 中调用 DispatchToolResult/DispatchError
+// 基于 uap.Client.Call 完成请求-响应关联，tool_result / error 由客户端直接投递，无需各 agent 注册 handler
 type RemoteCaller struct {
 	ab      *AgentBase
 	catalog *ToolCatalog
-
-	pending map[string]chan *uap.ToolResultPayload
-	pendMu  sync.Mutex
 }
 
 // NewRemoteCaller 创建远程调用组件
@@ -27,59 +24,50 @@ func NewRemoteCaller(ab *AgentBase, catalog *ToolCatalog) *RemoteCaller {
 	return &RemoteCaller{
 		ab:      ab,
 		catalog: catalog,
-		pending: make(map[string]chan *uap.ToolResultPayload),
 	}
 }
 
 // CallTool 发送工具调用并等待响应
 func (rc *RemoteCaller) CallTool(toolName string, args json.RawMessage, timeout time.Duration) (result string, agentID string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return rc.CallToolContext(ctx, toolName, args)
+}
+
+// CallToolContext 发送工具调用并等待响应，ctx 取消时通知远端 agent 取消
+func (rc *RemoteCaller) CallToolContext(ctx context.Context, toolName string, args json.RawMessage) (result string, agentID string, err error) {
 	agentID, ok := rc.catalog.GetAgentID(toolName)
 	if !ok {
 		return "", "", fmt.Errorf("tool %s not found in catalog", toolName)
 	}
 
-	msgID := uap.NewMsgID()
-	ch := make(chan *uap.ToolResultPayload, 1)
-
-	rc.pendMu.Lock()
-	rc.pending[msgID] = ch
-	rc.pendMu.Unlock()
-
-	defer func() {
-		rc.pendMu.Lock()
-		delete(rc.pending, msgID)
-		rc.pendMu.Unlock()
-	}()
-
 	log.Printf("[RemoteCaller] tool_call → agent=%s tool=%s", agentID, toolName)
 
-	// 发送 tool_call
-	sendErr := rc.ab.Client.Send(&uap.Message{
-		Type: uap.MsgToolCall,
-		ID:   msgID,
-		From: rc.ab.AgentID,
-		To:   agentID,
-		Payload: mustMarshal(uap.ToolCallPayload{
-			ToolName:  toolName,
-			Arguments: args,
-		}),
-		Ts: time.Now().UnixMilli(),
+	reply, err := rc.ab.Client.Call(ctx, agentID, uap.MsgToolCall, uap.ToolCallPayload{
+		ToolName:  toolName,
+		Arguments: args,
 	})
-	if sendErr != nil {
-		return "", agentID, fmt.Errorf("send tool_call: %v", sendErr)
+	if err != nil {
+		var callErr *uap.CallError
+		switch {
+		case errors.As(err, &callErr):
+			return "", agentID, fmt.Errorf("tool error: %s", callErr.Message)
+		case errors.Is(err, context.DeadlineExceeded):
+			return "", agentID, fmt.Errorf("tool %s timeout: %v", toolName, err)
+		default:
+			return "", agentID, err
+		}
 	}
 
-	// 等待结果
-	select {
-	case res := <-ch:
-		if !res.Success {
-			return "", agentID, fmt.Errorf("tool error: %s", res.Error)
-		}
-		log.Printf("[RemoteCaller] tool_result ← agent=%s tool=%s resultLen=%d", agentID, toolName, len(res.Result))
-		return res.Result, agentID, nil
-	case <-time.After(timeout):
-		return "", agentID, fmt.Errorf("tool %s timeout after %v", toolName, timeout)
+	var res uap.ToolResultPayload
+	if err := json.Unmarshal(reply.Payload, &res); err != nil {
+		return "", agentID, fmt.Errorf("invalid tool_result payload: %v", err)
 	}
+	if !res.Success {
+		return "", agentID, fmt.Errorf("tool error: %s", res.Error)
+	}
+	log.Printf("[RemoteCaller] tool_result ← agent=%s tool=%s resultLen=%d", agentID, toolName, len(res.Result))
+	return res.Result, agentID, nil
 }
 
 // CallToolWithRetry 带瞬态错误重试的工具调用（重试一次）
@@ -93,37 +81,6 @@ func (rc *RemoteCaller) CallToolWithRetry(toolName string, args json.RawMessage,
 	return result, agentID, err
 }
 
-// DispatchToolResult 分发 tool_result 到 pending channel
-// 返回 true 表示匹配到了 pending 请求
-func (rc *RemoteCaller) DispatchToolResult(payload *uap.ToolResultPayload) bool {
-	rc.pendMu.Lock()
-	ch, ok := rc.pending[payload.RequestID]
-	rc.pendMu.Unlock()
-	if ok {
-		ch <- payload
-		return true
-	}
-	return false
-}
-
-// DispatchError 分发 error 到 pending channel
-// gateway 使用原始 msg.ID 作为错误消息的 ID
-// 返回 true 表示匹配到了 pending 请求
-func (rc *RemoteCaller) DispatchError(msgID, errMsg string) bool {
-	rc.pendMu.Lock()
-	ch, ok := rc.pending[msgID]
-	rc.pendMu.Unlock()
-	if ok {
-		ch <- &uap.ToolResultPayload{
-			RequestID: msgID,
-			Success:   false,
-			Error:     errMsg,
-		}
-		return true
-	}
-	return false
-}
-
 // isTransientError 判断是否是瞬态网络错误（值得重试）
 func isTransientError(err error) bool {
 	msg := err.Error()
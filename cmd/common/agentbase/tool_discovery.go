@@ -17,11 +17,22 @@ type ToolCatalog struct {
 
 	// 工具目录: tool_name → agent_id
 	catalog map[string]string
+	tools   []CatalogTool // 最近一次发现的完整工具定义
 	mu      sync.RWMutex
 
 	stopCh chan struct{}
 }
 
+// CatalogTool gateway /api/gateway/tools 返回的单个工具
+type CatalogTool struct {
+	AgentID     string          `json:"agent_id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+var discoverClient = &http.Client{Timeout: 10 * time.Second}
+
 // NewToolCatalog 创建工具目录管理器
 func NewToolCatalog(gatewayHTTP string) *ToolCatalog {
 	return &ToolCatalog{
@@ -36,7 +47,7 @@ func NewToolCatalog(gatewayHTTP string) *ToolCatalog {
 func (tc *ToolCatalog) Discover(excludeAgentID string) error {
 	url := fmt.Sprintf("%s/api/gateway/tools", tc.gatewayHTTP)
 
-	resp, err := http.DefaultClient.Get(url)
+	resp, err := discoverClient.Get(url)
 	if err != nil {
 		return fmt.Errorf("GET %s: %v", url, err)
 	}
@@ -56,11 +67,9 @@ func (tc *ToolCatalog) Discover(excludeAgentID string) error {
 	}
 
 	catalog := make(map[string]string)
+	tools := make([]CatalogTool, 0, len(result.Tools))
 	for _, raw := range result.Tools {
-		var tool struct {
-			AgentID string `json:"agent_id"`
-			Name    string `json:"name"`
-		}
+		var tool CatalogTool
 		if err := json.Unmarshal(raw, &tool); err != nil {
 			continue
 		}
@@ -68,6 +77,7 @@ func (tc *ToolCatalog) Discover(excludeAgentID string) error {
 		if tool.AgentID == excludeAgentID {
 			continue
 		}
+		tools = append(tools, tool)
 		catalog[tool.Name] = tool.AgentID
 		// 预注册 agentID_toolName 变体（LLM 在 ExecuteCode 中可能拼接 agentID 前缀）
 		catalog[tool.AgentID+"_"+tool.Name] = tool.AgentID
@@ -75,6 +85,7 @@ func (tc *ToolCatalog) Discover(excludeAgentID string) error {
 
 	tc.mu.Lock()
 	tc.catalog = catalog
+	tc.tools = tools
 	tc.mu.Unlock()
 
 	log.Printf("[ToolCatalog] discovered %d tools from gateway", len(catalog))
@@ -122,6 +133,16 @@ func (tc *ToolCatalog) GetAll() map[string]string {
 	return result
 }
 
+// Tools 获取最近一次发现的完整工具定义（副本）
+func (tc *ToolCatalog) Tools() []CatalogTool {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+
+	result := make([]CatalogTool, len(tc.tools))
+	copy(result, tc.tools)
+	return result
+}
+
 // Stop 停止刷新循环
 func (tc *ToolCatalog) Stop() {
 	close(tc.stopCh)
@@ -0,0 +1,307 @@
+package otlptrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ========================= OTLP/HTTP 导出 =========================
+//
+// 使用 OTLP/HTTP JSON 编码（POST <endpoint>/v1/traces），无需引入 OpenTelemetry SDK，
+// Jaeger（开启 OTLP 接收）或 otel-collector 均可直接接收。
+// Exporter 为 nil 时所有方法均为空操作，调用方无需判断是否开启。
+
+const (
+	defaultBatchSize     = 256
+	defaultFlushInterval = 2 * time.Second
+	maxQueuedSpans       = 4096
+)
+
+// Config 导出配置
+type Config struct {
+	Endpoint      string            // collector 地址，如 http://127.0.0.1:4318（为空则不导出）
+	ServiceName   string            // resource service.name
+	Headers       map[string]string // 附加请求头（如鉴权）
+	BatchSize     int               // 单次发送 span 上限（默认 256）
+	FlushInterval time.Duration     // 发送间隔（默认 2s）
+}
+
+// Exporter 异步批量导出 span
+type Exporter struct {
+	cfg    Config
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+
+	flushCh chan chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	once    sync.Once
+}
+
+// NewExporter 创建导出器并启动后台发送协程；Endpoint 为空时返回 nil
+func NewExporter(cfg Config) *Exporter {
+	endpoint := strings.TrimRight(strings.TrimSpace(cfg.Endpoint), "/")
+	if endpoint == "" {
+		return nil
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	url := endpoint
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	e := &Exporter{
+		cfg:     cfg,
+		url:     url,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		flushCh: make(chan chan struct{}),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+// Export 提交一个已结束的 span（队列满时丢弃）
+func (e *Exporter) Export(span *Span) {
+	if e == nil || span == nil || span.TraceID == "" || span.SpanID == "" {
+		return
+	}
+	e.mu.Lock()
+	if len(e.queue) >= maxQueuedSpans {
+		e.dropped++
+		e.mu.Unlock()
+		return
+	}
+	e.queue = append(e.queue, span)
+	e.mu.Unlock()
+}
+
+// Flush 立即发送队列中的 span 并等待完成
+func (e *Exporter) Flush() {
+	if e == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case e.flushCh <- done:
+		<-done
+	case <-e.doneCh:
+	}
+}
+
+// Shutdown 发送剩余 span 并停止后台协程
+func (e *Exporter) Shutdown() {
+	if e == nil {
+		return
+	}
+	e.once.Do(func() {
+		close(e.stopCh)
+		<-e.doneCh
+	})
+}
+
+func (e *Exporter) loop() {
+	defer close(e.doneCh)
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.sendAll()
+		case done := <-e.flushCh:
+			e.sendAll()
+			close(done)
+		case <-e.stopCh:
+			e.sendAll()
+			return
+		}
+	}
+}
+
+// sendAll 分批发送队列中的全部 span
+func (e *Exporter) sendAll() {
+	for {
+		e.mu.Lock()
+		if e.dropped > 0 {
+			log.Printf("[OTLP] span queue full, dropped %d spans", e.dropped)
+			e.dropped = 0
+		}
+		n := len(e.queue)
+		if n == 0 {
+			e.mu.Unlock()
+			return
+		}
+		if n > e.cfg.BatchSize {
+			n = e.cfg.BatchSize
+		}
+		batch := e.queue[:n:n]
+		e.queue = e.queue[n:]
+		e.mu.Unlock()
+
+		if err := e.send(batch); err != nil {
+			log.Printf("[OTLP] export %d spans to %s failed: %v", len(batch), e.url, err)
+			return
+		}
+	}
+}
+
+func (e *Exporter) send(spans []*Span) error {
+	body, err := json.Marshal(encodeRequest(e.cfg.ServiceName, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// ========================= OTLP JSON 编码 =========================
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 0=UNSET 1=OK 2=ERROR
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func encodeRequest(serviceName string, spans []*Span) *otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		end := s.End
+		if end.Before(s.Start) {
+			end = s.Start
+		}
+		kind := s.Kind
+		if kind == 0 {
+			kind = SpanKindInternal
+		}
+		status := otlpStatus{Code: 1}
+		if s.Error != "" {
+			status = otlpStatus{Code: 2, Message: s.Error}
+		}
+		out = append(out, otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentSpanID,
+			Name:              s.Name,
+			Kind:              kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.Attributes),
+			Status:            status,
+		})
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: encodeAttributes(map[string]any{"service.name": serviceName})},
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "go_blog/uap"}, Spans: out}},
+		}},
+	}
+}
+
+func encodeAttributes(attrs map[string]any) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	result := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		var av otlpAnyValue
+		switch val := v.(type) {
+		case string:
+			av.StringValue = &val
+		case bool:
+			av.BoolValue = &val
+		case int:
+			s := strconv.Itoa(val)
+			av.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(val, 10)
+			av.IntValue = &s
+		case float64:
+			av.DoubleValue = &val
+		default:
+			s := fmt.Sprint(val)
+			av.StringValue = &s
+		}
+		result = append(result, otlpKeyValue{Key: k, Value: av})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
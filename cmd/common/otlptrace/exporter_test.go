@@ -0,0 +1,90 @@
+package otlptrace
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExporterPostsOTLPJSON(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if r.Header.Get("Authorization") != "Bearer x" {
+			t.Errorf("missing custom header")
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		received <- req
+	}))
+	defer srv.Close()
+
+	exp := NewExporter(Config{
+		Endpoint:      srv.URL,
+		ServiceName:   "gateway",
+		Headers:       map[string]string{"Authorization": "Bearer x"},
+		FlushInterval: time.Hour,
+	})
+	defer exp.Shutdown()
+
+	start := time.Unix(100, 0)
+	exp.Export(&Span{
+		TraceID:    TraceIDFrom("msg-1"),
+		SpanID:     SpanIDFrom("msg-1"),
+		Name:       "tool_call RawSearch",
+		Kind:       SpanKindServer,
+		Start:      start,
+		End:        start.Add(time.Second),
+		Attributes: map[string]any{"uap.to": "blog-agent", "uap.attempt": 2},
+		Error:      "timeout",
+	})
+	exp.Flush()
+
+	req := <-received
+	rs := req.ResourceSpans[0]
+	if *rs.Resource.Attributes[0].Value.StringValue != "gateway" {
+		t.Fatalf("unexpected resource %+v", rs.Resource)
+	}
+	span := rs.ScopeSpans[0].Spans[0]
+	if span.TraceID != TraceIDFrom("msg-1") || len(span.TraceID) != 32 || len(span.SpanID) != 16 {
+		t.Fatalf("unexpected ids %s/%s", span.TraceID, span.SpanID)
+	}
+	if span.StartTimeUnixNano != "100000000000" || span.EndTimeUnixNano != "101000000000" {
+		t.Fatalf("unexpected times %s-%s", span.StartTimeUnixNano, span.EndTimeUnixNano)
+	}
+	if span.Status.Code != 2 || span.Status.Message != "timeout" {
+		t.Fatalf("expected error status, got %+v", span.Status)
+	}
+	if span.Attributes[0].Key != "uap.attempt" || *span.Attributes[0].Value.IntValue != "2" {
+		t.Fatalf("unexpected attributes %+v", span.Attributes)
+	}
+}
+
+func TestNilExporterIsNoop(t *testing.T) {
+	exp := NewExporter(Config{})
+	if exp != nil {
+		t.Fatal("expected nil exporter without endpoint")
+	}
+	exp.Export(&Span{TraceID: NewTraceID(), SpanID: NewSpanID()})
+	exp.Flush()
+	exp.Shutdown()
+}
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	traceID, spanID := NewTraceID(), NewSpanID()
+	gotTrace, gotSpan, ok := ParseTraceParent(FormatTraceParent(traceID, spanID))
+	if !ok || gotTrace != traceID || gotSpan != spanID {
+		t.Fatalf("round trip failed: %s %s %v", gotTrace, gotSpan, ok)
+	}
+	for _, bad := range []string{"", "00-abc-def-01", "00-" + traceID + "-0000000000000000-01", "00-zz" + traceID[2:] + "-" + spanID + "-01"} {
+		if _, _, ok := ParseTraceParent(bad); ok {
+			t.Fatalf("expected %q rejected", bad)
+		}
+	}
+}
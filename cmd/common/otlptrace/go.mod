@@ -0,0 +1,3 @@
+module otlptrace
+
+go 1.24.0
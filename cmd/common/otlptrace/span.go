@@ -0,0 +1,97 @@
+package otlptrace
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ========================= Span 数据模型 =========================
+
+// Span 种类（与 OTLP SpanKind 枚举一致）
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+	SpanKindProducer = 4
+	SpanKindConsumer = 5
+)
+
+// Span 单个 span，TraceID / SpanID 为小写 hex（32 / 16 位）
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Kind         int
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]any // 支持 string / bool / int / int64 / float64，其余类型按 fmt 格式化
+	Error        string         // 非空时 status=ERROR
+}
+
+// ========================= ID 生成 =========================
+
+// NewTraceID 随机生成 trace ID
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID 随机生成 span ID
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+// TraceIDFrom 由任意字符串（如 UAP 消息 ID / task ID）派生稳定的 trace ID，
+// 不同进程对同一个 key 得到相同结果，无需额外传播即可对齐
+func TraceIDFrom(key string) string {
+	sum := sha256.Sum256([]byte("trace:" + key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// SpanIDFrom 由任意字符串派生稳定的 span ID
+func SpanIDFrom(key string) string {
+	sum := sha256.Sum256([]byte("span:" + key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// 极少发生，退化为时间派生
+		sum := sha256.Sum256([]byte(time.Now().String()))
+		copy(b, sum[:n])
+	}
+	return hex.EncodeToString(b)
+}
+
+// ========================= W3C traceparent =========================
+
+// FormatTraceParent 生成 W3C traceparent: 00-<trace_id>-<span_id>-01
+func FormatTraceParent(traceID, spanID string) string {
+	return fmt.Sprintf("00-%s-%s-01", traceID, spanID)
+}
+
+// ParseTraceParent 解析 W3C traceparent，返回 trace ID 与父 span ID
+func ParseTraceParent(tp string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(tp), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) || isZero(parts[1]) || isZero(parts[2]) {
+		return "", "", false
+	}
+	return strings.ToLower(parts[1]), strings.ToLower(parts[2]), true
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
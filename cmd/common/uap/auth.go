@@ -0,0 +1,54 @@
+package uap
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ========================= Agent 注册凭证 =========================
+//
+// 每个 agent 的注册 token 由 gateway 密钥对 agent_id + agent_type（+ 过期时间）做 HMAC-SHA256 签名，
+// 格式: v1.<expires_unix>.<hex_signature>，expires_unix 为 0 表示永不过期。
+// token 只能用于签发时绑定的 agent_id / agent_type，泄露后无法冒充其他 agent。
+
+const agentTokenVersion = "v1"
+
+// SignAgentToken 为指定 agent 签发注册 token
+func SignAgentToken(secret, agentID, agentType string, expiresAt time.Time) string {
+	var exp int64
+	if !expiresAt.IsZero() {
+		exp = expiresAt.Unix()
+	}
+	return fmt.Sprintf("%s.%d.%s", agentTokenVersion, exp, agentTokenSignature(secret, agentID, agentType, exp))
+}
+
+// VerifyAgentToken 校验注册 token 是否由 secret 签发且绑定到 agentID / agentType
+func VerifyAgentToken(secret, token, agentID, agentType string, now time.Time) error {
+	parts := strings.SplitN(token, ".", 3)
+	if len(parts) != 3 || parts[0] != agentTokenVersion {
+		return fmt.Errorf("malformed agent token")
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed agent token expiry")
+	}
+	want := agentTokenSignature(secret, agentID, agentType, exp)
+	if !hmac.Equal([]byte(parts[2]), []byte(want)) {
+		return fmt.Errorf("agent token not valid for %s (%s)", agentID, agentType)
+	}
+	if exp > 0 && now.Unix() > exp {
+		return fmt.Errorf("agent token expired")
+	}
+	return nil
+}
+
+func agentTokenSignature(secret, agentID, agentType string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", agentTokenVersion, agentID, agentType, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
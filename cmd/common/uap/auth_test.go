@@ -0,0 +1,57 @@
+package uap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgentTokenBoundToIDAndType(t *testing.T) {
+	now := time.Now()
+	token := SignAgentToken("secret", "deploy-1", "deploy", time.Time{})
+
+	if err := VerifyAgentToken("secret", token, "deploy-1", "deploy", now); err != nil {
+		t.Fatalf("expected token to verify: %v", err)
+	}
+	if err := VerifyAgentToken("secret", token, "image-1", "deploy", now); err == nil {
+		t.Fatalf("token must not verify for another agent_id")
+	}
+	if err := VerifyAgentToken("secret", token, "deploy-1", "image_agent", now); err == nil {
+		t.Fatalf("token must not verify for another agent_type")
+	}
+	if err := VerifyAgentToken("other", token, "deploy-1", "deploy", now); err == nil {
+		t.Fatalf("token must not verify with another secret")
+	}
+}
+
+func TestAgentTokenExpiry(t *testing.T) {
+	token := SignAgentToken("secret", "cron-1", "cron_agent", time.Now().Add(time.Hour))
+	if err := VerifyAgentToken("secret", token, "cron-1", "cron_agent", time.Now()); err != nil {
+		t.Fatalf("expected unexpired token to verify: %v", err)
+	}
+	if err := VerifyAgentToken("secret", token, "cron-1", "cron_agent", time.Now().Add(2*time.Hour)); err == nil {
+		t.Fatalf("expected expired token to be rejected")
+	}
+	if err := VerifyAgentToken("secret", "garbage", "cron-1", "cron_agent", time.Now()); err == nil {
+		t.Fatalf("expected malformed token to be rejected")
+	}
+}
+
+func TestServerCheckRegisterToken(t *testing.T) {
+	s := NewServer()
+	s.TokenSecret = "secret"
+	good := &RegisterPayload{AgentID: "a", AgentType: "deploy", AuthToken: SignAgentToken("secret", "a", "deploy", time.Time{})}
+	if verified, err := s.checkRegisterToken(good); err != nil || !verified {
+		t.Fatalf("expected per-agent token accepted and type verified: verified=%v err=%v", verified, err)
+	}
+	if _, err := s.checkRegisterToken(&RegisterPayload{AgentID: "a", AgentType: "deploy", AuthToken: "shared"}); err == nil {
+		t.Fatalf("expected shared token rejected when auth_token is not configured")
+	}
+	s.AuthToken = "shared"
+	verified, err := s.checkRegisterToken(&RegisterPayload{AgentID: "a", AgentType: "deploy", AuthToken: "shared"})
+	if err != nil {
+		t.Fatalf("expected shared token accepted during migration: %v", err)
+	}
+	if verified {
+		t.Fatalf("expected shared token not to verify the self-reported agent_type")
+	}
+}
@@ -0,0 +1,185 @@
+package uap
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ========================= 按类型寻址 / 负载均衡 =========================
+
+// TypeAddressPrefix 按 agent 类型寻址的前缀，如 To: "type:deploy-agent"
+const TypeAddressPrefix = "type:"
+
+// 负载均衡策略
+const (
+	RouteLeastInFlight = "least_inflight" // 选择在途请求最少的实例（默认）
+	RouteRoundRobin    = "round_robin"    // 按 agent ID 顺序轮询
+)
+
+// inFlightTTL 在途请求最长保留时间，超时未收到回复的条目在健康检查时清理
+const inFlightTTL = 30 * time.Minute
+
+// ParseTypeAddress 解析按类型寻址的 To 字段，返回 agent 类型
+func ParseTypeAddress(to string) (string, bool) {
+	if !strings.HasPrefix(to, TypeAddressPrefix) {
+		return "", false
+	}
+	agentType := strings.TrimPrefix(to, TypeAddressPrefix)
+	return agentType, agentType != ""
+}
+
+// InFlight 返回当前在途的 task_assign / tool_call 数量
+func (a *AgentConn) InFlight() int {
+	a.flightMu.Lock()
+	defer a.flightMu.Unlock()
+	return len(a.inFlight)
+}
+
+// hasCapacity 是否还能接收新的请求（Capacity<=0 视为不限）
+func (a *AgentConn) hasCapacity() bool {
+	return a.Capacity <= 0 || a.InFlight() < a.Capacity
+}
+
+// trackRequest 转发 tool_call / task_assign 后记录在途请求
+func (a *AgentConn) trackRequest(msg *Message) {
+	key := requestKey(msg)
+	if key == "" {
+		return
+	}
+	a.flightMu.Lock()
+	if a.inFlight == nil {
+		a.inFlight = make(map[string]time.Time)
+	}
+	a.inFlight[key] = time.Now()
+	a.flightMu.Unlock()
+}
+
+// untrackRequest 转发失败或被拒绝时释放预占的在途请求
+func (a *AgentConn) untrackRequest(msg *Message) {
+	key := requestKey(msg)
+	if key == "" {
+		return
+	}
+	a.flightMu.Lock()
+	delete(a.inFlight, key)
+	a.flightMu.Unlock()
+}
+
+// trackReply 收到此 agent 发出的 tool_result / task_complete / task_rejected 后释放在途请求
+func (a *AgentConn) trackReply(msg *Message) {
+	key := replyKey(msg)
+	if key == "" {
+		return
+	}
+	a.flightMu.Lock()
+	delete(a.inFlight, key)
+	a.flightMu.Unlock()
+}
+
+// pruneInFlight 清理超时未回复的在途请求
+func (a *AgentConn) pruneInFlight(ttl time.Duration) {
+	a.flightMu.Lock()
+	defer a.flightMu.Unlock()
+	for key, ts := range a.inFlight {
+		if time.Since(ts) > ttl {
+			delete(a.inFlight, key)
+		}
+	}
+}
+
+// requestKey 请求消息的在途键：tool_call 按 Message.ID，task_assign 按 task_id
+func requestKey(msg *Message) string {
+	switch msg.Type {
+	case MsgToolCall:
+		if msg.ID != "" {
+			return "call:" + msg.ID
+		}
+	case MsgTaskAssign:
+		var p TaskAssignPayload
+		if json.Unmarshal(msg.Payload, &p) == nil && p.TaskID != "" {
+			return "task:" + p.TaskID
+		}
+	}
+	return ""
+}
+
+// replyKey 回复消息对应的在途键
+func replyKey(msg *Message) string {
+	switch msg.Type {
+	case MsgToolResult:
+		var p ToolResultPayload
+		if json.Unmarshal(msg.Payload, &p) == nil && p.RequestID != "" {
+			return "call:" + p.RequestID
+		}
+	case MsgTaskComplete, MsgTaskRejected:
+		var p struct {
+			TaskID string `json:"task_id"`
+		}
+		if json.Unmarshal(msg.Payload, &p) == nil && p.TaskID != "" {
+			return "task:" + p.TaskID
+		}
+	}
+	return ""
+}
+
+// SelectAgentByType 按策略从指定类型的在线实例中挑选一个未满载的 agent
+// msg 非 nil 时在同一把锁内为选中的 agent 预占在途槽位，避免并发调用同时通过容量检查；
+// 转发失败时调用方需 untrackRequest 释放
+// 返回值 busy=true 表示有在线实例但全部达到 Capacity 上限
+func (s *Server) SelectAgentByType(agentType string, msg *Message) (agent *AgentConn, busy bool) {
+	candidates := s.GetAgentsByType(agentType)
+	if len(candidates) == 0 {
+		return nil, false
+	}
+
+	s.selectMu.Lock()
+	defer s.selectMu.Unlock()
+	agent, busy = s.pickAgentLocked(agentType, candidates)
+	if agent != nil && msg != nil {
+		agent.trackRequest(msg)
+	}
+	return agent, busy
+}
+
+// pickAgentLocked 按策略挑选未满载的实例（调用方持有 s.selectMu）
+func (s *Server) pickAgentLocked(agentType string, candidates []*AgentConn) (*AgentConn, bool) {
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+	var available []*AgentConn
+	for _, a := range candidates {
+		if a.hasCapacity() {
+			available = append(available, a)
+		}
+	}
+	if len(available) == 0 {
+		return nil, true
+	}
+
+	if s.RouteStrategy == RouteRoundRobin {
+		s.mu.Lock()
+		if s.rrCounters == nil {
+			s.rrCounters = make(map[string]*uint64)
+		}
+		counter, ok := s.rrCounters[agentType]
+		if !ok {
+			counter = new(uint64)
+			s.rrCounters[agentType] = counter
+		}
+		s.mu.Unlock()
+		n := atomic.AddUint64(counter, 1) - 1
+		return available[n%uint64(len(available))], false
+	}
+
+	// least_inflight：在途最少者优先，并列时按 ID 顺序
+	best := available[0]
+	bestLoad := best.InFlight()
+	for _, a := range available[1:] {
+		if load := a.InFlight(); load < bestLoad {
+			best, bestLoad = a, load
+		}
+	}
+	return best, false
+}
@@ -0,0 +1,121 @@
+package uap
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func addTestAgent(s *Server, id, agentType string, capacity int) *AgentConn {
+	a := &AgentConn{ID: id, AgentType: agentType, Name: id, Capacity: capacity, Online: true}
+	s.agents[id] = a
+	return a
+}
+
+func TestParseTypeAddress(t *testing.T) {
+	if typ, ok := ParseTypeAddress("type:deploy-agent"); !ok || typ != "deploy-agent" {
+		t.Fatalf("unexpected parse result: %q %v", typ, ok)
+	}
+	if _, ok := ParseTypeAddress("deploy-agent"); ok {
+		t.Fatalf("plain agent id should not be a type address")
+	}
+	if _, ok := ParseTypeAddress("type:"); ok {
+		t.Fatalf("empty type should be rejected")
+	}
+}
+
+func TestSelectAgentByTypeLeastInFlight(t *testing.T) {
+	s := NewServer()
+	a := addTestAgent(s, "deploy-a", "deploy-agent", 4)
+	b := addTestAgent(s, "deploy-b", "deploy-agent", 4)
+	addTestAgent(s, "codegen-a", "codegen-agent", 4)
+
+	a.trackRequest(&Message{Type: MsgToolCall, ID: "c1"})
+	if got, _ := s.SelectAgentByType("deploy-agent", nil); got != b {
+		t.Fatalf("expected deploy-b with fewer in-flight, got %v", got.ID)
+	}
+
+	// tool_result 释放在途请求后按 ID 顺序选回 deploy-a
+	payload, _ := json.Marshal(ToolResultPayload{RequestID: "c1", Success: true})
+	a.trackReply(&Message{Type: MsgToolResult, Payload: payload})
+	if got, _ := s.SelectAgentByType("deploy-agent", nil); got != a {
+		t.Fatalf("expected deploy-a after reply, got %v", got.ID)
+	}
+}
+
+func TestSelectAgentByTypeRespectsCapacity(t *testing.T) {
+	s := NewServer()
+	a := addTestAgent(s, "codegen-a", "codegen-agent", 1)
+	b := addTestAgent(s, "codegen-b", "codegen-agent", 1)
+
+	task, _ := json.Marshal(TaskAssignPayload{TaskID: "t1"})
+	a.trackRequest(&Message{Type: MsgTaskAssign, ID: "m1", Payload: task})
+	b.trackRequest(&Message{Type: MsgToolCall, ID: "m2"})
+
+	got, busy := s.SelectAgentByType("codegen-agent", nil)
+	if got != nil || !busy {
+		t.Fatalf("expected all instances busy, got agent=%v busy=%v", got, busy)
+	}
+
+	done, _ := json.Marshal(TaskCompletePayload{TaskID: "t1", Status: "success"})
+	a.trackReply(&Message{Type: MsgTaskComplete, Payload: done})
+	if got, busy := s.SelectAgentByType("codegen-agent", nil); got != a || busy {
+		t.Fatalf("expected codegen-a after task_complete, got %v busy=%v", got, busy)
+	}
+
+	if got, busy := s.SelectAgentByType("image-agent", nil); got != nil || busy {
+		t.Fatalf("expected no candidates for unknown type")
+	}
+}
+
+func TestSelectAgentByTypeRoundRobin(t *testing.T) {
+	s := NewServer()
+	s.RouteStrategy = RouteRoundRobin
+	addTestAgent(s, "deploy-b", "deploy-agent", 0)
+	addTestAgent(s, "deploy-a", "deploy-agent", 0)
+
+	var order []string
+	for i := 0; i < 4; i++ {
+		got, _ := s.SelectAgentByType("deploy-agent", nil)
+		order = append(order, got.ID)
+	}
+	want := []string{"deploy-a", "deploy-b", "deploy-a", "deploy-b"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("unexpected round robin order: %v", order)
+		}
+	}
+}
+
+func TestSelectAgentByTypeReservesCapacityAtomically(t *testing.T) {
+	s := NewServer()
+	addTestAgent(s, "codegen-a", "codegen-agent", 2)
+	addTestAgent(s, "codegen-b", "codegen-agent", 1)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	selected := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msg := &Message{Type: MsgToolCall, ID: fmt.Sprintf("call-%d", i)}
+			if got, _ := s.SelectAgentByType("codegen-agent", msg); got != nil {
+				mu.Lock()
+				selected++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if selected != 3 {
+		t.Fatalf("expected exactly 3 reservations for total capacity 3, got %d", selected)
+	}
+	for _, a := range s.GetAgentsByType("codegen-agent") {
+		if a.InFlight() > a.Capacity {
+			t.Fatalf("%s exceeded capacity: %d > %d", a.ID, a.InFlight(), a.Capacity)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package uap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ========================= 请求-响应关联 =========================
+//
+// 关联规则：
+//   - tool_call   → tool_result（payload.request_id == 请求 Message.ID）
+//   - task_assign → task_complete / task_rejected（payload.task_id 相同）
+//   - 任意请求    → error（gateway / 对端沿用请求 Message.ID）
+//
+// 被关联的回复由 Call 直接返回，不再进入 OnMessage。
+
+// CallError 对端或 gateway 返回的 error 消息
+type CallError struct {
+	Code    string
+	Message string
+}
+
+func (e *CallError) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
+
+// PendingCall 已发出、等待回复的请求
+type PendingCall struct {
+	client *Client
+	msg    *Message
+	keys   []string
+	ch     chan *Message
+
+	abandonOnce sync.Once
+	abandoned   chan struct{}
+}
+
+// ErrCallAbandoned Call 被调用方放弃等待（不通知对端）
+var ErrCallAbandoned = errors.New("call abandoned")
+
+// Call 发送请求并阻塞等待关联回复；ctx 取消或超时时向对端发送取消消息
+func (c *Client) Call(ctx context.Context, to, msgType string, payload any) (*Message, error) {
+	return c.CallMessage(ctx, &Message{
+		Type:    msgType,
+		To:      to,
+		Payload: mustMarshal(payload),
+	})
+}
+
+// CallMessage 同 Call，允许调用方自定义消息 ID（如复用 trace ID）
+func (c *Client) CallMessage(ctx context.Context, msg *Message) (*Message, error) {
+	if msg.TraceParent == "" {
+		msg.TraceParent = TraceParentFrom(ctx)
+	}
+	call, err := c.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+	return call.Wait(ctx)
+}
+
+// Start 注册关联并发送请求，返回可稍后 Wait 的句柄（发送失败时立即返回错误）
+func (c *Client) Start(msg *Message) (*PendingCall, error) {
+	if msg.ID == "" {
+		msg.ID = NewMsgID()
+	}
+	if msg.From == "" {
+		msg.From = c.AgentID
+	}
+	if msg.Ts == 0 {
+		msg.Ts = time.Now().UnixMilli()
+	}
+
+	call := &PendingCall{
+		client:    c,
+		msg:       msg,
+		keys:      requestCorrelationKeys(msg),
+		ch:        make(chan *Message, 1),
+		abandoned: make(chan struct{}),
+	}
+
+	c.pendingMu.Lock()
+	if c.pending == nil {
+		c.pending = make(map[string]*PendingCall)
+	}
+	for _, key := range call.keys {
+		c.pending[key] = call
+	}
+	c.pendingMu.Unlock()
+
+	if err := c.Send(msg); err != nil {
+		call.release()
+		return nil, fmt.Errorf("send %s: %w", msg.Type, err)
+	}
+	return call, nil
+}
+
+// ID 请求消息 ID
+func (p *PendingCall) ID() string {
+	return p.msg.ID
+}
+
+// Wait 等待关联回复；收到 error 消息时返回 *CallError
+func (p *PendingCall) Wait(ctx context.Context) (*Message, error) {
+	defer p.release()
+
+	select {
+	case reply := <-p.ch:
+		return p.result(reply)
+	case <-p.abandoned:
+		return nil, ErrCallAbandoned
+	case <-ctx.Done():
+		// 回复与取消同时到达时以回复为准，已完成的请求不再发送取消
+		select {
+		case reply := <-p.ch:
+			return p.result(reply)
+		case <-p.abandoned:
+			return nil, ErrCallAbandoned
+		default:
+		}
+		p.cancelRemote()
+		return nil, fmt.Errorf("%s %s to %s: %w", p.msg.Type, p.msg.ID, p.msg.To, ctx.Err())
+	}
+}
+
+// Abandon 放弃等待且不通知对端（调用方已通过其他途径确认请求完成时使用）
+func (p *PendingCall) Abandon() {
+	p.abandonOnce.Do(func() { close(p.abandoned) })
+}
+
+// result 将回复转换为 Wait 的返回值
+func (p *PendingCall) result(reply *Message) (*Message, error) {
+	if reply.Type == MsgError {
+		var payload ErrorPayload
+		json.Unmarshal(reply.Payload, &payload)
+		return reply, &CallError{Code: payload.Code, Message: payload.Message}
+	}
+	return reply, nil
+}
+
+// release 解除关联
+func (p *PendingCall) release() {
+	c := p.client
+	c.pendingMu.Lock()
+	for _, key := range p.keys {
+		if c.pending[key] == p {
+			delete(c.pending, key)
+		}
+	}
+	c.pendingMu.Unlock()
+}
+
+// cancelRemote 通知对端放弃处理（best effort，不等待结果）
+func (p *PendingCall) cancelRemote() {
+	var cancel *Message
+	switch p.msg.Type {
+	case MsgToolCall:
+		var payload ToolCallPayload
+		json.Unmarshal(p.msg.Payload, &payload)
+		cancel = &Message{
+			Type: MsgToolCancel,
+			Payload: mustMarshal(ToolCancelPayload{
+				OriginalMsgID: p.msg.ID,
+				ToolName:      payload.ToolName,
+			}),
+		}
+	case MsgTaskAssign:
+		var payload TaskAssignPayload
+		json.Unmarshal(p.msg.Payload, &payload)
+		cancel = &Message{
+			Type:    MsgTaskStop,
+			Payload: mustMarshal(TaskStopPayload{TaskID: payload.TaskID}),
+		}
+	default:
+		return
+	}
+	cancel.ID = NewMsgID()
+	cancel.From = p.client.AgentID
+	cancel.To = p.msg.To
+	cancel.Ts = time.Now().UnixMilli()
+	if err := p.client.Send(cancel); err != nil {
+		log.Printf("[UAP-Client] send %s for %s failed: %v", cancel.Type, p.msg.ID, err)
+	}
+}
+
+// deliverReply 将回复投递给等待中的 Call，返回 true 表示已被关联消费
+func (c *Client) deliverReply(msg *Message) bool {
+	key := replyCorrelationKey(msg)
+	if key == "" {
+		return false
+	}
+	c.pendingMu.Lock()
+	call, ok := c.pending[key]
+	if ok {
+		// 一个请求只接收一次回复
+		for _, k := range call.keys {
+			delete(c.pending, k)
+		}
+	}
+	c.pendingMu.Unlock()
+	if !ok {
+		return false
+	}
+	call.ch <- msg
+	return true
+}
+
+// requestCorrelationKeys 请求可被哪些回复键匹配
+func requestCorrelationKeys(msg *Message) []string {
+	keys := []string{"id:" + msg.ID}
+	if msg.Type == MsgTaskAssign {
+		var p TaskAssignPayload
+		if json.Unmarshal(msg.Payload, &p) == nil && p.TaskID != "" {
+			keys = append(keys, "task:"+p.TaskID)
+		}
+	}
+	return keys
+}
+
+// replyCorrelationKey 回复消息的关联键
+func replyCorrelationKey(msg *Message) string {
+	switch msg.Type {
+	case MsgToolResult:
+		var p ToolResultPayload
+		if json.Unmarshal(msg.Payload, &p) == nil && p.RequestID != "" {
+			return "id:" + p.RequestID
+		}
+	case MsgTaskComplete, MsgTaskRejected:
+		var p struct {
+			TaskID string `json:"task_id"`
+		}
+		if json.Unmarshal(msg.Payload, &p) == nil && p.TaskID != "" {
+			return "task:" + p.TaskID
+		}
+	case MsgError:
+		if msg.ID != "" {
+			return "id:" + msg.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+package uap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	s := NewServer()
+	hs := httptest.NewServer(http.HandlerFunc(s.HandleWebSocket))
+	t.Cleanup(hs.Close)
+	return s, "ws" + strings.TrimPrefix(hs.URL, "http")
+}
+
+func startTestClient(t *testing.T, url, id string, onMessage func(c *Client, msg *Message)) *Client {
+	t.Helper()
+	c := NewClient(url, id, "test", id)
+	registered := make(chan bool, 1)
+	c.OnRegistered = func(ok bool) { registered <- ok }
+	c.OnMessage = func(msg *Message) {
+		if onMessage != nil {
+			onMessage(c, msg)
+		}
+	}
+	go c.Run()
+	t.Cleanup(c.Stop)
+	select {
+	case ok := <-registered:
+		if !ok {
+			t.Fatalf("client %s registration rejected", id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("client %s registration timeout", id)
+	}
+	return c
+}
+
+func TestClientCallReturnsCorrelatedToolResult(t *testing.T) {
+	_, url := startTestServer(t)
+	startTestClient(t, url, "echo", func(c *Client, msg *Message) {
+		if msg.Type == MsgToolCall {
+			c.SendTo(msg.From, MsgToolResult, BuildToolResult(msg.ID, "pong", ""))
+		}
+	})
+	caller := startTestClient(t, url, "caller", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	reply, err := caller.Call(ctx, "echo", MsgToolCall, ToolCallPayload{ToolName: "Ping"})
+	if err != nil {
+		t.Fatalf("Call error: %v", err)
+	}
+	var result ToolResultPayload
+	json.Unmarshal(reply.Payload, &result)
+	if !result.Success || result.Result != "pong" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestClientCallCorrelatesTaskCompleteByTaskID(t *testing.T) {
+	_, url := startTestServer(t)
+	startTestClient(t, url, "worker", func(c *Client, msg *Message) {
+		if msg.Type == MsgTaskAssign {
+			var p TaskAssignPayload
+			json.Unmarshal(msg.Payload, &p)
+			c.SendTo(msg.From, MsgTaskComplete, TaskCompletePayload{TaskID: p.TaskID, Status: "success"})
+		}
+	})
+	caller := startTestClient(t, url, "caller", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	reply, err := caller.Call(ctx, "worker", MsgTaskAssign, TaskAssignPayload{TaskID: "job-1"})
+	if err != nil {
+		t.Fatalf("Call error: %v", err)
+	}
+	if reply.Type != MsgTaskComplete {
+		t.Fatalf("expected task_complete, got %s", reply.Type)
+	}
+}
+
+func TestClientCallReturnsGatewayError(t *testing.T) {
+	_, url := startTestServer(t)
+	caller := startTestClient(t, url, "caller", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := caller.Call(ctx, "missing", MsgToolCall, ToolCallPayload{ToolName: "Ping"})
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Code != "agent_offline" {
+		t.Fatalf("expected agent_offline CallError, got %v", err)
+	}
+}
+
+func TestClientCallCancellationNotifiesRemote(t *testing.T) {
+	_, url := startTestServer(t)
+	cancelled := make(chan ToolCancelPayload, 1)
+	startTestClient(t, url, "slow", func(c *Client, msg *Message) {
+		if msg.Type == MsgToolCancel {
+			var p ToolCancelPayload
+			json.Unmarshal(msg.Payload, &p)
+			cancelled <- p
+		}
+	})
+	caller := startTestClient(t, url, "caller", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	call, err := caller.Start(&Message{
+		Type:    MsgToolCall,
+		To:      "slow",
+		Payload: mustMarshal(ToolCallPayload{ToolName: "Sleep"}),
+	})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if _, err := call.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	select {
+	case p := <-cancelled:
+		if p.OriginalMsgID != call.ID() || p.ToolName != "Sleep" {
+			t.Fatalf("unexpected cancel payload: %+v", p)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("remote did not receive tool_cancel")
+	}
+}
+
+func TestClientCallPrefersDeliveredReplyOverCancellation(t *testing.T) {
+	_, url := startTestServer(t)
+	cancelled := make(chan struct{}, 2)
+	startTestClient(t, url, "worker", func(c *Client, msg *Message) {
+		if msg.Type == MsgToolCancel {
+			cancelled <- struct{}{}
+		}
+	})
+	caller := startTestClient(t, url, "caller", nil)
+
+	call, err := caller.Start(&Message{
+		Type:    MsgToolCall,
+		To:      "worker",
+		Payload: mustMarshal(ToolCallPayload{ToolName: "Ping"}),
+	})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	// 回复已到达，随后 ctx 才被取消（如场景结束时的 defer cancel）
+	if !caller.deliverReply(&Message{Type: MsgToolResult, Payload: mustMarshal(BuildToolResult(call.ID(), "pong", ""))}) {
+		t.Fatalf("reply should be correlated")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if reply, err := call.Wait(ctx); err != nil || reply == nil {
+		t.Fatalf("delivered reply should win over cancellation: %v %v", reply, err)
+	}
+
+	abandoned, err := caller.Start(&Message{
+		Type:    MsgToolCall,
+		To:      "worker",
+		Payload: mustMarshal(ToolCallPayload{ToolName: "Ping"}),
+	})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	abandoned.Abandon()
+	if _, err := abandoned.Wait(ctx); !errors.Is(err, ErrCallAbandoned) {
+		t.Fatalf("expected ErrCallAbandoned, got %v", err)
+	}
+
+	select {
+	case <-cancelled:
+		t.Fatalf("no tool_cancel should be sent for a completed or abandoned call")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestClientUpdateToolsKeepsConnection(t *testing.T) {
+	s, url := startTestServer(t)
+	offline := make(chan string, 1)
+	s.OnAgentOffline = func(a *AgentConn) { offline <- a.ID }
+
+	c := NewClient(url, "bridge", "test", "bridge")
+	acks := make(chan bool, 2)
+	c.OnRegistered = func(ok bool) { acks <- ok }
+	go c.Run()
+	t.Cleanup(c.Stop)
+	waitAck := func() {
+		select {
+		case ok := <-acks:
+			if !ok {
+				t.Fatal("register rejected")
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("register ack timeout")
+		}
+	}
+	waitAck()
+
+	if err := c.UpdateTools([]ToolDef{{Name: "mcp.search"}}); err != nil {
+		t.Fatalf("UpdateTools error: %v", err)
+	}
+	waitAck()
+
+	agents := s.GetAllAgents()
+	if len(agents) != 1 {
+		t.Fatalf("expected 1 agent, got %v", agents)
+	}
+	if tools, _ := agents[0]["tools"].([]string); len(tools) != 1 || tools[0] != "mcp.search" {
+		t.Fatalf("tools not updated in place: %v", agents[0]["tools"])
+	}
+	select {
+	case id := <-offline:
+		t.Fatalf("agent %s should not go offline on tool update", id)
+	default:
+	}
+}
@@ -34,6 +34,10 @@ type Client struct {
 	stopCh     chan struct{}
 	backoffIdx int
 
+	// Call 请求-响应关联（关联键 → 等待中的请求）
+	pending   map[string]*PendingCall
+	pendingMu sync.Mutex
+
 	// 消息处理回调
 	OnMessage func(msg *Message)
 
@@ -92,7 +96,10 @@ func (c *Client) connect() error {
 }
 
 // register 发送注册消息
-func (c *Client) register() {
+func (c *Client) register() error {
+	c.mu.Lock()
+	tools := c.Tools
+	c.mu.Unlock()
 	payload := RegisterPayload{
 		AgentID:      c.AgentID,
 		AgentType:    c.AgentType,
@@ -101,12 +108,12 @@ func (c *Client) register() {
 		HostPlatform: c.HostPlatform,
 		HostIP:       c.HostIP,
 		Workspace:    c.Workspace,
-		Tools:        c.Tools,
+		Tools:        tools,
 		Capacity:     c.Capacity,
 		Meta:         c.Meta,
 		AuthToken:    c.AuthToken,
 	}
-	c.Send(&Message{
+	return c.Send(&Message{
 		Type:    MsgRegister,
 		ID:      NewMsgID(),
 		From:    c.AgentID,
@@ -173,6 +180,10 @@ func (c *Client) runLoop() {
 			// else: gateway 自身的心跳确认，忽略
 
 		default:
+			// Call 等待中的回复直接投递，不进入 OnMessage
+			if c.deliverReply(&msg) {
+				continue
+			}
 			if c.OnMessage != nil {
 				c.OnMessage(&msg)
 			}
@@ -251,6 +262,18 @@ func (c *Client) SendNotify(toAgentID string, payload NotifyPayload) error {
 	return c.SendTo(toAgentID, MsgNotify, payload)
 }
 
+// UpdateTools 替换工具列表；已连接时在当前连接上重新发送 register，gateway 原地更新工具而不断开重连
+func (c *Client) UpdateTools(tools []ToolDef) error {
+	c.mu.Lock()
+	c.Tools = tools
+	connected := c.connected
+	c.mu.Unlock()
+	if !connected {
+		return nil // 下次连接时随 register 发送
+	}
+	return c.register()
+}
+
 // IsConnected 是否已连接
 func (c *Client) IsConnected() bool {
 	c.mu.Lock()
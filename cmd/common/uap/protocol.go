@@ -14,6 +14,7 @@ const (
 	// 工具调用（跨 agent）
 	MsgToolCall   = "tool_call"
 	MsgToolResult = "tool_result"
+	MsgToolCancel = "tool_cancel" // 调用方放弃等待，通知被调方取消
 
 	// 长任务
 	MsgTaskAssign   = "task_assign"
@@ -24,7 +25,8 @@ const (
 	MsgTaskStop     = "task_stop"
 
 	// 通知
-	MsgNotify = "notify"
+	MsgNotify          = "notify"
+	MsgDeliveryReceipt = "delivery_receipt" // 推送回执（app-agent → 原始发送方）
 
 	// 错误
 	MsgError = "error"
@@ -57,6 +59,8 @@ type Message struct {
 	To      string          `json:"to"`   // 目标 agent ID
 	Payload json.RawMessage `json:"payload"`
 	Ts      int64           `json:"ts"`
+
+	TraceParent string `json:"traceparent,omitempty"` // W3C traceparent，用于跨 agent 链路追踪（可选）
 }
 
 // ========================= 注册载荷 =========================
@@ -145,6 +149,12 @@ func BuildToolError(requestID string, err string) ToolResultPayload {
 	}
 }
 
+// ToolCancelPayload 工具调用取消
+type ToolCancelPayload struct {
+	OriginalMsgID string `json:"original_msg_id"` // 被取消的 tool_call Message.ID
+	ToolName      string `json:"tool_name"`
+}
+
 // ========================= 长任务载荷 =========================
 
 // TaskAssignPayload 任务分派
@@ -194,6 +204,22 @@ type NotifyPayload struct {
 	Meta        map[string]any `json:"meta,omitempty"`         // 可选附加元数据
 }
 
+// 推送回执状态
+const (
+	ReceiptDelivered = "delivered"
+	ReceiptRead      = "read"
+)
+
+// DeliveryReceiptPayload 推送回执，MessageID 为原始 notify/tool_call 消息的 ID
+type DeliveryReceiptPayload struct {
+	MessageID     string `json:"message_id"`
+	PushMessageID string `json:"push_message_id,omitempty"` // 渠道侧消息 ID
+	Channel       string `json:"channel"`
+	To            string `json:"to"`
+	Status        string `json:"status"` // delivered / read
+	Timestamp     int64  `json:"timestamp"`
+}
+
 // ========================= 错误载荷 =========================
 
 // ErrorPayload 错误消息
@@ -26,7 +26,13 @@ type AgentConn struct {
 	Conn         *websocket.Conn
 	mu           sync.Mutex
 	LastHB       time.Time
+	RegisteredAt time.Time // 最近一次注册时间（重启后刷新，供部署后健康检查判断）
 	Online       bool
+	TypeVerified bool // agent_type 由绑定 agent_id/agent_type 的 HMAC token 证明（共享 token 或未启用认证时为 false）
+
+	// 在途请求（tool_call / task_assign），用于按类型路由时的负载均衡
+	flightMu sync.Mutex
+	inFlight map[string]time.Time
 }
 
 // Send 向此 agent 发送消息
@@ -59,9 +65,17 @@ type Server struct {
 	mu       sync.RWMutex
 	upgrader websocket.Upgrader
 
-	// AuthToken 验证 token（为空则不验证）
+	// AuthToken 共享验证 token（AuthToken 与 TokenSecret 均为空则不验证）
 	AuthToken string
 
+	// TokenSecret 每 agent 注册凭证的 HMAC 密钥（见 SignAgentToken），token 绑定 agent_id + agent_type
+	TokenSecret string
+
+	// RouteStrategy 按类型寻址（To: "type:xxx"）时的实例选择策略，默认 least_inflight
+	RouteStrategy string
+	rrCounters    map[string]*uint64 // agentType → 轮询计数
+	selectMu      sync.Mutex         // 串行化按类型选择与在途槽位预占
+
 	// OnAgentOnline/Offline 回调
 	OnAgentOnline  func(agent *AgentConn)
 	OnAgentOffline func(agent *AgentConn)
@@ -70,10 +84,17 @@ type Server struct {
 	OnMessage func(from *AgentConn, msg *Message)
 
 	// 事件追踪回调（均为可选，nil 则跳过）
-	OnMessageReceived  func(from *AgentConn, msg *Message)               // 收到消息
+	OnMessageReceived  func(from *AgentConn, msg *Message)                // 收到消息
 	OnMessageForwarded func(from *AgentConn, to *AgentConn, msg *Message) // 转发成功
-	OnRouteError       func(from *AgentConn, msg *Message)               // 路由失败（目标离线）
-	OnHeartbeatTimeout func(agent *AgentConn)                            // 心跳超时
+	OnRouteError       func(from *AgentConn, msg *Message)                // 路由失败（目标离线）
+	OnHeartbeatTimeout func(agent *AgentConn)                             // 心跳超时
+
+	// OnTargetOffline 目标离线时的暂存回调（可选），返回 true 表示消息已被接管，不再回复 agent_offline
+	OnTargetOffline func(from *AgentConn, msg *Message) bool
+
+	// AuthorizeRoute 路由授权（可选），返回错误则拒绝转发并回复 route_denied；target 为 nil 表示目标不在线
+	AuthorizeRoute func(from *AgentConn, target *AgentConn, msg *Message) error
+	OnRouteDenied  func(from *AgentConn, msg *Message, reason string) // 路由被拒绝
 }
 
 // NewServer 创建 UAP 网关服务
@@ -142,7 +163,8 @@ func (s *Server) handleConn(conn *websocket.Conn) {
 				continue
 			}
 			if agent != nil {
-				log.Printf("[UAP] duplicate register from %s, ignoring", agent.ID)
+				// 已注册连接上再次 register：仅更新工具列表，不重建连接
+				s.handleToolsUpdate(agent, &msg)
 				continue
 			}
 			agent = s.handleRegister(conn, &msg)
@@ -169,6 +191,8 @@ func (s *Server) handleConn(conn *websocket.Conn) {
 			}
 			// 填充 From 字段（gateway 保证）
 			msg.From = agent.ID
+			// 回复类消息释放本连接的在途请求
+			agent.trackReply(&msg)
 			s.routeMessage(agent, &msg)
 		}
 	}
@@ -191,8 +215,9 @@ func (s *Server) handleRegister(conn *websocket.Conn, msg *Message) *AgentConn {
 	}
 
 	// 验证 token
-	if s.AuthToken != "" && payload.AuthToken != s.AuthToken {
-		log.Printf("[UAP] register rejected: invalid token from %s", payload.AgentID)
+	typeVerified, err := s.checkRegisterToken(&payload)
+	if err != nil {
+		log.Printf("[UAP] register rejected: %v (agent=%s type=%s)", err, payload.AgentID, payload.AgentType)
 		sendDirect(conn, &Message{
 			Type: MsgRegisterAck,
 			Payload: mustMarshal(RegisterAckPayload{
@@ -233,7 +258,9 @@ func (s *Server) handleRegister(conn *websocket.Conn, msg *Message) *AgentConn {
 		Meta:         payload.Meta,
 		Conn:         conn,
 		LastHB:       time.Now(),
+		RegisteredAt: time.Now(),
 		Online:       true,
+		TypeVerified: typeVerified,
 	}
 	s.agents[payload.AgentID] = agent
 	s.mu.Unlock()
@@ -257,6 +284,47 @@ func (s *Server) handleRegister(conn *websocket.Conn, msg *Message) *AgentConn {
 	return agent
 }
 
+// handleToolsUpdate 处理已注册连接上的重复 register：agent_id 一致时替换工具列表与描述
+func (s *Server) handleToolsUpdate(agent *AgentConn, msg *Message) {
+	var payload RegisterPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.AgentID != agent.ID {
+		log.Printf("[UAP] duplicate register from %s with mismatched payload, ignoring", agent.ID)
+		return
+	}
+
+	s.mu.Lock()
+	agent.Tools = payload.Tools
+	if payload.Description != "" {
+		agent.Description = payload.Description
+	}
+	s.mu.Unlock()
+
+	log.Printf("[UAP] agent %s updated tools=%d", agent.ID, len(payload.Tools))
+	agent.Send(&Message{
+		Type:    MsgRegisterAck,
+		Payload: mustMarshal(RegisterAckPayload{Success: true}),
+		Ts:      time.Now().UnixMilli(),
+	})
+}
+
+// checkRegisterToken 校验注册凭证：共享 AuthToken 或绑定 agent_id/agent_type 的 HMAC token 任一通过即可；
+// 返回的 typeVerified 仅在通过 HMAC token 认证时为 true
+func (s *Server) checkRegisterToken(payload *RegisterPayload) (typeVerified bool, err error) {
+	if s.AuthToken == "" && s.TokenSecret == "" {
+		return false, nil
+	}
+	if s.AuthToken != "" && payload.AuthToken == s.AuthToken {
+		return false, nil
+	}
+	if s.TokenSecret != "" {
+		if err := VerifyAgentToken(s.TokenSecret, payload.AuthToken, payload.AgentID, payload.AgentType, time.Now()); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid shared token")
+}
+
 // GetAgentByIDOrName 按 ID 或 Name 查找在线 agent（ID 优先）
 func (s *Server) GetAgentByIDOrName(idOrName string) *AgentConn {
 	s.mu.RLock()
@@ -286,33 +354,88 @@ func (s *Server) routeMessage(from *AgentConn, msg *Message) {
 		return
 	}
 
-	target := s.GetAgentByIDOrName(msg.To)
+	var target *AgentConn
+	reserved := false
+	if agentType, ok := ParseTypeAddress(msg.To); ok {
+		// 按类型寻址：在同类型在线实例中负载均衡（选中即预占在途槽位）
+		var busy bool
+		target, busy = s.SelectAgentByType(agentType, msg)
+		if busy {
+			log.Printf("[UAP] all %s instances at capacity, returning error to %s", agentType, from.ID)
+			if s.OnRouteError != nil {
+				s.OnRouteError(from, msg)
+			}
+			from.Send(&Message{
+				Type: MsgError,
+				ID:   msg.ID,
+				From: "gateway",
+				To:   from.ID,
+				Payload: mustMarshal(ErrorPayload{
+					Code:    "agent_busy",
+					Message: fmt.Sprintf("all %s instances are at capacity", agentType),
+				}),
+				Ts: time.Now().UnixMilli(),
+			})
+			return
+		}
+		if target != nil {
+			msg.To = target.ID
+			reserved = true
+		}
+	} else {
+		target = s.GetAgentByIDOrName(msg.To)
+	}
+
+	// 路由授权（离线目标同样校验，避免经离线队列绕过）
+	if s.AuthorizeRoute != nil {
+		if err := s.AuthorizeRoute(from, target, msg); err != nil {
+			if reserved {
+				target.untrackRequest(msg)
+			}
+			log.Printf("[UAP] route denied %s → %s (type=%s): %v", from.ID, msg.To, msg.Type, err)
+			if s.OnRouteDenied != nil {
+				s.OnRouteDenied(from, msg, err.Error())
+			}
+			from.Send(&Message{
+				Type: MsgError,
+				ID:   msg.ID,
+				From: "gateway",
+				To:   from.ID,
+				Payload: mustMarshal(ErrorPayload{
+					Code:    "route_denied",
+					Message: err.Error(),
+				}),
+				Ts: time.Now().UnixMilli(),
+			})
+			return
+		}
+	}
 
 	if target == nil {
+		// 离线暂存：由上层决定是否接管（如 gateway 的离线投递队列）
+		if s.OnTargetOffline != nil && s.OnTargetOffline(from, msg) {
+			return
+		}
 		// 目标 agent 不在线，返回错误给发送方
 		log.Printf("[UAP] target agent %s not online, returning error to %s", msg.To, from.ID)
 		// 事件追踪：路由失败
 		if s.OnRouteError != nil {
 			s.OnRouteError(from, msg)
 		}
-		from.Send(&Message{
-			Type: MsgError,
-			ID:   msg.ID,
-			From: "gateway",
-			To:   from.ID,
-			Payload: mustMarshal(ErrorPayload{
-				Code:    "agent_offline",
-				Message: fmt.Sprintf("target agent %s is not online", msg.To),
-			}),
-			Ts: time.Now().UnixMilli(),
-		})
+		from.Send(AgentOfflineError(msg))
 		return
 	}
 
 	// 转发给目标 agent
 	if err := target.Send(msg); err != nil {
+		if reserved {
+			target.untrackRequest(msg)
+		}
 		log.Printf("[UAP] forward to %s failed: %v", msg.To, err)
 	} else {
+		if !reserved {
+			target.trackRequest(msg)
+		}
 		// 事件追踪：转发成功
 		if s.OnMessageForwarded != nil {
 			s.OnMessageForwarded(from, target, msg)
@@ -388,7 +511,9 @@ func (s *Server) GetAllAgents() []map[string]any {
 				"workspace":     a.Workspace,
 				"tools":         tools,
 				"capacity":      a.Capacity,
+				"in_flight":     a.InFlight(),
 				"last_hb":       a.LastHB.Format(time.RFC3339),
+				"registered_at": a.RegisteredAt.UnixMilli(),
 			}
 			// 透传 meta 扩展字段（models、workspaces 等注册时上报的动态信息）
 			if len(a.Meta) > 0 {
@@ -448,6 +573,7 @@ func (s *Server) StartHealthCheck(timeout time.Duration) {
 				if a.Online && time.Since(a.LastHB) > timeout {
 					expired = append(expired, a)
 				}
+				a.pruneInFlight(inFlightTTL)
 			}
 			s.mu.RUnlock()
 
@@ -465,6 +591,21 @@ func (s *Server) StartHealthCheck(timeout time.Duration) {
 
 // ========================= 工具函数 =========================
 
+// AgentOfflineError 构建目标离线错误回复（ID 沿用原始消息，便于发送方关联）
+func AgentOfflineError(msg *Message) *Message {
+	return &Message{
+		Type: MsgError,
+		ID:   msg.ID,
+		From: "gateway",
+		To:   msg.From,
+		Payload: mustMarshal(ErrorPayload{
+			Code:    "agent_offline",
+			Message: fmt.Sprintf("target agent %s is not online", msg.To),
+		}),
+		Ts: time.Now().UnixMilli(),
+	}
+}
+
 func mustMarshal(v any) json.RawMessage {
 	data, _ := json.Marshal(v)
 	return data
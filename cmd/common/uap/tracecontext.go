@@ -0,0 +1,28 @@
+package uap
+
+import "context"
+
+// ========================= 链路上下文传播 =========================
+//
+// Message.TraceParent 携带 W3C traceparent。gateway 在转发 tool_call / task_assign 时
+// 会写入自身 span，接收方据此把本地 span 挂到同一条链路下；
+// 发起方可通过 WithTraceParent 把当前 span 放进 context，Call 会自动带上。
+
+type traceParentKey struct{}
+
+// WithTraceParent 将 traceparent 放入 context
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// TraceParentFrom 从 context 取出 traceparent（不存在时返回空串）
+func TraceParentFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tp, _ := ctx.Value(traceParentKey{}).(string)
+	return tp
+}
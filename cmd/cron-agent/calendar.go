@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ========================= 排除日历 =========================
+//
+// 日历描述"哪些日期不执行"，任务通过 exclude_calendars 引用。支持两种文件：
+//
+//	JSON: {"exclude_dates": ["2026-10-01"], "exclude_weekdays": [0, 6], "include_dates": ["2026-10-10"]}
+//	      exclude_weekdays 中 0=周日 … 6=周六；include_dates 优先级最高（如调休上班日）
+//	ICS : 每个 VEVENT 的 DTSTART~DTEND 覆盖的日期均被排除（全天事件 DTEND 不含当天，不展开 RRULE）
+
+const calendarDateLayout = "2006-01-02"
+
+// Calendar 排除日历
+type Calendar struct {
+	Name            string   `json:"name"`
+	ExcludeDates    []string `json:"exclude_dates"`
+	ExcludeWeekdays []int    `json:"exclude_weekdays"`
+	IncludeDates    []string `json:"include_dates"`
+
+	excluded map[string]bool
+	included map[string]bool
+	weekdays map[time.Weekday]bool
+}
+
+// Excludes 判断 t 所在日期（按 t 自身时区）是否被排除
+func (c *Calendar) Excludes(t time.Time) bool {
+	day := t.Format(calendarDateLayout)
+	if c.included[day] {
+		return false
+	}
+	return c.excluded[day] || c.weekdays[t.Weekday()]
+}
+
+func (c *Calendar) index() error {
+	c.excluded = make(map[string]bool, len(c.ExcludeDates))
+	c.included = make(map[string]bool, len(c.IncludeDates))
+	c.weekdays = make(map[time.Weekday]bool, len(c.ExcludeWeekdays))
+	for _, d := range c.ExcludeDates {
+		if _, err := time.Parse(calendarDateLayout, d); err != nil {
+			return fmt.Errorf("无效日期 %q: %v", d, err)
+		}
+		c.excluded[d] = true
+	}
+	for _, d := range c.IncludeDates {
+		if _, err := time.Parse(calendarDateLayout, d); err != nil {
+			return fmt.Errorf("无效日期 %q: %v", d, err)
+		}
+		c.included[d] = true
+	}
+	for _, w := range c.ExcludeWeekdays {
+		if w < 0 || w > 6 {
+			return fmt.Errorf("无效星期 %d（0=周日 … 6=周六）", w)
+		}
+		c.weekdays[time.Weekday(w)] = true
+	}
+	return nil
+}
+
+// LoadCalendar 按扩展名加载 .ics 或 JSON 日历
+func LoadCalendar(name, path string) (*Calendar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取日历 %s 失败: %w", path, err)
+	}
+
+	cal := &Calendar{Name: name}
+	if strings.EqualFold(filepath.Ext(path), ".ics") {
+		dates, err := parseICSDates(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析日历 %s 失败: %w", path, err)
+		}
+		cal.ExcludeDates = dates
+	} else {
+		if err := json.Unmarshal(data, cal); err != nil {
+			return nil, fmt.Errorf("解析日历 %s 失败: %w", path, err)
+		}
+		cal.Name = name
+	}
+
+	if err := cal.index(); err != nil {
+		return nil, fmt.Errorf("日历 %s: %w", name, err)
+	}
+	return cal, nil
+}
+
+// parseICSDates 提取所有 VEVENT 覆盖的日期
+func parseICSDates(data []byte) ([]string, error) {
+	var dates []string
+	var start, end string
+	inEvent := false
+
+	for _, line := range unfoldICS(data) {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(key, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, start, end = true, "", ""
+			}
+		case "DTSTART":
+			if inEvent {
+				start = value
+			}
+		case "DTEND":
+			if inEvent {
+				end = value
+			}
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || !inEvent {
+				continue
+			}
+			inEvent = false
+			days, err := icsEventDates(start, end)
+			if err != nil {
+				return nil, err
+			}
+			dates = append(dates, days...)
+		}
+	}
+	return dates, nil
+}
+
+// unfoldICS 按 RFC 5545 合并折行（以空格或 Tab 开头的行接续上一行）
+func unfoldICS(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// icsEventDates 展开事件覆盖的日期；全天事件 DTEND 为次日（不含）
+func icsEventDates(start, end string) ([]string, error) {
+	if start == "" {
+		return nil, nil
+	}
+	s, allDay, err := parseICSTime(start)
+	if err != nil {
+		return nil, err
+	}
+	if end == "" {
+		return []string{s.Format(calendarDateLayout)}, nil
+	}
+	e, _, err := parseICSTime(end)
+	if err != nil {
+		return nil, err
+	}
+	// 非全天事件若在 0 点结束，当天不算
+	last := e
+	if allDay || (e.Hour() == 0 && e.Minute() == 0 && e.Second() == 0 && e.After(s)) {
+		last = e.AddDate(0, 0, -1)
+	}
+
+	var days []string
+	for d := s; !d.After(last); d = d.AddDate(0, 0, 1) {
+		days = append(days, d.Format(calendarDateLayout))
+		if len(days) > 366 {
+			return nil, fmt.Errorf("事件跨度过长: %s~%s", start, end)
+		}
+	}
+	if len(days) == 0 {
+		days = append(days, s.Format(calendarDateLayout))
+	}
+	return days, nil
+}
+
+// parseICSTime 解析 20261001 / 20261001T090000 / 20261001T090000Z
+func parseICSTime(v string) (time.Time, bool, error) {
+	v = strings.TrimSpace(v)
+	if len(v) == 8 {
+		t, err := time.Parse("20060102", v)
+		return t, true, err
+	}
+	v = strings.TrimSuffix(v, "Z")
+	t, err := time.Parse("20060102T150405", v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("无效时间 %q", v)
+	}
+	return t, false, nil
+}
+
+// loadCalendars 加载配置中的全部日历，单个失败只记录错误
+func loadCalendars(paths map[string]string) (map[string]*Calendar, []error) {
+	calendars := make(map[string]*Calendar, len(paths))
+	var errs []error
+	for name, path := range paths {
+		cal, err := LoadCalendar(name, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		calendars[name] = cal
+	}
+	return calendars, errs
+}
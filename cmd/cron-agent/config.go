@@ -18,8 +18,16 @@ type Config struct {
 	QuietHoursEnd   string   `json:"quiet_hours_end"`   // 免打扰结束时间，如 "07:00"
 	Provider        string   `json:"provider,omitempty"` // LLM provider（如 openai, deepseek）
 	Model           string   `json:"model,omitempty"`    // LLM model（如 gpt-4, deepseek-chat）
+	TaskTimeoutSec  int      `json:"task_timeout_sec"`   // 等待 llm-agent task_complete 的超时（秒），超时后发送 task_stop（<=0 使用默认 1800）
+
+	Timezone            string            `json:"timezone,omitempty"`              // 任务默认时区（IANA，如 "Asia/Shanghai"；空=本机时区）
+	DefaultMissedPolicy string            `json:"default_missed_policy,omitempty"` // 任务未指定时的错过补偿策略 skip | run_once | run_all
+	Calendars           map[string]string `json:"calendars,omitempty"`             // 排除日历 name → .ics/.json 文件路径
 }
 
+// defaultTaskTimeoutSec 等待 task_complete 的默认超时，避免未回复的任务永久占用等待协程
+const defaultTaskTimeoutSec = 1800
+
 // DefaultConfig 默认配置
 func DefaultConfig() *Config {
 	return &Config{
@@ -28,6 +36,7 @@ func DefaultConfig() *Config {
 		LLMAgentID:     "llm-agent",
 		TaskFile:       "./cron-tasks.json",
 		ProtectedFiles: []string{"cron-agent.json", "cron-tasks.json"},
+		TaskTimeoutSec: defaultTaskTimeoutSec,
 	}
 }
 
@@ -53,6 +62,17 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.TaskFile == "" {
 		cfg.TaskFile = "./cron-tasks.json"
 	}
+	if cfg.TaskTimeoutSec <= 0 {
+		cfg.TaskTimeoutSec = defaultTaskTimeoutSec
+	}
+	if _, err := loadLocation(cfg.Timezone); err != nil {
+		return nil, err
+	}
+	policy, err := normalizeMissedPolicy(cfg.DefaultMissedPolicy, MissedPolicySkip)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DefaultMissedPolicy = policy
 
 	return cfg, nil
 }
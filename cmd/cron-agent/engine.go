@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"strings"
 	"sync"
@@ -32,20 +33,32 @@ type CronTask struct {
 	OneShot     bool   `json:"one_shot"`     // 执行后自动删除
 	IgnoreQuiet bool   `json:"ignore_quiet"` // true=忽略免打扰（如服务器监控），默认 false
 	CreatedAt   string `json:"created_at"`   // RFC3339 创建时间
+
+	Timezone         string   `json:"timezone,omitempty"`          // IANA 时区，如 "Asia/Shanghai"；空=配置默认时区
+	QuietHoursStart  string   `json:"quiet_hours_start,omitempty"` // 任务级免打扰（覆盖全局），按任务时区计算
+	QuietHoursEnd    string   `json:"quiet_hours_end,omitempty"`
+	ExcludeCalendars []string `json:"exclude_calendars,omitempty"` // 排除日历名称，命中的日期不执行
+	MissedPolicy     string   `json:"missed_policy,omitempty"`     // 错过执行的补偿策略 skip | run_once | run_all
+	JitterSec        int      `json:"jitter_sec,omitempty"`        // 执行前随机延迟 0~N 秒
+	RunAt            string   `json:"run_at,omitempty"`            // 延迟任务到期时间（RFC3339）
+	LastRunAt        string   `json:"last_run_at,omitempty"`       // 最近一次处理的调度时间点（RFC3339），用于错过补偿
 }
 
 // CronEngine 定时任务引擎：调度 + 存储 + 执行
 type CronEngine struct {
-	mu         sync.RWMutex
-	tasks      map[string]*CronTask          // taskID → task
-	entries    map[string]cron.EntryID       // taskID → cron entryID
-	timers     map[string]context.CancelFunc // taskID → 延迟任务取消函数
-	cron       *cron.Cron
-	ab         *agentbase.AgentBase
-	cfg        *Config
-	pending    sync.Map // executionID → cronTaskID
-	quietStart int      // 免打扰开始（分钟，如 23:00=1380）；-1 表示未启用
-	quietEnd   int      // 免打扰结束（分钟，如 07:00=420）
+	mu        sync.RWMutex
+	tasks     map[string]*CronTask          // taskID → task
+	entries   map[string]cron.EntryID       // taskID → cron entryID
+	timers    map[string]context.CancelFunc // taskID → 延迟任务取消函数
+	cron      *cron.Cron
+	ab        *agentbase.AgentBase
+	cfg       *Config
+	pending   sync.Map             // executionID → cronTaskID
+	quiet     quietWindow          // 全局免打扰时段
+	calendars map[string]*Calendar // 排除日历 name → calendar
+	saveTimer *time.Timer          // 触发记录（last_run_at）的延迟保存
+	stop      chan struct{}        // Stop 时关闭，结束等待中的补偿
+	stopOnce  sync.Once
 }
 
 // NewCronEngine 创建引擎，加载持久化任务，启动调度器
@@ -59,13 +72,23 @@ func NewCronEngine(cfg *Config, ab *agentbase.AgentBase) *CronEngine {
 		cron:    cron.New(),
 		ab:      ab,
 		cfg:     cfg,
+		stop:    make(chan struct{}),
 	}
 
-	e.quietStart, e.quietEnd = parseQuietHours(cfg.QuietHoursStart, cfg.QuietHoursEnd)
-	if e.quietStart >= 0 {
+	e.quiet.start, e.quiet.end = parseQuietHours(cfg.QuietHoursStart, cfg.QuietHoursEnd)
+	if e.quiet.enabled() {
 		log.Printf("[CronEngine] 免打扰时段: %s-%s", cfg.QuietHoursStart, cfg.QuietHoursEnd)
 	}
 
+	var errs []error
+	e.calendars, errs = loadCalendars(cfg.Calendars)
+	for _, err := range errs {
+		log.Printf("[CronEngine] ⚠ 加载日历失败: %v", err)
+	}
+	for name, cal := range e.calendars {
+		log.Printf("[CronEngine] 排除日历 %s: %d 个日期, %d 个星期", name, len(cal.excluded), len(cal.weekdays))
+	}
+
 	// 加载持久化任务
 	if err := e.loadFromFile(); err != nil {
 		log.Printf("[CronEngine] 加载任务文件失败: %v", err)
@@ -79,6 +102,9 @@ func NewCronEngine(cfg *Config, ab *agentbase.AgentBase) *CronEngine {
 
 	// 将已加载的任务注册到调度器
 	for _, task := range e.tasks {
+		if err := e.prepareTask(task); err != nil {
+			log.Printf("[CronEngine] ⚠ 任务配置无效 ID=%s name=%s: %v", task.ID, task.Name, err)
+		}
 		if err := e.scheduleTask(task); err != nil {
 			log.Printf("[CronEngine] 调度任务失败 ID=%s name=%s: %v", task.ID, task.Name, err)
 		} else {
@@ -88,6 +114,9 @@ func NewCronEngine(cfg *Config, ab *agentbase.AgentBase) *CronEngine {
 
 	e.cron.Start()
 	log.Printf("[CronEngine] ✓ 引擎启动完成，%d 个任务已调度", len(e.tasks))
+
+	// 停机期间错过的执行，连上 gateway 后按策略补偿
+	go e.catchUpMissed(time.Now())
 	return e
 }
 
@@ -99,6 +128,11 @@ func (e *CronEngine) AddTask(task *CronTask) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if err := e.prepareTask(task); err != nil {
+		log.Printf("[CronEngine] AddTask 校验失败 ID=%s: %v", task.ID, err)
+		return err
+	}
+
 	// 调度
 	if err := e.scheduleTask(task); err != nil {
 		log.Printf("[CronEngine] AddTask 调度失败 ID=%s: %v", task.ID, err)
@@ -133,11 +167,13 @@ func (e *CronEngine) RemoveTask(id string) error {
 		log.Printf("[CronEngine]   ├─ cron entry 已移除 entryID=%d", entryID)
 	}
 
-	// 取消延迟 timer
-	if cancel, ok := e.timers[id]; ok {
-		cancel()
-		delete(e.timers, id)
-		log.Printf("[CronEngine]   ├─ 延迟 timer 已取消")
+	// 取消延迟 timer 和等待中的补偿执行
+	for _, key := range []string{id, catchUpTimerKey(id)} {
+		if cancel, ok := e.timers[key]; ok {
+			cancel()
+			delete(e.timers, key)
+			log.Printf("[CronEngine]   ├─ timer 已取消 key=%s", key)
+		}
 	}
 
 	delete(e.tasks, id)
@@ -199,13 +235,14 @@ func (e *CronEngine) TriggerTask(id string) error {
 
 	log.Printf("[CronEngine] TriggerTask 手动触发 ID=%s name=%s type=%s",
 		task.ID, task.Name, task.TaskType)
-	go e.executeTask(task)
+	go e.executeTask(task, "")
 	return nil
 }
 
 // Stop 停止引擎
 func (e *CronEngine) Stop() {
 	log.Printf("[CronEngine] 停止引擎，取消所有定时器...")
+	e.stopOnce.Do(func() { close(e.stop) })
 	e.cron.Stop()
 
 	e.mu.Lock()
@@ -214,6 +251,12 @@ func (e *CronEngine) Stop() {
 		cancel()
 		delete(e.timers, id)
 	}
+	// 落盘尚未保存的触发记录
+	if e.saveTimer != nil {
+		e.saveTimer.Stop()
+		e.saveTimer = nil
+		e.saveToFile()
+	}
 	e.mu.Unlock()
 
 	log.Printf("[CronEngine] ✓ 引擎已停止，取消了 %d 个延迟定时器", timerCount)
@@ -286,11 +329,11 @@ func (e *CronEngine) ListPendingByOwner(owner string) []map[string]string {
 	return result
 }
 
-// HandleTaskComplete 处理 llm-agent 返回的任务完成消息
+// HandleTaskComplete 记录一次执行的最终结果并移出 pending
 func (e *CronEngine) HandleTaskComplete(executionID, status, errMsg, result string) {
 	cronTaskID, ok := e.pending.LoadAndDelete(executionID)
 	if !ok {
-		log.Printf("[CronEngine] ⚠ task_complete 未知 executionID=%s (可能已过期或重复)", executionID)
+		log.Printf("[CronEngine] ⚠ task_complete 未知 executionID=%s (可能已被删除或重复)", executionID)
 		return
 	}
 
@@ -319,11 +362,17 @@ func (e *CronEngine) scheduleTask(task *CronTask) error {
 	taskID := task.ID // 捕获闭包变量
 
 	if task.Schedule != "" {
-		log.Printf("[CronEngine] scheduleTask ID=%s 注册 cron/interval schedule=%q oneShot=%v",
-			taskID, task.Schedule, task.OneShot)
+		tz := e.taskTimezone(task)
+		log.Printf("[CronEngine] scheduleTask ID=%s 注册 cron/interval schedule=%q tz=%q oneShot=%v",
+			taskID, task.Schedule, tz, task.OneShot)
 
-		// cron 表达式或 @every 间隔
-		entryID, err := e.cron.AddFunc(task.Schedule, func() {
+		// cron 表达式或 @every 间隔，按任务时区计算
+		sched, err := parseTaskSchedule(task.Schedule, tz)
+		if err != nil {
+			return err
+		}
+		entryID := e.cron.Schedule(sched, cron.FuncJob(func() {
+			at := time.Now().Truncate(time.Second)
 			log.Printf("[CronEngine] ⏰ 调度触发 ID=%s name=%s schedule=%s",
 				taskID, task.Name, task.Schedule)
 
@@ -334,34 +383,50 @@ func (e *CronEngine) scheduleTask(task *CronTask) error {
 				log.Printf("[CronEngine] ⚠ 触发时任务已不存在 ID=%s", taskID)
 				return
 			}
-			e.executeTask(t)
-			if t.OneShot {
+			if e.fire(t, at) && t.OneShot {
 				log.Printf("[CronEngine] oneShot 任务执行完毕，自动移除 ID=%s", taskID)
 				e.RemoveTask(taskID)
 			}
-		})
-		if err != nil {
-			return fmt.Errorf("无效的调度表达式 %q: %v", task.Schedule, err)
-		}
+		}))
 		e.entries[taskID] = entryID
 		log.Printf("[CronEngine]   └─ cron entry 注册成功 entryID=%d", entryID)
 		return nil
 	}
 
 	if task.DelaySec > 0 {
-		log.Printf("[CronEngine] scheduleTask ID=%s 注册延迟任务 delay=%ds",
-			taskID, task.DelaySec)
+		// 到期时间持久化，重启后按剩余时间继续
+		runAt, err := time.Parse(time.RFC3339, task.RunAt)
+		if err != nil {
+			runAt = time.Now().Add(time.Duration(task.DelaySec) * time.Second)
+			task.RunAt = runAt.Format(time.RFC3339)
+		}
+		log.Printf("[CronEngine] scheduleTask ID=%s 注册延迟任务 delay=%ds run_at=%s",
+			taskID, task.DelaySec, task.RunAt)
 
 		// 延迟一次性任务
 		ctx, cancel := context.WithCancel(context.Background())
 		e.timers[taskID] = cancel
 		go func() {
-			log.Printf("[CronEngine] 延迟定时器启动 ID=%s 将在 %ds 后执行", taskID, task.DelaySec)
+			wait := time.Until(runAt)
+			if wait < 0 {
+				wait = 0
+			}
+			log.Printf("[CronEngine] 延迟定时器启动 ID=%s 将在 %s 后执行", taskID, wait.Round(time.Second))
 			select {
-			case <-time.After(time.Duration(task.DelaySec) * time.Second):
+			case <-time.After(wait):
 				log.Printf("[CronEngine] ⏰ 延迟触发 ID=%s name=%s delay=%ds 已到期",
 					taskID, task.Name, task.DelaySec)
-				e.executeTask(task)
+				if late := time.Since(runAt); late > missedGrace {
+					policy, _ := normalizeMissedPolicy(task.MissedPolicy, e.cfg.DefaultMissedPolicy)
+					if policy == MissedPolicySkip {
+						log.Printf("[CronEngine] ⏭ 延迟任务已错过 %s，按 skip 策略丢弃 ID=%s", late.Round(time.Second), taskID)
+						e.RemoveTask(taskID)
+						return
+					}
+					e.fireLate(task, runAt)
+				} else {
+					e.fire(task, runAt)
+				}
 				e.RemoveTask(taskID)
 			case <-ctx.Done():
 				log.Printf("[CronEngine] 延迟任务已取消 ID=%s", taskID)
@@ -373,11 +438,36 @@ func (e *CronEngine) scheduleTask(task *CronTask) error {
 	return fmt.Errorf("任务必须指定 schedule 或 delay_sec")
 }
 
-// executeTask 发送 task_assign 到 llm-agent
-func (e *CronEngine) executeTask(task *CronTask) {
-	if !task.IgnoreQuiet && e.isInQuietHours() {
+// fire 处理一次调度触发：排除日历 → 记录触发时间 → 随机延迟 → 执行；返回是否已执行
+func (e *CronEngine) fire(task *CronTask, at time.Time) bool {
+	if cal := e.excludedBy(task, at); cal != "" {
+		log.Printf("[CronEngine] ⏭ 日历 %s 排除当天，跳过 ID=%s name=%s", cal, task.ID, task.Name)
+		e.markRun(task.ID, at)
+		return false
+	}
+	e.markRun(task.ID, at)
+
+	if task.JitterSec > 0 {
+		jitter := time.Duration(rand.Intn(task.JitterSec+1)) * time.Second
+		log.Printf("[CronEngine] 随机延迟 %s 后执行 ID=%s", jitter, task.ID)
+		time.Sleep(jitter)
+	}
+	return e.executeTask(task, "")
+}
+
+// fireLate 补执行一次错过的调度（note 会附在提醒内容后）
+func (e *CronEngine) fireLate(task *CronTask, at time.Time) bool {
+	loc := e.taskLocation(task)
+	note := fmt.Sprintf("（补发：原定 %s 执行）", at.In(loc).Format("2006-01-02 15:04"))
+	log.Printf("[CronEngine] ↻ 补执行 ID=%s name=%s scheduled=%s", task.ID, task.Name, at.Format(time.RFC3339))
+	return e.executeTask(task, note)
+}
+
+// executeTask 发送 task_assign 到 llm-agent；note 非空时附加到提醒内容（补发说明）
+func (e *CronEngine) executeTask(task *CronTask, note string) bool {
+	if !task.IgnoreQuiet && e.inQuietHours(task, time.Now()) {
 		log.Printf("[CronEngine] ⏭ 免打扰时段，跳过 ID=%s name=%s", task.ID, task.Name)
-		return
+		return false
 	}
 
 	executionID := fmt.Sprintf("cron_%s_%d", task.ID, time.Now().UnixMilli())
@@ -392,12 +482,16 @@ func (e *CronEngine) executeTask(task *CronTask) {
 	var innerPayload interface{}
 	switch task.TaskType {
 	case "cron_reminder":
+		message := task.Message
+		if note != "" {
+			message = message + "\n" + note
+		}
 		innerPayload = map[string]string{
-			"message":     task.Message,
+			"message":     message,
 			"account":     task.Account,
 			"wechat_user": task.WechatUser,
 		}
-		log.Printf("[CronEngine]   payload: cron_reminder message=%q", task.Message)
+		log.Printf("[CronEngine]   payload: cron_reminder message=%q", message)
 	case "cron_query":
 		innerPayload = map[string]string{
 			"query":       task.Query,
@@ -407,7 +501,7 @@ func (e *CronEngine) executeTask(task *CronTask) {
 		log.Printf("[CronEngine]   payload: cron_query query=%q", task.Query)
 	default:
 		log.Printf("[CronEngine] ✗ 未知 task_type=%s, 跳过执行", task.TaskType)
-		return
+		return false
 	}
 
 	taskPayload := map[string]interface{}{
@@ -421,27 +515,65 @@ func (e *CronEngine) executeTask(task *CronTask) {
 		taskPayload["model"] = e.cfg.Model
 	}
 
-	// 记录 pending
+	// 记录 pending（供 cronListPending 展示执行中任务）
 	e.pending.Store(executionID, task.ID)
 
-	// 发送 task_assign
+	// 发送 task_assign，task_complete 由 uap.Client.Call 按 task_id 关联回来
 	payloadJSON, _ := json.Marshal(taskPayload)
 	log.Printf("[CronEngine]   发送 task_assign payload=%s", string(payloadJSON))
 
-	err := e.ab.SendMsg(e.cfg.LLMAgentID, uap.MsgTaskAssign, uap.TaskAssignPayload{
+	assignJSON, _ := json.Marshal(uap.TaskAssignPayload{
 		TaskID:  executionID,
 		Payload: json.RawMessage(payloadJSON),
 	})
+	call, err := e.ab.Client.Start(&uap.Message{
+		Type:    uap.MsgTaskAssign,
+		To:      e.cfg.LLMAgentID,
+		Payload: assignJSON,
+	})
 	if err != nil {
 		e.pending.Delete(executionID)
 		log.Printf("[CronEngine] ✗ 发送 task_assign 失败: %v", err)
 		log.Printf("[CronEngine]   gateway 是否连接: %v", e.ab.IsConnected())
-		return
+		return false
 	}
 
 	log.Printf("[CronEngine] ✓ task_assign 已发送 executionID=%s → %s, 等待 task_complete...",
 		executionID, e.cfg.LLMAgentID)
 	log.Printf("[CronEngine] ── executeTask 结束 ──")
+
+	go e.awaitCompletion(executionID, call)
+	return true
+}
+
+// awaitCompletion 等待 llm-agent 的 task_complete；超过 task_timeout_sec 后 Call 会自动发送 task_stop
+func (e *CronEngine) awaitCompletion(executionID string, call *uap.PendingCall) {
+	timeoutSec := e.cfg.TaskTimeoutSec
+	if timeoutSec <= 0 {
+		timeoutSec = defaultTaskTimeoutSec
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
+	defer cancel()
+
+	reply, err := call.Wait(ctx)
+	if err != nil {
+		e.HandleTaskComplete(executionID, "failed", err.Error(), "")
+		return
+	}
+
+	switch reply.Type {
+	case uap.MsgTaskComplete:
+		var payload uap.TaskCompletePayload
+		if err := json.Unmarshal(reply.Payload, &payload); err != nil {
+			e.HandleTaskComplete(executionID, "failed", fmt.Sprintf("解析 task_complete 失败: %v", err), "")
+			return
+		}
+		e.HandleTaskComplete(executionID, payload.Status, payload.Error, payload.Result)
+	case uap.MsgTaskRejected:
+		var payload uap.TaskRejectedPayload
+		json.Unmarshal(reply.Payload, &payload)
+		e.HandleTaskComplete(executionID, "rejected", payload.Reason, "")
+	}
 }
 
 // parseQuietHours 解析 "HH:MM" 格式为分钟数，解析失败或为空返回 -1, -1
@@ -477,19 +609,9 @@ func parseHHMM(s string) (int, bool) {
 	return h*60 + m, true
 }
 
-// isInQuietHours 判断当前时间是否在免打扰时段内
-func (e *CronEngine) isInQuietHours() bool {
-	if e.quietStart < 0 {
-		return false
-	}
-	now := time.Now()
-	cur := now.Hour()*60 + now.Minute()
-	if e.quietStart <= e.quietEnd {
-		// 不跨午夜，如 01:00→06:00
-		return cur >= e.quietStart && cur < e.quietEnd
-	}
-	// 跨午夜，如 23:00→07:00
-	return cur >= e.quietStart || cur < e.quietEnd
+// inQuietHours 判断 t 是否在任务的免打扰时段内（任务级设置优先，按任务时区计算）
+func (e *CronEngine) inQuietHours(task *CronTask, t time.Time) bool {
+	return e.quietFor(task).contains(t.In(e.taskLocation(task)))
 }
 
 // loadFromFile 从 JSON 文件加载任务
@@ -519,13 +641,11 @@ func (e *CronEngine) loadFromFile() error {
 
 // saveToFile 持久化任务到 JSON 文件（调用前需持有 mu 锁）
 func (e *CronEngine) saveToFile() {
-	// 只持久化周期性任务，纯延迟一次性任务不持久化
-	tasks := make([]*CronTask, 0)
+	// 延迟任务带 run_at 一并持久化，重启后继续等待
+	tasks := make([]*CronTask, 0, len(e.tasks))
 	for _, t := range e.tasks {
 		e.normalizeTaskOwnership(t)
-		if t.Schedule != "" {
-			tasks = append(tasks, t)
-		}
+		tasks = append(tasks, t)
 	}
 
 	data, err := json.MarshalIndent(tasks, "", "  ")
@@ -539,7 +659,7 @@ func (e *CronEngine) saveToFile() {
 		return
 	}
 
-	log.Printf("[CronEngine] 任务文件已保存: %s (%d 个周期任务)", e.cfg.TaskFile, len(tasks))
+	log.Printf("[CronEngine] 任务文件已保存: %s (%d 个任务)", e.cfg.TaskFile, len(tasks))
 }
 
 // newTaskID 生成短 UUID
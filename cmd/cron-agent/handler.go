@@ -55,10 +55,10 @@ func NewConnection(cfg *Config, agentID string) *Connection {
 
 	// 注册消息处理器
 	c.RegisterToolCallHandler(c.handleToolCall)
-	c.RegisterHandler(uap.MsgTaskComplete, c.handleTaskComplete)
+	// task_complete 由 uap.Client.Call 按 task_id 直接关联到 CronEngine.awaitCompletion
 	c.RegisterHandler(uap.MsgError, c.handleError)
 
-	log.Printf("[CronAgent] ✓ 连接管理器创建完成，已注册 3 个消息处理器 (tool_call, task_complete, error)")
+	log.Printf("[CronAgent] ✓ 连接管理器创建完成，已注册 2 个消息处理器 (tool_call, error)")
 
 	return c
 }
@@ -117,20 +117,26 @@ func buildToolDefs() []uap.ToolDef {
 	return []uap.ToolDef{
 		{
 			Name:        "cronCreateTask",
-			Description: "创建定时任务。支持三种调度模式：(1) delay_sec=N 延迟 N 秒后执行一次；(2) schedule 使用 cron 表达式如 '0 20 * * *' 每天20点执行，或间隔如 '@every 20m' 每20分钟执行；(3) schedule + one_shot=true 在下一个匹配时间执行一次后自动删除。task_type 为 'cron_reminder'（提醒通知，需要 message）或 'cron_query'（LLM 查询执行，需要 query）",
+			Description: "创建定时任务。支持三种调度模式：(1) delay_sec=N 延迟 N 秒后执行一次；(2) schedule 使用 cron 表达式如 '0 20 * * *' 每天20点执行，或间隔如 '@every 20m' 每20分钟执行；(3) schedule + one_shot=true 在下一个匹配时间执行一次后自动删除。task_type 为 'cron_reminder'（提醒通知，需要 message）或 'cron_query'（LLM 查询执行，需要 query）。可选：timezone 指定时区；quiet_hours_start/end 任务级免打扰；exclude_calendars 排除节假日等日历；missed_policy 控制停机错过后的补偿（skip/run_once/run_all）；jitter_sec 随机延迟",
 			Parameters: agentbase.MustMarshalJSON(map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"name":         map[string]interface{}{"type": "string", "description": "任务名称"},
-					"task_type":    map[string]interface{}{"type": "string", "enum": []string{"cron_reminder", "cron_query"}, "description": "任务类型"},
-					"schedule":     map[string]interface{}{"type": "string", "description": "cron 表达式或间隔，如 '0 20 * * *' 或 '@every 20m'"},
-					"delay_sec":    map[string]interface{}{"type": "integer", "description": "延迟秒数（一次性延迟任务，与 schedule 互斥）"},
-					"account":      map[string]interface{}{"type": "string", "description": "用户账号"},
-					"wechat_user":  map[string]interface{}{"type": "string", "description": "微信用户标识；仅微信场景需要，app/group 场景可留空"},
-					"message":      map[string]interface{}{"type": "string", "description": "提醒内容（task_type=cron_reminder 时必填）"},
-					"query":        map[string]interface{}{"type": "string", "description": "查询问题（task_type=cron_query 时必填）"},
-					"one_shot":     map[string]interface{}{"type": "boolean", "description": "是否一次性任务（配合 schedule 使用，执行一次后自动删除）"},
-					"ignore_quiet": map[string]interface{}{"type": "boolean", "description": "是否忽略免打扰时段（默认 false 受免打扰控制；服务器监控等重要任务设为 true）"},
+					"name":              map[string]interface{}{"type": "string", "description": "任务名称"},
+					"task_type":         map[string]interface{}{"type": "string", "enum": []string{"cron_reminder", "cron_query"}, "description": "任务类型"},
+					"schedule":          map[string]interface{}{"type": "string", "description": "cron 表达式或间隔，如 '0 20 * * *' 或 '@every 20m'"},
+					"delay_sec":         map[string]interface{}{"type": "integer", "description": "延迟秒数（一次性延迟任务，与 schedule 互斥）"},
+					"account":           map[string]interface{}{"type": "string", "description": "用户账号"},
+					"wechat_user":       map[string]interface{}{"type": "string", "description": "微信用户标识；仅微信场景需要，app/group 场景可留空"},
+					"message":           map[string]interface{}{"type": "string", "description": "提醒内容（task_type=cron_reminder 时必填）"},
+					"query":             map[string]interface{}{"type": "string", "description": "查询问题（task_type=cron_query 时必填）"},
+					"one_shot":          map[string]interface{}{"type": "boolean", "description": "是否一次性任务（配合 schedule 使用，执行一次后自动删除）"},
+					"ignore_quiet":      map[string]interface{}{"type": "boolean", "description": "是否忽略免打扰时段（默认 false 受免打扰控制；服务器监控等重要任务设为 true）"},
+					"timezone":          map[string]interface{}{"type": "string", "description": "IANA 时区，如 'Asia/Shanghai'、'America/New_York'；空=agent 默认时区"},
+					"quiet_hours_start": map[string]interface{}{"type": "string", "description": "任务级免打扰开始 HH:MM（覆盖全局设置，需与 quiet_hours_end 同时指定）"},
+					"quiet_hours_end":   map[string]interface{}{"type": "string", "description": "任务级免打扰结束 HH:MM"},
+					"exclude_calendars": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "排除日历名称（如 'cn-holidays'），命中的日期不执行"},
+					"missed_policy":     map[string]interface{}{"type": "string", "enum": []string{MissedPolicySkip, MissedPolicyRunOnce, MissedPolicyRunAll}, "description": "停机期间错过执行的补偿策略：skip 跳过（默认）、run_once 补一次、run_all 每次都补"},
+					"jitter_sec":        map[string]interface{}{"type": "integer", "description": "执行前随机延迟 0~N 秒，避免整点扎堆"},
 				},
 				"required": []string{"name", "task_type", "account"},
 			}),
@@ -228,22 +234,6 @@ func (c *Connection) handleToolCall(msg *uap.Message) {
 	}
 }
 
-func (c *Connection) handleTaskComplete(msg *uap.Message) {
-	var payload uap.TaskCompletePayload
-	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		log.Printf("[CronAgent] ✗ 解析 task_complete 失败: %v raw=%s", err, string(msg.Payload))
-		return
-	}
-
-	log.Printf("[CronAgent] ← task_complete 收到 from=%s taskID=%s status=%s resultLen=%d",
-		msg.From, payload.TaskID, payload.Status, len(payload.Result))
-	if payload.Error != "" {
-		log.Printf("[CronAgent]   error=%s", payload.Error)
-	}
-
-	c.engine.HandleTaskComplete(payload.TaskID, payload.Status, payload.Error, payload.Result)
-}
-
 func (c *Connection) handleError(msg *uap.Message) {
 	var payload uap.ErrorPayload
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
@@ -267,6 +257,7 @@ func (c *Connection) toolCreateTask(authenticatedUser string, args map[string]in
 	query, _ := args["query"].(string)
 	oneShot, _ := args["one_shot"].(bool)
 	ignoreQuiet, _ := args["ignore_quiet"].(bool)
+	jitterSec, _ := args["jitter_sec"].(float64)
 
 	log.Printf("[CronAgent] toolCreateTask name=%q type=%s schedule=%q delay=%.0f account=%s wechat=%s oneShot=%v ignoreQuiet=%v",
 		name, taskType, schedule, delaySec, account, wechatUser, oneShot, ignoreQuiet)
@@ -312,6 +303,13 @@ func (c *Connection) toolCreateTask(authenticatedUser string, args map[string]in
 		OneShot:     oneShot || (schedule == "" && delaySec > 0), // 纯延迟任务默认 one_shot
 		IgnoreQuiet: ignoreQuiet,
 		CreatedAt:   time.Now().Format(time.RFC3339),
+
+		Timezone:         stringArg(args, "timezone"),
+		QuietHoursStart:  stringArg(args, "quiet_hours_start"),
+		QuietHoursEnd:    stringArg(args, "quiet_hours_end"),
+		ExcludeCalendars: stringListArg(args, "exclude_calendars"),
+		MissedPolicy:     stringArg(args, "missed_policy"),
+		JitterSec:        int(jitterSec),
 	}
 
 	if err := c.engine.AddTask(task); err != nil {
@@ -416,6 +414,26 @@ func stringArg(args map[string]interface{}, key string) string {
 	return strings.TrimSpace(value)
 }
 
+// stringListArg 读取字符串数组参数，兼容逗号分隔的字符串
+func stringListArg(args map[string]interface{}, key string) []string {
+	var items []string
+	switch v := args[key].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if str, ok := item.(string); ok && strings.TrimSpace(str) != "" {
+				items = append(items, strings.TrimSpace(str))
+			}
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
 func resolveTaskOwner(authenticatedUser, account string) string {
 	if user := strings.TrimSpace(authenticatedUser); user != "" {
 		return user
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ========================= 调度辅助 =========================
+
+// 错过执行的补偿策略（agent 停机、调度延迟导致的错过）
+const (
+	MissedPolicySkip    = "skip"     // 跳过错过的执行（默认）
+	MissedPolicyRunOnce = "run_once" // 启动后补执行一次
+	MissedPolicyRunAll  = "run_all"  // 每个错过的时间点各补执行一次（上限 maxCatchUpRuns）
+)
+
+const (
+	maxCatchUpRuns = 20              // run_all 单次最多补执行次数
+	maxJitterSec   = 3600            // 随机延迟上限
+	missedGrace    = 2 * time.Minute // 延迟任务到期超过该时长视为错过
+
+	catchUpConnectWait = 10 * time.Minute // 补偿等待 gateway 连接的上限，超时放弃（下次启动重新计算）
+	runStateSaveDelay  = 10 * time.Second // 触发记录合并保存的延迟
+)
+
+// normalizeMissedPolicy 校验补偿策略，空值返回 def
+func normalizeMissedPolicy(policy, def string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "":
+		if def == "" {
+			return MissedPolicySkip, nil
+		}
+		return def, nil
+	case MissedPolicySkip:
+		return MissedPolicySkip, nil
+	case MissedPolicyRunOnce, "once":
+		return MissedPolicyRunOnce, nil
+	case MissedPolicyRunAll, "all":
+		return MissedPolicyRunAll, nil
+	}
+	return "", fmt.Errorf("无效的 missed_policy %q（skip/run_once/run_all）", policy)
+}
+
+var locationCache sync.Map // name → *time.Location
+
+// loadLocation 加载时区（带缓存），空字符串为本地时区
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" || strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	if loc, ok := locationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q: %v", name, err)
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+// parseTaskSchedule 解析任务的调度表达式，按任务时区计算触发时间
+func parseTaskSchedule(schedule, timezone string) (cron.Schedule, error) {
+	spec := strings.TrimSpace(schedule)
+	if timezone != "" && !strings.HasPrefix(spec, "TZ=") && !strings.HasPrefix(spec, "CRON_TZ=") {
+		if _, err := loadLocation(timezone); err != nil {
+			return nil, err
+		}
+		spec = fmt.Sprintf("CRON_TZ=%s %s", timezone, spec)
+	}
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("无效的调度表达式 %q: %v", schedule, err)
+	}
+	return sched, nil
+}
+
+// missedRuns 返回 (last, now] 内所有应触发的时间点，最多 limit 个（保留最近的）
+func missedRuns(sched cron.Schedule, last, now time.Time, limit int) []time.Time {
+	var runs []time.Time
+	for t := sched.Next(last); !t.IsZero() && !t.After(now); t = sched.Next(t) {
+		runs = append(runs, t)
+		if len(runs) > limit {
+			runs = runs[1:]
+		}
+	}
+	return runs
+}
+
+// quietWindow 免打扰时段（一天内的分钟数），start<0 表示未启用
+type quietWindow struct {
+	start int
+	end   int
+}
+
+func (q quietWindow) enabled() bool { return q.start >= 0 }
+
+// contains 判断 t（已转换到目标时区）是否在免打扰时段内
+func (q quietWindow) contains(t time.Time) bool {
+	if !q.enabled() {
+		return false
+	}
+	cur := t.Hour()*60 + t.Minute()
+	if q.start <= q.end {
+		// 不跨午夜，如 01:00→06:00
+		return cur >= q.start && cur < q.end
+	}
+	// 跨午夜，如 23:00→07:00
+	return cur >= q.start || cur < q.end
+}
+
+// endAfter 返回 t 之后最近一次免打扰结束时间
+func (q quietWindow) endAfter(t time.Time) time.Time {
+	end := time.Date(t.Year(), t.Month(), t.Day(), q.end/60, q.end%60, 0, 0, t.Location())
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// ========================= 引擎：任务级调度策略 =========================
+
+// prepareTask 校验并规范化任务的时区、免打扰、日历与补偿策略
+func (e *CronEngine) prepareTask(task *CronTask) error {
+	if _, err := loadLocation(e.taskTimezone(task)); err != nil {
+		return err
+	}
+	if (task.QuietHoursStart == "") != (task.QuietHoursEnd == "") {
+		return fmt.Errorf("quiet_hours_start 和 quiet_hours_end 需同时设置")
+	}
+	if task.QuietHoursStart != "" {
+		if _, ok := parseHHMM(task.QuietHoursStart); !ok {
+			return fmt.Errorf("无效的 quiet_hours_start %q（HH:MM）", task.QuietHoursStart)
+		}
+		if _, ok := parseHHMM(task.QuietHoursEnd); !ok {
+			return fmt.Errorf("无效的 quiet_hours_end %q（HH:MM）", task.QuietHoursEnd)
+		}
+	}
+	for _, name := range task.ExcludeCalendars {
+		if _, ok := e.calendars[name]; !ok {
+			return fmt.Errorf("未配置的排除日历 %q", name)
+		}
+	}
+	policy, err := normalizeMissedPolicy(task.MissedPolicy, "")
+	if err != nil {
+		return err
+	}
+	if task.MissedPolicy != "" {
+		task.MissedPolicy = policy
+	}
+	if task.JitterSec < 0 {
+		task.JitterSec = 0
+	}
+	if task.JitterSec > maxJitterSec {
+		task.JitterSec = maxJitterSec
+	}
+	return nil
+}
+
+// taskTimezone 任务时区，未设置时使用配置默认时区
+func (e *CronEngine) taskTimezone(task *CronTask) string {
+	if task.Timezone != "" {
+		return task.Timezone
+	}
+	return e.cfg.Timezone
+}
+
+func (e *CronEngine) taskLocation(task *CronTask) *time.Location {
+	loc, err := loadLocation(e.taskTimezone(task))
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// quietFor 任务生效的免打扰时段
+func (e *CronEngine) quietFor(task *CronTask) quietWindow {
+	if task.QuietHoursStart != "" && task.QuietHoursEnd != "" {
+		start, end := parseQuietHours(task.QuietHoursStart, task.QuietHoursEnd)
+		return quietWindow{start: start, end: end}
+	}
+	return e.quiet
+}
+
+// excludedBy 返回排除 at 当天的日历名，未排除返回空
+func (e *CronEngine) excludedBy(task *CronTask, at time.Time) string {
+	local := at.In(e.taskLocation(task))
+	for _, name := range task.ExcludeCalendars {
+		if cal, ok := e.calendars[name]; ok && cal.Excludes(local) {
+			return name
+		}
+	}
+	return ""
+}
+
+// markRun 记录最近处理的调度时间；任务文件延迟 runStateSaveDelay 合并保存，
+// 避免每次触发都重写整个文件（Stop 时落盘未保存的记录）
+func (e *CronEngine) markRun(taskID string, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	task, ok := e.tasks[taskID]
+	if !ok {
+		return
+	}
+	task.LastRunAt = at.Format(time.RFC3339)
+	if e.saveTimer == nil {
+		e.saveTimer = time.AfterFunc(runStateSaveDelay, e.flushRunState)
+	}
+}
+
+// flushRunState 保存延迟中的触发记录
+func (e *CronEngine) flushRunState() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.saveTimer == nil {
+		return
+	}
+	e.saveTimer = nil
+	e.saveToFile()
+}
+
+func catchUpTimerKey(taskID string) string {
+	return taskID + "#catchup"
+}
+
+// planCatchUp 计算任务在 (last_run_at, now] 内错过且本应执行的时间点，以及生效的补偿策略；
+// 从未触发过的任务从 created_at 起算。task 须为快照，调用方不持有 mu
+func (e *CronEngine) planCatchUp(task *CronTask, now time.Time) ([]time.Time, string) {
+	policy, _ := normalizeMissedPolicy(task.MissedPolicy, e.cfg.DefaultMissedPolicy)
+	since := task.LastRunAt
+	if since == "" {
+		since = task.CreatedAt
+	}
+	if task.Schedule == "" || since == "" {
+		return nil, policy
+	}
+	last, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return nil, policy
+	}
+	sched, err := parseTaskSchedule(task.Schedule, e.taskTimezone(task))
+	if err != nil {
+		return nil, policy
+	}
+
+	var runs []time.Time
+	for _, at := range missedRuns(sched, last, now, maxCatchUpRuns) {
+		// 本来就不会执行的时间点不算错过
+		if e.excludedBy(task, at) != "" {
+			continue
+		}
+		if !task.IgnoreQuiet && e.inQuietHours(task, at) {
+			continue
+		}
+		runs = append(runs, at)
+	}
+	return runs, policy
+}
+
+// catchUpMissed 启动时按各任务的补偿策略处理停机期间错过的执行
+func (e *CronEngine) catchUpMissed(startedAt time.Time) {
+	// 在锁内取快照，调度触发会并发更新 LastRunAt
+	e.mu.RLock()
+	tasks := make([]*CronTask, 0, len(e.tasks))
+	snapshots := make([]CronTask, 0, len(e.tasks))
+	for _, t := range e.tasks {
+		tasks = append(tasks, t)
+		snapshots = append(snapshots, *t)
+	}
+	e.mu.RUnlock()
+
+	type plan struct {
+		task *CronTask
+		runs []time.Time
+	}
+	var plans []plan
+	for i, task := range tasks {
+		runs, policy := e.planCatchUp(&snapshots[i], startedAt)
+		if len(runs) == 0 {
+			continue
+		}
+		log.Printf("[CronEngine] 任务 ID=%s name=%s 停机期间错过 %d 次执行 policy=%s",
+			task.ID, task.Name, len(runs), policy)
+		switch policy {
+		case MissedPolicyRunOnce:
+			plans = append(plans, plan{task, runs[len(runs)-1:]})
+		case MissedPolicyRunAll:
+			plans = append(plans, plan{task, runs})
+		default:
+			e.markRun(task.ID, runs[len(runs)-1])
+		}
+	}
+	if len(plans) == 0 {
+		return
+	}
+
+	// 等待连上 gateway 再发送；引擎停止或超时则放弃，last_run_at 不变，下次启动重新补偿
+	if !e.waitConnected(catchUpConnectWait) {
+		log.Printf("[CronEngine] ⚠ 未能连上 gateway，放弃本次补偿 (%d 个任务)", len(plans))
+		return
+	}
+	for _, p := range plans {
+		e.runCatchUp(p.task, p.runs)
+	}
+}
+
+// waitConnected 等待 gateway 连接，超时或引擎停止返回 false
+func (e *CronEngine) waitConnected(timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for !e.ab.IsConnected() {
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return false
+		case <-e.stop:
+			return false
+		}
+	}
+	return true
+}
+
+// runCatchUp 补执行错过的调度；当前处于免打扰时段则推迟到时段结束
+func (e *CronEngine) runCatchUp(task *CronTask, runs []time.Time) {
+	e.markRun(task.ID, runs[len(runs)-1])
+
+	run := func() {
+		executed := false
+		for _, at := range runs {
+			executed = e.fireLate(task, at) || executed
+		}
+		if executed && task.OneShot {
+			e.RemoveTask(task.ID)
+		}
+	}
+
+	now := time.Now()
+	if task.IgnoreQuiet || !e.inQuietHours(task, now) {
+		go run()
+		return
+	}
+
+	until := e.quietFor(task).endAfter(now.In(e.taskLocation(task)))
+	log.Printf("[CronEngine] 免打扰时段内，补执行推迟到 %s ID=%s", until.Format(time.RFC3339), task.ID)
+	ctx, cancel := context.WithCancel(context.Background())
+	key := catchUpTimerKey(task.ID)
+	e.mu.Lock()
+	e.timers[key] = cancel
+	e.mu.Unlock()
+	go func() {
+		select {
+		case <-time.After(time.Until(until)):
+			e.mu.Lock()
+			delete(e.timers, key)
+			e.mu.Unlock()
+			run()
+		case <-ctx.Done():
+		}
+	}()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadCalendarJSONAndICS(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "workdays.json")
+	os.WriteFile(jsonPath, []byte(`{"exclude_weekdays":[0,6],"exclude_dates":["2026-10-01"],"include_dates":["2026-10-10"]}`), 0644)
+	icsPath := filepath.Join(dir, "holidays.ics")
+	os.WriteFile(icsPath, []byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:国庆\r\nDTSTART;VALUE=DATE:20261001\r\nDTEND;VALUE=DATE:20261004\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nDTSTART:20261225T090000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"), 0644)
+
+	day := func(s string) time.Time {
+		d, _ := time.Parse(calendarDateLayout, s)
+		return d
+	}
+
+	work, err := LoadCalendar("workdays", jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for date, want := range map[string]bool{
+		"2026-10-01": true,  // 指定日期
+		"2026-10-03": true,  // 周六
+		"2026-10-10": false, // 周六但为调休上班日
+		"2026-10-12": false, // 周一
+	} {
+		if got := work.Excludes(day(date)); got != want {
+			t.Fatalf("workdays %s: got %v want %v", date, got, want)
+		}
+	}
+
+	hol, err := LoadCalendar("holidays", icsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for date, want := range map[string]bool{
+		"2026-10-01": true,
+		"2026-10-03": true,
+		"2026-10-04": false, // 全天事件 DTEND 不含当天
+		"2026-12-25": true,
+	} {
+		if got := hol.Excludes(day(date)); got != want {
+			t.Fatalf("holidays %s: got %v want %v", date, got, want)
+		}
+	}
+}
+
+func newTestEngine(t *testing.T) *CronEngine {
+	cfg := DefaultConfig()
+	cfg.TaskFile = filepath.Join(t.TempDir(), "cron-tasks.json")
+	return &CronEngine{
+		tasks:     make(map[string]*CronTask),
+		cfg:       cfg,
+		quiet:     quietWindow{start: -1, end: -1},
+		calendars: map[string]*Calendar{},
+	}
+}
+
+func TestPlanCatchUpHonorsTimezoneCalendarAndQuiet(t *testing.T) {
+	e := newTestEngine(t)
+	cal := &Calendar{Name: "holiday", ExcludeDates: []string{"2026-10-02"}}
+	cal.index()
+	e.calendars["holiday"] = cal
+
+	task := &CronTask{
+		ID:               "t1",
+		Schedule:         "0 20 * * *",
+		Timezone:         "America/New_York",
+		ExcludeCalendars: []string{"holiday"},
+		MissedPolicy:     MissedPolicyRunAll,
+		LastRunAt:        "2026-10-01T00:00:00Z",
+	}
+	if err := e.prepareTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	// 纽约 20:00 = UTC 次日 00:00；10-01、10-03 两次（10-02 被日历排除）
+	now := time.Date(2026, 10, 4, 1, 0, 0, 0, time.UTC)
+	runs, policy := e.planCatchUp(task, now)
+	if policy != MissedPolicyRunAll || len(runs) != 2 {
+		t.Fatalf("policy=%s runs=%v", policy, runs)
+	}
+	ny, _ := loadLocation("America/New_York")
+	if got := runs[0].In(ny).Format("2006-01-02 15:04"); got != "2026-10-01 20:00" {
+		t.Fatalf("first missed run %s", got)
+	}
+
+	// 任务级免打扰覆盖 20:00，错过的执行不需要补偿
+	task.QuietHoursStart, task.QuietHoursEnd = "19:00", "21:00"
+	if runs, _ := e.planCatchUp(task, now); len(runs) != 0 {
+		t.Fatalf("runs inside quiet hours should not be caught up: %v", runs)
+	}
+	task.IgnoreQuiet = true
+	if runs, _ := e.planCatchUp(task, now); len(runs) != 2 {
+		t.Fatalf("ignore_quiet task should catch up: %v", runs)
+	}
+
+	// 从未触发过的任务从 created_at 起算
+	task.LastRunAt, task.CreatedAt = "", "2026-10-02T12:00:00Z"
+	if runs, _ := e.planCatchUp(task, now); len(runs) != 1 {
+		t.Fatalf("expected catch-up from created_at: %v", runs)
+	}
+	task.CreatedAt = ""
+	if runs, _ := e.planCatchUp(task, now); len(runs) != 0 {
+		t.Fatalf("expected no catch-up without last_run_at or created_at: %v", runs)
+	}
+}
+
+func TestMarkRunDefersTaskFileSave(t *testing.T) {
+	e := newTestEngine(t)
+	e.tasks["t1"] = &CronTask{ID: "t1", Schedule: "0 8 * * *"}
+
+	at := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	e.markRun("t1", at)
+	e.markRun("t1", at.Add(24*time.Hour))
+	if _, err := os.Stat(e.cfg.TaskFile); !os.IsNotExist(err) {
+		t.Fatalf("markRun should not rewrite the task file immediately: %v", err)
+	}
+
+	e.flushRunState()
+	data, err := os.ReadFile(e.cfg.TaskFile)
+	if err != nil || !strings.Contains(string(data), "2026-10-02T08:00:00Z") {
+		t.Fatalf("flush should persist the latest last_run_at: %v %s", err, data)
+	}
+	if e.saveTimer != nil {
+		t.Fatalf("save timer should be cleared after flush")
+	}
+}
+
+func TestPrepareTaskValidation(t *testing.T) {
+	e := newTestEngine(t)
+	bad := []*CronTask{
+		{Timezone: "Mars/Olympus"},
+		{QuietHoursStart: "22:00"},
+		{QuietHoursStart: "25:00", QuietHoursEnd: "07:00"},
+		{ExcludeCalendars: []string{"missing"}},
+		{MissedPolicy: "sometimes"},
+	}
+	for _, task := range bad {
+		if err := e.prepareTask(task); err == nil {
+			t.Fatalf("expected error for %+v", task)
+		}
+	}
+	task := &CronTask{MissedPolicy: "ONCE", JitterSec: 999999}
+	if err := e.prepareTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if task.MissedPolicy != MissedPolicyRunOnce || task.JitterSec != maxJitterSec {
+		t.Fatalf("task not normalized: %+v", task)
+	}
+}
+
+func TestQuietWindowEndAfter(t *testing.T) {
+	q := quietWindow{start: 23 * 60, end: 7 * 60}
+	at := time.Date(2026, 10, 1, 23, 30, 0, 0, time.UTC)
+	if !q.contains(at) {
+		t.Fatal("23:30 should be quiet")
+	}
+	if end := q.endAfter(at); !end.Equal(time.Date(2026, 10, 2, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected quiet end %s", end)
+	}
+}
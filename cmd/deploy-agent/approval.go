@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultApprovalTimeoutSec = 3600
+
+// 审批状态
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+	ApprovalExpired  = "expired"
+)
+
+// PipelineApproval pipeline 审批门（仅内存保存，agent 重启后正在等待的 pipeline 随之终止）
+type PipelineApproval struct {
+	ID          string   `json:"id"`
+	Pipeline    string   `json:"pipeline"`
+	Stage       string   `json:"stage"`
+	Message     string   `json:"message,omitempty"`
+	Approvers   []string `json:"approvers,omitempty"`
+	RequestedBy string   `json:"requested_by,omitempty"`
+	Status      string   `json:"status"`
+	DecidedBy   string   `json:"decided_by,omitempty"`
+	Comment     string   `json:"comment,omitempty"`
+	CreatedAt   int64    `json:"created_at"`
+	ExpiresAt   int64    `json:"expires_at"`
+	DecidedAt   int64    `json:"decided_at,omitempty"`
+
+	done chan struct{}
+}
+
+// ApprovalManager 管理等待中的审批
+type ApprovalManager struct {
+	mu        sync.Mutex
+	approvals map[string]*PipelineApproval
+}
+
+// NewApprovalManager 创建审批管理器
+func NewApprovalManager() *ApprovalManager {
+	return &ApprovalManager{approvals: make(map[string]*PipelineApproval)}
+}
+
+// Request 创建审批，返回快照（ID 用于 DeployApprove）
+func (m *ApprovalManager) Request(pipeline string, stage *PipelineStep, requestedBy string) PipelineApproval {
+	timeout := stageTimeout(stage, defaultApprovalTimeoutSec)
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := newApprovalID()
+	for m.approvals[id] != nil {
+		id = newApprovalID()
+	}
+	a := &PipelineApproval{
+		ID:          id,
+		Pipeline:    pipeline,
+		Stage:       stage.Name,
+		Message:     stage.Message,
+		Approvers:   append([]string(nil), stage.Approvers...),
+		RequestedBy: requestedBy,
+		Status:      ApprovalPending,
+		CreatedAt:   now.UnixMilli(),
+		ExpiresAt:   now.Add(timeout).UnixMilli(),
+		done:        make(chan struct{}),
+	}
+	m.approvals[id] = a
+	return *a
+}
+
+// Wait 阻塞直到审批被处理、超时或 ctx 取消；未批准时返回错误
+func (m *ApprovalManager) Wait(ctx context.Context, id string) (PipelineApproval, error) {
+	m.mu.Lock()
+	a := m.approvals[id]
+	m.mu.Unlock()
+	if a == nil {
+		return PipelineApproval{}, fmt.Errorf("审批 %s 不存在", id)
+	}
+
+	timer := time.NewTimer(time.Until(time.UnixMilli(a.ExpiresAt)))
+	defer timer.Stop()
+	select {
+	case <-a.done:
+	case <-timer.C:
+		m.finish(a, ApprovalExpired, "", "超时未审批")
+	case <-ctx.Done():
+		m.finish(a, ApprovalExpired, "", ctx.Err().Error())
+	}
+
+	m.mu.Lock()
+	snapshot := *a
+	delete(m.approvals, id)
+	m.mu.Unlock()
+
+	switch snapshot.Status {
+	case ApprovalApproved:
+		return snapshot, nil
+	case ApprovalRejected:
+		if snapshot.Comment != "" {
+			return snapshot, fmt.Errorf("审批被 %s 拒绝: %s", snapshot.DecidedBy, snapshot.Comment)
+		}
+		return snapshot, fmt.Errorf("审批被 %s 拒绝", snapshot.DecidedBy)
+	default:
+		return snapshot, fmt.Errorf("审批未通过: %s", snapshot.Comment)
+	}
+}
+
+// Decide 批准或拒绝审批；user 必须是已认证用户且在审批人列表中，未配置审批人时一律拒绝操作
+func (m *ApprovalManager) Decide(id, user string, approve bool, comment string) (PipelineApproval, error) {
+	m.mu.Lock()
+	a := m.approvals[strings.TrimSpace(id)]
+	m.mu.Unlock()
+	if a == nil {
+		return PipelineApproval{}, fmt.Errorf("审批 %s 不存在或已结束", id)
+	}
+	user = strings.TrimSpace(user)
+	if user == "" {
+		return PipelineApproval{}, fmt.Errorf("审批需要已认证用户")
+	}
+	if len(a.Approvers) == 0 {
+		return PipelineApproval{}, fmt.Errorf("审批 %s 未配置审批人，不允许操作", id)
+	}
+	if !containsString(a.Approvers, user) {
+		return PipelineApproval{}, fmt.Errorf("用户 %q 不在审批人列表 %v 中", user, a.Approvers)
+	}
+
+	status := ApprovalRejected
+	if approve {
+		status = ApprovalApproved
+	}
+	if !m.finish(a, status, user, comment) {
+		return PipelineApproval{}, fmt.Errorf("审批 %s 已结束", id)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *a, nil
+}
+
+// Pending 列出等待中的审批（按创建时间）
+func (m *ApprovalManager) Pending() []PipelineApproval {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]PipelineApproval, 0, len(m.approvals))
+	for _, a := range m.approvals {
+		if a.Status == ApprovalPending {
+			out = append(out, *a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt < out[j].CreatedAt })
+	return out
+}
+
+func (m *ApprovalManager) finish(a *PipelineApproval, status, user, comment string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a.Status != ApprovalPending {
+		return false
+	}
+	a.Status = status
+	a.DecidedBy = user
+	a.Comment = comment
+	a.DecidedAt = time.Now().UnixMilli()
+	close(a.done)
+	return true
+}
+
+func newApprovalID() string {
+	buf := make([]byte, 3)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("ap-%06d", time.Now().UnixNano()%1000000)
+	}
+	return "ap-" + hex.EncodeToString(buf)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// approvalRequestText 审批通知文案（微信 / app）
+func approvalRequestText(a PipelineApproval) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔐 Pipeline %s 阶段 [%s] 等待审批", a.Pipeline, a.Stage)
+	if a.Message != "" {
+		fmt.Fprintf(&b, "\n%s", a.Message)
+	}
+	if a.RequestedBy != "" {
+		fmt.Fprintf(&b, "\n触发人: %s", a.RequestedBy)
+	}
+	fmt.Fprintf(&b, "\n审批 ID: %s（%s 前有效）", a.ID, time.UnixMilli(a.ExpiresAt).Format("01-02 15:04"))
+	fmt.Fprintf(&b, "\n回复「批准 %s」或「拒绝 %s」", a.ID, a.ID)
+	return b.String()
+}
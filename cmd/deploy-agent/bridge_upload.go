@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	bridgeChunkSize    = 4 * 1024 * 1024 // 分片大小
+	bridgeChunkRetries = 3               // 单个分片的重试次数
+)
+
+// errBridgeNoChunkedUpload bridge 版本过旧，不支持 /api/upload/init
+var errBridgeNoChunkedUpload = errors.New("bridge does not support chunked upload")
+
+// bridgeUploadSession /api/upload/init 响应
+type bridgeUploadSession struct {
+	UploadID    string `json:"upload_id"`
+	Filename    string `json:"filename"`
+	ChunkSize   int64  `json:"chunk_size"`
+	TotalChunks int    `json:"total_chunks"`
+	Received    []int  `json:"received"`
+	Skipped     bool   `json:"skipped"`
+}
+
+// bridgeUploadChunked 分片续传：init → 逐片 PUT（带 SHA-256）→ complete
+// 会话由文件 MD5 决定，上传中断后重新部署只补传缺失的分片
+func (d *Deployer) bridgeUploadChunked(bridgeURL, token, zipPath string) (string, error) {
+	info, err := os.Stat(zipPath)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	localMD5, err := d.localFileMD5(zipPath)
+	if err != nil {
+		return "", fmt.Errorf("计算文件MD5失败: %v", err)
+	}
+	base := strings.TrimRight(bridgeURL, "/")
+
+	var session bridgeUploadSession
+	status, err := bridgeRequest(http.MethodPost, base+"/api/upload/init", token, map[string]interface{}{
+		"filename":   filepath.Base(zipPath),
+		"size":       info.Size(),
+		"md5":        localMD5,
+		"chunk_size": bridgeChunkSize,
+		"signature":  d.packSignature,
+	}, &session)
+	if status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		return "", errBridgeNoChunkedUpload
+	}
+	if err != nil {
+		return "", fmt.Errorf("创建上传会话失败: %v", err)
+	}
+	if session.Skipped {
+		d.logf("info", "文件已存在于服务器（MD5相同）: %s，跳过上传\n", session.Filename)
+		return session.Filename, nil
+	}
+
+	received := make(map[int]bool, len(session.Received))
+	for _, i := range session.Received {
+		received[i] = true
+	}
+	if len(received) > 0 {
+		d.logf("info", "  > 续传: 服务端已有 %d/%d 个分片\n", len(received), session.TotalChunks)
+	}
+
+	file, err := os.Open(zipPath)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %v", err)
+	}
+	defer file.Close()
+
+	progress := &uploadProgressWriter{deployer: d, label: filepath.Base(zipPath), total: info.Size(), start: time.Now()}
+	buf := make([]byte, session.ChunkSize)
+	for i := 0; i < session.TotalChunks; i++ {
+		n, err := file.ReadAt(buf, int64(i)*session.ChunkSize)
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("读取分片 %d 失败: %v", i, err)
+		}
+		chunk := buf[:n]
+		if !received[i] {
+			if err := d.bridgePutChunk(base, token, session.UploadID, i, chunk); err != nil {
+				return "", err
+			}
+		}
+		progress.Write(chunk)
+	}
+
+	var done struct {
+		Filename string `json:"filename"`
+	}
+	if _, err := bridgeRequest(http.MethodPost, base+"/api/upload/"+session.UploadID+"/complete", token, nil, &done); err != nil {
+		return "", fmt.Errorf("合并分片失败: %v", err)
+	}
+	return done.Filename, nil
+}
+
+// bridgePutChunk 上传单个分片，失败时重试
+func (d *Deployer) bridgePutChunk(base, token, uploadID string, index int, chunk []byte) error {
+	sum := sha256.Sum256(chunk)
+	url := fmt.Sprintf("%s/api/upload/%s/chunk/%d", base, uploadID, index)
+
+	var lastErr error
+	for attempt := 1; attempt <= bridgeChunkRetries; attempt++ {
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(chunk))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("X-Chunk-SHA256", hex.EncodeToString(sum[:]))
+
+		client := &http.Client{Timeout: 2 * time.Minute}
+		resp, err := client.Do(req)
+		if err == nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+			if resp.StatusCode == http.StatusNotFound {
+				break // 会话已过期，重试无意义
+			}
+		} else {
+			lastErr = err
+		}
+		if attempt < bridgeChunkRetries {
+			d.logf("info", "  > 分片 %d 上传失败，重试 (%d/%d): %v\n", index, attempt, bridgeChunkRetries, lastErr)
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	return fmt.Errorf("上传分片 %d 失败（已完成的分片会在下次部署时续传）: %v", index, lastErr)
+}
+
+// bridgeRequest 发送 JSON 请求并解析响应，返回 HTTP 状态码
+func bridgeRequest(method, url, token string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// complete 需要在服务端合并整个包，超时放宽
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
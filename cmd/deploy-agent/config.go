@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -25,23 +26,26 @@ type Target struct {
 	Command      string // 本机命令型部署入口（type=command 时必填）
 	CommandArgs  []string
 	CommandEnv   map[string]string
-	WorkDir      string // 命令型部署工作目录（默认 ProjectDir）
+	WorkDir      string        // 命令型部署工作目录（默认 ProjectDir）
+	HealthChecks []HealthCheck // 部署后健康检查（command 类型不执行）
+	AutoRollback bool          // 健康检查失败时自动回滚到上一个健康版本
 }
 
 // ProjectConfig 项目级部署配置
 type ProjectConfig struct {
-	Name         string    // 项目名称（settings 文件名）
-	Aliases      []string  // 项目别名（支持中文）
-	AgentID      string    // 对应的 UAP agent_id（用于 ctrl_shutdown 等控制协议路由）
-	ProjectDir   string    // 项目根目录
-	PackScript   string    // 打包脚本路径
-	PackPattern  string    // 输出文件名模式（{date} → YYYYMMDD_HHMMSS）
-	BuildOnly    bool      // 仅支持打包，不支持部署
-	Targets      []*Target // 部署目标列表
-	ConfigFile   string    // 来源 settings 文件路径
-	Configured   bool      // 是否有持久化 settings（.json 文件）
-	ProtectFiles []string  // 增量部署时不覆盖的文件/目录
-	SetupDirs    []string  // 首次部署时自动创建的数据目录
+	Name         string         // 项目名称（settings 文件名）
+	Aliases      []string       // 项目别名（支持中文）
+	AgentID      string         // 对应的 UAP agent_id（用于 ctrl_shutdown 等控制协议路由）
+	ProjectDir   string         // 项目根目录
+	PackScript   string         // 打包脚本路径
+	PackPattern  string         // 输出文件名模式（{date} → YYYYMMDD_HHMMSS）
+	BuildOnly    bool           // 仅支持打包，不支持部署
+	Targets      []*Target      // 部署目标列表
+	ConfigFile   string         // 来源 settings 文件路径
+	Configured   bool           // 是否有持久化 settings（.json 文件）
+	ProtectFiles []string       // 增量部署时不覆盖的文件/目录
+	SetupDirs    []string       // 首次部署时自动创建的数据目录
+	Rollout      *RolloutConfig // 多目标发布策略（nil 时逐个部署）
 }
 
 // DeployConfig 全局部署配置
@@ -76,7 +80,21 @@ type DeployConfig struct {
 	GoBackendAgentID string // blog-agent-agent 在 gateway 中的 ID，默认 "blog-agent"
 
 	// Pipeline 编排
-	PipelinesDir string // pipelines/ 目录路径（自动推断）
+	PipelinesDir  string // pipelines/ 目录路径（自动推断）
+	TestAgentURL  string // test 阶段使用的 test-agent 控制台地址，默认 http://127.0.0.1:18086
+	WechatAgentID string // 审批通知发送的 wechat-agent ID，默认 wechat-wechat-agent
+
+	// 健康检查失败/自动回滚的 app 通知接收人，为空时通知部署触发人
+	HealthNotifyUser string
+
+	// 发布账本与回滚
+	ReleasesDir string         // 发布记录目录（默认配置文件同级 releases/）
+	ReleaseKeep int            // 每个 target 保留的发布包数量，默认 5
+	Releases    *ReleaseLedger // 发布账本（ReleasesDir 为空时为 nil，不记录）
+
+	// 发布包签名（bridge 配置 trusted_keys 时必须）
+	SigningKeyPath string             // Ed25519 私钥文件（deploy-agent genkey 生成）
+	SigningKey     ed25519.PrivateKey // 加载后的私钥（未配置时为 nil，不签名）
 
 	// 配置文件路径（用于 runInit 等需要引用配置路径的场景）
 	ConfigPath string
@@ -93,6 +111,12 @@ type deployConfigJSON struct {
 	SettingsDir      string   `json:"settings_dir"`
 	Workspaces       []string `json:"workspaces"`
 	GoBackendAgentID string   `json:"go_backend_agent_id,omitempty"`
+	ReleasesDir      string   `json:"releases_dir,omitempty"`
+	ReleaseKeep      int      `json:"release_keep,omitempty"`
+	TestAgentURL     string   `json:"test_agent_url,omitempty"`
+	WechatAgentID    string   `json:"wechat_agent_id,omitempty"`
+	HealthNotifyUser string   `json:"health_notify_user,omitempty"`
+	SigningKey       string   `json:"signing_key,omitempty"`
 }
 
 // DefaultProject 获取默认项目（仅一个项目时返回，否则返回 nil）
@@ -137,6 +161,7 @@ type projectJSON struct {
 	Targets      map[string]targetJSON `json:"targets"`
 	ProtectFiles []string              `json:"protect_files,omitempty"` // 增量部署时保护的文件
 	SetupDirs    []string              `json:"setup_dirs,omitempty"`    // 首次部署时创建的数据目录
+	Rollout      *RolloutConfig        `json:"rollout,omitempty"`       // 多目标发布策略
 }
 
 // buildJSON 构建配置
@@ -161,6 +186,8 @@ type targetJSON struct {
 	Args         []string          `json:"args,omitempty"`
 	Env          map[string]string `json:"env,omitempty"`
 	WorkDir      string            `json:"work_dir,omitempty"`
+	HealthChecks []HealthCheck     `json:"health_checks,omitempty"`
+	AutoRollback *bool             `json:"auto_rollback,omitempty"` // 默认 true（配置了 health_checks 时）
 }
 
 func cloneStringMap(src map[string]string) map[string]string {
@@ -258,6 +285,39 @@ func LoadConfig(path string, targetFilter string) (*DeployConfig, error) {
 
 	// 自动探测 pipelines 目录
 	cfg.detectPipelinesDir(path)
+	cfg.TestAgentURL = jcfg.TestAgentURL
+	if cfg.TestAgentURL == "" {
+		cfg.TestAgentURL = defaultTestAgentURL
+	}
+	cfg.HealthNotifyUser = strings.TrimSpace(jcfg.HealthNotifyUser)
+	cfg.WechatAgentID = jcfg.WechatAgentID
+	if cfg.WechatAgentID == "" {
+		cfg.WechatAgentID = defaultWechatAgentID
+	}
+
+	// 发布账本
+	cfg.ReleasesDir = jcfg.ReleasesDir
+	if cfg.ReleasesDir == "" {
+		cfg.ReleasesDir = "releases"
+	}
+	if !filepath.IsAbs(cfg.ReleasesDir) {
+		cfg.ReleasesDir = filepath.Join(filepath.Dir(path), cfg.ReleasesDir)
+	}
+	cfg.ReleaseKeep = jcfg.ReleaseKeep
+	cfg.Releases = NewReleaseLedger(cfg.ReleasesDir, cfg.ReleaseKeep)
+
+	// 发布包签名私钥
+	if jcfg.SigningKey != "" {
+		cfg.SigningKeyPath = jcfg.SigningKey
+		if !filepath.IsAbs(cfg.SigningKeyPath) {
+			cfg.SigningKeyPath = filepath.Join(filepath.Dir(path), cfg.SigningKeyPath)
+		}
+		key, err := loadSigningKey(cfg.SigningKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		cfg.SigningKey = key
+	}
 
 	return cfg, nil
 }
@@ -434,6 +494,12 @@ func mergeTargetJSON(global, project targetJSON) targetJSON {
 	if project.WorkDir != "" {
 		result.WorkDir = project.WorkDir
 	}
+	if len(project.HealthChecks) > 0 {
+		result.HealthChecks = append([]HealthCheck(nil), project.HealthChecks...)
+	}
+	if project.AutoRollback != nil {
+		result.AutoRollback = project.AutoRollback
+	}
 	return result
 }
 
@@ -520,6 +586,7 @@ func (c *DeployConfig) loadProjectsDir(settingsDir string) error {
 			existing.BuildOnly = proj.BuildOnly
 			existing.ProtectFiles = proj.ProtectFiles
 			existing.SetupDirs = proj.SetupDirs
+			existing.Rollout = proj.Rollout
 			c.registerProjectAliases(existing)
 		} else {
 			// 纯 .json 项目（不在 workspace 中），向后兼容
@@ -586,6 +653,10 @@ func (c *DeployConfig) parseProjectJSON(projName, filePath string, globalTargets
 		BuildOnly:    pj.BuildOnly,
 		ProtectFiles: pj.ProtectFiles,
 		SetupDirs:    pj.SetupDirs,
+		Rollout:      pj.Rollout,
+	}
+	if err := validateRollout(pj.Rollout); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filePath, err)
 	}
 
 	// 全局 pack_pattern
@@ -675,6 +746,9 @@ func (c *DeployConfig) parseProjectJSON(projName, filePath string, globalTargets
 			targets = append(targets, t)
 		}
 	}
+	if err := validateRolloutTargets(proj.Rollout, targets); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filePath, err)
+	}
 
 	return proj, targets, nil
 }
@@ -720,6 +794,12 @@ func (c *DeployConfig) parseTargetJSON(name string, tj *targetJSON) (*Target, er
 		deployType = "ssh"
 	}
 
+	if err := validateHealthChecks(tj.HealthChecks); err != nil {
+		return nil, fmt.Errorf("target %q: %v", targetName, err)
+	}
+	healthChecks := append([]HealthCheck(nil), tj.HealthChecks...)
+	autoRollback := len(healthChecks) > 0 && (tj.AutoRollback == nil || *tj.AutoRollback)
+
 	if deployType == "command" {
 		if tj.Command == "" {
 			return nil, fmt.Errorf("command is required for command target %q", targetName)
@@ -751,6 +831,8 @@ func (c *DeployConfig) parseTargetJSON(name string, tj *targetJSON) (*Target, er
 			RemoteScript: tj.RemoteScript,
 			Platform:     targetPlatform,
 			ServicePort:  tj.ServicePort,
+			HealthChecks: healthChecks,
+			AutoRollback: autoRollback,
 		}, nil
 	}
 
@@ -776,6 +858,8 @@ func (c *DeployConfig) parseTargetJSON(name string, tj *targetJSON) (*Target, er
 			BridgeURL:    tj.BridgeURL,
 			AuthToken:    tj.AuthToken,
 			ServicePort:  tj.ServicePort,
+			HealthChecks: healthChecks,
+			AutoRollback: autoRollback,
 		}, nil
 	}
 
@@ -801,6 +885,8 @@ func (c *DeployConfig) parseTargetJSON(name string, tj *targetJSON) (*Target, er
 		Platform:     targetPlatform,
 		Type:         "ssh",
 		ServicePort:  tj.ServicePort,
+		HealthChecks: healthChecks,
+		AutoRollback: autoRollback,
 	}, nil
 }
 
@@ -837,6 +923,8 @@ func generateDefaultConfig(configPath string) error {
 	defaultCfg := &deployConfigJSON{
 		SettingsDir:   "settings",
 		MaxConcurrent: 1,
+		ReleasesDir:   "releases",
+		ReleaseKeep:   defaultReleaseKeep,
 	}
 	data, err := json.MarshalIndent(defaultCfg, "", "  ")
 	if err != nil {
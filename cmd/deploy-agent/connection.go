@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -20,10 +21,12 @@ type Connection struct {
 	cfg         *DeployConfig
 	password    string
 	activeTasks map[string]bool
+	taskCancels map[string]context.CancelFunc // submitTask 提交的任务，task_stop 时取消
 	taskRecords map[string]*deployTaskRecord
 	taskOrder   []string
 	taskMu      sync.Mutex
 	fileToolKit *agentbase.FileToolKit
+	approvals   *ApprovalManager // pipeline 审批门
 }
 
 // NewConnection 创建连接管理器
@@ -63,8 +66,10 @@ func NewConnection(cfg *DeployConfig, password string, agentID string) *Connecti
 		cfg:         cfg,
 		password:    password,
 		activeTasks: make(map[string]bool),
+		taskCancels: make(map[string]context.CancelFunc),
 		taskRecords: make(map[string]*deployTaskRecord),
 		fileToolKit: fileToolKit,
+		approvals:   NewApprovalManager(),
 	}
 
 	// 注册消息处理器
@@ -95,15 +100,19 @@ func (c *Connection) handleTaskAssign(msg *uap.Message) {
 	log.Printf("[INFO] received deploy task: session=%s project=%s pipeline=%s target=%s pack_only=%v",
 		payload.SessionID, payload.Project, payload.Pipeline, payload.DeployTarget, payload.PackOnly)
 
+	if payload.User == "" {
+		payload.User = msg.From
+	}
+
 	rec := newDeployTaskRecord(payload.SessionID, "task_assign", payload)
 	sendEvent := func(level, text string) {
 		c.sendTaskStreamEvent(msg.From, payload.SessionID, level, text)
 	}
-	run := func() (map[string]any, error) {
+	run := func(ctx context.Context) (map[string]any, error) {
 		if payload.Pipeline != "" {
-			return c.runPipelineTask(payload, sendEvent)
+			return c.runPipelineTask(ctx, payload, sendEvent)
 		}
-		return c.runDeployTask(payload, sendEvent)
+		return c.runDeployTask(ctx, payload, sendEvent)
 	}
 
 	if err := c.submitTask(rec, msg.From, sendEvent, run); err != nil {
@@ -120,7 +129,12 @@ func (c *Connection) handleTaskAssign(msg *uap.Message) {
 func (c *Connection) handleTaskStop(msg *uap.Message) {
 	var payload TaskStopPayload
 	json.Unmarshal(msg.Payload, &payload)
-	log.Printf("[INFO] stop deploy task: session=%s (deploy not interruptible)", payload.SessionID)
+	if !c.stopTask(payload.SessionID) {
+		log.Printf("[INFO] stop deploy task: session=%s not running", payload.SessionID)
+		return
+	}
+	// 已发出的 SSH/上传步骤不可中断，取消只影响批次间等待与审批等可等待的步骤
+	log.Printf("[INFO] stop deploy task: session=%s cancelled", payload.SessionID)
 }
 
 // handleToolCancelCallback 处理工具取消回调
@@ -248,6 +262,12 @@ func buildArtifactResult(deployer *Deployer) map[string]any {
 			result["artifact_size"] = info.Size()
 		}
 	}
+	if len(deployer.releases) > 0 {
+		result["releases"] = deployer.releases
+	}
+	if len(deployer.rollout) > 0 {
+		result["rollout"] = deployer.rollout
+	}
 	return result
 }
 
@@ -287,7 +307,7 @@ func cloneProjectWithServicePort(proj *ProjectConfig, servicePort int, deployTar
 	return &cloned
 }
 
-func (c *Connection) runDeployTask(task TaskAssignPayload, sendEvent func(level, text string)) (map[string]any, error) {
+func (c *Connection) runDeployTask(ctx context.Context, task TaskAssignPayload, sendEvent func(level, text string)) (map[string]any, error) {
 	emit := func(level, text string) {
 		if sendEvent != nil {
 			sendEvent(level, text)
@@ -365,6 +385,8 @@ func (c *Connection) runDeployTask(task TaskAssignPayload, sendEvent func(level,
 	deployer := NewDeployer(&deployCfg, proj, c.password)
 	deployer.DeployMode = DeployMode(task.DeployMode)
 	deployer.CommandOptions = buildDeployCommandOptionsFromTask(task)
+	deployer.TriggerUser = task.User
+	deployer.Ctx = ctx
 	deployer.OnProgress = func(level, message string) {
 		evtType := "system"
 		prefix := "📦 "
@@ -374,6 +396,7 @@ func (c *Connection) runDeployTask(task TaskAssignPayload, sendEvent func(level,
 		}
 		emit(evtType, prefix+message)
 	}
+	deployer.OnHealthResult = c.healthReporter(emit, task.User)
 
 	if err := deployer.Run(packOnly, targetFilter); err != nil {
 		action := "部署"
@@ -403,7 +426,7 @@ func (c *Connection) runDeployTask(task TaskAssignPayload, sendEvent func(level,
 	return result, nil
 }
 
-func (c *Connection) runPipelineTask(task TaskAssignPayload, sendEvent func(level, text string)) (map[string]any, error) {
+func (c *Connection) runPipelineTask(ctx context.Context, task TaskAssignPayload, sendEvent func(level, text string)) (map[string]any, error) {
 	emit := func(level, text string) {
 		if sendEvent != nil {
 			sendEvent(level, text)
@@ -437,64 +460,170 @@ func (c *Connection) runPipelineTask(task TaskAssignPayload, sendEvent func(leve
 	if pip.Description != "" {
 		desc = " — " + pip.Description
 	}
-	emit("system", fmt.Sprintf("🔄 开始执行 Pipeline: %s%s (%d 步)", pip.Name, desc, len(pip.Steps)))
+	emit("system", fmt.Sprintf("🔄 开始执行 Pipeline: %s%s (%d 个阶段)", pip.Name, desc, len(pip.Stages)))
 
-	stepResults := make([]map[string]any, 0, len(pip.Steps))
-	for i, step := range pip.Steps {
-		proj := c.cfg.GetProject(step.Project)
-		if err := c.validateProjectTask(proj, step.PackOnly, step.Target); err != nil {
-			err = fmt.Errorf("pipeline %q step[%d] %s: %w", pip.Name, i, step.Project, err)
-			emit("error", "❌ "+err.Error())
-			return nil, err
+	results, err := RunPipeline(ctx, pip, c.pipelineStageExecutor(pip, task, emit), emit)
+	if err != nil {
+		emit("error", "❌ "+err.Error())
+		return nil, err
+	}
+
+	// steps 保留 deploy 阶段的产物信息（兼容旧结果格式）
+	stepResults := make([]map[string]any, 0, len(results))
+	for _, res := range results {
+		if res.Type == StageDeploy && res.Status == StageStatusSuccess {
+			stepResults = append(stepResults, res.Output)
 		}
+	}
+	emit("system", fmt.Sprintf("✅ Pipeline %q 全部完成 (%d 个阶段)", pip.Name, len(pip.Stages)))
+	return map[string]any{
+		"pipeline":    pip.Name,
+		"step_count":  len(stepResults),
+		"steps":       stepResults,
+		"stage_count": len(results),
+		"stages":      results,
+	}, nil
+}
 
-		deployCfg := *c.cfg
-		if step.PackOnly {
-			emit("system", fmt.Sprintf("📦 [%d/%d] 打包项目 [%s]...", i+1, len(pip.Steps), proj.Name))
-		} else {
-			targetLabel := step.Target
-			if targetLabel == "" {
-				targetLabel = "默认"
-			}
-			emit("system", fmt.Sprintf("🚀 [%d/%d] 部署项目 [%s] (目标: %s)...", i+1, len(pip.Steps), proj.Name, targetLabel))
+// pipelineStageExecutor 返回 daemon 模式下的阶段执行器
+func (c *Connection) pipelineStageExecutor(pip *Pipeline, task TaskAssignPayload, emit func(level, text string)) StageExecutor {
+	return func(ctx context.Context, stage *PipelineStep) (map[string]any, error) {
+		stageEmit := func(level, text string) {
+			emit(level, fmt.Sprintf("[%s] %s", stage.Name, text))
+		}
+		switch stage.Type {
+		case StageDeploy:
+			return c.runPipelineDeployStage(ctx, pip, stage, task, stageEmit)
+		case StageShell:
+			return runShellStage(ctx, c.cfg, stage, emit)
+		case StageTest:
+			return runTestStage(ctx, c.cfg, stage, emit)
+		case StageToolCall:
+			return c.runToolCallStage(ctx, stage)
+		case StageApproval:
+			return c.runApprovalStage(ctx, pip, stage, task.User, emit)
 		}
+		return nil, fmt.Errorf("未知的阶段类型 %q", stage.Type)
+	}
+}
 
-		deployer := NewDeployer(&deployCfg, proj, c.password)
-		deployer.CommandOptions = buildDeployCommandOptionsFromTask(task)
-		deployer.OnProgress = func(level, message string) {
-			evtType := "system"
-			prefix := "📦 "
-			if level == "error" {
-				evtType = "error"
-				prefix = "⚠️ "
-			}
-			emit(evtType, prefix+message)
+func (c *Connection) runPipelineDeployStage(ctx context.Context, pip *Pipeline, stage *PipelineStep, task TaskAssignPayload, emit func(level, text string)) (map[string]any, error) {
+	proj := c.cfg.GetProject(stage.Project)
+	if err := c.validateProjectTask(proj, stage.PackOnly, stage.Target); err != nil {
+		return nil, err
+	}
+
+	deployCfg := *c.cfg
+	deployer := NewDeployer(&deployCfg, proj, c.password)
+	deployer.CommandOptions = buildDeployCommandOptionsFromTask(task)
+	deployer.TriggerUser = task.User
+	deployer.Pipeline = pip.Name
+	deployer.Ctx = ctx
+	deployer.OnProgress = func(level, message string) {
+		evtType := "system"
+		prefix := "📦 "
+		if level == "error" {
+			evtType = "error"
+			prefix = "⚠️ "
 		}
+		emit(evtType, prefix+message)
+	}
+	deployer.OnHealthResult = c.healthReporter(emit, task.User)
 
-		if err := deployer.Run(step.PackOnly, step.Target); err != nil {
-			err = fmt.Errorf("Pipeline %q 在步骤 [%d/%d] %s 失败: %v", pip.Name, i+1, len(pip.Steps), proj.Name, err)
-			emit("error", "❌ "+err.Error())
-			return nil, err
+	if err := deployer.Run(stage.PackOnly, stage.Target); err != nil {
+		return nil, err
+	}
+	result := map[string]any{
+		"project":       proj.Name,
+		"deploy_target": stage.Target,
+		"pack_only":     stage.PackOnly,
+	}
+	for k, v := range buildArtifactResult(deployer) {
+		result[k] = v
+	}
+	return result, nil
+}
+
+// runToolCallStage 通过 UAP 调用其他 agent 的工具；未指定 agent 时从 gateway 工具目录查找
+func (c *Connection) runToolCallStage(ctx context.Context, stage *PipelineStep) (map[string]any, error) {
+	agentID := stage.Agent
+	if agentID != "" {
+		agentID = c.cfg.ResolveAgentID(agentID)
+	} else {
+		base := gatewayHTTPBase(c.cfg.ServerURL)
+		if base == "" {
+			return nil, fmt.Errorf("未配置 server_url，无法查找工具 %s", stage.Tool)
 		}
+		catalog := agentbase.NewToolCatalog(base)
+		if err := catalog.Discover(c.AgentID); err != nil {
+			return nil, fmt.Errorf("查询工具目录失败: %v", err)
+		}
+		id, ok := catalog.GetAgentID(stage.Tool)
+		if !ok {
+			return nil, fmt.Errorf("工具 %s 不在线", stage.Tool)
+		}
+		agentID = id
+	}
+
+	args, err := json.Marshal(stage.Args)
+	if err != nil {
+		return nil, fmt.Errorf("序列化工具参数失败: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, stageTimeout(stage, defaultToolTimeoutSec))
+	defer cancel()
+	reply, err := c.Client.Call(ctx, agentID, uap.MsgToolCall, uap.ToolCallPayload{
+		ToolName:  stage.Tool,
+		Arguments: args,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("调用 %s.%s 失败: %v", agentID, stage.Tool, err)
+	}
+	var res uap.ToolResultPayload
+	if err := json.Unmarshal(reply.Payload, &res); err != nil {
+		return nil, fmt.Errorf("解析 tool_result 失败: %v", err)
+	}
+	output := map[string]any{"agent": agentID, "tool": stage.Tool, "result": res.Result}
+	if !res.Success {
+		return output, fmt.Errorf("%s.%s 返回失败: %s", agentID, stage.Tool, res.Error)
+	}
+	return output, nil
+}
 
-		stepResult := map[string]any{
-			"project":       proj.Name,
-			"deploy_target": step.Target,
-			"pack_only":     step.PackOnly,
+// runApprovalStage 创建审批并通知审批人（微信 + app），阻塞直到批准、拒绝或超时
+func (c *Connection) runApprovalStage(ctx context.Context, pip *Pipeline, stage *PipelineStep, requestedBy string, emit func(level, text string)) (map[string]any, error) {
+	// 没有审批人就无人能批准，直接失败而不是等到超时
+	if len(stage.Approvers) == 0 {
+		return map[string]any{"status": ApprovalRejected}, fmt.Errorf("审批阶段 %s 未配置 approvers", stage.Name)
+	}
+	approval := c.approvals.Request(pip.Name, stage, requestedBy)
+	text := approvalRequestText(approval)
+	emit("system", text)
+
+	for _, user := range approval.Approvers {
+		if err := c.sendAppNotification(user, text); err != nil {
+			log.Printf("[WARN] send approval app notify failed user=%s err=%v", user, err)
 		}
-		for k, v := range buildArtifactResult(deployer) {
-			stepResult[k] = v
+		if err := c.Client.SendTo(c.cfg.WechatAgentID, uap.MsgNotify, uap.NotifyPayload{
+			Channel: "wechat",
+			To:      user,
+			Content: text,
+		}); err != nil {
+			log.Printf("[WARN] send approval wechat notify failed user=%s err=%v", user, err)
 		}
-		stepResults = append(stepResults, stepResult)
-		emit("system", fmt.Sprintf("✅ [%d/%d] %s 完成", i+1, len(pip.Steps), proj.Name))
 	}
 
-	emit("system", fmt.Sprintf("✅ Pipeline %q 全部完成 (%d 步)", pip.Name, len(pip.Steps)))
-	return map[string]any{
-		"pipeline":   pip.Name,
-		"step_count": len(stepResults),
-		"steps":      stepResults,
-	}, nil
+	decided, err := c.approvals.Wait(ctx, approval.ID)
+	output := map[string]any{
+		"approval_id": decided.ID,
+		"status":      decided.Status,
+		"decided_by":  decided.DecidedBy,
+		"comment":     decided.Comment,
+	}
+	if err != nil {
+		return output, err
+	}
+	emit("system", fmt.Sprintf("✅ [%s] 审批 %s 已由 %s 批准", stage.Name, decided.ID, decided.DecidedBy))
+	return output, nil
 }
 
 // executeDeploy 执行部署任务
@@ -600,6 +729,7 @@ func (c *Connection) executeDeploy(task TaskAssignPayload) {
 	deployer := NewDeployer(&deployCfg, proj, c.password)
 	deployer.DeployMode = DeployMode(task.DeployMode)
 	deployer.CommandOptions = buildDeployCommandOptionsFromTask(task)
+	deployer.TriggerUser = task.User
 	deployer.OnProgress = func(level, message string) {
 		evtType := "system"
 		prefix := "📦 "
@@ -609,6 +739,7 @@ func (c *Connection) executeDeploy(task TaskAssignPayload) {
 		}
 		sendEvent(evtType, prefix+message)
 	}
+	deployer.OnHealthResult = c.healthReporter(sendEvent, task.User)
 
 	err = deployer.Run(packOnly, targetFilter)
 
@@ -631,7 +762,7 @@ func (c *Connection) executeDeploy(task TaskAssignPayload) {
 	log.Printf("[INFO] deploy task %s (project=%s) completed, status=%s", sessionID, proj.Name, status)
 }
 
-// executePipeline 执行 pipeline 编排任务（顺序执行，失败即停）
+// executePipeline 执行 pipeline 编排任务（按 DAG 调度，结果通过 task_complete 回报）
 func (c *Connection) executePipeline(task TaskAssignPayload) {
 	sessionID := task.SessionID
 
@@ -652,113 +783,16 @@ func (c *Connection) executePipeline(task TaskAssignPayload) {
 		})
 	}
 
-	// 加载 pipelines 目录
-	if c.cfg.PipelinesDir == "" {
-		sendEvent("error", "❌ 未配置 pipelines/ 目录")
-		c.SendMsg(MsgTaskComplete, TaskCompletePayload{
-			SessionID: sessionID,
-			Status:    "error",
-			Error:     "pipelines directory not found",
-		})
-		return
-	}
-
-	pipCfg, err := LoadPipelines(c.cfg.PipelinesDir)
-	if err != nil {
-		sendEvent("error", fmt.Sprintf("❌ 加载 pipelines 失败: %v", err))
-		c.SendMsg(MsgTaskComplete, TaskCompletePayload{
-			SessionID: sessionID,
-			Status:    "error",
-			Error:     err.Error(),
-		})
-		return
-	}
-
-	pip := pipCfg.Get(task.Pipeline)
-	if pip == nil {
-		errMsg := fmt.Sprintf("pipeline %q 不存在，可用: %v", task.Pipeline, pipCfg.Names())
-		sendEvent("error", "❌ "+errMsg)
-		c.SendMsg(MsgTaskComplete, TaskCompletePayload{
-			SessionID: sessionID,
-			Status:    "error",
-			Error:     errMsg,
-		})
-		return
+	status, errMsg := "done", ""
+	if _, err := c.runPipelineTask(context.Background(), task, sendEvent); err != nil {
+		status, errMsg = "error", err.Error()
 	}
-
-	if err := ValidatePipeline(pip, c.cfg); err != nil {
-		sendEvent("error", fmt.Sprintf("❌ %v", err))
-		c.SendMsg(MsgTaskComplete, TaskCompletePayload{
-			SessionID: sessionID,
-			Status:    "error",
-			Error:     err.Error(),
-		})
-		return
-	}
-
-	desc := ""
-	if pip.Description != "" {
-		desc = " — " + pip.Description
-	}
-	sendEvent("system", fmt.Sprintf("🔄 开始执行 Pipeline: %s%s (%d 步)", pip.Name, desc, len(pip.Steps)))
-
-	for i, step := range pip.Steps {
-		proj := c.cfg.GetProject(step.Project)
-
-		// 浅拷贝 cfg
-		deployCfg := *c.cfg
-
-		packOnly := step.PackOnly
-		targetFilter := step.Target
-
-		if packOnly {
-			sendEvent("system", fmt.Sprintf("📦 [%d/%d] 打包项目 [%s]...",
-				i+1, len(pip.Steps), proj.Name))
-		} else {
-			targetLabel := targetFilter
-			if targetLabel == "" {
-				targetLabel = "默认"
-			}
-			sendEvent("system", fmt.Sprintf("🚀 [%d/%d] 部署项目 [%s] (目标: %s)...",
-				i+1, len(pip.Steps), proj.Name, targetLabel))
-		}
-
-		deployer := NewDeployer(&deployCfg, proj, c.password)
-		deployer.CommandOptions = buildDeployCommandOptionsFromTask(task)
-		deployer.OnProgress = func(level, message string) {
-			evtType := "system"
-			prefix := "📦 "
-			if level == "error" {
-				evtType = "error"
-				prefix = "⚠️ "
-			}
-			sendEvent(evtType, prefix+message)
-		}
-
-		stepErr := deployer.Run(packOnly, targetFilter)
-
-		if stepErr != nil {
-			errMsg := fmt.Sprintf("Pipeline %q 在步骤 [%d/%d] %s 失败: %v",
-				pip.Name, i+1, len(pip.Steps), proj.Name, stepErr)
-			sendEvent("error", "❌ "+errMsg)
-			c.SendMsg(MsgTaskComplete, TaskCompletePayload{
-				SessionID: sessionID,
-				Status:    "error",
-				Error:     errMsg,
-			})
-			log.Printf("[INFO] pipeline task %s failed at step %d/%d, status=error", sessionID, i+1, len(pip.Steps))
-			return
-		}
-
-		sendEvent("system", fmt.Sprintf("✅ [%d/%d] %s 完成", i+1, len(pip.Steps), proj.Name))
-	}
-
-	sendEvent("system", fmt.Sprintf("✅ Pipeline %q 全部完成 (%d 步)", pip.Name, len(pip.Steps)))
 	c.SendMsg(MsgTaskComplete, TaskCompletePayload{
 		SessionID: sessionID,
-		Status:    "done",
+		Status:    SessionStatus(status),
+		Error:     errMsg,
 	})
-	log.Printf("[INFO] pipeline task %s completed, all %d steps done", sessionID, len(pip.Steps))
+	log.Printf("[INFO] pipeline task %s (pipeline=%s) completed, status=%s", sessionID, task.Pipeline, status)
 }
 
 // canAccept 是否可以接受新任务
@@ -839,6 +873,7 @@ func buildDeployToolDefs(cfg *DeployConfig, ftk *agentbase.FileToolKit) []uap.To
 					"desc":             map[string]interface{}{"type": "string", "description": "命令型部署参数：描述"},
 					"private_key_path": map[string]interface{}{"type": "string", "description": "命令型部署参数：私钥路径，支持 ~/ 和相对路径"},
 					"project_path":     map[string]interface{}{"type": "string", "description": "命令型部署参数：项目目录覆盖，支持 ~/ 和相对路径"},
+					"user":             map[string]interface{}{"type": "string", "description": "触发人，记录到发布账本（不填则记录调用方）"},
 				},
 				"required": []string{"project"},
 			}),
@@ -877,6 +912,52 @@ func buildDeployToolDefs(cfg *DeployConfig, ftk *agentbase.FileToolKit) []uap.To
 				"required": []string{"pipeline"},
 			}),
 		},
+		{
+			Name:        "DeployListReleases",
+			Description: "列出项目在某个部署目标上的发布历史（包名、MD5、时间、触发人、pipeline，retained=true 表示包仍保留可回滚）。仅做查询，回滚前先用它确认 release_id。",
+			Parameters: mustMarshalJSON(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"project":       map[string]interface{}{"type": "string", "description": "项目名称或别名"},
+					"deploy_target": map[string]interface{}{"type": "string", "description": "部署目标名称，项目只有一个可回滚目标时可不填"},
+					"limit":         map[string]interface{}{"type": "integer", "description": "返回条数（默认 20）"},
+				},
+				"required": []string{"project"},
+			}),
+		},
+		{
+			Name:        "DeployRollback",
+			Description: "异步回滚项目到目标上保留的历史发布包（不重新打包），自动模式下按增量部署保护 protect_files。release_id 不填则回滚到上一个版本；调用后立即返回 session_id，进度通过 stream_event/task_complete 回流。",
+			Parameters: mustMarshalJSON(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"project":       map[string]interface{}{"type": "string", "description": "项目名称或别名"},
+					"deploy_target": map[string]interface{}{"type": "string", "description": "部署目标名称，项目只有一个可回滚目标时可不填"},
+					"release_id":    map[string]interface{}{"type": "integer", "description": "DeployListReleases 返回的发布 ID，不填则回滚到上一个版本"},
+					"deploy_mode":   map[string]interface{}{"type": "string", "enum": []string{"auto", "full", "increment"}, "description": "部署模式，默认 auto（回滚时等同 increment）"},
+					"user":          map[string]interface{}{"type": "string", "description": "触发人，记录到发布账本（不填则记录调用方）"},
+				},
+				"required": []string{"project"},
+			}),
+		},
+		{
+			Name:        "DeployListApprovals",
+			Description: "列出正在等待人工审批的 pipeline 阶段（审批 ID、pipeline、阶段、说明、审批人、过期时间）。",
+			Parameters:  mustMarshalJSON(map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}),
+		},
+		{
+			Name:        "DeployApprove",
+			Description: "批准或拒绝 pipeline 审批门。用户在微信或 app 中回复「批准 ap-xxxxxx」/「拒绝 ap-xxxxxx」时调用；批准后 pipeline 继续执行，拒绝则该阶段失败。",
+			Parameters: mustMarshalJSON(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"approval_id": map[string]interface{}{"type": "string", "description": "审批 ID（ap- 开头）"},
+					"approve":     map[string]interface{}{"type": "boolean", "description": "true=批准，false=拒绝"},
+					"comment":     map[string]interface{}{"type": "string", "description": "审批意见（可选）"},
+				},
+				"required": []string{"approval_id", "approve"},
+			}),
+		},
 	}
 	// 追加 DeployExecEnvBash（供 env-agent 远程执行环境检测命令）
 	for _, td := range ftk.ToolDefs() {
@@ -918,6 +999,13 @@ func (c *Connection) handleToolCall(msg *uap.Message) {
 
 	log.Printf("[INFO] tool_call from=%s tool=%s", msg.From, payload.ToolName)
 
+	// 触发人/审批人：优先使用已认证用户，未显式传 user 时记录调用方 agent
+	if authUser := strings.TrimSpace(payload.AuthenticatedUser); authUser != "" {
+		args["user"] = authUser
+	} else if user, _ := args["user"].(string); strings.TrimSpace(user) == "" {
+		args["user"] = msg.From
+	}
+
 	// 构建进度推送回调：通过 MsgNotify 将进度发送给调用方。
 	// 异步工具既推送到原始 msgID，也推送到 session_id，方便 llm-agent 在 accepted 后继续追踪。
 	sendProgressTo := func(trackID, text string) {
@@ -953,7 +1041,15 @@ func (c *Connection) handleToolCall(msg *uap.Message) {
 	case "DeployListPipelines":
 		result = c.toolListPipelines()
 	case "DeployPipeline":
-		result = c.toolDeployPipeline(args, sendAsyncProgress)
+		result = c.toolDeployPipeline(args, strings.TrimSpace(payload.AuthenticatedUser), sendAsyncProgress)
+	case "DeployListReleases":
+		result = c.toolListReleases(args)
+	case "DeployRollback":
+		result = c.toolDeployRollback(args, sendAsyncProgress)
+	case "DeployListApprovals":
+		result = c.toolListApprovals()
+	case "DeployApprove":
+		result = c.toolDeployApprove(args, strings.TrimSpace(payload.AuthenticatedUser))
 	default:
 		if result, handled := c.fileToolKit.HandleTool(payload.ToolName, args); handled {
 			c.Client.SendTo(msg.From, uap.MsgToolResult, uap.ToolResultPayload{
@@ -1026,6 +1122,7 @@ func (c *Connection) toolDeployProject(args map[string]interface{}, sendProgress
 	desc, _ := args["desc"].(string)
 	privateKeyPath, _ := args["private_key_path"].(string)
 	projectPathArg, _ := args["project_path"].(string)
+	user, _ := args["user"].(string)
 
 	proj, err := c.resolveProject(projectName)
 	if err != nil && projectDir != "" {
@@ -1064,11 +1161,12 @@ func (c *Connection) toolDeployProject(args map[string]interface{}, sendProgress
 		Desc:           desc,
 		PrivateKeyPath: privateKeyPath,
 		ProjectPath:    projectPathArg,
+		User:           user,
 	}
 	task.ProjectDir = proj.ProjectDir
 	rec := newDeployTaskRecord(task.SessionID, "DeployProject", task)
-	run := func() (map[string]any, error) {
-		return c.runDeployTask(task, func(_ string, text string) {
+	run := func(ctx context.Context) (map[string]any, error) {
+		return c.runDeployTask(ctx, task, func(_ string, text string) {
 			sendProgress(task.SessionID, text)
 		})
 	}
@@ -1120,8 +1218,8 @@ func (c *Connection) toolDeployAdhoc(args map[string]interface{}, sendProgress f
 		ServicePort: adhoc.ServicePort,
 	}
 	rec := newDeployTaskRecord(task.SessionID, "DeployAdhoc", task)
-	run := func() (map[string]any, error) {
-		return c.runDeployTask(task, func(_ string, text string) {
+	run := func(ctx context.Context) (map[string]any, error) {
+		return c.runDeployTask(ctx, task, func(_ string, text string) {
 			sendProgress(task.SessionID, text)
 		})
 	}
@@ -1145,24 +1243,31 @@ func (c *Connection) toolListPipelines() string {
 	}
 
 	type pipInfo struct {
-		Name        string `json:"name"`
-		Description string `json:"description,omitempty"`
-		Steps       int    `json:"steps"`
+		Name        string   `json:"name"`
+		Description string   `json:"description,omitempty"`
+		Steps       int      `json:"steps"`
+		Stages      []string `json:"stages"`
 	}
 	var pipelines []pipInfo
 	for _, p := range pipCfg.Pipelines {
+		stages := make([]string, 0, len(p.Stages))
+		for _, stage := range p.Stages {
+			stages = append(stages, stage.Name)
+		}
 		pipelines = append(pipelines, pipInfo{
 			Name:        p.Name,
 			Description: p.Description,
-			Steps:       len(p.Steps),
+			Steps:       len(p.Stages),
+			Stages:      stages,
 		})
 	}
 	tr := uap.BuildToolResult("", pipelines, fmt.Sprintf("列出%d个 pipeline", len(pipelines)))
 	return tr.Result
 }
 
-// toolDeployPipeline 执行部署编排 pipeline
-func (c *Connection) toolDeployPipeline(args map[string]interface{}, sendProgress func(string, string)) string {
+// toolDeployPipeline 执行部署编排 pipeline。触发人只取网关认证的用户：它出现在审批通知中，
+// 并决定健康检查失败推送给谁，不能由参数指定
+func (c *Connection) toolDeployPipeline(args map[string]interface{}, authUser string, sendProgress func(string, string)) string {
 	pipelineName, _ := args["pipeline"].(string)
 	if pipelineName == "" {
 		return buildToolErrorJSON(fmt.Errorf("缺少 pipeline 参数"))
@@ -1189,10 +1294,123 @@ func (c *Connection) toolDeployPipeline(args map[string]interface{}, sendProgres
 	task := TaskAssignPayload{
 		SessionID: newDeploySessionID("pipeline"),
 		Pipeline:  pip.Name,
+		User:      authUser,
 	}
 	rec := newDeployTaskRecord(task.SessionID, "DeployPipeline", task)
-	run := func() (map[string]any, error) {
-		return c.runPipelineTask(task, func(_ string, text string) {
+	run := func(ctx context.Context) (map[string]any, error) {
+		return c.runPipelineTask(ctx, task, func(_ string, text string) {
+			sendProgress(task.SessionID, text)
+		})
+	}
+	if err := c.submitTask(rec, "", nil, run); err != nil {
+		return buildToolErrorJSON(err)
+	}
+	sendProgress(task.SessionID, fmt.Sprintf("🕒 任务已接受 session_id=%s", task.SessionID))
+	return buildAcceptedTaskResult(rec)
+}
+
+// toolListReleases 列出项目在目标上的发布历史
+func (c *Connection) toolListReleases(args map[string]interface{}) string {
+	projectName, _ := args["project"].(string)
+	deployTarget, _ := args["deploy_target"].(string)
+	limit := parseOptionalIntArg(args, "limit")
+	if limit <= 0 {
+		limit = 20
+	}
+
+	proj, err := c.resolveProject(projectName)
+	if err != nil {
+		return buildToolErrorJSON(err)
+	}
+	if c.cfg.Releases == nil {
+		return buildToolErrorJSON(fmt.Errorf("未启用发布账本（releases_dir）"))
+	}
+	target, err := NewDeployer(c.cfg, proj, "").resolveRollbackTarget(deployTarget)
+	if err != nil {
+		return buildToolErrorJSON(err)
+	}
+	releases, err := c.cfg.Releases.List(proj.Name, target.Name)
+	if err != nil {
+		return buildToolErrorJSON(err)
+	}
+	if len(releases) > limit {
+		releases = releases[:limit]
+	}
+	data := map[string]any{
+		"project":  proj.Name,
+		"target":   target.Name,
+		"releases": releases,
+	}
+	tr := uap.BuildToolResult("", data, fmt.Sprintf("列出%d条发布记录", len(releases)))
+	return tr.Result
+}
+
+// toolListApprovals 列出等待中的审批
+func (c *Connection) toolListApprovals() string {
+	pending := c.approvals.Pending()
+	tr := uap.BuildToolResult("", pending, fmt.Sprintf("列出%d个待审批", len(pending)))
+	return tr.Result
+}
+
+// toolDeployApprove 批准或拒绝审批。审批人只取网关认证的用户，不接受参数或调用方 agent 冒充
+func (c *Connection) toolDeployApprove(args map[string]interface{}, authUser string) string {
+	if authUser == "" {
+		return buildToolErrorJSON(fmt.Errorf("审批需要已认证用户"))
+	}
+	approvalID, _ := args["approval_id"].(string)
+	if strings.TrimSpace(approvalID) == "" {
+		return buildToolErrorJSON(fmt.Errorf("缺少 approval_id 参数"))
+	}
+	approve, ok := args["approve"].(bool)
+	if !ok {
+		return buildToolErrorJSON(fmt.Errorf("缺少 approve 参数"))
+	}
+	comment, _ := args["comment"].(string)
+
+	decided, err := c.approvals.Decide(approvalID, authUser, approve, comment)
+	if err != nil {
+		return buildToolErrorJSON(err)
+	}
+	action := "拒绝"
+	if approve {
+		action = "批准"
+	}
+	log.Printf("[INFO] approval %s (pipeline=%s stage=%s) %s by %s", decided.ID, decided.Pipeline, decided.Stage, action, authUser)
+	tr := uap.BuildToolResult("", decided, fmt.Sprintf("已%s pipeline %s 阶段 %s", action, decided.Pipeline, decided.Stage))
+	return tr.Result
+}
+
+// toolDeployRollback 异步回滚到目标上保留的历史发布
+func (c *Connection) toolDeployRollback(args map[string]interface{}, sendProgress func(string, string)) string {
+	projectName, _ := args["project"].(string)
+	deployTarget, _ := args["deploy_target"].(string)
+	deployModeStr, _ := args["deploy_mode"].(string)
+	user, _ := args["user"].(string)
+
+	proj, err := c.resolveProject(projectName)
+	if err != nil {
+		return buildToolErrorJSON(err)
+	}
+	if c.cfg.Releases == nil {
+		return buildToolErrorJSON(fmt.Errorf("未启用发布账本（releases_dir）"))
+	}
+	target, err := NewDeployer(c.cfg, proj, "").resolveRollbackTarget(deployTarget)
+	if err != nil {
+		return buildToolErrorJSON(err)
+	}
+
+	task := TaskAssignPayload{
+		SessionID:    newDeploySessionID("rollback"),
+		Project:      proj.Name,
+		DeployTarget: target.Name,
+		DeployMode:   deployModeStr,
+		ReleaseID:    parseOptionalIntArg(args, "release_id"),
+		User:         user,
+	}
+	task.ProjectDir = proj.ProjectDir
+	rec := newDeployTaskRecord(task.SessionID, "DeployRollback", task)
+	run := func(context.Context) (map[string]any, error) {
+		return c.runRollbackTask(task, func(_ string, text string) {
 			sendProgress(task.SessionID, text)
 		})
 	}
@@ -1203,6 +1421,49 @@ func (c *Connection) toolDeployPipeline(args map[string]interface{}, sendProgres
 	return buildAcceptedTaskResult(rec)
 }
 
+// runRollbackTask 执行回滚任务
+func (c *Connection) runRollbackTask(task TaskAssignPayload, sendEvent func(level, text string)) (map[string]any, error) {
+	emit := func(level, text string) {
+		if sendEvent != nil {
+			sendEvent(level, text)
+		}
+	}
+
+	proj, err := c.resolveProject(task.Project)
+	if err != nil {
+		emit("error", fmt.Sprintf("❌ %v", err))
+		return nil, err
+	}
+	emit("system", fmt.Sprintf("⏪ 开始回滚项目 [%s] (目标: %s)...", proj.Name, task.DeployTarget))
+
+	deployCfg := *c.cfg
+	deployer := NewDeployer(&deployCfg, proj, c.password)
+	deployer.DeployMode = DeployMode(task.DeployMode)
+	deployer.TriggerUser = task.User
+	deployer.OnProgress = func(level, message string) {
+		evtType := "system"
+		prefix := "📦 "
+		if level == "error" {
+			evtType = "error"
+			prefix = "⚠️ "
+		}
+		emit(evtType, prefix+message)
+	}
+	deployer.OnHealthResult = c.healthReporter(emit, task.User)
+
+	release, err := deployer.Rollback(task.DeployTarget, task.ReleaseID)
+	if err != nil {
+		emit("error", fmt.Sprintf("❌ 回滚失败: %v", err))
+		return nil, err
+	}
+	emit("system", fmt.Sprintf("✅ 项目 %s 已回滚到 %s", proj.Name, release.Package))
+	return map[string]any{
+		"project":       proj.Name,
+		"deploy_target": task.DeployTarget,
+		"release":       release,
+	}, nil
+}
+
 // mustMarshalJSON 将值序列化为 JSON，失败时返回空对象
 func mustMarshalJSON(v interface{}) json.RawMessage {
 	data, err := json.Marshal(v)
@@ -2,12 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -36,10 +38,19 @@ type Deployer struct {
 	password       string
 	packFile       string                      // 打包后的 zip 文件名（不含路径）
 	packSource     string                      // 打包产物在本地的完整路径
+	packSignature  string                      // 打包产物的 Ed25519 签名（base64，未配置 signing_key 时为空）
 	SSHConnected   bool                        // SSH 连接是否成功过（密码有效）
 	OnProgress     func(level, message string) // daemon 模式进度回调（nil 则输出到 stdout）
 	DeployMode     DeployMode                  // 部署模式: auto/full/increment
 	CommandOptions DeployCommandOptions        // 命令型部署运行参数
+	TriggerUser    string                      // 触发人（写入发布账本）
+	Pipeline       string                      // 所属 pipeline（写入发布账本）
+	OnHealthResult func(outcome HealthOutcome) // 健康检查/自动回滚结果回调
+	Ctx            context.Context             // 任务上下文，取消后不再开始新的发布批次（nil 为不可取消）
+
+	rollbackTo *ReleaseRecord  // 非 nil 时为回滚：复用目标上保留的发布包
+	releases   []ReleaseRecord // 本次运行写入账本的记录
+	rollout    []RolloutBatch  // 本次运行的批次结果（配置了 rollout 时）
 }
 
 type uploadProgressWriter struct {
@@ -203,6 +214,12 @@ func (d *Deployer) Run(packOnly bool, targetFilter string) error {
 			return fmt.Errorf("找不到打包文件: %v", err)
 		}
 		d.logf("info", "[STEP 1/%d] 打包完成: %s (%s)\n", totalSteps, d.packFile, formatSize(info.Size()))
+		if err := d.signPack(); err != nil {
+			return fmt.Errorf("打包失败: %v", err)
+		}
+		if d.packSignature != "" {
+			d.logf("info", "  > 已签名: %s%s\n", d.packFile, packageSigSuffix)
+		}
 	}
 
 	if packOnly {
@@ -210,30 +227,36 @@ func (d *Deployer) Run(packOnly bool, targetFilter string) error {
 		return nil
 	}
 
-	// Step 2-4: 逐个目标部署
-	var errs []string
-	for _, t := range targets {
-		var err error
+	deployTarget := func(t *Target) error {
 		if t.Type == "command" {
 			stepIndex := 2
 			if !needsPack {
 				stepIndex = 1
 			}
-			err = d.deployCommand(t, stepIndex, totalSteps)
+			return d.deployCommand(t, stepIndex, totalSteps)
 		} else if isLocalTarget(t.Host) {
-			err = d.deployLocal(t, totalSteps)
+			return d.deployLocal(t, totalSteps)
 		} else if t.Type == "bridge" {
-			err = d.deployBridge(t, totalSteps)
-		} else {
-			err = d.deployRemote(t, totalSteps)
-		}
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("[%s] %v", t.Name, err))
+			return d.deployBridge(t, totalSteps)
 		}
+		return d.deployRemote(t, totalSteps)
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("部署失败: %s", strings.Join(errs, "; "))
+	// Step 2-4: 配置了发布策略时按批次发布，否则逐个目标部署
+	if d.proj.Rollout != nil {
+		if err := d.runRollout(targets, deployTarget); err != nil {
+			return fmt.Errorf("部署失败: %v", err)
+		}
+	} else {
+		var errs []string
+		for _, t := range targets {
+			if err := deployTarget(t); err != nil {
+				errs = append(errs, fmt.Sprintf("[%s] %v", t.Name, err))
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("部署失败: %s", strings.Join(errs, "; "))
+		}
 	}
 
 	d.logf("info", "[DONE] 项目 [%s] 部署完成，耗时 %s\n", d.proj.Name, formatDuration(time.Since(start)))
@@ -433,6 +456,7 @@ func (d *Deployer) deployRemote(t *Target, totalSteps int) error {
 		label = fmt.Sprintf("%s (%s)", t.Name, t.Host)
 	}
 
+	deployStart := time.Now()
 	user, host := parseHost(t.Host)
 	d.logf("info", "[STEP 2/%d] 连接 %s...\n", totalSteps, label)
 	client, err := d.connectSSH(user, host, t.Port)
@@ -446,10 +470,18 @@ func (d *Deployer) deployRemote(t *Target, totalSteps int) error {
 	binaryPath := t.RemoteDir + "/" + d.binaryName(t)
 	beforeMD5 := md5Remote(client, binaryPath)
 
-	d.logf("info", "[STEP 2/%d] 上传到 %s:%s...\n", totalSteps, t.Host, t.RemoteDir)
-	if err := d.upload(client, t); err != nil {
-		d.logf("error", "[ERROR] 上传到 %s 失败: %v\n", label, err)
-		return fmt.Errorf("上传到 %s 失败: %v", label, err)
+	if d.rollbackTo != nil {
+		d.logf("info", "[STEP 2/%d] 使用 %s:%s 上保留的发布包 %s\n", totalSteps, t.Host, t.RemoteDir, d.packFile)
+		if err := d.runRemoteCmd(client, "test -f "+shellQuote(t.RemoteDir+"/"+d.packFile)); err != nil {
+			d.logf("error", "[ERROR] 发布包 %s 不存在于 %s\n", d.packFile, label)
+			return fmt.Errorf("发布包 %s 不存在于 %s: %v", d.packFile, label, err)
+		}
+	} else {
+		d.logf("info", "[STEP 2/%d] 上传到 %s:%s...\n", totalSteps, t.Host, t.RemoteDir)
+		if err := d.upload(client, t); err != nil {
+			d.logf("error", "[ERROR] 上传到 %s 失败: %v\n", label, err)
+			return fmt.Errorf("上传到 %s 失败: %v", label, err)
+		}
 	}
 
 	d.logf("info", "[STEP 3/%d] 解压到 %s:%s...\n", totalSteps, t.Host, t.RemoteDir)
@@ -511,6 +543,21 @@ func (d *Deployer) deployRemote(t *Target, totalSteps int) error {
 	afterMD5 := md5Remote(client, binaryPath)
 	d.logMD5Diff(beforeMD5, afterMD5)
 
+	pruned, verifyErr := d.verifyRelease(t, afterMD5, deployStart, remoteHealthRunner(client, t.RemoteDir))
+	if len(pruned) > 0 {
+		rmCmd := "cd " + shellQuote(t.RemoteDir) + " && rm -f"
+		for _, pkg := range pruned {
+			rmCmd += " " + shellQuote(pkg)
+		}
+		if err := d.runRemoteCmd(client, rmCmd); err != nil {
+			d.logf("info", "  > 清理旧发布包警告: %v\n", err)
+		}
+	}
+	if verifyErr != nil {
+		d.logf("error", "[ERROR] %s %v\n", label, verifyErr)
+		return fmt.Errorf("%s %v", label, verifyErr)
+	}
+
 	d.logf("info", "[OK] %s 部署成功\n", label)
 	return nil
 }
@@ -522,6 +569,7 @@ func (d *Deployer) deployLocal(t *Target, totalSteps int) error {
 		label = t.Name + " (local)"
 	}
 
+	deployStart := time.Now()
 	targetDir := t.RemoteDir
 	d.logf("info", "[STEP 2/%d] 本机部署到 %s...\n", totalSteps, targetDir)
 
@@ -540,7 +588,13 @@ func (d *Deployer) deployLocal(t *Target, totalSteps int) error {
 	dstPath := filepath.Join(targetDir, d.packFile)
 	absSrc, _ := filepath.Abs(srcPath)
 	absDst, _ := filepath.Abs(dstPath)
-	if absSrc != absDst {
+	if d.rollbackTo != nil {
+		if _, err := os.Stat(dstPath); err != nil {
+			d.logf("error", "[ERROR] 发布包 %s 不存在: %v\n", dstPath, err)
+			return fmt.Errorf("发布包 %s 不存在: %v", dstPath, err)
+		}
+		d.logf("info", "  > 使用保留的发布包 %s\n", dstPath)
+	} else if absSrc != absDst {
 		if err := copyFile(srcPath, dstPath); err != nil {
 			d.logf("error", "[ERROR] 复制到 %s 失败: %v\n", dstPath, err)
 			return fmt.Errorf("复制到 %s 失败: %v", dstPath, err)
@@ -620,6 +674,17 @@ func (d *Deployer) deployLocal(t *Target, totalSteps int) error {
 	afterMD5 := md5File(binaryPath)
 	d.logMD5Diff(beforeMD5, afterMD5)
 
+	pruned, verifyErr := d.verifyRelease(t, afterMD5, deployStart, localHealthRunner(targetDir))
+	for _, pkg := range pruned {
+		if err := os.Remove(filepath.Join(targetDir, pkg)); err != nil && !os.IsNotExist(err) {
+			d.logf("info", "  > 清理旧发布包警告: %v\n", err)
+		}
+	}
+	if verifyErr != nil {
+		d.logf("error", "[ERROR] %s %v\n", label, verifyErr)
+		return fmt.Errorf("%s %v", label, verifyErr)
+	}
+
 	d.logf("info", "[OK] %s 部署成功\n", label)
 	return nil
 }
@@ -650,16 +715,23 @@ func (d *Deployer) deployBridge(t *Target, totalSteps int) error {
 		label = t.BridgeURL
 	}
 
+	deployStart := time.Now()
 	zipPath := d.packLocalPath()
 
-	// Step 2: 上传
-	d.logf("info", "[STEP 2/%d] 上传到 Bridge %s...\n", totalSteps, label)
-	filename, err := d.bridgeUpload(t.BridgeURL, t.AuthToken, zipPath)
-	if err != nil {
-		d.logf("error", "[ERROR] 上传到 Bridge 失败: %v\n", err)
-		return fmt.Errorf("上传到 Bridge 失败: %v", err)
+	// Step 2: 上传（回滚时直接使用 bridge 上保留的包）
+	filename := d.packFile
+	if d.rollbackTo != nil {
+		d.logf("info", "[STEP 2/%d] 使用 Bridge %s 上保留的发布包 %s\n", totalSteps, label, filename)
+	} else {
+		d.logf("info", "[STEP 2/%d] 上传到 Bridge %s...\n", totalSteps, label)
+		uploaded, err := d.bridgeUpload(t.BridgeURL, t.AuthToken, zipPath)
+		if err != nil {
+			d.logf("error", "[ERROR] 上传到 Bridge 失败: %v\n", err)
+			return fmt.Errorf("上传到 Bridge 失败: %v", err)
+		}
+		filename = uploaded
+		d.logf("info", "[STEP 2/%d] 上传完成: %s\n", totalSteps, filename)
 	}
-	d.logf("info", "[STEP 2/%d] 上传完成: %s\n", totalSteps, filename)
 
 	// Step 3: 触发部署
 	script := t.RemoteScript
@@ -681,13 +753,38 @@ func (d *Deployer) deployBridge(t *Target, totalSteps int) error {
 		return fmt.Errorf("Bridge 部署失败: %v", err)
 	}
 
+	// bridge 可能按 MD5 复用已有包名，账本记录实际部署的文件名
+	d.packFile = filename
+	// bridge 目标的 command 检查在本机执行
+	pruned, verifyErr := d.verifyRelease(t, "", deployStart, localHealthRunner(d.proj.ProjectDir))
+	for _, pkg := range pruned {
+		if err := d.bridgeDeletePackage(t.BridgeURL, t.AuthToken, pkg); err != nil {
+			d.logf("info", "  > 清理旧发布包 %s 警告: %v\n", pkg, err)
+		}
+	}
+	if verifyErr != nil {
+		d.logf("error", "[ERROR] %s %v\n", label, verifyErr)
+		return fmt.Errorf("%s %v", label, verifyErr)
+	}
+
 	d.logf("info", "[OK] %s Bridge 部署成功\n", label)
 	return nil
 }
 
-// bridgeUpload 上传 zip 到 bridge server (POST /api/upload multipart)
-// 会先计算本地 MD5 并查询服务端，相同 MD5 的文件不重复上传
+// bridgeUpload 上传 zip 到 bridge server
+// 优先使用分片续传协议，bridge 不支持时退回整包上传
 func (d *Deployer) bridgeUpload(bridgeURL, token, zipPath string) (string, error) {
+	filename, err := d.bridgeUploadChunked(bridgeURL, token, zipPath)
+	if err == errBridgeNoChunkedUpload {
+		d.logf("info", "  > Bridge 不支持分片上传，使用整包上传\n")
+		return d.bridgeUploadWhole(bridgeURL, token, zipPath)
+	}
+	return filename, err
+}
+
+// bridgeUploadWhole 整包上传 zip (POST /api/upload multipart)
+// 会先计算本地 MD5 并查询服务端，相同 MD5 的文件不重复上传
+func (d *Deployer) bridgeUploadWhole(bridgeURL, token, zipPath string) (string, error) {
 	// 计算本地文件 MD5
 	localMD5, err := d.localFileMD5(zipPath)
 	if err != nil {
@@ -713,6 +810,10 @@ func (d *Deployer) bridgeUpload(bridgeURL, token, zipPath string) (string, error
 	// 构建 multipart body
 	var buf bytes.Buffer
 	boundary := fmt.Sprintf("----deploy-%d", time.Now().UnixNano())
+	if d.packSignature != "" {
+		buf.WriteString(fmt.Sprintf("--%s\r\nContent-Disposition: form-data; name=\"signature\"\r\n\r\n%s\r\n",
+			boundary, d.packSignature))
+	}
 	writer := fmt.Sprintf("--%s\r\nContent-Disposition: form-data; name=\"file\"; filename=\"%s\"\r\nContent-Type: application/zip\r\n\r\n",
 		boundary, filepath.Base(zipPath))
 	buf.WriteString(writer)
@@ -783,6 +884,28 @@ func (d *Deployer) bridgePackages(bridgeURL, token string) ([]bridgePackageInfo,
 	return pkgs, nil
 }
 
+// bridgeDeletePackage 删除服务端已上传的包 (DELETE /api/packages?name=)
+func (d *Deployer) bridgeDeletePackage(bridgeURL, token, name string) error {
+	req, err := http.NewRequest("DELETE", strings.TrimRight(bridgeURL, "/")+"/api/packages?name="+url.QueryEscape(name), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 type bridgePackageInfo struct {
 	Name string `json:"name"`
 	Size int64  `json:"size"`
@@ -1347,6 +1470,11 @@ func parseHost(hostStr string) (string, string) {
 	return "root", hostStr
 }
 
+// shellQuote 将参数包成单引号字符串，供远程 shell 命令使用
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // formatSize 格式化文件大小
 func formatSize(bytes int64) string {
 	const (
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 健康检查类型
+const (
+	HealthCheckTCP     = "tcp"     // TCP 端口可连接
+	HealthCheckHTTP    = "http"    // HTTP 状态码 / 响应体匹配
+	HealthCheckCommand = "command" // 命令退出码（SSH 目标在远程执行，其余在本机执行）
+	HealthCheckAgent   = "agent"   // agent 在 gateway 重新注册
+)
+
+const (
+	defaultHealthRetries      = 5
+	defaultHealthIntervalSec  = 3
+	defaultHealthTimeoutSec   = 5
+	defaultAgentHealthWaitSec = 60
+)
+
+// HealthCheck 部署后健康检查配置（settings 中 targets.<name>.health_checks）
+type HealthCheck struct {
+	Type         string `json:"type"`                    // tcp | http | command | agent
+	Address      string `json:"address,omitempty"`       // tcp: host:port，默认 target 主机 + service_port
+	URL          string `json:"url,omitempty"`           // http: 完整 URL，或以 / 开头的路径（拼接 target 主机 + service_port）
+	Status       int    `json:"status,omitempty"`        // http: 期望状态码，默认 200
+	BodyContains string `json:"body_contains,omitempty"` // http: 响应体需包含的文本
+	Command      string `json:"command,omitempty"`       // command: 检查命令
+	ExitCode     int    `json:"exit_code,omitempty"`     // command: 期望退出码，默认 0
+	AgentID      string `json:"agent_id,omitempty"`      // agent: 默认项目 agent_id
+	Retries      int    `json:"retries,omitempty"`       // 最多尝试次数，默认 5（agent 类型不使用）
+	IntervalSec  int    `json:"interval_sec,omitempty"`  // 重试间隔，默认 3s
+	TimeoutSec   int    `json:"timeout_sec,omitempty"`   // 单次检查超时，默认 5s；agent 类型为等待注册的总时长，默认 60s
+}
+
+// HealthOutcome 健康检查与自动回滚结果（通过 Deployer.OnHealthResult 回调上报）
+type HealthOutcome struct {
+	Project         string         `json:"project"`
+	Target          string         `json:"target"`
+	Package         string         `json:"package"`
+	Healthy         bool           `json:"healthy"`
+	Error           string         `json:"error,omitempty"`
+	RolledBack      bool           `json:"rolled_back,omitempty"`
+	RollbackRelease *ReleaseRecord `json:"rollback_release,omitempty"`
+	RollbackError   string         `json:"rollback_error,omitempty"`
+}
+
+// healthCommandRunner 执行检查命令，返回退出码和输出
+type healthCommandRunner func(command string, timeout time.Duration) (int, string, error)
+
+func (hc HealthCheck) label() string {
+	switch hc.Type {
+	case HealthCheckTCP:
+		return "tcp " + hc.Address
+	case HealthCheckHTTP:
+		return "http " + hc.URL
+	case HealthCheckCommand:
+		return "command " + hc.Command
+	case HealthCheckAgent:
+		return "agent " + hc.AgentID
+	}
+	return hc.Type
+}
+
+// validateHealthChecks 加载配置时校验检查项
+func validateHealthChecks(checks []HealthCheck) error {
+	for i, hc := range checks {
+		switch hc.Type {
+		case HealthCheckTCP, HealthCheckAgent:
+		case HealthCheckHTTP:
+			if hc.URL == "" {
+				return fmt.Errorf("health_checks[%d]: url is required for http check", i)
+			}
+		case HealthCheckCommand:
+			if hc.Command == "" {
+				return fmt.Errorf("health_checks[%d]: command is required for command check", i)
+			}
+		default:
+			return fmt.Errorf("health_checks[%d]: unknown type %q", i, hc.Type)
+		}
+	}
+	return nil
+}
+
+// targetCheckHost 返回健康检查连接的主机名
+func targetCheckHost(t *Target) string {
+	if isLocalTarget(t.Host) {
+		return "127.0.0.1"
+	}
+	if t.Type == "bridge" {
+		if u, err := url.Parse(t.BridgeURL); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+	_, host := parseHost(t.Host)
+	return host
+}
+
+// resolveHealthCheck 补全默认值（地址、agent_id、重试与超时）
+func (d *Deployer) resolveHealthCheck(t *Target, hc HealthCheck) (HealthCheck, error) {
+	host := targetCheckHost(t)
+	switch hc.Type {
+	case HealthCheckTCP:
+		if hc.Address == "" {
+			if t.ServicePort <= 0 {
+				return hc, fmt.Errorf("tcp check requires address or service_port")
+			}
+			hc.Address = net.JoinHostPort(host, fmt.Sprint(t.ServicePort))
+		}
+	case HealthCheckHTTP:
+		if strings.HasPrefix(hc.URL, "/") {
+			if t.ServicePort <= 0 {
+				return hc, fmt.Errorf("http check with path %q requires service_port", hc.URL)
+			}
+			hc.URL = "http://" + net.JoinHostPort(host, fmt.Sprint(t.ServicePort)) + hc.URL
+		}
+		if hc.Status == 0 {
+			hc.Status = http.StatusOK
+		}
+	case HealthCheckAgent:
+		if hc.AgentID == "" {
+			hc.AgentID = d.proj.AgentID
+		}
+		if hc.AgentID == "" {
+			return hc, fmt.Errorf("agent check requires agent_id (project agent_id is empty)")
+		}
+		if hc.TimeoutSec <= 0 {
+			hc.TimeoutSec = defaultAgentHealthWaitSec
+		}
+	}
+	if hc.Retries <= 0 {
+		hc.Retries = defaultHealthRetries
+	}
+	if hc.IntervalSec <= 0 {
+		hc.IntervalSec = defaultHealthIntervalSec
+	}
+	if hc.TimeoutSec <= 0 {
+		hc.TimeoutSec = defaultHealthTimeoutSec
+	}
+	return hc, nil
+}
+
+// runHealthChecks 依次执行 target 上配置的健康检查，任一检查耗尽重试即失败
+func (d *Deployer) runHealthChecks(t *Target, deployStart time.Time, runCmd healthCommandRunner) error {
+	if len(t.HealthChecks) == 0 {
+		return nil
+	}
+	d.logf("info", "[HEALTH] 检查 %s 服务状态 (%d 项)...\n", t.Name, len(t.HealthChecks))
+	for _, raw := range t.HealthChecks {
+		hc, err := d.resolveHealthCheck(t, raw)
+		if err != nil {
+			return fmt.Errorf("%s: %v", raw.Type, err)
+		}
+		if err := d.runHealthCheck(hc, deployStart, runCmd); err != nil {
+			d.logf("error", "[HEALTH] ✗ %s: %v\n", hc.label(), err)
+			return fmt.Errorf("%s: %v", hc.label(), err)
+		}
+		d.logf("info", "[HEALTH] ✓ %s\n", hc.label())
+	}
+	return nil
+}
+
+func (d *Deployer) runHealthCheck(hc HealthCheck, deployStart time.Time, runCmd healthCommandRunner) error {
+	timeout := time.Duration(hc.TimeoutSec) * time.Second
+	interval := time.Duration(hc.IntervalSec) * time.Second
+
+	// agent 检查：在 timeout 内轮询 gateway，直到 agent 在部署开始后重新注册
+	if hc.Type == HealthCheckAgent {
+		deadline := time.Now().Add(timeout)
+		var lastErr error
+		for {
+			if lastErr = d.checkAgentRegistered(hc.AgentID, deployStart); lastErr == nil {
+				return nil
+			}
+			if time.Now().Add(interval).After(deadline) {
+				return fmt.Errorf("%ds 内未重新注册: %v", hc.TimeoutSec, lastErr)
+			}
+			time.Sleep(interval)
+		}
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= hc.Retries; attempt++ {
+		switch hc.Type {
+		case HealthCheckTCP:
+			lastErr = checkTCP(hc.Address, timeout)
+		case HealthCheckHTTP:
+			lastErr = checkHTTP(hc.URL, hc.Status, hc.BodyContains, timeout)
+		case HealthCheckCommand:
+			lastErr = checkCommand(runCmd, hc.Command, hc.ExitCode, timeout)
+		default:
+			return fmt.Errorf("unknown health check type %q", hc.Type)
+		}
+		if lastErr == nil {
+			return nil
+		}
+		if attempt < hc.Retries {
+			d.logf("info", "  > %s 第 %d/%d 次检查失败: %v，%ds 后重试\n", hc.label(), attempt, hc.Retries, lastErr, hc.IntervalSec)
+			time.Sleep(interval)
+		}
+	}
+	return fmt.Errorf("%d 次检查均失败: %v", hc.Retries, lastErr)
+}
+
+func checkTCP(address string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func checkHTTP(rawURL string, wantStatus int, bodyContains string, timeout time.Duration) error {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("HTTP %d（期望 %d）", resp.StatusCode, wantStatus)
+	}
+	if bodyContains != "" && !bytes.Contains(body, []byte(bodyContains)) {
+		return fmt.Errorf("响应体不包含 %q", bodyContains)
+	}
+	return nil
+}
+
+func checkCommand(runCmd healthCommandRunner, command string, wantExit int, timeout time.Duration) error {
+	if runCmd == nil {
+		return fmt.Errorf("command check not supported for this target")
+	}
+	code, output, err := runCmd(command, timeout)
+	if err != nil {
+		return err
+	}
+	if code != wantExit {
+		if output = strings.TrimSpace(output); output != "" {
+			return fmt.Errorf("退出码 %d（期望 %d）: %s", code, wantExit, output)
+		}
+		return fmt.Errorf("退出码 %d（期望 %d）", code, wantExit)
+	}
+	return nil
+}
+
+// checkAgentRegistered 查询 gateway 在线 agent 列表，要求 agent 在 since 之后注册
+func (d *Deployer) checkAgentRegistered(agentID string, since time.Time) error {
+	base := gatewayHTTPBase(d.cfg.ServerURL)
+	if base == "" {
+		return fmt.Errorf("未配置 server_url，无法查询 gateway")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(base + "/api/gateway/agents")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Agents []struct {
+			AgentID      string `json:"agent_id"`
+			RegisteredAt int64  `json:"registered_at"`
+		} `json:"agents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("解析 gateway 响应失败: %v", err)
+	}
+	for _, a := range result.Agents {
+		if a.AgentID != agentID {
+			continue
+		}
+		if a.RegisteredAt < since.UnixMilli() {
+			return fmt.Errorf("agent %s 仍是部署前的实例", agentID)
+		}
+		return nil
+	}
+	return fmt.Errorf("agent %s 不在线", agentID)
+}
+
+// gatewayHTTPBase 由 UAP WebSocket 地址推导 gateway HTTP 地址（ws://host/ws/uap → http://host）
+func gatewayHTTPBase(serverURL string) string {
+	u, err := url.Parse(strings.TrimSpace(serverURL))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	scheme := "http"
+	if u.Scheme == "wss" || u.Scheme == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host
+}
+
+// localHealthRunner 在本机目录中执行检查命令
+func localHealthRunner(dir string) healthCommandRunner {
+	return func(command string, timeout time.Duration) (int, string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/c", command)
+		} else {
+			cmd = exec.CommandContext(ctx, "bash", "-c", command)
+		}
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			return -1, string(output), fmt.Errorf("超时 (%s)", timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), string(output), nil
+		}
+		if err != nil {
+			return -1, string(output), err
+		}
+		return 0, string(output), nil
+	}
+}
+
+// remoteHealthRunner 通过 SSH 在目标目录中执行检查命令
+func remoteHealthRunner(client *ssh.Client, dir string) healthCommandRunner {
+	return func(command string, timeout time.Duration) (int, string, error) {
+		session, err := client.NewSession()
+		if err != nil {
+			return -1, "", fmt.Errorf("创建 SSH 会话失败: %v", err)
+		}
+		defer session.Close()
+		var output bytes.Buffer
+		session.Stdout = &output
+		session.Stderr = &output
+
+		done := make(chan error, 1)
+		go func() { done <- session.Run("cd " + shellQuote(dir) + " && " + command) }()
+		select {
+		case err = <-done:
+		case <-time.After(timeout):
+			return -1, output.String(), fmt.Errorf("超时 (%s)", timeout)
+		}
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitStatus(), output.String(), nil
+		}
+		if err != nil {
+			return -1, output.String(), err
+		}
+		return 0, output.String(), nil
+	}
+}
+
+// ========================= 部署后校验与自动回滚 =========================
+
+// verifyRelease 执行健康检查并写入发布记录；不健康时按配置自动回滚到上一个健康版本。
+// 返回需要从目标上清理的包与健康检查错误
+func (d *Deployer) verifyRelease(t *Target, binaryMD5 string, deployStart time.Time, runCmd healthCommandRunner) ([]string, error) {
+	healthErr := d.runHealthChecks(t, deployStart, runCmd)
+	status := releaseStatusOK
+	if healthErr != nil {
+		status = releaseStatusUnhealthy
+	}
+	pruned := d.recordRelease(t, binaryMD5, status)
+	if len(t.HealthChecks) == 0 {
+		return pruned, nil
+	}
+
+	outcome := HealthOutcome{
+		Project: d.proj.Name,
+		Target:  t.Name,
+		Package: d.packFile,
+		Healthy: healthErr == nil,
+	}
+	if healthErr == nil {
+		d.reportHealth(outcome)
+		return pruned, nil
+	}
+	outcome.Error = healthErr.Error()
+
+	// 回滚本身不健康时不再继续回滚，避免循环
+	if !t.AutoRollback || d.rollbackTo != nil {
+		d.reportHealth(outcome)
+		return pruned, fmt.Errorf("健康检查失败: %v", healthErr)
+	}
+
+	d.logf("error", "[HEALTH] 健康检查失败，自动回滚 %s...\n", t.Name)
+	rb := &Deployer{
+		cfg:         d.cfg,
+		proj:        d.proj,
+		password:    d.password,
+		OnProgress:  d.OnProgress,
+		DeployMode:  DeployModeIncr,
+		TriggerUser: d.TriggerUser,
+		Pipeline:    d.Pipeline,
+	}
+	release, rbErr := rb.Rollback(t.Name, 0)
+	if rb.SSHConnected {
+		d.SSHConnected = true
+	}
+	if rbErr != nil {
+		outcome.RollbackError = rbErr.Error()
+		d.reportHealth(outcome)
+		return pruned, fmt.Errorf("健康检查失败: %v；自动回滚失败: %v", healthErr, rbErr)
+	}
+	outcome.RolledBack = true
+	outcome.RollbackRelease = release
+	d.reportHealth(outcome)
+	return pruned, fmt.Errorf("健康检查失败: %v；已自动回滚到 %s", healthErr, release.Package)
+}
+
+func (d *Deployer) reportHealth(outcome HealthOutcome) {
+	if d.OnHealthResult != nil {
+		d.OnHealthResult(outcome)
+	}
+}
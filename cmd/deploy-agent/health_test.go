@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthChecksTCPHTTPAndAgent(t *testing.T) {
+	registeredAt := time.Now().Add(-time.Minute).UnixMilli()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			fmt.Fprint(w, `{"status":"ok"}`)
+		case "/api/gateway/agents":
+			fmt.Fprintf(w, `{"success":true,"agents":[{"agent_id":"blog-agent","registered_at":%d}]}`, registeredAt)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+
+	d := NewDeployer(&DeployConfig{ServerURL: "ws://" + strings.TrimPrefix(srv.URL, "http://") + "/ws/uap"},
+		&ProjectConfig{Name: "blog", AgentID: "blog-agent"}, "")
+	d.OnProgress = func(string, string) {}
+	target := &Target{Name: "local", Host: "local"}
+	fmt.Sscan(port, &target.ServicePort)
+	deployStart := time.Now().Add(-2 * time.Minute)
+
+	target.HealthChecks = []HealthCheck{
+		{Type: HealthCheckTCP, Retries: 1},
+		{Type: HealthCheckHTTP, URL: "/healthz", BodyContains: `"ok"`, Retries: 1},
+		{Type: HealthCheckCommand, Command: "exit 3", ExitCode: 3, Retries: 1},
+		{Type: HealthCheckAgent, TimeoutSec: 1, IntervalSec: 1},
+	}
+	if err := d.runHealthChecks(target, deployStart, localHealthRunner(t.TempDir())); err != nil {
+		t.Fatalf("health checks should pass: %v", err)
+	}
+
+	// agent 仍是部署前注册的实例
+	target.HealthChecks = []HealthCheck{{Type: HealthCheckAgent, TimeoutSec: 1, IntervalSec: 1}}
+	if err := d.runHealthChecks(target, time.Now(), nil); err == nil || !strings.Contains(err.Error(), "部署前") {
+		t.Fatalf("stale agent registration should fail, got %v", err)
+	}
+
+	target.HealthChecks = []HealthCheck{{Type: HealthCheckHTTP, URL: "/missing", Retries: 2, IntervalSec: 1}}
+	if err := d.runHealthChecks(target, deployStart, nil); err == nil || !strings.Contains(err.Error(), "2 次检查均失败") {
+		t.Fatalf("http 404 should fail after retries, got %v", err)
+	}
+}
+
+func TestUnhealthyDeployRollsBackToPreviousRelease(t *testing.T) {
+	if _, err := exec.LookPath("zip"); err != nil {
+		t.Skip("zip not available")
+	}
+	if _, err := exec.LookPath("unzip"); err != nil {
+		t.Skip("unzip not available")
+	}
+
+	projectDir := t.TempDir()
+	targetDir := t.TempDir()
+	script := "#!/bin/bash\nset -e\nv=$(cat VERSION)\nmkdir -p stage\nprintf \"$v\" > stage/demo\n" +
+		"(cd stage && zip -q ../demo_$v.zip demo)\n"
+	if err := os.WriteFile(filepath.Join(projectDir, "pack.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &DeployConfig{
+		HostPlatform: platformSubdir(),
+		Releases:     NewReleaseLedger(t.TempDir(), 3),
+	}
+	target := &Target{
+		Name:         "local",
+		Host:         "local",
+		RemoteDir:    targetDir,
+		HealthChecks: []HealthCheck{{Type: HealthCheckCommand, Command: `test "$(cat demo)" != bad`, Retries: 1}},
+		AutoRollback: true,
+	}
+	proj := &ProjectConfig{
+		Name:        "demo",
+		ProjectDir:  projectDir,
+		PackScript:  filepath.Join(projectDir, "pack.sh"),
+		PackPattern: "demo_{date}.zip",
+		Targets:     []*Target{target},
+	}
+
+	var outcomes []HealthOutcome
+	deploy := func(version string) error {
+		if err := os.WriteFile(filepath.Join(projectDir, "VERSION"), []byte(version), 0644); err != nil {
+			t.Fatal(err)
+		}
+		d := NewDeployer(cfg, proj, "")
+		d.OnProgress = func(string, string) {}
+		d.OnHealthResult = func(o HealthOutcome) { outcomes = append(outcomes, o) }
+		return d.Run(false, "local")
+	}
+
+	if err := deploy("v1"); err != nil {
+		t.Fatalf("deploy v1: %v", err)
+	}
+	err := deploy("bad")
+	if err == nil || !strings.Contains(err.Error(), "已自动回滚到 demo_v1.zip") {
+		t.Fatalf("unhealthy deploy should fail and roll back, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(targetDir, "demo")); string(data) != "v1" {
+		t.Fatalf("binary should be restored to v1, got %q", data)
+	}
+
+	if len(outcomes) != 2 || !outcomes[0].Healthy {
+		t.Fatalf("unexpected outcomes: %+v", outcomes)
+	}
+	failed := outcomes[1]
+	if failed.Healthy || !failed.RolledBack || failed.RollbackRelease == nil || failed.RollbackRelease.Package != "demo_v1.zip" {
+		t.Fatalf("unexpected failure outcome: %+v", failed)
+	}
+
+	releases, _ := cfg.Releases.List("demo", "local")
+	if len(releases) != 3 || releases[1].Status != releaseStatusUnhealthy || releases[0].RollbackOf != 1 {
+		t.Fatalf("unexpected ledger: %+v", releases)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -56,6 +58,14 @@ func main() {
 	agentStatusTarget := flag.String("agent-status", "", "查询指定 agent 状态")
 	forceShutdown := flag.Bool("force", false, "配合 --shutdown 使用，强制立即退出")
 
+	// 子命令：rollback / releases（使用独立 FlagSet）
+	if len(os.Args) > 1 && (os.Args[1] == "rollback" || os.Args[1] == "releases") {
+		os.Exit(runReleaseCommand(os.Args[1], os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "genkey" {
+		os.Exit(runGenKeyCommand(os.Args[2:]))
+	}
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "deploy-agent - 多项目部署工具\n\n")
 		fmt.Fprintf(os.Stderr, "用法:\n")
@@ -72,6 +82,11 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  -private-key-path <p> 命令型部署私钥路径（支持 ~/ 和相对路径）\n")
 		fmt.Fprintf(os.Stderr, "  -project-path <dir>   命令型部署项目目录覆盖\n")
 		fmt.Fprintf(os.Stderr, "  -list                 列出所有项目和目标\n\n")
+		fmt.Fprintf(os.Stderr, "发布历史与回滚:\n")
+		fmt.Fprintf(os.Stderr, "  releases -project <name> [-target <name>]                列出发布记录\n")
+		fmt.Fprintf(os.Stderr, "  rollback -project <name> [-target <name>] [-release <id>] 回滚（默认上一个版本）\n\n")
+		fmt.Fprintf(os.Stderr, "发布包签名:\n")
+		fmt.Fprintf(os.Stderr, "  genkey [-out <path>]  生成 Ed25519 签名私钥（配置 signing_key），输出 bridge trusted_keys 公钥\n\n")
 		fmt.Fprintf(os.Stderr, "控制协议:\n")
 		fmt.Fprintf(os.Stderr, "  -shutdown <agent-id>  关闭指定 agent（all=关闭所有）\n")
 		fmt.Fprintf(os.Stderr, "  -shutdown all         关闭所有已注册的 agent\n")
@@ -329,18 +344,15 @@ func main() {
 						desc = " — " + p.Description
 					}
 					fmt.Printf("  [%s]%s\n", p.Name, desc)
-					for i, s := range p.Steps {
+					for i, s := range p.Stages {
 						extra := ""
-						if s.Target != "" {
-							extra += " target=" + s.Target
-						}
 						if s.BuildPlatform != "" {
 							extra += " platform=" + s.BuildPlatform
 						}
-						if s.PackOnly {
-							extra += " pack_only"
+						if len(s.Needs) > 0 {
+							extra += " needs=" + strings.Join(s.Needs, ",")
 						}
-						fmt.Printf("    %d. %s%s\n", i+1, s.Project, extra)
+						fmt.Printf("    %d. [%s] %s%s\n", i+1, s.Name, describeStage(&s), extra)
 					}
 				}
 				fmt.Println()
@@ -414,14 +426,6 @@ func main() {
 			gatewayHTTP := envCfg.GatewayHTTP
 			catalog := agentbase.NewToolCatalog(gatewayHTTP)
 			rc := agentbase.NewRemoteCaller(conn.AgentBase, catalog)
-			// 注册 MsgToolResult handler 给 RemoteCaller
-			conn.RegisterHandler(uap.MsgToolResult, func(msg *uap.Message) {
-				var payload uap.ToolResultPayload
-				if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-					return
-				}
-				rc.DispatchToolResult(&payload)
-			})
 			go agentbase.NewEnvChecker(conn.AgentBase, catalog, rc, envCfg, nil).Run()
 		}
 
@@ -469,17 +473,17 @@ func main() {
 		if pip.Description != "" {
 			fmt.Printf("描述: %s\n", pip.Description)
 		}
-		fmt.Printf("步骤数: %d\n\n", len(pip.Steps))
+		fmt.Printf("阶段数: %d\n\n", len(pip.Stages))
 
+		// 并行阶段共享密码输入与标准输入，串行化交互
+		var promptMu sync.Mutex
 		currentPassword := *password
-		for i, step := range pip.Steps {
-			proj := cfg.GetProject(step.Project)
-			fmt.Printf("==================== [%d/%d] %s ====================\n", i+1, len(pip.Steps), step.Project)
-
+		deployStage := func(stage *PipelineStep) (map[string]any, error) {
+			proj := cfg.GetProject(stage.Project)
 			stepCfg := *cfg
 
-			packOnly := step.PackOnly
-			targetFilter := step.Target
+			packOnly := stage.PackOnly
+			targetFilter := stage.Target
 
 			// 判断是否需要密码
 			allLocal := true
@@ -490,6 +494,7 @@ func main() {
 				}
 			}
 
+			promptMu.Lock()
 			pwd := currentPassword
 			if pwd == "" && !packOnly && !allLocal {
 				// 从凭据存储获取
@@ -504,7 +509,7 @@ func main() {
 				}
 			}
 			if pwd == "" && !packOnly && !allLocal {
-				fmt.Print("SSH 密码: ")
+				fmt.Printf("[%s] SSH 密码: ", stage.Name)
 				if pwdBytes, err := term.ReadPassword(int(syscall.Stdin)); err == nil {
 					pwd = string(pwdBytes)
 				} else {
@@ -515,8 +520,11 @@ func main() {
 				fmt.Println()
 				currentPassword = pwd
 			}
+			promptMu.Unlock()
 
 			deployer := NewDeployer(&stepCfg, proj, pwd)
+			deployer.TriggerUser = currentUserName()
+			deployer.Pipeline = pip.Name
 			if *forceFull {
 				deployer.DeployMode = DeployModeFull
 			}
@@ -527,9 +535,7 @@ func main() {
 				ProjectPath:    *projectPath,
 			}
 			if deployErr := deployer.Run(packOnly, targetFilter); deployErr != nil {
-				fmt.Fprintf(os.Stderr, "\n❌ Pipeline %q 在步骤 [%d/%d] %s 失败: %v\n",
-					pip.Name, i+1, len(pip.Steps), step.Project, deployErr)
-				os.Exit(1)
+				return nil, deployErr
 			}
 
 			// SSH 连接成功后自动保存密码
@@ -547,11 +553,45 @@ func main() {
 					}
 				}
 			}
+			return map[string]any{"project": proj.Name, "deploy_target": stage.Target, "pack_only": packOnly}, nil
+		}
 
-			fmt.Printf("\n✅ 步骤 [%d/%d] %s 完成\n\n", i+1, len(pip.Steps), step.Project)
+		printEvent := func(level, text string) {
+			if level == "error" {
+				fmt.Fprintln(os.Stderr, text)
+				return
+			}
+			fmt.Println(text)
+		}
+		execStage := func(ctx context.Context, stage *PipelineStep) (map[string]any, error) {
+			switch stage.Type {
+			case StageDeploy:
+				return deployStage(stage)
+			case StageShell:
+				return runShellStage(ctx, cfg, stage, printEvent)
+			case StageTest:
+				return runTestStage(ctx, cfg, stage, printEvent)
+			case StageApproval:
+				promptMu.Lock()
+				defer promptMu.Unlock()
+				fmt.Printf("🔐 [%s] %s\n批准继续? [y/N]: ", stage.Name, stage.Message)
+				line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				if answer := strings.ToLower(strings.TrimSpace(line)); answer != "y" && answer != "yes" {
+					return map[string]any{"status": ApprovalRejected}, fmt.Errorf("审批被拒绝")
+				}
+				return map[string]any{"status": ApprovalApproved, "decided_by": currentUserName()}, nil
+			case StageToolCall:
+				return nil, fmt.Errorf("CLI 模式不支持 tool_call 阶段（需要 daemon 模式连接 gateway）")
+			}
+			return nil, fmt.Errorf("未知的阶段类型 %q", stage.Type)
 		}
 
-		fmt.Printf("✅ Pipeline %q 全部完成 (%d 步)\n", pip.Name, len(pip.Steps))
+		if _, err := RunPipeline(context.Background(), pip, execStage, printEvent); err != nil {
+			fmt.Fprintf(os.Stderr, "\n❌ %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("✅ Pipeline %q 全部完成 (%d 个阶段)\n", pip.Name, len(pip.Stages))
 		return
 	}
 
@@ -641,6 +681,7 @@ func main() {
 		}
 
 		deployer := NewDeployer(cfg, proj, pwd)
+		deployer.TriggerUser = currentUserName()
 		if *forceFull {
 			deployer.DeployMode = DeployModeFull
 		}
@@ -683,6 +724,116 @@ func main() {
 	}
 }
 
+// runReleaseCommand 执行 releases / rollback 子命令，返回进程退出码
+func runReleaseCommand(name string, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", "deploy-agent.json", "配置文件路径")
+	projectName := fs.String("project", "", "项目名称（单项目时可省略）")
+	targetName := fs.String("target", "", "部署目标（仅一个可回滚目标时可省略）")
+	releaseID := fs.Int("release", 0, "回滚到的发布 ID（默认上一个版本）")
+	limit := fs.Int("limit", 20, "releases 列出的记录数")
+	password := fs.String("password", "", "SSH 密码")
+	forceFull := fs.Bool("force-full", false, "回滚时强制完整部署（覆盖受保护文件）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := LoadConfigForDaemon(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	proj := cfg.DefaultProject()
+	if *projectName != "" {
+		proj = cfg.GetProject(*projectName)
+	}
+	if proj == nil {
+		fmt.Fprintf(os.Stderr, "请使用 -project 指定项目，可用项目: %v\n", cfg.ProjectNames())
+		return 1
+	}
+	if cfg.Releases == nil {
+		fmt.Fprintf(os.Stderr, "未启用发布账本（releases_dir）\n")
+		return 1
+	}
+
+	deployer := NewDeployer(cfg, proj, *password)
+	target, err := deployer.resolveRollbackTarget(*targetName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if name == "releases" {
+		releases, err := cfg.Releases.List(proj.Name, target.Name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取发布记录失败: %v\n", err)
+			return 1
+		}
+		fmt.Printf("项目: [%s]  目标: %s\n", proj.Name, target.Name)
+		if len(releases) == 0 {
+			fmt.Printf("暂无发布记录\n")
+			return 0
+		}
+		for i, r := range releases {
+			if i >= *limit {
+				break
+			}
+			extra := ""
+			if r.Pipeline != "" {
+				extra += " pipeline=" + r.Pipeline
+			}
+			if r.RollbackOf > 0 {
+				extra += fmt.Sprintf(" rollback_of=#%d", r.RollbackOf)
+			}
+			if r.RolledBack {
+				extra += " (已回滚)"
+			}
+			if !r.Retained {
+				extra += " (包已清理)"
+			}
+			fmt.Printf("  #%-4d %s  %s  %s  user=%s%s\n", r.ID,
+				time.UnixMilli(r.DeployedAt).Format("2006-01-02 15:04:05"), r.Package, shortMD5(r.MD5), r.User, extra)
+		}
+		return 0
+	}
+
+	// 远程 SSH 目标需要密码
+	pwd := *password
+	if pwd == "" && !isLocalTarget(target.Host) && target.Type != "bridge" {
+		user, host := parseHost(target.Host)
+		accountKey := fmt.Sprintf("%s@%s:%d", user, host, target.Port)
+		if saved, err := newCredentialStore().Get(accountKey); err == nil && saved != "" {
+			pwd = saved
+			fmt.Printf("已从凭据存储获取密码 (%s)\n", accountKey)
+		} else if cfg.SSHPassword != "" {
+			pwd = cfg.SSHPassword
+		} else {
+			fmt.Print("SSH 密码: ")
+			if pwdBytes, err := term.ReadPassword(int(syscall.Stdin)); err == nil {
+				pwd = string(pwdBytes)
+			} else {
+				reader := bufio.NewReader(os.Stdin)
+				line, _ := reader.ReadString('\n')
+				pwd = strings.TrimSpace(line)
+			}
+			fmt.Println()
+		}
+	}
+
+	deployer = NewDeployer(cfg, proj, pwd)
+	deployer.TriggerUser = currentUserName()
+	if *forceFull {
+		deployer.DeployMode = DeployModeFull
+	}
+	release, err := deployer.Rollback(target.Name, *releaseID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "项目 [%s] %v\n", proj.Name, err)
+		return 1
+	}
+	fmt.Printf("✅ 项目 [%s] 目标 %s 已回滚到 %s（发布记录 #%d）\n", proj.Name, target.Name, release.Package, release.ID)
+	return 0
+}
+
 // runControlCommand 执行控制协议命令（临时 UAP 连接）
 func runControlCommand(cfg *DeployConfig, shutdownTarget, statusTarget string, force bool) error {
 	agentID := fmt.Sprintf("deploy_ctrl_%d", os.Getpid())
@@ -9,19 +9,64 @@ import (
 	"strings"
 )
 
-// PipelineStep 编排中的单个步骤
+// 阶段类型
+const (
+	StageDeploy   = "deploy"    // 打包/部署项目（默认）
+	StageShell    = "shell"     // 本机执行 shell 命令
+	StageTest     = "test"      // 触发 test-agent suite 并等待结果
+	StageToolCall = "tool_call" // 通过 UAP 调用其他 agent 的工具
+	StageApproval = "approval"  // 人工审批，等待微信/app 批准后继续
+)
+
+// 阶段执行条件
+const (
+	WhenSuccess = "success" // 依赖全部成功且 pipeline 未失败（默认）
+	WhenFailure = "failure" // 任一依赖失败或被跳过
+	WhenAlways  = "always"  // 依赖结束即执行
+)
+
+// PipelineStep 编排中的单个阶段（旧格式 steps 中的步骤同样使用此结构）
 type PipelineStep struct {
-	Project       string `json:"project"`                  // 必填：项目名
+	Name  string   `json:"name,omitempty"`  // 阶段名（stages 中必填，旧格式自动生成 step-N）
+	Type  string   `json:"type,omitempty"`  // deploy(默认) | shell | test | tool_call | approval
+	Needs []string `json:"needs,omitempty"` // 依赖的阶段名
+	When  string   `json:"when,omitempty"`  // success(默认) | failure | always
+
+	// deploy
+	Project       string `json:"project,omitempty"`        // 项目名（deploy 必填；shell 用作默认工作目录）
 	Target        string `json:"target,omitempty"`         // 部署目标覆盖
 	BuildPlatform string `json:"build_platform,omitempty"` // 目标平台覆盖
 	PackOnly      bool   `json:"pack_only,omitempty"`      // 仅打包
+
+	// shell
+	Command string `json:"command,omitempty"` // shell 命令
+	Dir     string `json:"dir,omitempty"`     // 工作目录（默认项目目录）
+
+	// test
+	Suite    string `json:"suite,omitempty"`    // test-agent suite 文件名
+	Scenario string `json:"scenario,omitempty"` // 仅执行指定场景
+	URL      string `json:"url,omitempty"`      // test-agent 控制台地址（默认 test_agent_url）
+
+	// tool_call
+	Tool  string         `json:"tool,omitempty"`  // 工具名
+	Agent string         `json:"agent,omitempty"` // 目标 agent（可选，默认按 gateway 工具目录查找）
+	Args  map[string]any `json:"args,omitempty"`  // 工具参数
+
+	// approval
+	Message   string   `json:"message,omitempty"`   // 审批说明
+	Approvers []string `json:"approvers,omitempty"` // 审批人（为空时任何人可批准，通知发给默认用户）
+
+	TimeoutSec int `json:"timeout_sec,omitempty"` // shell/test/tool_call/approval 超时
 }
 
-// Pipeline 命名编排
+// Pipeline 命名编排：stages 组成 DAG，旧格式 steps 按顺序串联
 type Pipeline struct {
-	Name        string         `json:"name"`        // 编排名称（未设置时从文件名推导）
-	Description string         `json:"description"` // 描述
-	Steps       []PipelineStep `json:"steps"`       // 有序步骤
+	Name        string         `json:"name"`                   // 编排名称（未设置时从文件名推导）
+	Description string         `json:"description"`            // 描述
+	Steps       []PipelineStep `json:"steps,omitempty"`        // 旧格式：有序步骤
+	Stages      []PipelineStep `json:"stages,omitempty"`       // 阶段（needs 描述依赖）
+	OnFailure   []PipelineStep `json:"on_failure,omitempty"`   // pipeline 失败后依次执行的处理阶段
+	MaxParallel int            `json:"max_parallel,omitempty"` // 最大并行阶段数，默认 4
 }
 
 // PipelinesConfig 编排配置（从 pipelines/ 目录加载的全部 pipeline）
@@ -74,13 +119,8 @@ func LoadPipelines(dir string) (*PipelinesConfig, error) {
 		}
 
 		// 校验
-		if len(p.Steps) == 0 {
-			return nil, fmt.Errorf("pipeline %q (%s): steps 不能为空", p.Name, entry.Name())
-		}
-		for j, s := range p.Steps {
-			if s.Project == "" {
-				return nil, fmt.Errorf("pipeline %q (%s) step[%d]: project 不能为空", p.Name, entry.Name(), j)
-			}
+		if err := normalizePipeline(&p); err != nil {
+			return nil, fmt.Errorf("%v (%s)", err, entry.Name())
 		}
 
 		cfg.Pipelines = append(cfg.Pipelines, p)
@@ -108,17 +148,177 @@ func (cfg *PipelinesConfig) Names() []string {
 	return names
 }
 
-// ValidatePipeline 校验所有 step 的 project 是否存在于 DeployConfig
+// normalizePipeline 将旧格式 steps 转为串行 stages，补全默认值并校验阶段字段（可重复调用）
+func normalizePipeline(p *Pipeline) error {
+	if len(p.Stages) == 0 {
+		for i, step := range p.Steps {
+			step.Name = fmt.Sprintf("step-%d", i+1)
+			if i > 0 {
+				step.Needs = []string{fmt.Sprintf("step-%d", i)}
+			}
+			p.Stages = append(p.Stages, step)
+		}
+	}
+	if len(p.Stages) == 0 {
+		return fmt.Errorf("pipeline %q: stages 不能为空", p.Name)
+	}
+
+	names := make(map[string]bool, len(p.Stages))
+	for i := range p.Stages {
+		stage := &p.Stages[i]
+		if stage.Name == "" {
+			return fmt.Errorf("pipeline %q stage[%d]: name 不能为空", p.Name, i)
+		}
+		if names[stage.Name] {
+			return fmt.Errorf("pipeline %q: 阶段名 %q 重复", p.Name, stage.Name)
+		}
+		names[stage.Name] = true
+		if err := normalizeStage(stage); err != nil {
+			return fmt.Errorf("pipeline %q stage %q: %v", p.Name, stage.Name, err)
+		}
+	}
+	for _, stage := range p.Stages {
+		for _, need := range stage.Needs {
+			if !names[need] {
+				return fmt.Errorf("pipeline %q stage %q: needs 引用了不存在的阶段 %q", p.Name, stage.Name, need)
+			}
+			if need == stage.Name {
+				return fmt.Errorf("pipeline %q stage %q: 不能依赖自身", p.Name, stage.Name)
+			}
+		}
+		if stage.When == WhenFailure && len(stage.Needs) == 0 {
+			return fmt.Errorf("pipeline %q stage %q: when=failure 需要配置 needs", p.Name, stage.Name)
+		}
+	}
+
+	for i := range p.OnFailure {
+		handler := &p.OnFailure[i]
+		if handler.Name == "" {
+			handler.Name = fmt.Sprintf("on_failure-%d", i+1)
+		}
+		if len(handler.Needs) > 0 {
+			return fmt.Errorf("pipeline %q on_failure %q: 不支持 needs（按顺序执行）", p.Name, handler.Name)
+		}
+		if err := normalizeStage(handler); err != nil {
+			return fmt.Errorf("pipeline %q on_failure %q: %v", p.Name, handler.Name, err)
+		}
+	}
+	return nil
+}
+
+func normalizeStage(stage *PipelineStep) error {
+	if stage.Type == "" {
+		stage.Type = StageDeploy
+	}
+	if stage.When == "" {
+		stage.When = WhenSuccess
+	}
+	switch stage.When {
+	case WhenSuccess, WhenFailure, WhenAlways:
+	default:
+		return fmt.Errorf("未知的 when %q", stage.When)
+	}
+
+	switch stage.Type {
+	case StageDeploy:
+		if stage.Project == "" {
+			return fmt.Errorf("project 不能为空")
+		}
+	case StageShell:
+		if stage.Command == "" {
+			return fmt.Errorf("command 不能为空")
+		}
+	case StageTest:
+		if stage.Suite == "" {
+			return fmt.Errorf("suite 不能为空")
+		}
+	case StageToolCall:
+		if stage.Tool == "" {
+			return fmt.Errorf("tool 不能为空")
+		}
+	case StageApproval:
+	default:
+		return fmt.Errorf("未知的阶段类型 %q", stage.Type)
+	}
+	return nil
+}
+
+// pipelineOrder 按依赖关系拓扑排序，存在环时返回环上的阶段
+func pipelineOrder(p *Pipeline) ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	index := make(map[string]int, len(p.Stages))
+	for i, stage := range p.Stages {
+		index[stage.Name] = i
+	}
+	state := make(map[string]int, len(p.Stages))
+	order := make([]string, 0, len(p.Stages))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			cycleStart := 0
+			for i, n := range path {
+				if n == name {
+					cycleStart = i
+					break
+				}
+			}
+			cycle := append(append([]string(nil), path[cycleStart:]...), name)
+			return fmt.Errorf("pipeline %q 存在循环依赖: %s", p.Name, strings.Join(cycle, " → "))
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, need := range p.Stages[index[name]].Needs {
+			if _, ok := index[need]; !ok {
+				continue
+			}
+			if err := visit(need); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+	for _, stage := range p.Stages {
+		if err := visit(stage.Name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// ValidatePipeline 校验阶段结构、依赖无环，以及引用的 project 是否存在于 DeployConfig
 func ValidatePipeline(p *Pipeline, deployCfg *DeployConfig) error {
+	if err := normalizePipeline(p); err != nil {
+		return err
+	}
+	if _, err := pipelineOrder(p); err != nil {
+		return err
+	}
+
 	var missing []string
-	for idx, step := range p.Steps {
-		proj := deployCfg.GetProject(step.Project)
+	stages := append(append([]PipelineStep(nil), p.Stages...), p.OnFailure...)
+	for _, stage := range stages {
+		if stage.Project == "" {
+			continue
+		}
+		proj := deployCfg.GetProject(stage.Project)
 		if proj == nil {
-			missing = append(missing, step.Project)
+			missing = append(missing, stage.Project)
 			continue
 		}
-		if proj.BuildOnly && !step.PackOnly {
-			return fmt.Errorf("pipeline %q step[%d] project %q 仅支持 pack_only", p.Name, idx, step.Project)
+		if stage.Type == StageDeploy && proj.BuildOnly && !stage.PackOnly {
+			return fmt.Errorf("pipeline %q stage %q project %q 仅支持 pack_only", p.Name, stage.Name, stage.Project)
 		}
 	}
 	if len(missing) > 0 {
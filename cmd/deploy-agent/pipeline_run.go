@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPipelineParallel = 4
+	defaultShellTimeoutSec  = 600
+	defaultTestTimeoutSec   = 1800
+	defaultToolTimeoutSec   = 300
+	defaultTestAgentURL     = "http://127.0.0.1:18086"
+	testAgentPollInterval   = 3 * time.Second
+)
+
+// 阶段运行状态
+const (
+	StageStatusPending = "pending"
+	StageStatusSuccess = "success"
+	StageStatusFailed  = "failed"
+	StageStatusSkipped = "skipped"
+)
+
+// StageResult 单个阶段的执行结果
+type StageResult struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Project    string         `json:"project,omitempty"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	StartedAt  int64          `json:"started_at,omitempty"`  // 毫秒
+	FinishedAt int64          `json:"finished_at,omitempty"` // 毫秒
+	Output     map[string]any `json:"output,omitempty"`
+}
+
+// StageExecutor 执行单个阶段，返回阶段输出
+type StageExecutor func(ctx context.Context, stage *PipelineStep) (map[string]any, error)
+
+type stageDone struct {
+	index  int
+	output map[string]any
+	err    error
+}
+
+// RunPipeline 按 needs 依赖并行执行阶段：同一项目的 deploy 阶段串行，
+// 任一阶段失败后不再启动 when=success 的阶段，失败后依次执行 on_failure。
+// 返回 stages 与 on_failure 的执行结果，pipeline 失败时返回错误
+func RunPipeline(ctx context.Context, pip *Pipeline, execStage StageExecutor, emit func(level, text string)) ([]StageResult, error) {
+	if emit == nil {
+		emit = func(string, string) {}
+	}
+	if _, err := pipelineOrder(pip); err != nil {
+		return nil, err
+	}
+
+	maxParallel := pip.MaxParallel
+	if maxParallel <= 0 {
+		maxParallel = defaultPipelineParallel
+	}
+
+	results := make([]StageResult, len(pip.Stages))
+	index := make(map[string]int, len(pip.Stages))
+	for i, stage := range pip.Stages {
+		index[stage.Name] = i
+		results[i] = StageResult{Name: stage.Name, Type: stage.Type, Project: stage.Project, Status: StageStatusPending}
+	}
+
+	done := make(chan stageDone)
+	running := 0
+	started := make([]bool, len(pip.Stages))
+	busyProjects := make(map[string]bool)
+	failed := false
+
+	for {
+		// 反复扫描直到没有可调度的阶段（跳过会解锁后续阶段）
+		for progress := true; progress; {
+			progress = false
+			for i := range pip.Stages {
+				stage := &pip.Stages[i]
+				if started[i] || results[i].Status != StageStatusPending {
+					continue
+				}
+				ready, run, reason := stageReadiness(stage, results, index, failed)
+				if !ready {
+					continue
+				}
+				if !run {
+					results[i].Status = StageStatusSkipped
+					results[i].Error = reason
+					emit("system", fmt.Sprintf("⏭️ [%s] 跳过: %s", stage.Name, reason))
+					progress = true
+					continue
+				}
+				if running >= maxParallel {
+					continue
+				}
+				if stage.Type == StageDeploy && busyProjects[stage.Project] {
+					continue
+				}
+
+				started[i] = true
+				running++
+				if stage.Type == StageDeploy {
+					busyProjects[stage.Project] = true
+				}
+				results[i].StartedAt = time.Now().UnixMilli()
+				emit("system", fmt.Sprintf("▶️ [%s] 开始 (%s)", stage.Name, describeStage(stage)))
+				go func(i int, stage *PipelineStep) {
+					output, err := execStage(ctx, stage)
+					done <- stageDone{index: i, output: output, err: err}
+				}(i, stage)
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+
+		d := <-done
+		running--
+		stage := &pip.Stages[d.index]
+		if stage.Type == StageDeploy {
+			delete(busyProjects, stage.Project)
+		}
+		res := &results[d.index]
+		res.FinishedAt = time.Now().UnixMilli()
+		res.Output = d.output
+		elapsed := time.Duration(res.FinishedAt-res.StartedAt) * time.Millisecond
+		if d.err != nil {
+			failed = true
+			res.Status = StageStatusFailed
+			res.Error = d.err.Error()
+			emit("error", fmt.Sprintf("❌ [%s] 失败 (%s): %v", stage.Name, elapsed.Round(time.Second), d.err))
+		} else {
+			res.Status = StageStatusSuccess
+			emit("system", fmt.Sprintf("✅ [%s] 完成 (%s)", stage.Name, elapsed.Round(time.Second)))
+		}
+	}
+
+	var failedNames []string
+	var firstErr string
+	for _, res := range results {
+		if res.Status == StageStatusFailed {
+			failedNames = append(failedNames, res.Name)
+			if firstErr == "" {
+				firstErr = res.Error
+			}
+		}
+	}
+	if len(failedNames) == 0 {
+		return results, nil
+	}
+
+	for i := range pip.OnFailure {
+		handler := &pip.OnFailure[i]
+		res := StageResult{Name: handler.Name, Type: handler.Type, Project: handler.Project, StartedAt: time.Now().UnixMilli()}
+		emit("system", fmt.Sprintf("🧯 [%s] 执行失败处理 (%s)", handler.Name, describeStage(handler)))
+		output, err := execStage(ctx, handler)
+		res.FinishedAt = time.Now().UnixMilli()
+		res.Output = output
+		if err != nil {
+			res.Status = StageStatusFailed
+			res.Error = err.Error()
+			emit("error", fmt.Sprintf("⚠️ [%s] 失败处理出错: %v", handler.Name, err))
+		} else {
+			res.Status = StageStatusSuccess
+		}
+		results = append(results, res)
+	}
+
+	return results, fmt.Errorf("pipeline %q 阶段 %s 失败: %s", pip.Name, strings.Join(failedNames, ", "), firstErr)
+}
+
+// stageReadiness 判断阶段是否可以调度：ready=依赖均已结束，run=满足 when 条件
+func stageReadiness(stage *PipelineStep, results []StageResult, index map[string]int, pipelineFailed bool) (ready, run bool, reason string) {
+	allSuccess := true
+	var notOK []string
+	for _, need := range stage.Needs {
+		status := results[index[need]].Status
+		if status == StageStatusPending {
+			return false, false, ""
+		}
+		if status != StageStatusSuccess {
+			allSuccess = false
+			notOK = append(notOK, need)
+		}
+	}
+
+	switch stage.When {
+	case WhenAlways:
+		return true, true, ""
+	case WhenFailure:
+		if allSuccess {
+			return true, false, "依赖阶段均成功"
+		}
+		return true, true, ""
+	default:
+		if !allSuccess {
+			return true, false, "依赖阶段未成功: " + strings.Join(notOK, ", ")
+		}
+		if pipelineFailed {
+			return true, false, "pipeline 已有阶段失败"
+		}
+		return true, true, ""
+	}
+}
+
+func describeStage(stage *PipelineStep) string {
+	switch stage.Type {
+	case StageDeploy:
+		if stage.PackOnly {
+			return "打包 " + stage.Project
+		}
+		target := stage.Target
+		if target == "" {
+			target = "默认"
+		}
+		return fmt.Sprintf("部署 %s → %s", stage.Project, target)
+	case StageShell:
+		return "shell: " + stage.Command
+	case StageTest:
+		return "test-agent suite " + stage.Suite
+	case StageToolCall:
+		return "tool_call " + stage.Tool
+	case StageApproval:
+		return "等待审批"
+	}
+	return stage.Type
+}
+
+func stageTimeout(stage *PipelineStep, defaultSec int) time.Duration {
+	if stage.TimeoutSec > 0 {
+		return time.Duration(stage.TimeoutSec) * time.Second
+	}
+	return time.Duration(defaultSec) * time.Second
+}
+
+// ========================= 通用阶段执行 =========================
+
+// runShellStage 在本机执行 shell 阶段，工作目录默认为项目目录
+func runShellStage(ctx context.Context, cfg *DeployConfig, stage *PipelineStep, emit func(level, text string)) (map[string]any, error) {
+	dir := stage.Dir
+	if dir == "" && stage.Project != "" {
+		if proj := cfg.GetProject(stage.Project); proj != nil {
+			dir = proj.ProjectDir
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stageTimeout(stage, defaultShellTimeoutSec))
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/c", stage.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "bash", "-c", stage.Command)
+	}
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+
+	output := tailLines(string(out), 20)
+	if output != "" && emit != nil {
+		emit("system", fmt.Sprintf("📄 [%s] %s", stage.Name, output))
+	}
+	result := map[string]any{"command": stage.Command, "output": output}
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, fmt.Errorf("命令超时 (%s)", stageTimeout(stage, defaultShellTimeoutSec))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result["exit_code"] = exitErr.ExitCode()
+		return result, fmt.Errorf("命令退出码 %d", exitErr.ExitCode())
+	}
+	if err != nil {
+		return result, err
+	}
+	result["exit_code"] = 0
+	return result, nil
+}
+
+// runTestStage 通过 test-agent 控制台 API 触发 suite 并轮询到结束
+func runTestStage(ctx context.Context, cfg *DeployConfig, stage *PipelineStep, emit func(level, text string)) (map[string]any, error) {
+	base := strings.TrimRight(stage.URL, "/")
+	if base == "" {
+		base = strings.TrimRight(cfg.TestAgentURL, "/")
+	}
+	if base == "" {
+		base = defaultTestAgentURL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stageTimeout(stage, defaultTestTimeoutSec))
+	defer cancel()
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	body, _ := json.Marshal(map[string]string{"mode": "suite", "suite": stage.Suite, "scenario_id": stage.Scenario})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/run", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("触发 test-agent 失败: %v", err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("触发 test-agent 失败: HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if emit != nil {
+		emit("system", fmt.Sprintf("🧪 [%s] test-agent 已开始执行 %s", stage.Name, stage.Suite))
+	}
+
+	var state struct {
+		Running     bool   `json:"running"`
+		Status      string `json:"status"`
+		Error       string `json:"error"`
+		SuiteReport *struct {
+			RunID           string `json:"run_id"`
+			Status          string `json:"status"`
+			TotalScenarios  int    `json:"total_scenarios"`
+			PassedScenarios int    `json:"passed_scenarios"`
+			FailedScenarios int    `json:"failed_scenarios"`
+			AverageScore    int    `json:"average_score"`
+		} `json:"suite_report"`
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待 test-agent 结果超时: %v", ctx.Err())
+		case <-time.After(testAgentPollInterval):
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/state", nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+		decodeErr := json.NewDecoder(resp.Body).Decode(&state)
+		resp.Body.Close()
+		if decodeErr != nil || state.Running {
+			continue
+		}
+		break
+	}
+
+	result := map[string]any{"suite": stage.Suite, "status": state.Status}
+	if r := state.SuiteReport; r != nil {
+		result["run_id"] = r.RunID
+		result["total"] = r.TotalScenarios
+		result["passed"] = r.PassedScenarios
+		result["failed"] = r.FailedScenarios
+		result["score"] = r.AverageScore
+	}
+	if state.Status != "passed" {
+		if state.Error != "" {
+			return result, fmt.Errorf("测试 %s 未通过 (status=%s): %s", stage.Suite, state.Status, state.Error)
+		}
+		return result, fmt.Errorf("测试 %s 未通过 (status=%s)", stage.Suite, state.Status)
+	}
+	return result, nil
+}
+
+// tailLines 保留输出的最后 n 行
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\r\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
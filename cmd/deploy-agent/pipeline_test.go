@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadPipelinesConvertsLegacySteps(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"steps":[{"project":"blog"},{"project":"gateway","target":"ssh-prod"}]}`
+	if err := os.WriteFile(filepath.Join(dir, "prod.json"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadPipelines(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pip := cfg.Get("prod")
+	if pip == nil || len(pip.Stages) != 2 {
+		t.Fatalf("legacy steps should become stages: %+v", pip)
+	}
+	second := pip.Stages[1]
+	if second.Name != "step-2" || second.Type != StageDeploy || len(second.Needs) != 1 || second.Needs[0] != "step-1" {
+		t.Fatalf("legacy steps should be chained in order: %+v", second)
+	}
+}
+
+func TestValidatePipelineRejectsCyclesAndUnknownProjects(t *testing.T) {
+	cfg := &DeployConfig{Projects: map[string]*ProjectConfig{"blog": {Name: "blog"}}, ProjectOrder: []string{"blog"}}
+
+	cyclic := &Pipeline{Name: "cyclic", Stages: []PipelineStep{
+		{Name: "a", Project: "blog", Needs: []string{"c"}},
+		{Name: "b", Type: StageShell, Command: "true", Needs: []string{"a"}},
+		{Name: "c", Type: StageShell, Command: "true", Needs: []string{"b"}},
+	}}
+	if err := ValidatePipeline(cyclic, cfg); err == nil || !strings.Contains(err.Error(), "循环依赖") {
+		t.Fatalf("cycle should be rejected, got %v", err)
+	}
+
+	unknown := &Pipeline{Name: "unknown", Stages: []PipelineStep{
+		{Name: "a", Project: "blog"},
+		{Name: "b", Project: "nope", Needs: []string{"a"}},
+	}}
+	if err := ValidatePipeline(unknown, cfg); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("unknown project should be rejected, got %v", err)
+	}
+
+	missingNeed := &Pipeline{Name: "missing", Stages: []PipelineStep{{Name: "a", Project: "blog", Needs: []string{"ghost"}}}}
+	if err := ValidatePipeline(missingNeed, cfg); err == nil || !strings.Contains(err.Error(), "ghost") {
+		t.Fatalf("unknown need should be rejected, got %v", err)
+	}
+}
+
+func TestRunPipelineFansOutAndHandlesFailure(t *testing.T) {
+	pip := &Pipeline{
+		Name: "release",
+		Stages: []PipelineStep{
+			{Name: "blog", Project: "blog"},
+			{Name: "gateway", Project: "gateway"},
+			{Name: "smoke", Type: StageShell, Command: "smoke", Needs: []string{"blog", "gateway"}},
+			{Name: "announce", Type: StageShell, Command: "announce", Needs: []string{"smoke"}},
+			{Name: "revert", Type: StageShell, Command: "revert", Needs: []string{"smoke"}, When: WhenFailure},
+		},
+		OnFailure: []PipelineStep{{Type: StageShell, Command: "page-oncall"}},
+	}
+	if err := normalizePipeline(pip); err != nil {
+		t.Fatal(err)
+	}
+
+	// blog 与 gateway 互不依赖，必须同时运行
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+	var mu sync.Mutex
+	var ran []string
+	exec := func(ctx context.Context, stage *PipelineStep) (map[string]any, error) {
+		mu.Lock()
+		ran = append(ran, stage.Name)
+		mu.Unlock()
+		switch stage.Name {
+		case "blog", "gateway":
+			barrier.Done()
+			waited := make(chan struct{})
+			go func() { barrier.Wait(); close(waited) }()
+			select {
+			case <-waited:
+			case <-time.After(2 * time.Second):
+				return nil, fmt.Errorf("independent stages did not run in parallel")
+			}
+		case "smoke":
+			return nil, fmt.Errorf("smoke test failed")
+		}
+		return map[string]any{"stage": stage.Name}, nil
+	}
+
+	results, err := RunPipeline(context.Background(), pip, exec, nil)
+	if err == nil || !strings.Contains(err.Error(), "smoke") {
+		t.Fatalf("pipeline should fail at smoke, got %v", err)
+	}
+
+	status := make(map[string]string)
+	for _, r := range results {
+		status[r.Name] = r.Status
+	}
+	want := map[string]string{
+		"blog":         StageStatusSuccess,
+		"gateway":      StageStatusSuccess,
+		"smoke":        StageStatusFailed,
+		"announce":     StageStatusSkipped,
+		"revert":       StageStatusSuccess,
+		"on_failure-1": StageStatusSuccess,
+	}
+	for name, s := range want {
+		if status[name] != s {
+			t.Fatalf("stage %s status=%q want %q (all: %v)", name, status[name], s, status)
+		}
+	}
+	if last := ran[len(ran)-1]; last != "on_failure-1" {
+		t.Fatalf("on_failure should run last, order=%v", ran)
+	}
+}
+
+func TestRunPipelineSerializesSameProjectDeploys(t *testing.T) {
+	pip := &Pipeline{Name: "same", Stages: []PipelineStep{
+		{Name: "pack", Project: "blog", PackOnly: true},
+		{Name: "deploy", Project: "blog", Target: "ssh-prod"},
+	}}
+	if err := normalizePipeline(pip); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	active := 0
+	exec := func(ctx context.Context, stage *PipelineStep) (map[string]any, error) {
+		mu.Lock()
+		active++
+		overlap := active > 1
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		if overlap {
+			return nil, fmt.Errorf("deploys of the same project overlapped")
+		}
+		return nil, nil
+	}
+	if _, err := RunPipeline(context.Background(), pip, exec, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApprovalManagerRestrictsApprovers(t *testing.T) {
+	m := NewApprovalManager()
+	a := m.Request("release", &PipelineStep{Name: "gate", Approvers: []string{"ztt"}, TimeoutSec: 5}, "ci")
+
+	if len(m.Pending()) != 1 {
+		t.Fatalf("approval should be pending: %+v", m.Pending())
+	}
+	if _, err := m.Decide(a.ID, "mallory", true, ""); err == nil {
+		t.Fatal("non-approver should not be able to approve")
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := m.Wait(context.Background(), a.ID)
+		result <- err
+	}()
+	if _, err := m.Decide(a.ID, "ztt", true, "ship it"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("approved gate should pass: %v", err)
+	}
+	if _, err := m.Decide(a.ID, "ztt", false, ""); err == nil {
+		t.Fatal("decided approval should not be decided again")
+	}
+
+	rejected := m.Request("release", &PipelineStep{Name: "gate", Approvers: []string{"ztt"}}, "ci")
+	if _, err := m.Decide(rejected.ID, "", false, ""); err == nil {
+		t.Fatal("unauthenticated user should not be able to decide")
+	}
+	go m.Decide(rejected.ID, "ztt", false, "not today")
+	if _, err := m.Wait(context.Background(), rejected.ID); err == nil || !strings.Contains(err.Error(), "not today") {
+		t.Fatalf("rejected gate should fail with comment, got %v", err)
+	}
+
+	// 未配置审批人时任何人都不能批准
+	unassigned := m.Request("release", &PipelineStep{Name: "gate"}, "ci")
+	if _, err := m.Decide(unassigned.ID, "anyone", true, ""); err == nil {
+		t.Fatal("approval without approvers should be denied")
+	}
+}
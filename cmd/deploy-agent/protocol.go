@@ -62,6 +62,8 @@ type TaskAssignPayload struct {
 	Desc           string `json:"desc,omitempty"`             // 命令型部署参数：描述
 	PrivateKeyPath string `json:"private_key_path,omitempty"` // 命令型部署参数：私钥路径
 	ProjectPath    string `json:"project_path,omitempty"`     // 命令型部署参数：项目目录覆盖
+	User           string `json:"user,omitempty"`             // 触发人（写入发布账本，默认为调用方）
+	ReleaseID      int    `json:"release_id,omitempty"`       // 回滚目标发布 ID（0 表示上一个版本）
 	// Adhoc 一次性部署参数（ssh_host 存在时进入 adhoc 模式）
 	ProjectDir string `json:"project_dir,omitempty"` // Go 项目目录
 	SSHHost    string `json:"ssh_host,omitempty"`    // SSH 目标（如 root@1.2.3.4）
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReleaseKeep = 5   // 每个 target 默认保留的发布包数量
+	maxReleaseHistory  = 100 // 每个 target 账本最多保留的记录数
+)
+
+// 发布状态（旧记录为空，视为 ok）
+const (
+	releaseStatusOK        = "ok"
+	releaseStatusUnhealthy = "unhealthy" // 部署后健康检查未通过
+)
+
+// ReleaseRecord 一次成功发布的账本记录
+type ReleaseRecord struct {
+	ID         int    `json:"id"`
+	Project    string `json:"project"`
+	Target     string `json:"target"`
+	Package    string `json:"package"`               // 发布包文件名（保留在目标目录或 bridge 上）
+	MD5        string `json:"md5"`                   // 发布包 MD5
+	BinaryMD5  string `json:"binary_md5,omitempty"`  // 部署后二进制 MD5
+	DeployedAt int64  `json:"deployed_at"`           // 毫秒时间戳
+	User       string `json:"user,omitempty"`        // 触发人
+	Pipeline   string `json:"pipeline,omitempty"`    // 所属 pipeline
+	RollbackOf int    `json:"rollback_of,omitempty"` // 回滚发布时指向被恢复的记录
+	RolledBack bool   `json:"rolled_back,omitempty"` // 该发布已被回滚撤下，不再作为自动回滚目标
+	Retained   bool   `json:"retained"`              // 发布包是否仍保留在目标上（可回滚）
+	Status     string `json:"status,omitempty"`      // ok / unhealthy
+	Location   string `json:"location,omitempty"`    // 发布包存放位置（bridge 地址 / 主机与目录），用于判断包是否被共享
+}
+
+type releaseLedgerFile struct {
+	NextID   int             `json:"next_id"`
+	Releases []ReleaseRecord `json:"releases"`
+}
+
+// ReleaseLedger 按项目/target 记录发布历史：<dir>/<project>/<target>.json
+type ReleaseLedger struct {
+	mu   sync.Mutex
+	dir  string
+	keep int
+}
+
+// NewReleaseLedger 创建发布账本，dir 为空时返回 nil（不记录）
+func NewReleaseLedger(dir string, keep int) *ReleaseLedger {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil
+	}
+	// 至少保留当前与上一个版本，否则无法回滚
+	if keep <= 0 {
+		keep = defaultReleaseKeep
+	}
+	if keep < 2 {
+		keep = 2
+	}
+	return &ReleaseLedger{dir: dir, keep: keep}
+}
+
+// Record 追加一条发布记录，返回分配了 ID 的记录以及超出保留数量、需要从目标上删除的包
+func (l *ReleaseLedger) Record(rec ReleaseRecord) (ReleaseRecord, []string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := l.loadLocked(rec.Project, rec.Target)
+	if err != nil {
+		return rec, nil, err
+	}
+	file.NextID++
+	rec.ID = file.NextID
+	if rec.DeployedAt == 0 {
+		rec.DeployedAt = time.Now().UnixMilli()
+	}
+	rec.Retained = true
+	// 回滚撤下的是此前的线上发布
+	if rec.RollbackOf > 0 && len(file.Releases) > 0 {
+		file.Releases[len(file.Releases)-1].RolledBack = true
+	}
+	file.Releases = append(file.Releases, rec)
+
+	// 从新到旧保留前 keep 个不同的包，其余标记为已清理
+	wasRetained := make(map[string]bool)
+	for _, r := range file.Releases[:len(file.Releases)-1] {
+		if r.Retained {
+			wasRetained[r.Package] = true
+		}
+	}
+	kept := make(map[string]bool)
+	for i := len(file.Releases) - 1; i >= 0; i-- {
+		r := &file.Releases[i]
+		if !kept[r.Package] && len(kept) < l.keep {
+			kept[r.Package] = true
+		}
+		r.Retained = kept[r.Package]
+	}
+	var pruned []string
+	for pkg := range wasRetained {
+		if !kept[pkg] && !l.retainedElsewhereLocked(rec, pkg) {
+			pruned = append(pruned, pkg)
+		}
+	}
+
+	if len(file.Releases) > maxReleaseHistory {
+		file.Releases = append([]ReleaseRecord(nil), file.Releases[len(file.Releases)-maxReleaseHistory:]...)
+	}
+	if err := l.saveLocked(rec.Project, rec.Target, file); err != nil {
+		return rec, nil, err
+	}
+	return rec, pruned, nil
+}
+
+// List 返回发布记录（新的在前）
+func (l *ReleaseLedger) List(project, target string) ([]ReleaseRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := l.loadLocked(project, target)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ReleaseRecord, 0, len(file.Releases))
+	for i := len(file.Releases) - 1; i >= 0; i-- {
+		out = append(out, file.Releases[i])
+	}
+	return out, nil
+}
+
+// RollbackCandidate 选择回滚目标：releaseID>0 时按 ID 查找，
+// 否则取当前线上包之前最近一个仍保留、健康检查通过且未被回滚撤下的不同包
+func (l *ReleaseLedger) RollbackCandidate(project, target string, releaseID int) (ReleaseRecord, error) {
+	releases, err := l.List(project, target)
+	if err != nil {
+		return ReleaseRecord{}, err
+	}
+	if len(releases) == 0 {
+		return ReleaseRecord{}, fmt.Errorf("项目 [%s] 目标 [%s] 没有发布记录", project, target)
+	}
+
+	if releaseID > 0 {
+		for _, r := range releases {
+			if r.ID != releaseID {
+				continue
+			}
+			if !r.Retained {
+				return ReleaseRecord{}, fmt.Errorf("发布 #%d 的包 %s 已被清理，无法回滚", r.ID, r.Package)
+			}
+			return r, nil
+		}
+		return ReleaseRecord{}, fmt.Errorf("发布 #%d 不存在", releaseID)
+	}
+
+	// 被回滚撤下的包不再自动选中，否则连续回滚会在两个版本间来回切换
+	rolledBack := make(map[string]bool)
+	for _, r := range releases {
+		if r.RolledBack {
+			rolledBack[r.Package] = true
+		}
+	}
+	current := releases[0].Package
+	for _, r := range releases[1:] {
+		if r.Retained && r.Package != current && r.Status != releaseStatusUnhealthy && !rolledBack[r.Package] {
+			return r, nil
+		}
+	}
+	return ReleaseRecord{}, fmt.Errorf("项目 [%s] 目标 [%s] 没有可回滚的历史版本", project, target)
+}
+
+// retainedElsewhereLocked 其他账本（含其他项目）在同一存放位置仍保留该包时不删除。
+// 没有 location 的旧记录无法判断位置，按共享处理
+func (l *ReleaseLedger) retainedElsewhereLocked(rec ReleaseRecord, pkg string) bool {
+	ledgers, err := filepath.Glob(filepath.Join(l.dir, "*", "*.json"))
+	if err != nil {
+		return false
+	}
+	self := l.path(rec.Project, rec.Target)
+	for _, path := range ledgers {
+		if path == self {
+			continue
+		}
+		var other releaseLedgerFile
+		data, err := os.ReadFile(path)
+		if err != nil || json.Unmarshal(data, &other) != nil {
+			continue
+		}
+		for _, r := range other.Releases {
+			if r.Retained && r.Package == pkg && (r.Location == "" || rec.Location == "" || r.Location == rec.Location) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l *ReleaseLedger) path(project, target string) string {
+	return filepath.Join(l.dir, sanitizeReleaseName(project), sanitizeReleaseName(target)+".json")
+}
+
+func (l *ReleaseLedger) loadLocked(project, target string) (*releaseLedgerFile, error) {
+	file := &releaseLedgerFile{}
+	data, err := os.ReadFile(l.path(project, target))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		return nil, fmt.Errorf("read release ledger: %v", err)
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("parse release ledger: %v", err)
+	}
+	return file, nil
+}
+
+func (l *ReleaseLedger) saveLocked(project, target string, file *releaseLedgerFile) error {
+	path := l.path(project, target)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create release dir: %v", err)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal release ledger: %v", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("write release ledger: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("rename release ledger: %v", err)
+	}
+	return nil
+}
+
+var releaseNameReplacer = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sanitizeReleaseName 将项目/target 名转换为安全的文件名
+func sanitizeReleaseName(name string) string {
+	name = releaseNameReplacer.ReplaceAllString(strings.TrimSpace(name), "_")
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// currentUserName 返回当前系统用户名（CLI 模式下作为触发人）
+func currentUserName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "cli"
+}
+
+// ========================= 发布记录与回滚 =========================
+
+// recordRelease 部署完成后写入账本，返回超出保留数量、需要从目标上清理的包
+func (d *Deployer) recordRelease(t *Target, binaryMD5, status string) []string {
+	if d.cfg.Releases == nil || d.packFile == "" {
+		return nil
+	}
+	rec := ReleaseRecord{
+		Project:   d.proj.Name,
+		Target:    t.Name,
+		Package:   d.packFile,
+		BinaryMD5: binaryMD5,
+		User:      d.TriggerUser,
+		Pipeline:  d.Pipeline,
+		Status:    status,
+		Location:  releaseLocation(t),
+	}
+	if d.rollbackTo != nil {
+		rec.MD5 = d.rollbackTo.MD5
+		rec.RollbackOf = d.rollbackTo.ID
+	} else if sum, err := d.localFileMD5(d.packLocalPath()); err == nil {
+		rec.MD5 = sum
+	}
+
+	saved, pruned, err := d.cfg.Releases.Record(rec)
+	if err != nil {
+		d.logf("info", "  > 写入发布记录失败: %v\n", err)
+		return nil
+	}
+	d.releases = append(d.releases, saved)
+	d.logf("info", "  > 发布记录 #%d: %s (%s)\n", saved.ID, saved.Package, shortMD5(saved.MD5))
+	if len(pruned) > 0 {
+		d.logf("info", "  > 清理旧发布包: %s\n", strings.Join(pruned, ", "))
+	}
+	return pruned
+}
+
+// releaseLocation 发布包在目标上的存放位置：bridge 按地址（包集中存放在 bridge 上），
+// 本机与 SSH 按主机和部署目录
+func releaseLocation(t *Target) string {
+	switch {
+	case t.Type == "bridge":
+		return "bridge:" + strings.TrimRight(t.BridgeURL, "/")
+	case isLocalTarget(t.Host):
+		dir, err := filepath.Abs(t.RemoteDir)
+		if err != nil {
+			dir = t.RemoteDir
+		}
+		return "local:" + dir
+	}
+	_, host := parseHost(t.Host)
+	return "ssh:" + host + ":" + strings.TrimRight(t.RemoteDir, "/")
+}
+
+// resolveRollbackTarget 按名称匹配回滚 target（仅一个可用 target 时可省略）
+func (d *Deployer) resolveRollbackTarget(targetName string) (*Target, error) {
+	var candidates []*Target
+	for _, t := range d.proj.Targets {
+		if t == nil || t.Type == "command" {
+			continue
+		}
+		if isLocalTarget(t.Host) && t.Platform != "" && t.Platform != d.cfg.HostPlatform {
+			continue
+		}
+		if targetName == "" || t.Name == targetName || t.Host == targetName || strings.HasPrefix(t.Name, targetName+".") {
+			candidates = append(candidates, t)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		if targetName == "" {
+			return nil, fmt.Errorf("project %q has no rollback-capable targets", d.proj.Name)
+		}
+		return nil, fmt.Errorf("target %q not found", targetName)
+	case 1:
+		return candidates[0], nil
+	}
+	names := make([]string, 0, len(candidates))
+	for _, t := range candidates {
+		names = append(names, t.Name)
+	}
+	return nil, fmt.Errorf("匹配到多个 target，请指定其一: %s", strings.Join(names, ", "))
+}
+
+// Rollback 将 target 回滚到账本中的发布（releaseID<=0 表示上一个版本）
+// 复用目标上保留的发布包，不重新打包和上传；自动模式下按增量部署处理以保护 ProtectFiles
+func (d *Deployer) Rollback(targetName string, releaseID int) (*ReleaseRecord, error) {
+	if d.cfg.Releases == nil {
+		return nil, fmt.Errorf("未启用发布账本（releases_dir）")
+	}
+	start := time.Now()
+	d.mergeAgentProtectedFiles()
+
+	t, err := d.resolveRollbackTarget(targetName)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := d.cfg.Releases.RollbackCandidate(d.proj.Name, t.Name, releaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	d.rollbackTo = &rec
+	d.packFile = rec.Package
+	d.packSource = ""
+	if d.DeployMode == DeployModeAuto {
+		d.DeployMode = DeployModeIncr
+	}
+	d.logf("info", "[ROLLBACK] 项目 [%s] 目标 [%s] 回滚到 #%d %s (%s, %s)\n",
+		d.proj.Name, t.Name, rec.ID, rec.Package, shortMD5(rec.MD5),
+		time.UnixMilli(rec.DeployedAt).Format("2006-01-02 15:04:05"))
+
+	const totalSteps = 4
+	if isLocalTarget(t.Host) {
+		err = d.deployLocal(t, totalSteps)
+	} else if t.Type == "bridge" {
+		err = d.deployBridge(t, totalSteps)
+	} else {
+		err = d.deployRemote(t, totalSteps)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("回滚失败: %v", err)
+	}
+
+	d.logf("info", "[DONE] 项目 [%s] 已回滚到 #%d，耗时 %s\n", d.proj.Name, rec.ID, formatDuration(time.Since(start)))
+	if len(d.releases) == 0 {
+		return &rec, nil
+	}
+	latest := d.releases[len(d.releases)-1]
+	return &latest, nil
+}
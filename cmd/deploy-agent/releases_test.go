@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestReleaseLedgerRetainsLastPackages(t *testing.T) {
+	ledger := NewReleaseLedger(t.TempDir(), 2)
+	var pruned []string
+	for _, pkg := range []string{"a.zip", "b.zip", "a.zip", "c.zip"} {
+		rec, p, err := ledger.Record(ReleaseRecord{Project: "demo", Target: "prod", Package: pkg, User: "ztt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rec.Retained || rec.ID == 0 {
+			t.Fatalf("new release should be retained with an id: %+v", rec)
+		}
+		pruned = append(pruned, p...)
+	}
+	// 重新部署 a 后 b 变为最旧，c 发布时只清理 b
+	if len(pruned) != 1 || pruned[0] != "b.zip" {
+		t.Fatalf("unexpected pruned packages: %v", pruned)
+	}
+
+	releases, err := ledger.List("demo", "prod")
+	if err != nil || len(releases) != 4 || releases[0].Package != "c.zip" {
+		t.Fatalf("unexpected releases: %+v err=%v", releases, err)
+	}
+	prev, err := ledger.RollbackCandidate("demo", "prod", 0)
+	if err != nil || prev.Package != "a.zip" || prev.ID != 3 {
+		t.Fatalf("expected previous release a.zip #3, got %+v err=%v", prev, err)
+	}
+	if _, err := ledger.RollbackCandidate("demo", "prod", 2); err == nil {
+		t.Fatal("pruned release should not be a rollback candidate")
+	}
+}
+
+func TestRollbackCandidateSkipsRolledBackReleases(t *testing.T) {
+	ledger := NewReleaseLedger(t.TempDir(), 5)
+	for _, pkg := range []string{"a.zip", "b.zip", "c.zip"} {
+		if _, _, err := ledger.Record(ReleaseRecord{Project: "demo", Target: "prod", Package: pkg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rollback := func() ReleaseRecord {
+		t.Helper()
+		prev, err := ledger.RollbackCandidate("demo", "prod", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := ledger.Record(ReleaseRecord{Project: "demo", Target: "prod", Package: prev.Package, RollbackOf: prev.ID}); err != nil {
+			t.Fatal(err)
+		}
+		return prev
+	}
+
+	// c -> b -> a：第二次回滚不能再回到刚撤下的 c
+	if prev := rollback(); prev.Package != "b.zip" {
+		t.Fatalf("first rollback should restore b.zip, got %+v", prev)
+	}
+	if prev := rollback(); prev.Package != "a.zip" {
+		t.Fatalf("second rollback should restore a.zip, got %+v", prev)
+	}
+	// b 与 c 都已被回滚撤下，没有更早的版本可用
+	if prev, err := ledger.RollbackCandidate("demo", "prod", 0); err == nil {
+		t.Fatalf("rolled-back releases should not be candidates, got %+v", prev)
+	}
+	if prev, err := ledger.RollbackCandidate("demo", "prod", 3); err != nil || prev.Package != "c.zip" {
+		t.Fatalf("explicit release id should still be allowed, got %+v err=%v", prev, err)
+	}
+
+	releases, err := ledger.List("demo", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range releases {
+		if wantRolledBack := r.ID == 3 || r.ID == 4; r.RolledBack != wantRolledBack {
+			t.Fatalf("release #%d rolled_back=%v, want %v", r.ID, r.RolledBack, wantRolledBack)
+		}
+	}
+}
+
+func TestRollbackLocalTargetReusesRetainedPackage(t *testing.T) {
+	if _, err := exec.LookPath("zip"); err != nil {
+		t.Skip("zip not available")
+	}
+	if _, err := exec.LookPath("unzip"); err != nil {
+		t.Skip("unzip not available")
+	}
+
+	projectDir := t.TempDir()
+	targetDir := t.TempDir()
+	script := "#!/bin/bash\nset -e\nv=$(cat VERSION)\nmkdir -p stage\nprintf \"$v\" > stage/demo\nprintf 'packaged' > stage/config.json\n" +
+		"(cd stage && zip -q ../demo_$v.zip demo config.json)\n"
+	if err := os.WriteFile(filepath.Join(projectDir, "pack.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &DeployConfig{
+		HostPlatform: platformSubdir(),
+		Releases:     NewReleaseLedger(t.TempDir(), 2),
+	}
+	proj := &ProjectConfig{
+		Name:         "demo",
+		ProjectDir:   projectDir,
+		PackScript:   filepath.Join(projectDir, "pack.sh"),
+		PackPattern:  "demo_{date}.zip",
+		ProtectFiles: []string{"config.json"},
+		Targets:      []*Target{{Name: "local", Host: "local", RemoteDir: targetDir}},
+	}
+	newDeployer := func() *Deployer {
+		d := NewDeployer(cfg, proj, "")
+		d.TriggerUser = "ztt"
+		d.OnProgress = func(string, string) {}
+		return d
+	}
+
+	for _, version := range []string{"v1", "v2", "v3"} {
+		if err := os.WriteFile(filepath.Join(projectDir, "VERSION"), []byte(version), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := newDeployer().Run(false, "local"); err != nil {
+			t.Fatalf("deploy %s: %v", version, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "demo_v1.zip")); !os.IsNotExist(err) {
+		t.Fatalf("oldest package should be pruned from target, stat err=%v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(targetDir, "config.json"), []byte("prod"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	release, err := newDeployer().Rollback("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release.Package != "demo_v2.zip" || release.RollbackOf != 2 || release.User != "ztt" {
+		t.Fatalf("unexpected rollback release: %+v", release)
+	}
+	if data, _ := os.ReadFile(filepath.Join(targetDir, "demo")); string(data) != "v2" {
+		t.Fatalf("binary should be restored to v2, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(targetDir, "config.json")); string(data) != "prod" {
+		t.Fatalf("protected file should be kept, got %q", data)
+	}
+}
+
+func TestReleaseLedgerKeepsPackagesSharedAcrossProjects(t *testing.T) {
+	ledger := NewReleaseLedger(t.TempDir(), 2)
+	bridge := releaseLocation(&Target{Type: "bridge", BridgeURL: "http://bridge:9010/"})
+	record := func(project, target, pkg, location string) []string {
+		t.Helper()
+		_, pruned, err := ledger.Record(ReleaseRecord{Project: project, Target: target, Package: pkg, Location: location})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pruned
+	}
+
+	// 两个项目通过同一 bridge 部署了同一个包（bridge 按 MD5 复用包名）
+	record("blog", "prod", "shared.zip", bridge)
+	record("admin", "prod", "shared.zip", bridge)
+	record("admin", "staging", "shared.zip", "ssh:10.0.0.2:/opt/admin")
+	record("blog", "prod", "b.zip", bridge)
+	if pruned := record("blog", "prod", "c.zip", bridge); len(pruned) != 0 {
+		t.Fatalf("package retained by another project on the same bridge must not be pruned: %v", pruned)
+	}
+
+	// 其他位置上的同名包不影响清理
+	record("admin", "prod", "x.zip", bridge)
+	if pruned := record("admin", "prod", "y.zip", bridge); len(pruned) != 1 || pruned[0] != "shared.zip" {
+		t.Fatalf("package only retained on another host should be pruned here: %v", pruned)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote("/opt/my app/it's.zip"); got != `'/opt/my app/it'\''s.zip'` {
+		t.Fatalf("unexpected quoting: %s", got)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 发布策略
+const (
+	RolloutRolling = "rolling" // 按批次依次发布
+	RolloutCanary  = "canary"  // 先发布一台金丝雀，健康后再发布其余目标
+)
+
+// 批次状态
+const (
+	BatchSuccess = "success"
+	BatchFailed  = "failed"
+	BatchSkipped = "skipped"
+)
+
+// RolloutConfig 项目发布策略（settings 中 rollout 字段）
+type RolloutConfig struct {
+	Strategy  string `json:"strategy"`             // rolling | canary
+	BatchSize int    `json:"batch_size,omitempty"` // rolling 默认 1；canary 为金丝雀之后每批数量，0 表示其余一次发布
+	PauseSec  int    `json:"pause_sec,omitempty"`  // 批次间暂停秒数
+	Canary    string `json:"canary,omitempty"`     // 金丝雀 target 名（默认第一个选中且配置了 health_checks 的 target）
+}
+
+// RolloutBatch 单个批次的执行结果
+type RolloutBatch struct {
+	Index   int      `json:"index"`
+	Label   string   `json:"label"`
+	Targets []string `json:"targets"`
+	Status  string   `json:"status"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// validateRollout 加载配置时校验发布策略
+func validateRollout(r *RolloutConfig) error {
+	if r == nil {
+		return nil
+	}
+	switch r.Strategy {
+	case RolloutRolling, RolloutCanary:
+	default:
+		return fmt.Errorf("rollout: unknown strategy %q", r.Strategy)
+	}
+	if r.BatchSize < 0 || r.PauseSec < 0 {
+		return fmt.Errorf("rollout: batch_size and pause_sec must not be negative")
+	}
+	return nil
+}
+
+// validateRolloutTargets 加载配置时校验金丝雀：只以部署成功作为门禁发现不了问题，必须配置健康检查
+func validateRolloutTargets(r *RolloutConfig, targets []*Target) error {
+	if r == nil || r.Strategy != RolloutCanary {
+		return nil
+	}
+	eligible := false
+	for _, t := range targets {
+		if r.Canary != "" && t.Name == r.Canary && !canaryEligible(t) {
+			return fmt.Errorf("rollout: canary target %q has no health_checks", r.Canary)
+		}
+		eligible = eligible || canaryEligible(t)
+	}
+	if !eligible {
+		return fmt.Errorf("rollout: canary strategy requires a target with health_checks")
+	}
+	return nil
+}
+
+// canaryEligible 可作为金丝雀的 target：部署后会执行健康检查（command 类型不执行）
+func canaryEligible(t *Target) bool {
+	return t.Type != "command" && len(t.HealthChecks) > 0
+}
+
+// planRollout 将选中的 target 划分为批次
+func planRollout(r *RolloutConfig, targets []*Target) ([][]*Target, []string) {
+	var batches [][]*Target
+	var labels []string
+
+	rest := targets
+	batchSize := r.BatchSize
+	if r.Strategy == RolloutCanary {
+		canary := 0
+		for i, t := range targets {
+			if canaryEligible(t) {
+				canary = i
+				break
+			}
+		}
+		for i, t := range targets {
+			if r.Canary != "" && t.Name == r.Canary {
+				canary = i
+				break
+			}
+		}
+		batches = append(batches, []*Target{targets[canary]})
+		labels = append(labels, "canary")
+		rest = make([]*Target, 0, len(targets)-1)
+		rest = append(rest, targets[:canary]...)
+		rest = append(rest, targets[canary+1:]...)
+		if batchSize <= 0 {
+			batchSize = len(rest)
+		}
+	} else if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	for start := 0; start < len(rest); start += batchSize {
+		end := start + batchSize
+		if end > len(rest) {
+			end = len(rest)
+		}
+		batches = append(batches, rest[start:end])
+		labels = append(labels, "batch")
+	}
+	return batches, labels
+}
+
+// runRollout 按批次发布：批次内任一 target 部署或健康检查失败、或任务被取消即停止，后续批次保持不动
+func (d *Deployer) runRollout(targets []*Target, deployTarget func(t *Target) error) error {
+	r := d.proj.Rollout
+	batches, labels := planRollout(r, targets)
+	d.rollout = make([]RolloutBatch, len(batches))
+	for i, batch := range batches {
+		d.rollout[i] = RolloutBatch{Index: i + 1, Label: labels[i], Targets: targetNames(batch), Status: BatchSkipped}
+	}
+
+	if r.Strategy == RolloutCanary && r.Canary != "" && batches[0][0].Name != r.Canary {
+		d.logf("info", "[ROLLOUT] canary %s 未被选中，改用 %s\n", r.Canary, batches[0][0].Name)
+	}
+	if r.Strategy == RolloutCanary && !canaryEligible(batches[0][0]) {
+		return fmt.Errorf("canary %s 未配置 health_checks，拒绝发布", batches[0][0].Name)
+	}
+	d.logf("info", "[ROLLOUT] %s 发布 %d 个目标，共 %d 批\n", r.Strategy, len(targets), len(batches))
+
+	for i, batch := range batches {
+		rb := &d.rollout[i]
+		label := fmt.Sprintf("批次 %d/%d", rb.Index, len(batches))
+		if rb.Label == "canary" {
+			label += " (canary)"
+		}
+		if i > 0 && r.PauseSec > 0 {
+			d.logf("info", "[ROLLOUT] 暂停 %ds 后开始%s...\n", r.PauseSec, label)
+			if err := d.waitRolloutPause(time.Duration(r.PauseSec) * time.Second); err != nil {
+				d.logf("error", "[ROLLOUT] 任务已取消，停止发布，未发布: %s\n", strings.Join(remainingTargets(batches[i:]), ", "))
+				return fmt.Errorf("%s 开始前任务已取消: %v", label, err)
+			}
+		}
+		d.logf("info", "[ROLLOUT] %s 开始: %s\n", label, strings.Join(rb.Targets, ", "))
+
+		var errs []string
+		for _, t := range batch {
+			if err := deployTarget(t); err != nil {
+				errs = append(errs, fmt.Sprintf("[%s] %v", t.Name, err))
+			}
+		}
+		if len(errs) > 0 {
+			rb.Status = BatchFailed
+			rb.Error = strings.Join(errs, "; ")
+			remaining := remainingTargets(batches[i+1:])
+			msg := fmt.Sprintf("[ROLLOUT] %s 失败，停止发布", label)
+			if len(remaining) > 0 {
+				msg += "，未发布: " + strings.Join(remaining, ", ")
+			}
+			d.logf("error", "%s\n", msg)
+			return fmt.Errorf("%s 失败: %s", label, rb.Error)
+		}
+		rb.Status = BatchSuccess
+		d.logf("info", "[ROLLOUT] %s 完成\n", label)
+	}
+	return nil
+}
+
+// waitRolloutPause 批次间暂停，任务上下文取消时立即返回
+func (d *Deployer) waitRolloutPause(pause time.Duration) error {
+	ctx := d.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(pause)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func remainingTargets(batches [][]*Target) []string {
+	var names []string
+	for _, batch := range batches {
+		names = append(names, targetNames(batch)...)
+	}
+	return names
+}
+
+func targetNames(targets []*Target) []string {
+	names := make([]string, len(targets))
+	for i, t := range targets {
+		names[i] = t.Name
+	}
+	return names
+}
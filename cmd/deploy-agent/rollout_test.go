@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanRolloutBatches(t *testing.T) {
+	targets := []*Target{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+	plan := func(r *RolloutConfig) [][]string {
+		batches, _ := planRollout(r, targets)
+		var names [][]string
+		for _, b := range batches {
+			names = append(names, targetNames(b))
+		}
+		return names
+	}
+
+	if got := plan(&RolloutConfig{Strategy: RolloutRolling, BatchSize: 2}); !reflect.DeepEqual(got, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}) {
+		t.Fatalf("rolling batch_size=2: %v", got)
+	}
+	if got := plan(&RolloutConfig{Strategy: RolloutRolling}); len(got) != 5 {
+		t.Fatalf("rolling should default to one target per batch: %v", got)
+	}
+	if got := plan(&RolloutConfig{Strategy: RolloutCanary, Canary: "c"}); !reflect.DeepEqual(got, [][]string{{"c"}, {"a", "b", "d", "e"}}) {
+		t.Fatalf("canary should go first and the rest in one batch: %v", got)
+	}
+	if got := plan(&RolloutConfig{Strategy: RolloutCanary, Canary: "missing", BatchSize: 3}); !reflect.DeepEqual(got, [][]string{{"a"}, {"b", "c", "d"}, {"e"}}) {
+		t.Fatalf("unknown canary should fall back to the first target: %v", got)
+	}
+
+	if err := validateRollout(&RolloutConfig{Strategy: "blue-green"}); err == nil {
+		t.Fatal("unknown strategy should be rejected")
+	}
+
+	// 未指定 canary 时优先选配置了健康检查的 target
+	targets[3].HealthChecks = []HealthCheck{{Type: HealthCheckCommand, Command: "true"}}
+	if got := plan(&RolloutConfig{Strategy: RolloutCanary}); !reflect.DeepEqual(got, [][]string{{"d"}, {"a", "b", "c", "e"}}) {
+		t.Fatalf("canary should default to a health-checked target: %v", got)
+	}
+}
+
+func TestValidateRolloutRequiresCanaryHealthChecks(t *testing.T) {
+	checked := &Target{Name: "edge-1", HealthChecks: []HealthCheck{{Type: HealthCheckCommand, Command: "true"}}}
+	unchecked := &Target{Name: "edge-2"}
+	canary := func(name string) *RolloutConfig {
+		return &RolloutConfig{Strategy: RolloutCanary, Canary: name}
+	}
+
+	if err := validateRolloutTargets(canary("edge-2"), []*Target{checked, unchecked}); err == nil {
+		t.Fatal("canary target without health_checks should be rejected")
+	}
+	if err := validateRolloutTargets(canary(""), []*Target{unchecked}); err == nil {
+		t.Fatal("canary strategy without any health-checked target should be rejected")
+	}
+	if err := validateRolloutTargets(canary("edge-1"), []*Target{checked, unchecked}); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateRolloutTargets(&RolloutConfig{Strategy: RolloutRolling}, []*Target{unchecked}); err != nil {
+		t.Fatalf("rolling does not require health checks: %v", err)
+	}
+}
+
+func TestRolloutPauseStopsWhenTaskCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := &Deployer{
+		proj:       &ProjectConfig{Name: "demo", Rollout: &RolloutConfig{Strategy: RolloutRolling, PauseSec: 3600}},
+		OnProgress: func(string, string) {},
+		Ctx:        ctx,
+	}
+
+	var deployed []string
+	done := make(chan error, 1)
+	go func() {
+		done <- d.runRollout([]*Target{{Name: "a"}, {Name: "b"}}, func(t *Target) error {
+			deployed = append(deployed, t.Name)
+			cancel()
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("cancelled rollout should report an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pause between batches should stop when the task is cancelled")
+	}
+	if !reflect.DeepEqual(deployed, []string{"a"}) || d.rollout[1].Status != BatchSkipped {
+		t.Fatalf("later batches should be left untouched: deployed=%v batches=%+v", deployed, d.rollout)
+	}
+}
+
+func TestRolloutHaltsOnFailedCanary(t *testing.T) {
+	if _, err := exec.LookPath("zip"); err != nil {
+		t.Skip("zip not available")
+	}
+	if _, err := exec.LookPath("unzip"); err != nil {
+		t.Skip("unzip not available")
+	}
+
+	projectDir := t.TempDir()
+	script := "#!/bin/bash\nset -e\nmkdir -p stage\nprintf v1 > stage/demo\n(cd stage && zip -q ../demo_v1.zip demo)\n"
+	if err := os.WriteFile(filepath.Join(projectDir, "pack.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var targets []*Target
+	for _, name := range []string{"edge-1", "edge-2", "edge-3"} {
+		targets = append(targets, &Target{Name: name, Host: "local", RemoteDir: t.TempDir()})
+	}
+	// 金丝雀的健康检查必然失败
+	targets[1].HealthChecks = []HealthCheck{{Type: HealthCheckCommand, Command: "exit 1", Retries: 1}}
+
+	proj := &ProjectConfig{
+		Name:        "demo",
+		ProjectDir:  projectDir,
+		PackScript:  filepath.Join(projectDir, "pack.sh"),
+		PackPattern: "demo_{date}.zip",
+		Targets:     targets,
+		Rollout:     &RolloutConfig{Strategy: RolloutCanary, Canary: "edge-2"},
+	}
+	d := NewDeployer(&DeployConfig{HostPlatform: platformSubdir(), Releases: NewReleaseLedger(t.TempDir(), 3)}, proj, "")
+	var logs []string
+	d.OnProgress = func(_, msg string) { logs = append(logs, msg) }
+
+	err := d.Run(false, "")
+	if err == nil || !strings.Contains(err.Error(), "canary") {
+		t.Fatalf("failed canary should halt the rollout, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targets[1].RemoteDir, "demo")); err != nil {
+		t.Fatalf("canary should have been deployed: %v", err)
+	}
+	for _, tgt := range []*Target{targets[0], targets[2]} {
+		if _, err := os.Stat(filepath.Join(tgt.RemoteDir, "demo")); !os.IsNotExist(err) {
+			t.Fatalf("%s should be left untouched after the canary failed", tgt.Name)
+		}
+	}
+
+	if len(d.rollout) != 2 || d.rollout[0].Status != BatchFailed || d.rollout[1].Status != BatchSkipped {
+		t.Fatalf("unexpected batches: %+v", d.rollout)
+	}
+	if !strings.Contains(strings.Join(logs, ""), "未发布: edge-1, edge-3") {
+		t.Fatalf("progress should list the untouched targets: %v", logs)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 发布包签名：对 zip 的 SHA-256 摘要做 Ed25519 签名，写入 <package>.zip.sig（base64）。
+// bridge 在 deploy-bridge-server.json 的 trusted_keys 中配置公钥，部署前校验。
+const packageSigSuffix = ".sig"
+
+// loadSigningKey 读取私钥文件（base64 编码的 32 字节 seed 或 64 字节私钥）
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取签名私钥失败: %v", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("签名私钥 %s 不是有效的 base64: %v", path, err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, fmt.Errorf("签名私钥 %s 长度无效: %d 字节", path, len(raw))
+}
+
+// signingPublicKey 返回私钥对应公钥的 base64（填入 bridge trusted_keys）
+func signingPublicKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// signPackage 对包签名，返回 base64 签名
+func signPackage(key ed25519.PrivateKey, zipPath string) (string, error) {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, h.Sum(nil))), nil
+}
+
+// signPack 打包完成后签名并写入 .sig 文件（未配置 signing_key 时跳过）
+func (d *Deployer) signPack() error {
+	d.packSignature = ""
+	if d.cfg.SigningKey == nil {
+		return nil
+	}
+	zipPath := d.packLocalPath()
+	sig, err := signPackage(d.cfg.SigningKey, zipPath)
+	if err != nil {
+		return fmt.Errorf("签名失败: %v", err)
+	}
+	if err := os.WriteFile(zipPath+packageSigSuffix, []byte(sig+"\n"), 0644); err != nil {
+		return fmt.Errorf("写入签名失败: %v", err)
+	}
+	d.packSignature = sig
+	return nil
+}
+
+// runGenKeyCommand genkey 子命令：生成签名私钥文件并输出公钥
+func runGenKeyCommand(args []string) int {
+	fs := flag.NewFlagSet("genkey", flag.ContinueOnError)
+	out := fs.String("out", "deploy-signing.key", "私钥输出路径（填入 deploy-agent.json 的 signing_key）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if _, err := os.Stat(*out); err == nil {
+		fmt.Fprintf(os.Stderr, "私钥文件已存在: %s（不会覆盖）\n", *out)
+		return 1
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成密钥失败: %v\n", err)
+		return 1
+	}
+	seed := base64.StdEncoding.EncodeToString(priv.Seed())
+	if err := os.WriteFile(*out, []byte(seed+"\n"), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "写入私钥失败: %v\n", err)
+		return 1
+	}
+	fmt.Printf("已生成签名私钥: %s\n", *out)
+	fmt.Printf("公钥（加入 deploy-bridge-server.json 的 trusted_keys）:\n  %s\n", signingPublicKey(priv))
+	return 0
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSignPackageVerifiesWithPublicKey(t *testing.T) {
+	dir := t.TempDir()
+	_, priv, _ := ed25519.GenerateKey(nil)
+	keyPath := filepath.Join(dir, "signing.key")
+	if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(priv.Seed())+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := loadSigningKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(dir, "demo_v1.zip")
+	os.WriteFile(zipPath, []byte("PK demo"), 0644)
+	d := NewDeployer(&DeployConfig{SigningKey: key}, &ProjectConfig{Name: "demo"}, "")
+	d.packSource, d.packFile = zipPath, "demo_v1.zip"
+	if err := d.signPack(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(zipPath + packageSigSuffix)
+	if err != nil || strings.TrimSpace(string(data)) != d.packSignature {
+		t.Fatalf("signature file should hold the package signature: %v", err)
+	}
+	sig, _ := base64.StdEncoding.DecodeString(d.packSignature)
+	pub, _ := base64.StdEncoding.DecodeString(signingPublicKey(key))
+	digest := sha256.Sum256([]byte("PK demo"))
+	if !ed25519.Verify(ed25519.PublicKey(pub), digest[:], sig) {
+		t.Fatal("signature should verify against the SHA-256 digest with the public key")
+	}
+}
+
+func TestBridgeUploadResumesMissingChunks(t *testing.T) {
+	content := []byte("0123456789abcdefghij") // chunk_size=8 → 3 个分片
+	zipPath := filepath.Join(t.TempDir(), "demo_v1.zip")
+	os.WriteFile(zipPath, content, 0644)
+
+	var mu sync.Mutex
+	var initReq map[string]interface{}
+	var put []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/api/upload/init":
+			json.NewDecoder(r.Body).Decode(&initReq)
+			fmt.Fprint(w, `{"upload_id":"u_1","filename":"demo_v1.zip","chunk_size":8,"total_chunks":3,"received":[0]}`)
+		case strings.HasPrefix(r.URL.Path, "/api/upload/u_1/chunk/"):
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Chunk-SHA256") != hex.EncodeToString(sum[:]) {
+				http.Error(w, `{"error":"checksum"}`, http.StatusBadRequest)
+				return
+			}
+			put = append(put, strings.TrimPrefix(r.URL.Path, "/api/upload/u_1/chunk/")+"="+string(body))
+			fmt.Fprint(w, `{}`)
+		case r.URL.Path == "/api/upload/u_1/complete":
+			fmt.Fprint(w, `{"filename":"demo_v1.zip"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDeployer(&DeployConfig{}, &ProjectConfig{Name: "demo"}, "")
+	d.OnProgress = func(string, string) {}
+	d.packSignature = "c2ln"
+	name, err := d.bridgeUpload(srv.URL, "token", zipPath)
+	if err != nil || name != "demo_v1.zip" {
+		t.Fatalf("chunked upload failed: %q %v", name, err)
+	}
+	if got := strings.Join(put, ","); got != "1=89abcdef,2=ghij" {
+		t.Fatalf("only missing chunks should be uploaded, got %s", got)
+	}
+	if initReq["signature"] != "c2ln" || initReq["size"] != float64(len(content)) {
+		t.Fatalf("init should carry size and signature: %v", initReq)
+	}
+}
+
+func TestBridgeUploadFallsBackToWholeUpload(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "demo_v1.zip")
+	os.WriteFile(zipPath, []byte("PK demo"), 0644)
+
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/packages":
+			fmt.Fprint(w, `[]`)
+		case "/api/upload":
+			signature = r.FormValue("signature")
+			fmt.Fprint(w, `{"filename":"demo_v1.zip"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDeployer(&DeployConfig{}, &ProjectConfig{Name: "demo"}, "")
+	d.OnProgress = func(string, string) {}
+	d.packSignature = "c2ln"
+	if name, err := d.bridgeUpload(srv.URL, "token", zipPath); err != nil || name != "demo_v1.zip" {
+		t.Fatalf("legacy upload failed: %q %v", name, err)
+	}
+	if signature != "c2ln" {
+		t.Fatalf("legacy upload should send the signature field, got %q", signature)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"uap"
@@ -16,6 +18,7 @@ const (
 	deployTaskStatusError      = "error"
 	maxTaskHistory             = 200
 	defaultAppAgentID          = "app-app-agent"
+	defaultWechatAgentID       = "wechat-wechat-agent"
 	flutterAPKNotifyUser       = "ztt"
 )
 
@@ -128,6 +131,18 @@ func (c *Connection) reserveTask(rec *deployTaskRecord) bool {
 	return true
 }
 
+// stopTask 取消运行中的任务，任务不存在时返回 false
+func (c *Connection) stopTask(sessionID string) bool {
+	c.taskMu.Lock()
+	cancel := c.taskCancels[sessionID]
+	c.taskMu.Unlock()
+	if cancel == nil {
+		return false
+	}
+	cancel()
+	return true
+}
+
 func (c *Connection) setTaskRunning(sessionID string) {
 	c.taskMu.Lock()
 	defer c.taskMu.Unlock()
@@ -147,6 +162,7 @@ func (c *Connection) completeTask(sessionID, status, errMsg string, result map[s
 	defer c.taskMu.Unlock()
 
 	delete(c.activeTasks, sessionID)
+	delete(c.taskCancels, sessionID)
 	rec := c.taskRecords[sessionID]
 	if rec == nil {
 		return nil
@@ -200,7 +216,7 @@ func (c *Connection) submitTask(
 	rec *deployTaskRecord,
 	sourceAgent string,
 	sendEvent func(level, text string),
-	run func() (map[string]any, error),
+	run func(ctx context.Context) (map[string]any, error),
 ) error {
 	if rec == nil {
 		return fmt.Errorf("empty task record")
@@ -208,10 +224,15 @@ func (c *Connection) submitTask(
 	if !c.reserveTask(rec) {
 		return fmt.Errorf("deploy agent busy")
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.taskMu.Lock()
+	c.taskCancels[rec.SessionID] = cancel
+	c.taskMu.Unlock()
 
 	go func() {
+		defer cancel()
 		c.setTaskRunning(rec.SessionID)
-		result, err := run()
+		result, err := run(ctx)
 
 		status := deployTaskStatusDone
 		errMsg := ""
@@ -264,6 +285,46 @@ func (c *Connection) sendAppNotification(userID, content string) error {
 	})
 }
 
+// healthReporter 返回 Deployer.OnHealthResult 回调：结果写入任务流事件，
+// 健康检查失败与回滚结果同时推送到 app（接收人为 health_notify_user，未配置时为触发人）
+func (c *Connection) healthReporter(emit func(level, text string), triggerUser string) func(HealthOutcome) {
+	notifyUser := strings.TrimSpace(c.cfg.HealthNotifyUser)
+	if notifyUser == "" {
+		notifyUser = strings.TrimSpace(triggerUser)
+	}
+	return func(o HealthOutcome) {
+		if o.Healthy {
+			if emit != nil {
+				emit("system", fmt.Sprintf("💚 [%s/%s] 健康检查通过: %s", o.Project, o.Target, o.Package))
+			}
+			return
+		}
+
+		messages := []string{fmt.Sprintf("[%s/%s] %s 健康检查失败: %s", o.Project, o.Target, o.Package, o.Error)}
+		switch {
+		case o.RolledBack && o.RollbackRelease != nil:
+			messages = append(messages, fmt.Sprintf("[%s/%s] 已自动回滚到 %s (发布 #%d)",
+				o.Project, o.Target, o.RollbackRelease.Package, o.RollbackRelease.RollbackOf))
+		case o.RollbackError != "":
+			messages = append(messages, fmt.Sprintf("[%s/%s] 自动回滚失败: %s", o.Project, o.Target, o.RollbackError))
+		default:
+			messages = append(messages, fmt.Sprintf("[%s/%s] 未启用自动回滚，请手动处理", o.Project, o.Target))
+		}
+		for _, msg := range messages {
+			if emit != nil {
+				emit("error", "🩺 "+msg)
+			}
+			if notifyUser == "" {
+				log.Printf("[WARN] health notify skipped: no health_notify_user or trigger user")
+				continue
+			}
+			if err := c.sendAppNotification(notifyUser, msg); err != nil {
+				log.Printf("[WARN] send health notify failed user=%s err=%v", notifyUser, err)
+			}
+		}
+	}
+}
+
 func (c *Connection) notifyBuildFlutterAPKSuccess(rec *deployTaskRecord) {
 	if rec == nil || rec.Project != "build-flutter-apk" || rec.Status != deployTaskStatusDone {
 		return
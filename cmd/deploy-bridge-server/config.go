@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -17,6 +18,15 @@ type Config struct {
 
 	// 部署保护文件（deploy-agent 增量部署时跳过这些文件）
 	ProtectedFiles []string `json:"protected_files,omitempty"`
+
+	// 分片上传会话保留时间（小时），超时未完成的会话被清理
+	UploadSessionTTLHours int `json:"upload_session_ttl_hours,omitempty"`
+
+	// 受信任的发布包签名公钥（名称 → base64 Ed25519 公钥）
+	// 配置后所有部署都必须携带其中任一密钥的有效签名
+	TrustedKeys map[string]string `json:"trusted_keys,omitempty"`
+
+	trustedKeys map[string]ed25519.PublicKey // 解析后的 TrustedKeys
 }
 
 // DefaultConfig 默认配置
@@ -28,6 +38,8 @@ func DefaultConfig() *Config {
 		DeployTimeout:   120,
 		LogRetainCount:  50,
 
+		UploadSessionTTLHours: 24,
+
 		ProtectedFiles: []string{"bridge-server.json", "uploads/"},
 	}
 }
@@ -49,6 +61,12 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("auth_token is required (empty token is not allowed)")
 	}
 
+	keys, err := parseTrustedKeys(cfg.TrustedKeys)
+	if err != nil {
+		return nil, err
+	}
+	cfg.trustedKeys = keys
+
 	// 确保 upload_dir 存在
 	if err := os.MkdirAll(cfg.UploadDir, 0755); err != nil {
 		return nil, fmt.Errorf("create upload_dir: %v", err)
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -162,6 +164,37 @@ func (m *DeployManager) GetMD5(filename string) string {
 	return m.md5Cache[filename]
 }
 
+// errPackageExists 同名包已存在：已上传的包不可被替换，新内容需使用新文件名
+var errPackageExists = errors.New("同名包已存在")
+
+// StorePackage 将已校验的临时文件以 filename 存入 upload_dir。
+// 同名包已存在时拒绝，避免校验通过或正在部署的包被替换；新包不沿用残留的同名签名
+func (m *DeployManager) StorePackage(tmpPath, filename, md5sum string) error {
+	dstPath := filepath.Join(m.cfg.UploadDir, filename)
+	if err := os.Link(tmpPath, dstPath); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: %s", errPackageExists, filename)
+		}
+		return err
+	}
+	os.Remove(tmpPath)
+	os.Remove(dstPath + signatureSuffix)
+	m.CacheMD5(filename, md5sum)
+	return nil
+}
+
+// DeletePackage 删除已上传的包（及其签名）并清除 MD5 缓存
+func (m *DeployManager) DeletePackage(filename string) error {
+	if err := os.Remove(filepath.Join(m.cfg.UploadDir, filename)); err != nil {
+		return err
+	}
+	os.Remove(filepath.Join(m.cfg.UploadDir, filename+signatureSuffix))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.md5Cache, filename)
+	return nil
+}
+
 // CreateTask 创建部署任务
 func (m *DeployManager) CreateTask(filename, targetDir, script string, protectFiles, setupDirs []string, deployMode string) *DeployTask {
 	id := fmt.Sprintf("d_%d_%s", time.Now().Unix(), randStr(6))
@@ -256,13 +289,19 @@ func (m *DeployManager) RunDeploy(task *DeployTask) {
 		task.addLog("info", fmt.Sprintf("创建数据目录: %s", strings.Join(task.SetupDirs, ", ")))
 	}
 
-	// 2. 复制 zip 到目标目录
+	// 2. 复制 zip 到目标目录，签名对复制出的字节校验（解压的就是这份副本）
 	zipDst := filepath.Join(targetDir, task.Filename)
 	task.addLog("info", fmt.Sprintf("复制 %s → %s", task.Filename, targetDir))
-	if err := copyFileSimple(zipSrc, zipDst); err != nil {
+	digest, err := copyFileSHA256(zipSrc, zipDst)
+	if err != nil {
 		m.failTask(task, fmt.Sprintf("复制文件失败: %v", err))
 		return
 	}
+	if _, err := m.verifyPackageDigest(task.Filename, digest); err != nil {
+		os.Remove(zipDst)
+		m.failTask(task, fmt.Sprintf("签名校验失败: %v", err))
+		return
+	}
 
 	// 3. 解压（根据部署模式决定是否排除保护文件）
 	task.addLog("info", fmt.Sprintf("解压 %s...", task.Filename))
@@ -395,13 +434,26 @@ func (m *DeployManager) failTask(task *DeployTask, errMsg string) {
 	task.addLog("error", errMsg)
 }
 
-// copyFileSimple 简单文件复制
-func copyFileSimple(src, dst string) error {
-	data, err := os.ReadFile(src)
+// copyFileSHA256 复制文件并返回所复制内容的 SHA-256 摘要
+func copyFileSHA256(src, dst string) ([]byte, error) {
+	in, err := os.Open(src)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
 	}
-	return os.WriteFile(dst, data, 0644)
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
 }
 
 // randStr 生成随机字符串
@@ -435,6 +487,7 @@ func (m *DeployManager) ListPackages() []PackageInfo {
 			Size:    info.Size(),
 			ModTime: info.ModTime(),
 			MD5:     m.GetMD5(e.Name()),
+			Signed:  fileExists(filepath.Join(m.cfg.UploadDir, e.Name()+signatureSuffix)),
 		})
 	}
 
@@ -452,6 +505,12 @@ type PackageInfo struct {
 	Size    int64     `json:"size"`
 	ModTime time.Time `json:"mod_time"`
 	MD5     string    `json:"md5"`
+	Signed  bool      `json:"signed"`
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 // fileMD5 计算文件的 MD5 哈希
@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,14 +18,16 @@ import (
 type Handlers struct {
 	cfg     *Config
 	manager *DeployManager
+	uploads *UploadManager
 }
 
 // NewHandlers 创建处理器
 func NewHandlers(cfg *Config, manager *DeployManager) *Handlers {
-	return &Handlers{cfg: cfg, manager: manager}
+	return &Handlers{cfg: cfg, manager: manager, uploads: NewUploadManager(cfg, manager)}
 }
 
-// HandleUpload POST /api/upload — 上传 zip 包（MD5 去重）
+// HandleUpload POST /api/upload — 整包上传 zip（MD5 去重，可选 signature 字段）
+// 大包请使用 /api/upload/init 分片上传
 func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
@@ -60,8 +63,16 @@ func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	signature := r.FormValue("signature")
+	if signature != "" {
+		if _, err := decodeSignature(signature); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// 写入临时文件，同时计算 MD5
-	tmpPath := filepath.Join(h.cfg.UploadDir, filename+".tmp")
+	tmpPath := filepath.Join(h.cfg.UploadDir, filename+"."+randStr(6)+".tmp")
 	tmpFile, err := os.Create(tmpPath)
 	if err != nil {
 		jsonError(w, "创建文件失败", http.StatusInternalServerError)
@@ -82,6 +93,12 @@ func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	// 检查是否已有相同 MD5 的文件
 	if existing, found := h.manager.FindDuplicateByMD5(md5sum); found {
 		os.Remove(tmpPath)
+		if signature != "" {
+			if err := h.manager.SaveSignature(existing, signature); err != nil {
+				jsonError(w, "保存签名失败: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 		jsonResp(w, map[string]interface{}{
 			"filename": existing,
 			"size":     written,
@@ -91,15 +108,23 @@ func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 重命名为正式文件
-	dstPath := filepath.Join(h.cfg.UploadDir, filename)
-	if err := os.Rename(tmpPath, dstPath); err != nil {
+	// 存为正式文件（不覆盖同名包）
+	if err := h.manager.StorePackage(tmpPath, filename, md5sum); err != nil {
 		os.Remove(tmpPath)
+		if errors.Is(err, errPackageExists) {
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		}
 		jsonError(w, "保存文件失败", http.StatusInternalServerError)
 		return
 	}
 
-	h.manager.CacheMD5(filename, md5sum)
+	if signature != "" {
+		if err := h.manager.SaveSignature(filename, signature); err != nil {
+			jsonError(w, "保存签名失败: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	jsonResp(w, map[string]interface{}{
 		"filename": filename,
@@ -107,8 +132,25 @@ func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// HandlePackages GET /api/packages — 已上传包列表
+// HandlePackages GET /api/packages — 已上传包列表；DELETE /api/packages?name= — 删除包（deploy-agent 清理旧发布）
 func (h *Handlers) HandlePackages(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		name := filepath.Base(filepath.Clean(r.URL.Query().Get("name")))
+		if !strings.HasSuffix(strings.ToLower(name), ".zip") || strings.Contains(name, "..") {
+			jsonError(w, "非法文件名", http.StatusBadRequest)
+			return
+		}
+		if err := h.manager.DeletePackage(name); err != nil {
+			if os.IsNotExist(err) {
+				jsonError(w, "文件不存在: "+name, http.StatusNotFound)
+				return
+			}
+			jsonError(w, "删除文件失败", http.StatusInternalServerError)
+			return
+		}
+		jsonResp(w, map[string]interface{}{"deleted": name})
+		return
+	}
 	if r.Method != http.MethodGet {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
@@ -165,11 +207,21 @@ func (h *Handlers) HandleDeploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 签名校验（配置了 trusted_keys 时）。此处提前拒绝，RunDeploy 会对实际解压的副本再校验一次
+	keyName, err := h.manager.VerifyPackage(req.Filename)
+	if err != nil {
+		jsonError(w, "签名校验失败: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
 	if req.Script == "" {
 		req.Script = "publish.sh"
 	}
 
 	task := h.manager.CreateTask(req.Filename, req.TargetDir, req.Script, req.ProtectFiles, req.SetupDirs, req.DeployMode)
+	if keyName != "" {
+		task.addLog("info", fmt.Sprintf("签名校验通过: %s", keyName))
+	}
 	go h.manager.RunDeploy(task)
 
 	jsonResp(w, map[string]interface{}{
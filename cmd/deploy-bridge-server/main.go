@@ -53,6 +53,9 @@ func main() {
 	// API 路由（需要认证）
 	apiMux := http.NewServeMux()
 	apiMux.HandleFunc("/api/upload", handlers.HandleUpload)
+	apiMux.HandleFunc("/api/upload/init", handlers.HandleUploadInit)
+	// /api/upload/{id}/chunk/{index}、/api/upload/{id}/complete 通过前缀匹配
+	apiMux.HandleFunc("/api/upload/", handlers.HandleUploadSession)
 	apiMux.HandleFunc("/api/packages", handlers.HandlePackages)
 	apiMux.HandleFunc("/api/deploy", handlers.HandleDeploy)
 	apiMux.HandleFunc("/api/deploys", handlers.HandleDeploys)
@@ -76,6 +79,11 @@ func main() {
 	fmt.Printf("  upload_dir: %s\n", cfg.UploadDir)
 	fmt.Printf("  max_upload: %dMB\n", cfg.MaxUploadSizeMB)
 	fmt.Printf("  deploy_timeout: %ds\n", cfg.DeployTimeout)
+	if len(cfg.trustedKeys) > 0 {
+		fmt.Printf("  trusted_keys: %d（只部署签名包）\n", len(cfg.trustedKeys))
+	} else {
+		fmt.Printf("  trusted_keys: 未配置（不校验包签名）\n")
+	}
 
 	if err := http.ListenAndServe(cfg.Listen, mux); err != nil {
 		log.Fatalf("server: %v", err)
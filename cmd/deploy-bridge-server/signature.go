@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 签名文件与 zip 同目录保存：<package>.zip.sig（base64 编码的 Ed25519 签名）
+// 签名对象为 zip 文件的 SHA-256 摘要，与 deploy-agent pack 的签名方式一致
+const signatureSuffix = ".sig"
+
+// parseTrustedKeys 解析配置中的受信任公钥
+func parseTrustedKeys(keys map[string]string) (map[string]ed25519.PublicKey, error) {
+	out := make(map[string]ed25519.PublicKey, len(keys))
+	for name, encoded := range keys {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("trusted_keys[%s]: 不是有效的 base64 Ed25519 公钥", name)
+		}
+		out[name] = ed25519.PublicKey(raw)
+	}
+	return out, nil
+}
+
+// decodeSignature 校验签名格式，返回规范化的 base64 文本
+func decodeSignature(encoded string) (string, error) {
+	encoded = strings.TrimSpace(encoded)
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != ed25519.SignatureSize {
+		return "", fmt.Errorf("签名格式无效（需要 base64 编码的 Ed25519 签名）")
+	}
+	return encoded, nil
+}
+
+// SaveSignature 保存包的签名（相同内容的包去重时签名写到已有文件名上）。
+// 配置了 trusted_keys 时只保存能通过校验的签名；未配置时不覆盖已有签名
+func (m *DeployManager) SaveSignature(filename, signature string) error {
+	sig, err := decodeSignature(signature)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.cfg.UploadDir, filename+signatureSuffix)
+
+	if len(m.cfg.trustedKeys) == 0 {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(sig + "\n")
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+		return err
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(sig)
+	if _, err := m.verifySignature(filename, raw); err != nil {
+		return err
+	}
+	tmpPath := fmt.Sprintf("%s.%s.tmp", path, randStr(6))
+	if err := os.WriteFile(tmpPath, []byte(sig+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// VerifyPackage 部署前校验包签名，返回匹配的密钥名
+// 未配置 trusted_keys 时不校验，返回空字符串
+func (m *DeployManager) VerifyPackage(filename string) (string, error) {
+	if len(m.cfg.trustedKeys) == 0 {
+		return "", nil
+	}
+	digest, err := fileSHA256(filepath.Join(m.cfg.UploadDir, filename))
+	if err != nil {
+		return "", fmt.Errorf("计算 SHA-256 失败: %v", err)
+	}
+	return m.verifyPackageDigest(filename, digest)
+}
+
+// verifyPackageDigest 用包已保存的签名校验给定摘要，返回匹配的密钥名。
+// RunDeploy 对实际解压的副本计算摘要后调用，校验之后包被替换也不会部署未签名的内容
+func (m *DeployManager) verifyPackageDigest(filename string, digest []byte) (string, error) {
+	if len(m.cfg.trustedKeys) == 0 {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.cfg.UploadDir, filename+signatureSuffix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%s 未签名（bridge 已配置 trusted_keys，只部署签名包）", filename)
+		}
+		return "", fmt.Errorf("读取签名失败: %v", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "", fmt.Errorf("签名文件损坏: %v", err)
+	}
+	return m.verifyDigest(filename, digest, sig)
+}
+
+// verifySignature 用受信任公钥校验包签名，返回匹配的密钥名
+func (m *DeployManager) verifySignature(filename string, sig []byte) (string, error) {
+	digest, err := fileSHA256(filepath.Join(m.cfg.UploadDir, filename))
+	if err != nil {
+		return "", fmt.Errorf("计算 SHA-256 失败: %v", err)
+	}
+	return m.verifyDigest(filename, digest, sig)
+}
+
+// verifyDigest 按名称顺序尝试受信任公钥，结果稳定
+func (m *DeployManager) verifyDigest(filename string, digest, sig []byte) (string, error) {
+	names := make([]string, 0, len(m.cfg.trustedKeys))
+	for name := range m.cfg.trustedKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ed25519.Verify(m.cfg.trustedKeys[name], digest, sig) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%s 签名与任何受信任公钥都不匹配", filename)
+}
+
+// fileSHA256 计算文件的 SHA-256 摘要
+func fileSHA256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
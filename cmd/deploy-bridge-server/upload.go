@@ -0,0 +1,434 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	chunksDirName     = ".chunks"        // upload_dir 下的分片会话目录
+	defaultChunkSize  = 4 * 1024 * 1024  // 客户端未指定时的分片大小
+	minChunkSize      = 64 * 1024        // 分片大小下限
+	maxChunkSize      = 64 * 1024 * 1024 // 分片大小上限
+	chunkSHA256Header = "X-Chunk-SHA256" // 分片校验和请求头
+)
+
+var md5Pattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// UploadSession 分片上传会话
+// 会话 ID 由文件 MD5 和大小决定，同一文件重新 init 即可从已收到的分片继续；
+// 会话元数据与分片都落盘在 upload_dir/.chunks/<id>/，bridge 重启后仍可续传
+type UploadSession struct {
+	ID          string    `json:"upload_id"`
+	Filename    string    `json:"filename"`
+	Size        int64     `json:"size"`
+	MD5         string    `json:"md5"`
+	ChunkSize   int64     `json:"chunk_size"`
+	TotalChunks int       `json:"total_chunks"`
+	Signature   string    `json:"signature,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// chunkLen 返回第 index 个分片的应有长度
+func (s *UploadSession) chunkLen(index int) int64 {
+	if index == s.TotalChunks-1 {
+		return s.Size - int64(index)*s.ChunkSize
+	}
+	return s.ChunkSize
+}
+
+// UploadManager 分片上传会话管理
+type UploadManager struct {
+	cfg     *Config
+	manager *DeployManager
+	dir     string
+	mu      sync.Mutex // 保护会话创建 / 合并 / 清理
+}
+
+// NewUploadManager 创建分片上传管理器
+func NewUploadManager(cfg *Config, manager *DeployManager) *UploadManager {
+	return &UploadManager{
+		cfg:     cfg,
+		manager: manager,
+		dir:     filepath.Join(cfg.UploadDir, chunksDirName),
+	}
+}
+
+func (u *UploadManager) sessionDir(id string) string {
+	return filepath.Join(u.dir, id)
+}
+
+func (u *UploadManager) chunkPath(id string, index int) string {
+	return filepath.Join(u.sessionDir(id), fmt.Sprintf("%06d.part", index))
+}
+
+// loadSession 读取会话元数据
+func (u *UploadManager) loadSession(id string) (*UploadSession, error) {
+	if strings.ContainsAny(id, `/\.`) || id == "" {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(u.sessionDir(id), "session.json"))
+	if err != nil {
+		return nil, err
+	}
+	var s UploadSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (u *UploadManager) saveSession(s *UploadSession) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(u.sessionDir(s.ID), "session.json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// received 返回已收到的分片序号
+func (u *UploadManager) received(s *UploadSession) []int {
+	out := []int{}
+	for i := 0; i < s.TotalChunks; i++ {
+		if info, err := os.Stat(u.chunkPath(s.ID, i)); err == nil && info.Size() == s.chunkLen(i) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// purgeExpired 清理超过 upload_session_ttl_hours 未更新的会话（调用方持有 u.mu）
+func (u *UploadManager) purgeExpired() {
+	ttl := time.Duration(u.cfg.UploadSessionTTLHours) * time.Hour
+	if ttl <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		if time.Since(info.ModTime()) > ttl {
+			os.RemoveAll(filepath.Join(u.dir, e.Name()))
+		}
+	}
+}
+
+// initRequest POST /api/upload/init 请求体
+type initRequest struct {
+	Filename  string `json:"filename"`
+	Size      int64  `json:"size"`
+	MD5       string `json:"md5"`
+	ChunkSize int64  `json:"chunk_size"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Init 创建或恢复上传会话；服务端已有相同 MD5 的包时返回该包名（skipped）
+func (u *UploadManager) Init(req initRequest) (map[string]interface{}, error) {
+	filename, ok := safeZipName(req.Filename)
+	if !ok {
+		return nil, fmt.Errorf("非法文件名")
+	}
+	req.MD5 = strings.ToLower(req.MD5)
+	if !md5Pattern.MatchString(req.MD5) {
+		return nil, fmt.Errorf("md5 格式无效")
+	}
+	if req.Size <= 0 || req.Size > int64(u.cfg.MaxUploadSizeMB)*1024*1024 {
+		return nil, fmt.Errorf("文件大小无效或超过 %dMB", u.cfg.MaxUploadSizeMB)
+	}
+	if req.ChunkSize == 0 {
+		req.ChunkSize = defaultChunkSize
+	}
+	if req.ChunkSize < minChunkSize || req.ChunkSize > maxChunkSize {
+		return nil, fmt.Errorf("chunk_size 需在 %d ~ %d 之间", minChunkSize, maxChunkSize)
+	}
+	if req.Signature != "" {
+		sig, err := decodeSignature(req.Signature)
+		if err != nil {
+			return nil, err
+		}
+		req.Signature = sig
+	}
+
+	// 相同内容已存在：只补写签名
+	if existing, found := u.manager.FindDuplicateByMD5(req.MD5); found {
+		if req.Signature != "" {
+			if err := u.manager.SaveSignature(existing, req.Signature); err != nil {
+				return nil, fmt.Errorf("保存签名失败: %v", err)
+			}
+		}
+		return map[string]interface{}{
+			"filename": existing,
+			"size":     req.Size,
+			"skipped":  true,
+			"message":  "文件已存在（MD5相同）: " + existing,
+		}, nil
+	}
+
+	if fileExists(filepath.Join(u.cfg.UploadDir, filename)) {
+		return nil, fmt.Errorf("%w: %s，请使用新的文件名", errPackageExists, filename)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.purgeExpired()
+
+	id := fmt.Sprintf("u_%s_%d", req.MD5, req.Size)
+	s, err := u.loadSession(id)
+	if err != nil || s.ChunkSize != req.ChunkSize {
+		// 新会话，或分片大小变化导致已有分片不可复用
+		os.RemoveAll(u.sessionDir(id))
+		s = &UploadSession{
+			ID:          id,
+			Size:        req.Size,
+			MD5:         req.MD5,
+			ChunkSize:   req.ChunkSize,
+			TotalChunks: int((req.Size + req.ChunkSize - 1) / req.ChunkSize),
+			CreatedAt:   time.Now(),
+		}
+	}
+	s.Filename = filename
+	if req.Signature != "" {
+		s.Signature = req.Signature
+	}
+	if err := os.MkdirAll(u.sessionDir(id), 0755); err != nil {
+		return nil, fmt.Errorf("创建会话目录失败: %v", err)
+	}
+	if err := u.saveSession(s); err != nil {
+		return nil, fmt.Errorf("保存会话失败: %v", err)
+	}
+	return u.status(s), nil
+}
+
+func (u *UploadManager) status(s *UploadSession) map[string]interface{} {
+	return map[string]interface{}{
+		"upload_id":    s.ID,
+		"filename":     s.Filename,
+		"size":         s.Size,
+		"chunk_size":   s.ChunkSize,
+		"total_chunks": s.TotalChunks,
+		"received":     u.received(s),
+	}
+}
+
+// PutChunk 写入一个分片：校验长度与 SHA-256 后原子落盘，重复上传同一分片会覆盖
+func (u *UploadManager) PutChunk(id string, index int, checksum string, body io.Reader) error {
+	s, err := u.loadSession(id)
+	if err != nil {
+		return os.ErrNotExist
+	}
+	if index < 0 || index >= s.TotalChunks {
+		return fmt.Errorf("分片序号 %d 超出范围 [0, %d)", index, s.TotalChunks)
+	}
+	if checksum == "" {
+		return fmt.Errorf("缺少 %s 请求头", chunkSHA256Header)
+	}
+
+	want := s.chunkLen(index)
+	tmpPath := fmt.Sprintf("%s.%s.tmp", u.chunkPath(id, index), randStr(6))
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("创建分片失败: %v", err)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(body, want+1))
+	f.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("接收分片失败: %v", err)
+	}
+	if n != want {
+		os.Remove(tmpPath)
+		return fmt.Errorf("分片 %d 长度 %d，应为 %d", index, n, want)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(got, checksum) {
+		os.Remove(tmpPath)
+		return fmt.Errorf("分片 %d 校验和不匹配", index)
+	}
+	return os.Rename(tmpPath, u.chunkPath(id, index))
+}
+
+// Complete 按序合并分片，校验整包 MD5 后移入 upload_dir（不覆盖同名包）
+func (u *UploadManager) Complete(id string) (map[string]interface{}, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	s, err := u.loadSession(id)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	if got := u.received(s); len(got) != s.TotalChunks {
+		return nil, fmt.Errorf("分片未传完: %d/%d", len(got), s.TotalChunks)
+	}
+
+	// 临时文件名唯一，避免与同名的整包上传或其他会话互相覆盖
+	tmpPath := filepath.Join(u.cfg.UploadDir, s.Filename+"."+randStr(6)+".tmp")
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("创建文件失败: %v", err)
+	}
+	hash := md5.New()
+	w := io.MultiWriter(out, hash)
+	for i := 0; i < s.TotalChunks; i++ {
+		if err := appendFile(w, u.chunkPath(id, i)); err != nil {
+			out.Close()
+			os.Remove(tmpPath)
+			return nil, fmt.Errorf("合并分片 %d 失败: %v", i, err)
+		}
+	}
+	out.Close()
+
+	md5sum := hex.EncodeToString(hash.Sum(nil))
+	if md5sum != s.MD5 {
+		// 分片均已通过校验仍不一致，说明客户端声明有误，整个会话作废
+		os.Remove(tmpPath)
+		os.RemoveAll(u.sessionDir(id))
+		return nil, fmt.Errorf("合并后 MD5 %s 与声明的 %s 不一致，请重新上传", md5sum, s.MD5)
+	}
+
+	filename := s.Filename
+	skipped := false
+	if existing, found := u.manager.FindDuplicateByMD5(md5sum); found {
+		os.Remove(tmpPath)
+		filename, skipped = existing, true
+	} else if err := u.manager.StorePackage(tmpPath, filename, md5sum); err != nil {
+		// 会话保留，换一个文件名重新 init 即可直接完成
+		os.Remove(tmpPath)
+		if errors.Is(err, errPackageExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("保存文件失败: %v", err)
+	}
+	os.RemoveAll(u.sessionDir(id))
+	if s.Signature != "" {
+		if err := u.manager.SaveSignature(filename, s.Signature); err != nil {
+			return nil, fmt.Errorf("保存签名失败: %v", err)
+		}
+	}
+
+	return map[string]interface{}{
+		"filename": filename,
+		"size":     s.Size,
+		"md5":      md5sum,
+		"skipped":  skipped,
+	}, nil
+}
+
+func appendFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// safeZipName 清理路径穿越，只接受 .zip
+func safeZipName(name string) (string, bool) {
+	filename := filepath.Base(filepath.Clean(name))
+	if !strings.HasSuffix(strings.ToLower(filename), ".zip") || strings.Contains(filename, "..") {
+		return "", false
+	}
+	return filename, true
+}
+
+// HandleUploadInit POST /api/upload/init — 创建或恢复分片上传会话
+func (h *Handlers) HandleUploadInit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	var req initRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "无效的请求体", http.StatusBadRequest)
+		return
+	}
+	resp, err := h.uploads.Init(req)
+	if err != nil {
+		if errors.Is(err, errPackageExists) {
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonResp(w, resp)
+}
+
+// HandleUploadSession /api/upload/{id}[/chunk/{index} | /complete]
+//
+//	GET  /api/upload/{id}               — 会话状态（已收到的分片）
+//	PUT  /api/upload/{id}/chunk/{index} — 上传分片（X-Chunk-SHA256 为分片的 SHA-256）
+//	POST /api/upload/{id}/complete      — 合并分片
+func (h *Handlers) HandleUploadSession(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/upload/"), "/"), "/")
+	id := parts[0]
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s, err := h.uploads.loadSession(id)
+		if err != nil {
+			jsonError(w, "上传会话不存在或已过期", http.StatusNotFound)
+			return
+		}
+		jsonResp(w, h.uploads.status(s))
+
+	case len(parts) == 3 && parts[1] == "chunk" && r.Method == http.MethodPut:
+		index, err := strconv.Atoi(parts[2])
+		if err != nil {
+			jsonError(w, "无效的分片序号", http.StatusBadRequest)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxChunkSize+1)
+		if err := h.uploads.PutChunk(id, index, r.Header.Get(chunkSHA256Header), r.Body); err != nil {
+			if os.IsNotExist(err) {
+				jsonError(w, "上传会话不存在或已过期", http.StatusNotFound)
+				return
+			}
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonResp(w, map[string]interface{}{"upload_id": id, "index": index})
+
+	case len(parts) == 2 && parts[1] == "complete" && r.Method == http.MethodPost:
+		resp, err := h.uploads.Complete(id)
+		if err != nil {
+			if os.IsNotExist(err) {
+				jsonError(w, "上传会话不存在或已过期", http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, errPackageExists) {
+				jsonError(w, err.Error(), http.StatusConflict)
+				return
+			}
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonResp(w, resp)
+
+	default:
+		jsonError(w, "无效的路径", http.StatusNotFound)
+	}
+}
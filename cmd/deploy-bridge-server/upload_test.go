@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestHandlers(t *testing.T, trusted map[string]string) *Handlers {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.UploadDir = t.TempDir()
+	cfg.TrustedKeys = trusted
+	keys, err := parseTrustedKeys(trusted)
+	if err != nil {
+		t.Fatalf("parseTrustedKeys error: %v", err)
+	}
+	cfg.trustedKeys = keys
+	return NewHandlers(cfg, NewDeployManager(cfg))
+}
+
+func doJSON(t *testing.T, handler http.HandlerFunc, method, path string, body []byte, header map[string]string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	var out map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec.Code, out
+}
+
+func TestChunkedUploadResumesAndVerifiesChecksums(t *testing.T) {
+	h := newTestHandlers(t, nil)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10000) // 160000 字节 → 3 个 64KB 分片
+	sum := md5.Sum(data)
+	initBody, _ := json.Marshal(initRequest{Filename: "app_v1.zip", Size: int64(len(data)), MD5: hex.EncodeToString(sum[:]), ChunkSize: minChunkSize})
+
+	code, session := doJSON(t, h.HandleUploadInit, http.MethodPost, "/api/upload/init", initBody, nil)
+	if code != http.StatusOK || session["total_chunks"].(float64) != 3 {
+		t.Fatalf("init failed: %d %v", code, session)
+	}
+	id := session["upload_id"].(string)
+
+	putChunk := func(index int, checksum string) int {
+		chunk := data[index*minChunkSize : min((index+1)*minChunkSize, len(data))]
+		if checksum == "" {
+			s := sha256.Sum256(chunk)
+			checksum = hex.EncodeToString(s[:])
+		}
+		code, _ := doJSON(t, h.HandleUploadSession, http.MethodPut, fmt.Sprintf("/api/upload/%s/chunk/%d", id, index), chunk,
+			map[string]string{chunkSHA256Header: checksum})
+		return code
+	}
+
+	if code := putChunk(2, strings.Repeat("0", 64)); code != http.StatusBadRequest {
+		t.Fatalf("corrupted chunk should be rejected, got %d", code)
+	}
+	if code := putChunk(2, ""); code != http.StatusOK {
+		t.Fatalf("put chunk 2: %d", code)
+	}
+	if code, _ := doJSON(t, h.HandleUploadSession, http.MethodPost, "/api/upload/"+id+"/complete", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("complete with missing chunks should fail, got %d", code)
+	}
+
+	// 断线后重新 init，得到同一会话和已收到的分片
+	_, resumed := doJSON(t, h.HandleUploadInit, http.MethodPost, "/api/upload/init", initBody, nil)
+	if resumed["upload_id"] != id || fmt.Sprint(resumed["received"]) != "[2]" {
+		t.Fatalf("re-init should resume the session: %v", resumed)
+	}
+	for _, i := range []int{0, 1} {
+		if code := putChunk(i, ""); code != http.StatusOK {
+			t.Fatalf("put chunk %d: %d", i, code)
+		}
+	}
+
+	code, done := doJSON(t, h.HandleUploadSession, http.MethodPost, "/api/upload/"+id+"/complete", nil, nil)
+	if code != http.StatusOK || done["filename"] != "app_v1.zip" {
+		t.Fatalf("complete failed: %d %v", code, done)
+	}
+	assembled, err := os.ReadFile(filepath.Join(h.cfg.UploadDir, "app_v1.zip"))
+	if err != nil || !bytes.Equal(assembled, data) {
+		t.Fatalf("assembled package mismatch: %v", err)
+	}
+	if _, err := os.Stat(h.uploads.sessionDir(id)); !os.IsNotExist(err) {
+		t.Fatalf("session dir should be removed after complete")
+	}
+
+	// 相同内容再次上传直接复用
+	initBody, _ = json.Marshal(initRequest{Filename: "app_v2.zip", Size: int64(len(data)), MD5: hex.EncodeToString(sum[:])})
+	if _, dup := doJSON(t, h.HandleUploadInit, http.MethodPost, "/api/upload/init", initBody, nil); dup["skipped"] != true || dup["filename"] != "app_v1.zip" {
+		t.Fatalf("duplicate content should be skipped: %v", dup)
+	}
+}
+
+func TestDeployRequiresTrustedSignature(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	h := newTestHandlers(t, map[string]string{"ci": base64.StdEncoding.EncodeToString(pub)})
+
+	pkg := []byte("PK fake zip")
+	if err := os.WriteFile(filepath.Join(h.cfg.UploadDir, "app.zip"), pkg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(pkg)
+	deployBody := []byte(`{"filename":"app.zip","target_dir":"` + filepath.ToSlash(t.TempDir()) + `","script":"none.sh"}`)
+
+	if code, resp := doJSON(t, h.HandleDeploy, http.MethodPost, "/api/deploy", deployBody, nil); code != http.StatusForbidden {
+		t.Fatalf("unsigned package should be rejected: %d %v", code, resp)
+	}
+
+	untrusted := base64.StdEncoding.EncodeToString(ed25519.Sign(otherPriv, digest[:]))
+	if err := h.manager.SaveSignature("app.zip", untrusted); err == nil {
+		t.Fatal("signature from an untrusted key should not be saved")
+	}
+	if _, err := os.Stat(filepath.Join(h.cfg.UploadDir, "app.zip"+signatureSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("rejected signature should not be written")
+	}
+	// 目录中已有的不可信签名文件同样拒绝部署
+	if err := os.WriteFile(filepath.Join(h.cfg.UploadDir, "app.zip"+signatureSuffix), []byte(untrusted+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := doJSON(t, h.HandleDeploy, http.MethodPost, "/api/deploy", deployBody, nil); code != http.StatusForbidden {
+		t.Fatalf("package signed by an untrusted key should be rejected: %d %v", code, resp)
+	}
+
+	if err := h.manager.SaveSignature("app.zip", base64.StdEncoding.EncodeToString(ed25519.Sign(priv, digest[:]))); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := h.manager.VerifyPackage("app.zip"); err != nil || key != "ci" {
+		t.Fatalf("trusted signature should verify: %q %v", key, err)
+	}
+
+	if err := h.manager.DeletePackage("app.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(h.cfg.UploadDir, "app.zip"+signatureSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("signature should be deleted with the package")
+	}
+}
+
+func TestDuplicateUploadDoesNotReplaceSignature(t *testing.T) {
+	h := newTestHandlers(t, nil)
+	pkg := []byte("PK fake zip")
+	if err := os.WriteFile(filepath.Join(h.cfg.UploadDir, "app.zip"), pkg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, priv, _ := ed25519.GenerateKey(nil)
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	digest := sha256.Sum256(pkg)
+	first := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, digest[:]))
+	second := base64.StdEncoding.EncodeToString(ed25519.Sign(otherPriv, digest[:]))
+
+	sum := md5.Sum(pkg)
+	h.manager.CacheMD5("app.zip", hex.EncodeToString(sum[:]))
+	for _, sig := range []string{first, second} {
+		body, _ := json.Marshal(initRequest{Filename: "other.zip", Size: int64(len(pkg)), MD5: hex.EncodeToString(sum[:]), Signature: sig})
+		if code, resp := doJSON(t, h.HandleUploadInit, http.MethodPost, "/api/upload/init", body, nil); code != http.StatusOK || resp["skipped"] != true {
+			t.Fatalf("duplicate init failed: %d %v", code, resp)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(h.cfg.UploadDir, "app.zip"+signatureSuffix))
+	if err != nil || strings.TrimSpace(string(data)) != first {
+		t.Fatalf("existing signature should be kept, got %q err=%v", data, err)
+	}
+}
+
+func uploadWhole(t *testing.T, h *Handlers, filename string, data []byte) (int, map[string]interface{}) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	return doJSON(t, h.HandleUpload, http.MethodPost, "/api/upload", body.Bytes(), map[string]string{"Content-Type": mw.FormDataContentType()})
+}
+
+func TestUploadDoesNotReplaceExistingPackage(t *testing.T) {
+	h := newTestHandlers(t, nil)
+	zipPath := filepath.Join(h.cfg.UploadDir, "app.zip")
+
+	// 残留的同名签名不能沿用到新包上
+	if err := os.WriteFile(zipPath+signatureSuffix, []byte("stale\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := uploadWhole(t, h, "app.zip", []byte("PK v1")); code != http.StatusOK {
+		t.Fatalf("first upload failed: %d %v", code, resp)
+	}
+	if _, err := os.Stat(zipPath + signatureSuffix); !os.IsNotExist(err) {
+		t.Fatalf("stale signature should be removed when the package is stored")
+	}
+
+	if code, resp := uploadWhole(t, h, "app.zip", []byte("PK v2")); code != http.StatusConflict {
+		t.Fatalf("upload under an existing name should be rejected: %d %v", code, resp)
+	}
+	v2 := []byte("PK v2")
+	sum := md5.Sum(v2)
+	initBody, _ := json.Marshal(initRequest{Filename: "app.zip", Size: int64(len(v2)), MD5: hex.EncodeToString(sum[:])})
+	if code, resp := doJSON(t, h.HandleUploadInit, http.MethodPost, "/api/upload/init", initBody, nil); code != http.StatusConflict {
+		t.Fatalf("chunked upload under an existing name should be rejected: %d %v", code, resp)
+	}
+	if data, _ := os.ReadFile(zipPath); string(data) != "PK v1" {
+		t.Fatalf("existing package should be kept, got %q", data)
+	}
+}
+
+func TestRunDeployVerifiesTheCopiedPackage(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	h := newTestHandlers(t, map[string]string{"ci": base64.StdEncoding.EncodeToString(pub)})
+
+	pkg := []byte("PK signed zip")
+	zipPath := filepath.Join(h.cfg.UploadDir, "app.zip")
+	if err := os.WriteFile(zipPath, pkg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(pkg)
+	if err := h.manager.SaveSignature("app.zip", base64.StdEncoding.EncodeToString(ed25519.Sign(priv, digest[:]))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.manager.VerifyPackage("app.zip"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 校验通过后、部署开始前包被替换
+	if err := os.WriteFile(zipPath, []byte("PK tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	targetDir := t.TempDir()
+	task := h.manager.CreateTask("app.zip", targetDir, "", nil, nil, "full")
+	h.manager.RunDeploy(task)
+
+	if task.Status != "error" || !strings.Contains(task.Error, "签名校验失败") {
+		t.Fatalf("replaced package should fail verification: status=%s err=%s", task.Status, task.Error)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "app.zip")); !os.IsNotExist(err) {
+		t.Fatalf("unverified copy should be removed from the target")
+	}
+}
@@ -11,6 +11,22 @@ import (
 	"strings"
 )
 
+// SandboxConfig Python 子进程隔离配置
+type SandboxConfig struct {
+	Mode          string   `json:"mode"`             // "linux"（默认）：需沙箱可用，否则拒绝启动 | "none"：显式关闭隔离（非 Linux 平台必须配置）
+	AllowNetwork  bool     `json:"allow_network"`    // 默认 false：独立 network namespace，仅有未启用的 lo
+	MemoryMB      int      `json:"memory_mb"`        // 内存上限，默认 512
+	CPUPercent    int      `json:"cpu_percent"`      // cgroup cpu.max 配额（100 = 1 核），默认 100
+	CPUTimeSec    int      `json:"cpu_time_sec"`     // CPU 时间上限（RLIMIT_CPU），默认等于 max_exec_time_sec
+	MaxPids       int      `json:"max_pids"`         // 进程/线程数上限，默认 64（cgroup 不可用时退化为按宿主 UID 计数的 RLIMIT_NPROC）
+	TmpfsSizeMB   int      `json:"tmpfs_size_mb"`    // 私有工作目录 /tmp 大小，默认 64
+	MaxFileSizeMB int      `json:"max_file_size_mb"` // 单文件写入上限（RLIMIT_FSIZE），默认 16
+	CgroupParent  string   `json:"cgroup_parent"`    // 已委派的 cgroup v2 目录，为空或不可写时退化为 rlimit
+	HidePaths     []string `json:"hide_paths"`       // 沙箱内以空目录遮蔽的路径（配置文件所在目录自动加入）
+	UID           int      `json:"uid"`              // 映射到的宿主 uid，-1 自动（root 运行时为 65534，否则为当前用户）
+	GID           int      `json:"gid"`              // 映射到的宿主 gid，-1 自动
+}
+
 // Config execute-code-agent 配置
 type Config struct {
 	ServerURL        string `json:"server_url"`   // ws://127.0.0.1:10086/ws/uap
@@ -23,6 +39,19 @@ type Config struct {
 	MaxExecTimeSec   int    `json:"max_exec_time_sec"`   // 默认 120
 	MaxOutputSize    int    `json:"max_output_size"`     // 默认 50000 字符
 
+	// 其他语言的解释器/工具链（未安装时该语言不可用）
+	NodePath  string `json:"node_path"`  // 默认 "node"
+	ShellPath string `json:"shell_path"` // 默认 "bash"
+	GoPath    string `json:"go_path"`    // 默认 "go"
+
+	// 持久会话内核（python/javascript）
+	SessionIdleSec int `json:"session_idle_sec"` // 空闲超时回收，默认 600
+	MaxSessions    int `json:"max_sessions"`     // 同时存活的内核上限，默认 8
+
+	// 透传给代码子进程的环境变量（其余宿主环境变量不传递）
+	EnvAllowlist []string      `json:"env_allowlist"`
+	Sandbox      SandboxConfig `json:"sandbox"`
+
 	// 部署保护文件（deploy-agent 增量部署时跳过这些文件）
 	ProtectedFiles []string `json:"protected_files,omitempty"`
 }
@@ -39,6 +68,26 @@ func DefaultConfig() *Config {
 		MaxExecTimeSec:   120,
 		MaxOutputSize:    50000,
 
+		NodePath:       "node",
+		ShellPath:      "bash",
+		GoPath:         "go",
+		SessionIdleSec: 600,
+		MaxSessions:    8,
+
+		EnvAllowlist: defaultEnvAllowlist(),
+		Sandbox: SandboxConfig{
+			Mode:          SandboxModeLinux,
+			MemoryMB:      512,
+			CPUPercent:    100,
+			MaxPids:       64,
+			TmpfsSizeMB:   64,
+			MaxFileSizeMB: 16,
+			CgroupParent:  "/sys/fs/cgroup/execute-code-agent",
+			HidePaths:     []string{"/root", "/home"},
+			UID:           -1,
+			GID:           -1,
+		},
+
 		ProtectedFiles: []string{"execute-code-agent.json"},
 	}
 }
@@ -77,10 +126,60 @@ func LoadConfig(path string) *Config {
 	if cfg.MaxOutputSize <= 0 {
 		cfg.MaxOutputSize = 50000
 	}
+	if cfg.NodePath == "" {
+		cfg.NodePath = "node"
+	}
+	if cfg.ShellPath == "" {
+		cfg.ShellPath = "bash"
+	}
+	if cfg.GoPath == "" {
+		cfg.GoPath = "go"
+	}
+	if cfg.SessionIdleSec <= 0 {
+		cfg.SessionIdleSec = 600
+	}
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = 8
+	}
+	if cfg.EnvAllowlist == nil {
+		cfg.EnvAllowlist = defaultEnvAllowlist()
+	}
+	sb := &cfg.Sandbox
+	switch sb.Mode {
+	case SandboxModeLinux, SandboxModeNone:
+	case "":
+		sb.Mode = SandboxModeLinux
+	default:
+		log.Printf("[Config] 无效的 sandbox.mode %q，使用 linux（非 Linux 平台需显式配置 none）", sb.Mode)
+		sb.Mode = SandboxModeLinux
+	}
+	if sb.MemoryMB <= 0 {
+		sb.MemoryMB = 512
+	}
+	if sb.CPUPercent <= 0 {
+		sb.CPUPercent = 100
+	}
+	if sb.CPUTimeSec <= 0 {
+		sb.CPUTimeSec = cfg.MaxExecTimeSec
+	}
+	if sb.MaxPids <= 0 {
+		sb.MaxPids = 64
+	}
+	if sb.TmpfsSizeMB <= 0 {
+		sb.TmpfsSizeMB = 64
+	}
+	if sb.MaxFileSizeMB <= 0 {
+		sb.MaxFileSizeMB = 16
+	}
 
 	return cfg
 }
 
+// defaultEnvAllowlist 默认透传的环境变量（Windows 下 Python 启动需要 SYSTEMROOT）
+func defaultEnvAllowlist() []string {
+	return []string{"PATH", "LANG", "LC_ALL", "LC_CTYPE", "TZ", "SYSTEMROOT", "WINDIR", "TEMP", "TMP"}
+}
+
 // detectPython 自动检测可用的 Python 命令
 func detectPython() string {
 	// Windows 上优先 python，Linux/macOS 优先 python3
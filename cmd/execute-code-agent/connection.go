@@ -44,6 +44,7 @@ func NewConnection(cfg *Config, agentID string, pyVersion string) *Connection {
 			"python_version":  pyVersion,
 			"max_exec_time":   cfg.MaxExecTimeSec,
 			"max_output_size": cfg.MaxOutputSize,
+			"languages":       AvailableLanguages(cfg),
 		},
 	}
 
@@ -77,13 +78,30 @@ func buildToolDefs(ftk *agentbase.FileToolKit) []uap.ToolDef {
 	tools := []uap.ToolDef{
 		{
 			Name:        "ExecuteCode",
-			Description: "在 Python 沙箱中执行代码。代码可通过 call_tool(name, args) 调用其他 MCP 工具。只有 print() 的输出会返回。用于：多工具编排、数据过滤/转换/聚合、循环批量操作。safe_call_tool(name, args, default) 失败时返回 default 而不抛异常。",
+			Description: "在沙箱中执行代码（language: python 默认 / javascript / shell / go）。代码可通过 call_tool(name, args) 调用其他 MCP 工具（javascript: callTool，go: CallTool(name, map[string]any)，shell: call_tool name 'json' 输出结果 JSON）。只有标准输出会返回。用于：多工具编排、数据过滤/转换/聚合、循环批量操作。safe_call_tool(name, args, default) 失败时返回 default 而不抛异常。python/javascript 可设 persistent=true 在当前任务的持久会话中执行，变量跨调用保留。",
 			Parameters: mustMarshalJSON(map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"code": map[string]interface{}{
 						"type":        "string",
-						"description": "Python 代码（可使用 call_tool/safe_call_tool 调用 MCP 工具）",
+						"description": "代码（可使用 call_tool/safe_call_tool 调用 MCP 工具）；go 需为完整的 package main 程序",
+					},
+					"language": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{LangPython, LangJavaScript, LangShell, LangGo},
+						"description": "代码语言，默认 python",
+					},
+					"persistent": map[string]interface{}{
+						"type":        "boolean",
+						"description": "在持久会话内核中执行（仅 python/javascript），变量/函数/导入跨调用保留；会话空闲超时后回收",
+					},
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "持久会话 ID（可选，persistent=true 时默认由调用方填充为当前任务 ID）；会话归创建它的用户所有，其他用户不能复用",
+					},
+					"reset_session": map[string]interface{}{
+						"type":        "boolean",
+						"description": "执行前重启持久会话，清空之前的变量",
 					},
 					"description": map[string]interface{}{
 						"type":        "string",
@@ -117,16 +135,13 @@ func (c *Connection) handleToolResult(msg *uap.Message) {
 		log.Printf("[Connection] invalid tool_result payload: %v", err)
 		return
 	}
-	// 尝试分发到 RemoteCaller
-	if c.remoteCaller.DispatchToolResult(&payload) {
-		return
-	}
-	log.Printf("[Connection] tool_result requestID=%s has no pending channel (from=%s success=%v)",
+	// RemoteCaller 发起的调用结果已由 uap.Client 直接关联，这里只会收到无人等待的回复
+	log.Printf("[Connection] tool_result requestID=%s has no pending call (from=%s success=%v)",
 		payload.RequestID, msg.From, payload.Success)
 }
 
 // handleError 处理 gateway 错误消息（如 agent_offline）
-// gateway 使用原始 msg.ID 作为错误消息的 ID，用于匹配 pending 请求
+// 与 RemoteCaller 请求关联的错误由 uap.Client 直接投递给调用方，这里仅记录其余错误
 func (c *Connection) handleError(msg *uap.Message) {
 	var payload uap.ErrorPayload
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
@@ -135,9 +150,6 @@ func (c *Connection) handleError(msg *uap.Message) {
 	}
 
 	log.Printf("[Connection] error from=%s code=%s msg=%s (id=%s)", msg.From, payload.Code, payload.Message, msg.ID)
-
-	// 尝试分发到 RemoteCaller，让工具调用快速失败而非等待超时
-	c.remoteCaller.DispatchError(msg.ID, payload.Message)
 }
 
 // handleToolCall 处理 ExecuteCode / ExecEnvBash 工具调用
@@ -186,9 +198,13 @@ func (c *Connection) handleToolCall(msg *uap.Message) {
 
 	// 解析参数
 	var args struct {
-		Code        string   `json:"code"`
-		Description string   `json:"description"`
-		ToolsHint   []string `json:"tools_hint"`
+		Code         string   `json:"code"`
+		Language     string   `json:"language"`
+		Persistent   bool     `json:"persistent"`
+		SessionID    string   `json:"session_id"`
+		ResetSession bool     `json:"reset_session"`
+		Description  string   `json:"description"`
+		ToolsHint    []string `json:"tools_hint"`
 	}
 	if err := json.Unmarshal(payload.Arguments, &args); err != nil {
 		c.Client.SendTo(msg.From, uap.MsgToolResult, uap.ToolResultPayload{
@@ -208,14 +224,30 @@ func (c *Connection) handleToolCall(msg *uap.Message) {
 		return
 	}
 
+	if args.Persistent && args.SessionID == "" {
+		c.Client.SendTo(msg.From, uap.MsgToolResult, uap.ToolResultPayload{
+			RequestID: msg.ID,
+			Success:   false,
+			Error:     "persistent=true requires session_id",
+		})
+		return
+	}
+
 	desc := args.Description
 	if desc == "" {
 		desc = "(no description)"
 	}
-	log.Printf("[ExecuteCode] from=%s desc=%q code_len=%d", msg.From, desc, len(args.Code))
+	log.Printf("[ExecuteCode] from=%s lang=%s session=%q desc=%q code_len=%d",
+		msg.From, args.Language, args.SessionID, desc, len(args.Code))
 
 	// 执行代码
-	execResult := c.executor.Execute(args.Code)
+	execResult := c.executor.Run(ExecRequest{
+		Language:  args.Language,
+		Code:      args.Code,
+		SessionID: args.SessionID,
+		Reset:     args.ResetSession,
+		Owner:     sessionOwner(payload.AuthenticatedUser, msg.From),
+	})
 
 	log.Printf("[ExecuteCode] done success=%v exit_code=%d duration=%dms tool_calls=%d truncated=%v",
 		execResult.Success, execResult.ExitCode, execResult.DurationMs, len(execResult.ToolCalls), execResult.Truncated)
@@ -245,6 +277,11 @@ func (c *Connection) handleToolCall(msg *uap.Message) {
 	execData := map[string]interface{}{
 		"stdout":      stdout,
 		"duration_ms": execResult.DurationMs,
+		"language":    execResult.Language,
+	}
+	if execResult.SessionID != "" {
+		execData["session_id"] = execResult.SessionID
+		execData["session_new"] = execResult.SessionNew
 	}
 	if len(execResult.ToolCalls) > 0 {
 		execData["tool_calls"] = execResult.ToolCalls
@@ -278,9 +315,22 @@ func conditionalError(r *ExecutionResult) string {
 	}
 	switch r.ErrorType {
 	case "syntax":
+		if r.Language != "" && r.Language != LangPython {
+			return r.Language + " syntax error"
+		}
 		return "Python syntax error"
 	case "timeout":
 		return fmt.Sprintf("execution timeout (%ds)", r.DurationMs/1000)
+	case ErrTypeMemoryLimit:
+		return "memory limit exceeded"
+	case ErrTypePidsLimit:
+		return "process limit exceeded (too many processes/threads)"
+	case ErrTypeCPULimit:
+		return "cpu time limit exceeded"
+	case ErrTypeSeccomp:
+		return "forbidden system call blocked by sandbox"
+	case ErrTypeSandbox:
+		return "sandbox setup failed: " + strings.TrimSpace(r.Stderr)
 	case "runtime":
 		// 提取最后一行 stderr 作为简洁错误
 		trimmed := strings.TrimSpace(r.Stderr)
@@ -316,3 +366,11 @@ func (c *Connection) StartRefreshLoop() {
 func (c *Connection) remoteCallToolBridge(toolName string, args json.RawMessage) (string, string, error) {
 	return c.remoteCaller.CallToolWithRetry(toolName, args, 120*time.Second)
 }
+
+// sessionOwner 会话内核的所有者：优先使用已认证用户，其次是消息来源 agent
+func sessionOwner(authenticatedUser, from string) string {
+	if u := strings.TrimSpace(authenticatedUser); u != "" {
+		return "user:" + u
+	}
+	return "agent:" + from
+}
@@ -63,92 +63,146 @@ def safe_call_tool(tool_name, arguments=None, default=None):
 const toolCallPrefix = "__TOOL_CALL__"
 const toolCallSuffix = "__END__"
 
-// Executor Python 沙箱执行引擎
+// Executor 代码沙箱执行引擎
 type Executor struct {
 	cfg      *Config
 	callTool func(toolName string, args json.RawMessage) (result string, agentID string, err error)
+
+	sandbox    *sandboxRunner // sandbox.mode=linux 时启用
+	sandboxErr error          // 沙箱初始化失败时拒绝执行，不退化为无隔离
+	kernels    *KernelManager // 持久会话内核
+}
+
+// ExecRequest 一次代码执行请求
+type ExecRequest struct {
+	Language  string // python（默认）/ javascript / shell / go
+	Code      string
+	SessionID string // 非空时在该会话的持久内核中执行，变量跨调用保留
+	Reset     bool   // 执行前重启会话内核
+	Owner     string // 调用方身份（authenticated_user，缺省为消息来源 agent），会话内核只允许所有者使用
 }
 
 // NewExecutor 创建执行器
 func NewExecutor(cfg *Config) *Executor {
-	return &Executor{cfg: cfg}
+	e := &Executor{cfg: cfg}
+	if cfg.Sandbox.Mode == SandboxModeLinux {
+		e.sandbox, e.sandboxErr = newSandboxRunner(cfg)
+		if e.sandboxErr != nil {
+			log.Printf("[Executor] sandbox init failed: %v", e.sandboxErr)
+		}
+	}
+	e.kernels = NewKernelManager(e)
+	return e
 }
 
-// Execute 在 Python 沙箱中执行代码
+// Execute 一次性执行 Python 代码
 func (e *Executor) Execute(code string) *ExecutionResult {
-	result := &ExecutionResult{ToolCalls: []ToolCallRecord{}}
-	start := time.Now()
+	return e.Run(ExecRequest{Language: LangPython, Code: code})
+}
+
+// Run 按语言与会话执行代码
+func (e *Executor) Run(req ExecRequest) *ExecutionResult {
+	rt, err := lookupRuntime(req.Language)
+	if err != nil {
+		return failedResult(req.Language, "runtime", err.Error(), time.Now())
+	}
+	if req.SessionID == "" {
+		return e.runOnce(rt, req.Code)
+	}
+	if !rt.Persistent {
+		return failedResult(rt.Lang, "runtime", fmt.Sprintf("%s 不支持持久会话（session_id），仅 python/javascript 支持", rt.Lang), time.Now())
+	}
+	return e.kernels.Exec(rt, req)
+}
 
-	// 拼接桥接代码 + 用户代码
-	fullCode := bridgePython + "\n" + code
+// failedResult 执行前失败的结果
+func failedResult(lang, errorType, stderr string, start time.Time) *ExecutionResult {
+	return &ExecutionResult{
+		Success:    false,
+		Language:   lang,
+		ErrorType:  errorType,
+		Stderr:     stderr,
+		ExitCode:   -1,
+		DurationMs: time.Since(start).Milliseconds(),
+		ToolCalls:  []ToolCallRecord{},
+	}
+}
 
-	// 写入临时文件（解决 Windows 命令行编码问题，中文代码通过 -c 传递可能损坏）
-	tmpDir := os.TempDir()
-	tmpFile, err := os.CreateTemp(tmpDir, "exec_*.py")
+// command 构造子进程：沙箱模式经 sandbox-init 启动，否则在宿主临时目录中直接运行。
+// 返回的 cleanup 在进程结束后调用。
+func (e *Executor) command(ctx context.Context, rt *codeRuntime, files map[string]string, entry string) (*exec.Cmd, *sandboxRun, func(), error) {
+	if e.sandboxErr != nil {
+		return nil, nil, nil, e.sandboxErr
+	}
+	if e.sandbox != nil {
+		cmd, run, err := e.sandbox.command(ctx, rt, files, entry, sandboxEnv(e.cfg.EnvAllowlist, true))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return cmd, run, run.cleanup, nil
+	}
+
+	// 写入临时目录（解决 Windows 命令行编码问题，中文代码通过 -c 传递可能损坏）
+	dir, err := os.MkdirTemp("", "exec_*")
 	if err != nil {
-		result.Success = false
-		result.ErrorType = "runtime"
-		result.Stderr = fmt.Sprintf("failed to create temp file: %v", err)
-		result.DurationMs = time.Since(start).Milliseconds()
-		return result
-	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
-
-	// 写入 UTF-8 编码的 Python 代码（添加 coding 声明确保 Python 正确解读）
-	codingHeader := "# -*- coding: utf-8 -*-\n"
-	if _, err := tmpFile.WriteString(codingHeader + fullCode); err != nil {
-		tmpFile.Close()
-		result.Success = false
-		result.ErrorType = "runtime"
-		result.Stderr = fmt.Sprintf("failed to write temp file: %v", err)
-		result.DurationMs = time.Since(start).Milliseconds()
-		return result
+		return nil, nil, nil, fmt.Errorf("failed to create temp dir: %v", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	size := 0
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			cleanup()
+			return nil, nil, nil, fmt.Errorf("failed to write temp file: %v", err)
+		}
+		size += len(content)
 	}
-	tmpFile.Close()
+	log.Printf("[Executor] %s code written to temp dir: %s (%d bytes)", rt.Lang, filepath.Base(dir), size)
+
+	cmd := exec.CommandContext(ctx, rt.binary(e.cfg), rt.args(dir, entry, files)...)
+	cmd.Dir = dir
+	cmd.Env = sandboxEnv(e.cfg.EnvAllowlist, false)
+	if rt.env != nil {
+		cmd.Env = append(cmd.Env, rt.env(e.cfg, goBuildCacheDir())...)
+	}
+	return cmd, nil, cleanup, nil
+}
 
-	log.Printf("[Executor] code written to temp file: %s (%d bytes)", filepath.Base(tmpPath), len(codingHeader)+len(fullCode))
+// runOnce 一次性执行：每次启动新进程，执行结束即退出
+func (e *Executor) runOnce(rt *codeRuntime, code string) *ExecutionResult {
+	start := time.Now()
+	result := &ExecutionResult{ToolCalls: []ToolCallRecord{}, Language: rt.Lang}
 
-	// 启动 Python 子进程（带超时）
+	// 带超时启动子进程
 	ctx, cancel := context.WithTimeout(context.Background(),
 		time.Duration(e.cfg.MaxExecTimeSec)*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, e.cfg.PythonPath, "-u", tmpPath)
-	// 设置 UTF-8 编码环境变量，确保 stdin/stdout/stderr 使用 UTF-8
-	cmd.Env = append(os.Environ(), "PYTHONIOENCODING=utf-8", "PYTHONUTF8=1")
+	files, entry := rt.files(code)
+	cmd, run, cleanup, err := e.command(ctx, rt, files, entry)
+	if err != nil {
+		errorType := "runtime"
+		if e.sandbox != nil || e.sandboxErr != nil {
+			errorType = ErrTypeSandbox
+		}
+		return failedResult(rt.Lang, errorType, fmt.Sprintf("failed to prepare %s process: %v", rt.Lang, err), start)
+	}
+	defer cleanup()
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		result.Success = false
-		result.ErrorType = "runtime"
-		result.Stderr = fmt.Sprintf("failed to create stdin pipe: %v", err)
-		result.DurationMs = time.Since(start).Milliseconds()
-		return result
+		return failedResult(rt.Lang, "runtime", fmt.Sprintf("failed to create stdin pipe: %v", err), start)
 	}
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		result.Success = false
-		result.ErrorType = "runtime"
-		result.Stderr = fmt.Sprintf("failed to create stdout pipe: %v", err)
-		result.DurationMs = time.Since(start).Milliseconds()
-		return result
+		return failedResult(rt.Lang, "runtime", fmt.Sprintf("failed to create stdout pipe: %v", err), start)
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		result.Success = false
-		result.ErrorType = "runtime"
-		result.Stderr = fmt.Sprintf("failed to create stderr pipe: %v", err)
-		result.DurationMs = time.Since(start).Milliseconds()
-		return result
+		return failedResult(rt.Lang, "runtime", fmt.Sprintf("failed to create stderr pipe: %v", err), start)
 	}
 
 	if err := cmd.Start(); err != nil {
-		result.Success = false
-		result.ErrorType = "runtime"
-		result.Stderr = fmt.Sprintf("failed to start python: %v", err)
-		result.DurationMs = time.Since(start).Milliseconds()
-		return result
+		return failedResult(rt.Lang, "runtime", fmt.Sprintf("failed to start %s: %v", rt.Lang, err), start)
 	}
 
 	// 异步读取 stderr
@@ -158,101 +212,11 @@ func (e *Executor) Execute(code string) *ExecutionResult {
 		stderrCh <- string(data)
 	}()
 
-	// 逐行扫描 stdout
-	scanner := bufio.NewScanner(stdout)
-	// 增大 scanner buffer 以处理大行（工具调用结果可能很大）
-	scanner.Buffer(make([]byte, 0, 256*1024), 1024*1024)
+	// 逐行扫描 stdout，处理工具调用直到进程退出
 	var output strings.Builder
+	e.pumpOutput(rt, newLineScanner(stdout), stdin, result, &output, "")
 
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if strings.HasPrefix(line, toolCallPrefix) && strings.HasSuffix(line, toolCallSuffix) {
-			// 解析工具调用请求
-			jsonStr := line[len(toolCallPrefix) : len(line)-len(toolCallSuffix)]
-			var req toolCallRequest
-			if err := json.Unmarshal([]byte(jsonStr), &req); err != nil {
-				log.Printf("[Executor] invalid tool_call json: %v", err)
-				// 返回错误给 Python
-				resp := toolCallResponse{Success: false, Error: "invalid tool_call json"}
-				respData, _ := json.Marshal(resp)
-				stdin.Write(append(respData, '\n'))
-				continue
-			}
-
-			// 拦截虚拟工具（这些工具仅在 LLM Agent 主循环中处理，不可在 ExecuteCode 中调用）
-			if isVirtualTool(req.Tool) {
-				log.Printf("[Executor] blocked virtual tool call: %s", req.Tool)
-				resp := toolCallResponse{
-					Success: false,
-					Error:   fmt.Sprintf("%s 是虚拟工具，不能在 ExecuteCode 中调用。请直接使用具体工具（如 RawAddTodo, RawGetTodosByDate 等）", req.Tool),
-				}
-				respData, _ := json.Marshal(resp)
-				stdin.Write(append(respData, '\n'))
-
-				// 记录为失败调用
-				result.ToolCalls = append(result.ToolCalls, ToolCallRecord{
-					Tool:    req.Tool,
-					Success: false,
-					Error:   resp.Error,
-				})
-				continue
-			}
-
-			// 通过 UAP 调用真正的 MCP 工具
-			toolStart := time.Now()
-			toolResult, toolAgentID, toolErr := e.callTool(req.Tool, req.Args)
-			toolDuration := time.Since(toolStart)
-
-			// 记录工具调用（无论成败）
-			record := ToolCallRecord{
-				Tool:     req.Tool,
-				AgentID:  toolAgentID,
-				Success:  toolErr == nil,
-				Duration: toolDuration.Milliseconds(),
-			}
-			if toolErr != nil {
-				record.Error = toolErr.Error()
-			}
-			result.ToolCalls = append(result.ToolCalls, record)
-
-			log.Printf("[Executor] tool_call %s success=%v duration=%dms",
-				req.Tool, toolErr == nil, toolDuration.Milliseconds())
-
-			// 构建返回给 Python 的响应
-			var resp toolCallResponse
-			if toolErr != nil {
-				resp = toolCallResponse{
-					Success: false,
-					Error:   toolErr.Error(),
-				}
-			} else {
-				// 保持原始 JSON — 如果 toolResult 是合法 JSON 则原样传递
-				rawData := tryParseRawJSON(toolResult)
-				resp = toolCallResponse{
-					Success: true,
-					Data:    rawData,
-				}
-			}
-
-			respData, _ := json.Marshal(resp)
-			log.Printf("[Executor] tool_response → stdin tool=%s success=%v len=%d",
-				req.Tool, resp.Success, len(respData))
-			if _, writeErr := stdin.Write(append(respData, '\n')); writeErr != nil {
-				log.Printf("[Executor] write to stdin failed: %v", writeErr)
-				break
-			}
-		} else {
-			// 普通 print → 收集为最终输出
-			if output.Len() >= e.cfg.MaxOutputSize {
-				result.Truncated = true
-				continue // 不再追加
-			}
-			output.WriteString(line + "\n")
-		}
-	}
-
-	// 关闭 stdin，让 Python 可以正常退出
+	// 关闭 stdin，让子进程可以正常退出
 	stdin.Close()
 
 	// 等待进程结束
@@ -276,11 +240,7 @@ func (e *Executor) Execute(code string) *ExecutionResult {
 		result.ErrorType = "timeout"
 	} else if result.ExitCode != 0 {
 		result.Success = false
-		if strings.Contains(result.Stderr, "SyntaxError") {
-			result.ErrorType = "syntax"
-		} else {
-			result.ErrorType = "runtime"
-		}
+		result.ErrorType = classifyFailure(rt, run, cmd.ProcessState, result.ExitCode, result.Stderr)
 	} else {
 		result.Success = true
 		if result.Truncated {
@@ -291,6 +251,142 @@ func (e *Executor) Execute(code string) *ExecutionResult {
 	return result
 }
 
+// classifyFailure 非零退出的错误分类：沙箱初始化失败 > 资源超限 > 语法错误 > 运行时错误
+func classifyFailure(rt *codeRuntime, run *sandboxRun, state *os.ProcessState, exitCode int, stderr string) string {
+	if run != nil && exitCode == sandboxSetupExitCode && strings.HasPrefix(stderr, "sandbox: ") {
+		return ErrTypeSandbox
+	}
+	if run != nil {
+		if violation := run.violation(state, stderr); violation != "" {
+			return violation
+		}
+	}
+	for _, marker := range rt.syntaxMarkers {
+		if strings.Contains(stderr, marker) {
+			return "syntax"
+		}
+	}
+	return "runtime"
+}
+
+// newLineScanner 增大 buffer 以处理大行（工具调用请求可能很大）
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 256*1024), 1024*1024)
+	return scanner
+}
+
+// pumpOutput 读取子进程 stdout：普通输出收集到 output，工具调用转发并回写结果。
+// doneMarker 非空时读到包含该标记的行即返回标记后的内容（持久内核的单次执行结束）；
+// 否则读到 EOF 返回。返回值 ok 表示是否读到 doneMarker。
+func (e *Executor) pumpOutput(rt *codeRuntime, scanner *bufio.Scanner, stdin io.Writer, result *ExecutionResult, output *strings.Builder, doneMarker string) (string, bool) {
+	appendOutput := func(text string) {
+		if output.Len() >= e.cfg.MaxOutputSize {
+			result.Truncated = true
+			return // 不再追加
+		}
+		output.WriteString(text + "\n")
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// 用户输出未换行时，标记可能跟在同一行末尾
+		if doneMarker != "" {
+			if idx := strings.Index(line, doneMarker); idx >= 0 && strings.HasSuffix(line, toolCallSuffix) {
+				if idx > 0 {
+					appendOutput(line[:idx])
+				}
+				return line[idx+len(doneMarker) : len(line)-len(toolCallSuffix)], true
+			}
+		}
+		idx := strings.Index(line, toolCallPrefix)
+		if idx < 0 || !strings.HasSuffix(line, toolCallSuffix) {
+			// 普通 print → 收集为最终输出
+			appendOutput(line)
+			continue
+		}
+		if idx > 0 {
+			appendOutput(line[:idx])
+		}
+		if !e.handleToolCallLine(rt, line[idx+len(toolCallPrefix):len(line)-len(toolCallSuffix)], stdin, result) {
+			break
+		}
+	}
+	return "", false
+}
+
+// handleToolCallLine 解析并转发一次工具调用，将结果写回子进程 stdin；写入失败返回 false
+func (e *Executor) handleToolCallLine(rt *codeRuntime, jsonStr string, stdin io.Writer, result *ExecutionResult) bool {
+	reply := func(resp toolCallResponse) bool {
+		if _, err := stdin.Write(append(rt.encodeResponse(resp), '\n')); err != nil {
+			log.Printf("[Executor] write to stdin failed: %v", err)
+			return false
+		}
+		return true
+	}
+
+	var req toolCallRequest
+	if err := json.Unmarshal([]byte(jsonStr), &req); err != nil {
+		log.Printf("[Executor] invalid tool_call json: %v", err)
+		// 返回错误给子进程
+		return reply(toolCallResponse{Success: false, Error: "invalid tool_call json"})
+	}
+
+	// 拦截虚拟工具（这些工具仅在 LLM Agent 主循环中处理，不可在 ExecuteCode 中调用）
+	if isVirtualTool(req.Tool) {
+		log.Printf("[Executor] blocked virtual tool call: %s", req.Tool)
+		resp := toolCallResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%s 是虚拟工具，不能在 ExecuteCode 中调用。请直接使用具体工具（如 RawAddTodo, RawGetTodosByDate 等）", req.Tool),
+		}
+		// 记录为失败调用
+		result.ToolCalls = append(result.ToolCalls, ToolCallRecord{
+			Tool:    req.Tool,
+			Success: false,
+			Error:   resp.Error,
+		})
+		return reply(resp)
+	}
+
+	// 通过 UAP 调用真正的 MCP 工具
+	toolStart := time.Now()
+	toolResult, toolAgentID, toolErr := e.callTool(req.Tool, req.Args)
+	toolDuration := time.Since(toolStart)
+
+	// 记录工具调用（无论成败）
+	record := ToolCallRecord{
+		Tool:     req.Tool,
+		AgentID:  toolAgentID,
+		Success:  toolErr == nil,
+		Duration: toolDuration.Milliseconds(),
+	}
+	if toolErr != nil {
+		record.Error = toolErr.Error()
+	}
+	result.ToolCalls = append(result.ToolCalls, record)
+
+	log.Printf("[Executor] tool_call %s success=%v duration=%dms",
+		req.Tool, toolErr == nil, toolDuration.Milliseconds())
+
+	// 构建返回给子进程的响应
+	var resp toolCallResponse
+	if toolErr != nil {
+		resp = toolCallResponse{
+			Success: false,
+			Error:   toolErr.Error(),
+		}
+	} else {
+		// 保持原始 JSON — 如果 toolResult 是合法 JSON 则原样传递
+		resp = toolCallResponse{
+			Success: true,
+			Data:    tryParseRawJSON(toolResult),
+		}
+	}
+	log.Printf("[Executor] tool_response → stdin tool=%s success=%v", req.Tool, resp.Success)
+	return reply(resp)
+}
+
 // isVirtualTool 判断是否为虚拟工具（仅在 LLM Agent 主循环中处理，不可在 ExecuteCode 中调用）
 func isVirtualTool(name string) bool {
 	switch name {
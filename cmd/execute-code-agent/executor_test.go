@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestExecutor(t *testing.T, tweak func(cfg *Config)) *Executor {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Sandbox.Mode = SandboxModeNone
+	cfg.MaxExecTimeSec = 60
+	if tweak != nil {
+		tweak(cfg)
+	}
+	e := NewExecutor(cfg)
+	e.callTool = func(toolName string, args json.RawMessage) (string, string, error) {
+		return `{"tool":"` + toolName + `","args":` + string(args) + `}`, "mock-agent", nil
+	}
+	t.Cleanup(e.kernels.CloseAll)
+	return e
+}
+
+func requireRuntime(t *testing.T, e *Executor, lang string) {
+	t.Helper()
+	if _, err := exec.LookPath(codeRuntimes[lang].binary(e.cfg)); err != nil {
+		t.Skipf("%s runtime not installed", lang)
+	}
+}
+
+func TestRunLanguagesWithToolCalls(t *testing.T) {
+	e := newTestExecutor(t, nil)
+	cases := []struct {
+		lang, code string
+	}{
+		{LangPython, `r = call_tool("Echo", {"n": 7})
+print("n =", r["args"]["n"])`},
+		{LangJavaScript, `const r = callTool("Echo", { n: 7 });
+console.log("n =", r.args.n);`},
+		{LangShell, `r=$(call_tool Echo '{"n": 7}')
+echo "n = $(printf '%s' "$r" | sed 's/.*"n":\([0-9]*\).*/\1/')"`},
+		{LangGo, `package main
+
+import "fmt"
+
+func main() {
+	r, err := CallTool("Echo", map[string]any{"n": 7})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("n =", r.(map[string]any)["args"].(map[string]any)["n"])
+}`},
+	}
+	for _, c := range cases {
+		t.Run(c.lang, func(t *testing.T) {
+			requireRuntime(t, e, c.lang)
+			r := e.Run(ExecRequest{Language: c.lang, Code: c.code})
+			if !r.Success {
+				t.Fatalf("failed: %s %s", r.ErrorType, r.Stderr)
+			}
+			if strings.TrimSpace(r.Stdout) != "n = 7" {
+				t.Fatalf("stdout = %q", r.Stdout)
+			}
+			if len(r.ToolCalls) != 1 || r.ToolCalls[0].Tool != "Echo" || !r.ToolCalls[0].Success {
+				t.Fatalf("tool calls = %+v", r.ToolCalls)
+			}
+		})
+	}
+}
+
+func TestRunSyntaxErrors(t *testing.T) {
+	e := newTestExecutor(t, nil)
+	cases := map[string]string{
+		LangPython:     "def (",
+		LangJavaScript: "function (",
+		LangShell:      "if then fi (",
+		LangGo:         "package main\nfunc main() {",
+	}
+	for lang, code := range cases {
+		t.Run(lang, func(t *testing.T) {
+			requireRuntime(t, e, lang)
+			r := e.Run(ExecRequest{Language: lang, Code: code})
+			if r.Success || r.ErrorType != "syntax" {
+				t.Fatalf("success=%v error_type=%q stderr=%s", r.Success, r.ErrorType, r.Stderr)
+			}
+		})
+	}
+}
+
+func TestRunRejectsUnsupported(t *testing.T) {
+	e := newTestExecutor(t, nil)
+	if r := e.Run(ExecRequest{Language: "cobol", Code: "x"}); r.Success || !strings.Contains(r.Stderr, "不支持的语言") {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+	if r := e.Run(ExecRequest{Language: LangShell, Code: "echo", SessionID: "s1"}); r.Success || !strings.Contains(r.Stderr, "持久会话") {
+		t.Fatalf("shell session should be rejected: %+v", r)
+	}
+}
+
+func TestKernelPersistence(t *testing.T) {
+	e := newTestExecutor(t, nil)
+	cases := []struct {
+		lang                       string
+		define, use, fail, inspect string
+	}{
+		{LangPython, "x = 41\ndef inc(v):\n    return v + 1", "print(inc(x), call_tool('Echo', {})['tool'])", "raise ValueError('boom')", "print(x)"},
+		{LangJavaScript, "var x = 41; function inc(v) { return v + 1; }", "console.log(inc(x), callTool('Echo', {}).tool)", "throw new Error('boom')", "console.log(x)"},
+	}
+	for _, c := range cases {
+		t.Run(c.lang, func(t *testing.T) {
+			requireRuntime(t, e, c.lang)
+			run := func(code string, reset bool) *ExecutionResult {
+				return e.Run(ExecRequest{Language: c.lang, Code: code, SessionID: "task-1", Reset: reset})
+			}
+
+			r := run(c.define, false)
+			if !r.Success || !r.SessionNew {
+				t.Fatalf("define: success=%v new=%v stderr=%s", r.Success, r.SessionNew, r.Stderr)
+			}
+			r = run(c.use, false)
+			if !r.Success || r.SessionNew || strings.TrimSpace(r.Stdout) != "42 Echo" {
+				t.Fatalf("use: success=%v new=%v stdout=%q stderr=%s", r.Success, r.SessionNew, r.Stdout, r.Stderr)
+			}
+			if len(r.ToolCalls) != 1 {
+				t.Fatalf("tool calls = %+v", r.ToolCalls)
+			}
+
+			// 运行时错误不影响会话
+			r = run(c.fail, false)
+			if r.Success || r.ErrorType != "runtime" || !strings.Contains(r.Stderr, "boom") {
+				t.Fatalf("fail: success=%v error_type=%q stderr=%s", r.Success, r.ErrorType, r.Stderr)
+			}
+			if r = run(c.inspect, false); strings.TrimSpace(r.Stdout) != "41" {
+				t.Fatalf("state lost after error: %q %s", r.Stdout, r.Stderr)
+			}
+
+			// reset 后变量清空
+			r = run(c.inspect, true)
+			if r.Success || !r.SessionNew {
+				t.Fatalf("reset: success=%v new=%v stdout=%q", r.Success, r.SessionNew, r.Stdout)
+			}
+		})
+	}
+}
+
+func TestKernelIdleEvictionAndTimeout(t *testing.T) {
+	e := newTestExecutor(t, func(cfg *Config) {
+		cfg.MaxExecTimeSec = 2
+		cfg.MaxSessions = 1
+	})
+	requireRuntime(t, e, LangPython)
+
+	run := func(session, code string) *ExecutionResult {
+		return e.Run(ExecRequest{Language: LangPython, Code: code, SessionID: session})
+	}
+
+	run("a", "x = 1")
+	if n := e.kernels.evictIdle(time.Now()); n != 0 {
+		t.Fatalf("evicted %d fresh kernels", n)
+	}
+	if n := e.kernels.evictIdle(time.Now().Add(time.Hour)); n != 1 || e.kernels.Count() != 0 {
+		t.Fatalf("idle eviction: evicted=%d count=%d", n, e.kernels.Count())
+	}
+	if r := run("a", "print('x' in globals())"); !r.SessionNew || strings.TrimSpace(r.Stdout) != "False" {
+		t.Fatalf("expected fresh session, got new=%v stdout=%q", r.SessionNew, r.Stdout)
+	}
+
+	// max_sessions=1：新会话淘汰最久未用的会话
+	run("b", "y = 2")
+	if e.kernels.Count() != 1 {
+		t.Fatalf("count = %d, want 1", e.kernels.Count())
+	}
+
+	// 超时杀死内核，会话丢失
+	if r := run("b", "while True:\n    pass"); r.ErrorType != "timeout" {
+		t.Fatalf("error_type = %q, want timeout", r.ErrorType)
+	}
+	if r := run("b", "print('y' in globals())"); !r.SessionNew || strings.TrimSpace(r.Stdout) != "False" {
+		t.Fatalf("expected new session after timeout, got new=%v stdout=%q", r.SessionNew, r.Stdout)
+	}
+}
+
+func TestKernelSessionIsBoundToOwner(t *testing.T) {
+	e := newTestExecutor(t, nil)
+	requireRuntime(t, e, LangPython)
+
+	run := func(owner, code string) *ExecutionResult {
+		return e.Run(ExecRequest{Language: LangPython, Code: code, SessionID: "shared", Owner: owner})
+	}
+	if r := run("user:alice", "secret = 'alice-only'"); !r.Success || !r.SessionNew {
+		t.Fatalf("alice define: success=%v new=%v stderr=%s", r.Success, r.SessionNew, r.Stderr)
+	}
+
+	// 另一个调用方复用同一 session_id：拒绝，且看不到 alice 的变量
+	r := run("user:mallory", "print(secret)")
+	if r.Success || strings.Contains(r.Stdout, "alice-only") || !strings.Contains(r.Stderr, "其他调用方") {
+		t.Fatalf("foreign attach should be rejected: success=%v stdout=%q stderr=%s", r.Success, r.Stdout, r.Stderr)
+	}
+	if r := e.Run(ExecRequest{Language: LangPython, Code: "print(1)", SessionID: "shared", Owner: "user:mallory", Reset: true}); r.Success {
+		t.Fatalf("foreign reset should be rejected: %+v", r)
+	}
+
+	if r := run("user:alice", "print(secret)"); !r.Success || strings.TrimSpace(r.Stdout) != "alice-only" {
+		t.Fatalf("owner should keep its session: stdout=%q stderr=%s", r.Stdout, r.Stderr)
+	}
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ========================= 持久会话内核 =========================
+//
+// 同一 session_id 的多次 ExecuteCode 复用一个长驻解释器进程，变量/函数/导入跨调用保留。
+// 内核逐行读取 {"code": "..."} 请求，执行期间照常通过 __TOOL_CALL__ 协议调用工具，
+// 执行结束输出一行 __EXEC_DONE__{"ok":...,"error_type":...,"stderr":...}__END__。
+// 内核崩溃、超时或超限被杀后会话丢失，下次调用自动新建（ExecutionResult.SessionNew=true）。
+// 沙箱模式下 RLIMIT_CPU 作用于整个内核进程，即会话内所有执行累计的 CPU 时间。
+
+const execDonePrefix = "__EXEC_DONE__"
+
+// kernelPython Python 内核主循环（拼接在 bridgePython 之后）
+const kernelPython = `import io as _io, traceback as _traceback
+
+_kernel_globals = {"__name__": "__main__", "__builtins__": __builtins__,
+                   "call_tool": call_tool, "safe_call_tool": safe_call_tool}
+_real_stderr = sys.stderr
+
+while True:
+    _line = sys.stdin.readline()
+    if not _line:
+        break
+    _req = json.loads(_line)
+    _err = _io.StringIO()
+    _done = {"ok": True}
+    sys.stderr = _err
+    try:
+        exec(compile(_req["code"], "<session>", "exec"), _kernel_globals)
+    except SyntaxError:
+        _done = {"ok": False, "error_type": "syntax"}
+        _traceback.print_exc()
+    except SystemExit as _e:
+        if _e.code not in (None, 0):
+            _done = {"ok": False, "error_type": "runtime"}
+            print(f"SystemExit: {_e.code}", file=sys.stderr)
+    except BaseException:
+        _done = {"ok": False, "error_type": "runtime"}
+        _traceback.print_exc()
+    finally:
+        sys.stderr = _real_stderr
+    sys.stdout.flush()
+    _done["stderr"] = _err.getvalue()
+    print("__EXEC_DONE__" + json.dumps(_done) + "__END__", flush=True)
+`
+
+// kernelJavaScript node 内核主循环（拼接在 bridgeJavaScript 之后）。
+// 代码在同一全局上下文中同步执行：顶层变量跨调用保留，未完成的 Promise/定时器不会被等待。
+const kernelJavaScript = `const __vm = require('vm');
+const __util = require('util');
+globalThis.require = require;
+let __stderrBuf = null;
+const __origError = console.error;
+console.error = (...a) => { if (__stderrBuf !== null) __stderrBuf.push(__util.format(...a)); else __origError(...a); };
+console.warn = console.error;
+for (;;) {
+  const line = __readLine();
+  if (!line) break;
+  const req = JSON.parse(line);
+  const done = { ok: true };
+  __stderrBuf = [];
+  try {
+    __vm.runInThisContext(req.code, { filename: 'session.js' });
+  } catch (e) {
+    done.ok = false;
+    done.error_type = e instanceof SyntaxError ? 'syntax' : 'runtime';
+    __stderrBuf.push(e && e.stack ? e.stack : String(e));
+  }
+  done.stderr = __stderrBuf.length ? __stderrBuf.join('\n') + '\n' : '';
+  __stderrBuf = null;
+  __fs.writeSync(1, '__EXEC_DONE__' + JSON.stringify(done) + '__END__\n');
+}
+`
+
+// kernelFiles 内核启动文件
+func kernelFiles(rt *codeRuntime) (map[string]string, string) {
+	switch rt.Lang {
+	case LangPython:
+		return map[string]string{"kernel.py": "# -*- coding: utf-8 -*-\n" + bridgePython + "\n" + kernelPython}, "kernel.py"
+	case LangJavaScript:
+		return map[string]string{"kernel.js": bridgeJavaScript + "\n" + kernelJavaScript}, "kernel.js"
+	}
+	return nil, ""
+}
+
+// execDone 内核单次执行结束标记的内容
+type execDone struct {
+	OK        bool   `json:"ok"`
+	ErrorType string `json:"error_type"`
+	Stderr    string `json:"stderr"`
+}
+
+// kernel 一个会话的长驻解释器进程
+type kernel struct {
+	key       string
+	sessionID string
+	owner     string // 创建会话的调用方，其他调用方不能复用该会话
+	rt        *codeRuntime
+
+	cancel  context.CancelFunc
+	cleanup func()
+	run     *sandboxRun
+	stdin   io.WriteCloser
+	stdout  *bufio.Scanner
+	stderr  *kernelStderr
+	exited  chan struct{} // 进程退出后关闭
+	state   *os.ProcessState
+
+	closeOnce sync.Once
+
+	mu       sync.Mutex // 串行化同一会话的执行
+	users    int        // 正在使用/等待的执行数，受 KernelManager.mu 保护
+	lastUsed time.Time  // 受 KernelManager.mu 保护
+}
+
+// kernelStderr 收集内核进程自身的 stderr（解释器崩溃信息等），超过上限丢弃
+type kernelStderr struct {
+	mu  sync.Mutex
+	buf strings.Builder
+	max int
+}
+
+func (s *kernelStderr) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf.Len() < s.max {
+		s.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// take 取出并清空已收集的内容
+func (s *kernelStderr) take() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.buf.String()
+	s.buf.Reset()
+	return out
+}
+
+// KernelManager 管理会话内核：按 (语言, 调用方, session_id) 复用，空闲超时回收，数量超限时淘汰最久未用的空闲内核
+type KernelManager struct {
+	e *Executor
+
+	mu      sync.Mutex
+	kernels map[string]*kernel
+
+	janitorOnce sync.Once
+	stopCh      chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewKernelManager 创建内核管理器
+func NewKernelManager(e *Executor) *KernelManager {
+	return &KernelManager{
+		e:       e,
+		kernels: make(map[string]*kernel),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Exec 在会话内核中执行代码
+func (m *KernelManager) Exec(rt *codeRuntime, req ExecRequest) *ExecutionResult {
+	start := time.Now()
+	m.janitorOnce.Do(func() { go m.janitor() })
+
+	k, isNew, err := m.acquire(rt, req.Owner, req.SessionID, req.Reset)
+	if err != nil {
+		errorType := "runtime"
+		if m.e.sandbox != nil || m.e.sandboxErr != nil {
+			errorType = ErrTypeSandbox
+		}
+		result := failedResult(rt.Lang, errorType, err.Error(), start)
+		result.SessionID = req.SessionID
+		return result
+	}
+	defer m.release(k)
+
+	result := m.e.execInKernel(k, req.Code)
+	result.SessionID = req.SessionID
+	result.SessionNew = isNew
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// acquire 获取（必要时启动）会话内核并标记为忙；reset 时先关闭已有内核。
+// 同一 session_id 已被其他调用方占用时拒绝，避免读取或篡改他人的会话状态
+func (m *KernelManager) acquire(rt *codeRuntime, owner, sessionID string, reset bool) (*kernel, bool, error) {
+	key := rt.Lang + ":" + owner + ":" + sessionID
+
+	m.mu.Lock()
+	if other := m.foreignSessionLocked(rt.Lang, owner, sessionID); other != nil {
+		m.mu.Unlock()
+		log.Printf("[Kernel] reject session %s:%s for %s (owned by %s)", rt.Lang, sessionID, owner, other.owner)
+		return nil, false, fmt.Errorf("session_id %q 已被其他调用方使用，请换一个 session_id", sessionID)
+	}
+	k := m.kernels[key]
+	if k != nil && reset {
+		delete(m.kernels, key)
+		m.mu.Unlock()
+		log.Printf("[Kernel] reset session %s", key)
+		k.mu.Lock()
+		k.close()
+		k.mu.Unlock()
+		m.mu.Lock()
+		k = m.kernels[key]
+	}
+	if k != nil {
+		k.users++
+		m.mu.Unlock()
+		k.mu.Lock()
+		return k, false, nil
+	}
+	if len(m.kernels) >= m.e.cfg.MaxSessions {
+		victim := m.lruIdleLocked()
+		if victim == nil {
+			m.mu.Unlock()
+			return nil, false, fmt.Errorf("会话内核数已达上限 %d 且均在执行中，请稍后重试或不使用 session_id", m.e.cfg.MaxSessions)
+		}
+		delete(m.kernels, victim.key)
+		log.Printf("[Kernel] evict session %s (max_sessions=%d)", victim.key, m.e.cfg.MaxSessions)
+		go victim.close()
+	}
+	m.mu.Unlock()
+
+	// 启动在锁外进行，避免阻塞其他会话
+	k, err := m.e.startKernel(rt, key, sessionID)
+	if err != nil {
+		return nil, false, err
+	}
+	k.owner = owner
+	k.mu.Lock()
+
+	m.mu.Lock()
+	if other := m.foreignSessionLocked(rt.Lang, owner, sessionID); other != nil {
+		// 启动期间其他调用方抢先创建了同名会话
+		m.mu.Unlock()
+		k.mu.Unlock()
+		k.close()
+		return nil, false, fmt.Errorf("session_id %q 已被其他调用方使用，请换一个 session_id", sessionID)
+	}
+	if existing := m.kernels[key]; existing != nil {
+		// 并发请求已启动了同一会话的内核，使用已有的
+		existing.users++
+		m.mu.Unlock()
+		k.mu.Unlock()
+		k.close()
+		existing.mu.Lock()
+		return existing, false, nil
+	}
+	k.users++
+	m.kernels[key] = k
+	m.mu.Unlock()
+	log.Printf("[Kernel] started session %s", key)
+	return k, true, nil
+}
+
+// foreignSessionLocked 查找同语言、同 session_id 但属于其他调用方的内核（调用方持有 m.mu）
+func (m *KernelManager) foreignSessionLocked(lang, owner, sessionID string) *kernel {
+	for _, k := range m.kernels {
+		if k.rt.Lang == lang && k.sessionID == sessionID && k.owner != owner {
+			return k
+		}
+	}
+	return nil
+}
+
+// release 执行结束：内核已退出则移出会话表
+func (m *KernelManager) release(k *kernel) {
+	dead := k.dead()
+	k.mu.Unlock()
+
+	m.mu.Lock()
+	k.users--
+	k.lastUsed = time.Now()
+	if dead && m.kernels[k.key] == k {
+		delete(m.kernels, k.key)
+		log.Printf("[Kernel] session %s lost (process exited)", k.key)
+	}
+	m.mu.Unlock()
+	if dead {
+		k.close()
+	}
+}
+
+// lruIdleLocked 最久未用的空闲内核（调用方持有 m.mu）
+func (m *KernelManager) lruIdleLocked() *kernel {
+	var victim *kernel
+	for _, k := range m.kernels {
+		if k.users > 0 {
+			continue
+		}
+		if victim == nil || k.lastUsed.Before(victim.lastUsed) {
+			victim = k
+		}
+	}
+	return victim
+}
+
+// evictIdle 关闭空闲超过 session_idle_sec 的内核
+func (m *KernelManager) evictIdle(now time.Time) int {
+	idle := time.Duration(m.e.cfg.SessionIdleSec) * time.Second
+	var victims []*kernel
+	m.mu.Lock()
+	for key, k := range m.kernels {
+		if k.users == 0 && now.Sub(k.lastUsed) >= idle {
+			delete(m.kernels, key)
+			victims = append(victims, k)
+		}
+	}
+	m.mu.Unlock()
+	for _, k := range victims {
+		log.Printf("[Kernel] session %s idle timeout, closed", k.key)
+		k.close()
+	}
+	return len(victims)
+}
+
+func (m *KernelManager) janitor() {
+	interval := time.Duration(m.e.cfg.SessionIdleSec) * time.Second / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > 30*time.Second {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case now := <-ticker.C:
+			m.evictIdle(now)
+		}
+	}
+}
+
+// Count 当前存活的会话内核数
+func (m *KernelManager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.kernels)
+}
+
+// CloseAll 关闭所有内核（进程退出时调用）
+func (m *KernelManager) CloseAll() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+	m.mu.Lock()
+	kernels := m.kernels
+	m.kernels = make(map[string]*kernel)
+	m.mu.Unlock()
+	for _, k := range kernels {
+		k.close()
+	}
+}
+
+// startKernel 启动内核进程
+func (e *Executor) startKernel(rt *codeRuntime, key, sessionID string) (*kernel, error) {
+	files, entry := kernelFiles(rt)
+	if files == nil {
+		return nil, fmt.Errorf("%s 不支持持久会话", rt.Lang)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd, run, cleanup, err := e.command(ctx, rt, files, entry)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to prepare %s kernel: %v", rt.Lang, err)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		cleanup()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		cleanup()
+		return nil, err
+	}
+	stderr := &kernelStderr{max: e.cfg.MaxOutputSize}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		cancel()
+		cleanup()
+		return nil, fmt.Errorf("failed to start %s kernel: %v", rt.Lang, err)
+	}
+
+	k := &kernel{
+		key:       key,
+		sessionID: sessionID,
+		rt:        rt,
+		cancel:    cancel,
+		cleanup:   cleanup,
+		run:       run,
+		stdin:     stdin,
+		stdout:    newLineScanner(stdout),
+		stderr:    stderr,
+		exited:    make(chan struct{}),
+		lastUsed:  time.Now(),
+	}
+	go func() {
+		cmd.Wait()
+		k.state = cmd.ProcessState
+		close(k.exited)
+	}()
+	return k, nil
+}
+
+// execInKernel 在内核 k 中执行一段代码（调用方持有 k.mu）
+func (e *Executor) execInKernel(k *kernel, code string) *ExecutionResult {
+	result := &ExecutionResult{ToolCalls: []ToolCallRecord{}, Language: k.rt.Lang}
+
+	req, _ := json.Marshal(map[string]string{"code": code})
+	if _, err := k.stdin.Write(append(req, '\n')); err != nil {
+		return e.kernelLost(k, result, false)
+	}
+
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(time.Duration(e.cfg.MaxExecTimeSec)*time.Second, func() {
+		timedOut.Store(true)
+		k.cancel()
+	})
+	var output strings.Builder
+	payload, ok := e.pumpOutput(k.rt, k.stdout, k.stdin, result, &output, execDonePrefix)
+	timer.Stop()
+	result.Stdout = output.String()
+	if !ok {
+		return e.kernelLost(k, result, timedOut.Load())
+	}
+
+	var done execDone
+	if err := json.Unmarshal([]byte(payload), &done); err != nil {
+		result.Stderr = fmt.Sprintf("invalid kernel response: %v", err)
+		result.ErrorType = "runtime"
+		result.ExitCode = -1
+		return result
+	}
+	result.Stderr = done.Stderr + k.stderr.take()
+	switch {
+	case done.OK:
+		result.Success = true
+		if result.Truncated {
+			result.ErrorType = "output_truncated"
+		}
+	case done.ErrorType == "syntax":
+		result.ErrorType = "syntax"
+		result.ExitCode = 1
+	default:
+		result.ErrorType = "runtime"
+		if e.sandbox != nil {
+			if violation := classifyStderrViolation(result.Stderr); violation != "" {
+				result.ErrorType = violation
+			}
+		}
+		result.ExitCode = 1
+	}
+	return result
+}
+
+// kernelLost 内核进程在执行中退出（崩溃/超时/超限），按一次性执行的规则归类错误
+func (e *Executor) kernelLost(k *kernel, result *ExecutionResult, timedOut bool) *ExecutionResult {
+	k.cancel()
+	<-k.exited
+	result.Stderr += k.stderr.take()
+	result.ExitCode = -1
+	if k.state != nil {
+		result.ExitCode = k.state.ExitCode()
+	}
+	if timedOut {
+		result.ErrorType = "timeout"
+	} else {
+		result.ErrorType = classifyFailure(k.rt, k.run, k.state, result.ExitCode, result.Stderr)
+	}
+	result.Stderr += fmt.Sprintf("\n[session %s lost: kernel exited, variables are gone]\n", k.sessionID)
+	return result
+}
+
+// dead 内核进程是否已退出
+func (k *kernel) dead() bool {
+	select {
+	case <-k.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// close 结束内核进程并释放资源（可重复调用）
+func (k *kernel) close() {
+	k.closeOnce.Do(func() {
+		// 关闭 stdin 让内核主循环自然退出，超时则强制结束
+		k.stdin.Close()
+		select {
+		case <-k.exited:
+		case <-time.After(2 * time.Second):
+			k.cancel()
+			<-k.exited
+		}
+		k.cancel()
+		k.cleanup()
+	})
+}
@@ -15,6 +15,9 @@ import (
 )
 
 func main() {
+	// 沙箱内 re-exec 的 sandbox-init 模式，不会返回
+	MaybeRunSandboxInit()
+
 	configPath := flag.String("config", "execute-code-agent.json", "配置文件路径")
 	genConf := flag.Bool("genconf", false, "生成默认配置文件")
 	genDeploy := flag.Bool("gendeploy", false, "生成部署脚本")
@@ -49,6 +52,16 @@ func main() {
 	}
 	log.Printf("[ExecuteCodeAgent] Python 版本检查通过: %s (%s)", pyVersion, cfg.PythonPath)
 
+	// 沙箱检查：配置文件所在目录（含 auth_token）对沙箱内代码不可见
+	if absDir, err := filepath.Abs(filepath.Dir(*configPath)); err == nil {
+		cfg.Sandbox.HidePaths = append(cfg.Sandbox.HidePaths, absDir)
+	}
+	if err := ProbeSandbox(cfg); err != nil {
+		log.Fatalf("[ExecuteCodeAgent] 沙箱不可用，拒绝启动: %v", err)
+	}
+	log.Printf("[ExecuteCodeAgent] sandbox mode=%s network=%v memory=%dMB pids=%d",
+		cfg.Sandbox.Mode, cfg.Sandbox.AllowNetwork, cfg.Sandbox.MemoryMB, cfg.Sandbox.MaxPids)
+
 	// 加载 env.json
 	envCfg, err := agentbase.LoadEnvConfig(filepath.Dir(*configPath))
 	if err != nil {
@@ -61,6 +74,8 @@ func main() {
 		agentID, cfg.ServerURL, cfg.PythonPath)
 	log.Printf("[ExecuteCodeAgent] max_concurrent=%d max_exec_time=%ds max_output=%d",
 		cfg.MaxConcurrent, cfg.MaxExecTimeSec, cfg.MaxOutputSize)
+	log.Printf("[ExecuteCodeAgent] languages=%v max_sessions=%d session_idle=%ds",
+		AvailableLanguages(cfg), cfg.MaxSessions, cfg.SessionIdleSec)
 
 	conn := NewConnection(cfg, agentID, pyVersion)
 	conn.ActiveTaskCounter = func() int { return int(atomic.LoadInt32(&conn.activeCount)) }
@@ -85,6 +100,7 @@ func main() {
 		<-sigCh
 		log.Println("[ExecuteCodeAgent] received signal, initiating shutdown...")
 		conn.InitiateShutdown("signal")
+		conn.executor.kernels.CloseAll()
 		os.Exit(0)
 	}()
 
@@ -9,9 +9,12 @@ type ExecutionResult struct {
 	Stderr     string           `json:"stderr,omitempty"`
 	ExitCode   int              `json:"exit_code"`
 	DurationMs int64            `json:"duration_ms"`
-	ErrorType  string           `json:"error_type,omitempty"` // "syntax"|"runtime"|"timeout"|"output_truncated"
+	ErrorType  string           `json:"error_type,omitempty"` // "syntax"|"runtime"|"timeout"|"output_truncated"，沙箱下另有 memory_limit 等（见 sandbox.go）
 	ToolCalls  []ToolCallRecord `json:"tool_calls"`
 	Truncated  bool             `json:"truncated,omitempty"`
+	Language   string           `json:"language,omitempty"`
+	SessionID  string           `json:"session_id,omitempty"`  // 持久会话 ID
+	SessionNew bool             `json:"session_new,omitempty"` // 本次执行新建（或重建）了会话内核，之前的变量已丢失
 }
 
 // ToolCallRecord 单次工具调用记录
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ========================= 多语言运行时 =========================
+//
+// 所有语言共用同一套 stdout/stdin 工具调用协议：
+//   子进程输出一行 __TOOL_CALL__{"type":"tool_call","tool":...,"args":{...}}__END__
+//   agent 回写一行响应（python/javascript/go 为 JSON，shell 为 "ok <json>" / "error <msg>"）
+
+// 支持的语言
+const (
+	LangPython     = "python"
+	LangJavaScript = "javascript"
+	LangShell      = "shell"
+	LangGo         = "go"
+)
+
+// codeRuntime 单个语言的运行方式
+type codeRuntime struct {
+	Lang       string
+	Persistent bool // 是否支持会话内核
+	RlimitAS   bool // cgroup 不可用时可用 RLIMIT_AS 限制内存（node/go 启动即预留大量虚拟内存，不适用）
+
+	// binary 返回解释器/工具链命令（未解析为绝对路径）
+	binary func(cfg *Config) string
+	// files 生成一次性执行所需的文件（文件名 → 内容），entry 为入口文件
+	files func(code string) (files map[string]string, entry string)
+	// args 入口文件之外的参数（不含可执行文件本身），dir 为文件所在目录
+	args func(dir, entry string, files map[string]string) []string
+	// env 额外环境变量，workDir 为可写目录
+	env func(cfg *Config, workDir string) []string
+	// encodeResponse 工具调用结果回写格式
+	encodeResponse func(resp toolCallResponse) []byte
+	// syntaxMarkers stderr 包含其一即归类为 syntax 错误
+	syntaxMarkers []string
+}
+
+// bridgeJavaScript 注入到 node 子进程的桥接代码（同步读取 stdin，callTool 可直接返回结果）
+const bridgeJavaScript = `const __fs = require('fs');
+function __readLine() {
+  const bytes = [];
+  const buf = Buffer.alloc(1);
+  for (;;) {
+    let n;
+    try { n = __fs.readSync(0, buf, 0, 1, null); }
+    catch (e) { if (e.code === 'EAGAIN') continue; if (e.code === 'EOF') break; throw e; }
+    if (n === 0 || buf[0] === 10) break;
+    bytes.push(buf[0]);
+  }
+  return Buffer.from(bytes).toString('utf8').trim();
+}
+function __autoParse(data) {
+  if (typeof data === 'string') {
+    try { const v = JSON.parse(data); if (v !== null && typeof v === 'object') return v; } catch (e) {}
+  }
+  return data;
+}
+/** 调用 MCP 工具，返回工具的原始结果（对象/数组/字符串），失败抛异常 */
+function callTool(toolName, args) {
+  const req = JSON.stringify({ type: 'tool_call', tool: toolName, args: args || {} });
+  __fs.writeSync(1, '__TOOL_CALL__' + req + '__END__\n');
+  const line = __readLine();
+  if (!line) throw new Error('Tool ' + toolName + ': no response (agent disconnected?)');
+  const result = JSON.parse(line);
+  if (!result.success) throw new Error('Tool ' + toolName + ' failed: ' + (result.error || 'unknown'));
+  return __autoParse(result.data);
+}
+/** 调用 MCP 工具（失败时返回 defaultValue 而不是抛异常） */
+function safeCallTool(toolName, args, defaultValue) {
+  try { return callTool(toolName, args); }
+  catch (e) { console.error('[WARN] ' + toolName + ' failed: ' + e.message); return defaultValue === undefined ? null : defaultValue; }
+}
+const call_tool = callTool, safe_call_tool = safeCallTool;
+globalThis.callTool = callTool; globalThis.safeCallTool = safeCallTool;
+globalThis.call_tool = callTool; globalThis.safe_call_tool = safeCallTool;
+// ===== user code below =====
+`
+
+// bridgeShell 注入到 bash 的桥接函数：成功时向 stdout 输出结果 JSON，失败时输出错误到 stderr 并返回 1。
+// 工具调用请求写到 fd 9（原始 stdout 的副本），使 r=$(call_tool ...) 的命令替换中也能与 agent 通信
+const bridgeShell = `exec 9>&1
+call_tool() {
+  local __name="$1" __args="${2:-}"
+  [ -z "$__args" ] && __args='{}'
+  printf '__TOOL_CALL__{"type":"tool_call","tool":"%s","args":%s}__END__\n' "$__name" "$__args" >&9
+  local __resp
+  IFS= read -r __resp || { echo "Tool $__name: no response (agent disconnected?)" >&2; return 1; }
+  case "$__resp" in
+    "ok "*) printf '%s\n' "${__resp#ok }" ;;
+    *) echo "Tool $__name failed: ${__resp#error }" >&2; return 1 ;;
+  esac
+}
+safe_call_tool() {
+  call_tool "$1" "${2:-}" 2>/dev/null || printf '%s\n' "${3:-}"
+}
+# ===== user code below =====
+`
+
+// bridgeGo 与用户 main 包一起编译的桥接文件
+const bridgeGo = `package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var bridgeStdin = bufio.NewReader(os.Stdin)
+
+// CallTool 调用 MCP 工具，返回工具的原始结果（map[string]any / []any / string 等）
+func CallTool(name string, args map[string]any) (any, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	req, err := json.Marshal(map[string]any{"type": "tool_call", "tool": name, "args": args})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stdout, "__TOOL_CALL__%s__END__\n", req)
+	line, err := bridgeStdin.ReadString('\n')
+	if strings.TrimSpace(line) == "" {
+		return nil, fmt.Errorf("tool %s: no response (agent disconnected?): %v", name, err)
+	}
+	var resp struct {
+		Success bool            ` + "`json:\"success\"`" + `
+		Data    json.RawMessage ` + "`json:\"data\"`" + `
+		Error   string          ` + "`json:\"error\"`" + `
+	}
+	if err := json.Unmarshal([]byte(line), &resp); err != nil {
+		return nil, fmt.Errorf("tool %s: invalid response: %v", name, err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("tool %s failed: %s", name, resp.Error)
+	}
+	var v any
+	json.Unmarshal(resp.Data, &v)
+	if s, ok := v.(string); ok {
+		var parsed any
+		if json.Unmarshal([]byte(s), &parsed) == nil {
+			switch parsed.(type) {
+			case map[string]any, []any:
+				return parsed, nil
+			}
+		}
+	}
+	return v, nil
+}
+
+// SafeCallTool 调用 MCP 工具（失败时返回 def 而不是 error）
+func SafeCallTool(name string, args map[string]any, def any) any {
+	v, err := CallTool(name, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] %s failed: %v\n", name, err)
+		return def
+	}
+	return v
+}
+`
+
+func encodeJSONResponse(resp toolCallResponse) []byte {
+	data, _ := json.Marshal(resp)
+	return data
+}
+
+// encodeShellResponse shell 无法方便地解析 JSON 外壳，改用单行前缀格式
+func encodeShellResponse(resp toolCallResponse) []byte {
+	if !resp.Success {
+		return []byte("error " + strings.ReplaceAll(resp.Error, "\n", " "))
+	}
+	data := []byte(resp.Data)
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return []byte("ok " + strings.ReplaceAll(string(data), "\n", " "))
+	}
+	return append([]byte("ok "), compact.Bytes()...)
+}
+
+var codeRuntimes = map[string]*codeRuntime{
+	LangPython: {
+		Lang:       LangPython,
+		Persistent: true,
+		RlimitAS:   true,
+		binary:     func(cfg *Config) string { return cfg.PythonPath },
+		files: func(code string) (map[string]string, string) {
+			// 添加 coding 声明确保 Python 正确解读 UTF-8 代码
+			return map[string]string{"main.py": "# -*- coding: utf-8 -*-\n" + bridgePython + "\n" + code}, "main.py"
+		},
+		args: func(dir, entry string, _ map[string]string) []string {
+			return []string{"-u", filepath.Join(dir, entry)}
+		},
+		encodeResponse: encodeJSONResponse,
+		syntaxMarkers:  []string{"SyntaxError"},
+	},
+	LangJavaScript: {
+		Lang:       LangJavaScript,
+		Persistent: true,
+		binary:     func(cfg *Config) string { return cfg.NodePath },
+		files: func(code string) (map[string]string, string) {
+			return map[string]string{"main.js": bridgeJavaScript + "\n" + code}, "main.js"
+		},
+		args: func(dir, entry string, _ map[string]string) []string {
+			return []string{filepath.Join(dir, entry)}
+		},
+		env: func(cfg *Config, _ string) []string {
+			return []string{fmt.Sprintf("NODE_OPTIONS=--max-old-space-size=%d", cfg.Sandbox.MemoryMB)}
+		},
+		encodeResponse: encodeJSONResponse,
+		syntaxMarkers:  []string{"SyntaxError"},
+	},
+	LangShell: {
+		Lang:   LangShell,
+		binary: func(cfg *Config) string { return cfg.ShellPath },
+		files: func(code string) (map[string]string, string) {
+			return map[string]string{"main.sh": bridgeShell + "\n" + code}, "main.sh"
+		},
+		args: func(dir, entry string, _ map[string]string) []string {
+			return []string{filepath.Join(dir, entry)}
+		},
+		encodeResponse: encodeShellResponse,
+		syntaxMarkers:  []string{"syntax error"},
+	},
+	LangGo: {
+		Lang:   LangGo,
+		binary: func(cfg *Config) string { return cfg.GoPath },
+		files: func(code string) (map[string]string, string) {
+			return map[string]string{"main.go": code, "zz_bridge.go": bridgeGo}, "main.go"
+		},
+		args: func(dir, entry string, files map[string]string) []string {
+			args := []string{"run"}
+			for _, name := range sortedFileNames(files) {
+				args = append(args, filepath.Join(dir, name))
+			}
+			return args
+		},
+		// 沙箱内编译缓存位于私有 /tmp，每次执行需冷编译标准库（sandbox.tmpfs_size_mb 建议 ≥ 256）
+		env: func(cfg *Config, workDir string) []string {
+			return []string{
+				"GOCACHE=" + filepath.Join(workDir, ".gocache"),
+				"GOPATH=" + filepath.Join(workDir, ".gopath"),
+				"GOTOOLCHAIN=local",
+				"CGO_ENABLED=0",
+				fmt.Sprintf("GOMEMLIMIT=%dMiB", cfg.Sandbox.MemoryMB),
+			}
+		},
+		encodeResponse: encodeJSONResponse,
+		// go run 编译失败时输出包名行（如 "# command-line-arguments"）
+		syntaxMarkers: []string{"# command-line-arguments"},
+	},
+}
+
+// languageAliases 语言名别名
+var languageAliases = map[string]string{
+	"":             LangPython,
+	"py":           LangPython,
+	"python3":      LangPython,
+	"js":           LangJavaScript,
+	"node":         LangJavaScript,
+	"nodejs":       LangJavaScript,
+	"sh":           LangShell,
+	"bash":         LangShell,
+	"golang":       LangGo,
+	LangPython:     LangPython,
+	LangShell:      LangShell,
+	LangGo:         LangGo,
+	LangJavaScript: LangJavaScript,
+}
+
+// lookupRuntime 按语言名（含别名）查找运行时
+func lookupRuntime(language string) (*codeRuntime, error) {
+	lang, ok := languageAliases[strings.ToLower(strings.TrimSpace(language))]
+	if !ok {
+		return nil, fmt.Errorf("不支持的语言 %q（支持 python/javascript/shell/go）", language)
+	}
+	return codeRuntimes[lang], nil
+}
+
+// AvailableLanguages 返回本机可用的语言（python 为必需项，总是包含）
+func AvailableLanguages(cfg *Config) []string {
+	langs := []string{LangPython}
+	for _, lang := range []string{LangJavaScript, LangShell, LangGo} {
+		if _, err := exec.LookPath(codeRuntimes[lang].binary(cfg)); err == nil {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// goBuildCacheDir 非沙箱模式下 go run 复用的编译缓存目录
+func goBuildCacheDir() string {
+	return filepath.Join(os.TempDir(), "execute-code-go")
+}
+
+func sortedFileNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ========================= 沙箱 =========================
+//
+// Linux 下代码子进程（python/node/bash/go）经由本程序的 sandbox-init 模式启动：
+//   父进程  → 创建 user/mount/pid/ipc/uts（及 net）namespace，并放入独立 cgroup
+//   init    → 只读 rbind 根目录、私有 tmpfs /tmp、遮蔽敏感目录、pivot_root、
+//             设置 rlimit、清空 capability、安装 seccomp，最后 exec 解释器
+// 超限由父进程根据 cgroup 事件 / 退出信号归类为不同的 ErrorType。
+
+// 沙箱模式
+const (
+	SandboxModeLinux = "linux"
+	SandboxModeNone  = "none"
+)
+
+// 沙箱相关的 ErrorType
+const (
+	ErrTypeMemoryLimit = "memory_limit"      // 超出内存上限（cgroup OOM / MemoryError）
+	ErrTypePidsLimit   = "pids_limit"        // 超出进程数上限（fork 炸弹）
+	ErrTypeCPULimit    = "cpu_limit"         // 超出 CPU 时间上限（SIGXCPU）
+	ErrTypeSeccomp     = "seccomp_violation" // 调用了被禁止的系统调用（SIGSYS）
+	ErrTypeSandbox     = "sandbox_error"     // 沙箱初始化失败
+)
+
+// sandboxInitArg 以该参数启动时进入 sandbox-init 模式（仅供内部 re-exec 使用）
+const sandboxInitArg = "__sandbox_init__"
+
+// sandboxSetupExitCode sandbox-init 初始化失败时的退出码
+const sandboxSetupExitCode = 125
+
+// sandboxWorkDir 沙箱内的工作目录（私有 tmpfs）
+const sandboxWorkDir = "/tmp"
+
+// sandboxSpec 父进程传给 sandbox-init 的参数
+type sandboxSpec struct {
+	Argv           []string `json:"argv"`            // 解释器/工具链命令，argv[0] 为绝对路径
+	TmpfsSizeMB    int      `json:"tmpfs_size_mb"`   // /tmp 大小
+	HidePaths      []string `json:"hide_paths"`      // 遮蔽的路径
+	CPUTimeSec     int      `json:"cpu_time_sec"`    // RLIMIT_CPU
+	MaxFileSizeMB  int      `json:"max_file_size"`   // RLIMIT_FSIZE
+	MemoryRlimMB   int      `json:"memory_rlim_mb"`  // cgroup 不可用时的 RLIMIT_AS
+	PidsRlim       int      `json:"pids_rlim"`       // cgroup 不可用时的 RLIMIT_NPROC
+	NoNetwork      bool     `json:"no_network"`      // 是否处于独立 network namespace
+	DisableSeccomp bool     `json:"disable_seccomp"` // 当前架构无系统调用表时跳过 seccomp
+}
+
+// MaybeRunSandboxInit 若以 sandbox-init 模式启动则执行初始化并运行代码，不会返回
+func MaybeRunSandboxInit() {
+	if len(os.Args) < 3 || os.Args[1] != sandboxInitArg {
+		return
+	}
+	runSandboxInit(os.Args[2])
+}
+
+// sandboxEnv 按白名单构造子进程环境变量
+func sandboxEnv(allowlist []string, sandboxed bool) []string {
+	allowed := make(map[string]bool, len(allowlist))
+	for _, name := range allowlist {
+		allowed[strings.ToUpper(name)] = true
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, ok := strings.Cut(kv, "=")
+		if !ok || !allowed[strings.ToUpper(name)] {
+			continue
+		}
+		if sandboxed && (strings.EqualFold(name, "TEMP") || strings.EqualFold(name, "TMP")) {
+			continue
+		}
+		env = append(env, kv)
+	}
+	if sandboxed {
+		env = append(env, "HOME="+sandboxWorkDir, "TMPDIR="+sandboxWorkDir)
+	}
+	// 确保 stdin/stdout/stderr 使用 UTF-8
+	return append(env, "PYTHONIOENCODING=utf-8", "PYTHONUTF8=1")
+}
+
+// resolvePythonExecutable 获取 Python 真实路径（pyenv 等 shim 在沙箱内不可用）
+func resolvePythonExecutable(pythonPath string) (string, error) {
+	out, err := exec.Command(pythonPath, "-c", "import sys; print(sys.executable)").Output()
+	if err != nil {
+		return "", fmt.Errorf("无法获取 %s 的真实路径: %v", pythonPath, err)
+	}
+	path := strings.TrimSpace(string(out))
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("python 路径不是绝对路径: %q", path)
+	}
+	return path, nil
+}
+
+// resolveBinary 获取解释器真实路径（沙箱内只能按绝对路径 exec）
+func resolveBinary(name string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path, nil
+}
+
+// effectiveHidePaths 规范化遮蔽路径，跳过包含解释器本身的目录
+func effectiveHidePaths(paths []string, bins []string) ([]string, []string) {
+	var hide, skipped []string
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		if p == "" || !filepath.IsAbs(p) {
+			continue
+		}
+		p = filepath.Clean(p)
+		// 工作目录与 /proc 由沙箱自行挂载
+		if p == "/" || p == sandboxWorkDir || p == "/proc" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if containsBinary(p, bins) {
+			skipped = append(skipped, p)
+			continue
+		}
+		hide = append(hide, p)
+	}
+	return hide, skipped
+}
+
+func containsBinary(dir string, bins []string) bool {
+	for _, bin := range bins {
+		if bin == dir || strings.HasPrefix(bin, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyStderrViolation 未使用 cgroup 时按解释器报错识别资源超限
+func classifyStderrViolation(stderr string) string {
+	switch {
+	case strings.Contains(stderr, "MemoryError"),
+		strings.Contains(stderr, "JavaScript heap out of memory"),
+		strings.Contains(stderr, "fatal error: out of memory"):
+		return ErrTypeMemoryLimit
+	case strings.Contains(stderr, "BlockingIOError") && strings.Contains(stderr, "Resource temporarily unavailable"):
+		return ErrTypePidsLimit
+	}
+	return ""
+}
+
+// ProbeSandbox 校验 sandbox.mode：linux 试运行一次，沙箱不可用时返回错误（调用方拒绝启动），
+// 只有显式配置 none 才无隔离执行
+func ProbeSandbox(cfg *Config) error {
+	mode := cfg.Sandbox.Mode
+	if mode == SandboxModeNone {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("sandbox.mode=%s 仅支持 Linux（当前 %s），确需无隔离执行请显式配置 sandbox.mode=none", mode, runtime.GOOS)
+	}
+
+	result := NewExecutor(cfg).Execute("print('ok')")
+	if result.Success && strings.TrimSpace(result.Stdout) == "ok" {
+		return nil
+	}
+
+	// 失败即拒绝：不静默退化为无隔离执行
+	return fmt.Errorf("沙箱试运行失败 error_type=%s exit_code=%d stderr=%s（确需无隔离执行请显式配置 sandbox.mode=none）",
+		result.ErrorType, result.ExitCode, truncate(strings.TrimSpace(result.Stderr), 500))
+}
@@ -0,0 +1,591 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// ========================= 父进程：命名空间与 cgroup =========================
+
+// sandboxRunner Linux 沙箱启动器
+type sandboxRunner struct {
+	cfg          *Config
+	bins         map[string]string // 语言 → 解释器绝对路径（未安装的语言缺省）
+	hide         []string
+	uid, gid     int
+	cgroupParent string // 为空表示 cgroup 不可用，使用 rlimit 兜底
+	seq          atomic.Int64
+	warnOnce     sync.Once
+}
+
+// sandboxRun 单次执行占用的资源
+type sandboxRun struct {
+	bundle   *os.File // 代码文件包（已 unlink 的临时文件，以 fd 3 传给 sandbox-init）
+	cgroup   string
+	cgroupFD *os.File
+}
+
+func newSandboxRunner(cfg *Config) (*sandboxRunner, error) {
+	python, err := resolvePythonExecutable(cfg.PythonPath)
+	if err != nil {
+		return nil, err
+	}
+	bins := map[string]string{LangPython: python}
+	for lang, rt := range codeRuntimes {
+		if lang == LangPython {
+			continue
+		}
+		if path, err := resolveBinary(rt.binary(cfg)); err == nil {
+			bins[lang] = path
+		}
+	}
+	binList := make([]string, 0, len(bins))
+	for _, path := range bins {
+		binList = append(binList, path)
+	}
+	hide, skipped := effectiveHidePaths(cfg.Sandbox.HidePaths, binList)
+	if len(skipped) > 0 {
+		log.Printf("[Sandbox] hide_paths %v 包含解释器 %v，不遮蔽", skipped, binList)
+	}
+
+	s := &sandboxRunner{cfg: cfg, bins: bins, hide: hide, uid: cfg.Sandbox.UID, gid: cfg.Sandbox.GID}
+	if s.uid < 0 {
+		s.uid = os.Getuid()
+		if s.uid == 0 {
+			s.uid = 65534
+		}
+	}
+	if s.gid < 0 {
+		s.gid = os.Getgid()
+		if s.gid == 0 {
+			s.gid = 65534
+		}
+	}
+	if err := prepareCgroupParent(cfg.Sandbox.CgroupParent); err != nil {
+		log.Printf("[Sandbox] cgroup v2 不可用，内存/进程数改用 rlimit 限制: %v", err)
+	} else {
+		s.cgroupParent = cfg.Sandbox.CgroupParent
+	}
+	log.Printf("[Sandbox] enabled bins=%v uid=%d gid=%d network=%v cgroup=%q hide=%v",
+		bins, s.uid, s.gid, cfg.Sandbox.AllowNetwork, s.cgroupParent, hide)
+	return s, nil
+}
+
+// prepareCgroupParent 确认父 cgroup 存在并为子 cgroup 启用 memory/pids/cpu 控制器
+func prepareCgroupParent(parent string) error {
+	if parent == "" {
+		return fmt.Errorf("cgroup_parent 未配置")
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	controllers, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+	for _, c := range []string{"memory", "pids"} {
+		if !containsField(string(controllers), c) {
+			return fmt.Errorf("%s 未委派 %s 控制器", parent, c)
+		}
+	}
+	subtree := "+memory +pids"
+	if containsField(string(controllers), "cpu") {
+		subtree += " +cpu"
+	}
+	return os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(subtree), 0644)
+}
+
+func containsField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// command 构造 sandbox-init 子进程：files 写入沙箱 /tmp 后以 rt 对应的解释器运行 entry
+func (s *sandboxRunner) command(ctx context.Context, rt *codeRuntime, files map[string]string, entry string, env []string) (*exec.Cmd, *sandboxRun, error) {
+	sb := s.cfg.Sandbox
+	bin, ok := s.bins[rt.Lang]
+	if !ok {
+		return nil, nil, fmt.Errorf("未找到 %s 解释器 %q", rt.Lang, rt.binary(s.cfg))
+	}
+	bundle, err := writeFileBundle(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	run := &sandboxRun{bundle: bundle}
+
+	spec := sandboxSpec{
+		Argv:           append([]string{bin}, rt.args(sandboxWorkDir, entry, files)...),
+		TmpfsSizeMB:    sb.TmpfsSizeMB,
+		HidePaths:      s.hide,
+		CPUTimeSec:     sb.CPUTimeSec,
+		MaxFileSizeMB:  sb.MaxFileSizeMB,
+		NoNetwork:      !sb.AllowNetwork,
+		DisableSeccomp: seccompDenylist() == nil,
+	}
+	if spec.CPUTimeSec <= 0 {
+		spec.CPUTimeSec = s.cfg.MaxExecTimeSec
+	}
+	if s.cgroupParent != "" {
+		if err := s.createCgroup(run); err != nil {
+			s.warnOnce.Do(func() { log.Printf("[Sandbox] 创建 cgroup 失败，改用 rlimit: %v", err) })
+		}
+	}
+	if run.cgroup == "" {
+		if rt.RlimitAS {
+			spec.MemoryRlimMB = sb.MemoryMB
+		}
+		spec.PidsRlim = sb.MaxPids
+	}
+	if rt.env != nil {
+		env = append(env, rt.env(s.cfg, sandboxWorkDir)...)
+	}
+	specData, _ := json.Marshal(spec)
+
+	cloneFlags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS)
+	if spec.NoNetwork {
+		cloneFlags |= syscall.CLONE_NEWNET
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", sandboxInitArg, string(specData))
+	cmd.Env = env
+	cmd.ExtraFiles = []*os.File{bundle}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 cloneFlags,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: s.uid, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: s.gid, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		// 切换为命名空间内的 root（即宿主的 uid/gid），否则以 root 运行 agent 时子进程身份未映射、没有 capability
+		Credential: &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true},
+		Pdeathsig:  syscall.SIGKILL,
+	}
+	if run.cgroupFD != nil {
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(run.cgroupFD.Fd())
+	}
+	return cmd, run, nil
+}
+
+// writeFileBundle 将代码文件打包为 JSON 写入已删除的临时文件，避免宿主上残留代码
+func writeFileBundle(files map[string]string) (*os.File, error) {
+	f, err := os.CreateTemp("", "exec_bundle_*")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+	if err := json.NewEncoder(f).Encode(files); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// createCgroup 为本次执行创建子 cgroup 并写入限制
+func (s *sandboxRunner) createCgroup(run *sandboxRun) error {
+	sb := s.cfg.Sandbox
+	dir := filepath.Join(s.cgroupParent, fmt.Sprintf("exec-%d-%d", os.Getpid(), s.seq.Add(1)))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	limits := []struct {
+		file, value string
+		required    bool
+	}{
+		{"memory.max", strconv.Itoa(sb.MemoryMB << 20), true},
+		{"memory.swap.max", "0", false},
+		{"pids.max", strconv.Itoa(sb.MaxPids), true},
+		{"cpu.max", fmt.Sprintf("%d 100000", sb.CPUPercent*1000), false},
+	}
+	for _, l := range limits {
+		if err := os.WriteFile(filepath.Join(dir, l.file), []byte(l.value), 0644); err != nil && l.required {
+			os.Remove(dir)
+			return err
+		}
+	}
+	fd, err := os.Open(dir)
+	if err != nil {
+		os.Remove(dir)
+		return err
+	}
+	run.cgroup, run.cgroupFD = dir, fd
+	return nil
+}
+
+// violation 根据 cgroup 事件、退出码识别资源超限
+func (r *sandboxRun) violation(state *os.ProcessState, stderr string) string {
+	if state != nil {
+		switch state.ExitCode() {
+		case 128 + int(syscall.SIGSYS):
+			return ErrTypeSeccomp
+		case 128 + int(syscall.SIGXCPU):
+			return ErrTypeCPULimit
+		}
+	}
+	if r.cgroup != "" {
+		if cgroupEventCount(filepath.Join(r.cgroup, "memory.events"), "oom_kill") > 0 {
+			return ErrTypeMemoryLimit
+		}
+		if cgroupEventCount(filepath.Join(r.cgroup, "pids.events"), "max") > 0 {
+			return ErrTypePidsLimit
+		}
+		return ""
+	}
+	return classifyStderrViolation(stderr)
+}
+
+func cgroupEventCount(path, key string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, value, ok := strings.Cut(line, " "); ok && name == key {
+			n, _ := strconv.Atoi(strings.TrimSpace(value))
+			return n
+		}
+	}
+	return 0
+}
+
+// cleanup 释放代码文件与 cgroup
+func (r *sandboxRun) cleanup() {
+	if r.bundle != nil {
+		r.bundle.Close()
+	}
+	if r.cgroupFD != nil {
+		r.cgroupFD.Close()
+	}
+	if r.cgroup != "" {
+		// 进程已退出，cgroup 为空即可删除
+		if err := os.Remove(r.cgroup); err != nil {
+			log.Printf("[Sandbox] remove cgroup %s failed: %v", r.cgroup, err)
+		}
+	}
+}
+
+// ========================= sandbox-init：命名空间内的 pid 1 =========================
+
+// runSandboxInit 在新命名空间内搭建文件系统与限制，然后以子进程运行解释器并等待其退出。
+// 解释器以信号结束时以 128+信号值 退出，便于父进程识别。
+func runSandboxInit(specJSON string) {
+	// seccomp/no_new_privs 作用于线程，后续 fork 必须在同一线程上
+	runtime.LockOSThread()
+
+	fail := func(step string, err error) {
+		fmt.Fprintf(os.Stderr, "sandbox: %s: %v\n", step, err)
+		os.Exit(sandboxSetupExitCode)
+	}
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(specJSON), &spec); err != nil {
+		fail("parse spec", err)
+	}
+	if len(spec.Argv) == 0 {
+		fail("parse spec", fmt.Errorf("empty argv"))
+	}
+	bundle := os.NewFile(3, "bundle")
+	var files map[string]string
+	if err := json.NewDecoder(bundle).Decode(&files); err != nil {
+		fail("read code", err)
+	}
+	bundle.Close()
+
+	if err := setupSandboxFS(&spec, files); err != nil {
+		fail("mount", err)
+	}
+	syscall.Sethostname([]byte("sandbox"))
+	if err := setSandboxRlimits(&spec); err != nil {
+		fail("rlimit", err)
+	}
+	if err := dropCapabilities(); err != nil {
+		fail("capabilities", err)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		fail("no_new_privs", errno)
+	}
+	if !spec.DisableSeccomp {
+		if err := installSeccomp(seccompDenylist()); err != nil {
+			fail("seccomp", err)
+		}
+	}
+
+	pid, err := syscall.ForkExec(spec.Argv[0], spec.Argv, &syscall.ProcAttr{
+		Dir:   sandboxWorkDir,
+		Env:   os.Environ(),
+		Files: []uintptr{0, 1, 2},
+	})
+	if err != nil {
+		fail("exec "+filepath.Base(spec.Argv[0]), err)
+	}
+
+	// 作为 pid 1 回收孤儿进程；解释器退出后立即退出（命名空间内其余进程随之被杀）
+	for {
+		var ws syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			fail("wait", err)
+		}
+		if wpid != pid {
+			continue
+		}
+		if ws.Signaled() {
+			os.Exit(128 + int(ws.Signal()))
+		}
+		os.Exit(ws.ExitStatus())
+	}
+}
+
+// setupSandboxFS 只读根 + 私有 /tmp + 遮蔽目录 + 新 /proc，然后 pivot_root
+func setupSandboxFS(spec *sandboxSpec, files map[string]string) error {
+	// 暂存区：在私有挂载命名空间中覆盖 /tmp，不影响宿主
+	const stage = "/tmp"
+	newRoot := stage + "/root"
+
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make-rprivate: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", stage, "tmpfs", 0, "size=1m,mode=0755"); err != nil {
+		return fmt.Errorf("stage tmpfs: %w", err)
+	}
+	if err := os.Mkdir(newRoot, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("/", newRoot, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("rbind root: %w", err)
+	}
+	// /tmp、/proc 与遮蔽路径随后会被覆盖挂载，其下的子挂载无需（也常常无法）改为只读
+	covered := append([]string{sandboxWorkDir, "/proc"}, spec.HidePaths...)
+	if err := remountReadOnly(newRoot, covered); err != nil {
+		return err
+	}
+
+	// 私有工作目录
+	workDir := newRoot + sandboxWorkDir
+	opts := fmt.Sprintf("size=%dm,mode=1777", spec.TmpfsSizeMB)
+	if err := syscall.Mount("tmpfs", workDir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, opts); err != nil {
+		return fmt.Errorf("work tmpfs: %w", err)
+	}
+	for name, content := range files {
+		if name != filepath.Base(name) || name == "." || name == ".." {
+			return fmt.Errorf("invalid file name %q", name)
+		}
+		if err := os.WriteFile(workDir+"/"+name, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	// 遮蔽敏感路径
+	for _, p := range spec.HidePaths {
+		target := newRoot + p
+		info, err := os.Lstat(target)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			err = syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, "size=4k,mode=0755")
+		} else if info.Mode().IsRegular() {
+			err = syscall.Mount("/dev/null", target, "", syscall.MS_BIND, "")
+		}
+		if err != nil {
+			return fmt.Errorf("hide %s: %w", p, err)
+		}
+	}
+
+	// 新 pid namespace 对应的 /proc；不允许挂载时用空目录遮蔽宿主 /proc
+	procFlags := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC)
+	if err := syscall.Mount("proc", newRoot+"/proc", "proc", procFlags, ""); err != nil {
+		if err := syscall.Mount("tmpfs", newRoot+"/proc", "tmpfs", procFlags|syscall.MS_RDONLY, "size=4k"); err != nil {
+			return fmt.Errorf("mask /proc: %w", err)
+		}
+	}
+
+	if err := os.Chdir(newRoot); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+	return os.Chdir(sandboxWorkDir)
+}
+
+// remountReadOnly 将 root 及其下所有挂载点重新挂载为只读（保留被锁定的 nosuid/nodev/noexec 等标志）；
+// 任一挂载点失败都返回错误，避免代码获得可写的宿主子挂载。covered 为随后会被覆盖挂载的路径（相对 root）
+func remountReadOnly(root string, covered []string) error {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		mp := unescapeMountPath(fields[4])
+		if mp != root && !strings.HasPrefix(mp, root+"/") {
+			continue
+		}
+		if underAny(strings.TrimPrefix(mp, root), covered) {
+			continue
+		}
+		flags := uintptr(syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY) | mountOptFlags(fields[5])
+		if err := syscall.Mount("", mp, "", flags, ""); err != nil {
+			return fmt.Errorf("remount %s read-only: %w", mp, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// underAny p 是否等于或位于 dirs 中任一路径之下
+func underAny(p string, dirs []string) bool {
+	for _, d := range dirs {
+		d = strings.TrimRight(d, "/")
+		if d != "" && (p == d || strings.HasPrefix(p, d+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// mountOptFlags mountinfo 中的单挂载点选项 → MS_* 标志
+func mountOptFlags(opts string) uintptr {
+	var flags uintptr
+	for _, o := range strings.Split(opts, ",") {
+		switch o {
+		case "nosuid":
+			flags |= syscall.MS_NOSUID
+		case "nodev":
+			flags |= syscall.MS_NODEV
+		case "noexec":
+			flags |= syscall.MS_NOEXEC
+		case "noatime":
+			flags |= syscall.MS_NOATIME
+		case "nodiratime":
+			flags |= syscall.MS_NODIRATIME
+		case "relatime":
+			flags |= syscall.MS_RELATIME
+		case "strictatime":
+			flags |= syscall.MS_STRICTATIME
+		}
+	}
+	return flags
+}
+
+// unescapeMountPath 还原 mountinfo 中的八进制转义（如 \040 为空格）
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// RLIMIT_NPROC 按宿主真实 UID 计数：user namespace 内的 root 映射回运行 agent 的宿主用户，
+// 该用户名下已有的全部进程（agent 自身、并发的其他沙箱、同用户的其他服务）都会占用这个额度。
+// 因此 cgroup 不可用时 max_pids 只是粗略兜底，应以专用系统用户运行 execute-code-agent。
+const rlimitNproc = 6 // RLIMIT_NPROC
+
+// setSandboxRlimits 设置 CPU 时间、文件大小等 rlimit；cgroup 不可用时兜底限制内存与进程数
+func setSandboxRlimits(spec *sandboxSpec) error {
+	limits := []struct {
+		resource int
+		cur, max uint64
+	}{
+		{syscall.RLIMIT_CPU, uint64(spec.CPUTimeSec), uint64(spec.CPUTimeSec) + 5},
+		{syscall.RLIMIT_FSIZE, uint64(spec.MaxFileSizeMB) << 20, uint64(spec.MaxFileSizeMB) << 20},
+		{syscall.RLIMIT_CORE, 0, 0},
+	}
+	if spec.MemoryRlimMB > 0 {
+		n := uint64(spec.MemoryRlimMB) << 20
+		limits = append(limits, struct {
+			resource int
+			cur, max uint64
+		}{syscall.RLIMIT_AS, n, n})
+	}
+	if spec.PidsRlim > 0 {
+		n := uint64(spec.PidsRlim)
+		limits = append(limits, struct {
+			resource int
+			cur, max uint64
+		}{rlimitNproc, n, n})
+	}
+	for _, l := range limits {
+		var cur syscall.Rlimit
+		if err := syscall.Getrlimit(l.resource, &cur); err != nil {
+			return err
+		}
+		lim := syscall.Rlimit{Cur: min(l.cur, cur.Max), Max: min(l.max, cur.Max)}
+		if err := syscall.Setrlimit(l.resource, &lim); err != nil {
+			return fmt.Errorf("resource %d: %w", l.resource, err)
+		}
+	}
+	return nil
+}
+
+const (
+	prCapbsetDrop   = 24
+	prSetNoNewPrivs = 38
+	linuxCapV3      = 0x20080522
+)
+
+// dropCapabilities 清空 bounding/permitted/inheritable，解释器以无 capability 的 root 运行
+func dropCapabilities() error {
+	for c := uintptr(0); c < 64; c++ {
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapbsetDrop, c, 0); errno == syscall.EINVAL {
+			break
+		} else if errno != 0 {
+			return fmt.Errorf("capbset drop %d: %w", c, errno)
+		}
+	}
+	hdr := struct {
+		version uint32
+		pid     int32
+	}{version: linuxCapV3}
+	var data [2]struct{ effective, permitted, inheritable uint32 }
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capset: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestMain 沙箱通过 re-exec 当前二进制（测试时为测试程序）进入 sandbox-init
+func TestMain(m *testing.M) {
+	MaybeRunSandboxInit()
+	os.Exit(m.Run())
+}
+
+func newSandboxTestExecutor(t *testing.T, tweak func(cfg *Config)) *Executor {
+	t.Helper()
+	python, err := exec.LookPath("/usr/bin/python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	if err := exec.Command("unshare", "-Ur", "true").Run(); err != nil {
+		t.Skip("user namespaces unavailable")
+	}
+	cfg := DefaultConfig()
+	cfg.PythonPath = python
+	cfg.MaxExecTimeSec = 20
+	cfg.Sandbox.Mode = SandboxModeLinux
+	cfg.Sandbox.CgroupParent = ""
+	cfg.Sandbox.CPUTimeSec = 10
+	if tweak != nil {
+		tweak(cfg)
+	}
+	e := NewExecutor(cfg)
+	if e.sandboxErr != nil {
+		t.Skipf("sandbox unavailable: %v", e.sandboxErr)
+	}
+	if r := e.Execute("print('ok')"); !r.Success {
+		t.Skipf("sandbox probe failed: %s %s", r.ErrorType, r.Stderr)
+	}
+	return e
+}
+
+func TestSandboxIsolation(t *testing.T) {
+	secretDir := t.TempDir()
+	os.WriteFile(secretDir+"/token", []byte("secret"), 0644)
+	t.Setenv("EXEC_SANDBOX_SECRET", "leak")
+
+	e := newSandboxTestExecutor(t, func(cfg *Config) {
+		cfg.Sandbox.HidePaths = append(cfg.Sandbox.HidePaths, secretDir)
+	})
+
+	code := `
+import os, socket
+print("ppid", os.getppid())
+print("env", os.environ.get("EXEC_SANDBOX_SECRET"))
+print("hidden", os.path.exists(` + "\"" + secretDir + "/token\"" + `))
+try:
+    open("/usr/sandbox_probe", "w")
+    print("rootfs writable")
+except OSError:
+    print("rootfs readonly")
+open("/tmp/scratch.txt", "w").write("x")
+print("tmp", open("/tmp/scratch.txt").read())
+try:
+    socket.create_connection(("1.1.1.1", 53), timeout=2)
+    print("net ok")
+except OSError:
+    print("net blocked")
+`
+	r := e.Execute(code)
+	if !r.Success {
+		t.Fatalf("execution failed: %s %s", r.ErrorType, r.Stderr)
+	}
+	for _, want := range []string{"ppid 1", "env None", "hidden False", "rootfs readonly", "tmp x", "net blocked"} {
+		if !strings.Contains(r.Stdout, want) {
+			t.Fatalf("stdout missing %q:\n%s", want, r.Stdout)
+		}
+	}
+}
+
+func TestSandboxViolations(t *testing.T) {
+	e := newSandboxTestExecutor(t, func(cfg *Config) {
+		cfg.Sandbox.MemoryMB = 256
+		cfg.Sandbox.CPUTimeSec = 1
+	})
+
+	mountNr := seccompSyscallTables[runtime.GOARCH].nrs["mount"]
+	cases := []struct {
+		name, code, want string
+	}{
+		{"seccomp", fmt.Sprintf("import ctypes\nctypes.CDLL(None).syscall(%d, 0, 0, 0, 0, 0)", mountNr), ErrTypeSeccomp},
+		{"memory", "x = bytearray(1024 * 1024 * 1024)", ErrTypeMemoryLimit},
+		{"cpu", "while True:\n    pass", ErrTypeCPULimit},
+		{"pids", "import os, time\nfor _ in range(200):\n    if os.fork() == 0:\n        time.sleep(30)", ErrTypePidsLimit},
+		{"syntax", "def (", "syntax"},
+	}
+	for _, c := range cases {
+		if c.name == "seccomp" && mountNr == 0 {
+			continue
+		}
+		r := e.Execute(c.code)
+		if r.Success || r.ErrorType != c.want {
+			t.Fatalf("%s: success=%v error_type=%q exit=%d stderr=%s", c.name, r.Success, r.ErrorType, r.ExitCode, r.Stderr)
+		}
+	}
+}
+
+func TestSandboxRuntimesAndKernel(t *testing.T) {
+	e := newSandboxTestExecutor(t, func(cfg *Config) {
+		cfg.MaxExecTimeSec = 120
+		cfg.Sandbox.CPUTimeSec = 120
+		cfg.Sandbox.TmpfsSizeMB = 256
+	})
+	e.callTool = func(toolName string, args json.RawMessage) (string, string, error) {
+		return `{"ok":true}`, "mock-agent", nil
+	}
+	t.Cleanup(e.kernels.CloseAll)
+
+	cases := map[string]string{
+		LangJavaScript: "console.log(require('os').hostname(), callTool('Echo', {}).ok)",
+		LangShell:      "echo $(hostname) $(call_tool Echo)",
+		LangGo:         "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc main() {\n\th, _ := os.Hostname()\n\tr, _ := CallTool(\"Echo\", nil)\n\tfmt.Println(h, r.(map[string]any)[\"ok\"])\n}",
+	}
+	for lang, code := range cases {
+		t.Run(lang, func(t *testing.T) {
+			if _, ok := e.sandbox.bins[lang]; !ok {
+				t.Skipf("%s runtime not installed", lang)
+			}
+			r := e.Run(ExecRequest{Language: lang, Code: code})
+			if !r.Success {
+				t.Fatalf("failed: %s %s", r.ErrorType, r.Stderr)
+			}
+			if out := strings.TrimSpace(r.Stdout); out != "sandbox true" && out != `sandbox {"ok":true}` {
+				t.Fatalf("stdout = %q", out)
+			}
+		})
+	}
+
+	// 会话内核同样运行在沙箱中
+	r := e.Run(ExecRequest{Language: LangPython, Code: "import socket\nh = socket.gethostname()", SessionID: "t"})
+	if !r.Success {
+		t.Fatalf("kernel define failed: %s %s", r.ErrorType, r.Stderr)
+	}
+	r = e.Run(ExecRequest{Language: LangPython, Code: "print(h)", SessionID: "t"})
+	if strings.TrimSpace(r.Stdout) != "sandbox" || r.SessionNew {
+		t.Fatalf("kernel state: new=%v stdout=%q stderr=%s", r.SessionNew, r.Stdout, r.Stderr)
+	}
+}
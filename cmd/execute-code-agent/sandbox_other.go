@@ -0,0 +1,33 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// 非 Linux 平台不支持沙箱，需显式配置 sandbox.mode=none，否则拒绝启动
+
+type sandboxRunner struct{}
+
+type sandboxRun struct{}
+
+func newSandboxRunner(cfg *Config) (*sandboxRunner, error) {
+	return nil, fmt.Errorf("沙箱仅支持 Linux")
+}
+
+func (s *sandboxRunner) command(ctx context.Context, rt *codeRuntime, files map[string]string, entry string, env []string) (*exec.Cmd, *sandboxRun, error) {
+	return nil, nil, fmt.Errorf("沙箱仅支持 Linux")
+}
+
+func (r *sandboxRun) violation(state *os.ProcessState, stderr string) string { return "" }
+
+func (r *sandboxRun) cleanup() {}
+
+func runSandboxInit(specJSON string) {
+	fmt.Fprintln(os.Stderr, "sandbox: 仅支持 Linux")
+	os.Exit(sandboxSetupExitCode)
+}
@@ -0,0 +1,127 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// ========================= seccomp =========================
+//
+// 黑名单过滤：命中即 SECCOMP_RET_KILL_PROCESS（父进程看到 SIGSYS → seccomp_violation），
+// 其余系统调用放行。架构不匹配（如 x32、32 位 ABI）同样直接杀死。
+
+const (
+	prSetSeccomp       = 22
+	seccompModeFilter  = 2
+	seccompRetKillProc = 0x80000000
+	seccompRetAllow    = 0x7fff0000
+
+	auditArchX86_64  = 0xc000003e
+	auditArchAarch64 = 0xc00000b7
+	x32SyscallBit    = 0x40000000
+)
+
+// 被禁止的系统调用：挂载/命名空间、内核模块、调试其他进程、BPF、时钟、重启等
+var seccompDenyNames = []string{
+	"mount", "umount2", "pivot_root", "chroot", "unshare", "setns",
+	"open_tree", "move_mount", "fsopen", "fsconfig", "fsmount", "fspick", "mount_setattr",
+	"ptrace", "process_vm_readv", "process_vm_writev", "kcmp",
+	"init_module", "finit_module", "delete_module", "kexec_load", "kexec_file_load",
+	"bpf", "perf_event_open", "userfaultfd", "keyctl", "add_key", "request_key",
+	"open_by_handle_at", "name_to_handle_at", "fanotify_init", "lookup_dcookie",
+	"swapon", "swapoff", "reboot", "acct", "quotactl", "syslog", "vhangup",
+	"sethostname", "setdomainname", "settimeofday", "clock_settime", "clock_adjtime", "adjtimex",
+	"iopl", "ioperm",
+}
+
+// 各架构的系统调用号
+var seccompSyscallTables = map[string]struct {
+	arch uint32
+	nrs  map[string]uint32
+}{
+	"amd64": {auditArchX86_64, map[string]uint32{
+		"mount": 165, "umount2": 166, "pivot_root": 155, "chroot": 161, "unshare": 272, "setns": 308,
+		"open_tree": 428, "move_mount": 429, "fsopen": 430, "fsconfig": 431, "fsmount": 432, "fspick": 433, "mount_setattr": 442,
+		"ptrace": 101, "process_vm_readv": 310, "process_vm_writev": 311, "kcmp": 312,
+		"init_module": 175, "finit_module": 313, "delete_module": 176, "kexec_load": 246, "kexec_file_load": 320,
+		"bpf": 321, "perf_event_open": 298, "userfaultfd": 323, "keyctl": 250, "add_key": 248, "request_key": 249,
+		"open_by_handle_at": 304, "name_to_handle_at": 303, "fanotify_init": 300, "lookup_dcookie": 212,
+		"swapon": 167, "swapoff": 168, "reboot": 169, "acct": 163, "quotactl": 179, "syslog": 103, "vhangup": 153,
+		"sethostname": 170, "setdomainname": 171, "settimeofday": 164, "clock_settime": 227, "clock_adjtime": 305, "adjtimex": 159,
+		"iopl": 172, "ioperm": 173,
+	}},
+	"arm64": {auditArchAarch64, map[string]uint32{
+		"mount": 40, "umount2": 39, "pivot_root": 41, "chroot": 51, "unshare": 97, "setns": 268,
+		"open_tree": 428, "move_mount": 429, "fsopen": 430, "fsconfig": 431, "fsmount": 432, "fspick": 433, "mount_setattr": 442,
+		"ptrace": 117, "process_vm_readv": 270, "process_vm_writev": 271, "kcmp": 272,
+		"init_module": 105, "finit_module": 273, "delete_module": 106, "kexec_load": 104, "kexec_file_load": 294,
+		"bpf": 280, "perf_event_open": 241, "userfaultfd": 282, "keyctl": 219, "add_key": 217, "request_key": 218,
+		"open_by_handle_at": 265, "name_to_handle_at": 264, "fanotify_init": 262, "lookup_dcookie": 18,
+		"swapon": 224, "swapoff": 225, "reboot": 142, "acct": 89, "quotactl": 60, "syslog": 116, "vhangup": 58,
+		"sethostname": 161, "setdomainname": 162, "settimeofday": 170, "clock_settime": 112, "clock_adjtime": 266, "adjtimex": 171,
+	}},
+}
+
+// seccompFilter 当前架构的过滤规则
+type seccompFilter struct {
+	arch uint32
+	nrs  []uint32
+}
+
+// seccompDenylist 返回当前架构的黑名单；不支持的架构返回 nil
+func seccompDenylist() *seccompFilter {
+	table, ok := seccompSyscallTables[runtime.GOARCH]
+	if !ok {
+		return nil
+	}
+	f := &seccompFilter{arch: table.arch}
+	for _, name := range seccompDenyNames {
+		if nr, ok := table.nrs[name]; ok {
+			f.nrs = append(f.nrs, nr)
+		}
+	}
+	return f
+}
+
+// buildSeccompProgram 生成 BPF 程序
+func buildSeccompProgram(f *seccompFilter) []syscall.SockFilter {
+	const (
+		ldAbs = syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS
+		jeq   = syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K
+		jge   = syscall.BPF_JMP | syscall.BPF_JGE | syscall.BPF_K
+		ret   = syscall.BPF_RET | syscall.BPF_K
+	)
+	stmt := func(code uint16, k uint32) syscall.SockFilter { return syscall.SockFilter{Code: code, K: k} }
+	jump := func(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+		return syscall.SockFilter{Code: code, K: k, Jt: jt, Jf: jf}
+	}
+
+	// seccomp_data: nr 偏移 0，arch 偏移 4
+	prog := []syscall.SockFilter{
+		stmt(ldAbs, 4),
+		jump(jeq, f.arch, 1, 0),
+		stmt(ret, seccompRetKillProc),
+		stmt(ldAbs, 0),
+	}
+	if f.arch == auditArchX86_64 {
+		prog = append(prog, jump(jge, x32SyscallBit, 0, 1), stmt(ret, seccompRetKillProc))
+	}
+	for _, nr := range f.nrs {
+		prog = append(prog, jump(jeq, nr, 0, 1), stmt(ret, seccompRetKillProc))
+	}
+	return append(prog, stmt(ret, seccompRetAllow))
+}
+
+// installSeccomp 为当前线程安装过滤器（调用前需已设置 no_new_privs）
+func installSeccomp(f *seccompFilter) error {
+	prog := buildSeccompProgram(f)
+	fprog := syscall.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&fprog))); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_SECCOMP): %w", errno)
+	}
+	return nil
+}
@@ -1807,6 +1807,8 @@ class _ChatPageState extends State<ChatPage> {
   final List<CodingProjectInfo> _codingProjects = <CodingProjectInfo>[];
   final List<DeployProjectInfo> _deployProjects = <DeployProjectInfo>[];
   final Set<String> _seenMessageIds = <String>{};
+  final Map<String, Set<String>> _unreadMessageIdsByScope =
+      <String, Set<String>>{};
   final Set<String> _autoInstallTriggered = <String>{};
   final Set<String> _consumedCortanaReplyKeys = <String>{};
 
@@ -2534,6 +2536,7 @@ class _ChatPageState extends State<ChatPage> {
         _currentGroupId = '';
         _historyByScope.clear();
         _seenMessageIds.clear();
+        _unreadMessageIdsByScope.clear();
         _consumedCortanaReplyKeys.clear();
         _autoInstallTriggered.clear();
         _groups.clear();
@@ -2543,6 +2546,7 @@ class _ChatPageState extends State<ChatPage> {
       _currentGroupId = '';
       _historyByScope.clear();
       _seenMessageIds.clear();
+      _unreadMessageIdsByScope.clear();
       _consumedCortanaReplyKeys.clear();
       _autoInstallTriggered.clear();
       _groups.clear();
@@ -2577,6 +2581,7 @@ class _ChatPageState extends State<ChatPage> {
         _currentGroupId = '';
         _historyByScope.clear();
         _seenMessageIds.clear();
+        _unreadMessageIdsByScope.clear();
         _consumedCortanaReplyKeys.clear();
         _autoInstallTriggered.clear();
         _groups.clear();
@@ -2595,6 +2600,7 @@ class _ChatPageState extends State<ChatPage> {
       _currentGroupId = '';
       _historyByScope.clear();
       _seenMessageIds.clear();
+      _unreadMessageIdsByScope.clear();
       _consumedCortanaReplyKeys.clear();
       _autoInstallTriggered.clear();
       _groups.clear();
@@ -3867,6 +3873,12 @@ class _ChatPageState extends State<ChatPage> {
   }
 
   Future<void> _markScopeAsRead(String scopeKey) async {
+    final unreadIds = _unreadMessageIdsByScope.remove(scopeKey);
+    if (unreadIds != null) {
+      for (final messageId in unreadIds) {
+        _sendSocketReceipt('read', messageId);
+      }
+    }
     final history = _historyByScope[scopeKey];
     if (history == null || history.isEmpty) {
       return;
@@ -4364,6 +4376,12 @@ class _ChatPageState extends State<ChatPage> {
         return;
       }
 
+      if (direction == MessageDirection.incoming &&
+          envelope.messageId.isNotEmpty) {
+        _unreadMessageIdsByScope
+            .putIfAbsent(scopeKey, () => <String>{})
+            .add(envelope.messageId);
+      }
       _appendMessage(
         ChatMessage(
           content: envelope.content,
@@ -4413,13 +4431,17 @@ class _ChatPageState extends State<ChatPage> {
   }
 
   void _sendSocketAck(String messageId) {
+    _sendSocketReceipt('ack', messageId);
+  }
+
+  void _sendSocketReceipt(String type, String messageId) {
     final socket = _socket;
     if (socket == null || messageId.trim().isEmpty) {
       return;
     }
     try {
       socket.add(
-        jsonEncode(<String, dynamic>{'type': 'ack', 'message_id': messageId}),
+        jsonEncode(<String, dynamic>{'type': type, 'message_id': messageId}),
       );
     } catch (_) {}
   }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"uap"
+)
+
+// ========================= 路由 ACL =========================
+//
+// 规则按 agent_type 描述"谁可以向谁发送哪些消息类型"。
+// 只有在至少一条规则中出现过的消息类型才受控，其余消息类型保持放行；
+// 受控消息类型需命中任意一条规则才允许转发。
+
+// RouteRule 路由 ACL 规则
+type RouteRule struct {
+	MsgTypes []string `json:"msg_types"` // 受控消息类型，如 ["ctrl_shutdown"]
+	From     []string `json:"from"`      // 允许的发送方 agent_type（支持 path.Match 通配，如 "*"）
+	To       []string `json:"to"`        // 允许的目标 agent_type（支持通配）
+}
+
+// RouteACL 路由访问控制
+type RouteACL struct {
+	rules    []RouteRule
+	governed map[string]bool // 受控消息类型
+}
+
+// NewRouteACL 创建路由 ACL，规则为空时放行所有路由
+func NewRouteACL(rules []RouteRule) (*RouteACL, error) {
+	acl := &RouteACL{
+		rules:    rules,
+		governed: make(map[string]bool),
+	}
+	for i, rule := range rules {
+		if len(rule.MsgTypes) == 0 || len(rule.From) == 0 || len(rule.To) == 0 {
+			return nil, fmt.Errorf("route_acl[%d]: msg_types/from/to 均不能为空", i)
+		}
+		for _, patterns := range [][]string{rule.From, rule.To} {
+			for _, p := range patterns {
+				if _, err := path.Match(p, ""); err != nil {
+					return nil, fmt.Errorf("route_acl[%d]: invalid pattern %q: %v", i, p, err)
+				}
+			}
+		}
+		for _, t := range rule.MsgTypes {
+			acl.governed[t] = true
+		}
+	}
+	return acl, nil
+}
+
+// Check 校验 fromType 是否可以向 toType 发送 msgType；toType 为空表示目标类型未知（受控消息一律拒绝）
+func (acl *RouteACL) Check(fromType, toType, msgType string) error {
+	if !acl.governed[msgType] {
+		return nil
+	}
+	// 目标类型未知时无法证明规则允许，默认拒绝
+	if toType == "" {
+		return fmt.Errorf("%s agents may not send %s to unknown target", fromType, msgType)
+	}
+	for _, rule := range acl.rules {
+		if containsString(rule.MsgTypes, msgType) && matchAny(rule.From, fromType) && matchAny(rule.To, toType) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s agents may not send %s to %s agents", fromType, msgType, toType)
+}
+
+// Authorize 作为 uap.Server.AuthorizeRoute 使用；目标离线时通过 knownType 解析其类型。
+// 发送方未通过每 agent 凭证注册时其 agent_type 不可信，受控消息只能命中 from 为通配的规则；
+// 离线目标的类型无法解析时放行到离线处理（回复 agent_offline 或进入离线队列，投递时再按真实目标校验）
+func (acl *RouteACL) Authorize(knownType func(idOrName string) string) func(from, target *uap.AgentConn, msg *uap.Message) error {
+	return func(from, target *uap.AgentConn, msg *uap.Message) error {
+		var toType string
+		switch {
+		case target != nil:
+			toType = target.AgentType
+		default:
+			if t, ok := uap.ParseTypeAddress(msg.To); ok {
+				toType = t
+			} else if knownType != nil {
+				toType = knownType(msg.To)
+			}
+			if toType == "" {
+				return nil
+			}
+		}
+		if !from.TypeVerified {
+			if err := acl.Check("", toType, msg.Type); err != nil {
+				return fmt.Errorf("agent %s registered without a per-agent token, its type %q is not trusted for %s", from.ID, from.AgentType, msg.Type)
+			}
+			return nil
+		}
+		return acl.Check(from.AgentType, toType, msg.Type)
+	}
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
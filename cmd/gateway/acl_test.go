@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+
+	"uap"
+)
+
+func TestRouteACLGovernsOnlyListedMsgTypes(t *testing.T) {
+	acl, err := NewRouteACL([]RouteRule{
+		{MsgTypes: []string{uap.MsgCtrlShutdown}, From: []string{"deploy"}, To: []string{"*"}},
+		{MsgTypes: []string{uap.MsgToolCall, uap.MsgTaskAssign}, From: []string{"*"}, To: []string{"*"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRouteACL error: %v", err)
+	}
+
+	if err := acl.Check("image_agent", "deploy", uap.MsgCtrlShutdown); err == nil {
+		t.Fatalf("expected image_agent → deploy ctrl_shutdown to be denied")
+	}
+	if err := acl.Check("deploy", "codegen", uap.MsgCtrlShutdown); err != nil {
+		t.Fatalf("expected deploy to shut down codegen: %v", err)
+	}
+	if err := acl.Check("image_agent", "blog-agent", uap.MsgToolCall); err != nil {
+		t.Fatalf("expected tool_call to be allowed: %v", err)
+	}
+	if err := acl.Check("image_agent", "deploy", uap.MsgNotify); err != nil {
+		t.Fatalf("ungoverned msg type should pass: %v", err)
+	}
+}
+
+func TestRouteACLRejectsInvalidRules(t *testing.T) {
+	if _, err := NewRouteACL([]RouteRule{{MsgTypes: []string{uap.MsgToolCall}, From: []string{"["}, To: []string{"*"}}}); err == nil {
+		t.Fatalf("expected invalid glob to be rejected")
+	}
+	if _, err := NewRouteACL([]RouteRule{{MsgTypes: []string{uap.MsgToolCall}}}); err == nil {
+		t.Fatalf("expected empty from/to to be rejected")
+	}
+}
+
+func TestRouteACLResolvesOfflineTargetType(t *testing.T) {
+	acl, _ := NewRouteACL([]RouteRule{
+		{MsgTypes: []string{uap.MsgCtrlShutdown}, From: []string{"deploy"}, To: []string{"*"}},
+	})
+	q := newTestQueue(t, t.TempDir(), 10)
+	q.Remember(&uap.AgentConn{ID: "deploy-1", Name: "deploy-agent", AgentType: "deploy"})
+
+	authorize := acl.Authorize(q.KnownType)
+	from := &uap.AgentConn{ID: "image-1", AgentType: "image_agent", TypeVerified: true}
+	msg := &uap.Message{Type: uap.MsgCtrlShutdown, To: "deploy-agent"}
+	if err := authorize(from, nil, msg); err == nil {
+		t.Fatalf("expected shutdown of offline deploy-agent to be denied")
+	}
+	if err := authorize(from, nil, &uap.Message{Type: uap.MsgCtrlShutdown, To: "type:deploy"}); err == nil {
+		t.Fatalf("expected type-addressed shutdown to be denied")
+	}
+}
+
+func TestRouteACLDeniesUnknownTargetType(t *testing.T) {
+	acl, _ := NewRouteACL([]RouteRule{
+		{MsgTypes: []string{uap.MsgCtrlShutdown}, From: []string{"deploy"}, To: []string{"*"}},
+	})
+	if err := acl.Check("deploy", "", uap.MsgCtrlShutdown); err == nil {
+		t.Fatalf("governed message to an unknown target type should be denied")
+	}
+	if err := acl.Check("deploy", "", uap.MsgNotify); err != nil {
+		t.Fatalf("ungoverned msg type should pass even for unknown targets: %v", err)
+	}
+}
+
+func TestRouteACLDistrustsSharedTokenAgentType(t *testing.T) {
+	acl, _ := NewRouteACL([]RouteRule{
+		{MsgTypes: []string{uap.MsgCtrlShutdown}, From: []string{"deploy"}, To: []string{"*"}},
+		{MsgTypes: []string{uap.MsgToolCall}, From: []string{"*"}, To: []string{"*"}},
+	})
+	authorize := acl.Authorize(nil)
+	target := &uap.AgentConn{ID: "codegen-1", AgentType: "codegen"}
+
+	claimed := &uap.AgentConn{ID: "evil-1", AgentType: "deploy"}
+	if err := authorize(claimed, target, &uap.Message{Type: uap.MsgCtrlShutdown, To: "codegen-1"}); err == nil {
+		t.Fatalf("expected self-reported deploy type without per-agent token to be denied")
+	}
+	if err := authorize(claimed, target, &uap.Message{Type: uap.MsgToolCall, To: "codegen-1"}); err != nil {
+		t.Fatalf("expected wildcard rule to still allow unverified sender: %v", err)
+	}
+
+	verified := &uap.AgentConn{ID: "deploy-1", AgentType: "deploy", TypeVerified: true}
+	if err := authorize(verified, target, &uap.Message{Type: uap.MsgCtrlShutdown, To: "codegen-1"}); err != nil {
+		t.Fatalf("expected verified deploy agent to be allowed: %v", err)
+	}
+}
+
+func TestRouteACLDefersUnresolvableOfflineTarget(t *testing.T) {
+	acl, _ := NewRouteACL([]RouteRule{
+		{MsgTypes: []string{uap.MsgCtrlShutdown}, From: []string{"deploy"}, To: []string{"*"}},
+	})
+	// 无离线队列时目标类型无法解析，交由 agent_offline 处理而非 route_denied
+	authorize := acl.Authorize(nil)
+	from := &uap.AgentConn{ID: "deploy-1", AgentType: "deploy", TypeVerified: true}
+	if err := authorize(from, nil, &uap.Message{Type: uap.MsgCtrlShutdown, To: "codegen-1"}); err != nil {
+		t.Fatalf("expected unresolvable offline target to be deferred: %v", err)
+	}
+}
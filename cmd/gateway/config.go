@@ -10,7 +10,16 @@ import (
 type Config struct {
 	Port         int    `json:"port"`           // 网关监听端口
 	GoBackendURL string `json:"go_backend_url"` // blog-agent 后端地址（反向代理）
-	AuthToken    string `json:"auth_token"`     // agent 认证 token
+	AuthToken    string `json:"auth_token"`     // agent 共享认证 token（启用 agent_token_secret 后可置空以强制每 agent 凭证）
+
+	// 每 agent 注册凭证：HMAC 密钥，token 通过 gateway -gentoken 签发并绑定 agent_id + agent_type
+	AgentTokenSecret string `json:"agent_token_secret,omitempty"`
+	// 路由 ACL：限制哪些 agent_type 可以向哪些目标发送 tool_call / task_assign / ctrl_shutdown 等（为空则全部放行）
+	// 启用时必须配置 agent_token_secret；共享 auth_token 注册的 agent 只能命中 from 为 "*" 的规则
+	RouteACL []RouteRule `json:"route_acl,omitempty"`
+
+	// 按类型寻址（To: "type:xxx"）时的负载均衡策略: least_inflight（默认）/ round_robin
+	RouteStrategy string `json:"route_strategy"`
 
 	// 事件追踪配置
 	EventTracking   bool   `json:"event_tracking"`       // 启用追踪（默认 true）
@@ -19,6 +28,21 @@ type Config struct {
 	EventLogStdout  bool   `json:"event_log_stdout"`     // 终端输出（默认 true）
 	EventSkipHB     bool   `json:"event_skip_heartbeat"` // 跳过心跳事件（默认 false）
 
+	// 历史事件存储（按天分段 + trace/agent 索引，RingBuffer 之外的查询从这里读取）
+	EventRetentionDays    int `json:"event_retention_days"`     // 分段保留天数（默认 30，0=永久保留）
+	EventCompactAfterDays int `json:"event_compact_after_days"` // 超过 N 天的分段去除心跳事件（默认 1，0=不压缩）
+
+	// 离线投递队列（发往已知但离线 agent 的消息暂存，重新注册后重放）
+	OfflineQueueTypes  []string `json:"offline_queue_types,omitempty"` // 需要暂存的消息类型，如 ["tool_call","notify"]（为空则关闭）
+	OfflineQueueDir    string   `json:"offline_queue_dir"`             // 持久化目录（默认 "queue"）
+	OfflineQueueTTLSec int      `json:"offline_queue_ttl_sec"`         // 消息存活时间（默认 600 秒）
+	OfflineQueueMax    int      `json:"offline_queue_max"`             // 单个 agent 最大积压数（默认 100）
+
+	// OTLP 链路导出（OTLP/HTTP JSON，如本地 Jaeger: http://127.0.0.1:4318；为空则关闭）
+	OTLPEndpoint    string            `json:"otlp_endpoint,omitempty"`
+	OTLPServiceName string            `json:"otlp_service_name"`      // resource service.name（默认 "gateway"）
+	OTLPHeaders     map[string]string `json:"otlp_headers,omitempty"` // 附加请求头（如 collector 鉴权）
+
 	// 部署保护文件（deploy-agent 增量部署时跳过这些文件）
 	ProtectedFiles []string `json:"protected_files,omitempty"`
 }
@@ -29,13 +53,23 @@ func DefaultConfig() *Config {
 		Port:            9000,
 		GoBackendURL:    "http://127.0.0.1:8080",
 		AuthToken:       "",
+		RouteStrategy:   "least_inflight",
 		EventTracking:   true,
 		EventBufferSize: 10000,
 		EventLogDir:     "logs",
 		EventLogStdout:  true,
 		EventSkipHB:     false,
 
-		ProtectedFiles: []string{"gateway.json", "logs/"},
+		EventRetentionDays:    30,
+		EventCompactAfterDays: 1,
+
+		OfflineQueueDir:    "queue",
+		OfflineQueueTTLSec: 600,
+		OfflineQueueMax:    100,
+
+		OTLPServiceName: "gateway",
+
+		ProtectedFiles: []string{"gateway.json", "logs/", "queue/"},
 	}
 }
 
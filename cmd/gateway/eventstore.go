@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"uap"
+)
+
+// ========================= EventStore 分段事件存储 =========================
+//
+// 事件按天写入 events_YYYY-MM-DD.jsonl（与原 JSONL 日志格式一致），每个分段维护
+// trace_id / agent → 字节偏移的索引。已封存的分段索引落盘为 events_YYYY-MM-DD.idx，
+// 当天分段的索引保存在内存中，关闭时落盘；索引缺失或与文件大小不符时启动阶段扫描重建。
+// RingBuffer 覆盖不到的历史查询由 Tracker 透明地转到这里。
+
+const (
+	segmentPrefix = "events_"
+	segmentExt    = ".jsonl"
+	indexExt      = ".idx"
+)
+
+// segmentIndex 单个分段的索引
+type segmentIndex struct {
+	Date      string             `json:"date"`
+	MinTs     int64              `json:"min_ts"`
+	MaxTs     int64              `json:"max_ts"`
+	Count     int                `json:"count"`
+	Size      int64              `json:"size"`      // 建索引时的文件大小
+	Compacted bool               `json:"compacted"` // 是否已压缩（去除心跳事件）
+	Traces    map[string][]int64 `json:"traces"`    // trace_id → 偏移
+	Agents    map[string][]int64 `json:"agents"`    // agent ID（from/to）→ 偏移
+}
+
+func newSegmentIndex(date string) *segmentIndex {
+	return &segmentIndex{
+		Date:   date,
+		Traces: make(map[string][]int64),
+		Agents: make(map[string][]int64),
+	}
+}
+
+// add 将 offset 处的事件加入索引
+func (idx *segmentIndex) add(e *Event, offset int64) {
+	if idx.Count == 0 || e.Ts < idx.MinTs {
+		idx.MinTs = e.Ts
+	}
+	if e.Ts > idx.MaxTs {
+		idx.MaxTs = e.Ts
+	}
+	idx.Count++
+	if e.TraceID != "" {
+		idx.Traces[e.TraceID] = append(idx.Traces[e.TraceID], offset)
+	}
+	if e.From != "" {
+		idx.Agents[e.From] = append(idx.Agents[e.From], offset)
+	}
+	if e.To != "" && e.To != e.From {
+		idx.Agents[e.To] = append(idx.Agents[e.To], offset)
+	}
+}
+
+// candidates 返回需要读取的偏移（nil 表示需要全段扫描）
+func (idx *segmentIndex) candidates(q *EventQuery) ([]int64, bool) {
+	switch {
+	case q.TraceID != "":
+		return idx.Traces[q.TraceID], true
+	case q.Agent != "":
+		return idx.Agents[q.Agent], true
+	default:
+		return nil, false
+	}
+}
+
+// EventStoreConfig 事件存储配置
+type EventStoreConfig struct {
+	Dir              string // 分段目录
+	RetentionDays    int    // 保留天数（0=永久保留）
+	CompactAfterDays int    // 超过 N 天的分段去除心跳事件（0=不压缩）
+}
+
+// EventStore 分段事件存储
+type EventStore struct {
+	cfg      EventStoreConfig
+	mu       sync.Mutex
+	segments map[string]*segmentIndex // date → 索引
+	current  *os.File
+	today    string
+	offset   int64 // 当天分段的写入位置
+}
+
+// NewEventStore 创建事件存储并加载/重建所有分段索引
+func NewEventStore(cfg EventStoreConfig) (*EventStore, error) {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create event log dir: %w", err)
+	}
+	s := &EventStore{
+		cfg:      cfg,
+		segments: make(map[string]*segmentIndex),
+	}
+	if err := s.loadSegments(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write 追加一条事件并更新当天分段索引
+func (s *EventStore) Write(e *Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	today := time.Now().Format("2006-01-02")
+	if s.current == nil || s.today != today {
+		if err := s.rotateLocked(today); err != nil {
+			log.Printf("[Tracker] open event log failed: %v", err)
+			return
+		}
+	}
+
+	offset := s.offset
+	n, err := s.current.Write(append(data, '\n'))
+	s.offset += int64(n)
+	if err != nil {
+		log.Printf("[Tracker] write event log failed: %v", err)
+		return
+	}
+	idx := s.segments[today]
+	idx.add(e, offset)
+	idx.Size = s.offset
+}
+
+// rotateLocked 切换到新的一天：封存旧分段索引，打开新分段
+func (s *EventStore) rotateLocked(today string) error {
+	if s.current != nil {
+		s.current.Close()
+		s.current = nil
+		if idx := s.segments[s.today]; idx != nil {
+			s.saveIndex(idx)
+		}
+	}
+
+	f, err := os.OpenFile(s.segmentPath(today), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, ok := s.segments[today]; !ok {
+		s.segments[today] = newSegmentIndex(today)
+	}
+	s.current = f
+	s.today = today
+	s.offset = info.Size()
+	return nil
+}
+
+// Query 按条件查询历史事件（从新到旧，与 RingBuffer 一致），返回当前页与命中总数
+func (s *EventStore) Query(q *EventQuery) ([]Event, int) {
+	limit := q.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	var matched []Event
+	total := 0
+	snaps := s.snapshot(q)
+	for si := len(snaps) - 1; si >= 0; si-- {
+		snap := snaps[si]
+		events, err := s.readSegment(snap, q)
+		if err != nil {
+			log.Printf("[Tracker] read segment %s failed: %v", snap.date, err)
+			continue
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			total++
+			if total <= q.Offset {
+				continue
+			}
+			if len(matched) < limit {
+				matched = append(matched, events[i])
+			}
+		}
+	}
+	return matched, total
+}
+
+// segmentSnapshot 查询时对分段状态的快照（读取时不持锁）
+type segmentSnapshot struct {
+	date    string
+	offsets []int64
+	indexed bool
+	size    int64
+}
+
+// snapshot 选出与时间范围重叠的分段，并复制候选偏移
+func (s *EventStore) snapshot(q *EventQuery) []segmentSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dates := make([]string, 0, len(s.segments))
+	for date := range s.segments {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	var result []segmentSnapshot
+	for _, date := range dates {
+		idx := s.segments[date]
+		if idx.Count == 0 {
+			continue
+		}
+		if q.Since > 0 && idx.MaxTs < q.Since {
+			continue
+		}
+		if q.Until > 0 && idx.MinTs > q.Until {
+			continue
+		}
+		offsets, indexed := idx.candidates(q)
+		if indexed && len(offsets) == 0 {
+			continue
+		}
+		result = append(result, segmentSnapshot{
+			date:    date,
+			offsets: append([]int64(nil), offsets...),
+			indexed: indexed,
+			size:    idx.Size,
+		})
+	}
+	return result
+}
+
+// readSegment 读取分段中符合条件的事件
+func (s *EventStore) readSegment(snap segmentSnapshot, q *EventQuery) ([]Event, error) {
+	f, err := os.Open(s.segmentPath(snap.date))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var result []Event
+	if snap.indexed {
+		for _, off := range snap.offsets {
+			line, err := bufio.NewReader(io.NewSectionReader(f, off, snap.size-off)).ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return result, err
+			}
+			var e Event
+			if json.Unmarshal(line, &e) == nil && matchEvent(&e, q) {
+				result = append(result, e)
+			}
+		}
+		return result, nil
+	}
+
+	err = scanEvents(io.NewSectionReader(f, 0, snap.size), func(e *Event, _ int64) {
+		if matchEvent(e, q) {
+			result = append(result, *e)
+		}
+	})
+	return result, err
+}
+
+// OldestTs 返回存储中最旧事件的时间戳，没有事件时 ok=false
+func (s *EventStore) OldestTs() (ts int64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, idx := range s.segments {
+		if idx.Count == 0 {
+			continue
+		}
+		if !ok || idx.MinTs < ts {
+			ts, ok = idx.MinTs, true
+		}
+	}
+	return ts, ok
+}
+
+// Stats 存储统计
+func (s *EventStore) Stats() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events int
+	var oldest string
+	for date, idx := range s.segments {
+		events += idx.Count
+		if oldest == "" || date < oldest {
+			oldest = date
+		}
+	}
+	return map[string]any{
+		"segments":       len(s.segments),
+		"events":         events,
+		"oldest_segment": oldest,
+	}
+}
+
+// StartMaintenance 定期执行保留期清理和压缩
+func (s *EventStore) StartMaintenance(interval time.Duration) {
+	go func() {
+		s.Maintain(time.Now())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.Maintain(time.Now())
+		}
+	}()
+}
+
+// Maintain 删除超过保留期的分段，并压缩足够旧的分段
+func (s *EventStore) Maintain(now time.Time) {
+	s.mu.Lock()
+	var expired, compact []string
+	for date, idx := range s.segments {
+		if date == s.today {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			continue
+		}
+		age := now.Sub(day)
+		switch {
+		case s.cfg.RetentionDays > 0 && age > time.Duration(s.cfg.RetentionDays)*24*time.Hour:
+			expired = append(expired, date)
+			delete(s.segments, date)
+		case s.cfg.CompactAfterDays > 0 && !idx.Compacted && age > time.Duration(s.cfg.CompactAfterDays)*24*time.Hour:
+			compact = append(compact, date)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, date := range expired {
+		os.Remove(s.segmentPath(date))
+		os.Remove(s.indexPath(date))
+		log.Printf("[Tracker] event segment %s removed (retention %d days)", date, s.cfg.RetentionDays)
+	}
+	for _, date := range compact {
+		if err := s.compactSegment(date); err != nil {
+			log.Printf("[Tracker] compact segment %s failed: %v", date, err)
+		}
+	}
+}
+
+// compactSegment 重写已封存分段，去除心跳事件并重建索引
+func (s *EventStore) compactSegment(date string) error {
+	path := s.segmentPath(date)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".compact"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	idx := newSegmentIndex(date)
+	w := bufio.NewWriter(out)
+	var offset int64
+	dropped := 0
+	scanErr := scanEvents(f, func(e *Event, _ int64) {
+		if e.MsgType == uap.MsgHeartbeat || e.MsgType == uap.MsgHeartbeatAck {
+			dropped++
+			return
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		idx.add(e, offset)
+		n, _ := w.Write(append(data, '\n'))
+		offset += int64(n)
+	})
+	f.Close()
+	flushErr := w.Flush()
+	out.Close()
+	if scanErr != nil || flushErr != nil {
+		os.Remove(tmpPath)
+		if scanErr != nil {
+			return scanErr
+		}
+		return flushErr
+	}
+
+	idx.Size = offset
+	idx.Compacted = true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.segments[date]; !ok {
+		// 压缩期间已被保留期清理
+		os.Remove(tmpPath)
+		return nil
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	s.segments[date] = idx
+	s.saveIndex(idx)
+	log.Printf("[Tracker] event segment %s compacted (dropped %d heartbeat events, kept %d)", date, dropped, idx.Count)
+	return nil
+}
+
+// Close 封存当天索引并关闭文件
+func (s *EventStore) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		s.current.Close()
+		s.current = nil
+		if idx := s.segments[s.today]; idx != nil {
+			s.saveIndex(idx)
+		}
+	}
+}
+
+// ========================= 索引加载 =========================
+
+// loadSegments 加载所有分段索引；索引缺失或过期（文件大小不符）的分段重新扫描
+func (s *EventStore) loadSegments() error {
+	files, err := filepath.Glob(filepath.Join(s.cfg.Dir, segmentPrefix+"*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	today := time.Now().Format("2006-01-02")
+	for _, path := range files {
+		date := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), segmentPrefix), segmentExt)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if idx := s.loadIndex(date); idx != nil && idx.Size == info.Size() {
+			s.segments[date] = idx
+			continue
+		}
+
+		idx, err := s.buildIndex(date)
+		if err != nil {
+			log.Printf("[Tracker] index segment %s failed: %v", date, err)
+			continue
+		}
+		s.segments[date] = idx
+		if date != today {
+			s.saveIndex(idx)
+		}
+	}
+	return nil
+}
+
+// buildIndex 扫描分段文件重建索引
+func (s *EventStore) buildIndex(date string) (*segmentIndex, error) {
+	f, err := os.Open(s.segmentPath(date))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	idx := newSegmentIndex(date)
+	err = scanEvents(f, func(e *Event, offset int64) {
+		idx.add(e, offset)
+	})
+	if info, statErr := f.Stat(); statErr == nil {
+		idx.Size = info.Size()
+	}
+	return idx, err
+}
+
+func (s *EventStore) loadIndex(date string) *segmentIndex {
+	data, err := os.ReadFile(s.indexPath(date))
+	if err != nil {
+		return nil
+	}
+	var idx segmentIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil
+	}
+	return &idx
+}
+
+func (s *EventStore) saveIndex(idx *segmentIndex) {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(s.indexPath(idx.Date), data); err != nil {
+		log.Printf("[Tracker] save segment index %s failed: %v", idx.Date, err)
+	}
+}
+
+func (s *EventStore) segmentPath(date string) string {
+	return filepath.Join(s.cfg.Dir, segmentPrefix+date+segmentExt)
+}
+
+func (s *EventStore) indexPath(date string) string {
+	return filepath.Join(s.cfg.Dir, segmentPrefix+date+indexExt)
+}
+
+// scanEvents 逐行解析 JSONL，回调事件及其字节偏移（损坏行跳过）
+func scanEvents(r io.Reader, fn func(e *Event, offset int64)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var e Event
+			if json.Unmarshal(line, &e) == nil {
+				fn(&e, offset)
+			}
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
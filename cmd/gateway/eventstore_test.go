@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uap"
+)
+
+func TestTrackerFallsBackToStoreForEvictedTrace(t *testing.T) {
+	dir := t.TempDir()
+	tracker, err := NewTracker(&TrackerConfig{BufferSize: 4, LogDir: dir})
+	if err != nil {
+		t.Fatalf("NewTracker error: %v", err)
+	}
+
+	now := time.Now().UnixMilli()
+	tracker.Record(&Event{Ts: now, Kind: EventKindMsgIn, TraceID: "old-trace", From: "agent-a", MsgType: uap.MsgToolCall})
+	for i := 0; i < 8; i++ {
+		tracker.Record(&Event{Ts: now + int64(i+1), Kind: EventKindMsgIn, TraceID: "noise", From: "agent-b"})
+	}
+
+	if events, _ := tracker.ring.Query(&EventQuery{TraceID: "old-trace"}); len(events) != 0 {
+		t.Fatalf("expected trace evicted from ring buffer, got %d events", len(events))
+	}
+	if events := tracker.GetTrace("old-trace"); len(events) != 1 || events[0].From != "agent-a" {
+		t.Fatalf("expected trace from store, got %+v", events)
+	}
+	// 最新的一页已在缓冲区内，无需读取存储
+	if events, total := tracker.Query(&EventQuery{Agent: "agent-b", Limit: 2}); total != 4 || events[0].Ts != now+8 {
+		t.Fatalf("expected newest agent-b page from ring buffer, got total=%d events=%+v", total, events)
+	}
+	if events, total := tracker.Query(&EventQuery{Agent: "agent-b", Limit: 10}); total != 8 || events[0].Ts != now+8 {
+		t.Fatalf("expected 8 agent-b events from store newest first, got total=%d events=%+v", total, events)
+	}
+	tracker.Close()
+
+	// 重启后从落盘索引恢复
+	reopened, err := NewTracker(&TrackerConfig{BufferSize: 4, LogDir: dir})
+	if err != nil {
+		t.Fatalf("reopen tracker: %v", err)
+	}
+	defer reopened.Close()
+	if events := reopened.GetTrace("old-trace"); len(events) != 1 {
+		t.Fatalf("expected trace after restart, got %d events", len(events))
+	}
+	events, total := reopened.Query(&EventQuery{Since: now + 5, Limit: 10})
+	if total != 4 || events[0].Ts != now+8 {
+		t.Fatalf("expected 4 events since now+5, got total=%d events=%+v", total, events)
+	}
+}
+
+func TestEventStoreIndexesLegacySegmentAndCompacts(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Now().AddDate(0, 0, -3)
+	date := day.Format("2006-01-02")
+	writeTestSegment(t, dir, date, []Event{
+		{Ts: day.UnixMilli(), Kind: EventKindMsgIn, TraceID: "t1", From: "a", MsgType: uap.MsgToolCall},
+		{Ts: day.UnixMilli() + 1, Kind: EventKindMsgIn, TraceID: "hb", From: "a", MsgType: uap.MsgHeartbeat},
+		{Ts: day.UnixMilli() + 2, Kind: EventKindMsgOut, TraceID: "t1", From: "b", To: "a", MsgType: uap.MsgToolResult},
+	})
+
+	store, err := NewEventStore(EventStoreConfig{Dir: dir, CompactAfterDays: 1})
+	if err != nil {
+		t.Fatalf("NewEventStore error: %v", err)
+	}
+	defer store.Close()
+	if _, err := os.Stat(store.indexPath(date)); err != nil {
+		t.Fatalf("expected index written for legacy segment: %v", err)
+	}
+
+	store.Maintain(time.Now())
+
+	if _, total := store.Query(&EventQuery{MsgType: uap.MsgHeartbeat}); total != 0 {
+		t.Fatalf("expected heartbeat events compacted away, got %d", total)
+	}
+	events, _ := store.Query(&EventQuery{TraceID: "t1"})
+	if len(events) != 2 || events[0].MsgType != uap.MsgToolResult {
+		t.Fatalf("expected trace intact after compaction, got %+v", events)
+	}
+	if !store.segments[date].Compacted {
+		t.Fatal("expected segment marked compacted")
+	}
+}
+
+func TestEventStoreRetentionRemovesOldSegments(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().AddDate(0, 0, -10)
+	date := old.Format("2006-01-02")
+	writeTestSegment(t, dir, date, []Event{{Ts: old.UnixMilli(), Kind: EventKindMsgIn, TraceID: "t1"}})
+
+	store, err := NewEventStore(EventStoreConfig{Dir: dir, RetentionDays: 7})
+	if err != nil {
+		t.Fatalf("NewEventStore error: %v", err)
+	}
+	defer store.Close()
+
+	store.Maintain(time.Now())
+
+	if _, err := os.Stat(store.segmentPath(date)); !os.IsNotExist(err) {
+		t.Fatalf("expected segment removed, stat err=%v", err)
+	}
+	if _, err := os.Stat(store.indexPath(date)); !os.IsNotExist(err) {
+		t.Fatalf("expected index removed, stat err=%v", err)
+	}
+	if events, _ := store.Query(&EventQuery{TraceID: "t1"}); len(events) != 0 {
+		t.Fatalf("expected no events after retention, got %d", len(events))
+	}
+}
+
+func writeTestSegment(t *testing.T, dir, date string, events []Event) {
+	t.Helper()
+	f, err := os.Create(filepath.Join(dir, segmentPrefix+date+segmentExt))
+	if err != nil {
+		t.Fatalf("create segment: %v", err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for i := range events {
+		if err := enc.Encode(&events[i]); err != nil {
+			t.Fatalf("write event: %v", err)
+		}
+	}
+}
@@ -11,14 +11,19 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"deploygen"
+	"otlptrace"
+	"uap"
 )
 
 func main() {
 	configFile := flag.String("config", "gateway.json", "配置文件路径")
 	genConf := flag.Bool("genconf", false, "生成默认配置文件")
 	genDeploy := flag.Bool("gendeploy", false, "生成部署脚本")
+	genToken := flag.String("gentoken", "", "为 agent 签发注册 token，格式 agent_id:agent_type（需配置 agent_token_secret）")
+	tokenDays := flag.Int("token-days", 0, "签发 token 的有效天数（0=永不过期）")
 	flag.Parse()
 
 	if *genConf {
@@ -49,6 +54,16 @@ func main() {
 		cfg = DefaultConfig()
 	}
 
+	if *genToken != "" {
+		token, err := signTokenFromFlag(cfg, *genToken, *tokenDays)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(token)
+		return
+	}
+
 	log.Printf("[Gateway] starting on port %d", cfg.Port)
 	log.Printf("[Gateway] blog-agent upstream: %s", cfg.GoBackendURL)
 
@@ -64,17 +79,73 @@ func main() {
 			LogDir:     cfg.EventLogDir,
 			LogStdout:  cfg.EventLogStdout,
 			SkipHB:     cfg.EventSkipHB,
+
+			RetentionDays:    cfg.EventRetentionDays,
+			CompactAfterDays: cfg.EventCompactAfterDays,
 		})
 		if err2 != nil {
 			log.Printf("[Gateway] event tracker init failed: %v, continuing without tracking", err2)
 		} else {
-			log.Printf("[Gateway] event tracking enabled (buffer=%d, dir=%s, stdout=%v, skip_hb=%v)",
-				cfg.EventBufferSize, cfg.EventLogDir, cfg.EventLogStdout, cfg.EventSkipHB)
+			log.Printf("[Gateway] event tracking enabled (buffer=%d, dir=%s, stdout=%v, skip_hb=%v, retention=%dd, compact_after=%dd)",
+				cfg.EventBufferSize, cfg.EventLogDir, cfg.EventLogStdout, cfg.EventSkipHB, cfg.EventRetentionDays, cfg.EventCompactAfterDays)
+		}
+	}
+
+	// 初始化离线投递队列（仅在配置了消息类型时启用）
+	var queue *OfflineQueue
+	if len(cfg.OfflineQueueTypes) > 0 {
+		var err2 error
+		queue, err2 = NewOfflineQueue(QueueConfig{
+			Dir:         cfg.OfflineQueueDir,
+			MsgTypes:    cfg.OfflineQueueTypes,
+			TTL:         time.Duration(cfg.OfflineQueueTTLSec) * time.Second,
+			MaxPerAgent: cfg.OfflineQueueMax,
+		})
+		if err2 != nil {
+			log.Printf("[Gateway] offline queue init failed: %v, continuing without queue", err2)
+			queue = nil
+		} else {
+			log.Printf("[Gateway] offline queue enabled (types=%v, dir=%s, ttl=%ds, max=%d)",
+				cfg.OfflineQueueTypes, cfg.OfflineQueueDir, cfg.OfflineQueueTTLSec, cfg.OfflineQueueMax)
+		}
+	}
+
+	// 初始化路由 ACL
+	var acl *RouteACL
+	if len(cfg.RouteACL) > 0 {
+		acl, err = NewRouteACL(cfg.RouteACL)
+		if err != nil {
+			log.Fatalf("[Gateway] invalid route_acl: %v", err)
+		}
+		// ACL 按 agent_type 判定，共享 token 无法证明 agent_type，必须启用每 agent 凭证
+		if cfg.AgentTokenSecret == "" {
+			log.Fatalf("[Gateway] route_acl requires agent_token_secret: agent_type is self-reported under the shared auth_token")
+		}
+		if cfg.AuthToken != "" {
+			log.Printf("[Gateway] WARNING: route_acl enabled while shared auth_token is still accepted; agents registered with it only match route_acl rules whose from is \"*\"")
+		}
+		log.Printf("[Gateway] route ACL enabled (%d rules)", len(cfg.RouteACL))
+	}
+	if cfg.AgentTokenSecret != "" {
+		if cfg.AuthToken == "" {
+			log.Printf("[Gateway] per-agent registration tokens enforced")
+		} else {
+			log.Printf("[Gateway] per-agent registration tokens enabled (shared auth_token still accepted)")
 		}
 	}
 
+	// 初始化 OTLP span 导出
+	spans := NewSpanRecorder(otlptrace.NewExporter(otlptrace.Config{
+		Endpoint:    cfg.OTLPEndpoint,
+		ServiceName: cfg.OTLPServiceName,
+		Headers:     cfg.OTLPHeaders,
+	}))
+	if spans != nil {
+		log.Printf("[Gateway] OTLP trace export enabled (endpoint=%s, service=%s)", cfg.OTLPEndpoint, cfg.OTLPServiceName)
+	}
+
 	// 初始化路由器（包含 UAP server）
-	router := NewRouter(cfg, registry, tracker)
+	router := NewRouter(cfg, registry, tracker, queue, acl, spans)
 
 	// 注册 HTTP 路由
 	mux := http.NewServeMux()
@@ -143,7 +214,7 @@ func main() {
 				if e.MsgType == "tool_result" || e.MsgType == "task_complete" {
 					status = "completed"
 				}
-				if e.Kind == EventKindRouteErr || e.Error != "" {
+				if e.Kind == EventKindRouteErr || e.Kind == EventKindRouteDeny || e.Error != "" {
 					status = "error"
 				}
 			}
@@ -231,6 +302,9 @@ func main() {
 		if tracker != nil {
 			tracker.Close()
 		}
+		if spans != nil {
+			spans.Shutdown()
+		}
 		server.Close()
 	}()
 
@@ -240,3 +314,19 @@ func main() {
 	}
 	log.Println("[Gateway] stopped")
 }
+
+// signTokenFromFlag 解析 -gentoken 参数（agent_id:agent_type）并签发注册 token
+func signTokenFromFlag(cfg *Config, spec string, days int) (string, error) {
+	if cfg.AgentTokenSecret == "" {
+		return "", fmt.Errorf("agent_token_secret 未配置，无法签发 token")
+	}
+	agentID, agentType, ok := strings.Cut(spec, ":")
+	if !ok || agentID == "" || agentType == "" {
+		return "", fmt.Errorf("-gentoken 格式应为 agent_id:agent_type，实际: %q", spec)
+	}
+	var expiresAt time.Time
+	if days > 0 {
+		expiresAt = time.Now().AddDate(0, 0, days)
+	}
+	return uap.SignAgentToken(cfg.AgentTokenSecret, agentID, agentType, expiresAt), nil
+}
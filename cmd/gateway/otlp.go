@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"otlptrace"
+	"uap"
+)
+
+// ========================= OTLP span 导出 =========================
+//
+// 每个 tool_call → tool_result、task_assign → task_complete/task_rejected 请求对生成一个 span。
+// 请求带 traceparent 时挂到调用方 span 下，否则以 gateway trace_id（请求 Message.ID）派生 trace ID；
+// 转发前把 traceparent 改写为 gateway span，接收方的本地 span 随之成为其子节点。
+
+// SpanRecorder 根据请求/回复消息对生成 span
+type SpanRecorder struct {
+	exporter *otlptrace.Exporter
+	mu       sync.Mutex
+	pending  map[string]*pendingSpan // "id:<msgID>" / "task:<taskID>" → span
+}
+
+type pendingSpan struct {
+	span *otlptrace.Span
+	keys []string
+}
+
+// NewSpanRecorder 创建 span 记录器；exporter 为 nil 时返回 nil
+func NewSpanRecorder(exporter *otlptrace.Exporter) *SpanRecorder {
+	if exporter == nil {
+		return nil
+	}
+	return &SpanRecorder{
+		exporter: exporter,
+		pending:  make(map[string]*pendingSpan),
+	}
+}
+
+// Observe 处理 gateway 收到的消息（在转发前调用，会改写请求的 TraceParent）
+func (r *SpanRecorder) Observe(from *uap.AgentConn, msg *uap.Message) {
+	switch msg.Type {
+	case uap.MsgToolCall, uap.MsgTaskAssign:
+		r.begin(from, msg)
+	case uap.MsgToolResult, uap.MsgTaskComplete, uap.MsgTaskRejected, uap.MsgError:
+		r.end(from, msg)
+	}
+}
+
+// Fail 请求未能投递（目标离线 / ACL 拒绝）时以错误结束 span
+func (r *SpanRecorder) Fail(msg *uap.Message, reason string) {
+	if p := r.take("id:" + msg.ID); p != nil {
+		p.span.Error = reason
+		r.finish(p.span, time.Now())
+	}
+}
+
+func (r *SpanRecorder) begin(from *uap.AgentConn, msg *uap.Message) {
+	traceID, parentID, ok := otlptrace.ParseTraceParent(msg.TraceParent)
+	if !ok {
+		traceID, parentID = otlptrace.TraceIDFrom(msg.ID), ""
+	}
+
+	attrs := map[string]any{
+		"uap.msg_id":   msg.ID,
+		"uap.msg_type": msg.Type,
+		"uap.from":     msg.From,
+		"uap.to":       msg.To,
+	}
+	if from != nil {
+		attrs["uap.from_name"] = from.Name
+		attrs["uap.from_type"] = from.AgentType
+	}
+
+	keys := []string{"id:" + msg.ID}
+	name := msg.Type
+	switch msg.Type {
+	case uap.MsgToolCall:
+		if tool := extractToolName(msg.Payload); tool != "" {
+			attrs["tool.name"] = tool
+			name += " " + tool
+		}
+	case uap.MsgTaskAssign:
+		if taskID := extractTaskID(msg.Payload); taskID != "" {
+			attrs["task.id"] = taskID
+			keys = append(keys, "task:"+taskID)
+		}
+		if taskType := extractTaskType(msg.Payload); taskType != "" {
+			attrs["task.type"] = taskType
+			name += " " + taskType
+		}
+	}
+
+	span := &otlptrace.Span{
+		TraceID:      traceID,
+		SpanID:       otlptrace.NewSpanID(),
+		ParentSpanID: parentID,
+		Name:         name,
+		Kind:         otlptrace.SpanKindInternal,
+		Start:        time.Now(),
+		Attributes:   attrs,
+	}
+	msg.TraceParent = otlptrace.FormatTraceParent(span.TraceID, span.SpanID)
+
+	r.mu.Lock()
+	p := &pendingSpan{span: span, keys: keys}
+	for _, key := range keys {
+		r.pending[key] = p
+	}
+	r.mu.Unlock()
+}
+
+func (r *SpanRecorder) end(from *uap.AgentConn, msg *uap.Message) {
+	var key string
+	switch msg.Type {
+	case uap.MsgToolResult:
+		key = "id:" + extractRequestID(msg.Payload)
+	case uap.MsgTaskComplete, uap.MsgTaskRejected:
+		key = "task:" + extractTaskID(msg.Payload)
+	case uap.MsgError:
+		key = "id:" + msg.ID
+	}
+	p := r.take(key)
+	if p == nil {
+		return
+	}
+
+	span := p.span
+	if from != nil {
+		span.Attributes["uap.replied_by"] = from.ID
+		span.Attributes["uap.to_name"] = from.Name
+	}
+	span.Attributes["uap.reply_type"] = msg.Type
+	span.Error = replyError(msg)
+	r.finish(span, time.Now())
+}
+
+// take 取出并解除 key 关联的 span
+func (r *SpanRecorder) take(key string) *pendingSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pending[key]
+	if !ok {
+		return nil
+	}
+	for _, k := range p.keys {
+		delete(r.pending, k)
+	}
+	return p
+}
+
+func (r *SpanRecorder) finish(span *otlptrace.Span, end time.Time) {
+	span.End = end
+	r.exporter.Export(span)
+}
+
+// StartExpiry 定期结束超时未回复的 span（以错误状态导出，避免在途 span 无限堆积）
+func (r *SpanRecorder) StartExpiry(ttl, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			r.expire(time.Now(), ttl)
+		}
+	}()
+}
+
+func (r *SpanRecorder) expire(now time.Time, ttl time.Duration) {
+	r.mu.Lock()
+	var expired []*pendingSpan
+	for _, p := range r.pending {
+		if now.Sub(p.span.Start) > ttl {
+			expired = append(expired, p)
+			for _, k := range p.keys {
+				delete(r.pending, k)
+			}
+		}
+	}
+	r.mu.Unlock()
+	for _, p := range expired {
+		p.span.Error = fmt.Sprintf("no reply within %v", ttl)
+		r.finish(p.span, now)
+	}
+}
+
+// Shutdown 发送剩余 span
+func (r *SpanRecorder) Shutdown() {
+	r.exporter.Shutdown()
+}
+
+// replyError 从回复消息中提取失败原因（成功时返回空串）
+func replyError(msg *uap.Message) string {
+	switch msg.Type {
+	case uap.MsgError:
+		return extractErrorMessage(msg.Payload)
+	case uap.MsgTaskRejected:
+		var p uap.TaskRejectedPayload
+		json.Unmarshal(msg.Payload, &p)
+		return "rejected: " + p.Reason
+	case uap.MsgToolResult:
+		var p uap.ToolResultPayload
+		if json.Unmarshal(msg.Payload, &p) == nil && !p.Success {
+			if p.Error != "" {
+				return p.Error
+			}
+			return "tool failed"
+		}
+	case uap.MsgTaskComplete:
+		var p uap.TaskCompletePayload
+		if json.Unmarshal(msg.Payload, &p) == nil && p.Status != "" && p.Status != "success" {
+			if p.Error != "" {
+				return p.Error
+			}
+			return p.Status
+		}
+	}
+	return ""
+}
+
+// extractTaskType 读取 task_assign 内层 payload 的 task_type（约定字段，可能不存在）
+func extractTaskType(payload json.RawMessage) string {
+	var p uap.TaskAssignPayload
+	if json.Unmarshal(payload, &p) != nil {
+		return ""
+	}
+	var inner struct {
+		TaskType string `json:"task_type"`
+	}
+	json.Unmarshal(p.Payload, &inner)
+	return inner.TaskType
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"otlptrace"
+	"uap"
+)
+
+func TestSpanRecorderLinksRequestAndReply(t *testing.T) {
+	bodies := make(chan []byte, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies <- data
+	}))
+	defer srv.Close()
+
+	exporter := otlptrace.NewExporter(otlptrace.Config{Endpoint: srv.URL, ServiceName: "gateway", FlushInterval: time.Hour})
+	spans := NewSpanRecorder(exporter)
+
+	callerTrace, callerSpan := otlptrace.NewTraceID(), otlptrace.NewSpanID()
+	call := &uap.Message{
+		Type:        uap.MsgToolCall,
+		ID:          "msg-1",
+		From:        "llm-agent",
+		To:          "blog-agent",
+		Payload:     mustTrackerPayload(uap.ToolCallPayload{ToolName: "RawSearch"}),
+		TraceParent: otlptrace.FormatTraceParent(callerTrace, callerSpan),
+	}
+	spans.Observe(&uap.AgentConn{ID: "llm-agent", Name: "LLM"}, call)
+
+	traceID, gatewaySpan, ok := otlptrace.ParseTraceParent(call.TraceParent)
+	if !ok || traceID != callerTrace || gatewaySpan == callerSpan {
+		t.Fatalf("expected traceparent rewritten to gateway span in caller trace, got %q", call.TraceParent)
+	}
+
+	spans.Observe(&uap.AgentConn{ID: "blog-agent", Name: "Blog"}, &uap.Message{
+		Type:    uap.MsgToolResult,
+		ID:      "msg-2",
+		Payload: mustTrackerPayload(uap.ToolResultPayload{RequestID: "msg-1", Success: false, Error: "boom"}),
+	})
+	spans.Shutdown()
+
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Status       struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(<-bodies, &req); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	got := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(got) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(got))
+	}
+	span := got[0]
+	if span.TraceID != callerTrace || span.ParentSpanID != callerSpan || span.SpanID != gatewaySpan {
+		t.Fatalf("unexpected span ids %+v", span)
+	}
+	if span.Name != "tool_call RawSearch" || span.Status.Code != 2 || span.Status.Message != "boom" {
+		t.Fatalf("unexpected span %+v", span)
+	}
+	if len(spans.pending) != 0 {
+		t.Fatalf("expected no pending spans, got %d", len(spans.pending))
+	}
+}
+
+func TestSpanRecorderStartsTraceFromMessageID(t *testing.T) {
+	exporter := otlptrace.NewExporter(otlptrace.Config{Endpoint: "http://127.0.0.1:0", FlushInterval: time.Hour})
+	spans := NewSpanRecorder(exporter)
+
+	assign := &uap.Message{
+		Type:    uap.MsgTaskAssign,
+		ID:      "trace-9",
+		Payload: mustTrackerPayload(uap.TaskAssignPayload{TaskID: "task-9"}),
+	}
+	spans.Observe(nil, assign)
+	traceID, _, ok := otlptrace.ParseTraceParent(assign.TraceParent)
+	if !ok || traceID != otlptrace.TraceIDFrom("trace-9") {
+		t.Fatalf("expected trace derived from message ID, got %q", assign.TraceParent)
+	}
+
+	spans.Observe(nil, &uap.Message{
+		Type:    uap.MsgTaskComplete,
+		Payload: mustTrackerPayload(uap.TaskCompletePayload{TaskID: "task-9", Status: "success"}),
+	})
+	if len(spans.pending) != 0 {
+		t.Fatalf("expected task span finished, pending=%d", len(spans.pending))
+	}
+
+	spans.Observe(nil, &uap.Message{Type: uap.MsgToolCall, ID: "late", Payload: mustTrackerPayload(uap.ToolCallPayload{})})
+	spans.expire(time.Now().Add(time.Hour), 30*time.Minute)
+	if len(spans.pending) != 0 {
+		t.Fatalf("expected unanswered span expired, pending=%d", len(spans.pending))
+	}
+}
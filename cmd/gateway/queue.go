@@ -0,0 +1,438 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"uap"
+)
+
+// ========================= 离线投递队列 =========================
+//
+// 发往"已知但离线"agent 的消息按类型选择性落盘暂存，目标重新注册后按入队顺序重放。
+// 过期或队列溢出时仍按原逻辑回复 agent_offline 错误。
+
+const knownAgentsFile = "known_agents.json"
+
+// QueueConfig 离线队列配置
+type QueueConfig struct {
+	Dir         string        // 持久化目录
+	MsgTypes    []string      // 允许暂存的消息类型（为空则不启用）
+	TTL         time.Duration // 单条消息存活时间
+	MaxPerAgent int           // 单个 agent 最大积压数
+}
+
+// knownAgent 曾经注册过的 agent
+type knownAgent struct {
+	Name string `json:"name"`
+	Type string `json:"agent_type"`
+}
+
+// queuedMessage 暂存的消息
+type queuedMessage struct {
+	Msg        *uap.Message `json:"msg"`
+	FromType   string       `json:"from_type,omitempty"` // 发送方 agent_type，重放时重新校验 ACL
+	EnqueuedAt int64        `json:"enqueued_at"`         // unix 毫秒
+	ExpiresAt  int64        `json:"expires_at"`          // unix 毫秒
+}
+
+// OfflineQueue 离线投递队列
+type OfflineQueue struct {
+	cfg       QueueConfig
+	types     map[string]bool
+	mu        sync.Mutex
+	pending   map[string][]*queuedMessage // agentID → 待投递消息（按入队顺序）
+	replaying map[string]bool             // agentID → 正在重放
+	known     map[string]knownAgent       // agentID → 曾经注册过的 agent
+
+	// 以下回调由 Router 绑定
+	lookup    func(agentID string) *uap.AgentConn                        // 查找在线 agent
+	reply     func(agentID string, msg *uap.Message) error               // 给发送方回复错误
+	authorize func(from, target *uap.AgentConn, msg *uap.Message) error  // 路由 ACL（可选），重放前重新校验
+	denied    func(from *uap.AgentConn, msg *uap.Message, reason string) // 重放被 ACL 拒绝时的记录回调
+	tracker   *Tracker
+}
+
+// NewOfflineQueue 创建离线队列并加载磁盘上的积压消息
+func NewOfflineQueue(cfg QueueConfig) (*OfflineQueue, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Minute
+	}
+	if cfg.MaxPerAgent <= 0 {
+		cfg.MaxPerAgent = 100
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create queue dir: %w", err)
+	}
+
+	q := &OfflineQueue{
+		cfg:       cfg,
+		types:     make(map[string]bool),
+		pending:   make(map[string][]*queuedMessage),
+		replaying: make(map[string]bool),
+		known:     make(map[string]knownAgent),
+	}
+	for _, t := range cfg.MsgTypes {
+		q.types[t] = true
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Remember 记录已注册 agent，使其离线后的消息可被暂存
+func (q *OfflineQueue) Remember(agent *uap.AgentConn) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	info := knownAgent{Name: agent.Name, Type: agent.AgentType}
+	if existing, ok := q.known[agent.ID]; ok && existing == info {
+		return
+	}
+	q.known[agent.ID] = info
+	q.saveKnownLocked()
+}
+
+// KnownType 返回已知 agent（ID 或名称）的 agent_type，未知返回空
+func (q *OfflineQueue) KnownType(idOrName string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if agentID := q.resolveLocked(idOrName); agentID != "" {
+		return q.known[agentID].Type
+	}
+	return ""
+}
+
+// Enqueue 尝试暂存发往离线 agent 的消息，返回 false 表示未接管（调用方应回复 agent_offline）
+func (q *OfflineQueue) Enqueue(from *uap.AgentConn, msg *uap.Message) bool {
+	if !q.types[msg.Type] {
+		return false
+	}
+
+	q.mu.Lock()
+	agentID := q.resolveLocked(msg.To)
+	if agentID == "" {
+		q.mu.Unlock()
+		return false
+	}
+	if len(q.pending[agentID]) >= q.cfg.MaxPerAgent {
+		q.mu.Unlock()
+		log.Printf("[Queue] queue for %s full (%d), rejecting %s from %s", agentID, q.cfg.MaxPerAgent, msg.Type, msg.From)
+		return false
+	}
+	now := time.Now()
+	fromType := ""
+	if from != nil {
+		fromType = from.AgentType
+	}
+	q.pending[agentID] = append(q.pending[agentID], &queuedMessage{
+		Msg:        msg,
+		FromType:   fromType,
+		EnqueuedAt: now.UnixMilli(),
+		ExpiresAt:  now.Add(q.cfg.TTL).UnixMilli(),
+	})
+	depth := len(q.pending[agentID])
+	q.saveAgentLocked(agentID)
+	q.mu.Unlock()
+
+	log.Printf("[Queue] queued %s from %s for offline agent %s (depth=%d)", msg.Type, msg.From, agentID, depth)
+	if q.tracker != nil {
+		q.tracker.RecordMessage(EventKindMsgQueued, from, nil, msg)
+	}
+
+	// 入队期间目标可能已重新注册，补一次重放避免消息滞留到下次上线
+	if q.lookup != nil {
+		if target := q.lookup(agentID); target != nil {
+			q.Replay(target)
+		}
+	}
+	return true
+}
+
+// Replay 按入队顺序向重新上线的 agent 投递积压消息
+// 发送在释放 q.mu 后进行，慢连接不会阻塞其他 agent 的入队与重放；
+// 同一 agent 同时只有一个重放者，重放期间新入队的消息由它继续投递
+func (q *OfflineQueue) Replay(agent *uap.AgentConn) {
+	q.mu.Lock()
+	if q.replaying[agent.ID] {
+		q.mu.Unlock()
+		return
+	}
+	q.replaying[agent.ID] = true
+	q.mu.Unlock()
+
+	delivered, expiredCount := 0, 0
+	for {
+		q.mu.Lock()
+		items := q.pending[agent.ID]
+		if len(items) == 0 {
+			delete(q.replaying, agent.ID)
+			q.mu.Unlock()
+			break
+		}
+		delete(q.pending, agent.ID)
+		q.saveAgentLocked(agent.ID)
+		q.mu.Unlock()
+
+		now := time.Now().UnixMilli()
+		var expired []*queuedMessage
+		for i, item := range items {
+			if item.ExpiresAt <= now {
+				expired = append(expired, item)
+				continue
+			}
+			// 入队后 ACL 可能已变化（或目标以另一类型重新注册），投递前重新校验
+			if q.denyReplay(item, agent) {
+				continue
+			}
+			if err := agent.Send(item.Msg); err != nil {
+				// 连接又断开，未发送的消息放回队首等待下次上线
+				log.Printf("[Queue] replay to %s failed: %v, keeping %d message(s)", agent.ID, err, len(items)-i)
+				q.mu.Lock()
+				q.pending[agent.ID] = append(append([]*queuedMessage(nil), items[i:]...), q.pending[agent.ID]...)
+				q.saveAgentLocked(agent.ID)
+				delete(q.replaying, agent.ID)
+				q.mu.Unlock()
+				q.reject(expired)
+				return
+			}
+			delivered++
+			if q.tracker != nil {
+				q.tracker.RecordMessage(EventKindMsgOut, nil, agent, item.Msg)
+			}
+		}
+		q.reject(expired)
+		expiredCount += len(expired)
+	}
+
+	if delivered > 0 || expiredCount > 0 {
+		log.Printf("[Queue] replayed %d message(s) to %s (expired=%d)", delivered, agent.ID, expiredCount)
+	}
+}
+
+// StartExpiry 定期清理过期消息并回复 agent_offline
+func (q *OfflineQueue) StartExpiry(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			q.expire(time.Now().UnixMilli())
+		}
+	}()
+}
+
+// expire 移除截止 now 已过期的消息
+func (q *OfflineQueue) expire(now int64) {
+	q.mu.Lock()
+	var expired []*queuedMessage
+	for agentID, items := range q.pending {
+		kept := items[:0]
+		for _, item := range items {
+			if item.ExpiresAt <= now {
+				expired = append(expired, item)
+			} else {
+				kept = append(kept, item)
+			}
+		}
+		if len(kept) == len(items) {
+			continue
+		}
+		if len(kept) == 0 {
+			delete(q.pending, agentID)
+		} else {
+			q.pending[agentID] = kept
+		}
+		q.saveAgentLocked(agentID)
+	}
+	q.mu.Unlock()
+	q.reject(expired)
+}
+
+// Depth 返回各 agent 的积压数量
+func (q *OfflineQueue) Depth() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make(map[string]int, len(q.pending))
+	for agentID, items := range q.pending {
+		result[agentID] = len(items)
+	}
+	return result
+}
+
+// denyReplay 按当前 ACL 校验积压消息，拒绝时回复 route_denied 并返回 true
+func (q *OfflineQueue) denyReplay(item *queuedMessage, target *uap.AgentConn) bool {
+	if q.authorize == nil {
+		return false
+	}
+	msg := item.Msg
+	var from *uap.AgentConn
+	if q.lookup != nil {
+		from = q.lookup(msg.From)
+	}
+	if from == nil {
+		fromType := item.FromType
+		if fromType == "" {
+			fromType = q.KnownType(msg.From)
+		}
+		from = &uap.AgentConn{ID: msg.From, AgentType: fromType}
+	}
+	err := q.authorize(from, target, msg)
+	if err == nil {
+		return false
+	}
+
+	log.Printf("[Queue] replay denied %s → %s (type=%s): %v", msg.From, target.ID, msg.Type, err)
+	if q.denied != nil {
+		q.denied(from, msg, err.Error())
+	}
+	if q.reply != nil && msg.From != "" {
+		payload, _ := json.Marshal(uap.ErrorPayload{Code: "route_denied", Message: err.Error()})
+		denial := &uap.Message{
+			Type:    uap.MsgError,
+			ID:      msg.ID,
+			From:    "gateway",
+			To:      msg.From,
+			Payload: payload,
+			Ts:      time.Now().UnixMilli(),
+		}
+		if err := q.reply(msg.From, denial); err != nil {
+			log.Printf("[Queue] notify sender %s of denial failed: %v", msg.From, err)
+		}
+	}
+	return true
+}
+
+// resolveLocked 将 To（ID 或名称）解析为已知 agent ID
+func (q *OfflineQueue) resolveLocked(idOrName string) string {
+	if _, ok := q.known[idOrName]; ok {
+		return idOrName
+	}
+	for id, info := range q.known {
+		if info.Name == idOrName {
+			return id
+		}
+	}
+	return ""
+}
+
+// reject 对过期消息回复 agent_offline（发送方不在线则仅记录日志），调用方不得持有 q.mu
+func (q *OfflineQueue) reject(items []*queuedMessage) {
+	for _, item := range items {
+		log.Printf("[Queue] message %s (%s) to %s expired", item.Msg.ID, item.Msg.Type, item.Msg.To)
+		if q.tracker != nil {
+			q.tracker.RecordMessage(EventKindRouteErr, nil, nil, item.Msg)
+		}
+		if q.reply == nil || item.Msg.From == "" {
+			continue
+		}
+		if err := q.reply(item.Msg.From, uap.AgentOfflineError(item.Msg)); err != nil {
+			log.Printf("[Queue] notify sender %s of expiry failed: %v", item.Msg.From, err)
+		}
+	}
+}
+
+// ========================= 持久化 =========================
+
+func (q *OfflineQueue) agentFile(agentID string) string {
+	return filepath.Join(q.cfg.Dir, "queue_"+sanitizeFileName(agentID)+".jsonl")
+}
+
+// saveAgentLocked 重写单个 agent 的队列文件（为空则删除）
+func (q *OfflineQueue) saveAgentLocked(agentID string) {
+	path := q.agentFile(agentID)
+	items := q.pending[agentID]
+	if len(items) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("[Queue] remove %s failed: %v", path, err)
+		}
+		return
+	}
+
+	var sb strings.Builder
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			continue
+		}
+		sb.Write(data)
+		sb.WriteByte('\n')
+	}
+	if err := writeFileAtomic(path, []byte(sb.String())); err != nil {
+		log.Printf("[Queue] save %s failed: %v", path, err)
+	}
+}
+
+func (q *OfflineQueue) saveKnownLocked() {
+	data, err := json.MarshalIndent(q.known, "", "  ")
+	if err != nil {
+		return
+	}
+	path := filepath.Join(q.cfg.Dir, knownAgentsFile)
+	if err := writeFileAtomic(path, data); err != nil {
+		log.Printf("[Queue] save %s failed: %v", path, err)
+	}
+}
+
+// load 启动时加载已知 agent 和积压消息
+func (q *OfflineQueue) load() error {
+	if data, err := os.ReadFile(filepath.Join(q.cfg.Dir, knownAgentsFile)); err == nil {
+		if err := json.Unmarshal(data, &q.known); err != nil {
+			return fmt.Errorf("parse %s: %w", knownAgentsFile, err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(q.cfg.Dir, "queue_*.jsonl"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			var item queuedMessage
+			if err := json.Unmarshal([]byte(line), &item); err != nil || item.Msg == nil {
+				log.Printf("[Queue] skip corrupt entry in %s", path)
+				continue
+			}
+			agentID := q.resolveLocked(item.Msg.To)
+			if agentID == "" {
+				agentID = item.Msg.To
+			}
+			q.pending[agentID] = append(q.pending[agentID], &item)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic 先写临时文件再 rename，避免崩溃时留下半截文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// sanitizeFileName 将 agent ID 转为安全文件名
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, s)
+}
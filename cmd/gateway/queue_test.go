@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"uap"
+)
+
+func newTestQueue(t *testing.T, dir string, max int) *OfflineQueue {
+	t.Helper()
+	q, err := NewOfflineQueue(QueueConfig{
+		Dir:         dir,
+		MsgTypes:    []string{uap.MsgToolCall, uap.MsgNotify},
+		TTL:         time.Minute,
+		MaxPerAgent: max,
+	})
+	if err != nil {
+		t.Fatalf("NewOfflineQueue error: %v", err)
+	}
+	return q
+}
+
+func TestOfflineQueueOnlyAcceptsKnownAgentsAndConfiguredTypes(t *testing.T) {
+	q := newTestQueue(t, t.TempDir(), 10)
+	q.Remember(&uap.AgentConn{ID: "cron-1", Name: "cron-agent"})
+
+	if q.Enqueue(nil, &uap.Message{Type: uap.MsgToolCall, ID: "m1", From: "llm", To: "unknown-agent"}) {
+		t.Fatalf("expected unknown target to be rejected")
+	}
+	if q.Enqueue(nil, &uap.Message{Type: uap.MsgTaskAssign, ID: "m2", From: "llm", To: "cron-1"}) {
+		t.Fatalf("expected unconfigured msg type to be rejected")
+	}
+	if !q.Enqueue(nil, &uap.Message{Type: uap.MsgToolCall, ID: "m3", From: "llm", To: "cron-agent"}) {
+		t.Fatalf("expected message addressed by name to be queued")
+	}
+	if depth := q.Depth()["cron-1"]; depth != 1 {
+		t.Fatalf("expected depth 1 for cron-1, got %d", depth)
+	}
+}
+
+func TestOfflineQueueRejectsOnOverflow(t *testing.T) {
+	q := newTestQueue(t, t.TempDir(), 2)
+	q.Remember(&uap.AgentConn{ID: "wechat-1", Name: "wechat-agent"})
+
+	for i, id := range []string{"a", "b", "c"} {
+		ok := q.Enqueue(nil, &uap.Message{Type: uap.MsgNotify, ID: id, From: "cron", To: "wechat-1"})
+		if want := i < 2; ok != want {
+			t.Fatalf("enqueue %s: expected %v, got %v", id, want, ok)
+		}
+	}
+}
+
+func TestOfflineQueuePersistsAcrossRestartInOrder(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t, dir, 10)
+	q.Remember(&uap.AgentConn{ID: "cron-1", Name: "cron-agent"})
+	for _, id := range []string{"first", "second"} {
+		q.Enqueue(nil, &uap.Message{Type: uap.MsgToolCall, ID: id, From: "llm", To: "cron-1"})
+	}
+
+	reloaded := newTestQueue(t, dir, 10)
+	items := reloaded.pending["cron-1"]
+	if len(items) != 2 || items[0].Msg.ID != "first" || items[1].Msg.ID != "second" {
+		t.Fatalf("unexpected reloaded queue: %+v", items)
+	}
+
+	// 连接不可用时重放失败，消息保留
+	reloaded.Replay(&uap.AgentConn{ID: "cron-1", Name: "cron-agent"})
+	if depth := reloaded.Depth()["cron-1"]; depth != 2 {
+		t.Fatalf("expected messages kept after failed replay, got depth %d", depth)
+	}
+}
+
+func TestOfflineQueueExpiryRepliesAgentOffline(t *testing.T) {
+	q := newTestQueue(t, t.TempDir(), 10)
+	q.Remember(&uap.AgentConn{ID: "cron-1", Name: "cron-agent"})
+
+	var replies []*uap.Message
+	q.reply = func(agentID string, msg *uap.Message) error {
+		if agentID != "llm" {
+			t.Fatalf("expected reply to sender llm, got %s", agentID)
+		}
+		replies = append(replies, msg)
+		return nil
+	}
+	q.Enqueue(nil, &uap.Message{Type: uap.MsgToolCall, ID: "m1", From: "llm", To: "cron-1"})
+	q.expire(time.Now().Add(2 * time.Minute).UnixMilli())
+
+	if len(replies) != 1 {
+		t.Fatalf("expected 1 expiry reply, got %d", len(replies))
+	}
+	var payload uap.ErrorPayload
+	json.Unmarshal(replies[0].Payload, &payload)
+	if replies[0].ID != "m1" || payload.Code != "agent_offline" {
+		t.Fatalf("unexpected expiry reply: id=%s code=%s", replies[0].ID, payload.Code)
+	}
+	if len(q.Depth()) != 0 {
+		t.Fatalf("expected queue to be empty after expiry")
+	}
+}
+
+func TestTrackerStatsIncludesQueueDepth(t *testing.T) {
+	tracker, err := NewTracker(&TrackerConfig{BufferSize: 16, LogDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewTracker error: %v", err)
+	}
+	defer tracker.Close()
+
+	q := newTestQueue(t, t.TempDir(), 10)
+	q.tracker = tracker
+	tracker.SetQueue(q)
+	q.Remember(&uap.AgentConn{ID: "cron-1", Name: "cron-agent"})
+	q.Enqueue(nil, &uap.Message{Type: uap.MsgNotify, ID: "n1", From: "llm", To: "cron-1"})
+
+	stats := tracker.Stats()
+	if stats["queue_depth"] != 1 {
+		t.Fatalf("expected queue_depth 1, got %v", stats["queue_depth"])
+	}
+	events, _ := tracker.Query(&EventQuery{Kind: EventKindMsgQueued})
+	if len(events) != 1 {
+		t.Fatalf("expected 1 msg_queued event, got %d", len(events))
+	}
+}
+
+func TestOfflineQueueRepliesWithoutHoldingLock(t *testing.T) {
+	q := newTestQueue(t, t.TempDir(), 10)
+	q.Remember(&uap.AgentConn{ID: "cron-1", Name: "cron-agent"})
+	q.Remember(&uap.AgentConn{ID: "wechat-1", Name: "wechat-agent"})
+
+	// 回复发送方时入队其他 agent 的消息：若仍持有 q.mu 会死锁
+	q.reply = func(agentID string, msg *uap.Message) error {
+		q.Enqueue(nil, &uap.Message{Type: uap.MsgNotify, ID: "n-" + msg.ID, From: "cron", To: "wechat-1"})
+		return nil
+	}
+	q.Enqueue(nil, &uap.Message{Type: uap.MsgToolCall, ID: "m1", From: "llm", To: "cron-1"})
+
+	done := make(chan struct{})
+	go func() {
+		q.expire(time.Now().Add(2 * time.Minute).UnixMilli())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expire blocked while replying to the sender")
+	}
+	if depth := q.Depth()["wechat-1"]; depth != 1 {
+		t.Fatalf("expected enqueue from reply callback to succeed, got depth %d", depth)
+	}
+}
+
+func TestOfflineQueueReplayRechecksRouteACL(t *testing.T) {
+	q := newTestQueue(t, t.TempDir(), 10)
+	q.Remember(&uap.AgentConn{ID: "deploy-1", Name: "deploy-agent", AgentType: "deploy"})
+
+	acl, _ := NewRouteACL([]RouteRule{
+		{MsgTypes: []string{uap.MsgToolCall}, From: []string{"llm"}, To: []string{"deploy"}},
+	})
+	q.authorize = acl.Authorize(q.KnownType)
+	var replies []*uap.Message
+	q.reply = func(agentID string, msg *uap.Message) error {
+		replies = append(replies, msg)
+		return nil
+	}
+	q.Enqueue(&uap.AgentConn{ID: "img", AgentType: "image_agent"}, &uap.Message{Type: uap.MsgToolCall, ID: "m1", From: "img", To: "deploy-1"})
+
+	// 消息被拒绝，不会走到 Send（测试用的 AgentConn 没有连接）
+	q.Replay(&uap.AgentConn{ID: "deploy-1", AgentType: "deploy"})
+
+	if len(replies) != 1 {
+		t.Fatalf("expected 1 denial reply, got %d", len(replies))
+	}
+	var payload uap.ErrorPayload
+	json.Unmarshal(replies[0].Payload, &payload)
+	if replies[0].ID != "m1" || payload.Code != "route_denied" {
+		t.Fatalf("unexpected denial reply: id=%s code=%s", replies[0].ID, payload.Code)
+	}
+	if len(q.Depth()) != 0 {
+		t.Fatalf("denied message should be dropped from the queue")
+	}
+}
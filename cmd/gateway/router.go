@@ -14,12 +14,16 @@ type Router struct {
 	registry *Registry
 	server   *uap.Server
 	tracker  *Tracker
+	queue    *OfflineQueue
+	spans    *SpanRecorder
 }
 
 // NewRouter 创建路由器
-func NewRouter(cfg *Config, registry *Registry, tracker *Tracker) *Router {
+func NewRouter(cfg *Config, registry *Registry, tracker *Tracker, queue *OfflineQueue, acl *RouteACL, spans *SpanRecorder) *Router {
 	server := uap.NewServer()
 	server.AuthToken = cfg.AuthToken
+	server.TokenSecret = cfg.AgentTokenSecret
+	server.RouteStrategy = cfg.RouteStrategy
 
 	// 绑定注册表（含 tracker）
 	registry.SetServer(server, tracker)
@@ -43,6 +47,64 @@ func NewRouter(cfg *Config, registry *Registry, tracker *Tracker) *Router {
 		server.OnHeartbeatTimeout = func(agent *uap.AgentConn) {
 			tracker.RecordLifecycle(EventKindHBTimeout, agent, "heartbeat timeout")
 		}
+		server.OnRouteDenied = func(from *uap.AgentConn, msg *uap.Message, reason string) {
+			tracker.RecordMessage(EventKindRouteDeny, from, nil, msg)
+		}
+	}
+
+	// 绑定 OTLP span 导出：在 tracker 之前观察消息，转发前写入 gateway span 的 traceparent
+	if spans != nil {
+		onReceived := server.OnMessageReceived
+		server.OnMessageReceived = func(from *uap.AgentConn, msg *uap.Message) {
+			spans.Observe(from, msg)
+			if onReceived != nil {
+				onReceived(from, msg)
+			}
+		}
+		onRouteErr := server.OnRouteError
+		server.OnRouteError = func(from *uap.AgentConn, msg *uap.Message) {
+			spans.Fail(msg, "target unavailable: "+msg.To)
+			if onRouteErr != nil {
+				onRouteErr(from, msg)
+			}
+		}
+		onDenied := server.OnRouteDenied
+		server.OnRouteDenied = func(from *uap.AgentConn, msg *uap.Message, reason string) {
+			spans.Fail(msg, "route denied: "+reason)
+			if onDenied != nil {
+				onDenied(from, msg, reason)
+			}
+		}
+	}
+
+	// 绑定路由 ACL（离线目标的类型从离线队列的已知 agent 中解析）
+	if acl != nil {
+		var knownType func(string) string
+		if queue != nil {
+			knownType = queue.KnownType
+		}
+		server.AuthorizeRoute = acl.Authorize(knownType)
+	}
+
+	// 绑定离线投递队列：目标离线时暂存，重新注册后重放
+	if queue != nil {
+		queue.lookup = server.GetAgent
+		queue.reply = server.SendToAgent
+		queue.authorize = server.AuthorizeRoute
+		queue.denied = server.OnRouteDenied
+		queue.tracker = tracker
+		if tracker != nil {
+			tracker.SetQueue(queue)
+		}
+		server.OnTargetOffline = queue.Enqueue
+		onOnline := server.OnAgentOnline
+		server.OnAgentOnline = func(agent *uap.AgentConn) {
+			if onOnline != nil {
+				onOnline(agent)
+			}
+			queue.Remember(agent)
+			queue.Replay(agent)
+		}
 	}
 
 	return &Router{
@@ -50,6 +112,8 @@ func NewRouter(cfg *Config, registry *Registry, tracker *Tracker) *Router {
 		registry: registry,
 		server:   server,
 		tracker:  tracker,
+		queue:    queue,
+		spans:    spans,
 	}
 }
 
@@ -61,4 +125,10 @@ func (r *Router) HandleUAP(w http.ResponseWriter, req *http.Request) {
 // StartHealthCheck 启动心跳检测
 func (r *Router) StartHealthCheck() {
 	r.registry.StartHealthCheck(120 * time.Second)
+	if r.queue != nil {
+		r.queue.StartExpiry(30 * time.Second)
+	}
+	if r.spans != nil {
+		r.spans.StartExpiry(30*time.Minute, time.Minute)
+	}
 }
@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -24,6 +22,8 @@ const (
 	EventKindAgentOff  = "agent_offline" // agent 下线
 	EventKindHBTimeout = "hb_timeout"    // 心跳超时
 	EventKindRouteErr  = "route_error"   // 路由失败（目标离线）
+	EventKindMsgQueued = "msg_queued"    // 目标离线，消息进入离线队列
+	EventKindRouteDeny = "route_denied"  // 路由被 ACL 拒绝
 )
 
 // ========================= Event 数据模型 =========================
@@ -96,7 +96,7 @@ func (rb *RingBuffer) Push(e *Event) uint64 {
 	return seq
 }
 
-// Query 按条件过滤查询
+// Query 按条件过滤查询，结果从新到旧
 func (rb *RingBuffer) Query(q *EventQuery) ([]Event, int) {
 	rb.mu.RLock()
 	defer rb.mu.RUnlock()
@@ -109,15 +109,9 @@ func (rb *RingBuffer) Query(q *EventQuery) ([]Event, int) {
 	var matched []Event
 	total := 0
 
-	// 从最旧到最新遍历
-	start := 0
-	length := rb.count
-	if rb.count == rb.size {
-		start = rb.head // 环形缓冲区已满，head 指向最旧
-	}
-
-	for i := 0; i < length; i++ {
-		idx := (start + i) % rb.size
+	// 从最新到最旧遍历（head 前一个位置是最新事件）
+	for i := 0; i < rb.count; i++ {
+		idx := (rb.head - 1 - i + rb.size) % rb.size
 		e := &rb.buf[idx]
 
 		if !matchEvent(e, q) {
@@ -178,6 +172,20 @@ func (rb *RingBuffer) Stats() (byKind map[string]int64, byMsgType map[string]int
 	return
 }
 
+// OldestTs 返回缓冲区中最旧事件的时间戳，缓冲区为空时 ok=false
+func (rb *RingBuffer) OldestTs() (ts int64, ok bool) {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	if rb.count == 0 {
+		return 0, false
+	}
+	start := 0
+	if rb.count == rb.size {
+		start = rb.head
+	}
+	return rb.buf[start].Ts, true
+}
+
 // Used 返回缓冲区中有效事件数
 func (rb *RingBuffer) Used() int {
 	rb.mu.RLock()
@@ -213,62 +221,6 @@ func matchEvent(e *Event, q *EventQuery) bool {
 	return true
 }
 
-// ========================= JSONLWriter 文件写入 =========================
-
-// JSONLWriter 按天 JSONL 日志写入
-type JSONLWriter struct {
-	mu      sync.Mutex
-	dir     string
-	current *os.File
-	today   string
-}
-
-// NewJSONLWriter 创建 JSONL 写入器
-func NewJSONLWriter(dir string) (*JSONLWriter, error) {
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, fmt.Errorf("create event log dir: %w", err)
-	}
-	return &JSONLWriter{dir: dir}, nil
-}
-
-// Write 写入一条事件到 JSONL 文件
-func (w *JSONLWriter) Write(e *Event) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	today := time.Now().Format("2006-01-02")
-	if w.current == nil || w.today != today {
-		if w.current != nil {
-			w.current.Close()
-		}
-		path := filepath.Join(w.dir, fmt.Sprintf("events_%s.jsonl", today))
-		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-		if err != nil {
-			log.Printf("[Tracker] open event log failed: %v", err)
-			return
-		}
-		w.current = f
-		w.today = today
-	}
-
-	data, err := json.Marshal(e)
-	if err != nil {
-		return
-	}
-	w.current.Write(data)
-	w.current.Write([]byte("\n"))
-}
-
-// Close 关闭文件
-func (w *JSONLWriter) Close() {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	if w.current != nil {
-		w.current.Close()
-		w.current = nil
-	}
-}
-
 // ========================= TrackerConfig =========================
 
 // TrackerConfig Tracker 配置
@@ -277,6 +229,9 @@ type TrackerConfig struct {
 	LogDir     string // JSONL 日志目录
 	LogStdout  bool   // 是否输出到终端
 	SkipHB     bool   // 是否跳过 heartbeat 事件
+
+	RetentionDays    int // 历史分段保留天数（0=永久保留）
+	CompactAfterDays int // 超过 N 天的分段去除心跳事件（0=不压缩）
 }
 
 // ========================= Tracker 主体 =========================
@@ -284,25 +239,30 @@ type TrackerConfig struct {
 // Tracker 全量事件追踪器
 type Tracker struct {
 	ring       *RingBuffer
-	writer     *JSONLWriter
+	store      *EventStore
 	logStdout  bool
 	skipHB     bool
 	mu         sync.RWMutex
 	taskTraces map[string]string // taskID → traceID
 	traceStart map[string]int64  // traceID → 起始时间戳(ms)
 	agentNames map[string]string // agentID → name 缓存
+	queue      *OfflineQueue     // 离线投递队列（可选，用于统计积压深度）
 }
 
 // NewTracker 创建事件追踪器
 func NewTracker(cfg *TrackerConfig) (*Tracker, error) {
-	writer, err := NewJSONLWriter(cfg.LogDir)
+	store, err := NewEventStore(EventStoreConfig{
+		Dir:              cfg.LogDir,
+		RetentionDays:    cfg.RetentionDays,
+		CompactAfterDays: cfg.CompactAfterDays,
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	t := &Tracker{
 		ring:       NewRingBuffer(cfg.BufferSize),
-		writer:     writer,
+		store:      store,
 		logStdout:  cfg.LogStdout,
 		skipHB:     cfg.SkipHB,
 		taskTraces: make(map[string]string),
@@ -313,6 +273,9 @@ func NewTracker(cfg *TrackerConfig) (*Tracker, error) {
 	// 启动 taskTraces 清理协程（1 小时 TTL）
 	go t.cleanupLoop()
 
+	// 历史分段保留期清理 / 压缩
+	store.StartMaintenance(time.Hour)
+
 	return t, nil
 }
 
@@ -337,7 +300,7 @@ func (t *Tracker) Record(e *Event) {
 	t.ring.Push(e)
 
 	// 写入 JSONL 文件
-	t.writer.Write(e)
+	t.store.Write(e)
 
 	// 终端日志
 	if t.logStdout {
@@ -411,17 +374,59 @@ func (t *Tracker) RecordLifecycle(kind string, agent *uap.AgentConn, detail stri
 	t.Record(e)
 }
 
-// Query 按条件查询事件
+// SetQueue 绑定离线投递队列，Stats 中输出积压深度
+func (t *Tracker) SetQueue(q *OfflineQueue) {
+	t.mu.Lock()
+	t.queue = q
+	t.mu.Unlock()
+}
+
+// Query 按条件查询事件（从新到旧）；RingBuffer 无法覆盖查询范围时转到持久化存储
 func (t *Tracker) Query(q *EventQuery) ([]Event, int) {
-	return t.ring.Query(q)
+	events, total := t.ring.Query(q)
+	if t.needsStore(q, total) {
+		return t.store.Query(q)
+	}
+	return events, total
+}
+
+// needsStore 判断查询是否超出 RingBuffer 的覆盖范围，ringTotal 为缓冲区内的命中数
+func (t *Tracker) needsStore(q *EventQuery, ringTotal int) bool {
+	if t.store == nil {
+		return false
+	}
+	oldest, ok := t.ring.OldestTs()
+	if !ok {
+		return true
+	}
+	if q.Since > 0 {
+		return q.Since < oldest
+	}
+	// 未指定起始时间：只有按 trace / agent 过滤时才回溯历史，其余查询只看最近事件
+	if q.TraceID == "" && q.Agent == "" {
+		return false
+	}
+	// 持久化存储中没有早于缓冲区的事件，缓冲区即完整历史
+	if storeOldest, ok := t.store.OldestTs(); !ok || storeOldest >= oldest {
+		return false
+	}
+	// 结果从新到旧，请求的这一页已全部落在缓冲区内
+	limit := q.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	return ringTotal < q.Offset+limit
 }
 
-// GetTrace 获取完整调用链
+// GetTrace 获取完整调用链（按时间先后）
 func (t *Tracker) GetTrace(traceID string) []Event {
-	events, _ := t.ring.Query(&EventQuery{
+	events, _ := t.Query(&EventQuery{
 		TraceID: traceID,
 		Limit:   1000,
 	})
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
 	return events
 }
 
@@ -431,9 +436,10 @@ func (t *Tracker) Stats() map[string]any {
 
 	t.mu.RLock()
 	activeTraces := len(t.traceStart)
+	queue := t.queue
 	t.mu.RUnlock()
 
-	return map[string]any{
+	stats := map[string]any{
 		"buffer_capacity": t.ring.size,
 		"buffer_used":     t.ring.Used(),
 		"total_recorded":  t.ring.TotalRecorded(),
@@ -441,12 +447,23 @@ func (t *Tracker) Stats() map[string]any {
 		"by_msg_type":     byMsgType,
 		"by_agent":        byAgent,
 		"active_traces":   activeTraces,
+		"store":           t.store.Stats(),
+	}
+	if queue != nil {
+		depth := queue.Depth()
+		total := 0
+		for _, n := range depth {
+			total += n
+		}
+		stats["queue_depth"] = total
+		stats["queue_by_agent"] = depth
 	}
+	return stats
 }
 
 // Close 关闭追踪器
 func (t *Tracker) Close() {
-	t.writer.Close()
+	t.store.Close()
 }
 
 // ========================= Trace ID 关联 =========================
@@ -82,3 +82,23 @@ func mustTrackerPayload(v any) []byte {
 	data, _ := json.Marshal(v)
 	return data
 }
+
+func TestTrackerQueryReturnsNewestFirst(t *testing.T) {
+	tracker, err := NewTracker(&TrackerConfig{BufferSize: 16, LogDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewTracker error: %v", err)
+	}
+	defer tracker.Close()
+
+	now := time.Now().UnixMilli()
+	for i := 0; i < 3; i++ {
+		tracker.Record(&Event{Ts: now + int64(i), Kind: EventKindMsgIn, TraceID: "t", From: "a"})
+	}
+	events, total := tracker.Query(&EventQuery{Agent: "a", Limit: 2})
+	if total != 3 || len(events) != 2 || events[0].Ts != now+2 || events[1].Ts != now+1 {
+		t.Fatalf("expected newest-first page from ring buffer, got total=%d events=%+v", total, events)
+	}
+	if trace := tracker.GetTrace("t"); len(trace) != 3 || trace[0].Ts != now {
+		t.Fatalf("GetTrace should stay chronological, got %+v", trace)
+	}
+}
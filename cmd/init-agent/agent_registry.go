@@ -126,6 +126,7 @@ func AllAgentSchemas() []AgentSchema {
 			Fields: []ConfigField{
 				{Key: "admin", Label: "管理员账号", Description: "管理员用户名", Type: FieldString, Required: true, DefaultValue: "admin", Group: "custom"},
 				{Key: "port", Label: "HTTP 端口", Description: "博客服务监听端口", Type: FieldPort, Required: true, DefaultValue: "8080", Group: "custom"},
+				{Key: "storage_backend", Label: "存储后端", Description: "redis | file | bolt（file/bolt 无需 Redis）", Type: FieldString, Required: false, DefaultValue: "redis", Group: "custom"},
 				{Key: "redis_ip", Label: "Redis 地址", Description: "Redis 服务器 IP", Type: FieldString, Required: true, DefaultValue: "127.0.0.1", Group: "custom"},
 				{Key: "redis_port", Label: "Redis 端口", Description: "Redis 端口号", Type: FieldPort, Required: true, DefaultValue: "6379", Group: "custom"},
 				{Key: "redis_pwd", Label: "Redis 密码", Description: "Redis 密码（可空）", Type: FieldString, Required: false, DefaultValue: "", Group: "custom"},
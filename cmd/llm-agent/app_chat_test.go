@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
@@ -103,3 +104,12 @@ func TestCortanaRequestIDCanBeAttachedToAudioReplyMeta(t *testing.T) {
 		t.Fatalf("unexpected cortana_request_id: %#v", got)
 	}
 }
+
+func TestPreprocessGroupAppMessageIncludesPersona(t *testing.T) {
+	b := &Bridge{}
+	content := appMessageJSONPrefix + `{"kind":"app_message","user_id":"ztt","message_type":"text","content":"讲个笑话","scope":"group","group_id":"family","persona":"家庭助手，回答简短"}`
+	got := b.preprocessAppMessage(context.Background(), "", "ztt", content)
+	if !strings.Contains(got, "[群人设] 家庭助手，回答简短") || !strings.HasPrefix(got, "讲个笑话") {
+		t.Fatalf("group persona should be passed to the model: %q", got)
+	}
+}
@@ -19,6 +19,7 @@ type appInboundMessage struct {
 	Content     string                `json:"content"`
 	Scope       string                `json:"scope"`
 	GroupID     string                `json:"group_id,omitempty"`
+	Persona     string                `json:"persona,omitempty"`
 	Attachment  *appInboundAttachment `json:"attachment,omitempty"`
 	Meta        map[string]any        `json:"meta,omitempty"`
 }
@@ -74,6 +75,9 @@ func (b *Bridge) preprocessAppMessage(ctx context.Context, fromAgent, appUser, c
 	}
 	if msg.Scope == "group" && strings.TrimSpace(msg.GroupID) != "" {
 		sections = append(sections, fmt.Sprintf("[群聊消息] group_id=%s", msg.GroupID))
+		if persona := strings.TrimSpace(msg.Persona); persona != "" {
+			sections = append(sections, "[群人设] "+persona)
+		}
 	}
 
 	if msg.Attachment != nil {
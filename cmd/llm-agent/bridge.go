@@ -92,6 +92,10 @@ type Bridge struct {
 	llmTools    []LLMTool         // LLM function calling 工具列表
 	catalogMu   sync.RWMutex
 
+	// 远端返回工具不存在时的按需刷新（限频，避免等待 60s 定时刷新）
+	staleRefreshMu   sync.Mutex
+	staleRefreshLast time.Time
+
 	// agent 感知存储（两级路由用）
 	agentInfo  map[string]AgentInfo // agent_id → 元数据
 	agentTools map[string][]LLMTool // agent_id → 该 agent 的工具列表
@@ -100,6 +104,9 @@ type Bridge struct {
 	pending map[string]chan *toolResultWithFrom // request_id → result channel
 	pendMu  sync.Mutex
 
+	// 链路追踪：task_assign 携带的 traceparent（taskID → traceparent）
+	taskTraceParents sync.Map
+
 	// 工具调用进度转发（deploy-agent 等发送的 tool_progress 事件）
 	toolProgressSinks map[string]EventSink // msgID → sink
 	toolProgressMu    sync.Mutex
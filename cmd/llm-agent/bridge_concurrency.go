@@ -24,6 +24,7 @@ func (b *Bridge) registerTask(taskID, taskType string) {
 
 // deregisterTask 注销活跃任务，并尝试从队列消费下一个
 func (b *Bridge) deregisterTask(taskID string) {
+	b.taskTraceParents.Delete(taskID)
 	b.activeTaskMu.Lock()
 	delete(b.activeTasks, taskID)
 	active := len(b.activeTasks)
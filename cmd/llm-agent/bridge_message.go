@@ -181,6 +181,11 @@ func (b *Bridge) handleMessage(msg *uap.Message) {
 			return
 		}
 
+		// 记录上游 traceparent，任务 RequestTrace 挂到 gateway span 下（deregisterTask 时清理）
+		if msg.TraceParent != "" {
+			b.taskTraceParents.Store(taskPayload.TaskID, msg.TraceParent)
+		}
+
 		// 统一发送 task_accepted（无论直接执行还是入队，都告知 gateway 已收到）
 		b.client.Send(&uap.Message{
 			Type:    uap.MsgTaskAccepted,
@@ -207,6 +212,7 @@ func (b *Bridge) handleMessage(msg *uap.Message) {
 			// 入队成功，等待 drainQueue 触发执行
 		} else {
 			// 队列也满了，发送 task_rejected
+			b.taskTraceParents.Delete(taskPayload.TaskID)
 			b.client.Send(&uap.Message{
 				Type: uap.MsgTaskRejected,
 				ID:   uap.NewMsgID(),
@@ -562,7 +562,8 @@ func (b *Bridge) callRemoteAgent(ctx context.Context, toolName, agentID string,
 			Arguments:         args,
 			AuthenticatedUser: authenticatedUser, // 传递认证用户
 		}),
-		Ts: time.Now().UnixMilli(),
+		Ts:          time.Now().UnixMilli(),
+		TraceParent: uap.TraceParentFrom(ctx),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("send tool_call: %v", err)
@@ -580,6 +581,9 @@ func (b *Bridge) callRemoteAgent(ctx context.Context, toolName, agentID string,
 	select {
 	case result := <-ch:
 		if !result.Success {
+			if isStaleToolError(result.Error) {
+				b.refreshStaleTools(toolName)
+			}
 			return &ToolCallResult{Result: result.Result, AgentID: agentID, FromID: result.FromID},
 				fmt.Errorf("tool error: %s", result.Error)
 		}
@@ -605,7 +609,7 @@ func (b *Bridge) callRemoteAgent(ctx context.Context, toolName, agentID string,
 		if strings.HasPrefix(toolName, "Acp") && agentID != "" {
 			go func() {
 				cancelMsg := &uap.Message{
-					Type: "tool_cancel",
+					Type: uap.MsgToolCancel,
 					ID:   uap.NewMsgID(),
 					From: b.cfg.AgentID,
 					To:   agentID,
@@ -652,3 +656,32 @@ func isTerminalAsyncToolProgress(content string) bool {
 	content = strings.TrimSpace(content)
 	return strings.HasPrefix(content, "✅") || strings.HasPrefix(content, "❌")
 }
+
+// staleRefreshInterval 按需刷新工具目录的最小间隔
+const staleRefreshInterval = 5 * time.Second
+
+// isStaleToolError 远端已下线该工具（如 mcp-agent 的子服务器宕机或被过滤）
+func isStaleToolError(errMsg string) bool {
+	if strings.Contains(errMsg, "unknown tool") {
+		return true
+	}
+	return strings.HasPrefix(errMsg, "server ") && (strings.HasSuffix(errMsg, " not available") || strings.HasSuffix(errMsg, " not found"))
+}
+
+// refreshStaleTools 工具目录已过期时后台立即刷新，不等待定时刷新
+func (b *Bridge) refreshStaleTools(toolName string) {
+	b.staleRefreshMu.Lock()
+	if time.Since(b.staleRefreshLast) < staleRefreshInterval {
+		b.staleRefreshMu.Unlock()
+		return
+	}
+	b.staleRefreshLast = time.Now()
+	b.staleRefreshMu.Unlock()
+
+	log.Printf("[Bridge] tool %s no longer available remotely, refreshing tool catalog", toolName)
+	go func() {
+		if err := b.DiscoverTools(); err != nil {
+			log.Printf("[Bridge] refresh tools failed: %v", err)
+		}
+	}()
+}
@@ -115,6 +115,11 @@ type Config struct {
 	ToolEvalOnStartup  bool   `json:"tool_eval_on_startup"`  // 启动时自动评估工具（默认 true）
 	ToolEvalReportPath string `json:"tool_eval_report_path"` // 评估报告输出路径（默认 workspace/tool_eval_report.json）
 
+	// OTLP 链路导出（OTLP/HTTP JSON，如本地 Jaeger: http://127.0.0.1:4318；为空则关闭）
+	OTLPEndpoint    string            `json:"otlp_endpoint,omitempty"`
+	OTLPServiceName string            `json:"otlp_service_name,omitempty"` // resource service.name（默认 agent_id）
+	OTLPHeaders     map[string]string `json:"otlp_headers,omitempty"`      // 附加请求头（如 collector 鉴权）
+
 	// 部署保护文件（deploy-agent 增量部署时跳过这些文件）
 	ProtectedFiles []string `json:"protected_files,omitempty"`
 }
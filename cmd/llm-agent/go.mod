@@ -20,3 +20,7 @@ require (
 )
 
 replace deploygen => ../common/deploygen
+
+require otlptrace v0.0.0
+
+replace otlptrace => ../common/otlptrace
@@ -54,6 +54,12 @@ func main() {
 	log.Printf("[LLM-MCP] LLM model=%s, base_url=%s", cfg.LLM.Model, cfg.LLM.BaseURL)
 	log.Printf("[LLM-MCP] concurrency: MaxConcurrent=%d TaskQueueSize=%d", cfg.MaxConcurrent, cfg.TaskQueueSize)
 
+	// OTLP 链路导出
+	initTraceExporter(cfg)
+	if traceExporter != nil {
+		log.Printf("[LLM-MCP] OTLP trace export enabled (endpoint=%s)", cfg.OTLPEndpoint)
+	}
+
 	// 创建 Bridge
 	bridge := NewBridge(cfg)
 
@@ -95,6 +101,7 @@ func main() {
 		<-sigCh
 		log.Println("[LLM-MCP] shutting down...")
 		bridge.Stop()
+		traceExporter.Shutdown()
 		os.Exit(0)
 	}()
 
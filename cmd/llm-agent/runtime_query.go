@@ -138,6 +138,7 @@ func (b *Bridge) prepareQueryRuntime(ctx *TaskContext) (*QueryLoop, error) {
 			store.Save(rootSession)
 			store.SaveIndex(rootSession, nil)
 			trace := NewRequestTrace(ctx.TaskID, ctx.Source, "root_query", query, rootSession)
+			trace.AdoptTraceParent(b.traceParentFor(ctx))
 			trace.SetDescription(query)
 			trace.RecordPath("direct_reply", "命中 MCP 工具列表直答分支", nil)
 			trace.RecordEvent("direct_reply", "直接返回工具目录", directReply, 0, nil)
@@ -219,6 +220,7 @@ func (b *Bridge) prepareQueryRuntime(ctx *TaskContext) (*QueryLoop, error) {
 	ctx.CurrentSession = rootSession
 
 	trace := NewRequestTrace(ctx.TaskID, ctx.Source, "root_query", query, rootSession)
+	trace.AdoptTraceParent(b.traceParentFor(ctx))
 	trace.SetDescription(query)
 	trace.RecordPath("task_start", "进入根任务 QueryLoop", map[string]string{
 		"source":   fallbackText(strings.TrimSpace(ctx.Source), "unknown"),
@@ -7,6 +7,8 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"uap"
 )
 
 type functionEventSink struct {
@@ -46,6 +48,8 @@ type ToolExecutionCall struct {
 	Total          int
 	ToolCall       ToolCall
 	TraceRound     *TraceRound
+
+	toolSpanID string // OTLP span ID（Execute 内分配）
 }
 
 type ToolExecutionResult struct {
@@ -76,6 +80,11 @@ func (rt *ToolExecutionRuntime) Execute(call ToolExecutionCall) ToolExecutionRes
 	if callCtx == nil {
 		callCtx = context.Background()
 	}
+	// 远程工具 / 子任务通过 traceparent 挂到本次工具调用 span 下
+	if spanID, traceParent := call.Trace.ToolTraceParent(); traceParent != "" {
+		call.toolSpanID = spanID
+		callCtx = uap.WithTraceParent(callCtx, traceParent)
+	}
 
 	tcResult, err, found := rt.dispatch(call, callCtx, originalName)
 	if !found {
@@ -110,15 +119,44 @@ func (rt *ToolExecutionRuntime) dispatch(call ToolExecutionCall, callCtx context
 		return rt.dispatchSkill(call, callCtx)
 	}
 
+	args := call.ToolCall.Function.Arguments
+	if originalName == "ExecuteCode" {
+		args = withExecuteCodeSession(args, call.TaskID)
+	}
+
 	progressSink := call.Sink
-	result, err := rt.bridge.DispatchTool(callCtx, originalName, json.RawMessage(call.ToolCall.Function.Arguments), progressSink)
+	result, err := rt.bridge.DispatchTool(callCtx, originalName, json.RawMessage(args), progressSink)
 	if err == nil {
 		return result, nil, true
 	}
-	result, err = rt.bridge.CallToolCtxWithProgress(callCtx, originalName, json.RawMessage(call.ToolCall.Function.Arguments), progressSink)
+	result, err = rt.bridge.CallToolCtxWithProgress(callCtx, originalName, json.RawMessage(args), progressSink)
 	return result, err, true
 }
 
+// withExecuteCodeSession ExecuteCode 指定 persistent=true 但未带 session_id 时，以当前任务 ID 作为会话 ID，
+// 同一任务内的多次 ExecuteCode 共享解释器状态
+func withExecuteCodeSession(args, taskID string) string {
+	if taskID == "" {
+		return args
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(args), &m); err != nil {
+		return args
+	}
+	if persistent, _ := m["persistent"].(bool); !persistent {
+		return args
+	}
+	if sessionID, _ := m["session_id"].(string); sessionID != "" {
+		return args
+	}
+	m["session_id"] = taskID
+	data, err := json.Marshal(m)
+	if err != nil {
+		return args
+	}
+	return string(data)
+}
+
 func (rt *ToolExecutionRuntime) dispatchLocal(callCtx context.Context, originalName, args string, handler ToolHandler, sink EventSink) (*ToolCallResult, error, bool) {
 	type toolCallResultPair struct {
 		result *ToolCallResult
@@ -239,6 +277,8 @@ func (rt *ToolExecutionRuntime) finish(call ToolExecutionCall, originalName, res
 			BusinessErr:   truncate(strings.TrimSpace(bizErr), 200),
 			ToAgent:       toAgent,
 			FromAgent:     fromAgent,
+			StartedAt:     time.Now().Add(-duration).UnixMilli(),
+			SpanID:        call.toolSpanID,
 		})
 	}
 	if call.Trace != nil {
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"testing"
+	"time"
 )
 
 func testTool(name string) LLMTool {
@@ -109,3 +110,44 @@ func TestBashToolRejectsSourceWriteCommands(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestWithExecuteCodeSession(t *testing.T) {
+	cases := []struct {
+		args, want string
+	}{
+		{`{"code":"x=1","persistent":true}`, `{"code":"x=1","persistent":true,"session_id":"task-9"}`},
+		{`{"code":"x=1","persistent":true,"session_id":"mine"}`, `{"code":"x=1","persistent":true,"session_id":"mine"}`},
+		{`{"code":"x=1"}`, `{"code":"x=1"}`},
+		{`not json`, `not json`},
+	}
+	for _, c := range cases {
+		if got := withExecuteCodeSession(c.args, "task-9"); got != c.want {
+			t.Fatalf("withExecuteCodeSession(%s) = %s, want %s", c.args, got, c.want)
+		}
+	}
+}
+
+func TestIsStaleToolError(t *testing.T) {
+	stale := []string{
+		"unknown tool: mcp.fs.read_file",
+		"server fs not available",
+		"server fs not found",
+	}
+	for _, msg := range stale {
+		if !isStaleToolError(msg) {
+			t.Fatalf("expected %q to trigger a catalog refresh", msg)
+		}
+	}
+	if isStaleToolError("file not found: /tmp/a.txt") {
+		t.Fatalf("business errors must not trigger a catalog refresh")
+	}
+}
+
+func TestRefreshStaleToolsIsRateLimited(t *testing.T) {
+	b := &Bridge{}
+	b.staleRefreshLast = time.Now()
+	b.refreshStaleTools("mcp.fs.read_file")
+	if time.Since(b.staleRefreshLast) > time.Second {
+		t.Fatalf("refresh within the interval should be skipped")
+	}
+}
@@ -160,6 +160,7 @@ func (b *Bridge) runSkillSubtask(ctx *TaskContext, skillName, query string, pare
 	}
 
 	trace := NewRequestTrace(ctx.TaskID, ctx.Source, "skill_subtask", query, session)
+	trace.AdoptTraceParent(b.traceParentFor(ctx))
 	trace.SetDescription(query)
 	trace.SetToolView(toolView)
 	trace.RecordPath("skill_lookup", fmt.Sprintf("匹配 skill=%s", skillName), map[string]string{
@@ -5,6 +5,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"otlptrace"
 )
 
 const requestTraceVersion = 2
@@ -32,6 +34,11 @@ type RequestTrace struct {
 	ChildSessions   []TraceChildSession `json:"child_sessions,omitempty"`
 	ResultPreview   string              `json:"result_preview,omitempty"`
 	Error           string              `json:"error,omitempty"`
+
+	// OTLP 链路标识（见 trace_otlp.go）
+	OTelTraceID      string `json:"otel_trace_id,omitempty"`
+	OTelSpanID       string `json:"otel_span_id,omitempty"`
+	OTelParentSpanID string `json:"otel_parent_span_id,omitempty"`
 }
 
 type TraceToolView struct {
@@ -73,6 +80,7 @@ type TraceChildSession struct {
 // TraceRound 单轮 LLM 调用记录。
 type TraceRound struct {
 	Index            int                     `json:"index"`
+	LLMStartedAt     int64                   `json:"llm_started_at,omitempty"` // unix 毫秒
 	LLMDurationMs    int64                   `json:"llm_duration_ms"`
 	TextLen          int                     `json:"text_len"`
 	AssistantPreview string                  `json:"assistant_preview,omitempty"`
@@ -96,6 +104,8 @@ type TraceToolCall struct {
 	BusinessErr   string `json:"business_error,omitempty"`
 	ToAgent       string `json:"to_agent,omitempty"`
 	FromAgent     string `json:"from_agent,omitempty"`
+	StartedAt     int64  `json:"started_at,omitempty"` // unix 毫秒
+	SpanID        string `json:"span_id,omitempty"`    // OTLP span ID（远程调用的 traceparent 指向此 span）
 }
 
 func NewRequestTrace(taskID, source, scope, query string, session *TaskSession) *RequestTrace {
@@ -109,6 +119,9 @@ func NewRequestTrace(taskID, source, scope, query string, session *TaskSession)
 		Status:    "running",
 		StartTime: now,
 		UpdatedAt: now,
+
+		OTelTraceID: otlptrace.NewTraceID(),
+		OTelSpanID:  otlptrace.NewSpanID(),
 	}
 	trace.RefreshFromSession(session)
 	return trace
@@ -218,6 +231,7 @@ func (t *RequestTrace) RecordRoundLLM(index int, duration time.Duration, text st
 	if round == nil {
 		return
 	}
+	round.LLMStartedAt = time.Now().Add(-duration).UnixMilli()
 	round.LLMDurationMs = duration.Milliseconds()
 	round.TextLen = len(text)
 	round.AssistantPreview = truncate(strings.TrimSpace(text), 300)
@@ -305,6 +319,7 @@ func (t *RequestTrace) Finish(status, result string, err error) {
 	now := time.Now()
 	t.FinishedAt = &now
 	t.UpdatedAt = now
+	t.exportSpans()
 }
 
 // Summary 输出结构化追踪摘要。
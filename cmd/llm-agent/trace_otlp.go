@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"otlptrace"
+	"uap"
+)
+
+// ========================= OTLP 链路导出 =========================
+//
+// 每个 RequestTrace 结束时导出一棵 span 树：
+//   session span（root_query / skill_subtask）
+//     ├─ llm.request  每轮 LLM 调用
+//     └─ tool <name>  每次工具调用；远程工具的 traceparent 指向此 span，
+//                      gateway span 与 execute_skill 子任务的 session span 都挂在它下面
+// 根任务若由 task_assign 触发，session span 挂到 gateway 写入的 traceparent 下，
+// 与 gateway / 其他 agent 的 span 组成同一条链路。
+
+// traceExporter 全局导出器（未配置 otlp_endpoint 时为 nil，导出为空操作）
+var traceExporter *otlptrace.Exporter
+
+// initTraceExporter 根据配置创建导出器
+func initTraceExporter(cfg *Config) {
+	traceExporter = otlptrace.NewExporter(otlptrace.Config{
+		Endpoint:    cfg.OTLPEndpoint,
+		ServiceName: fallbackText(cfg.OTLPServiceName, cfg.AgentID),
+		Headers:     cfg.OTLPHeaders,
+	})
+}
+
+// traceParentFor 解析任务的上游 traceparent：优先 context（工具调用链），其次 task_assign 消息
+func (b *Bridge) traceParentFor(ctx *TaskContext) string {
+	if ctx == nil {
+		return ""
+	}
+	if tp := uap.TraceParentFrom(ctx.Ctx); tp != "" {
+		return tp
+	}
+	if v, ok := b.taskTraceParents.Load(ctx.TaskID); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+// AdoptTraceParent 挂到上游 span 下（traceparent 无效时保持独立链路）
+func (t *RequestTrace) AdoptTraceParent(traceParent string) {
+	if t == nil {
+		return
+	}
+	if traceID, parentID, ok := otlptrace.ParseTraceParent(traceParent); ok {
+		t.OTelTraceID = traceID
+		t.OTelParentSpanID = parentID
+	}
+}
+
+// ToolTraceParent 为一次工具调用分配 span ID，返回传给下游的 traceparent
+func (t *RequestTrace) ToolTraceParent() (spanID, traceParent string) {
+	if t == nil || t.OTelTraceID == "" {
+		return "", ""
+	}
+	spanID = otlptrace.NewSpanID()
+	return spanID, otlptrace.FormatTraceParent(t.OTelTraceID, spanID)
+}
+
+// exportSpans 导出 session / LLM 轮次 / 工具调用 span
+func (t *RequestTrace) exportSpans() {
+	if traceExporter == nil || t == nil || t.OTelTraceID == "" || t.FinishedAt == nil {
+		return
+	}
+
+	kind := otlptrace.SpanKindInternal
+	if t.OTelParentSpanID != "" {
+		kind = otlptrace.SpanKindServer
+	}
+	sessionErr := t.Error
+	if sessionErr == "" && t.Status == "failed" {
+		sessionErr = "failed"
+	}
+	traceExporter.Export(&otlptrace.Span{
+		TraceID:      t.OTelTraceID,
+		SpanID:       t.OTelSpanID,
+		ParentSpanID: t.OTelParentSpanID,
+		Name:         "llm-agent " + fallbackText(t.Scope, "request"),
+		Kind:         kind,
+		Start:        t.StartTime,
+		End:          *t.FinishedAt,
+		Attributes: map[string]any{
+			"task.id":           t.TaskID,
+			"session.id":        t.SessionID,
+			"session.root_id":   t.RootID,
+			"session.parent_id": t.ParentSessionID,
+			"request.source":    t.Source,
+			"request.query":     truncate(t.Query, 200),
+			"request.status":    t.Status,
+			"llm.rounds":        len(t.Rounds),
+		},
+		Error: sessionErr,
+	})
+
+	for _, round := range t.Rounds {
+		if round.LLMStartedAt > 0 {
+			start := time.UnixMilli(round.LLMStartedAt)
+			traceExporter.Export(&otlptrace.Span{
+				TraceID:      t.OTelTraceID,
+				SpanID:       otlptrace.NewSpanID(),
+				ParentSpanID: t.OTelSpanID,
+				Name:         fmt.Sprintf("llm.request #%d", round.Index),
+				Kind:         otlptrace.SpanKindClient,
+				Start:        start,
+				End:          start.Add(time.Duration(round.LLMDurationMs) * time.Millisecond),
+				Attributes: map[string]any{
+					"llm.round":      round.Index,
+					"llm.text_len":   round.TextLen,
+					"llm.tool_calls": strings.Join(round.ToolCallNames, ","),
+				},
+			})
+		}
+		for _, tc := range round.ToolCalls {
+			if tc.StartedAt == 0 {
+				continue
+			}
+			spanID := tc.SpanID
+			if spanID == "" {
+				spanID = otlptrace.NewSpanID()
+			}
+			var toolErr string
+			switch {
+			case !tc.Success:
+				toolErr = fallbackText(tc.BusinessErr, "tool failed")
+			case tc.BusinessErr != "":
+				toolErr = tc.BusinessErr
+			}
+			start := time.UnixMilli(tc.StartedAt)
+			traceExporter.Export(&otlptrace.Span{
+				TraceID:      t.OTelTraceID,
+				SpanID:       spanID,
+				ParentSpanID: t.OTelSpanID,
+				Name:         "tool " + tc.ToolName,
+				Kind:         otlptrace.SpanKindClient,
+				Start:        start,
+				End:          start.Add(time.Duration(tc.DurationMs) * time.Millisecond),
+				Attributes: map[string]any{
+					"tool.name":       tc.ToolName,
+					"tool.call_id":    tc.ToolCallID,
+					"tool.to_agent":   tc.ToAgent,
+					"tool.from_agent": tc.FromAgent,
+					"tool.result_len": tc.ResultLen,
+					"llm.round":       round.Index,
+				},
+				Error: toolErr,
+			})
+		}
+	}
+}
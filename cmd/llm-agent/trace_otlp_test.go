@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"otlptrace"
+)
+
+func TestRequestTraceExportsSpanTree(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies <- data
+	}))
+	defer srv.Close()
+
+	prev := traceExporter
+	traceExporter = otlptrace.NewExporter(otlptrace.Config{Endpoint: srv.URL, ServiceName: "llm-agent", FlushInterval: time.Hour})
+	defer func() { traceExporter = prev }()
+
+	gatewayTrace, gatewaySpan := otlptrace.NewTraceID(), otlptrace.NewSpanID()
+	trace := NewRequestTrace("task-1", "web", "root_query", "hello", nil)
+	trace.AdoptTraceParent(otlptrace.FormatTraceParent(gatewayTrace, gatewaySpan))
+
+	toolSpan, traceParent := trace.ToolTraceParent()
+	if gotTrace, gotSpan, ok := otlptrace.ParseTraceParent(traceParent); !ok || gotTrace != gatewayTrace || gotSpan != toolSpan {
+		t.Fatalf("unexpected tool traceparent %q", traceParent)
+	}
+
+	trace.RecordRoundLLM(1, 200*time.Millisecond, "calling tool", nil, nil)
+	round := trace.EnsureRound(1)
+	round.ToolCalls = append(round.ToolCalls, TraceToolCall{
+		ToolName:   "RawSearch",
+		Success:    true,
+		DurationMs: 50,
+		StartedAt:  time.Now().UnixMilli(),
+		SpanID:     toolSpan,
+	})
+	trace.Finish("done", "ok", nil)
+	traceExporter.Shutdown()
+
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(<-bodies, &req); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("expected session + llm + tool spans, got %d", len(spans))
+	}
+	byName := make(map[string]int)
+	for i, s := range spans {
+		if s.TraceID != gatewayTrace {
+			t.Fatalf("span %s not in gateway trace", s.Name)
+		}
+		byName[s.Name] = i
+	}
+	session := spans[byName["llm-agent root_query"]]
+	if session.ParentSpanID != gatewaySpan || session.SpanID != trace.OTelSpanID {
+		t.Fatalf("session span not parented to gateway span: %+v", session)
+	}
+	if s := spans[byName["llm.request #1"]]; s.ParentSpanID != session.SpanID {
+		t.Fatalf("llm span not under session: %+v", s)
+	}
+	if s := spans[byName["tool RawSearch"]]; s.ParentSpanID != session.SpanID || s.SpanID != toolSpan {
+		t.Fatalf("tool span mismatch: %+v", s)
+	}
+}
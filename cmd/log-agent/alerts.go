@@ -0,0 +1,635 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agentbase"
+	"uap"
+)
+
+// ========================= 告警规则 =========================
+//
+// 每条规则增量跟随一个日志源文件（同 tail_follow），只统计启动后新写入的行：
+//   - 阈值：window_sec 内匹配数 >= threshold 触发
+//   - 速率：另设 baseline_sec 时，还要求当前窗口速率 > 前 baseline_sec 的平均速率 × rate_factor（基线未积累满前不触发）
+// 触发后 cooldown_sec 内不重复通知。规则与触发记录持久化到 alerts.rules_file。
+// 通知对象只能是设置规则的用户本人或 alerts.notify_targets 中配置的对象。
+
+// AlertTarget 告警通知对象
+type AlertTarget struct {
+	Channel string `json:"channel"` // wechat | app
+	To      string `json:"to"`      // 企业微信用户 / app 账号
+}
+
+// AlertRule 告警规则
+type AlertRule struct {
+	Name        string            `json:"name"`
+	Source      string            `json:"source"`
+	File        string            `json:"file,omitempty"` // 文件名，默认跟随目录下最新的 .log 文件
+	Keyword     string            `json:"keyword,omitempty"`
+	Regex       string            `json:"regex,omitempty"`
+	Level       string            `json:"level,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	WindowSec   int               `json:"window_sec"`
+	Threshold   int               `json:"threshold"`
+	BaselineSec int               `json:"baseline_sec,omitempty"`
+	RateFactor  float64           `json:"rate_factor,omitempty"`
+	CooldownSec int               `json:"cooldown_sec"`
+	MaxLines    int               `json:"max_lines"` // 通知中附带的匹配行数
+	Notify      []AlertTarget     `json:"notify"`
+	Enabled     bool              `json:"enabled"`
+	Owner       string            `json:"owner,omitempty"` // 创建规则的已认证用户，只有本人或管理员可替换/删除
+
+	LastFiredAt int64 `json:"last_fired_at,omitempty"` // unix 秒
+	FireCount   int   `json:"fire_count,omitempty"`
+}
+
+// withDefaults 填充规则默认值
+func (r AlertRule) withDefaults() AlertRule {
+	if r.WindowSec <= 0 {
+		r.WindowSec = 300
+	}
+	if r.Threshold <= 0 {
+		r.Threshold = 1
+	}
+	if r.BaselineSec > 0 && r.RateFactor <= 1 {
+		r.RateFactor = 3
+	}
+	if r.CooldownSec <= 0 {
+		r.CooldownSec = 600
+	}
+	if r.MaxLines <= 0 {
+		r.MaxLines = 10
+	}
+	if r.MaxLines > 50 {
+		r.MaxLines = 50
+	}
+	return r
+}
+
+// alertHit 一次匹配
+type alertHit struct {
+	at   time.Time
+	line string
+}
+
+// alertWatch 单条规则的运行时状态
+type alertWatch struct {
+	rule      AlertRule
+	follower  *tailFollower
+	hits      []alertHit
+	startedAt time.Time
+	notifying bool // 通知发送中，避免下一轮评估重复触发
+}
+
+// pendingAlert 已触发、待发送的告警
+type pendingAlert struct {
+	watch   *alertWatch
+	targets []AlertTarget
+	payload uap.NotifyPayload
+	firedAt int64
+}
+
+// AlertRuleStatus ListAlertRules 返回的规则状态
+type AlertRuleStatus struct {
+	AlertRule
+	WindowCount  int    `json:"window_count"`           // 当前窗口匹配数
+	CooldownLeft int    `json:"cooldown_remaining_sec"` // 剩余静默时间
+	Warming      bool   `json:"warming,omitempty"`      // 速率基线尚未积累满
+	LastFired    string `json:"last_fired,omitempty"`
+}
+
+// AlertEngine 告警规则引擎
+type AlertEngine struct {
+	mu      sync.Mutex
+	cfg     *Config
+	watches map[string]*alertWatch
+	now     func() time.Time
+	notify  func(target AlertTarget, payload uap.NotifyPayload) error
+	stopCh  chan struct{}
+	stopped sync.Once
+}
+
+// NewAlertEngine 创建告警引擎并加载持久化规则
+func NewAlertEngine(cfg *Config, notify func(target AlertTarget, payload uap.NotifyPayload) error) *AlertEngine {
+	e := &AlertEngine{
+		cfg:     cfg,
+		watches: make(map[string]*alertWatch),
+		now:     time.Now,
+		notify:  notify,
+		stopCh:  make(chan struct{}),
+	}
+	if err := e.load(); err != nil {
+		log.Printf("[Alert] load rules failed: %v", err)
+	}
+	return e
+}
+
+// Start 启动周期评估
+func (e *AlertEngine) Start() {
+	go func() {
+		ticker := time.NewTicker(time.Duration(e.cfg.Alerts.EvalSec) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stopCh:
+				return
+			case <-ticker.C:
+				e.Evaluate()
+			}
+		}
+	}()
+}
+
+// Stop 停止评估并关闭文件
+func (e *AlertEngine) Stop() {
+	e.stopped.Do(func() { close(e.stopCh) })
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, w := range e.watches {
+		w.follower.close()
+	}
+}
+
+// Evaluate 读取新增日志并评估所有启用的规则。
+// 评估在锁内进行，通知在释放锁后发送；至少一个通知对象送达才记录触发
+func (e *AlertEngine) Evaluate() {
+	e.mu.Lock()
+	now := e.now()
+	var pending []pendingAlert
+	for _, name := range e.sortedNames() {
+		w := e.watches[name]
+		if !w.rule.Enabled || w.notifying {
+			continue
+		}
+		w.follower.poll(false)
+		if p, ok := e.evaluateWatch(w, now); ok {
+			w.notifying = true
+			pending = append(pending, p)
+		}
+	}
+	e.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	delivered := make([]bool, len(pending))
+	for i, p := range pending {
+		for _, target := range p.targets {
+			if err := e.notify(target, p.payload); err != nil {
+				log.Printf("[Alert] rule=%s notify %s:%s failed: %v", p.watch.rule.Name, target.Channel, target.To, err)
+				continue
+			}
+			delivered[i] = true
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	changed := false
+	for i, p := range pending {
+		p.watch.notifying = false
+		if !delivered[i] {
+			log.Printf("[Alert] rule=%s fired but no notification delivered, will retry", p.watch.rule.Name)
+			continue
+		}
+		// 规则在发送期间被替换或删除时不再记录
+		if e.watches[p.watch.rule.Name] != p.watch {
+			continue
+		}
+		p.watch.rule.LastFiredAt = p.firedAt
+		p.watch.rule.FireCount++
+		changed = true
+	}
+	if changed {
+		e.save()
+	}
+}
+
+// evaluateWatch 评估单条规则，触发时返回待发送的通知（调用前需持有 mu 锁）
+func (e *AlertEngine) evaluateWatch(w *alertWatch, now time.Time) (pendingAlert, bool) {
+	rule := w.rule
+	window := time.Duration(rule.WindowSec) * time.Second
+	baseline := time.Duration(rule.BaselineSec) * time.Second
+
+	// 丢弃超出窗口 + 基线的匹配
+	cutoff := now.Add(-window - baseline)
+	kept := w.hits[:0]
+	for _, h := range w.hits {
+		if h.at.After(cutoff) {
+			kept = append(kept, h)
+		}
+	}
+	w.hits = kept
+
+	windowStart := now.Add(-window)
+	var current []alertHit
+	baselineCount := 0
+	for _, h := range w.hits {
+		if h.at.After(windowStart) {
+			current = append(current, h)
+		} else {
+			baselineCount++
+		}
+	}
+
+	if len(current) < rule.Threshold {
+		return pendingAlert{}, false
+	}
+	rateNote := ""
+	if rule.BaselineSec > 0 {
+		if now.Sub(w.startedAt) < window+baseline {
+			return pendingAlert{}, false
+		}
+		currentRate := float64(len(current)) / window.Minutes()
+		baselineRate := float64(baselineCount) / baseline.Minutes()
+		if currentRate <= baselineRate*rule.RateFactor {
+			return pendingAlert{}, false
+		}
+		rateNote = fmt.Sprintf("，速率 %.1f/分钟（基线 %.1f/分钟）", currentRate, baselineRate)
+	}
+	if rule.LastFiredAt > 0 && now.Sub(time.Unix(rule.LastFiredAt, 0)) < time.Duration(rule.CooldownSec)*time.Second {
+		return pendingAlert{}, false
+	}
+
+	lines := make([]string, 0, rule.MaxLines)
+	for _, h := range current[max(len(current)-rule.MaxLines, 0):] {
+		lines = append(lines, h.line)
+	}
+	file := rule.File
+	if w.follower.path != "" {
+		file = w.follower.info.Name()
+	}
+	content := fmt.Sprintf("🚨 日志告警: %s\n源: %s %s\n%s 内匹配 %d 条（阈值 %d）%s\n最近匹配:\n%s",
+		rule.Name, rule.Source, file, formatWindow(window), len(current), rule.Threshold, rateNote, strings.Join(lines, "\n"))
+	payload := uap.NotifyPayload{
+		Content: content,
+		Meta: map[string]any{
+			"alert_rule": rule.Name,
+			"source":     rule.Source,
+			"file":       file,
+			"count":      len(current),
+			"window_sec": rule.WindowSec,
+			"lines":      lines,
+		},
+	}
+	log.Printf("[Alert] rule=%s fired count=%d window=%v", rule.Name, len(current), window)
+	targets := append([]AlertTarget(nil), rule.Notify...)
+	return pendingAlert{watch: w, targets: targets, payload: payload, firedAt: now.Unix()}, true
+}
+
+// ========================= 规则管理 =========================
+
+// SetRule 新建或替换规则（保留触发记录与所有者）。caller 为设置规则的已认证用户，
+// 通知对象须为 caller 本人或 alerts.notify_targets 中的配置项；替换他人规则需为管理员
+func (e *AlertEngine) SetRule(rule AlertRule, caller string) (AlertRule, error) {
+	if caller == "" {
+		return rule, fmt.Errorf("缺少已认证用户，拒绝设置告警规则")
+	}
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return rule, fmt.Errorf("缺少 name")
+	}
+	if len(rule.Notify) == 0 {
+		return rule, fmt.Errorf("notify 不能为空")
+	}
+	for _, t := range rule.Notify {
+		if (t.Channel != "wechat" && t.Channel != "app") || t.To == "" {
+			return rule, fmt.Errorf("notify 项需要 channel(wechat/app) 与 to")
+		}
+		if !e.notifyAllowed(t, caller) {
+			return rule, fmt.Errorf("不允许通知 %s:%s，只能通知自己或 alerts.notify_targets 中的对象", t.Channel, t.To)
+		}
+	}
+	rule = rule.withDefaults()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rule.Owner = caller
+	if old, ok := e.watches[rule.Name]; ok {
+		if !e.canManage(old.rule, caller) {
+			return rule, fmt.Errorf("规则 %s 属于 %s，只有本人或管理员可以替换", rule.Name, ownerLabel(old.rule))
+		}
+		rule.LastFiredAt, rule.FireCount = old.rule.LastFiredAt, old.rule.FireCount
+		if old.rule.Owner != "" {
+			rule.Owner = old.rule.Owner
+		}
+	}
+	w, err := e.newWatch(rule)
+	if err != nil {
+		return rule, err
+	}
+	if old, ok := e.watches[rule.Name]; ok {
+		old.follower.close()
+	}
+	e.watches[rule.Name] = w
+	e.save()
+	log.Printf("[Alert] rule %s saved by %s (source=%s enabled=%v)", rule.Name, caller, rule.Source, rule.Enabled)
+	return rule, nil
+}
+
+// notifyAllowed 通知对象是否为调用者本人或配置允许的对象
+func (e *AlertEngine) notifyAllowed(t AlertTarget, caller string) bool {
+	if caller != "" && t.To == caller {
+		return true
+	}
+	for _, allowed := range e.cfg.Alerts.NotifyTargets {
+		if allowed.Channel == t.Channel && allowed.To == t.To {
+			return true
+		}
+	}
+	return false
+}
+
+// canManage caller 是否可以替换/删除规则：规则所有者或管理员（无所有者的规则只有管理员可管理）
+func (e *AlertEngine) canManage(rule AlertRule, caller string) bool {
+	if caller == "" {
+		return false
+	}
+	if rule.Owner != "" && rule.Owner == caller {
+		return true
+	}
+	for _, admin := range e.cfg.Alerts.Admins {
+		if admin == caller {
+			return true
+		}
+	}
+	return false
+}
+
+func ownerLabel(rule AlertRule) string {
+	if rule.Owner == "" {
+		return "管理员"
+	}
+	return rule.Owner
+}
+
+// DeleteRule 删除规则，只有规则所有者或管理员可以删除
+func (e *AlertEngine) DeleteRule(name, caller string) error {
+	if caller == "" {
+		return fmt.Errorf("缺少已认证用户，拒绝删除告警规则")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w, ok := e.watches[name]
+	if !ok {
+		return fmt.Errorf("规则 %s 不存在", name)
+	}
+	if !e.canManage(w.rule, caller) {
+		return fmt.Errorf("规则 %s 属于 %s，只有本人或管理员可以删除", name, ownerLabel(w.rule))
+	}
+	w.follower.close()
+	delete(e.watches, name)
+	e.save()
+	log.Printf("[Alert] rule %s deleted by %s", name, caller)
+	return nil
+}
+
+// Rules 规则及运行状态（按名称排序）
+func (e *AlertEngine) Rules() []AlertRuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	statuses := make([]AlertRuleStatus, 0, len(e.watches))
+	for _, name := range e.sortedNames() {
+		w := e.watches[name]
+		st := AlertRuleStatus{AlertRule: w.rule}
+		windowStart := now.Add(-time.Duration(w.rule.WindowSec) * time.Second)
+		for _, h := range w.hits {
+			if h.at.After(windowStart) {
+				st.WindowCount++
+			}
+		}
+		if w.rule.LastFiredAt > 0 {
+			fired := time.Unix(w.rule.LastFiredAt, 0)
+			st.LastFired = fired.Format("2006-01-02 15:04:05")
+			if remain := time.Duration(w.rule.CooldownSec)*time.Second - now.Sub(fired); remain > 0 {
+				st.CooldownLeft = int(remain.Seconds())
+			}
+		}
+		if w.rule.BaselineSec > 0 {
+			st.Warming = now.Sub(w.startedAt) < time.Duration(w.rule.WindowSec+w.rule.BaselineSec)*time.Second
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// newWatch 校验规则并从文件末尾开始跟随
+func (e *AlertEngine) newWatch(rule AlertRule) (*alertWatch, error) {
+	src, ok := e.cfg.LogSources[rule.Source]
+	if !ok {
+		return nil, fmt.Errorf("未知日志源 %s", rule.Source)
+	}
+	if !validFollowFile(rule.File) {
+		return nil, fmt.Errorf("file 只能是日志源目录下的文件名")
+	}
+	filter, err := newFollowFilter(rule.Keyword, rule.Regex, rule.Level, rule.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &alertWatch{rule: rule, startedAt: e.now()}
+	w.follower = &tailFollower{
+		spec: followSpec{dir: src.Path, file: rule.File, filter: filter, maxLines: 500},
+		emit: func(ev FollowEvent) {
+			if ev.Event != "lines" {
+				return
+			}
+			at := e.now()
+			for _, line := range ev.Lines {
+				w.hits = append(w.hits, alertHit{at: at, line: line})
+			}
+		},
+	}
+	w.follower.poll(true)
+	return w, nil
+}
+
+func (e *AlertEngine) sortedNames() []string {
+	names := make([]string, 0, len(e.watches))
+	for name := range e.watches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// load 从规则文件加载
+func (e *AlertEngine) load() error {
+	if e.cfg.Alerts.RulesFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(e.cfg.Alerts.RulesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var rules []AlertRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("解析规则文件失败: %v", err)
+	}
+	for _, rule := range rules {
+		w, err := e.newWatch(rule.withDefaults())
+		if err != nil {
+			log.Printf("[Alert] skip rule %s: %v", rule.Name, err)
+			continue
+		}
+		e.watches[rule.Name] = w
+	}
+	log.Printf("[Alert] loaded %d rules from %s", len(e.watches), e.cfg.Alerts.RulesFile)
+	return nil
+}
+
+// save 持久化规则（调用前需持有 mu 锁）
+func (e *AlertEngine) save() {
+	if e.cfg.Alerts.RulesFile == "" {
+		return
+	}
+	rules := make([]AlertRule, 0, len(e.watches))
+	for _, name := range e.sortedNames() {
+		rules = append(rules, e.watches[name].rule)
+	}
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		log.Printf("[Alert] marshal rules failed: %v", err)
+		return
+	}
+	if err := os.WriteFile(e.cfg.Alerts.RulesFile, data, 0644); err != nil {
+		log.Printf("[Alert] write rules file failed: %v", err)
+	}
+}
+
+func formatWindow(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%d 分钟", int(d.Minutes()))
+	}
+	return d.String()
+}
+
+// ========================= 告警工具 =========================
+
+// buildAlertToolDefs 告警规则管理工具定义
+func buildAlertToolDefs() []uap.ToolDef {
+	return []uap.ToolDef{
+		{
+			Name:        "ListAlertRules",
+			Description: "列出日志告警规则及状态（当前窗口匹配数、上次触发时间、剩余静默时间）",
+			Parameters:  agentbase.MustMarshalJSON(map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}),
+		},
+		{
+			Name:        "SetAlertRule",
+			Description: "新建或按名称替换日志告警规则。规则持续跟随日志源新写入的行，window_sec 内匹配数达到 threshold 时通过企业微信/app 通知，cooldown_sec 内不重复通知。同名规则只有创建者或管理员可以替换。设置 baseline_sec 时改为速率告警：当前窗口速率超过前 baseline_sec 平均速率的 rate_factor 倍才触发",
+			Parameters: agentbase.MustMarshalJSON(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":    map[string]interface{}{"type": "string", "description": "规则名（唯一）"},
+					"source":  map[string]interface{}{"type": "string", "description": "日志源名称"},
+					"file":    map[string]interface{}{"type": "string", "description": "文件名，不填则跟随目录下最新的 .log 文件"},
+					"keyword": map[string]interface{}{"type": "string", "description": "关键词过滤"},
+					"regex":   map[string]interface{}{"type": "string", "description": "正则过滤（RE2）"},
+					"level":   map[string]interface{}{"type": "string", "description": "日志级别，逗号分隔，如 error,fatal"},
+					"fields": map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+						"description":          "JSON 行字段过滤，语法同 QueryLog",
+					},
+					"window_sec":   map[string]interface{}{"type": "integer", "description": "统计窗口，默认 300"},
+					"threshold":    map[string]interface{}{"type": "integer", "description": "窗口内匹配数阈值，默认 1"},
+					"baseline_sec": map[string]interface{}{"type": "integer", "description": "速率基线时长，不填则为纯阈值告警"},
+					"rate_factor":  map[string]interface{}{"type": "number", "description": "速率倍数，默认 3"},
+					"cooldown_sec": map[string]interface{}{"type": "integer", "description": "触发后静默时间，默认 600"},
+					"max_lines":    map[string]interface{}{"type": "integer", "description": "通知附带的最近匹配行数，默认 10，上限 50"},
+					"notify": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"channel": map[string]interface{}{"type": "string", "enum": []string{"wechat", "app"}},
+								"to":      map[string]interface{}{"type": "string", "description": "企业微信用户 ID 或 app 账号"},
+							},
+							"required": []string{"channel", "to"},
+						},
+						"description": "通知对象列表，只能是自己或配置允许的对象",
+					},
+					"enabled": map[string]interface{}{"type": "boolean", "description": "是否启用，默认 true"},
+				},
+				"required": []string{"name", "source", "notify"},
+			}),
+		},
+		{
+			Name:        "DeleteAlertRule",
+			Description: "删除日志告警规则（只能删除自己创建的规则，管理员除外）",
+			Parameters: agentbase.MustMarshalJSON(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string", "description": "规则名"},
+				},
+				"required": []string{"name"},
+			}),
+		},
+	}
+}
+
+// sendAlert 通过 UAP notify 发送到 wechat-agent / app-agent
+func (c *Connection) sendAlert(target AlertTarget, payload uap.NotifyPayload) error {
+	agentID := c.cfg.Alerts.WechatAgentID
+	if target.Channel == "app" {
+		agentID = c.cfg.Alerts.AppAgentID
+	}
+	payload.Channel = target.Channel
+	payload.To = target.To
+	return c.Client.SendTo(agentID, uap.MsgNotify, payload)
+}
+
+// toolListAlertRules 列出告警规则
+func (c *Connection) toolListAlertRules() string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"success": true,
+		"rules":   c.alerts.Rules(),
+	})
+	return string(data)
+}
+
+// toolSetAlertRule 新建或替换告警规则
+func (c *Connection) toolSetAlertRule(raw json.RawMessage, caller string) string {
+	rule := AlertRule{Enabled: true}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &rule); err != nil {
+			return agentbase.ErrorJSON(fmt.Sprintf("参数解析失败: %v", err))
+		}
+	}
+	// 触发记录只由引擎维护
+	rule.LastFiredAt, rule.FireCount = 0, 0
+	saved, err := c.alerts.SetRule(rule, caller)
+	if err != nil {
+		return agentbase.ErrorJSON(err.Error())
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"success": true,
+		"rule":    saved,
+	})
+	return string(data)
+}
+
+// toolDeleteAlertRule 删除告警规则
+func (c *Connection) toolDeleteAlertRule(args map[string]interface{}, caller string) string {
+	name, _ := args["name"].(string)
+	if name == "" {
+		return agentbase.ErrorJSON("缺少 name 参数")
+	}
+	if err := c.alerts.DeleteRule(name, caller); err != nil {
+		return agentbase.ErrorJSON(err.Error())
+	}
+	return `{"success":true}`
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"uap"
+)
+
+type sentAlert struct {
+	target  AlertTarget
+	payload uap.NotifyPayload
+}
+
+// newAlertTestEngine 构造带假时钟与通知记录的告警引擎
+func newAlertTestEngine(t *testing.T, dir, rulesFile string) (*AlertEngine, *time.Time, *[]sentAlert) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.LogSources = map[string]LogSource{"svc": {Path: dir}}
+	cfg.Alerts.RulesFile = rulesFile
+	cfg.Alerts.NotifyTargets = []AlertTarget{{Channel: "wechat", To: "ops"}, {Channel: "app", To: "admin"}}
+
+	now := time.Now()
+	var sent []sentAlert
+	e := NewAlertEngine(cfg, func(target AlertTarget, payload uap.NotifyPayload) error {
+		sent = append(sent, sentAlert{target, payload})
+		return nil
+	})
+	e.now = func() time.Time { return now }
+	t.Cleanup(e.Stop)
+	return e, &now, &sent
+}
+
+func TestAlertThresholdWithCooldown(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "ERROR before start\n")
+
+	e, now, sent := newAlertTestEngine(t, dir, "")
+	_, err := e.SetRule(AlertRule{
+		Name: "errors", Source: "svc", File: "app.log", Level: "error",
+		WindowSec: 60, Threshold: 2, CooldownSec: 300, MaxLines: 1, Enabled: true,
+		Notify: []AlertTarget{{Channel: "wechat", To: "ops"}, {Channel: "app", To: "admin"}},
+	}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 启动前已有的行不计入；一条未达阈值
+	appendFile(t, path, "INFO ok\nERROR first\n")
+	e.Evaluate()
+	if len(*sent) != 0 {
+		t.Fatalf("should not fire below threshold: %+v", *sent)
+	}
+
+	appendFile(t, path, "ERROR second\n")
+	e.Evaluate()
+	if len(*sent) != 2 {
+		t.Fatalf("expected one notification per target, got %d", len(*sent))
+	}
+	first := (*sent)[0].payload
+	if (*sent)[1].target.Channel != "app" || first.Meta["count"] != 2 {
+		t.Fatalf("unexpected notification: %+v", *sent)
+	}
+	if !strings.Contains(first.Content, "ERROR second") || strings.Contains(first.Content, "ERROR first") {
+		t.Fatalf("max_lines should keep only the latest line: %s", first.Content)
+	}
+
+	// 静默期内不重复通知，静默结束且窗口内仍达阈值再次通知
+	appendFile(t, path, "ERROR third\nERROR fourth\n")
+	*now = now.Add(30 * time.Second)
+	e.Evaluate()
+	if len(*sent) != 2 {
+		t.Fatalf("should be silenced during cooldown, got %d", len(*sent))
+	}
+	*now = now.Add(5 * time.Minute)
+	appendFile(t, path, "ERROR fifth\nERROR sixth\n")
+	e.Evaluate()
+	if len(*sent) != 4 {
+		t.Fatalf("expected notification after cooldown, got %d", len(*sent))
+	}
+	if rules := e.Rules(); rules[0].FireCount != 2 || rules[0].WindowCount != 2 || rules[0].CooldownLeft < 299 {
+		t.Fatalf("unexpected rule status: %+v", rules[0])
+	}
+}
+
+func TestAlertRateOverBaseline(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "")
+
+	e, now, sent := newAlertTestEngine(t, dir, "")
+	if _, err := e.SetRule(AlertRule{
+		Name: "timeouts", Source: "svc", Keyword: "timeout",
+		WindowSec: 60, BaselineSec: 300, RateFactor: 2, Enabled: true,
+		Notify: []AlertTarget{{Channel: "wechat", To: "ops"}},
+	}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 基线期：每分钟 2 条
+	for i := 0; i < 6; i++ {
+		appendFile(t, path, "timeout a\ntimeout b\n")
+		e.Evaluate()
+		*now = now.Add(time.Minute)
+	}
+	if len(*sent) != 0 {
+		t.Fatalf("steady rate should not fire: %+v", *sent)
+	}
+	if rules := e.Rules(); rules[0].Warming {
+		t.Fatalf("baseline should be ready: %+v", rules[0])
+	}
+
+	// 速率升到每分钟 5 条（> 2 × 2）
+	appendFile(t, path, strings.Repeat("timeout burst\n", 5))
+	e.Evaluate()
+	if len(*sent) != 1 || !strings.Contains((*sent)[0].payload.Content, "基线") {
+		t.Fatalf("expected rate alert, got %+v", *sent)
+	}
+}
+
+func TestAlertRulesPersistAndValidate(t *testing.T) {
+	dir := t.TempDir()
+	rulesFile := filepath.Join(t.TempDir(), "log-agent-alerts.json")
+
+	e, _, _ := newAlertTestEngine(t, dir, rulesFile)
+	for _, bad := range []AlertRule{
+		{Name: "x", Source: "nope", Notify: []AlertTarget{{Channel: "wechat", To: "ops"}}},
+		{Name: "x", Source: "svc", Regex: "(", Notify: []AlertTarget{{Channel: "wechat", To: "ops"}}},
+		{Name: "x", Source: "svc", File: "../passwd", Notify: []AlertTarget{{Channel: "wechat", To: "ops"}}},
+		{Name: "x", Source: "svc", Notify: []AlertTarget{{Channel: "sms", To: "ops"}}},
+		{Name: "x", Source: "svc"},
+	} {
+		if _, err := e.SetRule(bad, "alice"); err == nil {
+			t.Fatalf("expected validation error for %+v", bad)
+		}
+	}
+
+	if _, err := e.SetRule(AlertRule{Name: "a", Source: "svc", Level: "fatal", Enabled: true, Notify: []AlertTarget{{Channel: "app", To: "admin"}}}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.SetRule(AlertRule{Name: "b", Source: "svc", Keyword: "panic", Notify: []AlertTarget{{Channel: "wechat", To: "ops"}}}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if e.DeleteRule("b", "alice") != nil || e.DeleteRule("b", "alice") == nil {
+		t.Fatal("delete should succeed exactly once")
+	}
+
+	reloaded, _, _ := newAlertTestEngine(t, dir, rulesFile)
+	rules := reloaded.Rules()
+	if len(rules) != 1 || rules[0].Name != "a" || rules[0].WindowSec != 300 || rules[0].CooldownSec != 600 || !rules[0].Enabled {
+		t.Fatalf("unexpected reloaded rules: %+v", rules)
+	}
+}
+
+func TestAlertNotifyTargetsRestricted(t *testing.T) {
+	e, _, _ := newAlertTestEngine(t, t.TempDir(), "")
+	stranger := AlertRule{Name: "x", Source: "svc", Notify: []AlertTarget{{Channel: "app", To: "bob"}}}
+	if _, err := e.SetRule(stranger, "alice"); err == nil {
+		t.Fatal("notifying another user should be rejected")
+	}
+	if _, err := e.SetRule(AlertRule{Name: "y", Source: "svc", Notify: []AlertTarget{{Channel: "wechat", To: "ops"}}}, ""); err == nil {
+		t.Fatal("anonymous caller should be rejected")
+	}
+	if _, err := e.SetRule(stranger, "bob"); err != nil {
+		t.Fatalf("caller should be able to notify themselves: %v", err)
+	}
+}
+
+func TestAlertRuleOwnership(t *testing.T) {
+	e, _, _ := newAlertTestEngine(t, t.TempDir(), "")
+	e.cfg.Alerts.Admins = []string{"root"}
+	rule := AlertRule{Name: "x", Source: "svc", Notify: []AlertTarget{{Channel: "wechat", To: "ops"}}}
+	saved, err := e.SetRule(rule, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Owner != "alice" {
+		t.Fatalf("owner = %q, want alice", saved.Owner)
+	}
+
+	if _, err := e.SetRule(rule, "bob"); err == nil {
+		t.Fatal("another user should not replace the rule")
+	}
+	if err := e.DeleteRule("x", "bob"); err == nil {
+		t.Fatal("another user should not delete the rule")
+	}
+	if err := e.DeleteRule("x", ""); err == nil {
+		t.Fatal("anonymous delete should be rejected")
+	}
+	if _, err := e.SetRule(rule, "alice"); err != nil {
+		t.Fatalf("owner should replace own rule: %v", err)
+	}
+
+	// 管理员可替换（所有者不变）和删除他人规则
+	saved, err = e.SetRule(rule, "root")
+	if err != nil || saved.Owner != "alice" {
+		t.Fatalf("admin replace: owner=%q err=%v", saved.Owner, err)
+	}
+	if err := e.DeleteRule("x", "root"); err != nil {
+		t.Fatalf("admin should delete any rule: %v", err)
+	}
+}
+
+func TestAlertFiresOnlyAfterDelivery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "")
+
+	e, _, sent := newAlertTestEngine(t, dir, "")
+	var failing atomic.Bool
+	failing.Store(true)
+	notify := e.notify
+	e.notify = func(target AlertTarget, payload uap.NotifyPayload) error {
+		// 发送期间引擎不应持有锁
+		if !e.mu.TryLock() {
+			t.Error("notify called while holding the engine lock")
+		} else {
+			e.mu.Unlock()
+		}
+		if failing.Load() {
+			return errors.New("agent offline")
+		}
+		return notify(target, payload)
+	}
+	if _, err := e.SetRule(AlertRule{
+		Name: "errors", Source: "svc", Keyword: "ERROR", Enabled: true,
+		Notify: []AlertTarget{{Channel: "wechat", To: "ops"}},
+	}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	appendFile(t, path, "ERROR boom\n")
+	e.Evaluate()
+	if rules := e.Rules(); rules[0].FireCount != 0 || rules[0].LastFiredAt != 0 {
+		t.Fatalf("failed delivery should not start cooldown: %+v", rules[0])
+	}
+
+	// 投递恢复后下一轮重试
+	failing.Store(false)
+	e.Evaluate()
+	if len(*sent) != 1 {
+		t.Fatalf("expected retry to deliver, got %d", len(*sent))
+	}
+	if rules := e.Rules(); rules[0].FireCount != 1 || rules[0].LastFiredAt == 0 {
+		t.Fatalf("delivery should record the firing: %+v", rules[0])
+	}
+}
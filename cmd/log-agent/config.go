@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 // LogSource 命名日志源配置
@@ -12,12 +13,38 @@ type LogSource struct {
 	Description string `json:"description"` // 描述（注入工具描述，帮助 LLM 理解）
 }
 
+// QueryConfig QueryLog 扫描上限
+type QueryConfig struct {
+	MaxFiles  int `json:"max_files"`   // 单次查询最多扫描的文件数（取最新的），默认 50
+	MaxScanMB int `json:"max_scan_mb"` // 单次查询最多扫描的日志字节数（gz 按解压后计），默认 512
+}
+
+// FollowConfig tail_follow 任务配置
+type FollowConfig struct {
+	MaxTasks int `json:"max_tasks"` // 并发跟随任务上限，默认 5
+	PollMs   int `json:"poll_ms"`   // 轮询间隔，默认 500
+	MaxSec   int `json:"max_sec"`   // 单个任务最长持续时间，默认 3600
+}
+
+// AlertConfig 日志告警配置
+type AlertConfig struct {
+	RulesFile     string        `json:"rules_file"`      // 规则持久化文件，默认与配置文件同目录的 log-agent-alerts.json
+	EvalSec       int           `json:"eval_sec"`        // 规则评估间隔，默认 15
+	WechatAgentID string        `json:"wechat_agent_id"` // channel=wechat 的通知目标 agent，默认 wechat-wechat-agent
+	AppAgentID    string        `json:"app_agent_id"`    // channel=app 的通知目标 agent，默认 app-app-agent
+	NotifyTargets []AlertTarget `json:"notify_targets"`  // 规则可通知的对象；未列出的对象只能由用户本人设置给自己
+	Admins        []string      `json:"admins"`          // 可替换/删除任意用户规则的管理员
+}
+
 // Config log-agent 配置
 type Config struct {
 	ServerURL  string               `json:"server_url"`
 	AuthToken  string               `json:"auth_token"`
 	AgentName  string               `json:"agent_name"`
 	LogSources map[string]LogSource `json:"log_sources"` // 源名 → 配置
+	Query      QueryConfig          `json:"query"`
+	Follow     FollowConfig         `json:"follow"`
+	Alerts     AlertConfig          `json:"alerts"`
 
 	// 部署保护文件（deploy-agent 增量部署时跳过这些文件）
 	ProtectedFiles []string `json:"protected_files,omitempty"`
@@ -29,8 +56,11 @@ func DefaultConfig() *Config {
 		ServerURL:  "ws://127.0.0.1:10086/ws/uap",
 		AgentName:  "log-agent",
 		LogSources: make(map[string]LogSource),
+		Query:      QueryConfig{MaxFiles: 50, MaxScanMB: 512},
+		Follow:     FollowConfig{MaxTasks: 5, PollMs: 500, MaxSec: 3600},
+		Alerts:     AlertConfig{EvalSec: 15, WechatAgentID: "wechat-wechat-agent", AppAgentID: "app-app-agent"},
 
-		ProtectedFiles: []string{"log-agent.json"},
+		ProtectedFiles: []string{"log-agent.json", "log-agent-alerts.json"},
 	}
 }
 
@@ -50,6 +80,33 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.AgentName == "" {
 		cfg.AgentName = "log-agent"
 	}
+	if cfg.Query.MaxFiles <= 0 {
+		cfg.Query.MaxFiles = 50
+	}
+	if cfg.Query.MaxScanMB <= 0 {
+		cfg.Query.MaxScanMB = 512
+	}
+	if cfg.Follow.MaxTasks <= 0 {
+		cfg.Follow.MaxTasks = 5
+	}
+	if cfg.Follow.PollMs <= 0 {
+		cfg.Follow.PollMs = 500
+	}
+	if cfg.Follow.MaxSec <= 0 {
+		cfg.Follow.MaxSec = 3600
+	}
+	if cfg.Alerts.RulesFile == "" {
+		cfg.Alerts.RulesFile = filepath.Join(filepath.Dir(path), "log-agent-alerts.json")
+	}
+	if cfg.Alerts.EvalSec <= 0 {
+		cfg.Alerts.EvalSec = 15
+	}
+	if cfg.Alerts.WechatAgentID == "" {
+		cfg.Alerts.WechatAgentID = "wechat-wechat-agent"
+	}
+	if cfg.Alerts.AppAgentID == "" {
+		cfg.Alerts.AppAgentID = "app-app-agent"
+	}
 
 	return cfg, nil
 }
@@ -21,6 +21,8 @@ type Connection struct {
 
 	cfg         *Config
 	logToolKit  *agentbase.LogToolKit
+	follows     *followManager
+	alerts      *AlertEngine
 	activeCount int32 // 活跃任务原子计数
 }
 
@@ -37,8 +39,10 @@ func NewConnection(cfg *Config, agentID string) *Connection {
 	sourcesStr := strings.Join(sourceNames, ", ")
 
 	readLogDesc := fmt.Sprintf("查询指定日志源的日志文件。可用源: %s。用 ListLogSources 查看详情", sourcesStr)
+	queryLogDesc := fmt.Sprintf("跨日志源全部文件（含 .gz 轮转文件）检索与统计：正则/级别/JSON 字段过滤，count_by 分组计数、histogram 时间分布。可用源: %s", sourcesStr)
 
-	tools := append(buildLogToolDefs(readLogDesc), logToolKit.ToolDefs()...)
+	tools := append(buildLogToolDefs(readLogDesc, queryLogDesc), buildAlertToolDefs()...)
+	tools = append(tools, logToolKit.ToolDefs()...)
 
 	baseCfg := &agentbase.Config{
 		ServerURL:   cfg.ServerURL,
@@ -51,6 +55,7 @@ func NewConnection(cfg *Config, agentID string) *Connection {
 		Tools:       tools,
 		Meta: map[string]any{
 			"log_sources": buildLogSourceSummary(cfg.LogSources),
+			"task_types":  []string{"tail_follow"},
 		},
 	}
 
@@ -58,16 +63,24 @@ func NewConnection(cfg *Config, agentID string) *Connection {
 		AgentBase:  agentbase.NewAgentBase(baseCfg),
 		cfg:        cfg,
 		logToolKit: logToolKit,
+		follows:    newFollowManager(),
 	}
+	c.alerts = NewAlertEngine(cfg, c.sendAlert)
 
 	c.RegisterToolCallHandler(c.handleToolCallMsg)
+	c.RegisterHandler(uap.MsgTaskAssign, c.handleTaskAssign)
+	c.RegisterHandler(uap.MsgTaskStop, c.handleTaskStop)
 	c.RegisterHandler(uap.MsgError, c.handleError)
+	c.OnShutdown = func() {
+		c.follows.StopAll()
+		c.alerts.Stop()
+	}
 
 	return c
 }
 
 // buildLogToolDefs 构建 log-agent 的工具定义
-func buildLogToolDefs(readLogDesc string) []uap.ToolDef {
+func buildLogToolDefs(readLogDesc, queryLogDesc string) []uap.ToolDef {
 	return []uap.ToolDef{
 		{
 			Name:        "ListLogSources",
@@ -108,6 +121,61 @@ func buildLogToolDefs(readLogDesc string) []uap.ToolDef {
 				"required": []string{"source"},
 			}),
 		},
+		{
+			Name:        "QueryLog",
+			Description: queryLogDesc,
+			Parameters: agentbase.MustMarshalJSON(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"source": map[string]interface{}{
+						"type":        "string",
+						"description": "日志源名称",
+					},
+					"file": map[string]interface{}{
+						"type":        "string",
+						"description": "文件名通配，如 access.log*，默认 *.log*（含 error.log.1、app.log.2.gz 等轮转文件）",
+					},
+					"keyword": map[string]interface{}{
+						"type":        "string",
+						"description": "关键词过滤",
+					},
+					"regex": map[string]interface{}{
+						"type":        "string",
+						"description": "正则过滤（RE2）。命名捕获组可作为字段使用，如 HTTP/1.1\" (?P<status>\\d{3}) 再以 count_by=status 统计",
+					},
+					"level": map[string]interface{}{
+						"type":        "string",
+						"description": "日志级别，逗号分隔，如 error,fatal。JSON 行取 level/lvl/severity 字段，文本行识别 ERROR/WARN 等大写标记",
+					},
+					"fields": map[string]interface{}{
+						"type":                 "object",
+						"additionalProperties": map[string]interface{}{"type": "string"},
+						"description":          "JSON 行字段过滤（支持 a.b 路径）：\"500\" 相等、\"5*\" 通配、\">=500\" 数值比较、\"!=200\"、\"~正则\"",
+					},
+					"start_time": map[string]interface{}{
+						"type":        "string",
+						"description": "起始时间：\"2006-01-02 15:04:05\"、\"15:04:05\"（今天）、RFC3339 或相对时长如 \"2h\"（2 小时前）",
+					},
+					"end_time": map[string]interface{}{
+						"type":        "string",
+						"description": "结束时间，格式同上",
+					},
+					"count_by": map[string]interface{}{
+						"type":        "string",
+						"description": "按字段分组计数（字段路径、正则命名捕获组，或内置 level / file），返回 groups 而非原始行",
+					},
+					"histogram": map[string]interface{}{
+						"type":        "string",
+						"description": "按时间间隔统计匹配行数，如 1m、5m、1h，返回 histogram 而非原始行",
+					},
+					"lines": map[string]interface{}{
+						"type":        "integer",
+						"description": "返回最近 N 条匹配行（或 count_by 前 N 组），默认 200，上限 2000",
+					},
+				},
+				"required": []string{"source"},
+			}),
+		},
 	}
 }
 
@@ -151,6 +219,14 @@ func (c *Connection) handleToolCallMsg(msg *uap.Message) {
 		result = c.toolListLogSources()
 	case "ReadLog":
 		result = c.toolReadLog(args)
+	case "QueryLog":
+		result = c.toolQueryLog(args)
+	case "ListAlertRules":
+		result = c.toolListAlertRules()
+	case "SetAlertRule":
+		result = c.toolSetAlertRule(payload.Arguments, strings.TrimSpace(payload.AuthenticatedUser))
+	case "DeleteAlertRule":
+		result = c.toolDeleteAlertRule(args, strings.TrimSpace(payload.AuthenticatedUser))
 	default:
 		c.Client.SendTo(msg.From, uap.MsgToolResult, uap.BuildToolError(msg.ID, fmt.Sprintf("unknown tool: %s", payload.ToolName)))
 		return
@@ -204,14 +280,9 @@ func (c *Connection) toolReadLog(args map[string]interface{}) string {
 	}
 
 	// 查找日志源
-	logSource, ok := c.cfg.LogSources[source]
-	if !ok {
-		names := make([]string, 0, len(c.cfg.LogSources))
-		for name := range c.cfg.LogSources {
-			names = append(names, name)
-		}
-		sort.Strings(names)
-		return agentbase.ErrorJSON(fmt.Sprintf("未知日志源 %q，可用源: %s", source, strings.Join(names, ", ")))
+	logSource, err := c.lookupSource(source)
+	if err != nil {
+		return agentbase.ErrorJSON(err.Error())
 	}
 
 	// 获取文件名
@@ -287,6 +358,64 @@ func (c *Connection) toolReadLog(args map[string]interface{}) string {
 	return agentbase.ReadLogFile(filePath, lines, keyword, startTime, endTime)
 }
 
+// toolQueryLog 跨文件检索与聚合
+func (c *Connection) toolQueryLog(args map[string]interface{}) string {
+	source, _ := args["source"].(string)
+	if source == "" {
+		return agentbase.ErrorJSON("缺少 source 参数")
+	}
+	logSource, err := c.lookupSource(source)
+	if err != nil {
+		return agentbase.ErrorJSON(err.Error())
+	}
+	filter, err := newLineFilter(args)
+	if err != nil {
+		return agentbase.ErrorJSON(err.Error())
+	}
+
+	req := queryRequest{
+		dir:      logSource.Path,
+		filter:   filter,
+		limit:    min(max(agentbase.GetOptionalIntParam(args, "lines", 200), 1), 2000),
+		maxFiles: c.cfg.Query.MaxFiles,
+		maxBytes: int64(c.cfg.Query.MaxScanMB) << 20,
+	}
+	req.pattern, _ = args["file"].(string)
+	req.countBy, _ = args["count_by"].(string)
+	if h, _ := args["histogram"].(string); h != "" {
+		if req.histogram, err = time.ParseDuration(h); err != nil || req.histogram <= 0 {
+			return agentbase.ErrorJSON("histogram 间隔无效，示例: 1m、5m、1h")
+		}
+	}
+
+	log.Printf("[QueryLog] source=%s file=%s count_by=%s histogram=%v", source, req.pattern, req.countBy, req.histogram)
+
+	res, err := runQuery(req)
+	if err != nil {
+		return agentbase.ErrorJSON(err.Error())
+	}
+	data, _ := json.Marshal(struct {
+		Success bool   `json:"success"`
+		Source  string `json:"source"`
+		*queryResult
+	}{true, source, res})
+	return string(data)
+}
+
+// lookupSource 按名称查找日志源
+func (c *Connection) lookupSource(source string) (LogSource, error) {
+	logSource, ok := c.cfg.LogSources[source]
+	if !ok {
+		names := make([]string, 0, len(c.cfg.LogSources))
+		for name := range c.cfg.LogSources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return LogSource{}, fmt.Errorf("未知日志源 %s，可用源: %s", source, strings.Join(names, ", "))
+	}
+	return logSource, nil
+}
+
 // findLatestLogFile 在目录中找到最新修改的 .log 文件
 func findLatestLogFile(dir string) (string, error) {
 	entries, err := os.ReadDir(dir)
@@ -24,6 +24,14 @@ func TestNewConnectionIncludesLogQueryTools(t *testing.T) {
 	if !hasTool(conn.Client.Tools, "ReadLog") {
 		t.Fatalf("expected ReadLog to be registered")
 	}
+	if !hasTool(conn.Client.Tools, "QueryLog") {
+		t.Fatalf("expected QueryLog to be registered")
+	}
+	for _, name := range []string{"ListAlertRules", "SetAlertRule", "DeleteAlertRule"} {
+		if !hasTool(conn.Client.Tools, name) {
+			t.Fatalf("expected %s to be registered", name)
+		}
+	}
 }
 
 func hasTool(tools []uap.ToolDef, name string) bool {
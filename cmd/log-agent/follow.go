@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"agentbase"
+	"uap"
+)
+
+// ========================= tail_follow 任务 =========================
+//
+// 通过 task_assign 启动（payload.task_type = "tail_follow"），持续以 task_event 推送新增日志行，
+// 收到 task_stop 或超过 follow.max_sec 后发送 task_complete。文件轮转/截断后自动从新文件开头继续。
+// 未指定 file 时固定跟随启动时目录下最新的 .log 文件，只有该文件被替换或消失时才切换。
+
+// TailFollowPayload tail_follow 任务参数
+type TailFollowPayload struct {
+	TaskType  string            `json:"task_type"` // "tail_follow"
+	Source    string            `json:"source"`
+	File      string            `json:"file,omitempty"` // 文件名，不填则跟随目录下最新的 .log 文件
+	Keyword   string            `json:"keyword,omitempty"`
+	Regex     string            `json:"regex,omitempty"`
+	Level     string            `json:"level,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	FromLines int               `json:"from_lines,omitempty"` // 启动时先推送末尾 N 行（过滤前），默认 0
+	MaxSec    int               `json:"max_sec,omitempty"`    // 最长跟随时间，不超过配置上限
+}
+
+// FollowEvent task_event 中的事件
+type FollowEvent struct {
+	Event string   `json:"event"` // lines | rotated | waiting
+	File  string   `json:"file,omitempty"`
+	Lines []string `json:"lines,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// followSpec 已校验的跟随参数
+type followSpec struct {
+	dir       string
+	file      string
+	filter    *lineFilter
+	fromLines int
+	poll      time.Duration
+	maxLines  int // 单个事件最多行数
+}
+
+const (
+	followReadLimit    = 1 << 20   // 单次轮询最多读取的字节数，剩余部分下次轮询继续
+	followMaxLineBytes = 64 * 1024 // 单行最大长度，超出部分丢弃
+)
+
+// tailFollower 跟随单个日志源
+type tailFollower struct {
+	spec followSpec
+	emit func(FollowEvent)
+
+	path    string
+	f       *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+	waitErr string
+	emitted int
+	known   map[string]bool // 未指定 file 时，上次轮询目录下已存在的 .log 文件
+}
+
+// run 阻塞直到 ctx 结束，返回推送的行数
+func (t *tailFollower) run(ctx context.Context) int {
+	defer t.close()
+	t.poll(true)
+	ticker := time.NewTicker(t.spec.poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return t.emitted
+		case <-ticker.C:
+			t.poll(false)
+		}
+	}
+}
+
+func (t *tailFollower) close() {
+	if t.f != nil {
+		t.f.Close()
+		t.f = nil
+	}
+}
+
+// resolvePath 确定要跟随的文件。未指定 file 时固定跟随已选中的文件，只有它消失后才重新选择
+// 目录下最新的 .log 文件（跳过被改名的原文件）；existed 表示切换到的是上次轮询时已存在的其他文件
+func (t *tailFollower) resolvePath() (path string, existed bool, err error) {
+	if t.spec.file != "" {
+		return filepath.Join(t.spec.dir, t.spec.file), false, nil
+	}
+	prev := t.known
+	files := scanLogFiles(t.spec.dir)
+	t.known = make(map[string]bool, len(files))
+	for p := range files {
+		t.known[p] = true
+	}
+	if t.path != "" && files[t.path] != nil {
+		return t.path, false, nil
+	}
+
+	var latest os.FileInfo
+	for p, info := range files {
+		if t.info != nil && os.SameFile(info, t.info) {
+			continue
+		}
+		if latest == nil || info.ModTime().After(latest.ModTime()) {
+			path, latest = p, info
+		}
+	}
+	if latest == nil {
+		return "", false, fmt.Errorf("目录 %s 下没有 .log 文件", t.spec.dir)
+	}
+	return path, path != t.path && prev[path], nil
+}
+
+// scanLogFiles 目录下的 .log 文件（路径 → 文件信息，读取失败返回空集合）
+func scanLogFiles(dir string) map[string]os.FileInfo {
+	files := make(map[string]os.FileInfo)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return files
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".log") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files[filepath.Join(dir, entry.Name())] = info
+		}
+	}
+	return files
+}
+
+// poll 检查轮转并推送新增行
+func (t *tailFollower) poll(first bool) {
+	path, existed, err := t.resolvePath()
+	if err != nil {
+		t.waiting(err)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.waiting(err)
+		return
+	}
+	t.waitErr = ""
+
+	switch {
+	case t.f == nil || path != t.path || !os.SameFile(info, t.info):
+		// 首次打开或轮转：首次从末尾开始，轮转后的新文件从头读取，
+		// 切换到此前已存在的其他文件时从当前末尾开始，避免重复推送
+		reopened := t.f != nil
+		t.close()
+		f, err := os.Open(path)
+		if err != nil {
+			t.waiting(err)
+			return
+		}
+		t.f, t.path, t.info, t.partial = f, path, info, nil
+		t.offset = 0
+		if first {
+			t.offset = info.Size()
+			t.emitTail(info.Size())
+		} else {
+			if existed {
+				t.offset = info.Size()
+			}
+			if reopened {
+				t.emit(FollowEvent{Event: "rotated", File: filepath.Base(path)})
+			}
+		}
+	case info.Size() < t.offset:
+		// copytruncate 截断
+		t.offset, t.partial = 0, nil
+		t.emit(FollowEvent{Event: "rotated", File: filepath.Base(path)})
+	}
+	t.info = info
+
+	if info.Size() <= t.offset {
+		return
+	}
+	data := make([]byte, min(info.Size()-t.offset, followReadLimit))
+	n, err := t.f.ReadAt(data, t.offset)
+	if err != nil && err != io.EOF {
+		t.waiting(err)
+		return
+	}
+	t.offset += int64(n)
+	data = data[:n]
+
+	// 只推送完整的行，残余部分留到下次
+	nl := bytes.IndexByte(data, '\n')
+	if nl < 0 {
+		t.appendPartial(data)
+		return
+	}
+	t.appendPartial(data[:nl])
+	lines := []string{string(t.partial)}
+	rest := data[nl+1:]
+	if last := bytes.LastIndexByte(rest, '\n'); last >= 0 {
+		lines = append(lines, strings.Split(string(rest[:last]), "\n")...)
+		rest = rest[last+1:]
+	}
+	t.partial = nil
+	t.appendPartial(rest)
+	t.push(lines)
+}
+
+// appendPartial 追加未完成的行，超过 followMaxLineBytes 的部分丢弃
+func (t *tailFollower) appendPartial(b []byte) {
+	if room := followMaxLineBytes - len(t.partial); room > 0 {
+		t.partial = append(t.partial, b[:min(len(b), room)]...)
+	}
+}
+
+// emitTail 启动时推送末尾 fromLines 行（最多回读 1MB）
+func (t *tailFollower) emitTail(size int64) {
+	if t.spec.fromLines <= 0 || size == 0 {
+		return
+	}
+	start := max(size-1<<20, 0)
+	data := make([]byte, size-start)
+	n, err := t.f.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(data[:n]), "\n"), "\n")
+	if start > 0 && len(lines) > 0 {
+		lines = lines[1:] // 首行可能不完整
+	}
+	if len(lines) > t.spec.fromLines {
+		lines = lines[len(lines)-t.spec.fromLines:]
+	}
+	t.push(lines)
+}
+
+// push 过滤后分批推送
+func (t *tailFollower) push(lines []string) {
+	var batch []string
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		if len(line) > followMaxLineBytes {
+			line = line[:followMaxLineBytes]
+		}
+		if _, ok := t.spec.filter.match(line); !ok {
+			continue
+		}
+		batch = append(batch, line)
+		if len(batch) >= t.spec.maxLines {
+			t.emitLines(batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		t.emitLines(batch)
+	}
+}
+
+func (t *tailFollower) emitLines(lines []string) {
+	t.emitted += len(lines)
+	t.emit(FollowEvent{Event: "lines", File: filepath.Base(t.path), Lines: lines})
+}
+
+// waiting 文件暂不可用（轮转间隙等），同一错误只推送一次
+func (t *tailFollower) waiting(err error) {
+	if msg := err.Error(); msg != t.waitErr {
+		t.waitErr = msg
+		t.emit(FollowEvent{Event: "waiting", Error: msg})
+	}
+}
+
+// ========================= 任务管理 =========================
+
+// followTask 运行中的 tail_follow 任务
+type followTask struct {
+	cancel  context.CancelFunc
+	stopped bool // 由 task_stop / shutdown 结束
+}
+
+// followManager 管理 tail_follow 任务
+type followManager struct {
+	mu    sync.Mutex
+	tasks map[string]*followTask
+}
+
+func newFollowManager() *followManager {
+	return &followManager{tasks: make(map[string]*followTask)}
+}
+
+// Count 运行中的任务数
+func (m *followManager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.tasks)
+}
+
+// Stop 停止任务，返回是否存在
+func (m *followManager) Stop(taskID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task, ok := m.tasks[taskID]
+	if ok {
+		task.stopped = true
+		task.cancel()
+	}
+	return ok
+}
+
+// StopAll 停止全部任务
+func (m *followManager) StopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, task := range m.tasks {
+		task.stopped = true
+		task.cancel()
+	}
+}
+
+// handleTaskAssign 处理 task_assign（目前仅支持 tail_follow）
+func (c *Connection) handleTaskAssign(msg *uap.Message) {
+	var assign uap.TaskAssignPayload
+	if err := json.Unmarshal(msg.Payload, &assign); err != nil {
+		log.Printf("[Follow] parse task_assign failed: %v", err)
+		return
+	}
+	reject := func(reason string) {
+		log.Printf("[Follow] reject task=%s: %s", assign.TaskID, reason)
+		c.Client.SendTo(msg.From, uap.MsgTaskRejected, uap.TaskRejectedPayload{TaskID: assign.TaskID, Reason: reason})
+	}
+
+	var payload TailFollowPayload
+	if err := json.Unmarshal(assign.Payload, &payload); err != nil {
+		reject("invalid payload")
+		return
+	}
+	if payload.TaskType != "tail_follow" {
+		reject(fmt.Sprintf("unsupported task_type: %s", payload.TaskType))
+		return
+	}
+	spec, err := c.buildFollowSpec(payload)
+	if err != nil {
+		reject(err.Error())
+		return
+	}
+
+	maxDur := time.Duration(c.cfg.Follow.MaxSec) * time.Second
+	if payload.MaxSec > 0 && time.Duration(payload.MaxSec)*time.Second < maxDur {
+		maxDur = time.Duration(payload.MaxSec) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), maxDur)
+	task := &followTask{cancel: cancel}
+
+	c.follows.mu.Lock()
+	if _, exists := c.follows.tasks[assign.TaskID]; exists {
+		c.follows.mu.Unlock()
+		cancel()
+		reject("task already running")
+		return
+	}
+	if len(c.follows.tasks) >= c.cfg.Follow.MaxTasks {
+		c.follows.mu.Unlock()
+		cancel()
+		reject(fmt.Sprintf("too many tail_follow tasks (max %d)", c.cfg.Follow.MaxTasks))
+		return
+	}
+	c.follows.tasks[assign.TaskID] = task
+	c.follows.mu.Unlock()
+
+	c.Client.SendTo(msg.From, uap.MsgTaskAccepted, uap.TaskAcceptedPayload{TaskID: assign.TaskID})
+	log.Printf("[Follow] task=%s source=%s file=%s max=%v", assign.TaskID, payload.Source, payload.File, maxDur)
+
+	go func() {
+		follower := &tailFollower{spec: spec, emit: func(ev FollowEvent) {
+			c.Client.SendTo(msg.From, uap.MsgTaskEvent, uap.TaskEventPayload{
+				TaskID: assign.TaskID,
+				Event:  agentbase.MustMarshalJSON(ev),
+			})
+		}}
+		emitted := follower.run(ctx)
+		cancel()
+
+		c.follows.mu.Lock()
+		delete(c.follows.tasks, assign.TaskID)
+		stopped := task.stopped
+		c.follows.mu.Unlock()
+
+		status := "success"
+		if stopped {
+			status = "cancelled"
+		}
+		result, _ := json.Marshal(map[string]any{"lines_emitted": emitted})
+		c.Client.SendTo(msg.From, uap.MsgTaskComplete, uap.TaskCompletePayload{
+			TaskID: assign.TaskID,
+			Status: status,
+			Result: string(result),
+		})
+		log.Printf("[Follow] task=%s finished status=%s lines=%d", assign.TaskID, status, emitted)
+	}()
+}
+
+// handleTaskStop 处理 task_stop
+func (c *Connection) handleTaskStop(msg *uap.Message) {
+	var payload uap.TaskStopPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return
+	}
+	if !c.follows.Stop(payload.TaskID) {
+		log.Printf("[Follow] task_stop for unknown task=%s", payload.TaskID)
+	}
+}
+
+// buildFollowSpec 校验源与过滤参数
+func (c *Connection) buildFollowSpec(p TailFollowPayload) (followSpec, error) {
+	src, err := c.lookupSource(p.Source)
+	if err != nil {
+		return followSpec{}, err
+	}
+	if !validFollowFile(p.File) {
+		return followSpec{}, fmt.Errorf("file 只能是日志源目录下的文件名")
+	}
+	filter, err := newFollowFilter(p.Keyword, p.Regex, p.Level, p.Fields)
+	if err != nil {
+		return followSpec{}, err
+	}
+
+	return followSpec{
+		dir:       src.Path,
+		file:      p.File,
+		filter:    filter,
+		fromLines: min(p.FromLines, 2000),
+		poll:      time.Duration(c.cfg.Follow.PollMs) * time.Millisecond,
+		maxLines:  500,
+	}, nil
+}
+
+// validFollowFile 跟随文件只能是日志源目录下的文件名（空表示最新 .log 文件）
+func validFollowFile(file string) bool {
+	return !strings.ContainsAny(file, `/\`) && file != "." && file != ".."
+}
+
+// newFollowFilter 由结构化参数构造行过滤器（tail_follow 与告警规则共用）
+func newFollowFilter(keyword, regex, level string, fields map[string]string) (*lineFilter, error) {
+	args := map[string]interface{}{"keyword": keyword, "regex": regex, "level": level}
+	if len(fields) > 0 {
+		m := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			m[k] = v
+		}
+		args["fields"] = m
+	}
+	return newLineFilter(args)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []FollowEvent
+}
+
+func (r *eventRecorder) emit(ev FollowEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func (r *eventRecorder) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lines []string
+	for _, ev := range r.events {
+		lines = append(lines, ev.Lines...)
+	}
+	return lines
+}
+
+func (r *eventRecorder) waitLines(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if lines := r.lines(); len(lines) >= n {
+			return lines
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d lines, got %v", n, r.lines())
+	return nil
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(data)
+	f.Close()
+}
+
+func TestTailFollowerFiltersAndHandlesRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "old INFO a\nold ERROR b\n")
+
+	filter, err := newLineFilter(map[string]interface{}{"level": "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &eventRecorder{}
+	follower := &tailFollower{
+		spec: followSpec{dir: dir, file: "app.log", filter: filter, fromLines: 10, poll: 20 * time.Millisecond, maxLines: 500},
+		emit: rec.emit,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- follower.run(ctx) }()
+
+	// 启动时回放末尾行（过滤后）
+	rec.waitLines(t, 1)
+
+	// 半行不推送，补全后推送
+	appendFile(t, path, "new ERROR c")
+	time.Sleep(100 * time.Millisecond)
+	if n := len(rec.lines()); n != 1 {
+		t.Fatalf("partial line emitted: %v", rec.lines())
+	}
+	appendFile(t, path, " done\nnew INFO d\n")
+	rec.waitLines(t, 2)
+
+	// 轮转：旧文件改名，新文件从头读取
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "rotated ERROR e\n")
+	lines := rec.waitLines(t, 3)
+
+	cancel()
+	if emitted := <-done; emitted != 3 {
+		t.Fatalf("emitted = %d, want 3", emitted)
+	}
+	want := []string{"old ERROR b", "new ERROR c done", "rotated ERROR e"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("lines = %v, want %v", lines, want)
+	}
+	rotated := false
+	for _, ev := range rec.events {
+		rotated = rotated || ev.Event == "rotated"
+	}
+	if !rotated {
+		t.Fatalf("expected rotated event: %+v", rec.events)
+	}
+}
+
+func TestBuildFollowSpecValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LogSources = map[string]LogSource{"svc": {Path: t.TempDir()}}
+	c := NewConnection(cfg, "log-agent-test")
+
+	if _, err := c.buildFollowSpec(TailFollowPayload{Source: "svc", File: "../passwd"}); err == nil {
+		t.Fatal("path traversal should be rejected")
+	}
+	if _, err := c.buildFollowSpec(TailFollowPayload{Source: "missing"}); err == nil {
+		t.Fatal("unknown source should be rejected")
+	}
+	if _, err := c.buildFollowSpec(TailFollowPayload{Source: "svc", Regex: "("}); err == nil {
+		t.Fatal("bad regex should be rejected")
+	}
+	spec, err := c.buildFollowSpec(TailFollowPayload{Source: "svc", FromLines: 99999})
+	if err != nil || spec.fromLines != 2000 || spec.poll != 500*time.Millisecond {
+		t.Fatalf("unexpected spec: %+v %v", spec, err)
+	}
+}
+
+func TestTailFollowerBoundsReadsAndLineLength(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "")
+
+	filter, err := newLineFilter(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &eventRecorder{}
+	follower := &tailFollower{
+		spec: followSpec{dir: dir, file: "app.log", filter: filter, maxLines: 500},
+		emit: rec.emit,
+	}
+	defer follower.close()
+	follower.poll(true)
+
+	// 超长的未完成行只保留前 followMaxLineBytes 字节
+	appendFile(t, path, strings.Repeat("a", 3*followReadLimit))
+	follower.poll(false)
+	if follower.offset != followReadLimit {
+		t.Fatalf("offset = %d, want one read of %d bytes", follower.offset, followReadLimit)
+	}
+	follower.poll(false)
+	follower.poll(false)
+	if len(follower.partial) != followMaxLineBytes {
+		t.Fatalf("partial = %d bytes, want capped at %d", len(follower.partial), followMaxLineBytes)
+	}
+
+	appendFile(t, path, "tail\nnext\n")
+	follower.poll(false)
+	lines := rec.lines()
+	if len(lines) != 2 || len(lines[0]) != followMaxLineBytes || lines[1] != "next" {
+		t.Fatalf("unexpected lines: %d %v", len(lines), len(lines) > 1 && lines[1] == "next")
+	}
+}
+
+func TestTailFollowerPinsLatestFile(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	appendFile(t, b, "b ERROR old\n")
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(b, old, old)
+	appendFile(t, a, "a ERROR 1\n")
+
+	filter, _ := newLineFilter(map[string]interface{}{})
+	rec := &eventRecorder{}
+	follower := &tailFollower{
+		spec: followSpec{dir: dir, filter: filter, poll: time.Hour, maxLines: 500},
+		emit: rec.emit,
+	}
+	defer follower.close()
+	follower.poll(true)
+
+	// 两个文件交替写入：始终跟随启动时选中的 a.log，不触发轮转、不重读
+	appendFile(t, b, "b ERROR 2\n")
+	follower.poll(false)
+	appendFile(t, a, "a ERROR 3\n")
+	follower.poll(false)
+	appendFile(t, b, "b ERROR 4\n")
+	follower.poll(false)
+	if got := strings.Join(rec.lines(), "|"); got != "a ERROR 3" {
+		t.Fatalf("lines = %q, want only new lines of a.log", got)
+	}
+
+	// a.log 消失后切换到已存在的 b.log，从其当前末尾开始
+	if err := os.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	follower.poll(false)
+	appendFile(t, b, "b ERROR 5\n")
+	follower.poll(false)
+	if got := strings.Join(rec.lines(), "|"); got != "a ERROR 3|b ERROR 5" {
+		t.Fatalf("lines = %q, want switch to b.log at its end", got)
+	}
+	rotated := 0
+	for _, ev := range rec.events {
+		if ev.Event == "rotated" {
+			rotated++
+		}
+	}
+	if rotated != 1 {
+		t.Fatalf("rotated events = %d, want 1: %+v", rotated, rec.events)
+	}
+}
+
+func TestTailFollowerLatestFileRenameRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "ERROR 1\n")
+
+	filter, _ := newLineFilter(map[string]interface{}{})
+	rec := &eventRecorder{}
+	follower := &tailFollower{
+		spec: followSpec{dir: dir, filter: filter, poll: time.Hour, maxLines: 500},
+		emit: rec.emit,
+	}
+	defer follower.close()
+	follower.poll(true)
+
+	// 改名为另一个 .log 文件：不重读旧文件，等待新文件出现后从头读取
+	if err := os.Rename(path, filepath.Join(dir, "app-1.log")); err != nil {
+		t.Fatal(err)
+	}
+	follower.poll(false)
+	appendFile(t, path, "ERROR 2\n")
+	follower.poll(false)
+	if got := strings.Join(rec.lines(), "|"); got != "ERROR 2" {
+		t.Fatalf("lines = %q, want only the new file", got)
+	}
+}
@@ -50,7 +50,8 @@ func main() {
 		agentID, cfg.ServerURL, len(cfg.LogSources))
 
 	conn := NewConnection(cfg, agentID)
-	conn.ActiveTaskCounter = func() int { return int(atomic.LoadInt32(&conn.activeCount)) }
+	conn.ActiveTaskCounter = func() int { return int(atomic.LoadInt32(&conn.activeCount)) + conn.follows.Count() }
+	conn.alerts.Start()
 
 	// 信号处理
 	go func() {
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ========================= 行过滤 =========================
+
+// lineFilter 日志行过滤条件（QueryLog 与 tail_follow 共用）
+type lineFilter struct {
+	keyword   string
+	regex     *regexp.Regexp // 命名捕获组作为字段参与 fields 过滤与 count_by
+	levels    map[string]bool
+	fields    []fieldCond
+	startTime time.Time
+	endTime   time.Time
+}
+
+// fieldCond 字段条件："500"（相等，支持 * 通配）、">=500"、"!=200"、"~^5\d\d$"（正则）
+type fieldCond struct {
+	path  string
+	op    string
+	value string
+	num   float64
+	isNum bool
+	re    *regexp.Regexp
+}
+
+// logEntry 解析后的日志行
+type logEntry struct {
+	line   string
+	fields map[string]any // JSON 字段 + 正则命名捕获
+	level  string
+	time   time.Time
+	hasTS  bool
+}
+
+var textLevelRe = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|PANIC|CRITICAL)\b`)
+
+// newLineFilter 从工具参数构建过滤条件
+func newLineFilter(args map[string]interface{}) (*lineFilter, error) {
+	f := &lineFilter{}
+	f.keyword, _ = args["keyword"].(string)
+
+	if pattern, _ := args["regex"].(string); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("regex 无效: %v", err)
+		}
+		f.regex = re
+	}
+
+	if level, _ := args["level"].(string); level != "" {
+		f.levels = make(map[string]bool)
+		for _, l := range strings.Split(level, ",") {
+			if l = normalizeLevel(l); l != "" {
+				f.levels[l] = true
+			}
+		}
+	}
+
+	if raw, ok := args["fields"].(map[string]interface{}); ok {
+		paths := make([]string, 0, len(raw))
+		for p := range raw {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			cond, err := parseFieldCond(p, fmt.Sprint(raw[p]))
+			if err != nil {
+				return nil, err
+			}
+			f.fields = append(f.fields, cond)
+		}
+	}
+
+	var err error
+	if s, _ := args["start_time"].(string); s != "" {
+		if f.startTime, err = parseQueryTime(s); err != nil {
+			return nil, fmt.Errorf("start_time 格式错误: %v", err)
+		}
+	}
+	if s, _ := args["end_time"].(string); s != "" {
+		if f.endTime, err = parseQueryTime(s); err != nil {
+			return nil, fmt.Errorf("end_time 格式错误: %v", err)
+		}
+	}
+	return f, nil
+}
+
+func parseFieldCond(path, expr string) (fieldCond, error) {
+	cond := fieldCond{path: path, op: "="}
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "~"} {
+		if strings.HasPrefix(expr, op) {
+			cond.op = op
+			expr = strings.TrimPrefix(expr, op)
+			break
+		}
+	}
+	cond.value = strings.TrimSpace(expr)
+	switch cond.op {
+	case "~":
+		re, err := regexp.Compile(cond.value)
+		if err != nil {
+			return cond, fmt.Errorf("fields.%s 正则无效: %v", path, err)
+		}
+		cond.re = re
+	case ">", ">=", "<", "<=":
+		n, err := strconv.ParseFloat(cond.value, 64)
+		if err != nil {
+			return cond, fmt.Errorf("fields.%s 比较值必须为数字", path)
+		}
+		cond.num, cond.isNum = n, true
+	}
+	return cond, nil
+}
+
+// match 判断单行是否满足条件，满足时返回解析结果
+func (f *lineFilter) match(line string) (*logEntry, bool) {
+	if f.keyword != "" && !strings.Contains(line, f.keyword) {
+		return nil, false
+	}
+
+	entry := &logEntry{line: line}
+	if f.regex != nil {
+		m := f.regex.FindStringSubmatch(line)
+		if m == nil {
+			return nil, false
+		}
+		for i, name := range f.regex.SubexpNames() {
+			if name != "" && i < len(m) {
+				if entry.fields == nil {
+					entry.fields = make(map[string]any)
+				}
+				entry.fields[name] = m[i]
+			}
+		}
+	}
+	entry.parse()
+
+	if f.levels != nil && !f.levels[entry.level] {
+		return nil, false
+	}
+	for _, cond := range f.fields {
+		v, ok := lookupField(entry.fields, cond.path)
+		if !ok || !cond.matches(v) {
+			return nil, false
+		}
+	}
+	if !f.startTime.IsZero() || !f.endTime.IsZero() {
+		// 设置了时间范围时跳过无法解析时间的行
+		if !entry.hasTS {
+			return nil, false
+		}
+		if !f.startTime.IsZero() && entry.time.Before(f.startTime) {
+			return nil, false
+		}
+		if !f.endTime.IsZero() && entry.time.After(f.endTime) {
+			return nil, false
+		}
+	}
+	return entry, true
+}
+
+func (c fieldCond) matches(v string) bool {
+	switch c.op {
+	case "~":
+		return c.re.MatchString(v)
+	case "!=":
+		return v != c.value
+	case ">", ">=", "<", "<=":
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return false
+		}
+		switch c.op {
+		case ">":
+			return n > c.num
+		case ">=":
+			return n >= c.num
+		case "<":
+			return n < c.num
+		default:
+			return n <= c.num
+		}
+	}
+	if strings.Contains(c.value, "*") {
+		ok, _ := filepath.Match(c.value, v)
+		return ok
+	}
+	return v == c.value
+}
+
+// parse 解析 JSON 行字段、级别与时间
+func (e *logEntry) parse() {
+	trimmed := strings.TrimSpace(e.line)
+	if strings.HasPrefix(trimmed, "{") {
+		var obj map[string]any
+		if json.Unmarshal([]byte(trimmed), &obj) == nil {
+			if e.fields == nil {
+				e.fields = obj
+			} else {
+				// 正则捕获优先
+				for k, v := range obj {
+					if _, exists := e.fields[k]; !exists {
+						e.fields[k] = v
+					}
+				}
+			}
+		}
+	}
+
+	for _, key := range []string{"level", "lvl", "severity", "log.level"} {
+		if v, ok := lookupField(e.fields, key); ok {
+			e.level = normalizeLevel(v)
+			break
+		}
+	}
+	if e.level == "" {
+		if m := textLevelRe.FindString(e.line); m != "" {
+			e.level = normalizeLevel(m)
+		}
+	}
+
+	for _, key := range []string{"time", "ts", "timestamp", "@timestamp"} {
+		if v, ok := e.fields[key]; ok {
+			if t, ok := parseFieldTime(v); ok {
+				e.time, e.hasTS = t, true
+				return
+			}
+		}
+	}
+	e.time, e.hasTS = extractTextTime(e.line)
+}
+
+func normalizeLevel(l string) string {
+	switch l = strings.ToLower(strings.TrimSpace(l)); l {
+	case "warning":
+		return "warn"
+	case "err":
+		return "error"
+	case "critical", "crit":
+		return "fatal"
+	}
+	return l
+}
+
+// lookupField 按点分路径查找字段并转为字符串；顶层存在带点的键时优先
+func lookupField(fields map[string]any, path string) (string, bool) {
+	if fields == nil {
+		return "", false
+	}
+	if v, ok := fields[path]; ok {
+		return fieldString(v), true
+	}
+	var cur any = fields
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if cur, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+	return fieldString(cur), true
+}
+
+func fieldString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(x)
+		return string(data)
+	}
+}
+
+// parseFieldTime 解析 JSON 时间字段：RFC3339 / 常见文本格式 / unix 秒或毫秒
+func parseFieldTime(v any) (time.Time, bool) {
+	switch x := v.(type) {
+	case float64:
+		if x > 1e12 {
+			return time.UnixMilli(int64(x)), true
+		}
+		if x > 0 {
+			return time.Unix(int64(x), int64((x-float64(int64(x)))*1e9)), true
+		}
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006/01/02 15:04:05", "2006-01-02T15:04:05"} {
+			if t, err := time.ParseInLocation(layout, x, time.Local); err == nil {
+				return t, true
+			}
+		}
+		if n, err := strconv.ParseFloat(x, 64); err == nil {
+			return parseFieldTime(n)
+		}
+	}
+	return time.Time{}, false
+}
+
+// extractTextTime 从文本行解析时间：Go log 前缀、ISO 日期前缀、nginx [02/Jan/2006:15:04:05 -0700]
+func extractTextTime(line string) (time.Time, bool) {
+	if len(line) >= 19 {
+		for _, layout := range []string{"2006/01/02 15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+			if t, err := time.ParseInLocation(layout, line[:19], time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	if i := strings.IndexByte(line, '['); i >= 0 && len(line) >= i+28 {
+		if t, err := time.Parse("02/Jan/2006:15:04:05 -0700", line[i+1:i+27]); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseQueryTime 解析查询时间："2006-01-02 15:04:05"、"15:04:05"（今天）、RFC3339、相对时长 "30m"/"-2h"（之前）
+func parseQueryTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(strings.TrimPrefix(s, "-")); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("15:04:05", s, time.Local); err == nil {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+	}
+	return time.Time{}, fmt.Errorf("不支持的时间格式 %s", s)
+}
+
+// ========================= 多文件查询 =========================
+
+// logFile 参与查询的日志文件
+type logFile struct {
+	name    string
+	path    string
+	modTime time.Time
+}
+
+// listLogFiles 列出源目录下匹配 pattern 的文件（含 .gz 轮转文件），按修改时间从旧到新
+func listLogFiles(dir, pattern string) ([]logFile, error) {
+	if pattern == "" {
+		pattern = "*.log*"
+	}
+	if strings.ContainsAny(pattern, `/\`) {
+		return nil, fmt.Errorf("file 只能是文件名通配，禁止包含路径分隔符")
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("file 通配无效: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	var files []logFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, entry.Name()); !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{name: entry.Name(), path: filepath.Join(dir, entry.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	return files, nil
+}
+
+// scanLines 逐行读取文件（.gz 自动解压），超长行截断；fn 收到行内容与该行解压后的字节数（含换行），返回 false 时停止
+func scanLines(path string, fn func(line string, n int64) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("gzip: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	const maxLineBytes = 64 * 1024
+	br := bufio.NewReaderSize(r, 64*1024)
+	var buf []byte
+	var n int64
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		n += int64(len(chunk))
+		if len(buf) < maxLineBytes {
+			buf = append(buf, chunk...)
+		}
+		if err != nil {
+			if err == io.EOF {
+				if len(buf) > 0 {
+					fn(string(buf), n)
+				}
+				return nil
+			}
+			return err
+		}
+		if isPrefix {
+			continue
+		}
+		if len(buf) > maxLineBytes {
+			buf = buf[:maxLineBytes]
+		}
+		if !fn(string(buf), n+1) {
+			return nil
+		}
+		buf, n = buf[:0], 0
+	}
+}
+
+// queryRequest QueryLog 参数
+type queryRequest struct {
+	dir       string
+	pattern   string
+	filter    *lineFilter
+	limit     int
+	countBy   string
+	histogram time.Duration
+	maxFiles  int
+	maxBytes  int64
+}
+
+type matchedLine struct {
+	File string `json:"file"`
+	Line string `json:"line"`
+}
+
+type countGroup struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type histogramBucket struct {
+	Start string `json:"start"`
+	Count int    `json:"count"`
+}
+
+// queryResult QueryLog 返回
+type queryResult struct {
+	Files        []string          `json:"files"`
+	ScannedLines int               `json:"scanned_lines"`
+	Matched      int               `json:"matched"`
+	Lines        []matchedLine     `json:"lines,omitempty"`
+	Groups       []countGroup      `json:"groups,omitempty"`
+	Missing      int               `json:"missing,omitempty"` // count_by 字段缺失的匹配行数
+	Histogram    []histogramBucket `json:"histogram,omitempty"`
+	Untimed      int               `json:"untimed,omitempty"` // histogram 无法解析时间的匹配行数
+	Truncated    bool              `json:"truncated"`         // 因文件数或扫描量上限未扫描完
+	SkippedFiles []string          `json:"skipped_files,omitempty"`
+}
+
+// runQuery 执行多文件查询
+func runQuery(req queryRequest) (*queryResult, error) {
+	files, err := listLogFiles(req.dir, req.pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &queryResult{Files: []string{}}
+	// 早于起始时间修改的文件不可能包含范围内的行
+	if !req.filter.startTime.IsZero() {
+		kept := files[:0]
+		for _, f := range files {
+			if !f.modTime.Before(req.filter.startTime) {
+				kept = append(kept, f)
+			}
+		}
+		files = kept
+	}
+	if req.maxFiles > 0 && len(files) > req.maxFiles {
+		files = files[len(files)-req.maxFiles:]
+		res.Truncated = true
+	}
+
+	aggregate := req.countBy != "" || req.histogram > 0
+	groups := make(map[string]int)
+	buckets := make(map[int64]int)
+	var ring []matchedLine
+
+	// 扫描量按解压后的行字节数累计，逐行检查，.gz 不会因压缩比绕过上限
+	var scanned int64
+	for _, f := range files {
+		if req.maxBytes > 0 && scanned >= req.maxBytes {
+			res.Truncated = true
+			break
+		}
+		err := scanLines(f.path, func(line string, n int64) bool {
+			if req.maxBytes > 0 && scanned >= req.maxBytes {
+				res.Truncated = true
+				return false
+			}
+			scanned += n
+			res.ScannedLines++
+			entry, ok := req.filter.match(line)
+			if !ok {
+				return true
+			}
+			res.Matched++
+			if req.countBy != "" {
+				if v, ok := groupValue(entry, f.name, req.countBy); ok {
+					groups[v]++
+				} else {
+					res.Missing++
+				}
+			}
+			if req.histogram > 0 {
+				if entry.hasTS {
+					buckets[entry.time.Truncate(req.histogram).Unix()]++
+				} else {
+					res.Untimed++
+				}
+			}
+			if !aggregate {
+				ring = append(ring, matchedLine{File: f.name, Line: line})
+				if len(ring) > req.limit {
+					ring = ring[1:]
+				}
+			}
+			return true
+		})
+		if err != nil {
+			res.SkippedFiles = append(res.SkippedFiles, fmt.Sprintf("%s: %v", f.name, err))
+			continue
+		}
+		res.Files = append(res.Files, f.name)
+	}
+
+	if !aggregate {
+		res.Lines = ring
+		return res, nil
+	}
+
+	if req.countBy != "" {
+		for v, n := range groups {
+			res.Groups = append(res.Groups, countGroup{Value: v, Count: n})
+		}
+		sort.Slice(res.Groups, func(i, j int) bool {
+			if res.Groups[i].Count != res.Groups[j].Count {
+				return res.Groups[i].Count > res.Groups[j].Count
+			}
+			return res.Groups[i].Value < res.Groups[j].Value
+		})
+		if len(res.Groups) > req.limit {
+			res.Groups = res.Groups[:req.limit]
+		}
+	}
+	if req.histogram > 0 {
+		keys := make([]int64, 0, len(buckets))
+		for k := range buckets {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		for _, k := range keys {
+			res.Histogram = append(res.Histogram, histogramBucket{
+				Start: time.Unix(k, 0).In(time.Local).Format("2006-01-02 15:04:05"),
+				Count: buckets[k],
+			})
+		}
+	}
+	return res, nil
+}
+
+// groupValue count_by 取值：level / file 为内置维度，其余按字段路径
+func groupValue(entry *logEntry, file, key string) (string, bool) {
+	switch key {
+	case "level":
+		return entry.level, entry.level != ""
+	case "file":
+		return file, true
+	}
+	return lookupField(entry.fields, key)
+}
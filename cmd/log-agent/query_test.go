@@ -0,0 +1,175 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeLogFile(t *testing.T, path string, lines []string, modTime time.Time) {
+	t.Helper()
+	content := strings.Join(lines, "\n") + "\n"
+	if strings.HasSuffix(path, ".gz") {
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz := gzip.NewWriter(f)
+		gz.Write([]byte(content))
+		gz.Close()
+		f.Close()
+	} else if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newQueryTestConn 构造带 JSON 与 nginx 日志的日志源，轮转文件为 gzip
+func newQueryTestConn(t *testing.T) *Connection {
+	t.Helper()
+	dir := t.TempDir()
+	now := time.Now()
+	ts := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+
+	writeLogFile(t, filepath.Join(dir, "app.log.1.gz"), []string{
+		`{"time":"` + ts(3*time.Hour) + `","level":"error","msg":"old failure","http":{"status":500}}`,
+		`{"time":"` + ts(3*time.Hour) + `","level":"info","msg":"old ok","http":{"status":200}}`,
+	}, now.Add(-3*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "app.log"), []string{
+		`{"time":"` + ts(50*time.Minute) + `","level":"ERROR","msg":"db timeout","http":{"status":503}}`,
+		`{"time":"` + ts(40*time.Minute) + `","level":"warn","msg":"slow","http":{"status":200}}`,
+		`{"time":"` + ts(10*time.Minute) + `","level":"error","msg":"upstream","http":{"status":502}}`,
+		`plain text line without json`,
+	}, now)
+	nginxTime := now.Add(-5 * time.Minute).Format("02/Jan/2006:15:04:05 -0700")
+	writeLogFile(t, filepath.Join(dir, "access.log"), []string{
+		`1.1.1.1 - - [` + nginxTime + `] "GET / HTTP/1.1" 200 12`,
+		`1.1.1.1 - - [` + nginxTime + `] "GET /a HTTP/1.1" 502 12`,
+		`1.1.1.1 - - [` + nginxTime + `] "GET /b HTTP/1.1" 502 12`,
+		`1.1.1.1 - - [` + nginxTime + `] "GET /c HTTP/1.1" 504 12`,
+	}, now)
+
+	cfg := DefaultConfig()
+	cfg.LogSources = map[string]LogSource{"svc": {Path: dir}}
+	return NewConnection(cfg, "log-agent-test")
+}
+
+func queryLog(t *testing.T, c *Connection, args map[string]interface{}) map[string]any {
+	t.Helper()
+	args["source"] = "svc"
+	out := c.toolQueryLog(args)
+	var res map[string]any
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("decode %s: %v", out, err)
+	}
+	if res["success"] != true {
+		t.Fatalf("query failed: %s", out)
+	}
+	return res
+}
+
+func TestQueryLogSpansRotatedFiles(t *testing.T) {
+	c := newQueryTestConn(t)
+
+	res := queryLog(t, c, map[string]interface{}{"file": "app.log*", "level": "error"})
+	if res["matched"] != float64(3) || len(res["files"].([]any)) != 2 {
+		t.Fatalf("unexpected result: %v", res)
+	}
+	lines := res["lines"].([]any)
+	if first := lines[0].(map[string]any); first["file"] != "app.log.1.gz" || !strings.Contains(first["line"].(string), "old failure") {
+		t.Fatalf("rotated gzip file should come first: %v", first)
+	}
+
+	// 起始时间早于轮转文件修改时间时跳过该文件
+	res = queryLog(t, c, map[string]interface{}{"file": "app.log*", "start_time": "1h", "fields": map[string]interface{}{"http.status": ">=500"}})
+	if res["matched"] != float64(2) || len(res["files"].([]any)) != 1 {
+		t.Fatalf("unexpected time/field filter result: %v", res)
+	}
+}
+
+func TestQueryLogAggregations(t *testing.T) {
+	c := newQueryTestConn(t)
+
+	res := queryLog(t, c, map[string]interface{}{"file": "app.log*", "count_by": "level"})
+	groups := res["groups"].([]any)
+	if first := groups[0].(map[string]any); first["value"] != "error" || first["count"] != float64(3) {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+	if res["missing"] != float64(1) || res["lines"] != nil {
+		t.Fatalf("unexpected missing/lines: %v", res)
+	}
+
+	// 文本日志：正则命名捕获作为字段
+	res = queryLog(t, c, map[string]interface{}{
+		"file":     "access.log",
+		"regex":    `HTTP/1.1" (?P<status>\d{3}) `,
+		"fields":   map[string]interface{}{"status": "5*"},
+		"count_by": "status",
+	})
+	groups = res["groups"].([]any)
+	if res["matched"] != float64(3) || len(groups) != 2 || groups[0].(map[string]any)["value"] != "502" {
+		t.Fatalf("unexpected 5xx groups: %v", res)
+	}
+
+	res = queryLog(t, c, map[string]interface{}{"file": "access.log", "start_time": "1h", "histogram": "1h"})
+	hist := res["histogram"].([]any)
+	if len(hist) == 0 || res["untimed"] != nil {
+		t.Fatalf("unexpected histogram: %v", res)
+	}
+	total := 0.0
+	for _, b := range hist {
+		total += b.(map[string]any)["count"].(float64)
+	}
+	if total != 4 {
+		t.Fatalf("histogram total = %v, want 4", total)
+	}
+}
+
+func TestQueryLogRejectsBadInput(t *testing.T) {
+	c := newQueryTestConn(t)
+	for _, args := range []map[string]interface{}{
+		{"source": "svc", "file": "../etc/*"},
+		{"source": "svc", "regex": "("},
+		{"source": "svc", "fields": map[string]interface{}{"status": ">abc"}},
+		{"source": "svc", "histogram": "soon"},
+		{"source": "nope"},
+	} {
+		if out := c.toolQueryLog(args); !strings.Contains(out, `"error"`) {
+			t.Fatalf("expected error for %v, got %s", args, out)
+		}
+	}
+}
+
+func TestRunQueryLimitsDecompressedBytes(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "big.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	line := strings.Repeat("x", 1023) + "\n"
+	for i := 0; i < 1000; i++ {
+		gz.Write([]byte(line))
+	}
+	gz.Close()
+	f.Close()
+
+	filter, err := newLineFilter(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 压缩后只有几 KB，扫描量须按解压后的 1MB 计算
+	res, err := runQuery(queryRequest{dir: dir, pattern: "*.gz", filter: filter, limit: 10, maxBytes: 100 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || res.ScannedLines != 100 {
+		t.Fatalf("expected scan to stop after 100KB of decompressed lines: truncated=%v scanned=%d", res.Truncated, res.ScannedLines)
+	}
+}
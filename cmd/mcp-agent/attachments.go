@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"obsstore"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ========================= 结构化附件 =========================
+//
+// MCP 工具结果、资源、提示词中的图片 / 音频 / 二进制资源不再压平成文本：
+// 配置了 OBS 时上传并返回签名下载地址，否则在 max_inline_kb 以内内联 base64，超出只保留元信息。
+
+// 附件类型
+const (
+	AttachmentImage        = "image"
+	AttachmentAudio        = "audio"
+	AttachmentResource     = "resource"      // 嵌入资源（文本或二进制）
+	AttachmentResourceLink = "resource_link" // 仅引用，可再用 read_resource 读取
+)
+
+// MCPAttachment 结构化附件
+type MCPAttachment struct {
+	Type      string `json:"type"`
+	MIMEType  string `json:"mime_type,omitempty"`
+	URI       string `json:"uri,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Text      string `json:"text,omitempty"` // 文本类嵌入资源
+	Size      int    `json:"size,omitempty"` // 二进制内容解码后字节数
+	ObjectKey string `json:"object_key,omitempty"`
+	URL       string `json:"url,omitempty"` // OBS 签名下载地址
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	Data      string `json:"data,omitempty"`    // 未配置 OBS 时内联的 base64
+	Omitted   bool   `json:"omitted,omitempty"` // 未配置 OBS 且超过 max_inline_kb，内容未返回
+}
+
+// attachmentStore 附件上传所需的 OBS 能力（*obsstore.Store 实现）
+type attachmentStore interface {
+	Enabled() bool
+	PutObject(ctx context.Context, req obsstore.PutObjectRequest) error
+	CreateSignedGetURL(ctx context.Context, key string, ttl time.Duration) (*obsstore.SignedURL, error)
+}
+
+// newAttachmentStore 按配置创建 OBS 存储，未配置或初始化失败返回 nil（附件改为内联）
+func newAttachmentStore(cfg *Config) attachmentStore {
+	store, err := obsstore.New(cfg.OBS)
+	if err != nil {
+		log.Printf("[MCPAgent] OBS disabled: %v", err)
+		return nil
+	}
+	if !store.Enabled() {
+		return nil
+	}
+	return store
+}
+
+// SetAttachmentStore 设置附件存储（nil 表示不上传）
+func (m *MCPManager) SetAttachmentStore(store attachmentStore, cfg AttachmentConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	m.attachmentCfg = cfg.withDefaults()
+}
+
+// contentResult 文本与附件分开收集
+type contentResult struct {
+	Text        []string
+	Attachments []MCPAttachment
+}
+
+// render 无附件时返回纯文本（与旧行为一致），否则返回 {"text":..., "attachments":[...]}
+func (r *contentResult) render() (string, error) {
+	text := strings.Join(r.Text, "\n")
+	if len(r.Attachments) == 0 {
+		return text, nil
+	}
+	return marshalResult(struct {
+		Text        string          `json:"text,omitempty"`
+		Attachments []MCPAttachment `json:"attachments"`
+	}{text, r.Attachments}, nil)
+}
+
+// appendContent 转换单个 MCP Content
+func (m *MCPManager) appendContent(ctx context.Context, server string, out *contentResult, content mcp.Content) {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		out.Text = append(out.Text, c.Text)
+	case *mcp.TextContent:
+		out.Text = append(out.Text, c.Text)
+	case mcp.ImageContent:
+		out.Attachments = append(out.Attachments, m.binaryAttachment(ctx, server, AttachmentImage, c.MIMEType, "", c.Data))
+	case mcp.AudioContent:
+		out.Attachments = append(out.Attachments, m.binaryAttachment(ctx, server, AttachmentAudio, c.MIMEType, "", c.Data))
+	case mcp.ResourceLink:
+		out.Attachments = append(out.Attachments, MCPAttachment{
+			Type:     AttachmentResourceLink,
+			MIMEType: c.MIMEType,
+			URI:      c.URI,
+			Name:     c.Name,
+		})
+	case mcp.EmbeddedResource:
+		m.appendResourceContents(ctx, server, out, c.Resource, true)
+	default:
+		// 未知内容类型序列化为 JSON
+		data, _ := json.Marshal(c)
+		out.Text = append(out.Text, string(data))
+	}
+}
+
+// appendResourceContents 转换资源内容。
+// read_resource 直接读取时文本资源即结果正文；嵌入在工具结果/提示词中时作为附件保留 URI
+func (m *MCPManager) appendResourceContents(ctx context.Context, server string, out *contentResult, rc mcp.ResourceContents, embedded bool) {
+	switch c := rc.(type) {
+	case mcp.TextResourceContents:
+		if !embedded {
+			out.Text = append(out.Text, c.Text)
+			return
+		}
+		out.Attachments = append(out.Attachments, MCPAttachment{
+			Type:     AttachmentResource,
+			MIMEType: c.MIMEType,
+			URI:      c.URI,
+			Text:     c.Text,
+		})
+	case mcp.BlobResourceContents:
+		out.Attachments = append(out.Attachments, m.binaryAttachment(ctx, server, AttachmentResource, c.MIMEType, c.URI, c.Blob))
+	}
+}
+
+// binaryAttachment 处理 base64 二进制内容：优先上传 OBS，失败或未配置时按大小内联
+func (m *MCPManager) binaryAttachment(ctx context.Context, server, kind, mimeType, uri, b64 string) MCPAttachment {
+	att := MCPAttachment{Type: kind, MIMEType: mimeType, URI: uri}
+	if uri != "" {
+		att.Name = path.Base(uri)
+	}
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		// 非法 base64 原样内联，交给调用方判断
+		att.Data = b64
+		return att
+	}
+	att.Size = len(data)
+
+	m.mu.RLock()
+	store, cfg := m.store, m.attachmentCfg
+	m.mu.RUnlock()
+
+	if store != nil && store.Enabled() {
+		err := m.uploadAttachment(ctx, store, cfg, server, data, &att)
+		if err == nil {
+			return att
+		}
+		log.Printf("[MCPManager] upload %s attachment from %s failed: %v, falling back to inline", kind, server, err)
+	}
+
+	if len(data) <= cfg.MaxInlineKB*1024 {
+		att.Data = b64
+	} else {
+		att.Omitted = true
+	}
+	return att
+}
+
+// uploadAttachment 按内容哈希命名上传（重复内容复用同一对象），并签发下载地址
+func (m *MCPManager) uploadAttachment(ctx context.Context, store attachmentStore, cfg AttachmentConfig, server string, data []byte, att *MCPAttachment) error {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:16])
+	if exts, _ := mime.ExtensionsByType(att.MIMEType); len(exts) > 0 {
+		name += exts[0]
+	}
+	key := path.Join(cfg.KeyPrefix, server, name)
+
+	if err := store.PutObject(ctx, obsstore.PutObjectRequest{
+		Key:         key,
+		Body:        bytes.NewReader(data),
+		Size:        int64(len(data)),
+		ContentType: att.MIMEType,
+		Metadata:    map[string]string{"mcp-server": server},
+	}); err != nil {
+		return fmt.Errorf("put object: %w", err)
+	}
+	signed, err := store.CreateSignedGetURL(ctx, key, time.Duration(cfg.URLTTLSec)*time.Second)
+	if err != nil {
+		return fmt.Errorf("sign url: %w", err)
+	}
+	att.ObjectKey = key
+	att.URL = signed.URL
+	att.ExpiresAt = signed.ExpiresAt
+	return nil
+}
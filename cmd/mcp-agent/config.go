@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"reflect"
+
+	"obsstore"
 )
 
 // MCPServerConfig 单个 MCP Server 配置
@@ -17,6 +19,38 @@ type MCPServerConfig struct {
 	URL       string            `json:"url"`       // http: 服务器 URL
 	Headers   map[string]string `json:"headers"`   // http: 请求头
 	Enabled   bool              `json:"enabled"`
+
+	IncludeTools   []string         `json:"include_tools,omitempty"` // 仅注册匹配的工具（原始工具名，支持 * 通配），为空表示全部
+	ExcludeTools   []string         `json:"exclude_tools,omitempty"` // 不注册的工具（优先于 include_tools）
+	HealthCheckSec int              `json:"health_check_sec"`        // ping 健康检查间隔，默认 5
+	Restart        MCPRestartPolicy `json:"restart"`
+}
+
+// MCPRestartPolicy 服务器失联后的重启（stdio 重新拉起进程 / http 重新连接）策略
+type MCPRestartPolicy struct {
+	MaxRetries        int     `json:"max_retries"`         // 连续重启失败上限，超过后标记为 failed 不再重试（SIGHUP 重载可恢复），0 表示不限
+	InitialBackoffSec float64 `json:"initial_backoff_sec"` // 首次重启等待，默认 1
+	MaxBackoffSec     float64 `json:"max_backoff_sec"`     // 等待上限，默认 60
+	Multiplier        float64 `json:"multiplier"`          // 退避倍数，默认 2
+}
+
+// MCPServeConfig 反向桥接：将 gateway 上所有 UAP 工具作为 MCP Server 暴露给外部 MCP 客户端
+type MCPServeConfig struct {
+	Enabled        bool              `json:"enabled"`          // 常驻模式下启动 Streamable HTTP 端点（-stdio 模式不受此开关影响）
+	HTTPAddr       string            `json:"http_addr"`        // 监听地址，默认 127.0.0.1:10091
+	EndpointPath   string            `json:"endpoint_path"`    // 默认 /mcp
+	Tokens         map[string]string `json:"tokens"`           // Bearer 令牌 → 账号（作为 tool_call 的 authenticated_user），为空时不启动 HTTP 端点
+	StdioAccount   string            `json:"stdio_account"`    // stdio 模式使用的账号（必填）
+	RefreshSec     int               `json:"refresh_sec"`      // 工具列表轮询间隔，默认 10
+	ExcludeTools   []string          `json:"exclude_tools"`    // 不暴露的工具（支持 * 通配）
+	CallTimeoutSec int               `json:"call_timeout_sec"` // 单次工具调用超时，默认 300
+}
+
+// AttachmentConfig MCP 返回的图片/音频/二进制资源的处理方式
+type AttachmentConfig struct {
+	KeyPrefix   string `json:"key_prefix"`    // OBS 对象键前缀，默认 mcp-attachments
+	URLTTLSec   int    `json:"url_ttl_sec"`   // 签名下载地址有效期，默认 86400
+	MaxInlineKB int    `json:"max_inline_kb"` // 未配置 OBS 时内联 base64 的上限，默认 256，超过只返回元信息
 }
 
 // Config mcp-agent 配置
@@ -28,6 +62,11 @@ type Config struct {
 	ToolPrefix         string                     `json:"tool_prefix"`
 	ToolCallTimeoutSec int                        `json:"tool_call_timeout_sec"`
 	MCPServers         map[string]MCPServerConfig `json:"mcp_servers"`
+	MCPServe           MCPServeConfig             `json:"mcp_serve"`
+
+	// 配置 OBS 后附件上传并返回签名地址，否则内联
+	OBS         obsstore.Config  `json:"obs,omitempty"`
+	Attachments AttachmentConfig `json:"attachments"`
 
 	// 部署保护文件（deploy-agent 增量部署时跳过这些文件）
 	ProtectedFiles []string `json:"protected_files,omitempty"`
@@ -42,6 +81,13 @@ func DefaultConfig() *Config {
 		ToolPrefix:         "mcp",
 		ToolCallTimeoutSec: 30,
 		MCPServers:         make(map[string]MCPServerConfig),
+		MCPServe: MCPServeConfig{
+			HTTPAddr:       "127.0.0.1:10091",
+			EndpointPath:   "/mcp",
+			RefreshSec:     10,
+			CallTimeoutSec: 300,
+		},
+		Attachments: AttachmentConfig{}.withDefaults(),
 
 		ProtectedFiles: []string{"mcp-agent.json"},
 	}
@@ -70,10 +116,60 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.ToolCallTimeoutSec <= 0 {
 		cfg.ToolCallTimeoutSec = 30
 	}
+	if cfg.MCPServe.HTTPAddr == "" {
+		cfg.MCPServe.HTTPAddr = "127.0.0.1:10091"
+	}
+	if cfg.MCPServe.EndpointPath == "" {
+		cfg.MCPServe.EndpointPath = "/mcp"
+	}
+	if cfg.MCPServe.RefreshSec <= 0 {
+		cfg.MCPServe.RefreshSec = 10
+	}
+	if cfg.MCPServe.CallTimeoutSec <= 0 {
+		cfg.MCPServe.CallTimeoutSec = 300
+	}
+	cfg.Attachments = cfg.Attachments.withDefaults()
+	for name, server := range cfg.MCPServers {
+		cfg.MCPServers[name] = server.withDefaults()
+	}
 
 	return cfg, nil
 }
 
+// withDefaults 填充健康检查与重启策略默认值
+func (c MCPServerConfig) withDefaults() MCPServerConfig {
+	if c.HealthCheckSec <= 0 {
+		c.HealthCheckSec = 5
+	}
+	if c.Restart.MaxRetries < 0 {
+		c.Restart.MaxRetries = 0
+	}
+	if c.Restart.InitialBackoffSec <= 0 {
+		c.Restart.InitialBackoffSec = 1
+	}
+	if c.Restart.MaxBackoffSec <= 0 {
+		c.Restart.MaxBackoffSec = 60
+	}
+	if c.Restart.Multiplier < 1 {
+		c.Restart.Multiplier = 2
+	}
+	return c
+}
+
+// withDefaults 填充附件配置默认值
+func (c AttachmentConfig) withDefaults() AttachmentConfig {
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = "mcp-attachments"
+	}
+	if c.URLTTLSec <= 0 {
+		c.URLTTLSec = 86400
+	}
+	if c.MaxInlineKB <= 0 {
+		c.MaxInlineKB = 256
+	}
+	return c
+}
+
 // DiffServers 比较新旧配置，返回需要 added/removed/changed 的 server 名
 func DiffServers(oldServers, newServers map[string]MCPServerConfig) (added, removed, changed []string) {
 	for name := range newServers {
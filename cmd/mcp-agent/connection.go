@@ -43,6 +43,7 @@ func NewConnection(cfg *Config, agentID string, mcpMgr *MCPManager, cfgPath stri
 
 	c.RegisterToolCallHandler(c.handleToolCallMsg)
 	c.RegisterHandler(uap.MsgError, c.handleError)
+	c.RegisterHandler(uap.MsgNotify, c.handleNotify)
 
 	return c
 }
@@ -96,3 +97,18 @@ func (c *Connection) handleError(msg *uap.Message) {
 	}
 	log.Printf("[Connection] error from=%s code=%s msg=%s", msg.From, payload.Code, payload.Message)
 }
+
+// handleNotify 处理 gateway 广播事件：agent 下线时立即刷新对外暴露的 MCP 工具列表
+func (c *Connection) handleNotify(msg *uap.Message) {
+	var event struct {
+		Event   string `json:"event"`
+		AgentID string `json:"agent_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &event); err != nil || event.Event != "agent_offline" {
+		return
+	}
+	if exporter != nil {
+		log.Printf("[Connection] agent %s offline, refreshing exported tools", event.AgentID)
+		exporter.TriggerRefresh()
+	}
+}
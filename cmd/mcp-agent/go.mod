@@ -1,11 +1,12 @@
 module mcp-agent
 
-go 1.24.0
+go 1.25.0
 
 require (
-	deploygen v0.0.0
 	agentbase v0.0.0
+	deploygen v0.0.0
 	github.com/mark3labs/mcp-go v0.45.0
+	obsstore v0.0.0
 	uap v0.0.0
 )
 
@@ -14,18 +15,20 @@ require (
 	github.com/buger/jsonparser v1.1.1 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
+	github.com/huaweicloud/huaweicloud-sdk-go-obs v3.25.9+incompatible // indirect
 	github.com/invopop/jsonschema v0.13.0 // indirect
 	github.com/mailru/easyjson v0.7.7 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	golang.org/x/net v0.50.0 // indirect
-	golang.org/x/text v0.34.0 // indirect
+	golang.org/x/net v0.52.0 // indirect
+	golang.org/x/text v0.35.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 replace (
-	deploygen => ../common/deploygen
 	agentbase => ../common/agentbase
+	deploygen => ../common/deploygen
+	obsstore => ../common/obsstore
 	uap => ../common/uap
 )
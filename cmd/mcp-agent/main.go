@@ -1,16 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"agentbase"
 	"deploygen"
+	"uap"
 )
 
 var (
@@ -19,12 +26,30 @@ var (
 	currentCfg  *Config
 	mcpMgr      *MCPManager
 	cfgPath     string
+	exporter    *ToolExporter // 反向桥接（UAP 工具 → MCP），未启用时为 nil
+
+	// 重新注册工具列表（热加载 / 服务器失联或恢复）互斥，并对健康变化做防抖
+	registerMu    sync.Mutex
+	refreshTimer  *time.Timer
+	refreshTimerM sync.Mutex
 )
 
+// registrationDebounce 服务器状态变化后等待的时间，合并短时间内的多次变化
+const registrationDebounce = 2 * time.Second
+
+// currentAgent 返回当前连接（热加载后为新连接）
+func currentAgent() *agentbase.AgentBase {
+	if currentConn == nil {
+		return nil
+	}
+	return currentConn.AgentBase
+}
+
 func main() {
 	configPathFlag := flag.String("config", "mcp-agent.json", "配置文件路径")
 	genConf := flag.Bool("genconf", false, "生成默认配置文件")
 	genDeploy := flag.Bool("gendeploy", false, "生成部署脚本")
+	stdioMode := flag.Bool("stdio", false, "以 stdio MCP Server 模式运行，将 gateway 上的全部工具暴露给 MCP 客户端")
 	flag.Parse()
 
 	if *genConf {
@@ -55,6 +80,11 @@ func main() {
 	}
 	currentCfg = cfg
 
+	if *stdioMode {
+		runStdioExport(cfg)
+		return
+	}
+
 	agentID := fmt.Sprintf("mcp_bridge_%d", os.Getpid())
 
 	log.Printf("[MCPAgent] starting agent_id=%s gateway=%s", agentID, cfg.ServerURL)
@@ -63,6 +93,10 @@ func main() {
 
 	// 创建 MCP 管理器
 	mcpMgr = NewMCPManager(cfg.ToolPrefix)
+	mcpMgr.OnChange = scheduleReregister
+	store := newAttachmentStore(cfg)
+	mcpMgr.SetAttachmentStore(store, cfg.Attachments)
+	log.Printf("[MCPAgent] attachments: obs=%v", store != nil)
 
 	// 逐个启动 MCP Server
 	for name, serverCfg := range cfg.MCPServers {
@@ -75,9 +109,20 @@ func main() {
 	tools := mcpMgr.BuildUAPTools()
 
 	// 创建连接
-	currentConn = NewConnection(cfg, agentID, mcpMgr, cfgPath)
-	currentConn.Client.Tools = tools
-	currentConn.ActiveTaskCounter = func() int { return int(atomic.LoadInt32(&currentConn.activeCount)) }
+	registerMu.Lock()
+	currentConn = newAgentConnection(cfg, agentID, tools)
+	registerMu.Unlock()
+
+	// 反向桥接：Streamable HTTP 端点（不暴露本 agent 自身桥接的工具，避免环路）
+	if cfg.MCPServe.Enabled {
+		exporter = NewToolExporter(cfg, agentID, currentAgent)
+		exporter.Start()
+		go func() {
+			if err := exporter.ListenHTTP(); err != nil {
+				log.Printf("[MCPAgent] mcp_serve http stopped: %v", err)
+			}
+		}()
+	}
 
 	// 信号处理
 	sigCh := make(chan os.Signal, 1)
@@ -92,6 +137,9 @@ func main() {
 			case os.Interrupt, syscall.SIGTERM:
 				log.Println("[MCPAgent] received signal, initiating shutdown...")
 				mcpMgr.StopAll()
+				if exporter != nil {
+					exporter.Stop()
+				}
 				currentConn.InitiateShutdown("signal")
 				os.Exit(0)
 			}
@@ -111,6 +159,7 @@ func handleReload() {
 	}
 
 	added, removed, changed := DiffServers(currentCfg.MCPServers, newCfg.MCPServers)
+	mcpMgr.SetAttachmentStore(newAttachmentStore(newCfg), newCfg.Attachments)
 
 	// 停止已移除和已变更的 server
 	for _, name := range removed {
@@ -132,25 +181,110 @@ func handleReload() {
 		}
 	}
 
-	// 重建工具列表并更新 gateway 注册
-	tools := mcpMgr.BuildUAPTools()
-	currentConn.Client.Tools = tools
-
-	// 更新配置引用
+	// 更新配置引用并重建工具列表
 	currentCfg = newCfg
-	currentConn.cfg = newCfg
+	tools := reregister(true)
+
+	log.Printf("[MCPAgent] reload complete: %d tools registered", len(tools))
+}
+
+// newAgentConnection 创建连接并设置工具列表、活跃任务计数与 ctrl_status 扩展状态
+func newAgentConnection(cfg *Config, agentID string, tools []uap.ToolDef) *Connection {
+	conn := NewConnection(cfg, agentID, mcpMgr, cfgPath)
+	conn.Client.Tools = tools
+	conn.ActiveTaskCounter = func() int { return int(atomic.LoadInt32(&conn.activeCount)) }
+	conn.StatusMeta = func() map[string]any {
+		return map[string]any{"mcp_servers": mcpMgr.Status()}
+	}
+	return conn
+}
+
+// scheduleReregister 服务器健康状态变化时（防抖后）重新注册工具列表
+func scheduleReregister() {
+	refreshTimerM.Lock()
+	defer refreshTimerM.Unlock()
+	if refreshTimer != nil {
+		refreshTimer.Stop()
+	}
+	refreshTimer = time.AfterFunc(registrationDebounce, func() { reregister(false) })
+}
+
+// reregister 重建工具列表。reload（force）时配置可能变化，重建连接；
+// 健康状态变化只在列表有变化时于现有连接上重新发送工具列表，不断开重连
+func reregister(force bool) []uap.ToolDef {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	tools := mcpMgr.BuildUAPTools()
+	if currentConn == nil {
+		return tools
+	}
+	if !force {
+		if sameTools(currentConn.Client.Tools, tools) {
+			return tools
+		}
+		if err := currentConn.Client.UpdateTools(tools); err != nil {
+			log.Printf("[MCPAgent] update tools failed (will be sent on reconnect): %v", err)
+		}
+		log.Printf("[MCPAgent] updated tool list: %d tools", len(tools))
+		return tools
+	}
 
-	// 断开重连，让 gateway 获取新工具列表
 	// Stop 会关闭 stopCh，需要重建 Connection
 	oldConn := currentConn
 	oldConn.Stop()
 
-	newConn := NewConnection(newCfg, oldConn.AgentID, mcpMgr, cfgPath)
-	newConn.Client.Tools = tools
-	currentConn = newConn
+	currentConn = newAgentConnection(currentCfg, oldConn.AgentID, tools)
 
 	// 在新 goroutine 中启动新连接
 	go currentConn.Run()
 
-	log.Printf("[MCPAgent] reload complete: %d tools registered", len(tools))
+	log.Printf("[MCPAgent] re-registered with %d tools", len(tools))
+	return tools
+}
+
+// sameTools 两个工具列表是否相同（忽略顺序）
+func sameTools(a, b []uap.ToolDef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sorted := func(tools []uap.ToolDef) []uap.ToolDef {
+		out := append([]uap.ToolDef(nil), tools...)
+		sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+		return out
+	}
+	sa, sb := sorted(a), sorted(b)
+	for i := range sa {
+		if sa[i].Name != sb[i].Name || sa[i].Description != sb[i].Description ||
+			!bytes.Equal(sa[i].Parameters, sb[i].Parameters) {
+			return false
+		}
+	}
+	return true
+}
+
+// runStdioExport stdio 模式：作为独立 agent 接入 gateway，通过 stdin/stdout 提供 MCP 服务。
+// stdout 为协议通道，日志输出到 stderr；客户端关闭 stdin 后退出。
+func runStdioExport(cfg *Config) {
+	if strings.TrimSpace(cfg.MCPServe.StdioAccount) == "" {
+		log.Fatalf("[MCPAgent] -stdio 需要配置 mcp_serve.stdio_account")
+	}
+	agentID := fmt.Sprintf("mcp_export_%d", os.Getpid())
+	log.Printf("[MCPAgent] stdio export agent_id=%s gateway=%s", agentID, cfg.ServerURL)
+
+	mcpMgr = NewMCPManager(cfg.ToolPrefix)
+	currentConn = NewConnection(cfg, agentID, mcpMgr, cfgPath)
+	go currentConn.Run()
+
+	exporter = NewToolExporter(cfg, agentID, currentAgent)
+	exporter.Start()
+	defer exporter.Stop()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := exporter.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+		log.Printf("[MCPAgent] stdio server stopped: %v", err)
+	}
+	currentConn.Stop()
 }
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agentbase"
+	"uap"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ========================= 反向桥接：UAP → MCP =========================
+//
+// ToolExporter 把 gateway 上所有在线 agent 的工具作为一个 MCP Server 暴露（stdio / Streamable HTTP），
+// tools/call 转发为 UAP tool_call 发给工具所属 agent。工具列表按 refresh_sec 轮询 gateway，
+// 收到 agent_offline 通知时立即刷新，列表变化时向客户端发送 notifications/tools/list_changed。
+
+// exportedTool MCP 工具与 UAP 工具的对应关系
+type exportedTool struct {
+	MCPName     string
+	UAPName     string
+	AgentID     string
+	Description string
+	Schema      json.RawMessage
+}
+
+// gatewayTool /api/gateway/tools 返回的单个工具
+type gatewayTool = agentbase.CatalogTool
+
+type accountCtxKey struct{}
+
+// ToolExporter UAP 工具网络的 MCP Server
+type ToolExporter struct {
+	cfg     MCPServeConfig
+	catalog *agentbase.ToolCatalog
+	selfID  string
+	agent   func() *agentbase.AgentBase // 热加载会替换连接，调用时再取
+
+	mcpServer   *server.MCPServer
+	mu          sync.RWMutex
+	routes      map[string]exportedTool // MCP 工具名 → 路由
+	fingerprint string
+
+	refreshCh chan struct{}
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewToolExporter 创建反向桥接
+func NewToolExporter(cfg *Config, selfID string, agent func() *agentbase.AgentBase) *ToolExporter {
+	e := &ToolExporter{
+		cfg:       cfg.MCPServe,
+		catalog:   agentbase.NewToolCatalog(strings.TrimRight(cfg.GatewayHTTP, "/")),
+		selfID:    selfID,
+		agent:     agent,
+		routes:    make(map[string]exportedTool),
+		refreshCh: make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+	e.mcpServer = server.NewMCPServer("go_blog-uap", "1.0.0", server.WithToolCapabilities(true))
+	return e
+}
+
+// Start 首次拉取工具列表并启动刷新循环
+func (e *ToolExporter) Start() {
+	if err := e.Refresh(); err != nil {
+		log.Printf("[MCPExport] initial refresh failed: %v", err)
+	}
+	go e.refreshLoop()
+}
+
+// Stop 停止刷新循环
+func (e *ToolExporter) Stop() {
+	e.stopOnce.Do(func() { close(e.stopCh) })
+}
+
+// TriggerRefresh 异步刷新（agent 上下线时调用）
+func (e *ToolExporter) TriggerRefresh() {
+	select {
+	case e.refreshCh <- struct{}{}:
+	default:
+	}
+}
+
+func (e *ToolExporter) refreshLoop() {
+	ticker := time.NewTicker(time.Duration(e.cfg.RefreshSec) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+		case <-e.refreshCh:
+		}
+		if err := e.Refresh(); err != nil {
+			log.Printf("[MCPExport] refresh failed: %v", err)
+		}
+	}
+}
+
+// Refresh 从 gateway 拉取工具并更新 MCP 工具列表；仅在名称/描述/参数变化时通知客户端
+func (e *ToolExporter) Refresh() error {
+	if err := e.catalog.Discover(e.selfID); err != nil {
+		return err
+	}
+	routes := buildExportedTools(e.catalog.Tools(), e.selfID, e.cfg.ExcludeTools)
+	fp := toolsFingerprint(routes)
+
+	e.mu.Lock()
+	changed := fp != e.fingerprint
+	e.routes = routes
+	e.fingerprint = fp
+	e.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	serverTools := make([]server.ServerTool, 0, len(routes))
+	for _, name := range sortedRouteNames(routes) {
+		rt := routes[name]
+		serverTools = append(serverTools, server.ServerTool{
+			Tool:    mcp.NewToolWithRawSchema(rt.MCPName, rt.Description, rt.Schema),
+			Handler: e.handlerFor(rt.MCPName),
+		})
+	}
+	e.mcpServer.SetTools(serverTools...)
+	log.Printf("[MCPExport] tool list changed: %d tools exported", len(serverTools))
+	return nil
+}
+
+// handlerFor 每次调用按当前路由查找所属 agent（agent 重启后 ID 会变化）
+func (e *ToolExporter) handlerFor(mcpName string) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		e.mu.RLock()
+		rt, ok := e.routes[mcpName]
+		e.mu.RUnlock()
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("tool %s is no longer available", mcpName)), nil
+		}
+
+		args, err := json.Marshal(req.GetArguments())
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+		}
+		// 没有认证账号的调用不转发（下游 agent 会把空账号当作未认证处理）
+		account, _ := ctx.Value(accountCtxKey{}).(string)
+		if account == "" {
+			return mcp.NewToolResultError("unauthenticated: no account bound to this MCP session"), nil
+		}
+
+		result, err := e.callUAPTool(ctx, rt, args, account)
+		if err != nil {
+			log.Printf("[MCPExport] tools/call %s → %s@%s failed: %v", mcpName, rt.UAPName, rt.AgentID, err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		log.Printf("[MCPExport] tools/call %s → %s@%s ok resultLen=%d account=%s",
+			mcpName, rt.UAPName, rt.AgentID, len(result), account)
+		return mcp.NewToolResultText(result), nil
+	}
+}
+
+// callUAPTool 发送 UAP tool_call 并等待 tool_result
+func (e *ToolExporter) callUAPTool(ctx context.Context, rt exportedTool, args json.RawMessage, account string) (string, error) {
+	ab := e.agent()
+	if ab == nil || !ab.IsConnected() {
+		return "", fmt.Errorf("not connected to gateway")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(e.cfg.CallTimeoutSec)*time.Second)
+	defer cancel()
+
+	reply, err := ab.Client.Call(ctx, rt.AgentID, uap.MsgToolCall, uap.ToolCallPayload{
+		ToolName:          rt.UAPName,
+		Arguments:         args,
+		AuthenticatedUser: account,
+	})
+	if err != nil {
+		var callErr *uap.CallError
+		switch {
+		case errors.As(err, &callErr):
+			return "", fmt.Errorf("tool error: %s", callErr.Message)
+		case errors.Is(err, context.DeadlineExceeded):
+			return "", fmt.Errorf("tool %s timeout after %ds", rt.UAPName, e.cfg.CallTimeoutSec)
+		default:
+			return "", err
+		}
+	}
+
+	var res uap.ToolResultPayload
+	if err := json.Unmarshal(reply.Payload, &res); err != nil {
+		return "", fmt.Errorf("invalid tool_result payload: %v", err)
+	}
+	if !res.Success {
+		return "", fmt.Errorf("tool error: %s", res.Error)
+	}
+	return res.Result, nil
+}
+
+// ServeStdio 在 stdin/stdout 上提供 MCP 服务，直到输入关闭或 ctx 取消；必须配置 stdio_account
+func (e *ToolExporter) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	account := strings.TrimSpace(e.cfg.StdioAccount)
+	if account == "" {
+		return errors.New("mcp_serve.stdio_account 未配置，拒绝以匿名身份提供 stdio 服务")
+	}
+	stdio := server.NewStdioServer(e.mcpServer)
+	stdio.SetContextFunc(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, accountCtxKey{}, account)
+	})
+	log.Printf("[MCPExport] serving MCP over stdio account=%q", account)
+	return stdio.Listen(ctx, in, out)
+}
+
+// HTTPHandler Streamable HTTP 处理器（含 Bearer 令牌校验）
+func (e *ToolExporter) HTTPHandler() http.Handler {
+	streamable := server.NewStreamableHTTPServer(e.mcpServer,
+		server.WithEndpointPath(e.cfg.EndpointPath),
+		server.WithHTTPContextFunc(func(ctx context.Context, r *http.Request) context.Context {
+			account, _ := e.authenticate(r)
+			return context.WithValue(ctx, accountCtxKey{}, account)
+		}),
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := e.authenticate(r); !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		streamable.ServeHTTP(w, r)
+	})
+}
+
+// ListenHTTP 启动 Streamable HTTP 服务（阻塞）；未配置 tokens 时拒绝启动
+func (e *ToolExporter) ListenHTTP() error {
+	if len(e.cfg.Tokens) == 0 {
+		return errors.New("mcp_serve.tokens 为空，拒绝启动未认证的 HTTP 端点")
+	}
+	mux := http.NewServeMux()
+	mux.Handle(e.cfg.EndpointPath, e.HTTPHandler())
+	log.Printf("[MCPExport] serving MCP over Streamable HTTP at http://%s%s", e.cfg.HTTPAddr, e.cfg.EndpointPath)
+	return http.ListenAndServe(e.cfg.HTTPAddr, mux)
+}
+
+// authenticate 解析 Bearer 令牌对应的账号；令牌未知或映射到空账号时拒绝
+func (e *ToolExporter) authenticate(r *http.Request) (string, bool) {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" {
+		return "", false
+	}
+	account := strings.TrimSpace(e.cfg.Tokens[token])
+	return account, account != ""
+}
+
+// ========================= 工具列表构建 =========================
+
+// buildExportedTools 将 gateway 工具转为 MCP 工具：排除自身与 exclude 规则，
+// 名称规范化为 MCP 允许的字符；同名工具按 agent_id 排序取第一个
+func buildExportedTools(tools []gatewayTool, selfID string, exclude []string) map[string]exportedTool {
+	sorted := make([]gatewayTool, len(tools))
+	copy(sorted, tools)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].AgentID < sorted[j].AgentID
+	})
+
+	routes := make(map[string]exportedTool)
+	for _, t := range sorted {
+		if t.Name == "" || t.AgentID == "" || t.AgentID == selfID || matchAny(t.Name, exclude) {
+			continue
+		}
+		name := sanitizeToolName(t.Name)
+		if prev, exists := routes[name]; exists {
+			if prev.UAPName != t.Name {
+				log.Printf("[MCPExport] tool name collision %s: %s vs %s, keep first", name, prev.UAPName, t.Name)
+			}
+			continue
+		}
+		schema := t.Parameters
+		if len(schema) == 0 || string(schema) == "null" {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		routes[name] = exportedTool{
+			MCPName:     name,
+			UAPName:     t.Name,
+			AgentID:     t.AgentID,
+			Description: t.Description,
+			Schema:      schema,
+		}
+	}
+	return routes
+}
+
+// sanitizeToolName MCP 工具名仅允许 [A-Za-z0-9_-]，最长 64
+func sanitizeToolName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	s := sb.String()
+	if len(s) > 64 {
+		s = s[:64]
+	}
+	return s
+}
+
+// matchAny 工具名是否匹配任一通配规则
+func matchAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedRouteNames(routes map[string]exportedTool) []string {
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toolsFingerprint 工具名/描述/参数的摘要，路由（agent_id）变化不算列表变化
+func toolsFingerprint(routes map[string]exportedTool) string {
+	h := sha256.New()
+	for _, name := range sortedRouteNames(routes) {
+		rt := routes[name]
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", rt.MCPName, rt.Description, rt.Schema)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
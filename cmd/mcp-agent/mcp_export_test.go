@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestBuildExportedTools(t *testing.T) {
+	tools := []gatewayTool{
+		{AgentID: "blog_2", Name: "RawGetBlogData", Description: "new"},
+		{AgentID: "blog_1", Name: "RawGetBlogData", Description: "old", Parameters: json.RawMessage(`{"type":"object"}`)},
+		{AgentID: "mcp_bridge_1", Name: "mcp.fs.read", Description: "self"},
+		{AgentID: "deploy_1", Name: "deploy.restart", Description: "restart"},
+		{AgentID: "cron_1", Name: "cron.add", Description: "add"},
+	}
+	routes := buildExportedTools(tools, "mcp_bridge_1", []string{"cron.*"})
+
+	if len(routes) != 2 {
+		t.Fatalf("unexpected routes: %+v", routes)
+	}
+	if rt := routes["RawGetBlogData"]; rt.AgentID != "blog_1" || string(rt.Schema) != `{"type":"object"}` {
+		t.Fatalf("duplicate tool should resolve to first agent_id: %+v", rt)
+	}
+	rt, ok := routes["deploy_restart"]
+	if !ok || rt.UAPName != "deploy.restart" || !strings.Contains(string(rt.Schema), `"properties"`) {
+		t.Fatalf("tool name not sanitized or default schema missing: %+v", routes)
+	}
+}
+
+func TestSanitizeToolName(t *testing.T) {
+	if got := sanitizeToolName("mcp.fs/读"); got != "mcp_fs__" {
+		t.Fatalf("got %q", got)
+	}
+	if got := sanitizeToolName(strings.Repeat("a", 80)); len(got) != 64 {
+		t.Fatalf("name not truncated: %d", len(got))
+	}
+}
+
+func TestExporterRefreshAndAuth(t *testing.T) {
+	tools := []gatewayTool{{AgentID: "todo_1", Name: "AddTodo", Description: "add todo"}}
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "tools": tools})
+	}))
+	defer gw.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayHTTP = gw.URL
+	cfg.MCPServe.Tokens = map[string]string{"secret": "alice"}
+	e := NewToolExporter(cfg, "mcp_bridge_1", currentAgent)
+
+	if err := e.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	fp := e.fingerprint
+	if e.mcpServer.GetTool("AddTodo") == nil {
+		t.Fatal("tool not exported")
+	}
+
+	// 仅 agent_id 变化（agent 重启）：更新路由但工具列表不变
+	tools[0].AgentID = "todo_2"
+	e.Refresh()
+	if e.fingerprint != fp || e.routes["AddTodo"].AgentID != "todo_2" {
+		t.Fatalf("route not updated or fingerprint changed: %+v", e.routes)
+	}
+
+	// agent 下线：工具被移除
+	tools = nil
+	e.Refresh()
+	if e.mcpServer.GetTool("AddTodo") != nil {
+		t.Fatal("offline tool still exported")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	if _, ok := e.authenticate(req); ok {
+		t.Fatal("request without token must be rejected")
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	if account, ok := e.authenticate(req); !ok || account != "alice" {
+		t.Fatalf("token should map to account, got %q %v", account, ok)
+	}
+}
+
+func TestExporterRefusesAnonymousAccess(t *testing.T) {
+	cfg := DefaultConfig()
+	e := NewToolExporter(cfg, "mcp_bridge_1", currentAgent)
+
+	if err := e.ListenHTTP(); err == nil {
+		t.Fatal("HTTP export without tokens must refuse to start")
+	}
+	if err := e.ServeStdio(context.Background(), strings.NewReader(""), io.Discard); err == nil {
+		t.Fatal("stdio export without stdio_account must refuse to start")
+	}
+
+	e.cfg.Tokens = map[string]string{"blank": " "}
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("Authorization", "Bearer blank")
+	if _, ok := e.authenticate(req); ok {
+		t.Fatal("token mapped to an empty account must be rejected")
+	}
+
+	e.routes["AddTodo"] = exportedTool{MCPName: "AddTodo", UAPName: "AddTodo", AgentID: "todo_1"}
+	res, err := e.handlerFor("AddTodo")(context.Background(), mcp.CallToolRequest{})
+	if err != nil || !res.IsError {
+		t.Fatalf("call without account must not be forwarded: %+v %v", res, err)
+	}
+}
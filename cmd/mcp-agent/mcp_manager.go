@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -17,15 +18,40 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// 服务器健康状态
+const (
+	ServerStatusStarting   = "starting"   // 首次连接中
+	ServerStatusHealthy    = "healthy"    // 最近一次 ping 成功
+	ServerStatusRestarting = "restarting" // 失联，按退避策略重启中
+	ServerStatusFailed     = "failed"     // 超过 restart.max_retries，已放弃
+)
+
 // MCPServerState 单个 MCP 服务器运行时状态
 type MCPServerState struct {
-	Name      string
-	Config    MCPServerConfig
-	Client    client.MCPClient
-	Tools     []mcp.Tool // MCP 原始工具定义
-	Available bool
-	mu        sync.RWMutex
-	cancel    context.CancelFunc // 停止管理 goroutine
+	Name         string
+	Config       MCPServerConfig
+	Client       client.MCPClient
+	Tools        []mcp.Tool             // MCP 原始工具定义
+	Capabilities mcp.ServerCapabilities // Initialize 返回的服务器能力（resources / prompts）
+	Available    bool
+	Status       string    // ServerStatus*
+	LastError    string    // 最近一次连接/ping 失败原因
+	LastCheck    time.Time // 最近一次健康检查时间
+	Restarts     int       // 成功重启次数
+	mu           sync.RWMutex
+	cancel       context.CancelFunc // 停止管理 goroutine
+}
+
+// MCPServerStatus ctrl_status 中报告的单个服务器状态
+type MCPServerStatus struct {
+	Name      string `json:"name"`
+	Transport string `json:"transport"`
+	Status    string `json:"status"`
+	Available bool   `json:"available"`
+	Tools     int    `json:"tools"` // 过滤后注册到 gateway 的工具数
+	Restarts  int    `json:"restarts"`
+	LastError string `json:"last_error,omitempty"`
+	LastCheck int64  `json:"last_check,omitempty"` // unix 毫秒
 }
 
 // MCPManager 管理所有 MCP 服务器连接
@@ -34,47 +60,64 @@ type MCPManager struct {
 	toolIndex  map[string]string          // "mcp.read_file" → "filesystem"
 	toolPrefix string
 	mu         sync.RWMutex
+
+	store         attachmentStore // 附件上传，nil 时内联
+	attachmentCfg AttachmentConfig
+
+	// OnChange 服务器可用性变化（失联/恢复/放弃）时回调，用于向 gateway 重新注册工具列表
+	OnChange func()
 }
 
 // NewMCPManager 创建 MCPManager
 func NewMCPManager(prefix string) *MCPManager {
 	return &MCPManager{
-		servers:    make(map[string]*MCPServerState),
-		toolIndex:  make(map[string]string),
-		toolPrefix: prefix,
+		servers:       make(map[string]*MCPServerState),
+		toolIndex:     make(map[string]string),
+		toolPrefix:    prefix,
+		attachmentCfg: AttachmentConfig{}.withDefaults(),
 	}
 }
 
-// StartServer 启动单个 MCP Server 连接
+// StartServer 启动单个 MCP Server 连接。
+// 首次连接失败时仍会纳入管理，由后台按重启策略重试；返回的 error 仅用于日志
 func (m *MCPManager) StartServer(name string, cfg MCPServerConfig) error {
 	if !cfg.Enabled {
 		log.Printf("[MCPManager] server %s disabled, skip", name)
 		return nil
 	}
+	cfg = cfg.withDefaults()
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	state := &MCPServerState{
 		Name:   name,
 		Config: cfg,
+		Status: ServerStatusStarting,
 		cancel: cancel,
 	}
 
-	// 创建客户端并初始化
-	if err := m.connectServer(ctx, state); err != nil {
-		cancel()
-		return fmt.Errorf("connect %s: %w", name, err)
-	}
-
 	m.mu.Lock()
 	m.servers[name] = state
 	m.mu.Unlock()
 
-	// stdio 传输：后台监控，崩溃时自动重启
-	if cfg.Transport == "stdio" {
-		go m.watchStdioServer(ctx, state)
+	// 创建客户端并初始化
+	err := m.connectServer(ctx, state)
+	state.mu.Lock()
+	state.LastCheck = time.Now()
+	if err != nil {
+		state.Status = ServerStatusRestarting
+		state.LastError = err.Error()
+	} else {
+		state.Status = ServerStatusHealthy
 	}
+	state.mu.Unlock()
+
+	// 后台健康检查，失联时按策略重启
+	go m.watchServer(ctx, state)
 
+	if err != nil {
+		return fmt.Errorf("connect %s: %w", name, err)
+	}
 	log.Printf("[MCPManager] server %s started, %d tools discovered", name, len(state.Tools))
 	return nil
 }
@@ -123,7 +166,7 @@ func (m *MCPManager) connectServer(ctx context.Context, state *MCPServerState) e
 	}
 	initReq.Params.Capabilities = mcp.ClientCapabilities{}
 
-	_, err = mcpClient.Initialize(initCtx, initReq)
+	initResult, err := mcpClient.Initialize(initCtx, initReq)
 	if err != nil {
 		mcpClient.Close()
 		return fmt.Errorf("initialize: %w", err)
@@ -142,6 +185,7 @@ func (m *MCPManager) connectServer(ctx context.Context, state *MCPServerState) e
 	state.mu.Lock()
 	state.Client = mcpClient
 	state.Tools = toolsResult.Tools
+	state.Capabilities = initResult.Capabilities
 	state.Available = true
 	state.mu.Unlock()
 
@@ -152,67 +196,115 @@ func (m *MCPManager) connectServer(ctx context.Context, state *MCPServerState) e
 	return nil
 }
 
-// watchStdioServer 监控 stdio 子进程，崩溃时指数退避重启
-func (m *MCPManager) watchStdioServer(ctx context.Context, state *MCPServerState) {
-	backoff := 0
+// watchServer 周期性 ping 服务器，失联时关闭客户端并按重启策略指数退避重连
+func (m *MCPManager) watchServer(ctx context.Context, state *MCPServerState) {
+	cfg := state.Config
+	interval := time.Duration(cfg.HealthCheckSec) * time.Second
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(5 * time.Second):
-		}
-
-		// 尝试 ping 检测存活
 		state.mu.RLock()
 		c := state.Client
 		state.mu.RUnlock()
 
-		if c == nil {
-			continue
+		if c != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+
+			pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+			err := c.Ping(pingCtx)
+			pingCancel()
+			if ctx.Err() != nil {
+				return
+			}
+
+			state.mu.Lock()
+			state.LastCheck = time.Now()
+			if err == nil {
+				state.Status = ServerStatusHealthy
+				state.mu.Unlock()
+				continue
+			}
+			log.Printf("[MCPManager] %s ping failed: %v, restarting...", state.Name, err)
+			state.Available = false
+			state.Status = ServerStatusRestarting
+			state.LastError = "ping: " + err.Error()
+			if state.Client != nil {
+				state.Client.Close()
+				state.Client = nil
+			}
+			state.mu.Unlock()
+			m.notifyChange()
 		}
 
-		pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
-		err := c.Ping(pingCtx)
-		pingCancel()
-
-		if err == nil {
-			backoff = 0
-			continue
-		}
-
-		log.Printf("[MCPManager] %s ping failed: %v, restarting...", state.Name, err)
-
-		state.mu.Lock()
-		state.Available = false
-		if state.Client != nil {
-			state.Client.Close()
-			state.Client = nil
+		if !m.restartServer(ctx, state) {
+			return
 		}
-		state.mu.Unlock()
+	}
+}
 
-		// 指数退避重启
-		delay := time.Duration(math.Min(float64(time.Second)*math.Pow(2, float64(backoff)), 60)) * time.Second
-		if delay < time.Second {
-			delay = time.Second
-		}
-		if delay > 60*time.Second {
-			delay = 60 * time.Second
+// restartServer 按退避策略重连，成功返回 true；超过重试上限或被停止返回 false
+func (m *MCPManager) restartServer(ctx context.Context, state *MCPServerState) bool {
+	policy := state.Config.Restart
+	for attempt := 1; ; attempt++ {
+		if policy.MaxRetries > 0 && attempt > policy.MaxRetries {
+			state.mu.Lock()
+			state.Status = ServerStatusFailed
+			state.mu.Unlock()
+			log.Printf("[MCPManager] %s gave up after %d restart attempts", state.Name, policy.MaxRetries)
+			m.notifyChange()
+			return false
 		}
-		backoff++
 
-		log.Printf("[MCPManager] %s waiting %v before restart...", state.Name, delay)
+		delay := restartBackoff(policy, attempt)
+		log.Printf("[MCPManager] %s waiting %v before restart (attempt %d)...", state.Name, delay, attempt)
 		select {
 		case <-ctx.Done():
-			return
+			return false
 		case <-time.After(delay):
 		}
 
-		if err := m.connectServer(ctx, state); err != nil {
+		err := m.connectServer(ctx, state)
+		state.mu.Lock()
+		state.LastCheck = time.Now()
+		if err != nil {
+			state.LastError = err.Error()
+			state.mu.Unlock()
 			log.Printf("[MCPManager] %s restart failed: %v", state.Name, err)
-		} else {
-			log.Printf("[MCPManager] %s restarted successfully", state.Name)
-			backoff = 0
+			continue
 		}
+		if ctx.Err() != nil {
+			// 重连期间被 StopServer，丢弃新客户端
+			if state.Client != nil {
+				state.Client.Close()
+				state.Client = nil
+			}
+			state.Available = false
+			state.mu.Unlock()
+			return false
+		}
+		state.Status = ServerStatusHealthy
+		state.Restarts++
+		state.mu.Unlock()
+		log.Printf("[MCPManager] %s restarted successfully", state.Name)
+		m.notifyChange()
+		return true
+	}
+}
+
+// restartBackoff 第 attempt 次（从 1 开始）重启前的等待时间
+func restartBackoff(policy MCPRestartPolicy, attempt int) time.Duration {
+	sec := policy.InitialBackoffSec * math.Pow(policy.Multiplier, float64(attempt-1))
+	if sec > policy.MaxBackoffSec {
+		sec = policy.MaxBackoffSec
+	}
+	return time.Duration(sec * float64(time.Second))
+}
+
+func (m *MCPManager) notifyChange() {
+	if m.OnChange != nil {
+		m.OnChange()
 	}
 }
 
@@ -267,24 +359,38 @@ func (m *MCPManager) BuildUAPTools() []uap.ToolDef {
 		tool       mcp.Tool
 	}
 	var allTools []toolEntry
+	var hasResources, hasPrompts bool
 
 	for serverName, state := range m.servers {
 		state.mu.RLock()
-		for _, t := range state.Tools {
-			nameCount[t.Name]++
-			allTools = append(allTools, toolEntry{serverName: serverName, tool: t})
+		// 失联的服务器不注册工具，避免 llm-agent 调用到已失效的工具
+		if state.Available {
+			for _, t := range filterTools(state.Tools, state.Config.IncludeTools, state.Config.ExcludeTools) {
+				nameCount[t.Name]++
+				allTools = append(allTools, toolEntry{serverName: serverName, tool: t})
+			}
+			hasResources = hasResources || hasResourcesCap(state.Capabilities)
+			hasPrompts = hasPrompts || hasPromptsCap(state.Capabilities)
 		}
 		state.mu.RUnlock()
 	}
 
-	// 第二遍：构建 UAP 工具定义，同名消歧
+	// 内置资源/提示词工具占用 mcp.{name}，同名的服务器工具改用消歧格式
 	newIndex := make(map[string]string)
 	var uapTools []uap.ToolDef
+	for _, def := range builtinToolDefs(hasResources, hasPrompts) {
+		nameCount[def.Name]++
+		def.Name = fmt.Sprintf("%s.%s", m.toolPrefix, def.Name)
+		uapTools = append(uapTools, def)
+		newIndex[def.Name] = ""
+	}
+
+	// 第二遍：构建 UAP 工具定义，同名消歧
 
 	for _, entry := range allTools {
 		var prefixedName string
 		if nameCount[entry.tool.Name] > 1 {
-			// 冲突：mcp.{server}_{tool}
+			// 冲突（含与内置工具同名）：mcp.{server}_{tool}
 			prefixedName = fmt.Sprintf("%s.%s_%s", m.toolPrefix, entry.serverName, entry.tool.Name)
 		} else {
 			// 唯一：mcp.{tool}
@@ -301,6 +407,53 @@ func (m *MCPManager) BuildUAPTools() []uap.ToolDef {
 	return uapTools
 }
 
+// filterTools 按 include/exclude 通配列表过滤工具（exclude 优先）
+func filterTools(tools []mcp.Tool, include, exclude []string) []mcp.Tool {
+	if len(include) == 0 && len(exclude) == 0 {
+		return tools
+	}
+	var kept []mcp.Tool
+	for _, t := range tools {
+		if len(include) > 0 && !matchAny(t.Name, include) {
+			continue
+		}
+		if matchAny(t.Name, exclude) {
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept
+}
+
+// Status 各服务器健康状态（按名称排序），通过 ctrl_status 上报
+func (m *MCPManager) Status() []MCPServerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]MCPServerStatus, 0, len(m.servers))
+	for name, state := range m.servers {
+		state.mu.RLock()
+		st := MCPServerStatus{
+			Name:      name,
+			Transport: state.Config.Transport,
+			Status:    state.Status,
+			Available: state.Available,
+			Restarts:  state.Restarts,
+			LastError: state.LastError,
+		}
+		if state.Available {
+			st.Tools = len(filterTools(state.Tools, state.Config.IncludeTools, state.Config.ExcludeTools))
+		}
+		if !state.LastCheck.IsZero() {
+			st.LastCheck = state.LastCheck.UnixMilli()
+		}
+		state.mu.RUnlock()
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
 // CallTool 代理工具调用
 func (m *MCPManager) CallTool(ctx context.Context, prefixedName string, args map[string]interface{}) (string, error) {
 	m.mu.RLock()
@@ -310,6 +463,9 @@ func (m *MCPManager) CallTool(ctx context.Context, prefixedName string, args map
 	if !exists {
 		return "", fmt.Errorf("unknown tool: %s", prefixedName)
 	}
+	if serverName == "" {
+		return m.callBuiltin(ctx, strings.TrimPrefix(prefixedName, m.toolPrefix+"."), args)
+	}
 
 	m.mu.RLock()
 	state, exists := m.servers[serverName]
@@ -340,11 +496,15 @@ func (m *MCPManager) CallTool(ctx context.Context, prefixedName string, args map
 		return "", fmt.Errorf("call tool %s on %s: %w", originalName, serverName, err)
 	}
 
+	var out contentResult
+	for _, content := range result.Content {
+		m.appendContent(ctx, serverName, &out, content)
+	}
 	if result.IsError {
-		return "", fmt.Errorf("tool %s returned error: %s", originalName, mcpResultToString(result))
+		return "", fmt.Errorf("tool %s returned error: %s", originalName, strings.Join(out.Text, "\n"))
 	}
 
-	return mcpResultToString(result), nil
+	return out.render()
 }
 
 // stripPrefix 从带前缀的工具名还原原始名
@@ -379,26 +539,3 @@ func mcpToolToUAPToolDef(tool mcp.Tool, prefixedName string) uap.ToolDef {
 		Parameters:  params,
 	}
 }
-
-// mcpResultToString 将 MCP CallToolResult 转为字符串
-func mcpResultToString(result *mcp.CallToolResult) string {
-	if result == nil {
-		return ""
-	}
-
-	var parts []string
-	for _, content := range result.Content {
-		switch c := content.(type) {
-		case mcp.TextContent:
-			parts = append(parts, c.Text)
-		case *mcp.TextContent:
-			parts = append(parts, c.Text)
-		default:
-			// 非文本内容序列化为 JSON
-			data, _ := json.Marshal(c)
-			parts = append(parts, string(data))
-		}
-	}
-
-	return strings.Join(parts, "\n")
-}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	policy := MCPServerConfig{}.withDefaults().Restart
+	policy.MaxBackoffSec = 5
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := restartBackoff(policy, i+1); got != w {
+			t.Fatalf("attempt %d: got %v want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestFilterTools(t *testing.T) {
+	tools := []mcp.Tool{{Name: "read_file"}, {Name: "write_file"}, {Name: "list_dir"}, {Name: "delete_file"}}
+	kept := filterTools(tools, []string{"*_file"}, []string{"delete_*"})
+	if len(kept) != 2 || kept[0].Name != "read_file" || kept[1].Name != "write_file" {
+		t.Fatalf("unexpected tools: %+v", kept)
+	}
+	if got := filterTools(tools, nil, nil); len(got) != 4 {
+		t.Fatalf("no filters should keep all tools, got %d", len(got))
+	}
+}
+
+func TestBuildUAPToolsSkipsUnavailableServers(t *testing.T) {
+	m := NewMCPManager("mcp")
+	m.servers["fs"] = &MCPServerState{
+		Name:      "fs",
+		Config:    MCPServerConfig{ExcludeTools: []string{"write_*"}},
+		Tools:     []mcp.Tool{{Name: "read_file"}, {Name: "write_file"}},
+		Available: true,
+		Status:    ServerStatusHealthy,
+	}
+	m.servers["dead"] = &MCPServerState{
+		Name:   "dead",
+		Tools:  []mcp.Tool{{Name: "search"}},
+		Status: ServerStatusRestarting,
+	}
+
+	tools := m.BuildUAPTools()
+	if len(tools) != 1 || tools[0].Name != "mcp.read_file" {
+		t.Fatalf("unexpected tools: %+v", tools)
+	}
+	if _, err := m.CallTool(context.Background(), "mcp.write_file", nil); err == nil {
+		t.Fatal("excluded tool should not be callable")
+	}
+
+	status := m.Status()
+	if len(status) != 2 || status[0].Name != "dead" || status[0].Available || status[1].Tools != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestWatchServerRestartsHTTPServer(t *testing.T) {
+	mcpServer := server.NewMCPServer("test", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("echo"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	ts := httptest.NewServer(server.NewStreamableHTTPServer(mcpServer))
+	defer ts.Close()
+
+	m := NewMCPManager("mcp")
+	var changes atomic.Int32
+	m.OnChange = func() { changes.Add(1) }
+	defer m.StopAll()
+
+	cfg := MCPServerConfig{
+		Transport:      "http",
+		URL:            ts.URL,
+		Enabled:        true,
+		HealthCheckSec: 1,
+		Restart:        MCPRestartPolicy{MaxRetries: 2, InitialBackoffSec: 0.1, MaxBackoffSec: 0.2},
+	}
+	if err := m.StartServer("remote", cfg); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if tools := m.BuildUAPTools(); len(tools) != 1 {
+		t.Fatalf("expected 1 tool, got %+v", tools)
+	}
+
+	// 服务端关闭：ping 失败 → 重启耗尽 → failed，工具下线
+	ts.CloseClientConnections()
+	ts.Close()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if st := m.Status(); st[0].Status == ServerStatusFailed {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	st := m.Status()[0]
+	if st.Status != ServerStatusFailed || st.Available || st.LastError == "" {
+		t.Fatalf("unexpected status after server death: %+v", st)
+	}
+	if changes.Load() < 2 {
+		t.Fatalf("expected OnChange for unavailable and failed, got %d", changes.Load())
+	}
+	if tools := m.BuildUAPTools(); len(tools) != 0 {
+		t.Fatalf("dead server tools still registered: %+v", tools)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"uap"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ========================= 资源 / 提示词桥接 =========================
+//
+// MCP 的 resources / prompts 不是工具，llm-agent 无法直接使用。这里发布四个只读的内置 UAP 工具：
+//   {prefix}.list_resources / {prefix}.read_resource / {prefix}.list_prompts / {prefix}.get_prompt
+// 仅当至少一个可用服务器声明了对应 capability 时注册；toolIndex 中以空 server 名标记。
+
+const (
+	builtinListResources = "list_resources"
+	builtinReadResource  = "read_resource"
+	builtinListPrompts   = "list_prompts"
+	builtinGetPrompt     = "get_prompt"
+)
+
+// builtinToolDefs 按服务器能力生成内置工具定义（名称未加前缀）
+func builtinToolDefs(hasResources, hasPrompts bool) []uap.ToolDef {
+	var defs []uap.ToolDef
+	if hasResources {
+		defs = append(defs,
+			uap.ToolDef{
+				Name:        builtinListResources,
+				Description: "列出已连接 MCP 服务器提供的资源（文件、数据库表等）及资源模板",
+				Parameters: json.RawMessage(`{"type":"object","properties":{` +
+					`"server":{"type":"string","description":"只列出指定服务器，默认全部"}}}`),
+			},
+			uap.ToolDef{
+				Name:        builtinReadResource,
+				Description: "按 URI 读取 MCP 资源内容；二进制内容以附件形式返回",
+				Parameters: json.RawMessage(`{"type":"object","properties":{` +
+					`"uri":{"type":"string","description":"资源 URI（来自 list_resources 或按资源模板构造）"},` +
+					`"server":{"type":"string","description":"资源所在服务器，默认依次尝试所有提供资源的服务器"}},` +
+					`"required":["uri"]}`),
+			},
+		)
+	}
+	if hasPrompts {
+		defs = append(defs,
+			uap.ToolDef{
+				Name:        builtinListPrompts,
+				Description: "列出已连接 MCP 服务器提供的提示词模板及其参数",
+				Parameters: json.RawMessage(`{"type":"object","properties":{` +
+					`"server":{"type":"string","description":"只列出指定服务器，默认全部"}}}`),
+			},
+			uap.ToolDef{
+				Name:        builtinGetPrompt,
+				Description: "渲染 MCP 提示词模板，返回消息列表",
+				Parameters: json.RawMessage(`{"type":"object","properties":{` +
+					`"name":{"type":"string","description":"提示词名称"},` +
+					`"server":{"type":"string","description":"提示词所在服务器，默认按名称查找"},` +
+					`"arguments":{"type":"object","additionalProperties":{"type":"string"},"description":"模板参数"}},` +
+					`"required":["name"]}`),
+			},
+		)
+	}
+	return defs
+}
+
+// ResourceInfo list_resources 返回的单个资源
+type ResourceInfo struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	MIMEType    string `json:"mime_type,omitempty"`
+}
+
+// ResourceTemplateInfo list_resources 返回的资源模板
+type ResourceTemplateInfo struct {
+	URITemplate string `json:"uri_template"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	MIMEType    string `json:"mime_type,omitempty"`
+}
+
+// ServerResources 单个服务器的资源列表
+type ServerResources struct {
+	Server    string                 `json:"server"`
+	Resources []ResourceInfo         `json:"resources,omitempty"`
+	Templates []ResourceTemplateInfo `json:"templates,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// PromptInfo list_prompts 返回的单个提示词
+type PromptInfo struct {
+	Server      string               `json:"server"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Arguments   []mcp.PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptMessage get_prompt 渲染后的单条消息
+type PromptMessage struct {
+	Role        string          `json:"role"`
+	Text        string          `json:"text,omitempty"`
+	Attachments []MCPAttachment `json:"attachments,omitempty"`
+}
+
+// RenderedPrompt get_prompt 返回结果
+type RenderedPrompt struct {
+	Server      string          `json:"server"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// callBuiltin 执行内置资源/提示词工具
+func (m *MCPManager) callBuiltin(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	server := stringArg(args, "server")
+	switch name {
+	case builtinListResources:
+		return marshalResult(m.listResources(ctx, server))
+	case builtinReadResource:
+		uri := stringArg(args, "uri")
+		if uri == "" {
+			return "", fmt.Errorf("uri is required")
+		}
+		return m.readResource(ctx, server, uri)
+	case builtinListPrompts:
+		return marshalResult(m.listPrompts(ctx, server))
+	case builtinGetPrompt:
+		promptName := stringArg(args, "name")
+		if promptName == "" {
+			return "", fmt.Errorf("name is required")
+		}
+		promptArgs := make(map[string]string)
+		if raw, ok := args["arguments"].(map[string]interface{}); ok {
+			for k, v := range raw {
+				if s, ok := v.(string); ok {
+					promptArgs[k] = s
+				} else {
+					promptArgs[k] = fmt.Sprint(v)
+				}
+			}
+		}
+		return marshalResult(m.getPrompt(ctx, server, promptName, promptArgs))
+	}
+	return "", fmt.Errorf("unknown tool: %s", name)
+}
+
+// capableServers 返回声明了指定能力的可用服务器（按名称排序）；server 非空时只返回该服务器
+func (m *MCPManager) capableServers(server string, has func(mcp.ServerCapabilities) bool) ([]*MCPServerState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var states []*MCPServerState
+	for name, state := range m.servers {
+		if server != "" && name != server {
+			continue
+		}
+		state.mu.RLock()
+		ok := state.Available && state.Client != nil && has(state.Capabilities)
+		state.mu.RUnlock()
+		if ok {
+			states = append(states, state)
+		}
+	}
+	if server != "" && len(states) == 0 {
+		return nil, fmt.Errorf("server %s not available or does not support this operation", server)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states, nil
+}
+
+func hasResourcesCap(c mcp.ServerCapabilities) bool { return c.Resources != nil }
+func hasPromptsCap(c mcp.ServerCapabilities) bool   { return c.Prompts != nil }
+
+// listResources 汇总各服务器的资源与资源模板，单个服务器失败只记录错误
+func (m *MCPManager) listResources(ctx context.Context, server string) ([]ServerResources, error) {
+	states, err := m.capableServers(server, hasResourcesCap)
+	if err != nil {
+		return nil, err
+	}
+	listings := make([]ServerResources, 0, len(states))
+	for _, state := range states {
+		listing := ServerResources{Server: state.Name}
+		c := state.client()
+		if c == nil {
+			listing.Error = "not available"
+			listings = append(listings, listing)
+			continue
+		}
+
+		res, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+		if err != nil {
+			listing.Error = err.Error()
+			listings = append(listings, listing)
+			continue
+		}
+		for _, r := range res.Resources {
+			listing.Resources = append(listing.Resources, ResourceInfo{
+				URI:         r.URI,
+				Name:        r.Name,
+				Description: r.Description,
+				MIMEType:    r.MIMEType,
+			})
+		}
+
+		// 资源模板是可选能力，失败不影响资源列表
+		if tpl, err := c.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{}); err == nil {
+			for _, t := range tpl.ResourceTemplates {
+				info := ResourceTemplateInfo{Name: t.Name, Description: t.Description, MIMEType: t.MIMEType}
+				if t.URITemplate != nil {
+					info.URITemplate = t.URITemplate.Raw()
+				}
+				listing.Templates = append(listing.Templates, info)
+			}
+		}
+		listings = append(listings, listing)
+	}
+	return listings, nil
+}
+
+// readResource 读取资源。未指定服务器时按名称顺序依次尝试，返回第一个成功的结果
+func (m *MCPManager) readResource(ctx context.Context, server, uri string) (string, error) {
+	states, err := m.capableServers(server, hasResourcesCap)
+	if err != nil {
+		return "", err
+	}
+	if len(states) == 0 {
+		return "", fmt.Errorf("no available server provides resources")
+	}
+
+	var errs []string
+	for _, state := range states {
+		c := state.client()
+		if c == nil {
+			continue
+		}
+		req := mcp.ReadResourceRequest{}
+		req.Params.URI = uri
+		res, err := c.ReadResource(ctx, req)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", state.Name, err))
+			continue
+		}
+
+		var out contentResult
+		for _, rc := range res.Contents {
+			m.appendResourceContents(ctx, state.Name, &out, rc, false)
+		}
+		return out.render()
+	}
+	return "", fmt.Errorf("read resource %s failed: %s", uri, strings.Join(errs, "; "))
+}
+
+// listPrompts 汇总各服务器的提示词模板
+func (m *MCPManager) listPrompts(ctx context.Context, server string) ([]PromptInfo, error) {
+	states, err := m.capableServers(server, hasPromptsCap)
+	if err != nil {
+		return nil, err
+	}
+	prompts := []PromptInfo{}
+	var errs []string
+	for _, state := range states {
+		c := state.client()
+		if c == nil {
+			continue
+		}
+		res, err := c.ListPrompts(ctx, mcp.ListPromptsRequest{})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", state.Name, err))
+			continue
+		}
+		for _, p := range res.Prompts {
+			prompts = append(prompts, PromptInfo{
+				Server:      state.Name,
+				Name:        p.Name,
+				Description: p.Description,
+				Arguments:   p.Arguments,
+			})
+		}
+	}
+	if len(prompts) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("list prompts failed: %s", strings.Join(errs, "; "))
+	}
+	return prompts, nil
+}
+
+// getPrompt 渲染提示词。未指定服务器时先按名称定位所属服务器
+func (m *MCPManager) getPrompt(ctx context.Context, server, name string, args map[string]string) (*RenderedPrompt, error) {
+	if server == "" {
+		prompts, err := m.listPrompts(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range prompts {
+			if p.Name == name {
+				server = p.Server
+				break
+			}
+		}
+		if server == "" {
+			return nil, fmt.Errorf("prompt %s not found", name)
+		}
+	}
+
+	states, err := m.capableServers(server, hasPromptsCap)
+	if err != nil {
+		return nil, err
+	}
+	c := states[0].client()
+	if c == nil {
+		return nil, fmt.Errorf("server %s not available", server)
+	}
+
+	req := mcp.GetPromptRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	res, err := c.GetPrompt(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("get prompt %s on %s: %w", name, server, err)
+	}
+
+	rendered := &RenderedPrompt{Server: server, Name: name, Description: res.Description, Messages: []PromptMessage{}}
+	for _, msg := range res.Messages {
+		var out contentResult
+		m.appendContent(ctx, server, &out, msg.Content)
+		rendered.Messages = append(rendered.Messages, PromptMessage{
+			Role:        string(msg.Role),
+			Text:        strings.Join(out.Text, "\n"),
+			Attachments: out.Attachments,
+		})
+	}
+	return rendered, nil
+}
+
+// client 返回当前客户端，服务器不可用时返回 nil
+func (s *MCPServerState) client() client.MCPClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.Available {
+		return nil
+	}
+	return s.Client
+}
+
+func stringArg(args map[string]interface{}, key string) string {
+	s, _ := args[key].(string)
+	return strings.TrimSpace(s)
+}
+
+func marshalResult(v any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
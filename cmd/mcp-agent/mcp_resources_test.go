@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"obsstore"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type fakeAttachmentStore struct {
+	objects map[string][]byte
+}
+
+func (f *fakeAttachmentStore) Enabled() bool { return true }
+
+func (f *fakeAttachmentStore) PutObject(_ context.Context, req obsstore.PutObjectRequest) error {
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	f.objects[req.Key] = data
+	return nil
+}
+
+func (f *fakeAttachmentStore) CreateSignedGetURL(_ context.Context, key string, ttl time.Duration) (*obsstore.SignedURL, error) {
+	return &obsstore.SignedURL{URL: "https://obs.example.com/" + key, ExpiresAt: time.Now().Add(ttl).Unix()}, nil
+}
+
+var testPNG = []byte("\x89PNG\r\n\x1a\nfake-image")
+
+// startResourceServer 启动一个提供资源、资源模板、提示词和图片工具的 MCP 服务器
+func startResourceServer(t *testing.T) *MCPManager {
+	t.Helper()
+	s := server.NewMCPServer("test", "1.0.0")
+	s.AddResource(mcp.NewResource("file:///notes.txt", "notes.txt", mcp.WithMIMEType("text/plain")),
+		func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{mcp.TextResourceContents{URI: req.Params.URI, MIMEType: "text/plain", Text: "hello notes"}}, nil
+		})
+	s.AddResource(mcp.NewResource("file:///logo.png", "logo.png", mcp.WithMIMEType("image/png")),
+		func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{mcp.BlobResourceContents{URI: req.Params.URI, MIMEType: "image/png", Blob: base64.StdEncoding.EncodeToString(testPNG)}}, nil
+		})
+	s.AddResourceTemplate(mcp.NewResourceTemplate("db://tables/{name}", "table"),
+		func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{mcp.TextResourceContents{URI: req.Params.URI, Text: "rows of " + req.Params.URI}}, nil
+		})
+	s.AddPrompt(mcp.NewPrompt("review", mcp.WithPromptDescription("code review"), mcp.WithArgument("lang", mcp.RequiredArgument())),
+		func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return mcp.NewGetPromptResult("code review", []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("review this "+req.Params.Arguments["lang"]+" code")),
+			}), nil
+		})
+	s.AddTool(mcp.NewTool("screenshot"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result := mcp.NewToolResultImage("captured", base64.StdEncoding.EncodeToString(testPNG), "image/png")
+		result.Content = append(result.Content, mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "file:///page.html", MIMEType: "text/html", Text: "<p>hi</p>"}))
+		return result, nil
+	})
+	s.AddTool(mcp.NewTool("read_resource"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("server tool"), nil
+	})
+	ts := httptest.NewServer(server.NewStreamableHTTPServer(s))
+	t.Cleanup(ts.Close)
+
+	m := NewMCPManager("mcp")
+	t.Cleanup(m.StopAll)
+	if err := m.StartServer("docs", MCPServerConfig{Transport: "http", URL: ts.URL, Enabled: true}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	return m
+}
+
+func TestBuiltinResourceAndPromptTools(t *testing.T) {
+	m := startResourceServer(t)
+	ctx := context.Background()
+
+	names := make(map[string]bool)
+	for _, def := range m.BuildUAPTools() {
+		names[def.Name] = true
+	}
+	for _, want := range []string{"mcp.list_resources", "mcp.read_resource", "mcp.list_prompts", "mcp.get_prompt", "mcp.screenshot", "mcp.docs_read_resource"} {
+		if !names[want] {
+			t.Fatalf("missing tool %s in %v", want, names)
+		}
+	}
+	if out, err := m.CallTool(ctx, "mcp.docs_read_resource", nil); err != nil || out != "server tool" {
+		t.Fatalf("colliding server tool: %q %v", out, err)
+	}
+
+	out, err := m.CallTool(ctx, "mcp.list_resources", nil)
+	if err != nil {
+		t.Fatalf("list_resources: %v", err)
+	}
+	var listings []ServerResources
+	if err := json.Unmarshal([]byte(out), &listings); err != nil {
+		t.Fatalf("decode %s: %v", out, err)
+	}
+	if len(listings) != 1 || len(listings[0].Resources) != 2 || len(listings[0].Templates) != 1 || listings[0].Templates[0].URITemplate != "db://tables/{name}" {
+		t.Fatalf("unexpected listing: %s", out)
+	}
+
+	if out, err = m.CallTool(ctx, "mcp.read_resource", map[string]interface{}{"uri": "file:///notes.txt"}); err != nil || out != "hello notes" {
+		t.Fatalf("read text resource: %q %v", out, err)
+	}
+	if out, err = m.CallTool(ctx, "mcp.read_resource", map[string]interface{}{"uri": "db://tables/users", "server": "docs"}); err != nil || out != "rows of db://tables/users" {
+		t.Fatalf("read templated resource: %q %v", out, err)
+	}
+	if _, err = m.CallTool(ctx, "mcp.read_resource", map[string]interface{}{"uri": "file:///missing"}); err == nil {
+		t.Fatal("reading a missing resource should fail")
+	}
+
+	if out, err = m.CallTool(ctx, "mcp.list_prompts", nil); err != nil || !strings.Contains(out, `"name":"review"`) || !strings.Contains(out, `"lang"`) {
+		t.Fatalf("list_prompts: %s %v", out, err)
+	}
+	out, err = m.CallTool(ctx, "mcp.get_prompt", map[string]interface{}{"name": "review", "arguments": map[string]interface{}{"lang": "go"}})
+	if err != nil {
+		t.Fatalf("get_prompt: %v", err)
+	}
+	var rendered RenderedPrompt
+	if err := json.Unmarshal([]byte(out), &rendered); err != nil {
+		t.Fatalf("decode %s: %v", out, err)
+	}
+	if rendered.Server != "docs" || len(rendered.Messages) != 1 || rendered.Messages[0].Role != "user" || rendered.Messages[0].Text != "review this go code" {
+		t.Fatalf("unexpected prompt: %s", out)
+	}
+}
+
+func TestBinaryResultsBecomeAttachments(t *testing.T) {
+	m := startResourceServer(t)
+	ctx := context.Background()
+	m.BuildUAPTools()
+
+	decode := func(out string) (result struct {
+		Text        string          `json:"text"`
+		Attachments []MCPAttachment `json:"attachments"`
+	}) {
+		t.Helper()
+		if err := json.Unmarshal([]byte(out), &result); err != nil {
+			t.Fatalf("decode %s: %v", out, err)
+		}
+		return
+	}
+
+	// 未配置 OBS：内联 base64
+	out, err := m.CallTool(ctx, "mcp.screenshot", nil)
+	if err != nil {
+		t.Fatalf("screenshot: %v", err)
+	}
+	res := decode(out)
+	if res.Text != "captured" || len(res.Attachments) != 2 {
+		t.Fatalf("unexpected result: %s", out)
+	}
+	img, page := res.Attachments[0], res.Attachments[1]
+	if img.Type != AttachmentImage || img.Size != len(testPNG) || img.Data != base64.StdEncoding.EncodeToString(testPNG) || img.URL != "" {
+		t.Fatalf("unexpected inline image: %+v", img)
+	}
+	if page.Type != AttachmentResource || page.URI != "file:///page.html" || page.Text != "<p>hi</p>" {
+		t.Fatalf("unexpected embedded resource: %+v", page)
+	}
+
+	// 超过内联上限：只保留元信息
+	m.attachmentCfg.MaxInlineKB = 0
+	out, _ = m.CallTool(ctx, "mcp.screenshot", nil)
+	if img = decode(out).Attachments[0]; !img.Omitted || img.Data != "" {
+		t.Fatalf("oversized image should be omitted: %+v", img)
+	}
+
+	// 配置 OBS：上传并返回签名地址
+	store := &fakeAttachmentStore{objects: make(map[string][]byte)}
+	m.SetAttachmentStore(store, AttachmentConfig{})
+	out, err = m.CallTool(ctx, "mcp.read_resource", map[string]interface{}{"uri": "file:///logo.png"})
+	if err != nil {
+		t.Fatalf("read blob resource: %v", err)
+	}
+	blob := decode(out).Attachments[0]
+	if blob.Data != "" || blob.Name != "logo.png" || !strings.HasPrefix(blob.ObjectKey, "mcp-attachments/docs/") || !strings.HasSuffix(blob.ObjectKey, ".png") {
+		t.Fatalf("unexpected uploaded attachment: %+v", blob)
+	}
+	if blob.URL != "https://obs.example.com/"+blob.ObjectKey || string(store.objects[blob.ObjectKey]) != string(testPNG) {
+		t.Fatalf("object not stored: %+v", blob)
+	}
+}
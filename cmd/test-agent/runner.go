@@ -220,7 +220,8 @@ func (r *Runner) runScenario(ctx context.Context, suiteID string, scenario *Test
 	r.saveRun(run)
 
 	dispatchStep := run.beginStep("dispatch_entry", "发送入口消息")
-	if err := r.dispatchScenario(run, scenario); err != nil {
+	call, err := r.dispatchScenario(run, scenario)
+	if err != nil {
 		run.finishStep(dispatchStep, StepStatusFailed, err.Error(), nil)
 		run.Result.FinalError = err.Error()
 		run.finish(RunStatusError)
@@ -232,10 +233,20 @@ func (r *Runner) runScenario(ctx context.Context, suiteID string, scenario *Test
 		"task_id":  taskID,
 	})
 	r.saveRun(run)
+	if call != nil {
+		// 超时或场景失败时取消等待，Call 会通知被测 agent 停止处理；
+		// 链路已正常结束时放弃等待，不再发送取消
+		callCtx, cancelCall := context.WithTimeout(ctx, scenario.timeoutOrDefault(r.cfg.DefaultTimeoutSec))
+		defer cancelCall()
+		go r.awaitReply(callCtx, call)
+	}
 
 	waitStep := run.beginStep("await_execution", "轮询等待链路完成")
 	r.saveRun(run)
 	waitErr := r.awaitScenario(ctx, run, scenario, waitStep)
+	if call != nil && waitErr == nil {
+		call.Abandon()
+	}
 	if waitErr != nil {
 		run.finishStep(waitStep, StepStatusFailed, waitErr.Error(), nil)
 	} else {
@@ -303,11 +314,12 @@ func (r *Runner) captureAvailability() (*GatewayHealthSnapshot, []GatewayAgentSn
 	return health, agents, nil
 }
 
-func (r *Runner) dispatchScenario(run *TestRun, scenario *TestScenario) error {
+// dispatchScenario 发送入口消息；tool_call / task_assign 返回可等待关联回复的请求句柄
+func (r *Runner) dispatchScenario(run *TestRun, scenario *TestScenario) (*uap.PendingCall, error) {
 	switch strings.TrimSpace(scenario.Entry.Type) {
 	case EntryTypeNotify:
 		if scenario.Entry.Notify == nil {
-			return fmt.Errorf("notify entry is required")
+			return nil, fmt.Errorf("notify entry is required")
 		}
 		payload := uap.NotifyPayload{
 			Channel:     scenario.Entry.Notify.Channel,
@@ -316,7 +328,7 @@ func (r *Runner) dispatchScenario(run *TestRun, scenario *TestScenario) error {
 			MessageType: scenario.Entry.Notify.MessageType,
 			Meta:        cloneMap(scenario.Entry.Notify.Meta),
 		}
-		return r.client.Send(&uap.Message{
+		return nil, r.client.Send(&uap.Message{
 			Type:    uap.MsgNotify,
 			ID:      run.TraceID,
 			From:    r.cfg.AgentID,
@@ -326,39 +338,44 @@ func (r *Runner) dispatchScenario(run *TestRun, scenario *TestScenario) error {
 		})
 	case EntryTypeTaskAssign:
 		if scenario.Entry.Task == nil {
-			return fmt.Errorf("task entry is required")
+			return nil, fmt.Errorf("task entry is required")
 		}
 		payload := uap.TaskAssignPayload{
 			TaskID:  run.TaskID,
 			Payload: scenario.Entry.Task.Payload,
 		}
-		return r.client.Send(&uap.Message{
+		return r.client.Start(&uap.Message{
 			Type:    uap.MsgTaskAssign,
 			ID:      run.TraceID,
-			From:    r.cfg.AgentID,
 			To:      scenario.Entry.ToAgent,
 			Payload: mustMarshal(payload),
-			Ts:      time.Now().UnixMilli(),
 		})
 	case EntryTypeToolCall:
 		if scenario.Entry.Tool == nil {
-			return fmt.Errorf("tool entry is required")
+			return nil, fmt.Errorf("tool entry is required")
 		}
 		payload := uap.ToolCallPayload{
 			ToolName:          scenario.Entry.Tool.ToolName,
 			Arguments:         scenario.Entry.Tool.Arguments,
 			AuthenticatedUser: scenario.Entry.Tool.AuthenticatedUser,
 		}
-		return r.client.Send(&uap.Message{
+		return r.client.Start(&uap.Message{
 			Type:    uap.MsgToolCall,
 			ID:      run.TraceID,
-			From:    r.cfg.AgentID,
 			To:      scenario.Entry.ToAgent,
 			Payload: mustMarshal(payload),
-			Ts:      time.Now().UnixMilli(),
 		})
 	default:
-		return fmt.Errorf("unsupported entry type: %s", scenario.Entry.Type)
+		return nil, fmt.Errorf("unsupported entry type: %s", scenario.Entry.Type)
+	}
+}
+
+// awaitReply 等待入口请求的关联回复（tool_result / task_complete / task_rejected / error），
+// 被 Call 消费的回复不会进入 OnMessage，这里转交 handleMessage 统一记录
+func (r *Runner) awaitReply(ctx context.Context, call *uap.PendingCall) {
+	reply, _ := call.Wait(ctx)
+	if reply != nil {
+		r.handleMessage(reply)
 	}
 }
 